@@ -0,0 +1,61 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// APIKeyScope limits what the requests authenticated with an APIKey may do
+type APIKeyScope string
+
+const (
+	// APIKeyScopeRead allows reading graphs, images and exports
+	APIKeyScopeRead APIKeyScope = "read"
+
+	// APIKeyScopeWrite allows creating and changing graphs and uploading
+	// images
+	APIKeyScopeWrite APIKeyScope = "write"
+)
+
+// AllAPIKeyScopes returns every scope an APIKey can be granted
+func AllAPIKeyScopes() []APIKeyScope {
+	return []APIKeyScope{
+		APIKeyScopeRead,
+		APIKeyScopeWrite,
+	}
+}
+
+// APIKey lets scripts act as the user that issued it without an interactive
+// session. Only the SHA-256 hash of the key's secret is stored.
+type APIKey struct {
+	ID     string
+	UserID string
+	Name   string
+	Scopes []APIKeyScope
+
+	// The most requests per minute the key may make
+	RateLimit int
+
+	SecretSHA256 string
+
+	// The first characters of the secret, so users can tell keys apart
+	Prefix string
+
+	CreatedAt time.Time
+}
+
+// HasScope reports whether the key was granted a scope
+func (k APIKey) HasScope(scope APIKeyScope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// APIKeyStore persists the APIKeys users have issued
+type APIKeyStore interface {
+	Add(ctx context.Context, key APIKey) error
+	GetBySecretSHA256(ctx context.Context, secretSHA256 string) (APIKey, error)
+	ListByUser(ctx context.Context, userID string) ([]APIKey, error)
+
+	// Delete revokes an APIKey issued by the user
+	Delete(ctx context.Context, userID string, id string) error
+}
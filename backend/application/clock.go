@@ -0,0 +1,19 @@
+package application
+
+import "time"
+
+// Clock tells the time the application records runs and completions at, so
+// that tests can control it
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the Clock reading the system time, used unless another is
+// provided
+var SystemClock Clock = systemClock{}
@@ -10,20 +10,208 @@ type CreateImageGraphCommand struct {
 	messages.BaseCommand
 	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
 	Name         string                  `json:"name"`
+	ExternalID   string                  `json:"external_id,omitempty"`
+	Owner        string                  `json:"owner,omitempty"`
 }
 
 func NewCreateImageGraphCommand(
 	imageGraphID imagegraph.ImageGraphID,
 	name string,
+	externalID string,
+	owner string,
 ) *CreateImageGraphCommand {
 	command := &CreateImageGraphCommand{
 		ImageGraphID: imageGraphID,
 		Name:         name,
+		ExternalID:   externalID,
+		Owner:        owner,
 	}
 	command.Init("CreateImageGraphCommand")
 	return command
 }
 
+type SetImageGraphPublicCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	Public       bool                    `json:"public"`
+}
+
+func NewSetImageGraphPublicCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	public bool,
+) *SetImageGraphPublicCommand {
+	command := &SetImageGraphPublicCommand{
+		ImageGraphID: imageGraphID,
+		Public:       public,
+	}
+	command.Init("SetImageGraphPublicCommand")
+	return command
+}
+
+type SetImageGraphPerformanceModeCommand struct {
+	messages.BaseCommand
+	ImageGraphID    imagegraph.ImageGraphID `json:"image_graph_id"`
+	PerformanceMode bool                    `json:"performance_mode"`
+}
+
+func NewSetImageGraphPerformanceModeCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	performanceMode bool,
+) *SetImageGraphPerformanceModeCommand {
+	command := &SetImageGraphPerformanceModeCommand{
+		ImageGraphID:    imageGraphID,
+		PerformanceMode: performanceMode,
+	}
+	command.Init("SetImageGraphPerformanceModeCommand")
+	return command
+}
+
+type SetImageGraphColorManagementCommand struct {
+	messages.BaseCommand
+	ImageGraphID    imagegraph.ImageGraphID `json:"image_graph_id"`
+	ColorManagement string                  `json:"color_management"`
+}
+
+func NewSetImageGraphColorManagementCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	colorManagement string,
+) *SetImageGraphColorManagementCommand {
+	command := &SetImageGraphColorManagementCommand{
+		ImageGraphID:    imageGraphID,
+		ColorManagement: colorManagement,
+	}
+	command.Init("SetImageGraphColorManagementCommand")
+	return command
+}
+
+// RegenerateImageGraphCommand runs an ImageGraph's pipeline again from the
+// current images of its Input nodes
+type RegenerateImageGraphCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+}
+
+func NewRegenerateImageGraphCommand(
+	imageGraphID imagegraph.ImageGraphID,
+) *RegenerateImageGraphCommand {
+	command := &RegenerateImageGraphCommand{
+		ImageGraphID: imageGraphID,
+	}
+	command.Init("RegenerateImageGraphCommand")
+	return command
+}
+
+type SetImageGraphParametersCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID    `json:"image_graph_id"`
+	Parameters   imagegraph.GraphParameters `json:"parameters"`
+}
+
+func NewSetImageGraphParametersCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	parameters imagegraph.GraphParameters,
+) *SetImageGraphParametersCommand {
+	command := &SetImageGraphParametersCommand{
+		ImageGraphID: imageGraphID,
+		Parameters:   parameters,
+	}
+	command.Init("SetImageGraphParametersCommand")
+	return command
+}
+
+type SetImageGraphSeedCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	Seed         *int                    `json:"seed"`
+}
+
+func NewSetImageGraphSeedCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	seed *int,
+) *SetImageGraphSeedCommand {
+	command := &SetImageGraphSeedCommand{
+		ImageGraphID: imageGraphID,
+		Seed:         seed,
+	}
+	command.Init("SetImageGraphSeedCommand")
+	return command
+}
+
+type ShareImageGraphCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	UserID       string                  `json:"user_id"`
+	Role         imagegraph.Role         `json:"role"`
+}
+
+func NewShareImageGraphCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	userID string,
+	role imagegraph.Role,
+) *ShareImageGraphCommand {
+	command := &ShareImageGraphCommand{
+		ImageGraphID: imageGraphID,
+		UserID:       userID,
+		Role:         role,
+	}
+	command.Init("ShareImageGraphCommand")
+	return command
+}
+
+type UnshareImageGraphCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	UserID       string                  `json:"user_id"`
+}
+
+func NewUnshareImageGraphCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	userID string,
+) *UnshareImageGraphCommand {
+	command := &UnshareImageGraphCommand{
+		ImageGraphID: imageGraphID,
+		UserID:       userID,
+	}
+	command.Init("UnshareImageGraphCommand")
+	return command
+}
+
+type AddImageGraphTagCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	Tag          string                  `json:"tag"`
+}
+
+func NewAddImageGraphTagCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	tag string,
+) *AddImageGraphTagCommand {
+	command := &AddImageGraphTagCommand{
+		ImageGraphID: imageGraphID,
+		Tag:          tag,
+	}
+	command.Init("AddImageGraphTagCommand")
+	return command
+}
+
+type RemoveImageGraphTagCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	Tag          string                  `json:"tag"`
+}
+
+func NewRemoveImageGraphTagCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	tag string,
+) *RemoveImageGraphTagCommand {
+	command := &RemoveImageGraphTagCommand{
+		ImageGraphID: imageGraphID,
+		Tag:          tag,
+	}
+	command.Init("RemoveImageGraphTagCommand")
+	return command
+}
+
 type AddImageGraphNodeCommand struct {
 	messages.BaseCommand
 	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
@@ -31,6 +219,10 @@ type AddImageGraphNodeCommand struct {
 	NodeType     imagegraph.NodeType     `json:"node_type"`
 	Name         string                  `json:"name"`
 	Config       imagegraph.NodeConfig   `json:"config"`
+	ExternalID   string                  `json:"external_id,omitempty"`
+	// Inputs are added to the node on top of its type's inputs, along with
+	// the node, so that it is never left without them
+	Inputs []imagegraph.InputName `json:"inputs,omitempty"`
 }
 
 func NewAddImageGraphNodeCommand(
@@ -39,6 +231,7 @@ func NewAddImageGraphNodeCommand(
 	nodeType imagegraph.NodeType,
 	name string,
 	config imagegraph.NodeConfig,
+	externalID string,
 ) *AddImageGraphNodeCommand {
 	command := &AddImageGraphNodeCommand{
 		ImageGraphID: imageGraphID,
@@ -46,6 +239,7 @@ func NewAddImageGraphNodeCommand(
 		NodeType:     nodeType,
 		Name:         name,
 		Config:       config,
+		ExternalID:   externalID,
 	}
 	command.Init("AddImageGraphNodeCommand")
 	return command
@@ -76,6 +270,8 @@ type ConnectImageGraphNodesCommand struct {
 	OutputName   imagegraph.OutputName   `json:"output_name"`
 	ToNodeID     imagegraph.NodeID       `json:"to_node_id"`
 	InputName    imagegraph.InputName    `json:"input_name"`
+	// Transform is made to images passed along the connection
+	Transform string `json:"transform,omitempty"`
 }
 
 func NewConnectImageGraphNodesCommand(
@@ -123,6 +319,141 @@ func NewDisconnectImageGraphNodesCommand(
 	return command
 }
 
+type AddImageGraphNodeInputCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	InputName    imagegraph.InputName    `json:"input_name"`
+}
+
+func NewAddImageGraphNodeInputCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	inputName imagegraph.InputName,
+) *AddImageGraphNodeInputCommand {
+	command := &AddImageGraphNodeInputCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		InputName:    inputName,
+	}
+	command.Init("AddImageGraphNodeInputCommand")
+	return command
+}
+
+type RemoveImageGraphNodeInputCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	InputName    imagegraph.InputName    `json:"input_name"`
+}
+
+func NewRemoveImageGraphNodeInputCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	inputName imagegraph.InputName,
+) *RemoveImageGraphNodeInputCommand {
+	command := &RemoveImageGraphNodeInputCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		InputName:    inputName,
+	}
+	command.Init("RemoveImageGraphNodeInputCommand")
+	return command
+}
+
+type AddImageGraphPaletteSwatchCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Color        string                  `json:"color"`
+	Enabled      bool                    `json:"enabled"`
+	Index        int                     `json:"index"`
+}
+
+func NewAddImageGraphPaletteSwatchCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	color string,
+	enabled bool,
+	index int,
+) *AddImageGraphPaletteSwatchCommand {
+	command := &AddImageGraphPaletteSwatchCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Color:        color,
+		Enabled:      enabled,
+		Index:        index,
+	}
+	command.Init("AddImageGraphPaletteSwatchCommand")
+	return command
+}
+
+type SetImageGraphPaletteSwatchEnabledCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Color        string                  `json:"color"`
+	Enabled      bool                    `json:"enabled"`
+}
+
+func NewSetImageGraphPaletteSwatchEnabledCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	color string,
+	enabled bool,
+) *SetImageGraphPaletteSwatchEnabledCommand {
+	command := &SetImageGraphPaletteSwatchEnabledCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Color:        color,
+		Enabled:      enabled,
+	}
+	command.Init("SetImageGraphPaletteSwatchEnabledCommand")
+	return command
+}
+
+type RemoveImageGraphPaletteSwatchCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Color        string                  `json:"color"`
+}
+
+func NewRemoveImageGraphPaletteSwatchCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	color string,
+) *RemoveImageGraphPaletteSwatchCommand {
+	command := &RemoveImageGraphPaletteSwatchCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Color:        color,
+	}
+	command.Init("RemoveImageGraphPaletteSwatchCommand")
+	return command
+}
+
+type ReorderImageGraphPaletteSwatchesCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Colors       []string                `json:"colors"`
+}
+
+func NewReorderImageGraphPaletteSwatchesCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	colors []string,
+) *ReorderImageGraphPaletteSwatchesCommand {
+	command := &ReorderImageGraphPaletteSwatchesCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Colors:       colors,
+	}
+	command.Init("ReorderImageGraphPaletteSwatchesCommand")
+	return command
+}
+
 type SetImageGraphNodeOutputImageCommand struct {
 	messages.BaseCommand
 	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
@@ -130,6 +461,8 @@ type SetImageGraphNodeOutputImageCommand struct {
 	OutputName   imagegraph.OutputName   `json:"output_name"`
 	ImageID      imagegraph.ImageID      `json:"image_id"`
 	NodeVersion  imagegraph.NodeVersion  `json:"node_version"`
+	// Upload is set when the image was uploaded, to record on the event
+	Upload *imagegraph.UploadedImage `json:"upload,omitempty"`
 }
 
 func NewSetImageGraphNodeOutputImageCommand(
@@ -150,6 +483,48 @@ func NewSetImageGraphNodeOutputImageCommand(
 	return command
 }
 
+type SetImageGraphNodeGenerationFailedCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Message      string                  `json:"message"`
+	NodeVersion  imagegraph.NodeVersion  `json:"node_version"`
+}
+
+func NewSetImageGraphNodeGenerationFailedCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	message string,
+	nodeVersion imagegraph.NodeVersion,
+) *SetImageGraphNodeGenerationFailedCommand {
+	command := &SetImageGraphNodeGenerationFailedCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Message:      message,
+		NodeVersion:  nodeVersion,
+	}
+	command.Init("SetImageGraphNodeGenerationFailedCommand")
+	return command
+}
+
+type ResumeImageGraphNodeGenerationCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+}
+
+func NewResumeImageGraphNodeGenerationCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+) *ResumeImageGraphNodeGenerationCommand {
+	command := &ResumeImageGraphNodeGenerationCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+	}
+	command.Init("ResumeImageGraphNodeGenerationCommand")
+	return command
+}
+
 type UnsetImageGraphNodeOutputImageCommand struct {
 	messages.BaseCommand
 	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
@@ -171,6 +546,32 @@ func NewUnsetImageGraphNodeOutputImageCommand(
 	return command
 }
 
+// PromoteImageGraphNodeOutputVariantCommand makes a previous image of a node
+// output its current image again
+type PromoteImageGraphNodeOutputVariantCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	OutputName   imagegraph.OutputName   `json:"output_name"`
+	ImageID      imagegraph.ImageID      `json:"image_id"`
+}
+
+func NewPromoteImageGraphNodeOutputVariantCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName imagegraph.OutputName,
+	imageID imagegraph.ImageID,
+) *PromoteImageGraphNodeOutputVariantCommand {
+	command := &PromoteImageGraphNodeOutputVariantCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		OutputName:   outputName,
+		ImageID:      imageID,
+	}
+	command.Init("PromoteImageGraphNodeOutputVariantCommand")
+	return command
+}
+
 type SetImageGraphNodePreviewCommand struct {
 	messages.BaseCommand
 	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
@@ -255,21 +656,191 @@ func NewSetImageGraphNodeNameCommand(
 	return command
 }
 
+type SetImageGraphNodeDescriptionCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Description  string                  `json:"description"`
+}
+
+func NewSetImageGraphNodeDescriptionCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	description string,
+) *SetImageGraphNodeDescriptionCommand {
+	command := &SetImageGraphNodeDescriptionCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Description:  description,
+	}
+	command.Init("SetImageGraphNodeDescriptionCommand")
+	return command
+}
+
+type AddImageGraphNodeTagCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Tag          string                  `json:"tag"`
+}
+
+func NewAddImageGraphNodeTagCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	tag string,
+) *AddImageGraphNodeTagCommand {
+	command := &AddImageGraphNodeTagCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Tag:          tag,
+	}
+	command.Init("AddImageGraphNodeTagCommand")
+	return command
+}
+
+type RemoveImageGraphNodeTagCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Tag          string                  `json:"tag"`
+}
+
+func NewRemoveImageGraphNodeTagCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	tag string,
+) *RemoveImageGraphNodeTagCommand {
+	command := &RemoveImageGraphNodeTagCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Tag:          tag,
+	}
+	command.Init("RemoveImageGraphNodeTagCommand")
+	return command
+}
+
+type SetImageGraphNodeBypassCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Bypassed     bool                    `json:"bypassed"`
+}
+
+func NewSetImageGraphNodeBypassCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	bypassed bool,
+) *SetImageGraphNodeBypassCommand {
+	command := &SetImageGraphNodeBypassCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Bypassed:     bypassed,
+	}
+	command.Init("SetImageGraphNodeBypassCommand")
+	return command
+}
+
+type SetImageGraphNodePinnedCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+	Pinned       bool                    `json:"pinned"`
+}
+
+func NewSetImageGraphNodePinnedCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	pinned bool,
+) *SetImageGraphNodePinnedCommand {
+	command := &SetImageGraphNodePinnedCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Pinned:       pinned,
+	}
+	command.Init("SetImageGraphNodePinnedCommand")
+	return command
+}
+
+type SetImageGraphNodeImplementationCommand struct {
+	messages.BaseCommand
+	ImageGraphID   imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID         imagegraph.NodeID       `json:"node_id"`
+	Implementation int                     `json:"implementation"`
+}
+
+func NewSetImageGraphNodeImplementationCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	implementation int,
+) *SetImageGraphNodeImplementationCommand {
+	command := &SetImageGraphNodeImplementationCommand{
+		ImageGraphID:   imageGraphID,
+		NodeID:         nodeID,
+		Implementation: implementation,
+	}
+	command.Init("SetImageGraphNodeImplementationCommand")
+	return command
+}
+
+type SetImageGraphNodeExpressionsCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID      `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID            `json:"node_id"`
+	Expressions  imagegraph.ConfigExpressions `json:"expressions"`
+}
+
+func NewSetImageGraphNodeExpressionsCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	expressions imagegraph.ConfigExpressions,
+) *SetImageGraphNodeExpressionsCommand {
+	command := &SetImageGraphNodeExpressionsCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+		Expressions:  expressions,
+	}
+	command.Init("SetImageGraphNodeExpressionsCommand")
+	return command
+}
+
+type UpgradeImageGraphNodeImplementationCommand struct {
+	messages.BaseCommand
+	ImageGraphID imagegraph.ImageGraphID `json:"image_graph_id"`
+	NodeID       imagegraph.NodeID       `json:"node_id"`
+}
+
+func NewUpgradeImageGraphNodeImplementationCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+) *UpgradeImageGraphNodeImplementationCommand {
+	command := &UpgradeImageGraphNodeImplementationCommand{
+		ImageGraphID: imageGraphID,
+		NodeID:       nodeID,
+	}
+	command.Init("UpgradeImageGraphNodeImplementationCommand")
+	return command
+}
+
 // Layout Commands
 
+// UpdateLayoutCommand replaces the node layouts a user saved for an
+// ImageGraph. An empty UserID updates the layout shared by everyone.
 type UpdateLayoutCommand struct {
 	messages.BaseCommand
-	GraphID       imagegraph.ImageGraphID `json:"graph_id"`
-	NodePositions []ui.NodePosition       `json:"node_positions"`
+	GraphID     imagegraph.ImageGraphID `json:"graph_id"`
+	UserID      string                  `json:"user_id"`
+	NodeLayouts []ui.NodeLayout         `json:"node_layouts"`
 }
 
 func NewUpdateLayoutCommand(
 	graphID imagegraph.ImageGraphID,
-	nodePositions []ui.NodePosition,
+	userID string,
+	nodeLayouts []ui.NodeLayout,
 ) *UpdateLayoutCommand {
 	command := &UpdateLayoutCommand{
-		GraphID:       graphID,
-		NodePositions: nodePositions,
+		GraphID:     graphID,
+		UserID:      userID,
+		NodeLayouts: nodeLayouts,
 	}
 	command.Init("UpdateLayoutCommand")
 	return command
@@ -277,9 +848,12 @@ func NewUpdateLayoutCommand(
 
 // Viewport Commands
 
+// UpdateViewportCommand sets the zoom and pan a user saved for an
+// ImageGraph. An empty UserID updates the viewport shared by everyone.
 type UpdateViewportCommand struct {
 	messages.BaseCommand
 	GraphID imagegraph.ImageGraphID `json:"graph_id"`
+	UserID  string                  `json:"user_id"`
 	Zoom    float64                 `json:"zoom"`
 	PanX    float64                 `json:"pan_x"`
 	PanY    float64                 `json:"pan_y"`
@@ -287,10 +861,12 @@ type UpdateViewportCommand struct {
 
 func NewUpdateViewportCommand(
 	graphID imagegraph.ImageGraphID,
+	userID string,
 	zoom, panX, panY float64,
 ) *UpdateViewportCommand {
 	command := &UpdateViewportCommand{
 		GraphID: graphID,
+		UserID:  userID,
 		Zoom:    zoom,
 		PanX:    panX,
 		PanY:    panY,
@@ -298,3 +874,92 @@ func NewUpdateViewportCommand(
 	command.Init("UpdateViewportCommand")
 	return command
 }
+
+type SaveViewportBookmarkCommand struct {
+	messages.BaseCommand
+	GraphID  imagegraph.ImageGraphID `json:"graph_id"`
+	UserID   string                  `json:"user_id"`
+	Bookmark ui.ViewportBookmark     `json:"bookmark"`
+}
+
+func NewSaveViewportBookmarkCommand(
+	graphID imagegraph.ImageGraphID,
+	userID string,
+	bookmark ui.ViewportBookmark,
+) *SaveViewportBookmarkCommand {
+	command := &SaveViewportBookmarkCommand{
+		GraphID:  graphID,
+		UserID:   userID,
+		Bookmark: bookmark,
+	}
+	command.Init("SaveViewportBookmarkCommand")
+	return command
+}
+
+type DeleteViewportBookmarkCommand struct {
+	messages.BaseCommand
+	GraphID imagegraph.ImageGraphID `json:"graph_id"`
+	UserID  string                  `json:"user_id"`
+	Name    string                  `json:"name"`
+}
+
+func NewDeleteViewportBookmarkCommand(
+	graphID imagegraph.ImageGraphID,
+	userID string,
+	name string,
+) *DeleteViewportBookmarkCommand {
+	command := &DeleteViewportBookmarkCommand{
+		GraphID: graphID,
+		UserID:  userID,
+		Name:    name,
+	}
+	command.Init("DeleteViewportBookmarkCommand")
+	return command
+}
+
+// Outbox Commands
+
+// PublishOutboxEventsCommand dispatches events committed to the outbox to
+// their handlers
+type PublishOutboxEventsCommand struct {
+	messages.BaseCommand
+	Events []messages.Event `json:"events"`
+}
+
+func NewPublishOutboxEventsCommand(events []messages.Event) *PublishOutboxEventsCommand {
+	command := &PublishOutboxEventsCommand{
+		Events: events,
+	}
+	command.Init("PublishOutboxEventsCommand")
+	return command
+}
+
+type MarkOutboxEventsPublishedCommand struct {
+	messages.BaseCommand
+	EventIDs []int64 `json:"event_ids"`
+}
+
+func NewMarkOutboxEventsPublishedCommand(eventIDs []int64) *MarkOutboxEventsPublishedCommand {
+	command := &MarkOutboxEventsPublishedCommand{
+		EventIDs: eventIDs,
+	}
+	command.Init("MarkOutboxEventsPublishedCommand")
+	return command
+}
+
+// Dead Letter Commands
+
+// ReplayDeadLetterCommand dispatches the event of a DeadLetter again, to the
+// handler that failed it
+type ReplayDeadLetterCommand struct {
+	messages.BaseCommand
+	Event messages.Event `json:"event"`
+}
+
+func NewReplayDeadLetterCommand(event messages.Event) *ReplayDeadLetterCommand {
+	command := &ReplayDeadLetterCommand{
+		Event: event,
+	}
+	command.Init("ReplayDeadLetterCommand")
+	return command
+}
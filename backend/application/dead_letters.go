@@ -0,0 +1,310 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+)
+
+// DeadLetter is an event one of its handlers kept failing, kept with the
+// handler's last error so that it can be inspected and replayed
+type DeadLetter struct {
+	ID            int64
+	Handler       string
+	Event         messages.Event
+	Error         string
+	Attempts      int
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+}
+
+// DeadLetterStore persists DeadLetters until they are replayed or discarded
+type DeadLetterStore interface {
+	// Add stores a DeadLetter, returning the ID it was given
+	Add(ctx context.Context, letter DeadLetter) (int64, error)
+	Get(ctx context.Context, id int64) (DeadLetter, error)
+	// List retrieves the most recent DeadLetters, newest first
+	List(ctx context.Context, limit int) ([]DeadLetter, error)
+	// Update records another failure of a DeadLetter's handler
+	Update(ctx context.Context, letter DeadLetter) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// DeadLetterQueue retries the event handlers it is given when they fail, and stores the events whose handlers still fail as
+// DeadLetters. Handlers are retried on the message bus, which handles
+// nothing else in the meantime, so backoffs should be short.
+type DeadLetterQueue struct {
+	mb       *messagebus.MessageBus
+	store    DeadLetterStore
+	logger   *slog.Logger
+	attempts int
+	backoff  time.Duration
+	clock    Clock
+}
+
+// DeadLetterQueueOption configures optional DeadLetterQueue behavior
+type DeadLetterQueueOption func(*DeadLetterQueue)
+
+// WithDeadLetterRetries makes up to attempts attempts at handling each
+// event, waiting backoff after the first failure and doubling the wait after
+// each one that follows
+func WithDeadLetterRetries(attempts int, backoff time.Duration) DeadLetterQueueOption {
+	return func(q *DeadLetterQueue) {
+		q.attempts = max(attempts, 1)
+		q.backoff = backoff
+	}
+}
+
+// WithDeadLetterClock sets the Clock DeadLetters record failures at
+func WithDeadLetterClock(clock Clock) DeadLetterQueueOption {
+	return func(q *DeadLetterQueue) {
+		q.clock = clock
+	}
+}
+
+// NewDeadLetterQueue creates a DeadLetterQueue replaying DeadLetters on a
+// message bus and registers the command handler it replays them with. It
+// must be created before the event handlers it is given to.
+func NewDeadLetterQueue(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	store DeadLetterStore,
+	logger *slog.Logger,
+	opts ...DeadLetterQueueOption,
+) (
+	*DeadLetterQueue,
+	error,
+) {
+	queue := &DeadLetterQueue{
+		mb:       mb,
+		store:    store,
+		logger:   logger,
+		attempts: 3,
+		backoff:  100 * time.Millisecond,
+		clock:    SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(queue)
+	}
+
+	if err := registerCommandHandler(mb, queues, queue.HandleReplayDeadLetterCommand); err != nil {
+		return nil, fmt.Errorf("could not create dead letter queue: %w", err)
+	}
+
+	return queue, nil
+}
+
+// List retrieves the most recent DeadLetters, newest first
+func (q *DeadLetterQueue) List(ctx context.Context, limit int) ([]DeadLetter, error) {
+	return q.store.List(ctx, limit)
+}
+
+func (q *DeadLetterQueue) Get(ctx context.Context, id int64) (DeadLetter, error) {
+	return q.store.Get(ctx, id)
+}
+
+// Discard removes a DeadLetter without handling its event
+func (q *DeadLetterQueue) Discard(ctx context.Context, id int64) error {
+	return q.store.Delete(ctx, id)
+}
+
+// Replay dispatches a DeadLetter's event to the handler that failed it,
+// retrying it as usual. The DeadLetter is removed once the handler succeeds,
+// and otherwise records the new failures and ErrDeadLetterReplayFailed is
+// returned. The message bus must be running.
+func (q *DeadLetterQueue) Replay(ctx context.Context, id int64) error {
+	letter, err := q.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	replay := &deadLetterReplay{
+		handler: letter.Handler,
+		event:   letter.Event,
+		outcome: make(chan deadLetterOutcome, 1),
+	}
+
+	replayCtx := context.WithValue(ctx, deadLetterReplayKey{}, replay)
+	if err := q.mb.HandleCommand(replayCtx, NewReplayDeadLetterCommand(letter.Event)); err != nil {
+		return fmt.Errorf("could not replay dead letter %d: %w", id, err)
+	}
+
+	// The message bus dispatches the events a command returns before it
+	// handles the next command, so once a command without an event is
+	// handled the replayed event has been through its handler
+	if err := q.mb.HandleCommand(ctx, NewReplayDeadLetterCommand(nil)); err != nil {
+		return fmt.Errorf("could not replay dead letter %d: %w", id, err)
+	}
+
+	var outcome deadLetterOutcome
+	select {
+	case outcome = <-replay.outcome:
+	default:
+		return fmt.Errorf("%w: no handler %s is registered for %s", ErrDeadLetterReplayFailed, letter.Handler, letter.Event.GetType())
+	}
+
+	if outcome.err == nil {
+		if err := q.store.Delete(ctx, id); err != nil {
+			return fmt.Errorf("could not remove replayed dead letter %d: %w", id, err)
+		}
+		return nil
+	}
+
+	letter.Error = outcome.err.Error()
+	letter.Attempts += outcome.attempts
+	letter.LastFailedAt = q.clock.Now()
+
+	if err := q.store.Update(ctx, letter); err != nil {
+		return fmt.Errorf("could not record failed replay of dead letter %d: %w", id, err)
+	}
+
+	return fmt.Errorf("%w: %v", ErrDeadLetterReplayFailed, outcome.err)
+}
+
+// HandleReplayDeadLetterCommand returns the command's event, if it has one,
+// for the message bus to dispatch
+func (q *DeadLetterQueue) HandleReplayDeadLetterCommand(
+	ctx context.Context,
+	command *ReplayDeadLetterCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	if command.Event == nil {
+		return nil, nil
+	}
+
+	return []messages.Event{command.Event}, nil
+}
+
+// handle handles an event, retrying the handler while it fails. Once the
+// attempts run out the event is stored as a DeadLetter, unless it is being
+// replayed, and the last error is returned.
+func (q *DeadLetterQueue) handle(
+	ctx context.Context,
+	name string,
+	event messages.Event,
+	replay *deadLetterReplay,
+	handler func(context.Context) ([]messages.Event, error),
+) (
+	[]messages.Event,
+	error,
+) {
+	attempt := 1
+	events, err := handler(ctx)
+
+	for err != nil && attempt < q.attempts {
+		q.logger.WarnContext(ctx, "event handler failed, retrying",
+			"handler", name,
+			"event", event.GetType(),
+			"attempt", attempt,
+			"error", err,
+		)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(q.backoff << (attempt - 1)):
+		}
+
+		// An event whose context ended can't be handled now
+		if ctx.Err() != nil {
+			break
+		}
+
+		attempt++
+		events, err = handler(ctx)
+	}
+
+	if replay != nil {
+		replay.outcome <- deadLetterOutcome{attempts: attempt, err: err}
+		return events, err
+	}
+
+	if err == nil {
+		return events, nil
+	}
+
+	now := q.clock.Now()
+	letter := DeadLetter{
+		Handler:       name,
+		Event:         event,
+		Error:         err.Error(),
+		Attempts:      attempt,
+		FirstFailedAt: now,
+		LastFailedAt:  now,
+	}
+
+	// The event is kept even if the context it was handled in ended
+	id, storeErr := q.store.Add(context.WithoutCancel(ctx), letter)
+	if storeErr != nil {
+		q.logger.ErrorContext(ctx, "could not store dead letter",
+			"handler", name,
+			"event", event.GetType(),
+			"error", storeErr,
+		)
+		return events, errors.Join(err, storeErr)
+	}
+
+	q.logger.ErrorContext(ctx, "event handler failed, stored dead letter",
+		"handler", name,
+		"event", event.GetType(),
+		"attempts", attempt,
+		"dead_letter_id", id,
+		"error", err,
+	)
+
+	return events, err
+}
+
+type deadLetterReplayKey struct{}
+
+// deadLetterReplay marks the context of a replayed DeadLetter, so that only
+// the handler that failed its event handles it again
+type deadLetterReplay struct {
+	handler string
+	event   messages.Event
+	outcome chan deadLetterOutcome
+}
+
+type deadLetterOutcome struct {
+	attempts int
+	err      error
+}
+
+// deadLettered wraps an event handler so that it is retried and
+// dead-lettered by the DeadLetterQueue, if any, and handles only the replays
+// of its own DeadLetters
+func deadLettered[E messages.Event](
+	queue *DeadLetterQueue,
+	name string,
+	handler func(context.Context, E) ([]messages.Event, error),
+) func(context.Context, E) ([]messages.Event, error) {
+	return func(ctx context.Context, event E) ([]messages.Event, error) {
+		var replay *deadLetterReplay
+		if marked, ok := ctx.Value(deadLetterReplayKey{}).(*deadLetterReplay); ok && marked != nil {
+			// Events that follow from the replayed event are handled
+			// as usual
+			if marked.event == messages.Event(event) {
+				if marked.handler != name {
+					return nil, nil
+				}
+				replay = marked
+			}
+			ctx = context.WithValue(ctx, deadLetterReplayKey{}, (*deadLetterReplay)(nil))
+		}
+
+		if queue == nil {
+			return handler(ctx, event)
+		}
+
+		return queue.handle(ctx, name, event, replay, func(ctx context.Context) ([]messages.Event, error) {
+			return handler(ctx, event)
+		})
+	}
+}
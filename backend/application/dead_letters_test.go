@@ -0,0 +1,202 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/infratest"
+)
+
+type deadLetters struct {
+	mu      sync.Mutex
+	letters map[int64]DeadLetter
+	nextID  int64
+}
+
+func (d *deadLetters) Add(_ context.Context, letter DeadLetter) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	letter.ID = d.nextID
+	d.letters[letter.ID] = letter
+	return letter.ID, nil
+}
+
+func (d *deadLetters) Get(_ context.Context, id int64) (DeadLetter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	letter, ok := d.letters[id]
+	if !ok {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+	return letter, nil
+}
+
+func (d *deadLetters) List(context.Context, int) ([]DeadLetter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var letters []DeadLetter
+	for _, letter := range d.letters {
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (d *deadLetters) Update(_ context.Context, letter DeadLetter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.letters[letter.ID] = letter
+	return nil
+}
+
+func (d *deadLetters) Delete(_ context.Context, id int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.letters, id)
+	return nil
+}
+
+// publishEventCommand has its event dispatched by the message bus
+type publishEventCommand struct {
+	messages.BaseCommand
+	event messages.Event
+}
+
+func newPublishEventCommand(event messages.Event) *publishEventCommand {
+	command := &publishEventCommand{event: event}
+	command.Init("PublishEventCommand")
+	return command
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	ctx := context.Background()
+
+	store := &deadLetters{letters: map[int64]DeadLetter{}}
+	clock := infratest.NewClock(time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC))
+
+	mb := messagebus.New()
+	queue, err := NewDeadLetterQueue(
+		mb,
+		nil,
+		store,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithDeadLetterRetries(3, time.Millisecond),
+		WithDeadLetterClock(clock),
+	)
+	if err != nil {
+		t.Fatalf("failed to create dead letter queue: %v", err)
+	}
+
+	err = registerCommandHandler(mb, nil, func(_ context.Context, command *publishEventCommand) ([]messages.Event, error) {
+		return []messages.Event{command.event}, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register command handler: %v", err)
+	}
+
+	// failures is how many more times the failing handler fails
+	var failures, failingCalls, steadyCalls int
+	err = errors.Join(
+		registerNamedEventHandler(mb, nil, queue, "failing", func(context.Context, *imagegraph.NodeNeedsOutputsEvent) ([]messages.Event, error) {
+			failingCalls++
+			if failures > 0 {
+				failures--
+				return nil, errors.New("storage unavailable")
+			}
+			return nil, nil
+		}),
+		registerNamedEventHandler(mb, nil, queue, "steady", func(context.Context, *imagegraph.NodeNeedsOutputsEvent) ([]messages.Event, error) {
+			steadyCalls++
+			return nil, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to register event handlers: %v", err)
+	}
+
+	bus := infratest.StartBus(t, mb)
+
+	t.Run("retries handlers that fail", func(t *testing.T) {
+		failures, failingCalls = 2, 0
+		bus.MustHandle(newPublishEventCommand(nodeNeedsOutputsEvent(t)))
+
+		if failingCalls != 3 {
+			t.Errorf("expected the handler to succeed on its third attempt, got %d calls", failingCalls)
+		}
+		if len(store.letters) != 0 {
+			t.Errorf("expected no dead letters, got %v", store.letters)
+		}
+	})
+
+	var letter DeadLetter
+	event := nodeNeedsOutputsEvent(t)
+
+	t.Run("stores events whose handlers keep failing", func(t *testing.T) {
+		failures, failingCalls = 10, 0
+		bus.MustHandle(newPublishEventCommand(event))
+
+		letters, _ := store.List(ctx, 10)
+		if len(letters) != 1 {
+			t.Fatalf("expected a dead letter, got %v", letters)
+		}
+
+		letter = letters[0]
+		if letter.Handler != "failing" || letter.Event != messages.Event(event) || letter.Attempts != 3 {
+			t.Errorf("expected the failing handler's event after 3 attempts, got %+v", letter)
+		}
+		if letter.Error != "storage unavailable" || !letter.FirstFailedAt.Equal(clock.Now()) {
+			t.Errorf("expected the handler's error at the current time, got %+v", letter)
+		}
+	})
+
+	t.Run("records failed replays", func(t *testing.T) {
+		steadyCalls = 0
+		failed := clock.Advance(time.Hour)
+
+		err := queue.Replay(ctx, letter.ID)
+		if !errors.Is(err, ErrDeadLetterReplayFailed) {
+			t.Fatalf("expected ErrDeadLetterReplayFailed, got %v", err)
+		}
+
+		replayed, err := store.Get(ctx, letter.ID)
+		if err != nil {
+			t.Fatalf("expected the dead letter to be kept: %v", err)
+		}
+		if replayed.Attempts != 6 || !replayed.LastFailedAt.Equal(failed) || !replayed.FirstFailedAt.Equal(letter.FirstFailedAt) {
+			t.Errorf("expected the replay's attempts to be recorded, got %+v", replayed)
+		}
+		if steadyCalls != 0 {
+			t.Errorf("expected only the failing handler to handle the replay, got %d steady calls", steadyCalls)
+		}
+	})
+
+	t.Run("removes dead letters replayed successfully", func(t *testing.T) {
+		failures, failingCalls, steadyCalls = 0, 0, 0
+
+		if err := queue.Replay(ctx, letter.ID); err != nil {
+			t.Fatalf("failed to replay dead letter: %v", err)
+		}
+
+		if failingCalls != 1 || steadyCalls != 0 {
+			t.Errorf("expected only the failing handler to handle the replay, got %d and %d calls", failingCalls, steadyCalls)
+		}
+		if _, err := store.Get(ctx, letter.ID); !errors.Is(err, ErrDeadLetterNotFound) {
+			t.Errorf("expected the dead letter to be removed, got %v", err)
+		}
+	})
+
+	t.Run("reports missing dead letters", func(t *testing.T) {
+		if err := queue.Replay(ctx, letter.ID); !errors.Is(err, ErrDeadLetterNotFound) {
+			t.Errorf("expected ErrDeadLetterNotFound, got %v", err)
+		}
+	})
+}
@@ -10,3 +10,49 @@ var ErrLayoutNotFound = errors.New("layout not found")
 
 // ErrViewportNotFound is returned when Viewport cannot be found
 var ErrViewportNotFound = errors.New("viewport not found")
+
+// ErrViewportBookmarkNotFound is returned when a Viewport has no bookmark
+// with the requested name
+var ErrViewportBookmarkNotFound = errors.New("viewport bookmark not found")
+
+// ErrDuplicateExternalID is returned when an ImageGraph is added with an
+// external ID that is already in use
+var ErrDuplicateExternalID = errors.New("external ID already in use")
+
+// ErrGraphLimitExceeded is returned when a command would take an ImageGraph
+// past its configured complexity limits
+var ErrGraphLimitExceeded = errors.New("image graph limit exceeded")
+
+// ErrImageGraphBusy is returned when a command is rejected because too many
+// commands are already queued for its ImageGraph
+var ErrImageGraphBusy = errors.New("image graph busy")
+
+// ErrAPIKeyNotFound is returned when an APIKey cannot be found
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrPermissionDenied is returned when the user issuing a command doesn't have
+// the role on an ImageGraph that the command requires
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrWebhookNotFound is returned when a Webhook cannot be found
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrSnapshotNotFound is returned when a Snapshot cannot be found
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrSnapshotNameTaken is returned when a Snapshot is added with the name of
+// another Snapshot of the same ImageGraph
+var ErrSnapshotNameTaken = errors.New("snapshot name already in use")
+
+// ErrGraphLinkNotFound is returned when a GraphLink cannot be found
+var ErrGraphLinkNotFound = errors.New("graph link not found")
+
+// ErrScheduleNotFound is returned when an ImageGraph has no Schedule
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrDeadLetterNotFound is returned when a DeadLetter cannot be found
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrDeadLetterReplayFailed is returned when the handler of a replayed
+// DeadLetter fails again
+var ErrDeadLetterReplayFailed = errors.New("dead letter replay failed")
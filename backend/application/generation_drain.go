@@ -0,0 +1,86 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// PendingGeneration marks a node whose output generation was interrupted by
+// shutdown, so that it can be resumed once the application restarts
+type PendingGeneration struct {
+	ImageGraphID imagegraph.ImageGraphID
+	NodeID       imagegraph.NodeID
+}
+
+// PendingGenerationStore persists the PendingGenerations left over when the
+// application shuts down
+type PendingGenerationStore interface {
+	Add(ctx context.Context, pending []PendingGeneration) error
+
+	// Take removes and returns every stored PendingGeneration
+	Take(ctx context.Context) ([]PendingGeneration, error)
+}
+
+// Drain waits for in-flight output generation to finish until ctx is done,
+// including generation started by the outputs of the generation it waits
+// for. Generation still running then is cancelled and returned so that it
+// can be resumed later. The message bus must keep running while draining,
+// since finished generation sets its outputs with commands.
+func (h *ImageGraphEventHandlers) Drain(ctx context.Context) []PendingGeneration {
+	keys := h.generations.drain(ctx)
+
+	pending := make([]PendingGeneration, 0, len(keys))
+	for _, key := range keys {
+		pending = append(pending, PendingGeneration{
+			ImageGraphID: key.imageGraphID,
+			NodeID:       key.nodeID,
+		})
+	}
+
+	return pending
+}
+
+// WaitIdle waits until no output generation or input preview is running,
+// including any started while waiting, and returns how many have finished
+// so far. Finished generation may have sent commands whose events start
+// more, so a caller waiting for a pipeline to settle must let the message
+// bus dispatch those events and wait again until the count stops changing.
+func (h *ImageGraphEventHandlers) WaitIdle(ctx context.Context) (uint64, error) {
+	completed, err := h.generations.wait(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("gave up waiting for generation to finish: %w", err)
+	}
+
+	return completed, nil
+}
+
+// ResumePendingGenerations requests the outputs of the nodes whose
+// generation was interrupted by the last shutdown again. Nodes that have
+// stopped generating since are left alone, and deleted ImageGraphs are
+// skipped. It returns the number of PendingGenerations taken from the store.
+func ResumePendingGenerations(
+	ctx context.Context,
+	mb CommandHandler,
+	store PendingGenerationStore,
+) (
+	int,
+	error,
+) {
+	pending, err := store.Take(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not get pending generations: %w", err)
+	}
+
+	var errs []error
+	for _, p := range pending {
+		err := mb.HandleCommand(ctx, NewResumeImageGraphNodeGenerationCommand(p.ImageGraphID, p.NodeID))
+		if err != nil && !errors.Is(err, ErrImageGraphNotFound) {
+			errs = append(errs, fmt.Errorf("could not resume generation for node %q: %w", p.NodeID, err))
+		}
+	}
+
+	return len(pending), errors.Join(errs...)
+}
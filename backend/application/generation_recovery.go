@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// interruptedGenerationMessage is the failure recorded on nodes whose
+// generation was lost when recovery fails them instead of resuming them
+const interruptedGenerationMessage = "generation was interrupted"
+
+// recoveryPageSize is how many ImageGraphs are scanned at a time
+const recoveryPageSize = 100
+
+// RecoverStuckGenerations scans every ImageGraph for nodes that have been
+// generating since before the cutoff, which are left behind when the process
+// generating their outputs dies. Their generation is resumed, or when fail is
+// set, they're moved into the Failed state. It returns the number of nodes
+// recovered.
+func RecoverStuckGenerations(
+	ctx context.Context,
+	mb CommandHandler,
+	views ImageGraphViews,
+	cutoff time.Time,
+	fail bool,
+) (
+	int,
+	error,
+) {
+	recovered := 0
+	var errs []error
+
+	for offset := 0; ; offset += recoveryPageSize {
+		page, err := views.List(ctx, ListImageGraphsOptions{
+			Sort:   SortImageGraphsByCreated,
+			Limit:  recoveryPageSize,
+			Offset: offset,
+		})
+		if err != nil {
+			return recovered, fmt.Errorf("could not list image graphs: %w", err)
+		}
+
+		for _, summary := range page.ImageGraphs {
+			ig, err := views.Get(ctx, summary.ID)
+			if errors.Is(err, ErrImageGraphNotFound) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not get image graph %q: %w", summary.ID, err))
+				continue
+			}
+
+			for _, node := range ig.GeneratingSince(cutoff) {
+				err := mb.HandleCommand(ctx, recoveryCommand(ig.ID, node, fail))
+				if err != nil {
+					errs = append(errs, fmt.Errorf("could not recover generation for node %q: %w", node.ID, err))
+					continue
+				}
+
+				recovered++
+			}
+		}
+
+		if len(page.ImageGraphs) < recoveryPageSize {
+			break
+		}
+	}
+
+	return recovered, errors.Join(errs...)
+}
+
+func recoveryCommand(
+	imageGraphID imagegraph.ImageGraphID,
+	node *imagegraph.Node,
+	fail bool,
+) messages.Command {
+	if fail {
+		return NewSetImageGraphNodeGenerationFailedCommand(
+			imageGraphID,
+			node.ID,
+			interruptedGenerationMessage,
+			node.Version,
+		)
+	}
+
+	return NewResumeImageGraphNodeGenerationCommand(imageGraphID, node.ID)
+}
@@ -0,0 +1,101 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GenerationRun records one run of a node's output generation: how long it
+// took, and the pixels of the images it loaded and saved. Runs that were
+// superseded before they finished aren't recorded.
+type GenerationRun struct {
+	ImageGraphID imagegraph.ImageGraphID
+	NodeID       imagegraph.NodeID
+	NodeType     imagegraph.NodeType
+	NodeVersion  imagegraph.NodeVersion
+	StartedAt    time.Time
+	Duration     time.Duration
+	InputPixels  int64
+	OutputPixels int64
+	Failed       bool
+}
+
+// GenerationRunStore persists the GenerationRuns of nodes
+type GenerationRunStore interface {
+	Add(ctx context.Context, run GenerationRun) error
+	// ListByNode retrieves the most recent runs of a node, newest first
+	ListByNode(
+		ctx context.Context,
+		imageGraphID imagegraph.ImageGraphID,
+		nodeID imagegraph.NodeID,
+		limit int,
+	) ([]GenerationRun, error)
+}
+
+// GenerationRunStats summarises the runs of a node. Durations and pixels
+// only count the runs that succeeded.
+type GenerationRunStats struct {
+	Runs              int
+	Failures          int
+	Last              time.Duration
+	Mean              time.Duration
+	Min               time.Duration
+	Max               time.Duration
+	MeanInputPixels   int64
+	MeanOutputPixels  int64
+	PerMegapixel      time.Duration
+	pixelTime         time.Duration
+	totalInputPixels  int64
+	totalOutputPixels int64
+	total             time.Duration
+}
+
+// SummarizeGenerationRuns summarises runs listed newest first
+func SummarizeGenerationRuns(runs []GenerationRun) GenerationRunStats {
+	var stats GenerationRunStats
+
+	for _, run := range runs {
+		stats.Runs++
+		if run.Failed {
+			stats.Failures++
+			continue
+		}
+
+		succeeded := stats.Runs - stats.Failures
+		if succeeded == 1 {
+			stats.Last = run.Duration
+			stats.Min = run.Duration
+		}
+		stats.Min = min(stats.Min, run.Duration)
+		stats.Max = max(stats.Max, run.Duration)
+		stats.total += run.Duration
+		stats.Mean = stats.total / time.Duration(succeeded)
+
+		stats.totalInputPixels += run.InputPixels
+		stats.totalOutputPixels += run.OutputPixels
+		stats.MeanInputPixels = stats.totalInputPixels / int64(succeeded)
+		stats.MeanOutputPixels = stats.totalOutputPixels / int64(succeeded)
+
+		if run.InputPixels > 0 {
+			stats.pixelTime += run.Duration
+		}
+	}
+
+	if stats.totalInputPixels > 0 {
+		stats.PerMegapixel = time.Duration(float64(stats.pixelTime) * 1e6 / float64(stats.totalInputPixels))
+	}
+
+	return stats
+}
+
+// Timing returns the stats as a GenerationTiming, for estimating how long
+// the node's next generation takes
+func (s GenerationRunStats) Timing() imagegraph.GenerationTiming {
+	return imagegraph.GenerationTiming{
+		Count:        s.Runs - s.Failures,
+		Mean:         s.Mean,
+		PerMegapixel: s.PerMegapixel,
+	}
+}
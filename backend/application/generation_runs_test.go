@@ -0,0 +1,37 @@
+package application
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeGenerationRuns(t *testing.T) {
+	runs := []GenerationRun{
+		{Duration: 300 * time.Millisecond, InputPixels: 2_000_000, OutputPixels: 500_000},
+		{Duration: 5 * time.Second, Failed: true},
+		{Duration: 100 * time.Millisecond, InputPixels: 1_000_000, OutputPixels: 250_000},
+	}
+
+	stats := SummarizeGenerationRuns(runs)
+
+	if stats.Runs != 3 || stats.Failures != 1 {
+		t.Errorf("expected 3 runs with 1 failure, got %d with %d", stats.Runs, stats.Failures)
+	}
+	if stats.Last != 300*time.Millisecond {
+		t.Errorf("expected the newest successful run to be last, got %v", stats.Last)
+	}
+	if stats.Mean != 200*time.Millisecond || stats.Min != 100*time.Millisecond || stats.Max != 300*time.Millisecond {
+		t.Errorf("expected failures left out of durations, got mean %v min %v max %v", stats.Mean, stats.Min, stats.Max)
+	}
+	if stats.MeanInputPixels != 1_500_000 || stats.MeanOutputPixels != 375_000 {
+		t.Errorf("expected mean pixels of 1500000 in and 375000 out, got %d and %d", stats.MeanInputPixels, stats.MeanOutputPixels)
+	}
+	if stats.PerMegapixel != 400*time.Millisecond/3 {
+		t.Errorf("expected %v per megapixel, got %v", 400*time.Millisecond/3, stats.PerMegapixel)
+	}
+
+	timing := stats.Timing()
+	if timing.Count != 2 || timing.Mean != stats.Mean {
+		t.Errorf("expected a timing of the 2 successful runs, got %+v", timing)
+	}
+}
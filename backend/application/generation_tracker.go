@@ -0,0 +1,210 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+type generationKey struct {
+	imageGraphID imagegraph.ImageGraphID
+	nodeID       imagegraph.NodeID
+}
+
+type generation struct {
+	cancel context.CancelFunc
+}
+
+// generationTracker owns the contexts of in-flight node output generation so
+// that work stops once nobody will see its result: when a newer version of
+// the node needs outputs, when the node is removed, or when a deadline
+// passes.
+type generationTracker struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	running map[generationKey]*generation
+
+	// background counts other work started by event handlers, such as input
+	// previews, which isn't cancelled but is waited for by wait
+	background int
+
+	// completed counts the generations and background work that have
+	// finished
+	completed uint64
+
+	// finished is closed and replaced each time a generation finishes, to
+	// wake up drain
+	finished chan struct{}
+}
+
+func newGenerationTracker(timeout time.Duration) *generationTracker {
+	return &generationTracker{
+		timeout:  timeout,
+		running:  make(map[generationKey]*generation),
+		finished: make(chan struct{}),
+	}
+}
+
+// start returns the context to generate a node's outputs with, cancelling
+// any generation still running for an earlier version of the node. The
+// context outlives the provided one, since outputs are broadcast to every
+// viewer of the graph, but keeps its values and deadline. done must be
+// called once generation finishes.
+func (t *generationTracker) start(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+) (
+	context.Context,
+	func(),
+) {
+	genCtx := context.WithoutCancel(ctx)
+	cancels := []context.CancelFunc{}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		genCtx, cancel = context.WithDeadline(genCtx, deadline)
+		cancels = append(cancels, cancel)
+	}
+
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		genCtx, cancel = context.WithTimeout(genCtx, t.timeout)
+		cancels = append(cancels, cancel)
+	}
+
+	genCtx, cancel := context.WithCancel(genCtx)
+	cancels = append(cancels, cancel)
+
+	g := &generation{
+		cancel: func() {
+			for _, cancel := range cancels {
+				cancel()
+			}
+		},
+	}
+
+	key := generationKey{imageGraphID: imageGraphID, nodeID: nodeID}
+
+	t.mu.Lock()
+	if previous, ok := t.running[key]; ok {
+		previous.cancel()
+	}
+	t.running[key] = g
+	t.mu.Unlock()
+
+	done := func() {
+		g.cancel()
+
+		t.mu.Lock()
+		if t.running[key] == g {
+			delete(t.running, key)
+		}
+		t.notifyFinished()
+		t.mu.Unlock()
+	}
+
+	return genCtx, done
+}
+
+// startBackground counts work running in the background of an event
+// handler until done is called
+func (t *generationTracker) startBackground() (done func()) {
+	t.mu.Lock()
+	t.background++
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		t.background--
+		t.notifyFinished()
+		t.mu.Unlock()
+	}
+}
+
+// cancel stops any generation running for the node
+func (t *generationTracker) cancel(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+) {
+	key := generationKey{imageGraphID: imageGraphID, nodeID: nodeID}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if g, ok := t.running[key]; ok {
+		g.cancel()
+		delete(t.running, key)
+		t.notifyFinished()
+	}
+}
+
+// drain waits for the running generations to finish, including any started
+// while waiting, until ctx is done. Generations still running then are
+// cancelled and returned.
+func (t *generationTracker) drain(ctx context.Context) []generationKey {
+	for {
+		t.mu.Lock()
+		if len(t.running) == 0 {
+			t.mu.Unlock()
+			return nil
+		}
+		finished := t.finished
+		t.mu.Unlock()
+
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return t.cancelAll()
+		}
+	}
+}
+
+// wait waits until no generation or background work is running, including
+// any started while waiting, returning how many have completed. Unlike
+// drain it doesn't cancel anything when ctx is done.
+func (t *generationTracker) wait(ctx context.Context) (uint64, error) {
+	for {
+		t.mu.Lock()
+		if len(t.running) == 0 && t.background == 0 {
+			completed := t.completed
+			t.mu.Unlock()
+			return completed, nil
+		}
+		finished := t.finished
+		t.mu.Unlock()
+
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// cancelAll stops every running generation, returning the nodes they were
+// generating outputs for
+func (t *generationTracker) cancelAll() []generationKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]generationKey, 0, len(t.running))
+	for key, g := range t.running {
+		g.cancel()
+		delete(t.running, key)
+		keys = append(keys, key)
+	}
+	t.notifyFinished()
+
+	return keys
+}
+
+// notifyFinished counts a completion and wakes up drain and wait. t.mu
+// must be held.
+func (t *generationTracker) notifyFinished() {
+	t.completed++
+	close(t.finished)
+	t.finished = make(chan struct{})
+}
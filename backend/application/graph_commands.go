@@ -0,0 +1,331 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// CommandHandler handles commands, as the message bus and GraphQueues do
+type CommandHandler interface {
+	HandleCommand(ctx context.Context, command messages.Command) error
+}
+
+// graphQueueObserver is notified as commands and events queue for
+// ImageGraphs
+type graphQueueObserver interface {
+	ObserveGraphCommandsQueued(queued int)
+	ObserveGraphEventsQueued(queued int)
+	ObserveGraphCommandRejected(commandType string)
+}
+
+type rejectWhenBusyKey struct{}
+
+// RejectWhenBusy marks commands sent with the context to fail with
+// ErrImageGraphBusy, rather than wait, when their ImageGraph's queue is full.
+// Commands sent while handling events always wait, since their work would be
+// lost.
+func RejectWhenBusy(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rejectWhenBusyKey{}, true)
+}
+
+// waitWhenBusy undoes RejectWhenBusy for the commands sent while handling
+// an event
+func waitWhenBusy(ctx context.Context) context.Context {
+	if reject, _ := ctx.Value(rejectWhenBusyKey{}).(bool); !reject {
+		return ctx
+	}
+	return context.WithValue(ctx, rejectWhenBusyKey{}, false)
+}
+
+// GraphQueues sends commands to a message bus, queueing them by the
+// ImageGraph named in their ImageGraphID or GraphID field. The bus handles
+// one command at a time and dispatches the events it returns before taking
+// the next, so handlers never interleave; the queues keep a graph's commands
+// in the order they were sent and let only one of them wait on the bus at a
+// time, so a burst of commands for one graph doesn't hold back the others.
+// Commands for no ImageGraph go straight to the bus.
+//
+// The events a command leads to queue in the bus until it has dispatched
+// them, and a graph with a large fan-out can queue many at once. The
+// handlers registered with the bus are given the GraphQueues and count them
+// for the graph they belong to, and a graph's queued events take up room in
+// its queue until they are dispatched, holding back more commands for the
+// graph meanwhile.
+type GraphQueues struct {
+	mb *messagebus.MessageBus
+
+	mu        sync.Mutex
+	tails     map[imagegraph.ImageGraphID]chan struct{}
+	depths    map[imagegraph.ImageGraphID]int
+	queued    int
+	maxQueued int
+	observer  graphQueueObserver
+
+	// handlers counts the event handlers registered with the bus for each
+	// event type, since the bus dispatches an event to each of them
+	handlers map[reflect.Type]int
+
+	// events counts the dispatches of events to handlers the bus has queued
+	// for each ImageGraph
+	events       map[imagegraph.ImageGraphID]int
+	queuedEvents int
+
+	// left is closed and replaced each time a command or event leaves a
+	// queue, to wake up commands waiting for room
+	left chan struct{}
+}
+
+// GraphQueuesOption configures optional GraphQueues behavior
+type GraphQueuesOption func(*GraphQueues)
+
+// WithGraphQueueLimit bounds how many commands and queued events may wait
+// behind the command sent to the bus for each ImageGraph, 0 for unlimited.
+// Commands arriving at a full queue wait until there is room, unless they
+// were sent with RejectWhenBusy.
+func WithGraphQueueLimit(maxQueued int) GraphQueuesOption {
+	return func(q *GraphQueues) {
+		q.maxQueued = max(maxQueued, 0)
+	}
+}
+
+// WithGraphQueueObserver reports the number of queued commands and events,
+// and the commands rejected from full queues, to the observer
+func WithGraphQueueObserver(observer graphQueueObserver) GraphQueuesOption {
+	return func(q *GraphQueues) {
+		q.observer = observer
+	}
+}
+
+// NewGraphQueues creates the GraphQueues sending commands to a message bus.
+// The handlers registered with the bus must be given the same GraphQueues,
+// so that they count the events they queue. Every component sending commands
+// for ImageGraphs should send them through the same GraphQueues.
+func NewGraphQueues(mb *messagebus.MessageBus, opts ...GraphQueuesOption) *GraphQueues {
+	q := &GraphQueues{
+		mb:       mb,
+		tails:    make(map[imagegraph.ImageGraphID]chan struct{}),
+		depths:   make(map[imagegraph.ImageGraphID]int),
+		handlers: make(map[reflect.Type]int),
+		events:   make(map[imagegraph.ImageGraphID]int),
+		left:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// HandleCommand sends a command to the message bus once the commands sent
+// before it for the same ImageGraph have been handled, and waits for it to
+// be handled
+func (q *GraphQueues) HandleCommand(ctx context.Context, command messages.Command) error {
+	imageGraphID, ok := messageImageGraphID(command)
+	if !ok {
+		return q.mb.HandleCommand(ctx, command)
+	}
+
+	release, err := q.acquire(ctx, imageGraphID, command.GetType())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return q.mb.HandleCommand(ctx, command)
+}
+
+// acquire waits for the commands queued before it for the ImageGraph to be
+// handled. The returned release function must be called once the command is
+// handled. If ctx is done first the command leaves the queue without being
+// sent, and the commands behind it wait for the ones before it. When the
+// queue is full, counting the graph's queued events, the command waits for
+// room, or fails with ErrImageGraphBusy if ctx was marked with
+// RejectWhenBusy.
+func (q *GraphQueues) acquire(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	commandType string,
+) (
+	func(),
+	error,
+) {
+	q.mu.Lock()
+	for q.maxQueued > 0 && q.depths[imageGraphID]+q.events[imageGraphID] > q.maxQueued {
+		if reject, _ := ctx.Value(rejectWhenBusyKey{}).(bool); reject {
+			observer := q.observer
+			q.mu.Unlock()
+
+			if observer != nil {
+				observer.ObserveGraphCommandRejected(commandType)
+			}
+			return nil, fmt.Errorf("%w: %d commands and events queued for ImageGraph %q", ErrImageGraphBusy, q.maxQueued, imageGraphID)
+		}
+
+		left := q.left
+		q.mu.Unlock()
+
+		select {
+		case <-left:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for room in the queue for ImageGraph %q: %w", imageGraphID, ctx.Err())
+		}
+
+		q.mu.Lock()
+	}
+
+	prev := q.tails[imageGraphID]
+	done := make(chan struct{})
+	q.tails[imageGraphID] = done
+	q.depths[imageGraphID]++
+	q.queued++
+	q.observe()
+	q.mu.Unlock()
+
+	release := func() {
+		close(done)
+
+		q.mu.Lock()
+		if q.tails[imageGraphID] == done {
+			delete(q.tails, imageGraphID)
+		}
+		if q.depths[imageGraphID]--; q.depths[imageGraphID] == 0 {
+			delete(q.depths, imageGraphID)
+		}
+		q.queued--
+		q.observe()
+		close(q.left)
+		q.left = make(chan struct{})
+		q.mu.Unlock()
+	}
+
+	if prev == nil {
+		return release, nil
+	}
+
+	select {
+	case <-prev:
+		return release, nil
+	case <-ctx.Done():
+		go func() {
+			<-prev
+			release()
+		}()
+
+		return nil, fmt.Errorf("gave up waiting for commands for ImageGraph %q: %w", imageGraphID, ctx.Err())
+	}
+}
+
+// observe reports the number of queued commands. q.mu must be held.
+func (q *GraphQueues) observe() {
+	if q.observer != nil {
+		q.observer.ObserveGraphCommandsQueued(q.queued)
+	}
+}
+
+// countEventHandler counts an event handler registered with the bus, which
+// dispatches each event of its type to it
+func (q *GraphQueues) countEventHandler(eventType reflect.Type) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	q.handlers[eventType]++
+	q.mu.Unlock()
+}
+
+// queueEvents counts the events a handler returned, which the bus queues to
+// dispatch to each of their handlers. It is called from the bus.
+func (q *GraphQueues) queueEvents(events []messages.Event) {
+	if q == nil || len(events) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	for _, event := range events {
+		imageGraphID, ok := messageImageGraphID(event)
+		if !ok {
+			continue
+		}
+
+		dispatches := q.handlers[reflect.TypeOf(event)]
+		if dispatches == 0 {
+			continue
+		}
+
+		q.events[imageGraphID] += dispatches
+		q.queuedEvents += dispatches
+	}
+	queued := q.queuedEvents
+	q.mu.Unlock()
+
+	q.observeEvents(queued)
+}
+
+// dequeueEvent counts an event the bus dispatched to one of its handlers,
+// waking up the commands waiting for room in the event's queue. It is called
+// from the bus.
+func (q *GraphQueues) dequeueEvent(event messages.Event) {
+	if q == nil {
+		return
+	}
+
+	imageGraphID, ok := messageImageGraphID(event)
+	if !ok {
+		return
+	}
+
+	q.mu.Lock()
+	if q.events[imageGraphID] == 0 {
+		q.mu.Unlock()
+		return
+	}
+	if q.events[imageGraphID]--; q.events[imageGraphID] == 0 {
+		delete(q.events, imageGraphID)
+	}
+	q.queuedEvents--
+	queued := q.queuedEvents
+	close(q.left)
+	q.left = make(chan struct{})
+	q.mu.Unlock()
+
+	q.observeEvents(queued)
+}
+
+// observeEvents reports the number of queued events. Only the bus changes
+// it, so unlike observe it is called without q.mu held.
+func (q *GraphQueues) observeEvents(queued int) {
+	if q.observer != nil {
+		q.observer.ObserveGraphEventsQueued(queued)
+	}
+}
+
+// messageImageGraphID finds the ImageGraph a command or event belongs to
+// from its ImageGraphID or GraphID field
+func messageImageGraphID(message any) (imagegraph.ImageGraphID, bool) {
+	v := reflect.Indirect(reflect.ValueOf(message))
+	if v.Kind() != reflect.Struct {
+		return imagegraph.ImageGraphID{}, false
+	}
+
+	for _, name := range []string{"ImageGraphID", "GraphID"} {
+		field := v.FieldByName(name)
+		if !field.IsValid() {
+			continue
+		}
+
+		if id, ok := field.Interface().(imagegraph.ImageGraphID); ok && !id.IsNil() {
+			return id, true
+		}
+	}
+
+	return imagegraph.ImageGraphID{}, false
+}
@@ -0,0 +1,356 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/infratest"
+)
+
+func TestGraphQueues(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends commands to the bus", func(t *testing.T) {
+		mb := messagebus.New()
+
+		var handled []string
+		err := errors.Join(
+			messagebus.RegisterCommandHandler(mb, func(_ context.Context, command *SetImageGraphPublicCommand) ([]messages.Event, error) {
+				handled = append(handled, command.GetType())
+				return nil, nil
+			}),
+			messagebus.RegisterCommandHandler(mb, func(_ context.Context, command *MarkOutboxEventsPublishedCommand) ([]messages.Event, error) {
+				handled = append(handled, command.GetType())
+				return nil, nil
+			}),
+		)
+		if err != nil {
+			t.Fatalf("failed to register handlers: %v", err)
+		}
+		infratest.StartBus(t, mb)
+
+		q := NewGraphQueues(mb)
+		imageGraphID := imagegraph.MustNewImageGraphID()
+
+		if err := q.HandleCommand(ctx, NewSetImageGraphPublicCommand(imageGraphID, true)); err != nil {
+			t.Fatalf("failed to handle command: %v", err)
+		}
+		// Commands for no graph aren't queued
+		if err := q.HandleCommand(ctx, NewMarkOutboxEventsPublishedCommand([]int64{1})); err != nil {
+			t.Fatalf("failed to handle command: %v", err)
+		}
+
+		if !slices.Equal(handled, []string{"SetImageGraphPublicCommand", "MarkOutboxEventsPublishedCommand"}) {
+			t.Errorf("expected both commands to be handled, got %v", handled)
+		}
+		if len(q.depths) != 0 {
+			t.Errorf("expected the queues to be empty, got %v", q.depths)
+		}
+	})
+
+	t.Run("runs commands for a graph in order", func(t *testing.T) {
+		q := NewGraphQueues(nil)
+		imageGraphID := imagegraph.MustNewImageGraphID()
+
+		release, err := q.acquire(ctx, imageGraphID, "")
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+
+		var (
+			mu    sync.Mutex
+			order []int
+			wg    sync.WaitGroup
+		)
+
+		for i := range 5 {
+			tail := queueTail(q, imageGraphID)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				release, err := q.acquire(ctx, imageGraphID, "")
+				if err != nil {
+					t.Errorf("failed to acquire: %v", err)
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				release()
+			}()
+
+			// Queue the next command only once this one is queued
+			for queueTail(q, imageGraphID) == tail {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		release()
+		wg.Wait()
+
+		if !slices.Equal(order, []int{0, 1, 2, 3, 4}) {
+			t.Errorf("expected commands in arrival order, got %v", order)
+		}
+		if len(q.tails) != 0 {
+			t.Errorf("expected the queue to be empty, got %d graphs", len(q.tails))
+		}
+	})
+
+	t.Run("doesn't hold back commands for other graphs", func(t *testing.T) {
+		q := NewGraphQueues(nil)
+
+		release, err := q.acquire(ctx, imagegraph.MustNewImageGraphID(), "")
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+		defer release()
+
+		acquireCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		other, err := q.acquire(acquireCtx, imagegraph.MustNewImageGraphID(), "")
+		if err != nil {
+			t.Fatalf("expected another graph not to wait: %v", err)
+		}
+		other()
+	})
+
+	t.Run("leaves the queue when the context is done", func(t *testing.T) {
+		q := NewGraphQueues(nil)
+		imageGraphID := imagegraph.MustNewImageGraphID()
+
+		release, err := q.acquire(ctx, imageGraphID, "")
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := q.acquire(cancelled, imageGraphID, ""); err == nil {
+			t.Fatal("expected an error for a cancelled context")
+		}
+
+		acquired := make(chan func())
+		go func() {
+			next, err := q.acquire(ctx, imageGraphID, "")
+			if err != nil {
+				t.Errorf("failed to acquire: %v", err)
+			}
+			acquired <- next
+		}()
+
+		select {
+		case <-acquired:
+			t.Fatal("expected the command to wait for the first")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		release()
+
+		select {
+		case next := <-acquired:
+			next()
+		case <-time.After(time.Second):
+			t.Fatal("expected the command to run once the first finished")
+		}
+	})
+
+	t.Run("rejects commands marked to when the queue is full", func(t *testing.T) {
+		observer := &queueObserver{}
+		q := NewGraphQueues(nil, WithGraphQueueLimit(1), WithGraphQueueObserver(observer))
+		imageGraphID := imagegraph.MustNewImageGraphID()
+
+		release, err := q.acquire(ctx, imageGraphID, "")
+		if err != nil {
+			t.Fatalf("failed to acquire: %v", err)
+		}
+
+		// One command may wait behind the one sent to the bus
+		acquired := make(chan func())
+		go func() {
+			next, err := q.acquire(ctx, imageGraphID, "")
+			if err != nil {
+				t.Errorf("failed to acquire: %v", err)
+			}
+			acquired <- next
+		}()
+		for queueTail(q, imageGraphID) == nil || observer.max() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		_, err = q.acquire(RejectWhenBusy(ctx), imageGraphID, "SetImageGraphPublicCommand")
+		if !errors.Is(err, ErrImageGraphBusy) {
+			t.Fatalf("expected ErrImageGraphBusy, got %v", err)
+		}
+		if observer.rejected != 1 {
+			t.Errorf("expected the rejection to be observed, got %d", observer.rejected)
+		}
+
+		// Commands sent while handling events wait for room instead
+		waited := make(chan func())
+		go func() {
+			next, err := q.acquire(waitWhenBusy(RejectWhenBusy(ctx)), imageGraphID, "")
+			if err != nil {
+				t.Errorf("failed to acquire: %v", err)
+			}
+			waited <- next
+		}()
+
+		select {
+		case <-waited:
+			t.Fatal("expected the command to wait for room")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		release()
+		(<-acquired)()
+		(<-waited)()
+
+		if observer.last() != 0 {
+			t.Errorf("expected no commands queued, got %d", observer.last())
+		}
+		if len(q.depths) != 0 {
+			t.Errorf("expected the queue depths to be empty, got %v", q.depths)
+		}
+	})
+
+	t.Run("counts a graph's queued events against its queue", func(t *testing.T) {
+		observer := &queueObserver{}
+		q := NewGraphQueues(nil, WithGraphQueueLimit(1), WithGraphQueueObserver(observer))
+		imageGraphID := imagegraph.MustNewImageGraphID()
+
+		// The bus dispatches the event to each of its three handlers
+		eventType := reflect.TypeFor[*imagegraph.PublicSetEvent]()
+		for range 3 {
+			q.countEventHandler(eventType)
+		}
+
+		event := &imagegraph.PublicSetEvent{ImageGraphEvent: imagegraph.ImageGraphEvent{ImageGraphID: imageGraphID}}
+		q.queueEvents([]messages.Event{event})
+		if observer.events[len(observer.events)-1] != 3 {
+			t.Fatalf("expected 3 events queued, got %v", observer.events)
+		}
+
+		_, err := q.acquire(RejectWhenBusy(ctx), imageGraphID, "")
+		if !errors.Is(err, ErrImageGraphBusy) {
+			t.Fatalf("expected ErrImageGraphBusy, got %v", err)
+		}
+
+		other, err := q.acquire(RejectWhenBusy(ctx), imagegraph.MustNewImageGraphID(), "")
+		if err != nil {
+			t.Fatalf("expected another graph's queue to have room: %v", err)
+		}
+		other()
+
+		waited := make(chan func())
+		go func() {
+			next, err := q.acquire(ctx, imageGraphID, "")
+			if err != nil {
+				t.Errorf("failed to acquire: %v", err)
+			}
+			waited <- next
+		}()
+
+		q.dequeueEvent(event)
+		select {
+		case <-waited:
+			t.Fatal("expected the command to wait while the queue is full")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		q.dequeueEvent(event)
+		select {
+		case next := <-waited:
+			next()
+		case <-time.After(time.Second):
+			t.Fatal("expected the command to be sent once there was room in the queue")
+		}
+
+		q.dequeueEvent(event)
+
+		if len(q.events) != 0 {
+			t.Errorf("expected no events queued, got %v", q.events)
+		}
+	})
+}
+
+type queueObserver struct {
+	mu       sync.Mutex
+	queued   []int
+	events   []int
+	rejected int
+}
+
+func (o *queueObserver) ObserveGraphCommandsQueued(queued int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.queued = append(o.queued, queued)
+}
+
+func (o *queueObserver) ObserveGraphEventsQueued(queued int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, queued)
+}
+
+func (o *queueObserver) ObserveGraphCommandRejected(string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.rejected++
+}
+
+func (o *queueObserver) max() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Max(append([]int{0}, o.queued...))
+}
+
+func (o *queueObserver) last() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.queued[len(o.queued)-1]
+}
+
+func queueTail(q *GraphQueues, imageGraphID imagegraph.ImageGraphID) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tails[imageGraphID]
+}
+
+func TestMessageImageGraphID(t *testing.T) {
+	imageGraphID := imagegraph.MustNewImageGraphID()
+
+	tests := []struct {
+		name    string
+		message any
+		want    bool
+	}{
+		{"ImageGraphID field", NewSetImageGraphPublicCommand(imageGraphID, true), true},
+		{"embedded ImageGraphID field", &imagegraph.PublicSetEvent{ImageGraphEvent: imagegraph.ImageGraphEvent{ImageGraphID: imageGraphID}}, true},
+		{"GraphID field", NewUpdateViewportCommand(imageGraphID, "", 1, 0, 0), true},
+		{"no graph", NewMarkOutboxEventsPublishedCommand([]int64{1}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := messageImageGraphID(tt.message)
+			if ok != tt.want {
+				t.Fatalf("expected found %v, got %v", tt.want, ok)
+			}
+			if ok && got != imageGraphID {
+				t.Errorf("expected %v, got %v", imageGraphID, got)
+			}
+		})
+	}
+}
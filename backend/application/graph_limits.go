@@ -0,0 +1,61 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GraphLimits caps the complexity of an ImageGraph. Commands that would take
+// a graph past a limit are rejected with ErrGraphLimitExceeded. A zero limit
+// is unlimited.
+type GraphLimits struct {
+	MaxNodes       int
+	MaxConnections int
+}
+
+// checkAddNode returns an error if adding a node to the ImageGraph would
+// exceed the limits. Limits are checked before the graph is changed.
+func (l GraphLimits) checkAddNode(ig *imagegraph.ImageGraph) error {
+	c := ig.Complexity()
+	c.Nodes++
+	return l.check(c)
+}
+
+// checkConnect returns an error if connecting a node's input would exceed the
+// limits. Connecting an input that is already connected replaces its
+// connection rather than adding one.
+func (l GraphLimits) checkConnect(
+	ig *imagegraph.ImageGraph,
+	toNodeID imagegraph.NodeID,
+	inputName imagegraph.InputName,
+) error {
+	c := ig.Complexity()
+
+	if toNode, ok := ig.Nodes.Get(toNodeID); ok {
+		if connected, err := toNode.IsInputConnected(inputName); err == nil && !connected {
+			c.Connections++
+		}
+	}
+
+	return l.check(c)
+}
+
+// check returns an error if the complexity exceeds any of the limits
+func (l GraphLimits) check(c imagegraph.Complexity) error {
+	if l.MaxNodes > 0 && c.Nodes > l.MaxNodes {
+		return fmt.Errorf(
+			"%w: graph would have %d nodes, the limit is %d",
+			ErrGraphLimitExceeded, c.Nodes, l.MaxNodes,
+		)
+	}
+
+	if l.MaxConnections > 0 && c.Connections > l.MaxConnections {
+		return fmt.Errorf(
+			"%w: graph would have %d connections, the limit is %d",
+			ErrGraphLimitExceeded, c.Connections, l.MaxConnections,
+		)
+	}
+
+	return nil
+}
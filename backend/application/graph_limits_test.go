@@ -0,0 +1,56 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dmpettyp/artwork/testsupport"
+)
+
+func TestGraphLimits(t *testing.T) {
+	b := testsupport.NewGraphBuilder().
+		WithInput().
+		WithBlur(5).
+		WithBlur(3).
+		Connect("input", "blur")
+	ig := b.MustBuild(t)
+
+	t.Run("allows adding a node up to the limit", func(t *testing.T) {
+		limits := GraphLimits{MaxNodes: 4}
+		if err := limits.checkAddNode(ig); err != nil {
+			t.Errorf("expected the node to be allowed, got %v", err)
+		}
+	})
+
+	t.Run("rejects adding a node past the limit", func(t *testing.T) {
+		limits := GraphLimits{MaxNodes: 3}
+		if err := limits.checkAddNode(ig); !errors.Is(err, ErrGraphLimitExceeded) {
+			t.Errorf("expected ErrGraphLimitExceeded, got %v", err)
+		}
+	})
+
+	t.Run("rejects connecting an unconnected input past the limit", func(t *testing.T) {
+		limits := GraphLimits{MaxConnections: 1}
+		err := limits.checkConnect(ig, b.NodeID("blur2"), "original")
+		if !errors.Is(err, ErrGraphLimitExceeded) {
+			t.Errorf("expected ErrGraphLimitExceeded, got %v", err)
+		}
+	})
+
+	t.Run("allows replacing a connection at the limit", func(t *testing.T) {
+		limits := GraphLimits{MaxConnections: 1}
+		if err := limits.checkConnect(ig, b.NodeID("blur"), "original"); err != nil {
+			t.Errorf("expected the connection to be allowed, got %v", err)
+		}
+	})
+
+	t.Run("treats zero limits as unlimited", func(t *testing.T) {
+		var limits GraphLimits
+		if err := limits.checkAddNode(ig); err != nil {
+			t.Errorf("expected the node to be allowed, got %v", err)
+		}
+		if err := limits.checkConnect(ig, b.NodeID("blur2"), "original"); err != nil {
+			t.Errorf("expected the connection to be allowed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GraphLink subscribes an Input node of one ImageGraph to a node output of
+// another, so each image the source output is set to is copied to the Input
+// node. Linked graphs form pipelines of pipelines.
+type GraphLink struct {
+	ID string
+
+	SourceImageGraphID imagegraph.ImageGraphID
+	SourceNodeID       imagegraph.NodeID
+	SourceOutputName   imagegraph.OutputName
+
+	TargetImageGraphID imagegraph.ImageGraphID
+	TargetNodeID       imagegraph.NodeID
+
+	CreatedAt time.Time
+}
+
+// GraphLinkStore persists the GraphLinks feeding ImageGraphs' Input nodes
+type GraphLinkStore interface {
+	Add(ctx context.Context, link GraphLink) error
+
+	// ListByImageGraph retrieves the GraphLinks feeding an ImageGraph
+	ListByImageGraph(ctx context.Context, imageGraphID imagegraph.ImageGraphID) ([]GraphLink, error)
+
+	// ListBySource retrieves the GraphLinks fed by an ImageGraph
+	ListBySource(ctx context.Context, imageGraphID imagegraph.ImageGraphID) ([]GraphLink, error)
+
+	Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) error
+}
+
+// GraphLinkCreatesCycle reports whether linking the source ImageGraph to
+// the target would let an image propagate back to the source, which would
+// regenerate the graphs forever
+func GraphLinkCreatesCycle(
+	ctx context.Context,
+	links GraphLinkStore,
+	source imagegraph.ImageGraphID,
+	target imagegraph.ImageGraphID,
+) (
+	bool,
+	error,
+) {
+	visited := map[imagegraph.ImageGraphID]bool{}
+	pending := []imagegraph.ImageGraphID{target}
+
+	for len(pending) > 0 {
+		graphID := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if graphID == source {
+			return true, nil
+		}
+		if visited[graphID] {
+			continue
+		}
+		visited[graphID] = true
+
+		fed, err := links.ListBySource(ctx, graphID)
+		if err != nil {
+			return false, err
+		}
+		for _, link := range fed {
+			pending = append(pending, link.TargetImageGraphID)
+		}
+	}
+
+	return false, nil
+}
+
+type imageCopier interface {
+	Copy(from imagegraph.ImageID, to imagegraph.ImageID) error
+}
+
+// GraphLinkEventHandlers copies the images set on linked node outputs to
+// the Input nodes linked to them
+type GraphLinkEventHandlers struct {
+	uow    UnitOfWork
+	links  GraphLinkStore
+	images imageCopier
+}
+
+// NewGraphLinkEventHandlers initializes the handlers struct that propagates
+// images along GraphLinks and registers all handlers with the provided
+// message bus
+func NewGraphLinkEventHandlers(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	uow UnitOfWork,
+	links GraphLinkStore,
+	images imageCopier,
+) (
+	*GraphLinkEventHandlers,
+	error,
+) {
+	handlers := &GraphLinkEventHandlers{
+		uow:    uow,
+		links:  links,
+		images: images,
+	}
+
+	err := errors.Join(
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeOutputImageSetEvent),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create graph link event handlers: %w", err)
+	}
+
+	return handlers, nil
+}
+
+// HandleNodeOutputImageSetEvent copies the image to each Input node linked
+// to the output
+func (h *GraphLinkEventHandlers) HandleNodeOutputImageSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeOutputImageSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	links, err := h.links.ListBySource(ctx, event.ImageGraphID)
+	if err != nil {
+		return nil, fmt.Errorf("could not process NodeOutputImageSetEvent for ImageGraph %q: %w", event.ImageGraphID, err)
+	}
+
+	var events []messages.Event
+	var errs []error
+	for _, link := range links {
+		if link.SourceNodeID != event.NodeID || link.SourceOutputName != event.OutputName {
+			continue
+		}
+
+		linkEvents, err := h.propagate(ctx, link, event.ImageID, event.Upload)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		events = append(events, linkEvents...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return events, fmt.Errorf("could not process NodeOutputImageSetEvent for ImageGraph %q: %w", event.ImageGraphID, err)
+	}
+
+	return events, nil
+}
+
+// propagate sets the link's Input node to a copy of the image, so either
+// graph can drop its image without breaking the other. Links whose Input
+// node or graph is gone are skipped.
+func (h *GraphLinkEventHandlers) propagate(
+	ctx context.Context,
+	link GraphLink,
+	imageID imagegraph.ImageID,
+	upload *imagegraph.UploadedImage,
+) (
+	[]messages.Event,
+	error,
+) {
+	events, err := h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(link.TargetImageGraphID)
+		if errors.Is(err, ErrImageGraphNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		node, ok := ig.Nodes.Get(link.TargetNodeID)
+		if !ok {
+			return nil
+		}
+
+		copyID := imagegraph.MustNewImageID()
+		if err := h.images.Copy(imageID, copyID); err != nil {
+			return fmt.Errorf("could not copy image %q: %w", imageID, err)
+		}
+
+		if upload != nil {
+			return ig.SetNodeUploadedOutputImage(node.ID, "original", copyID, node.Version, *upload)
+		}
+		return ig.SetNodeOutputImage(node.ID, "original", copyID, node.Version)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not propagate graph link %q: %w", link.ID, err)
+	}
+
+	return events, nil
+}
@@ -11,7 +11,20 @@ import (
 )
 
 type ImageGraphCommandHandlers struct {
-	uow UnitOfWork
+	uow    UnitOfWork
+	limits GraphLimits
+}
+
+// ImageGraphCommandHandlersOption configures optional ImageGraphCommandHandlers
+// behavior
+type ImageGraphCommandHandlersOption func(*ImageGraphCommandHandlers)
+
+// WithGraphLimits rejects commands that would take an ImageGraph past the
+// provided limits
+func WithGraphLimits(limits GraphLimits) ImageGraphCommandHandlersOption {
+	return func(h *ImageGraphCommandHandlers) {
+		h.limits = limits
+	}
 }
 
 // NewImageGraphCommandHandlers initializes the handlers struct that processes
@@ -19,25 +32,58 @@ type ImageGraphCommandHandlers struct {
 // message bus
 func NewImageGraphCommandHandlers(
 	mb *messagebus.MessageBus,
+	queues *GraphQueues,
 	uow UnitOfWork,
+	opts ...ImageGraphCommandHandlersOption,
 ) (
 	*ImageGraphCommandHandlers,
 	error,
 ) {
 	handlers := &ImageGraphCommandHandlers{uow: uow}
 
+	for _, opt := range opts {
+		opt(handlers)
+	}
+
 	err := errors.Join(
-		messagebus.RegisterCommandHandler(mb, handlers.HandleCreateImageGraphCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleAddImageGraphNodeCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleRemoveImageGraphNodeCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleConnectImageGraphNodesCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleDisconnectImageGraphNodesCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleSetImageGraphNodeOutputImageCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleUnsetImageGraphNodeOutputImageCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleSetImageGraphNodePreviewCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleUnsetImageGraphNodePreviewCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleSetImageGraphNodeConfigCommand),
-		messagebus.RegisterCommandHandler(mb, handlers.HandleSetImageGraphNodeNameCommand),
+		registerCommandHandler(mb, queues, handlers.HandleCreateImageGraphCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphPublicCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphColorManagementCommand),
+		registerCommandHandler(mb, queues, handlers.HandleRegenerateImageGraphCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphPerformanceModeCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphParametersCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphSeedCommand),
+		registerCommandHandler(mb, queues, handlers.HandleShareImageGraphCommand),
+		registerCommandHandler(mb, queues, handlers.HandleUnshareImageGraphCommand),
+		registerCommandHandler(mb, queues, handlers.HandleAddImageGraphTagCommand),
+		registerCommandHandler(mb, queues, handlers.HandleRemoveImageGraphTagCommand),
+		registerCommandHandler(mb, queues, handlers.HandleAddImageGraphNodeCommand),
+		registerCommandHandler(mb, queues, handlers.HandleRemoveImageGraphNodeCommand),
+		registerCommandHandler(mb, queues, handlers.HandleConnectImageGraphNodesCommand),
+		registerCommandHandler(mb, queues, handlers.HandleDisconnectImageGraphNodesCommand),
+		registerCommandHandler(mb, queues, handlers.HandleAddImageGraphNodeInputCommand),
+		registerCommandHandler(mb, queues, handlers.HandleRemoveImageGraphNodeInputCommand),
+		registerCommandHandler(mb, queues, handlers.HandleAddImageGraphPaletteSwatchCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphPaletteSwatchEnabledCommand),
+		registerCommandHandler(mb, queues, handlers.HandleRemoveImageGraphPaletteSwatchCommand),
+		registerCommandHandler(mb, queues, handlers.HandleReorderImageGraphPaletteSwatchesCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeOutputImageCommand),
+		registerCommandHandler(mb, queues, handlers.HandleUnsetImageGraphNodeOutputImageCommand),
+		registerCommandHandler(mb, queues, handlers.HandlePromoteImageGraphNodeOutputVariantCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeGenerationFailedCommand),
+		registerCommandHandler(mb, queues, handlers.HandleResumeImageGraphNodeGenerationCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodePreviewCommand),
+		registerCommandHandler(mb, queues, handlers.HandleUnsetImageGraphNodePreviewCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeConfigCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeNameCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeDescriptionCommand),
+		registerCommandHandler(mb, queues, handlers.HandleAddImageGraphNodeTagCommand),
+		registerCommandHandler(mb, queues, handlers.HandleRemoveImageGraphNodeTagCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeBypassCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodePinnedCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeImplementationCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSetImageGraphNodeExpressionsCommand),
+		registerCommandHandler(mb, queues, handlers.HandleUpgradeImageGraphNodeImplementationCommand),
 	)
 
 	if err != nil {
@@ -55,7 +101,15 @@ func (h *ImageGraphCommandHandlers) HandleCreateImageGraphCommand(
 	error,
 ) {
 	return h.uow.Run(ctx, func(repos *Repos) error {
-		ig, err := imagegraph.NewImageGraph(command.ImageGraphID, command.Name)
+		var opts []imagegraph.ImageGraphOption
+		if command.ExternalID != "" {
+			opts = append(opts, imagegraph.WithExternalID(command.ExternalID))
+		}
+		if command.Owner != "" {
+			opts = append(opts, imagegraph.WithOwner(command.Owner))
+		}
+
+		ig, err := imagegraph.NewImageGraph(command.ImageGraphID, command.Name, opts...)
 
 		if err != nil {
 			return fmt.Errorf("could not process CreateImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
@@ -71,9 +125,9 @@ func (h *ImageGraphCommandHandlers) HandleCreateImageGraphCommand(
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleAddImageGraphNodeCommand(
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphPublicCommand(
 	ctx context.Context,
-	command *AddImageGraphNodeCommand,
+	command *SetImageGraphPublicCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -82,33 +136,28 @@ func (h *ImageGraphCommandHandlers) HandleAddImageGraphNodeCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphPublicCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.AddNode(
-			command.NodeID,
-			command.NodeType,
-			command.Name,
-		)
+		err = authorize(ctx, ig, imagegraph.RoleOwner)
 
 		if err != nil {
-			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphPublicCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		if command.Config != nil {
-			err = ig.SetNodeConfig(command.NodeID, command.Config)
-			if err != nil {
-				return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
-			}
+		err = ig.SetPublic(command.Public)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphPublicCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphNodeCommand(
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphColorManagementCommand(
 	ctx context.Context,
-	command *RemoveImageGraphNodeCommand,
+	command *SetImageGraphColorManagementCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -117,22 +166,28 @@ func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphNodeCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process RemoveImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphColorManagementCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.RemoveNode(command.NodeID)
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
 
 		if err != nil {
-			return fmt.Errorf("could not process RemoveImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphColorManagementCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetColorManagement(command.ColorManagement)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphColorManagementCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleConnectImageGraphNodesCommand(
+func (h *ImageGraphCommandHandlers) HandleRegenerateImageGraphCommand(
 	ctx context.Context,
-	command *ConnectImageGraphNodesCommand,
+	command *RegenerateImageGraphCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -141,27 +196,28 @@ func (h *ImageGraphCommandHandlers) HandleConnectImageGraphNodesCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process ConnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process RegenerateImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.ConnectNodes(
-			command.FromNodeID,
-			command.OutputName,
-			command.ToNodeID,
-			command.InputName,
-		)
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
 
 		if err != nil {
-			return fmt.Errorf("could not process ConnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process RegenerateImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.Regenerate()
+
+		if err != nil {
+			return fmt.Errorf("could not process RegenerateImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleDisconnectImageGraphNodesCommand(
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphParametersCommand(
 	ctx context.Context,
-	command *DisconnectImageGraphNodesCommand,
+	command *SetImageGraphParametersCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -170,27 +226,28 @@ func (h *ImageGraphCommandHandlers) HandleDisconnectImageGraphNodesCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process DisconnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphParametersCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.DisconnectNodes(
-			command.FromNodeID,
-			command.OutputName,
-			command.ToNodeID,
-			command.InputName,
-		)
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
 
 		if err != nil {
-			return fmt.Errorf("could not process DisconnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphParametersCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetParameters(command.Parameters)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphParametersCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeOutputImageCommand(
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphSeedCommand(
 	ctx context.Context,
-	command *SetImageGraphNodeOutputImageCommand,
+	command *SetImageGraphSeedCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -199,36 +256,28 @@ func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeOutputImageCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphSeedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		nodeVersion := command.NodeVersion
-		if nodeVersion == 0 {
-			node, ok := ig.Nodes.Get(command.NodeID)
-			if !ok {
-				return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: node %q not found", command.ImageGraphID, command.NodeID)
-			}
-			nodeVersion = node.Version
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphSeedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.SetNodeOutputImage(
-			command.NodeID,
-			command.OutputName,
-			command.ImageID,
-			nodeVersion,
-		)
+		err = ig.SetSeed(command.Seed)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphSeedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleUnsetImageGraphNodeOutputImageCommand(
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphPerformanceModeCommand(
 	ctx context.Context,
-	command *UnsetImageGraphNodeOutputImageCommand,
+	command *SetImageGraphPerformanceModeCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -237,25 +286,28 @@ func (h *ImageGraphCommandHandlers) HandleUnsetImageGraphNodeOutputImageCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process UnsetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphPerformanceModeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.UnsetNodeOutputImage(
-			command.NodeID,
-			command.OutputName,
-		)
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
 
 		if err != nil {
-			return fmt.Errorf("could not process UnsetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process SetImageGraphPerformanceModeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetPerformanceMode(command.PerformanceMode)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphPerformanceModeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodePreviewCommand(
+func (h *ImageGraphCommandHandlers) HandleShareImageGraphCommand(
 	ctx context.Context,
-	command *SetImageGraphNodePreviewCommand,
+	command *ShareImageGraphCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -264,35 +316,28 @@ func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodePreviewCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process ShareImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		nodeVersion := command.NodeVersion
-		if nodeVersion == 0 {
-			node, ok := ig.Nodes.Get(command.NodeID)
-			if !ok {
-				return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: node %q not found", command.ImageGraphID, command.NodeID)
-			}
-			nodeVersion = node.Version
+		err = authorize(ctx, ig, imagegraph.RoleOwner)
+
+		if err != nil {
+			return fmt.Errorf("could not process ShareImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.SetNodePreview(
-			command.NodeID,
-			command.ImageID,
-			nodeVersion,
-		)
+		err = ig.Share(command.UserID, command.Role)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process ShareImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleUnsetImageGraphNodePreviewCommand(
+func (h *ImageGraphCommandHandlers) HandleUnshareImageGraphCommand(
 	ctx context.Context,
-	command *UnsetImageGraphNodePreviewCommand,
+	command *UnshareImageGraphCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -301,22 +346,28 @@ func (h *ImageGraphCommandHandlers) HandleUnsetImageGraphNodePreviewCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process UnsetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process UnshareImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.UnsetNodePreview(command.NodeID)
+		err = authorize(ctx, ig, imagegraph.RoleOwner)
 
 		if err != nil {
-			return fmt.Errorf("could not process UnsetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process UnshareImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.Unshare(command.UserID)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnshareImageGraphCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeConfigCommand(
+func (h *ImageGraphCommandHandlers) HandleAddImageGraphTagCommand(
 	ctx context.Context,
-	command *SetImageGraphNodeConfigCommand,
+	command *AddImageGraphTagCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -325,23 +376,28 @@ func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeConfigCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodeConfigCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process AddImageGraphTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		if command.Config != nil {
-			err = ig.SetNodeConfig(command.NodeID, command.Config)
-			if err != nil {
-				return fmt.Errorf("could not process SetImageGraphNodeConfigCommand for ImageGraph %q: %w", command.ImageGraphID, err)
-			}
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.AddTag(command.Tag)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
 	})
 }
 
-func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeNameCommand(
+func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphTagCommand(
 	ctx context.Context,
-	command *SetImageGraphNodeNameCommand,
+	command *RemoveImageGraphTagCommand,
 ) (
 	[]messages.Event,
 	error,
@@ -350,13 +406,933 @@ func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeNameCommand(
 		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodeNameCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process RemoveImageGraphTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
-		err = ig.SetNodeName(command.NodeID, command.Name)
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
 
 		if err != nil {
-			return fmt.Errorf("could not process SetImageGraphNodeNameCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			return fmt.Errorf("could not process RemoveImageGraphTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.RemoveTag(command.Tag)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleAddImageGraphNodeCommand(
+	ctx context.Context,
+	command *AddImageGraphNodeCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		if err := h.limits.checkAddNode(ig); err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		var opts []imagegraph.NodeOption
+		if command.ExternalID != "" {
+			opts = append(opts, imagegraph.WithNodeExternalID(command.ExternalID))
+		}
+
+		err = ig.AddNode(
+			command.NodeID,
+			command.NodeType,
+			command.Name,
+			opts...,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = setUpAddedNode(ig, command)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+// setUpAddedNode sets the config of a node added by an
+// AddImageGraphNodeCommand and adds its extra inputs
+func setUpAddedNode(ig *imagegraph.ImageGraph, command *AddImageGraphNodeCommand) error {
+	if command.Config != nil {
+		if err := ig.SetNodeConfig(command.NodeID, command.Config); err != nil {
+			return err
+		}
+	}
+
+	for _, inputName := range command.Inputs {
+		if err := ig.AddNodeInput(command.NodeID, inputName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphNodeCommand(
+	ctx context.Context,
+	command *RemoveImageGraphNodeCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.RemoveNode(command.NodeID)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleConnectImageGraphNodesCommand(
+	ctx context.Context,
+	command *ConnectImageGraphNodesCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process ConnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process ConnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = h.limits.checkConnect(ig, command.ToNodeID, command.InputName)
+
+		if err != nil {
+			return fmt.Errorf("could not process ConnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.ConnectNodesWithTransform(
+			command.FromNodeID,
+			command.OutputName,
+			command.ToNodeID,
+			command.InputName,
+			command.Transform,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process ConnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleDisconnectImageGraphNodesCommand(
+	ctx context.Context,
+	command *DisconnectImageGraphNodesCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process DisconnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process DisconnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.DisconnectNodes(
+			command.FromNodeID,
+			command.OutputName,
+			command.ToNodeID,
+			command.InputName,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process DisconnectImageGraphNodesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleAddImageGraphNodeInputCommand(
+	ctx context.Context,
+	command *AddImageGraphNodeInputCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeInputCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeInputCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.AddNodeInput(command.NodeID, command.InputName)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeInputCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphNodeInputCommand(
+	ctx context.Context,
+	command *RemoveImageGraphNodeInputCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeInputCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeInputCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.RemoveNodeInput(command.NodeID, command.InputName)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeInputCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleAddImageGraphPaletteSwatchCommand(
+	ctx context.Context,
+	command *AddImageGraphPaletteSwatchCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphPaletteSwatchCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphPaletteSwatchCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.AddPaletteSwatch(command.NodeID, command.Color, command.Enabled, command.Index)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphPaletteSwatchCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphPaletteSwatchEnabledCommand(
+	ctx context.Context,
+	command *SetImageGraphPaletteSwatchEnabledCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphPaletteSwatchEnabledCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphPaletteSwatchEnabledCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetPaletteSwatchEnabled(command.NodeID, command.Color, command.Enabled)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphPaletteSwatchEnabledCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphPaletteSwatchCommand(
+	ctx context.Context,
+	command *RemoveImageGraphPaletteSwatchCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphPaletteSwatchCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphPaletteSwatchCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.RemovePaletteSwatch(command.NodeID, command.Color)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphPaletteSwatchCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleReorderImageGraphPaletteSwatchesCommand(
+	ctx context.Context,
+	command *ReorderImageGraphPaletteSwatchesCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process ReorderImageGraphPaletteSwatchesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process ReorderImageGraphPaletteSwatchesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.ReorderPaletteSwatches(command.NodeID, command.Colors)
+
+		if err != nil {
+			return fmt.Errorf("could not process ReorderImageGraphPaletteSwatchesCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeOutputImageCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeOutputImageCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		nodeVersion := command.NodeVersion
+		if nodeVersion == 0 {
+			node, ok := ig.Nodes.Get(command.NodeID)
+			if !ok {
+				return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: node %q not found", command.ImageGraphID, command.NodeID)
+			}
+			nodeVersion = node.Version
+		}
+
+		if command.Upload != nil {
+			err = ig.SetNodeUploadedOutputImage(
+				command.NodeID,
+				command.OutputName,
+				command.ImageID,
+				nodeVersion,
+				*command.Upload,
+			)
+		} else {
+			err = ig.SetNodeOutputImage(
+				command.NodeID,
+				command.OutputName,
+				command.ImageID,
+				nodeVersion,
+			)
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeGenerationFailedCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeGenerationFailedCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeGenerationFailedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeGenerationFailedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodeGenerationFailed(
+			command.NodeID,
+			command.Message,
+			command.NodeVersion,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeGenerationFailedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleResumeImageGraphNodeGenerationCommand(
+	ctx context.Context,
+	command *ResumeImageGraphNodeGenerationCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process ResumeImageGraphNodeGenerationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process ResumeImageGraphNodeGenerationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.ResumeNodeGeneration(command.NodeID)
+
+		if err != nil {
+			return fmt.Errorf("could not process ResumeImageGraphNodeGenerationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleUnsetImageGraphNodeOutputImageCommand(
+	ctx context.Context,
+	command *UnsetImageGraphNodeOutputImageCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnsetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnsetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.UnsetNodeOutputImage(
+			command.NodeID,
+			command.OutputName,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnsetImageGraphNodeOutputImageCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandlePromoteImageGraphNodeOutputVariantCommand(
+	ctx context.Context,
+	command *PromoteImageGraphNodeOutputVariantCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process PromoteImageGraphNodeOutputVariantCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process PromoteImageGraphNodeOutputVariantCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.PromoteNodeOutputVariant(
+			command.NodeID,
+			command.OutputName,
+			command.ImageID,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process PromoteImageGraphNodeOutputVariantCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodePreviewCommand(
+	ctx context.Context,
+	command *SetImageGraphNodePreviewCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		nodeVersion := command.NodeVersion
+		if nodeVersion == 0 {
+			node, ok := ig.Nodes.Get(command.NodeID)
+			if !ok {
+				return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: node %q not found", command.ImageGraphID, command.NodeID)
+			}
+			nodeVersion = node.Version
+		}
+
+		err = ig.SetNodePreview(
+			command.NodeID,
+			command.ImageID,
+			nodeVersion,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleUnsetImageGraphNodePreviewCommand(
+	ctx context.Context,
+	command *UnsetImageGraphNodePreviewCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnsetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnsetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.UnsetNodePreview(command.NodeID)
+
+		if err != nil {
+			return fmt.Errorf("could not process UnsetImageGraphNodePreviewCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeConfigCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeConfigCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeConfigCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeConfigCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		if command.Config != nil {
+			err = ig.SetNodeConfig(command.NodeID, command.Config)
+			if err != nil {
+				return fmt.Errorf("could not process SetImageGraphNodeConfigCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeNameCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeNameCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeNameCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeNameCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodeName(command.NodeID, command.Name)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeNameCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeDescriptionCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeDescriptionCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeDescriptionCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeDescriptionCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodeDescription(command.NodeID, command.Description)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeDescriptionCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleAddImageGraphNodeTagCommand(
+	ctx context.Context,
+	command *AddImageGraphNodeTagCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.AddNodeTag(command.NodeID, command.Tag)
+
+		if err != nil {
+			return fmt.Errorf("could not process AddImageGraphNodeTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleRemoveImageGraphNodeTagCommand(
+	ctx context.Context,
+	command *RemoveImageGraphNodeTagCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.RemoveNodeTag(command.NodeID, command.Tag)
+
+		if err != nil {
+			return fmt.Errorf("could not process RemoveImageGraphNodeTagCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeBypassCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeBypassCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeBypassCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeBypassCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodeBypassed(command.NodeID, command.Bypassed)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeBypassCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodePinnedCommand(
+	ctx context.Context,
+	command *SetImageGraphNodePinnedCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodePinnedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodePinnedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodePinned(command.NodeID, command.Pinned)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodePinnedCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeImplementationCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeImplementationCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeImplementationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeImplementationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodeImplementation(command.NodeID, command.Implementation)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeImplementationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleSetImageGraphNodeExpressionsCommand(
+	ctx context.Context,
+	command *SetImageGraphNodeExpressionsCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeExpressionsCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeExpressionsCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.SetNodeExpressions(command.NodeID, command.Expressions)
+
+		if err != nil {
+			return fmt.Errorf("could not process SetImageGraphNodeExpressionsCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ImageGraphCommandHandlers) HandleUpgradeImageGraphNodeImplementationCommand(
+	ctx context.Context,
+	command *UpgradeImageGraphNodeImplementationCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		ig, err := repos.ImageGraphRepository.Get(command.ImageGraphID)
+
+		if err != nil {
+			return fmt.Errorf("could not process UpgradeImageGraphNodeImplementationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = authorize(ctx, ig, imagegraph.RoleEditor)
+
+		if err != nil {
+			return fmt.Errorf("could not process UpgradeImageGraphNodeImplementationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
+		}
+
+		err = ig.UpgradeNodeImplementation(command.NodeID)
+
+		if err != nil {
+			return fmt.Errorf("could not process UpgradeImageGraphNodeImplementationCommand for ImageGraph %q: %w", command.ImageGraphID, err)
 		}
 
 		return nil
@@ -4,17 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/infrastructure/imagegen"
 	"github.com/dmpettyp/dorky/messagebus"
 	"github.com/dmpettyp/dorky/messages"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dmpettyp/artwork/tracing"
 )
 
 // ImageGraphNotifier is an interface for broadcasting graph notifications
 type ImageGraphNotifier interface {
 	BroadcastNodeUpdate(graphID imagegraph.ImageGraphID, nodeUpdate any)
 	BroadcastLayoutUpdate(graphID imagegraph.ImageGraphID)
+	BroadcastGraphListUpdate(graphID imagegraph.ImageGraphID, listUpdate any)
 }
 
 type imageRemover interface {
@@ -26,6 +33,46 @@ type ImageGraphEventHandlers struct {
 	imageGen     *imagegen.ImageGen
 	imageRemover imageRemover
 	notifier     ImageGraphNotifier
+	logger       *slog.Logger
+	generations  *generationTracker
+	processed    ProcessedEventStore
+	runs         GenerationRunStore
+	clock        Clock
+}
+
+// ImageGraphEventHandlersOption configures optional ImageGraphEventHandlers
+// behavior
+type ImageGraphEventHandlersOption func(*ImageGraphEventHandlers)
+
+// WithGenerationTimeout cancels node output generation that runs longer than
+// the provided duration, failing the node
+func WithGenerationTimeout(timeout time.Duration) ImageGraphEventHandlersOption {
+	return func(h *ImageGraphEventHandlers) {
+		h.generations = newGenerationTracker(timeout)
+	}
+}
+
+// WithProcessedEvents skips events the handlers already processed, so events
+// delivered more than once don't generate outputs twice
+func WithProcessedEvents(processed ProcessedEventStore) ImageGraphEventHandlersOption {
+	return func(h *ImageGraphEventHandlers) {
+		h.processed = processed
+	}
+}
+
+// WithGenerationRuns records how long every generation of a node takes and
+// the sizes of the images it works on
+func WithGenerationRuns(runs GenerationRunStore) ImageGraphEventHandlersOption {
+	return func(h *ImageGraphEventHandlers) {
+		h.runs = runs
+	}
+}
+
+// WithGenerationClock sets the Clock generation runs are timed with
+func WithGenerationClock(clock Clock) ImageGraphEventHandlersOption {
+	return func(h *ImageGraphEventHandlers) {
+		h.clock = clock
+	}
 }
 
 // NewImageGraphEventHandlers initializes the handlers struct that processes
@@ -33,10 +80,14 @@ type ImageGraphEventHandlers struct {
 // message bus
 func NewImageGraphEventHandlers(
 	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
 	uow UnitOfWork,
 	imageGen *imagegen.ImageGen,
 	imageRemover imageRemover,
 	notifier ImageGraphNotifier,
+	logger *slog.Logger,
+	opts ...ImageGraphEventHandlersOption,
 ) (
 	*ImageGraphEventHandlers,
 	error,
@@ -46,17 +97,34 @@ func NewImageGraphEventHandlers(
 		imageGen:     imageGen,
 		imageRemover: imageRemover,
 		notifier:     notifier,
+		logger:       logger,
+		generations:  newGenerationTracker(0),
+		clock:        SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(handlers)
 	}
 
 	err := errors.Join(
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeAddedEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeInputConnectedEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeInputDisconnectedEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeNeedsOutputsEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeOutputImageSetEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeOutputImageUnsetEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodePreviewSetEvent),
-		messagebus.RegisterEventHandler(mb, handlers.HandleNodeRemovedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleCreatedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeAddedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeInputConnectedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeInputDisconnectedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeInputTransformSetEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeInputAddedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeInputRemovedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeNeedsOutputsEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeOutputImageSetEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeOutputImageUnsetEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeOutputVariantsDroppedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeGenerationFailedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodePreviewSetEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeRemovedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeDescriptionSetEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeExpressionsSetEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeTagAddedEvent),
+		registerIdempotentEventHandler(mb, queues, deadLetters, handlers.processed, handlers.HandleNodeTagRemovedEvent),
 	)
 
 	if err != nil {
@@ -66,6 +134,23 @@ func NewImageGraphEventHandlers(
 	return handlers, nil
 }
 
+func (h *ImageGraphEventHandlers) HandleCreatedEvent(
+	ctx context.Context,
+	event *imagegraph.CreatedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	// Broadcast the new graph so open graph lists show it
+	h.notifier.BroadcastGraphListUpdate(event.ImageGraphID, map[string]any{
+		"change": "created",
+		"name":   event.Name,
+		"owner":  event.Owner,
+	})
+
+	return nil, nil
+}
+
 func (h *ImageGraphEventHandlers) HandleNodeOutputImageUnsetEvent(
 	ctx context.Context,
 	event *imagegraph.NodeOutputImageUnsetEvent,
@@ -73,11 +158,13 @@ func (h *ImageGraphEventHandlers) HandleNodeOutputImageUnsetEvent(
 	[]messages.Event,
 	error,
 ) {
-	if err := h.imageRemover.Remove(event.ImageID); err != nil {
-		return nil, fmt.Errorf(
-			"could not process NodeOutputImageUnsetEvent for ImageGraph %q: %w",
-			event.ImageGraphID, err,
-		)
+	if !event.Retained {
+		if err := h.imageRemover.Remove(event.ImageID); err != nil {
+			return nil, fmt.Errorf(
+				"could not process NodeOutputImageUnsetEvent for ImageGraph %q: %w",
+				event.ImageGraphID, err,
+			)
+		}
 	}
 
 	return h.uow.Run(ctx, func(repos *Repos) error {
@@ -100,6 +187,27 @@ func (h *ImageGraphEventHandlers) HandleNodeOutputImageUnsetEvent(
 	})
 }
 
+// HandleNodeOutputVariantsDroppedEvent removes the images that fell out of
+// an output's history
+func (h *ImageGraphEventHandlers) HandleNodeOutputVariantsDroppedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeOutputVariantsDroppedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	for _, imageID := range event.ImageIDs {
+		if err := h.imageRemover.Remove(imageID); err != nil {
+			return nil, fmt.Errorf(
+				"could not process NodeOutputVariantsDroppedEvent for ImageGraph %q: %w",
+				event.ImageGraphID, err,
+			)
+		}
+	}
+
+	return nil, nil
+}
+
 func (h *ImageGraphEventHandlers) HandleNodeNeedsOutputsEvent(
 	ctx context.Context,
 	event *imagegraph.NodeNeedsOutputsEvent,
@@ -120,17 +228,121 @@ func (h *ImageGraphEventHandlers) HandleNodeNeedsOutputsEvent(
 		)
 	}
 
+	if event.Bypassed {
+		generator = generateBypassedNodeOutputs
+	}
+
+	genCtx, done := h.generations.start(ctx, event.ImageGraphID, event.NodeID)
+	if event.SkipPreview {
+		genCtx = imagegen.WithoutPreview(genCtx)
+	}
+
 	go func() {
-		err := generator(ctx, event, h.imageGen)
+		defer done()
+
+		// Generation outlives the event handler's span, so it gets its own
+		// child span covering the image processing and storage
+		genCtx, span := tracer.Start(genCtx, "GenerateNodeOutputs", trace.WithAttributes(
+			attribute.String("artwork.node_type", imagegraph.NodeTypeMapper.FromWithDefault(event.NodeType, "unknown")),
+			attribute.String("artwork.node_id", event.NodeID.String()),
+			attribute.Int64("artwork.node_version", int64(event.NodeVersion)),
+			attribute.Bool("artwork.bypassed", event.Bypassed),
+		))
+
+		genCtx, generationSize := imagegen.WithGenerationSize(genCtx)
+		startedAt := h.clock.Now()
+
+		genEvent, err := resolveExpressions(genCtx, event, h.imageGen)
+		if err == nil {
+			// Outputs trace back to the images the inputs were set to, not
+			// to the transformed placeholders generators get
+			genCtx = imagegen.WithProvenance(genCtx, event.Provenance(genEvent.NodeConfig))
+			genCtx, genEvent, err = transformInputs(genCtx, genEvent)
+		}
+		if err == nil {
+			err = generator(genCtx, genEvent, h.imageGen)
+		}
+		defer tracing.End(span, err)
+
+		// Generation was superseded by a newer version of the node or the
+		// node was removed; nobody is waiting on this result
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
+		h.recordRun(context.WithoutCancel(genCtx), event, startedAt, generationSize(), err)
+
+		if err == nil {
+			return
+		}
+
+		h.logger.Error(
+			"generation failed",
+			"error", err,
+			"image_graph_id", event.ImageGraphID,
+			"node_id", event.NodeID,
+		)
+
+		// The generation context may have expired, but the failure must
+		// still be recorded
+		err = h.imageGen.ReportGenerationFailure(
+			context.WithoutCancel(genCtx),
+			event.ImageGraphID,
+			event.NodeID,
+			event.NodeVersion,
+			err,
+		)
 
 		if err != nil {
-			fmt.Println(err)
+			h.logger.Error(
+				"could not report generation failure",
+				"error", err,
+				"image_graph_id", event.ImageGraphID,
+				"node_id", event.NodeID,
+			)
 		}
 	}()
 
 	return nil, nil
 }
 
+// recordRun records a finished generation of a node, if runs are recorded.
+// Bypassed nodes only pass on their input, so their runs say nothing about
+// the node and are left out. Failing to record a run is logged and doesn't
+// fail the generation.
+func (h *ImageGraphEventHandlers) recordRun(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	startedAt time.Time,
+	size imagegen.GenerationSize,
+	err error,
+) {
+	if h.runs == nil || event.Bypassed {
+		return
+	}
+
+	run := GenerationRun{
+		ImageGraphID: event.ImageGraphID,
+		NodeID:       event.NodeID,
+		NodeType:     event.NodeType,
+		NodeVersion:  event.NodeVersion,
+		StartedAt:    startedAt,
+		Duration:     h.clock.Now().Sub(startedAt),
+		InputPixels:  size.InputPixels,
+		OutputPixels: size.OutputPixels,
+		Failed:       err != nil,
+	}
+
+	if err := h.runs.Add(ctx, run); err != nil {
+		h.logger.Error(
+			"could not record generation run",
+			"error", err,
+			"image_graph_id", event.ImageGraphID,
+			"node_id", event.NodeID,
+		)
+	}
+}
+
 func (h *ImageGraphEventHandlers) HandleNodeOutputImageSetEvent(
 	ctx context.Context,
 	event *imagegraph.NodeOutputImageSetEvent,
@@ -147,7 +359,10 @@ func (h *ImageGraphEventHandlers) HandleNodeOutputImageSetEvent(
 	})
 
 	if event.NodeType == imagegraph.NodeTypeInput {
+		done := h.generations.startBackground()
 		go func() {
+			defer done()
+
 			_ = h.imageGen.GeneratePreviewForInputNode(
 				ctx,
 				event.ImageGraphID,
@@ -185,6 +400,22 @@ func (h *ImageGraphEventHandlers) HandleNodeOutputImageSetEvent(
 	})
 }
 
+func (h *ImageGraphEventHandlers) HandleNodeGenerationFailedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeGenerationFailedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id": event.NodeID.String(),
+		"state":   "failed",
+		"error":   event.Message,
+	})
+
+	return nil, nil
+}
+
 func (h *ImageGraphEventHandlers) HandleNodePreviewSetEvent(
 	ctx context.Context,
 	event *imagegraph.NodePreviewSetEvent,
@@ -222,6 +453,8 @@ func (h *ImageGraphEventHandlers) HandleNodeRemovedEvent(
 	[]messages.Event,
 	error,
 ) {
+	h.generations.cancel(event.ImageGraphID, event.NodeID)
+
 	// Broadcast that node was removed
 	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
 		"node_id": event.NodeID.String(),
@@ -231,6 +464,72 @@ func (h *ImageGraphEventHandlers) HandleNodeRemovedEvent(
 	return nil, nil
 }
 
+func (h *ImageGraphEventHandlers) HandleNodeDescriptionSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeDescriptionSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	// Broadcast the new notes so other viewers of the graph see them
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id":     event.NodeID.String(),
+		"description": event.Description,
+	})
+
+	return nil, nil
+}
+
+func (h *ImageGraphEventHandlers) HandleNodeExpressionsSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeExpressionsSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	expressions := event.Expressions
+	if expressions == nil {
+		expressions = imagegraph.ConfigExpressions{}
+	}
+
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id":     event.NodeID.String(),
+		"expressions": expressions,
+	})
+
+	return nil, nil
+}
+
+func (h *ImageGraphEventHandlers) HandleNodeTagAddedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeTagAddedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id": event.NodeID.String(),
+		"tags":    event.Tags,
+	})
+
+	return nil, nil
+}
+
+func (h *ImageGraphEventHandlers) HandleNodeTagRemovedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeTagRemovedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id": event.NodeID.String(),
+		"tags":    event.Tags,
+	})
+
+	return nil, nil
+}
+
 func (h *ImageGraphEventHandlers) HandleNodeInputConnectedEvent(
 	ctx context.Context,
 	event *imagegraph.NodeInputConnectedEvent,
@@ -247,6 +546,57 @@ func (h *ImageGraphEventHandlers) HandleNodeInputConnectedEvent(
 	return nil, nil
 }
 
+func (h *ImageGraphEventHandlers) HandleNodeInputTransformSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeInputTransformSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	// Broadcast the connection's new transform
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id":    event.NodeID.String(),
+		"input_name": string(event.InputName),
+		"transform":  event.Transform,
+	})
+
+	return nil, nil
+}
+
+func (h *ImageGraphEventHandlers) HandleNodeInputAddedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeInputAddedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	// Broadcast the node's new input
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id":    event.NodeID.String(),
+		"state":      "input_added",
+		"input_name": string(event.InputName),
+	})
+
+	return nil, nil
+}
+
+func (h *ImageGraphEventHandlers) HandleNodeInputRemovedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeInputRemovedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	// Broadcast that the node's input was removed
+	h.notifier.BroadcastNodeUpdate(event.ImageGraphID, map[string]any{
+		"node_id":    event.NodeID.String(),
+		"state":      "input_removed",
+		"input_name": string(event.InputName),
+	})
+
+	return nil, nil
+}
+
 func (h *ImageGraphEventHandlers) HandleNodeInputDisconnectedEvent(
 	ctx context.Context,
 	event *imagegraph.NodeInputDisconnectedEvent,
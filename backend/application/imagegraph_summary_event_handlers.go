@@ -0,0 +1,187 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ImageGraphSummaryEventHandlers keeps the ImageGraphSummary projections
+// that ImageGraphs are listed with up to date. Summaries are refreshed from
+// the stored ImageGraph on the events that change what lists show.
+type ImageGraphSummaryEventHandlers struct {
+	imageGraphViews ImageGraphViews
+	summaries       ImageGraphSummaryStore
+}
+
+// NewImageGraphSummaryEventHandlers initializes the handlers struct that
+// maintains ImageGraphSummaries and registers all handlers with the provided
+// message bus
+func NewImageGraphSummaryEventHandlers(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	imageGraphViews ImageGraphViews,
+	summaries ImageGraphSummaryStore,
+) (
+	*ImageGraphSummaryEventHandlers,
+	error,
+) {
+	handlers := &ImageGraphSummaryEventHandlers{
+		imageGraphViews: imageGraphViews,
+		summaries:       summaries,
+	}
+
+	err := errors.Join(
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleCreatedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandlePublicSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleTagAddedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleTagRemovedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleSharedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleUnsharedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeAddedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeRemovedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodePreviewSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodePreviewUnsetEvent),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create image graph summary event handlers: %w", err)
+	}
+
+	return handlers, nil
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleCreatedEvent(
+	ctx context.Context,
+	event *imagegraph.CreatedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandlePublicSetEvent(
+	ctx context.Context,
+	event *imagegraph.PublicSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleTagAddedEvent(
+	ctx context.Context,
+	event *imagegraph.TagAddedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleTagRemovedEvent(
+	ctx context.Context,
+	event *imagegraph.TagRemovedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleSharedEvent(
+	ctx context.Context,
+	event *imagegraph.SharedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleUnsharedEvent(
+	ctx context.Context,
+	event *imagegraph.UnsharedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleNodeAddedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeAddedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleNodeRemovedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeRemovedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+// HandleNodePreviewSetEvent refreshes the thumbnail, which is the preview of
+// an Output node
+func (h *ImageGraphSummaryEventHandlers) HandleNodePreviewSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodePreviewSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	if event.NodeType != imagegraph.NodeTypeOutput {
+		return nil, nil
+	}
+
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+func (h *ImageGraphSummaryEventHandlers) HandleNodePreviewUnsetEvent(
+	ctx context.Context,
+	event *imagegraph.NodePreviewUnsetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	if event.NodeType != imagegraph.NodeTypeOutput {
+		return nil, nil
+	}
+
+	return nil, h.refresh(ctx, event.ImageGraphID)
+}
+
+// refresh stores the summary of the ImageGraph as it is now. Events can be
+// handled out of order, so the store keeps whichever summary is of the
+// latest version.
+func (h *ImageGraphSummaryEventHandlers) refresh(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) error {
+	ig, err := h.imageGraphViews.Get(ctx, imageGraphID)
+	if err != nil {
+		return fmt.Errorf("could not refresh summary of ImageGraph %q: %w", imageGraphID, err)
+	}
+
+	if err := h.summaries.Put(ctx, NewImageGraphSummary(ig)); err != nil {
+		return fmt.Errorf("could not refresh summary of ImageGraph %q: %w", imageGraphID, err)
+	}
+
+	return nil
+}
@@ -20,6 +20,7 @@ type LayoutCommandHandlers struct {
 // message bus
 func NewLayoutCommandHandlers(
 	mb *messagebus.MessageBus,
+	queues *GraphQueues,
 	uow UnitOfWork,
 ) (
 	*LayoutCommandHandlers,
@@ -27,7 +28,7 @@ func NewLayoutCommandHandlers(
 ) {
 	handlers := &LayoutCommandHandlers{uow: uow}
 
-	err := messagebus.RegisterCommandHandler(mb, handlers.HandleUpdateLayoutCommand)
+	err := registerCommandHandler(mb, queues, handlers.HandleUpdateLayoutCommand)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create layout command handlers: %w", err)
@@ -44,15 +45,21 @@ func (h *LayoutCommandHandlers) HandleUpdateLayoutCommand(
 	error,
 ) {
 	return h.uow.Run(ctx, func(repos *Repos) error {
+		// The graph's nodes are needed to check that only they are laid out
+		ig, err := repos.ImageGraphRepository.Get(command.GraphID)
+		if err != nil {
+			return fmt.Errorf("could not get ImageGraph %q: %w", command.GraphID, err)
+		}
+
 		// Try to get existing layout, or create and add new if it doesn't exist
-		layout, err := repos.LayoutRepository.Get(command.GraphID)
+		layout, err := repos.LayoutRepository.Get(command.GraphID, command.UserID)
 
 		if err != nil {
 			if !errors.Is(err, ErrLayoutNotFound) {
 				return fmt.Errorf("could not get Layout for ImageGraph %q: %w", command.GraphID, err)
 			}
 
-			layout, err = ui.NewLayout(command.GraphID)
+			layout, err = ui.NewLayout(command.GraphID, command.UserID)
 			if err != nil {
 				return fmt.Errorf("could not create Layout for ImageGraph %q: %w", command.GraphID, err)
 			}
@@ -63,8 +70,11 @@ func (h *LayoutCommandHandlers) HandleUpdateLayoutCommand(
 			}
 		}
 
-		// Update node positions using domain method (emits event internally)
-		layout.SetNodePositions(command.NodePositions)
+		// Update node layouts using domain method (emits event internally)
+		err = layout.SetNodeLayouts(command.NodeLayouts, ig.Nodes)
+		if err != nil {
+			return fmt.Errorf("could not update Layout for ImageGraph %q: %w", command.GraphID, err)
+		}
 
 		return nil
 	})
@@ -18,6 +18,8 @@ type LayoutEventHandlers struct {
 // message bus
 func NewLayoutEventHandlers(
 	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
 	notifier ImageGraphNotifier,
 ) (
 	*LayoutEventHandlers,
@@ -27,7 +29,7 @@ func NewLayoutEventHandlers(
 		notifier: notifier,
 	}
 
-	err := messagebus.RegisterEventHandler(mb, handlers.HandleLayoutUpdatedEvent)
+	err := registerEventHandler(mb, queues, deadLetters, handlers.HandleLayoutUpdatedEvent)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create layout event handlers: %w", err)
@@ -3,6 +3,7 @@ package application
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/infrastructure/imagegen"
@@ -27,6 +28,128 @@ var nodeOutputGenerators = map[imagegraph.NodeType]nodeOutputGenerator{
 	imagegraph.NodeTypePaletteCreate:  generatePaletteCreateNodeOutputs,
 	imagegraph.NodeTypePaletteEdit:    generatePaletteEditNodeOutputs,
 	imagegraph.NodeTypeOutput:         generateOutputNodeOutputs,
+	imagegraph.NodeTypeGenerate:       generateGenerateNodeOutputs,
+	imagegraph.NodeTypeUpscale:        generateUpscaleNodeOutputs,
+	imagegraph.NodeTypeDiff:           generateDiffNodeOutputs,
+	imagegraph.NodeTypeAutoContrast:   generateAutoContrastNodeOutputs,
+	imagegraph.NodeTypeColorSpace:     generateColorSpaceNodeOutputs,
+	imagegraph.NodeTypeContactSheet:   generateContactSheetNodeOutputs,
+	imagegraph.NodeTypeSwitch:         generateSwitchNodeOutputs,
+}
+
+// transformInputs hands generators a placeholder image ID in place of each
+// input image that its connection transforms, which imagegen loads as the
+// transformed image
+func transformInputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+) (
+	context.Context,
+	*imagegraph.NodeNeedsOutputsEvent,
+	error,
+) {
+	var transformed map[imagegraph.ImageID]imagegen.TransformedInput
+	inputs := slices.Clone(event.Inputs)
+
+	for i, input := range inputs {
+		if input.Transform == imagegraph.ConnectionTransformNone || input.ImageID.IsNil() {
+			continue
+		}
+
+		placeholder, err := imagegraph.NewImageID()
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not transform input %q: %w", input.Name, err)
+		}
+
+		if transformed == nil {
+			transformed = make(map[imagegraph.ImageID]imagegen.TransformedInput)
+		}
+		transformed[placeholder] = imagegen.TransformedInput{
+			ImageID:   input.ImageID,
+			Transform: input.Transform,
+		}
+		inputs[i].ImageID = placeholder
+	}
+
+	if transformed == nil {
+		return ctx, event, nil
+	}
+
+	// The event is shared with other handlers, so it's copied rather than
+	// changed
+	transformedEvent := *event
+	transformedEvent.Inputs = inputs
+
+	return imagegen.WithTransformedInputs(ctx, transformed), &transformedEvent, nil
+}
+
+// resolveExpressions hands generators the node's config with its
+// expressions evaluated against the sizes of its input images and the
+// graph parameters they use. Bypassed nodes don't use their config, so
+// theirs is left as it is.
+func resolveExpressions(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) (
+	*imagegraph.NodeNeedsOutputsEvent,
+	error,
+) {
+	if len(event.Expressions) == 0 || event.Bypassed {
+		return event, nil
+	}
+
+	sizes := make(map[imagegraph.InputName]imagegraph.ImageSize)
+	for _, input := range event.Inputs {
+		if input.ImageID.IsNil() {
+			continue
+		}
+
+		size, err := imageGen.ImageSize(ctx, input.ImageID)
+		if err != nil {
+			return nil, fmt.Errorf("could not read size of input %q: %w", input.Name, err)
+		}
+		sizes[input.Name] = size
+	}
+
+	config, err := event.Expressions.Resolve(
+		event.NodeConfig,
+		imagegraph.ExpressionVariables(event.NodeType, sizes, event.Parameters),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The event is shared with other handlers, so it's copied rather than
+	// changed
+	resolvedEvent := *event
+	resolvedEvent.NodeConfig = config
+
+	return &resolvedEvent, nil
+}
+
+// generateBypassedNodeOutputs forwards a bypassed node's primary input image
+// to its primary output instead of running the node type's generator
+func generateBypassedNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	def := imagegraph.NodeTypeDefs[event.NodeType]
+
+	inputImageID, err := event.GetInput(def.PrimaryInput())
+	if err != nil {
+		return err
+	}
+
+	return imageGen.GenerateOutputsForBypassedNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		inputImageID,
+		def.PrimaryOutput(),
+	)
 }
 
 func generateBlurNodeOutputs(
@@ -51,6 +174,7 @@ func generateBlurNodeOutputs(
 		event.NodeVersion,
 		inputImageID,
 		config.Radius,
+		config.Linear,
 	)
 }
 
@@ -106,6 +230,7 @@ func generateResizeNodeOutputs(
 		config.Width,
 		config.Height,
 		config.Interpolation,
+		config.Linear,
 	)
 }
 
@@ -137,6 +262,7 @@ func generateResizeMatchNodeOutputs(
 		originalImageID,
 		sizeMatchImageID,
 		config.Interpolation,
+		config.Linear,
 	)
 }
 
@@ -182,6 +308,11 @@ func generatePaletteExtractNodeOutputs(
 		return err
 	}
 
+	seed, ok := imagegraph.EffectiveSeed(config, event.GraphSeed)
+	if !ok {
+		seed = imagegraph.DefaultPaletteExtractSeed
+	}
+
 	return imageGen.GenerateOutputsForPaletteExtractNode(
 		ctx,
 		event.ImageGraphID,
@@ -189,7 +320,10 @@ func generatePaletteExtractNodeOutputs(
 		event.NodeVersion,
 		sourceImageID,
 		config.NumColors,
-		config.Method,
+		config.ExtractMethod(),
+		config.MaxSamples,
+		seed,
+		event.Implementation,
 	)
 }
 
@@ -221,6 +355,7 @@ func generatePaletteApplyNodeOutputs(
 		sourceImageID,
 		paletteImageID,
 		config,
+		event.Implementation,
 	)
 }
 
@@ -284,6 +419,11 @@ func generateOutputNodeOutputs(
 	event *imagegraph.NodeNeedsOutputsEvent,
 	imageGen *imagegen.ImageGen,
 ) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigOutput)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate Output Node outputs")
+	}
+
 	inputImageID, err := event.GetInput("input")
 	if err != nil {
 		return err
@@ -295,5 +435,230 @@ func generateOutputNodeOutputs(
 		event.NodeID,
 		event.NodeVersion,
 		inputImageID,
+		config.AnimationFormat,
+		event.ColorManagement,
+		config.StripMetadata,
+	)
+}
+
+func generateGenerateNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigGenerate)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate Generate Node outputs")
+	}
+
+	// New nodes start generating with the default config before a prompt
+	// has been provided
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	// The reference input is optional, a nil image means it isn't connected
+	referenceImageID, _ := event.GetInput("reference")
+
+	var seed *int
+	if value, ok := imagegraph.EffectiveSeed(config, event.GraphSeed); ok {
+		seed = &value
+	}
+
+	return imageGen.GenerateOutputsForGenerateNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		referenceImageID,
+		imagegen.GenerateRequest{
+			Provider:       config.Provider,
+			Prompt:         config.Prompt,
+			NegativePrompt: config.NegativePrompt,
+			Model:          config.Model,
+			Width:          config.Width,
+			Height:         config.Height,
+			Seed:           seed,
+			Strength:       config.Strength,
+		},
+	)
+}
+
+func generateUpscaleNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigUpscale)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate Upscale Node outputs")
+	}
+
+	inputImageID, err := event.GetInput("original")
+	if err != nil {
+		return err
+	}
+
+	return imageGen.GenerateOutputsForUpscaleNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		inputImageID,
+		config.Scale,
+		config.Model,
+	)
+}
+
+func generateDiffNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigDiff)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate Diff Node outputs")
+	}
+
+	baseImageID, err := event.GetInput("base")
+	if err != nil {
+		return err
+	}
+
+	compareImageID, err := event.GetInput("compare")
+	if err != nil {
+		return err
+	}
+
+	return imageGen.GenerateOutputsForDiffNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		baseImageID,
+		compareImageID,
+		config.Amplify,
+	)
+}
+
+func generateAutoContrastNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigAutoContrast)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate AutoContrast Node outputs")
+	}
+
+	inputImageID, err := event.GetInput("original")
+	if err != nil {
+		return err
+	}
+
+	return imageGen.GenerateOutputsForAutoContrastNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		inputImageID,
+		config.Mode,
+		config.ClipPercent,
+	)
+}
+
+func generateColorSpaceNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigColorSpace)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate ColorSpace Node outputs")
+	}
+
+	inputImageID, err := event.GetInput("original")
+	if err != nil {
+		return err
+	}
+
+	return imageGen.GenerateOutputsForColorSpaceNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		inputImageID,
+		config.From,
+		config.To,
+	)
+}
+
+// generateContactSheetNodeOutputs lays out the images of the node's inputs
+// in order, skipping inputs without one, each labelled with the name of the
+// node it came from
+func generateContactSheetNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigContactSheet)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate ContactSheet Node outputs")
+	}
+
+	var images []imagegen.ContactSheetImage
+	for _, input := range event.Inputs {
+		if input.ImageID.IsNil() {
+			continue
+		}
+
+		label := input.FromNodeName
+		if label == "" {
+			label = string(input.Name)
+		}
+
+		images = append(images, imagegen.ContactSheetImage{
+			ImageID: input.ImageID,
+			Label:   label,
+		})
+	}
+
+	return imageGen.GenerateOutputsForContactSheetNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		images,
+		config.Columns,
+		config.CellSize,
+		config.Spacing,
+		config.Labels,
+		config.Background,
+	)
+}
+
+// generateSwitchNodeOutputs forwards the image on the node's selected input
+func generateSwitchNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigSwitch)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate Switch Node outputs")
+	}
+
+	inputImageID, err := event.GetInput(config.SelectedInput())
+	if err != nil {
+		return err
+	}
+
+	return imageGen.GenerateOutputsForSwitchNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		inputImageID,
+		config.SelectedInput(),
 	)
 }
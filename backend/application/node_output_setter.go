@@ -5,14 +5,13 @@ import (
 	"fmt"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
-	"github.com/dmpettyp/dorky/messagebus"
 )
 
 type NodeUpdater struct {
-	messageBus *messagebus.MessageBus
+	messageBus CommandHandler
 }
 
-func NewNodeUpdater(messageBus *messagebus.MessageBus) *NodeUpdater {
+func NewNodeUpdater(messageBus CommandHandler) *NodeUpdater {
 	return &NodeUpdater{
 		messageBus: messageBus,
 	}
@@ -66,6 +65,27 @@ func (s *NodeUpdater) SetNodePreviewImage(
 	return nil
 }
 
+func (s *NodeUpdater) SetNodeGenerationFailed(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	message string,
+	nodeVersion imagegraph.NodeVersion,
+) error {
+	cmd := NewSetImageGraphNodeGenerationFailedCommand(
+		imageGraphID,
+		nodeID,
+		message,
+		nodeVersion,
+	)
+
+	if err := s.messageBus.HandleCommand(ctx, cmd); err != nil {
+		return fmt.Errorf("could not set node generation failure: %w", err)
+	}
+
+	return nil
+}
+
 func (s *NodeUpdater) SetNodeConfig(
 	ctx context.Context,
 	imageGraphID imagegraph.ImageGraphID,
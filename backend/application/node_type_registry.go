@@ -0,0 +1,85 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+)
+
+// RegisterNodeType adds a node type declared outside the code, whose nodes
+// are configured with the given fields and generate their outputs with the
+// given operation. Node types must be registered at startup, before any graph
+// is loaded or any message is handled.
+func RegisterNodeType(
+	name string,
+	def imagegraph.NodeTypeDef,
+	fields []imagegraph.FieldSchema,
+	operation imagegen.NodeOperation,
+) (
+	imagegraph.NodeType,
+	error,
+) {
+	if (operation.Builtin == "") == (operation.ProcessorURL == "") {
+		return imagegraph.NodeTypeNone, fmt.Errorf("node type %q needs either a built-in operation or a processor", name)
+	}
+
+	nodeType, err := imagegraph.RegisterNodeType(name, def, fields)
+	if err != nil {
+		return imagegraph.NodeTypeNone, err
+	}
+
+	nodeOutputGenerators[nodeType] = func(
+		ctx context.Context,
+		event *imagegraph.NodeNeedsOutputsEvent,
+		imageGen *imagegen.ImageGen,
+	) error {
+		return generateDeclaredNodeOutputs(ctx, event, imageGen, name, operation)
+	}
+
+	return nodeType, nil
+}
+
+func generateDeclaredNodeOutputs(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+	imageGen *imagegen.ImageGen,
+	name string,
+	operation imagegen.NodeOperation,
+) error {
+	config, ok := event.NodeConfig.(*imagegraph.NodeConfigDeclared)
+	if !ok {
+		return fmt.Errorf("invalid config provided to generate %s Node outputs", name)
+	}
+
+	def := imagegraph.NodeTypeDefs[event.NodeType]
+
+	inputs := make(map[imagegraph.InputName]imagegraph.ImageID, len(event.Inputs))
+	for _, input := range event.Inputs {
+		if !input.ImageID.IsNil() {
+			inputs[input.Name] = input.ImageID
+		}
+	}
+
+	if operation.Builtin != "" {
+		if _, err := event.GetInput(def.PrimaryInput()); err != nil {
+			return err
+		}
+	}
+
+	return imageGen.GenerateOutputsForDeclaredNode(
+		ctx,
+		event.ImageGraphID,
+		event.NodeID,
+		event.NodeVersion,
+		imagegen.DeclaredNode{
+			NodeType:     name,
+			Operation:    operation,
+			Inputs:       inputs,
+			PrimaryInput: def.PrimaryInput(),
+			Outputs:      def.Outputs,
+			Config:       config.Values,
+		},
+	)
+}
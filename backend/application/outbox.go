@@ -0,0 +1,267 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/dmpettyp/artwork/logging"
+)
+
+// outboxBatchSize is how many events are claimed from the outbox at a time
+const outboxBatchSize = 100
+
+// outboxLease is how long claimed events are left to the relay that claimed
+// them before other relays may publish them
+const outboxLease = time.Minute
+
+// requestIDCarrierKey is the carrier key the request ID is stored under
+const requestIDCarrierKey = "x-request-id"
+
+// OutboxEvent is an event committed along with the aggregate it came from,
+// waiting for an OutboxRelay to publish it
+type OutboxEvent struct {
+	ID    int64
+	Event messages.Event
+
+	// Carrier holds the trace context and request ID of the command that
+	// committed the event, so its handlers are traced and logged with it
+	Carrier map[string]string
+}
+
+// Outbox stores the events committed by a UnitOfWork until they are
+// published
+type Outbox interface {
+	// Claim leases up to limit unpublished events, oldest first. Other
+	// relays skip claimed events until the lease runs out.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error)
+
+	MarkPublished(ctx context.Context, ids []int64) error
+
+	// Committed receives a value when events are committed to the outbox
+	Committed() <-chan struct{}
+}
+
+// NewOutboxCarrier captures the trace context and request ID of ctx to be
+// stored with the events committed in it
+func NewOutboxCarrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	if requestID, ok := logging.RequestIDFromContext(ctx); ok {
+		carrier[requestIDCarrierKey] = requestID
+	}
+
+	return carrier
+}
+
+// outboxContext restores the trace context and request ID of a carrier
+func outboxContext(ctx context.Context, carrier map[string]string) context.Context {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+
+	if requestID, ok := carrier[requestIDCarrierKey]; ok {
+		ctx = logging.WithRequestID(ctx, requestID)
+	}
+
+	return ctx
+}
+
+// OutboxRelay publishes the events in an Outbox to the message bus. Events
+// are only marked published after their handlers ran, so an event may be
+// published more than once if the process dies while publishing it, but is
+// never lost.
+type OutboxRelay struct {
+	mb           *messagebus.MessageBus
+	outbox       Outbox
+	logger       *slog.Logger
+	pollInterval time.Duration
+
+	cancel func()
+	wg     sync.WaitGroup
+}
+
+// OutboxRelayOption configures optional OutboxRelay behavior
+type OutboxRelayOption func(*OutboxRelay)
+
+// WithOutboxPollInterval sets how often the outbox is checked for events that
+// weren't signalled as committed, such as events left by another process
+func WithOutboxPollInterval(interval time.Duration) OutboxRelayOption {
+	return func(r *OutboxRelay) {
+		r.pollInterval = interval
+	}
+}
+
+// NewOutboxRelay creates a relay for the outbox and registers the command
+// handlers it publishes with on the provided message bus
+func NewOutboxRelay(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	outbox Outbox,
+	logger *slog.Logger,
+	opts ...OutboxRelayOption,
+) (
+	*OutboxRelay,
+	error,
+) {
+	relay := &OutboxRelay{
+		mb:           mb,
+		outbox:       outbox,
+		logger:       logger,
+		pollInterval: 5 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(relay)
+	}
+
+	err := errors.Join(
+		registerCommandHandler(mb, queues, relay.HandlePublishOutboxEventsCommand),
+		registerCommandHandler(mb, queues, relay.HandleMarkOutboxEventsPublishedCommand),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create outbox relay: %w", err)
+	}
+
+	return relay, nil
+}
+
+// Start publishes events as they are committed to the outbox until Stop is
+// called. The message bus must be running.
+func (r *OutboxRelay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			r.relay(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.outbox.Committed():
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops publishing and waits for the batch being published to finish.
+// Events left in the outbox are published when the relay next starts.
+func (r *OutboxRelay) Stop(ctx context.Context) error {
+	r.logger.Info("stopping outbox relay")
+
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop outbox relay: %w", ctx.Err())
+	}
+}
+
+// relay publishes batches of events until the outbox is empty
+func (r *OutboxRelay) relay(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := r.publishBatch(ctx)
+		if err != nil {
+			r.logger.Error("could not publish outbox events", "error", err)
+			return
+		}
+
+		if published < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// publishBatch claims a batch of events and publishes them. Events committed
+// in the same context are published together in that context.
+func (r *OutboxRelay) publishBatch(ctx context.Context) (int, error) {
+	claimed, err := r.outbox.Claim(ctx, outboxBatchSize, outboxLease)
+	if err != nil {
+		return 0, fmt.Errorf("could not claim outbox events: %w", err)
+	}
+
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, 0, len(claimed))
+	for start := 0; start < len(claimed); {
+		end := start + 1
+		for end < len(claimed) && maps.Equal(claimed[end].Carrier, claimed[start].Carrier) {
+			end++
+		}
+
+		events := make([]messages.Event, 0, end-start)
+		for _, e := range claimed[start:end] {
+			events = append(events, e.Event)
+			ids = append(ids, e.ID)
+		}
+
+		publishCtx := outboxContext(ctx, claimed[start].Carrier)
+		if err := r.mb.HandleCommand(publishCtx, NewPublishOutboxEventsCommand(events)); err != nil {
+			return 0, fmt.Errorf("could not publish outbox events: %w", err)
+		}
+
+		start = end
+	}
+
+	// The message bus dispatches the events a command returns before it
+	// handles the next command, so by the time this command is handled every
+	// event above has been through its handlers
+	if err := r.mb.HandleCommand(ctx, NewMarkOutboxEventsPublishedCommand(ids)); err != nil {
+		return 0, fmt.Errorf("could not mark outbox events published: %w", err)
+	}
+
+	return len(claimed), nil
+}
+
+// HandlePublishOutboxEventsCommand returns the command's events for the
+// message bus to dispatch
+func (r *OutboxRelay) HandlePublishOutboxEventsCommand(
+	ctx context.Context,
+	command *PublishOutboxEventsCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return command.Events, nil
+}
+
+func (r *OutboxRelay) HandleMarkOutboxEventsPublishedCommand(
+	ctx context.Context,
+	command *MarkOutboxEventsPublishedCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	if err := r.outbox.MarkPublished(ctx, command.EventIDs); err != nil {
+		return nil, fmt.Errorf("could not process MarkOutboxEventsPublishedCommand: %w", err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,348 @@
+package application
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// How a PipelineRun ended
+const (
+	// Every Output node generated the final images of the run's input
+	PipelineRunCompleted = "completed"
+	// A node the run regenerated failed
+	PipelineRunFailed = "failed"
+	// The graph settled without every Output node generated, e.g. because
+	// other inputs have no image yet or there are no Output nodes
+	PipelineRunIncomplete = "incomplete"
+	// Another input image started a new run before the graph settled
+	PipelineRunSuperseded = "superseded"
+)
+
+// PipelineRun records one run of an ImageGraph's pipeline, from an image
+// being set on an Input node until the graph settles: the nodes that
+// regenerated on the way, how long they took, and the final images
+// produced. Runs are recorded when they end.
+type PipelineRun struct {
+	// ID is the ID of the event that set the triggering image
+	ID             string
+	ImageGraphID   imagegraph.ImageGraphID
+	TriggerNodeID  imagegraph.NodeID
+	TriggerImageID imagegraph.ImageID
+	StartedAt      time.Time
+	EndedAt        time.Time
+	Status         string
+	Nodes          []PipelineRunNode
+	Outputs        []CompletedOutput
+}
+
+// Duration is how long the run took
+func (r PipelineRun) Duration() time.Duration {
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// PipelineRunNode is a node regenerated during a PipelineRun. Nodes that
+// regenerated more than once, e.g. because their config changed during the
+// run, are timed from their first generation starting to their last ending.
+type PipelineRunNode struct {
+	NodeID      imagegraph.NodeID
+	NodeType    imagegraph.NodeType
+	StartedAt   time.Time
+	EndedAt     time.Time
+	Generations int
+	Failed      bool
+}
+
+// Duration is how long the node took to regenerate, or 0 when it hadn't
+// finished when the run ended
+func (n PipelineRunNode) Duration() time.Duration {
+	if n.EndedAt.IsZero() {
+		return 0
+	}
+	return n.EndedAt.Sub(n.StartedAt)
+}
+
+// PipelineRunStore persists the PipelineRuns of ImageGraphs
+type PipelineRunStore interface {
+	Add(ctx context.Context, run PipelineRun) error
+	// ListByImageGraph retrieves the most recent runs of an ImageGraph,
+	// newest first
+	ListByImageGraph(
+		ctx context.Context,
+		imageGraphID imagegraph.ImageGraphID,
+		limit int,
+	) ([]PipelineRun, error)
+}
+
+// PipelineRunEventHandlers follows the pipelines of ImageGraphs through their
+// events, recording a PipelineRun each time an input image runs through one
+type PipelineRunEventHandlers struct {
+	runs            PipelineRunStore
+	imageGraphViews ImageGraphViews
+	clock           Clock
+
+	mu   sync.Mutex
+	open map[imagegraph.ImageGraphID]*PipelineRun
+}
+
+// PipelineRunEventHandlersOption configures optional PipelineRunEventHandlers
+// behavior
+type PipelineRunEventHandlersOption func(*PipelineRunEventHandlers)
+
+// WithPipelineRunClock sets the Clock runs that end incomplete are ended at
+func WithPipelineRunClock(clock Clock) PipelineRunEventHandlersOption {
+	return func(h *PipelineRunEventHandlers) {
+		h.clock = clock
+	}
+}
+
+// NewPipelineRunEventHandlers initializes the handlers struct that records
+// PipelineRuns and registers all handlers with the provided message bus
+func NewPipelineRunEventHandlers(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	runs PipelineRunStore,
+	imageGraphViews ImageGraphViews,
+	opts ...PipelineRunEventHandlersOption,
+) (
+	*PipelineRunEventHandlers,
+	error,
+) {
+	handlers := &PipelineRunEventHandlers{
+		runs:            runs,
+		imageGraphViews: imageGraphViews,
+		clock:           SystemClock,
+		open:            make(map[imagegraph.ImageGraphID]*PipelineRun),
+	}
+
+	for _, opt := range opts {
+		opt(handlers)
+	}
+
+	err := errors.Join(
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeNeedsOutputsEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeOutputImageSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeInputImageSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeGenerationFailedEvent),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create pipeline run event handlers: %w", err)
+	}
+
+	return handlers, nil
+}
+
+// HandleNodeNeedsOutputsEvent adds the node to the ImageGraph's open run
+func (h *PipelineRunEventHandlers) HandleNodeNeedsOutputsEvent(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	if event.NodeType == imagegraph.NodeTypeInput {
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	run, ok := h.open[event.ImageGraphID]
+	if !ok {
+		return nil, nil
+	}
+
+	node := runNode(run, event.NodeID, event.NodeType)
+	if node.Generations == 0 {
+		node.StartedAt = event.GetTimestamp()
+	}
+	node.Generations++
+	node.EndedAt = time.Time{}
+
+	return nil, nil
+}
+
+// HandleNodeOutputImageSetEvent starts a run when an Input node's image is
+// set, superseding any run still open, and otherwise times the node that
+// generated the image
+func (h *PipelineRunEventHandlers) HandleNodeOutputImageSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeOutputImageSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	if event.NodeType == imagegraph.NodeTypeInput {
+		return nil, h.start(ctx, event)
+	}
+
+	h.mu.Lock()
+	if run, ok := h.open[event.ImageGraphID]; ok {
+		if i := slices.IndexFunc(run.Nodes, func(n PipelineRunNode) bool { return n.NodeID == event.NodeID }); i >= 0 {
+			run.Nodes[i].EndedAt = event.GetTimestamp()
+		}
+	}
+	h.mu.Unlock()
+
+	return nil, h.checkEnded(ctx, event.ImageGraphID)
+}
+
+// HandleNodeInputImageSetEvent checks whether the run ended, since an image
+// reaching a node that waits for other inputs may be the last thing it does
+func (h *PipelineRunEventHandlers) HandleNodeInputImageSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeInputImageSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.checkEnded(ctx, event.ImageGraphID)
+}
+
+// HandleNodeGenerationFailedEvent marks the node failed in the open run
+func (h *PipelineRunEventHandlers) HandleNodeGenerationFailedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeGenerationFailedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.mu.Lock()
+	if run, ok := h.open[event.ImageGraphID]; ok {
+		node := runNode(run, event.NodeID, event.NodeType)
+		if node.Generations == 0 {
+			node.StartedAt = event.GetTimestamp()
+			node.Generations++
+		}
+		node.EndedAt = event.GetTimestamp()
+		node.Failed = true
+	}
+	h.mu.Unlock()
+
+	return nil, h.checkEnded(ctx, event.ImageGraphID)
+}
+
+// start opens a run for an image set on an Input node. A run still open is
+// recorded as superseded.
+func (h *PipelineRunEventHandlers) start(ctx context.Context, event *imagegraph.NodeOutputImageSetEvent) error {
+	h.mu.Lock()
+	superseded, ok := h.open[event.ImageGraphID]
+	h.open[event.ImageGraphID] = &PipelineRun{
+		ID:             event.EventID.String(),
+		ImageGraphID:   event.ImageGraphID,
+		TriggerNodeID:  event.NodeID,
+		TriggerImageID: event.ImageID,
+		StartedAt:      event.GetTimestamp(),
+	}
+	h.mu.Unlock()
+
+	if ok {
+		superseded.EndedAt = event.GetTimestamp()
+		superseded.Status = PipelineRunSuperseded
+		if err := h.record(ctx, superseded); err != nil {
+			return err
+		}
+	}
+
+	return h.checkEnded(ctx, event.ImageGraphID)
+}
+
+// checkEnded records the ImageGraph's open run if the graph has settled
+func (h *PipelineRunEventHandlers) checkEnded(ctx context.Context, imageGraphID imagegraph.ImageGraphID) error {
+	h.mu.Lock()
+	_, ok := h.open[imageGraphID]
+	h.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	ig, err := h.imageGraphViews.Get(ctx, imageGraphID)
+	if err != nil {
+		return fmt.Errorf("could not check pipeline run of ImageGraph %q: %w", imageGraphID, err)
+	}
+
+	if !ig.Settled() {
+		return nil
+	}
+
+	h.mu.Lock()
+	run, ok := h.open[imageGraphID]
+	delete(h.open, imageGraphID)
+	h.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	run.EndedAt = h.clock.Now().UTC()
+	run.Status = PipelineRunIncomplete
+
+	for _, node := range run.Nodes {
+		if !node.EndedAt.IsZero() && node.EndedAt.After(run.EndedAt) {
+			run.EndedAt = node.EndedAt
+		}
+	}
+
+	if ig.PipelineComplete() {
+		run.Status = PipelineRunCompleted
+	}
+	if slices.ContainsFunc(run.Nodes, func(n PipelineRunNode) bool { return n.Failed }) {
+		run.Status = PipelineRunFailed
+	}
+
+	// Output nodes that failed still hold the images of an earlier run
+	for _, export := range ig.Exports() {
+		generated := func(n PipelineRunNode) bool {
+			return n.NodeID == export.NodeID && !n.Failed && !n.EndedAt.IsZero()
+		}
+		if !slices.ContainsFunc(run.Nodes, generated) {
+			continue
+		}
+		run.Outputs = append(run.Outputs, CompletedOutput{
+			Name:     export.Name,
+			NodeID:   export.NodeID,
+			ImageID:  export.ImageID,
+			Duration: export.GeneratedAt.Sub(run.StartedAt),
+		})
+	}
+
+	return h.record(ctx, run)
+}
+
+// record stores a run that ended, its nodes in the order they started
+func (h *PipelineRunEventHandlers) record(ctx context.Context, run *PipelineRun) error {
+	slices.SortStableFunc(run.Nodes, func(a, b PipelineRunNode) int {
+		return cmp.Compare(a.StartedAt.UnixNano(), b.StartedAt.UnixNano())
+	})
+
+	if err := h.runs.Add(ctx, *run); err != nil {
+		return fmt.Errorf("could not record pipeline run of ImageGraph %q: %w", run.ImageGraphID, err)
+	}
+
+	return nil
+}
+
+// runNode returns the node in the run, adding it if it isn't there yet. The
+// caller must hold h.mu.
+func runNode(run *PipelineRun, nodeID imagegraph.NodeID, nodeType imagegraph.NodeType) *PipelineRunNode {
+	for i := range run.Nodes {
+		if run.Nodes[i].NodeID == nodeID {
+			return &run.Nodes[i]
+		}
+	}
+
+	run.Nodes = append(run.Nodes, PipelineRunNode{NodeID: nodeID, NodeType: nodeType})
+	return &run.Nodes[len(run.Nodes)-1]
+}
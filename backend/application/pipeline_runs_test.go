@@ -0,0 +1,190 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/testsupport"
+)
+
+// graphView serves a single ImageGraph as it is now
+type graphView struct {
+	ImageGraphViews
+	ig *imagegraph.ImageGraph
+}
+
+func (v graphView) Get(_ context.Context, _ imagegraph.ImageGraphID) (*imagegraph.ImageGraph, error) {
+	return v.ig, nil
+}
+
+type pipelineRuns struct {
+	runs []PipelineRun
+}
+
+func (s *pipelineRuns) Add(_ context.Context, run PipelineRun) error {
+	s.runs = append(s.runs, run)
+	return nil
+}
+
+func (s *pipelineRuns) ListByImageGraph(context.Context, imagegraph.ImageGraphID, int) ([]PipelineRun, error) {
+	return s.runs, nil
+}
+
+func TestPipelineRunEventHandlers(t *testing.T) {
+	ctx := context.Background()
+
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(imagegraph.MustNewImageID()).
+		WithOutput().Named("poster").
+		WithOutput().Named("thumbnail").
+		Connect("input", "poster").
+		Connect("input", "thumbnail")
+
+	ig := b.MustBuild(t)
+	inputID, posterID, thumbID := b.NodeID("input"), b.NodeID("poster"), b.NodeID("thumbnail")
+
+	store := &pipelineRuns{}
+	h := &PipelineRunEventHandlers{
+		runs:            store,
+		imageGraphViews: graphView{ig: ig},
+		clock:           SystemClock,
+		open:            make(map[imagegraph.ImageGraphID]*PipelineRun),
+	}
+
+	// deliver hands the graph's events to the handlers, as the message bus
+	// would once they're committed
+	deliver := func(t *testing.T) {
+		t.Helper()
+
+		for _, event := range ig.GetEvents() {
+			var err error
+			switch e := event.(type) {
+			case *imagegraph.NodeNeedsOutputsEvent:
+				_, err = h.HandleNodeNeedsOutputsEvent(ctx, e)
+			case *imagegraph.NodeOutputImageSetEvent:
+				_, err = h.HandleNodeOutputImageSetEvent(ctx, e)
+			case *imagegraph.NodeInputImageSetEvent:
+				_, err = h.HandleNodeInputImageSetEvent(ctx, e)
+			case *imagegraph.NodeGenerationFailedEvent:
+				_, err = h.HandleNodeGenerationFailedEvent(ctx, e)
+			}
+			if err != nil {
+				t.Fatalf("failed to handle %T: %v", event, err)
+			}
+		}
+		ig.ResetEvents()
+	}
+
+	setOutput := func(t *testing.T, nodeID imagegraph.NodeID, outputName imagegraph.OutputName, imageID imagegraph.ImageID) {
+		t.Helper()
+
+		node, _ := ig.Nodes.Get(nodeID)
+		if err := ig.SetNodeOutputImage(nodeID, outputName, imageID, node.Version); err != nil {
+			t.Fatalf("failed to set output image: %v", err)
+		}
+		deliver(t)
+	}
+
+	setInput := func(t *testing.T) imagegraph.ImageID {
+		t.Helper()
+
+		imageID := imagegraph.MustNewImageID()
+		setOutput(t, inputID, "original", imageID)
+		return imageID
+	}
+
+	propagate := func(t *testing.T, imageID imagegraph.ImageID) {
+		t.Helper()
+
+		if err := ig.PropagateOutputImageToConnections(inputID, "original", imageID); err != nil {
+			t.Fatalf("failed to propagate image: %v", err)
+		}
+		deliver(t)
+	}
+
+	ig.ResetEvents()
+
+	t.Run("records a completed run once every output is generated", func(t *testing.T) {
+		store.runs = nil
+
+		imageID := setInput(t)
+		propagate(t, imageID)
+		setOutput(t, posterID, "final", imagegraph.MustNewImageID())
+
+		if len(store.runs) != 0 {
+			t.Fatalf("expected no run before the graph settles, got %+v", store.runs)
+		}
+
+		finalID := imagegraph.MustNewImageID()
+		setOutput(t, thumbID, "final", finalID)
+
+		if len(store.runs) != 1 {
+			t.Fatalf("expected 1 run, got %d", len(store.runs))
+		}
+
+		run := store.runs[0]
+		if run.Status != PipelineRunCompleted {
+			t.Errorf("expected a completed run, got %q", run.Status)
+		}
+		if run.TriggerNodeID != inputID || run.TriggerImageID != imageID {
+			t.Errorf("expected the run to be triggered by the input image, got %v %v", run.TriggerNodeID, run.TriggerImageID)
+		}
+		if len(run.Nodes) != 2 || run.Nodes[0].Generations != 1 || run.Nodes[0].EndedAt.IsZero() {
+			t.Errorf("expected both output nodes generated once, got %+v", run.Nodes)
+		}
+		if len(run.Outputs) != 2 {
+			t.Fatalf("expected 2 outputs, got %+v", run.Outputs)
+		}
+		if run.Outputs[0].ImageID != finalID && run.Outputs[1].ImageID != finalID {
+			t.Errorf("expected the thumbnail's final image among the outputs, got %+v", run.Outputs)
+		}
+		if run.Duration() < 0 {
+			t.Errorf("expected a non-negative duration, got %v", run.Duration())
+		}
+	})
+
+	t.Run("records a run superseded by another input image", func(t *testing.T) {
+		store.runs = nil
+
+		setInput(t)
+		imageID := setInput(t)
+
+		if len(store.runs) != 1 || store.runs[0].Status != PipelineRunSuperseded {
+			t.Fatalf("expected a superseded run, got %+v", store.runs)
+		}
+
+		propagate(t, imageID)
+		setOutput(t, posterID, "final", imagegraph.MustNewImageID())
+		setOutput(t, thumbID, "final", imagegraph.MustNewImageID())
+
+		if len(store.runs) != 2 || store.runs[1].TriggerImageID != imageID {
+			t.Errorf("expected the second image's run recorded, got %+v", store.runs)
+		}
+	})
+
+	t.Run("records a failed run", func(t *testing.T) {
+		store.runs = nil
+
+		imageID := setInput(t)
+		propagate(t, imageID)
+
+		node, _ := ig.Nodes.Get(posterID)
+		if err := ig.SetNodeGenerationFailed(posterID, "out of memory", node.Version); err != nil {
+			t.Fatalf("failed to fail generation: %v", err)
+		}
+		deliver(t)
+
+		setOutput(t, thumbID, "final", imagegraph.MustNewImageID())
+
+		if len(store.runs) != 1 {
+			t.Fatalf("expected 1 run, got %d", len(store.runs))
+		}
+		if store.runs[0].Status != PipelineRunFailed {
+			t.Errorf("expected a failed run, got %q", store.runs[0].Status)
+		}
+		if len(store.runs[0].Outputs) != 1 {
+			t.Errorf("expected only the thumbnail's output, got %+v", store.runs[0].Outputs)
+		}
+	})
+}
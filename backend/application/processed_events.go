@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ProcessedEventStore records the events each handler has processed, so that
+// events delivered again aren't processed twice
+type ProcessedEventStore interface {
+	Processed(ctx context.Context, handler string, eventID imagegraph.EventID) (bool, error)
+	MarkProcessed(ctx context.Context, handler string, eventID imagegraph.EventID) error
+}
+
+// identifiedEvent is an event with an ID that survives redelivery
+type identifiedEvent interface {
+	messages.Event
+	GetEventID() imagegraph.EventID
+}
+
+// registerIdempotentEventHandler registers an event handler that skips the
+// events it already processed. Without a store every event is handled.
+func registerIdempotentEventHandler[E identifiedEvent](
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	processed ProcessedEventStore,
+	handler func(context.Context, E) ([]messages.Event, error),
+) error {
+	name := handlerName(handler)
+
+	if processed == nil {
+		return registerNamedEventHandler(mb, queues, deadLetters, name, handler)
+	}
+
+	return registerNamedEventHandler(mb, queues, deadLetters, name, idempotent(processed, name, handler))
+}
+
+// idempotent wraps an event handler so that it only processes each event
+// once. An event is only recorded as processed once the handler succeeds, so
+// an event that failed is processed again when it is redelivered. Events
+// without an ID, such as events stored before events had IDs, are always
+// processed.
+func idempotent[E identifiedEvent](
+	processed ProcessedEventStore,
+	name string,
+	handler func(context.Context, E) ([]messages.Event, error),
+) func(context.Context, E) ([]messages.Event, error) {
+	return func(ctx context.Context, event E) ([]messages.Event, error) {
+		eventID := event.GetEventID()
+		if eventID.IsNil() {
+			return handler(ctx, event)
+		}
+
+		done, err := processed.Processed(ctx, name, eventID)
+		if err != nil {
+			return nil, fmt.Errorf("could not check whether %s processed event %q: %w", name, eventID, err)
+		}
+
+		if done {
+			return nil, nil
+		}
+
+		events, err := handler(ctx, event)
+		if err != nil {
+			return events, err
+		}
+
+		if err := processed.MarkProcessed(ctx, name, eventID); err != nil {
+			return events, fmt.Errorf("could not record that %s processed event %q: %w", name, eventID, err)
+		}
+
+		return events, nil
+	}
+}
@@ -0,0 +1,129 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/testsupport"
+)
+
+type processedEvents struct {
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+func (p *processedEvents) Processed(_ context.Context, handler string, eventID imagegraph.EventID) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done[handler+"/"+eventID.String()], nil
+}
+
+func (p *processedEvents) MarkProcessed(_ context.Context, handler string, eventID imagegraph.EventID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[handler+"/"+eventID.String()] = true
+	return nil
+}
+
+func nodeNeedsOutputsEvent(t *testing.T) *imagegraph.NodeNeedsOutputsEvent {
+	t.Helper()
+
+	ig := testsupport.NewGraphBuilder().MustBuild(t)
+	if err := ig.AddNode(imagegraph.MustNewNodeID(), imagegraph.NodeTypeInput, "input"); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	for _, event := range ig.GetEvents() {
+		if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+			return e
+		}
+	}
+
+	t.Fatal("expected a NodeNeedsOutputsEvent")
+	return nil
+}
+
+func TestIdempotentEventHandler(t *testing.T) {
+	ctx := context.Background()
+
+	newHandler := func(fail *bool) (func(context.Context, *imagegraph.NodeNeedsOutputsEvent) ([]messages.Event, error), *int) {
+		calls := 0
+		return func(context.Context, *imagegraph.NodeNeedsOutputsEvent) ([]messages.Event, error) {
+			calls++
+			if fail != nil && *fail {
+				return nil, errors.New("generation failed")
+			}
+			return nil, nil
+		}, &calls
+	}
+
+	t.Run("skips redelivered events", func(t *testing.T) {
+		handler, calls := newHandler(nil)
+		h := idempotent(&processedEvents{done: map[string]bool{}}, "generate", handler)
+
+		event := nodeNeedsOutputsEvent(t)
+		h(ctx, event)
+		h(ctx, event)
+
+		if *calls != 1 {
+			t.Errorf("expected the event to be handled once, got %d", *calls)
+		}
+
+		h(ctx, nodeNeedsOutputsEvent(t))
+		if *calls != 2 {
+			t.Errorf("expected a new event to be handled, got %d calls", *calls)
+		}
+	})
+
+	t.Run("handles redelivered events that failed", func(t *testing.T) {
+		fail := true
+		handler, calls := newHandler(&fail)
+		h := idempotent(&processedEvents{done: map[string]bool{}}, "generate", handler)
+
+		event := nodeNeedsOutputsEvent(t)
+		if _, err := h(ctx, event); err == nil {
+			t.Fatal("expected the handler's error")
+		}
+
+		fail = false
+		h(ctx, event)
+		h(ctx, event)
+
+		if *calls != 2 {
+			t.Errorf("expected the failed event to be handled again once, got %d calls", *calls)
+		}
+	})
+
+	t.Run("tracks each handler separately", func(t *testing.T) {
+		store := &processedEvents{done: map[string]bool{}}
+		first, firstCalls := newHandler(nil)
+		second, secondCalls := newHandler(nil)
+
+		event := nodeNeedsOutputsEvent(t)
+		idempotent(store, "first", first)(ctx, event)
+		idempotent(store, "second", second)(ctx, event)
+
+		if *firstCalls != 1 || *secondCalls != 1 {
+			t.Errorf("expected both handlers to handle the event, got %d and %d", *firstCalls, *secondCalls)
+		}
+	})
+
+	t.Run("always handles events without IDs", func(t *testing.T) {
+		handler, calls := newHandler(nil)
+		h := idempotent(&processedEvents{done: map[string]bool{}}, "generate", handler)
+
+		event := nodeNeedsOutputsEvent(t)
+		event.EventID = imagegraph.EventID{}
+		h(ctx, event)
+		h(ctx, event)
+
+		if *calls != 2 {
+			t.Errorf("expected the event to be handled twice, got %d", *calls)
+		}
+	})
+}
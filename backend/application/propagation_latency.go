@@ -0,0 +1,366 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+)
+
+type propagationLatencyObserver interface {
+	ObserveInputLatency(duration time.Duration)
+	ObserveRegenerationLatency(duration time.Duration)
+}
+
+// PropagationConnection identifies a connection between an upstream node's
+// output and a downstream node's input
+type PropagationConnection struct {
+	FromNodeID     imagegraph.NodeID
+	FromOutputName imagegraph.OutputName
+	ToNodeID       imagegraph.NodeID
+	ToInputName    imagegraph.InputName
+}
+
+// LatencyStats summarises a set of observed latencies
+type LatencyStats struct {
+	Count int
+	Last  time.Duration
+	Mean  time.Duration
+	Max   time.Duration
+	total time.Duration
+}
+
+func (s *LatencyStats) observe(d time.Duration) {
+	s.Count++
+	s.Last = d
+	s.total += d
+	s.Mean = s.total / time.Duration(s.Count)
+	s.Max = max(s.Max, d)
+}
+
+func (s *LatencyStats) merge(other LatencyStats) {
+	if other.Count == 0 {
+		return
+	}
+	s.Count += other.Count
+	s.Last = other.Last
+	s.total += other.total
+	s.Mean = s.total / time.Duration(s.Count)
+	s.Max = max(s.Max, other.Max)
+}
+
+// ConnectionLatency holds the propagation latencies observed for a single
+// connection. Input is the time from the upstream output being set to the
+// downstream input receiving the image, Regeneration is the time until the
+// downstream node has set a new output from it.
+type ConnectionLatency struct {
+	PropagationConnection
+	Input        LatencyStats
+	Regeneration LatencyStats
+}
+
+// PropagationLatencyReport aggregates the propagation latencies of all
+// connections in an ImageGraph
+type PropagationLatencyReport struct {
+	ImageGraphID imagegraph.ImageGraphID
+	Input        LatencyStats
+	Regeneration LatencyStats
+	Connections  []ConnectionLatency
+}
+
+type pendingOutput struct {
+	imageGraphID imagegraph.ImageGraphID
+	nodeID       imagegraph.NodeID
+	outputName   imagegraph.OutputName
+	setAt        time.Time
+}
+
+type outputKey struct {
+	imageGraphID imagegraph.ImageGraphID
+	nodeID       imagegraph.NodeID
+	outputName   imagegraph.OutputName
+}
+
+type pendingRegeneration struct {
+	connection PropagationConnection
+	setAt      time.Time
+}
+
+// PropagationLatencyTracker measures how long images take to flow across
+// connections, using the timestamps of the events emitted along the way
+type PropagationLatencyTracker struct {
+	mu       sync.Mutex
+	observer propagationLatencyObserver
+
+	// Output images that were set and may still arrive at downstream inputs
+	pendingOutputs map[imagegraph.ImageID]pendingOutput
+	latestOutputs  map[outputKey]imagegraph.ImageID
+
+	// Connections whose downstream node received an image and hasn't
+	// regenerated its outputs yet
+	pendingRegenerations map[imagegraph.NodeID][]pendingRegeneration
+
+	connections map[imagegraph.ImageGraphID]map[PropagationConnection]*ConnectionLatency
+}
+
+func NewPropagationLatencyTracker(observer propagationLatencyObserver) *PropagationLatencyTracker {
+	return &PropagationLatencyTracker{
+		observer:             observer,
+		pendingOutputs:       make(map[imagegraph.ImageID]pendingOutput),
+		latestOutputs:        make(map[outputKey]imagegraph.ImageID),
+		pendingRegenerations: make(map[imagegraph.NodeID][]pendingRegeneration),
+		connections:          make(map[imagegraph.ImageGraphID]map[PropagationConnection]*ConnectionLatency),
+	}
+}
+
+func (t *PropagationLatencyTracker) connectionLatency(
+	imageGraphID imagegraph.ImageGraphID,
+	connection PropagationConnection,
+) *ConnectionLatency {
+	graphConnections, ok := t.connections[imageGraphID]
+	if !ok {
+		graphConnections = make(map[PropagationConnection]*ConnectionLatency)
+		t.connections[imageGraphID] = graphConnections
+	}
+
+	latency, ok := graphConnections[connection]
+	if !ok {
+		latency = &ConnectionLatency{PropagationConnection: connection}
+		graphConnections[connection] = latency
+	}
+
+	return latency
+}
+
+// OutputSet records an output image being set on a node. It completes the
+// regeneration of any connections into the node and starts timing the
+// propagation of the image to the node's downstream connections.
+func (t *PropagationLatencyTracker) OutputSet(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName imagegraph.OutputName,
+	imageID imagegraph.ImageID,
+	at time.Time,
+) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, pending := range t.pendingRegenerations[nodeID] {
+		d := at.Sub(pending.setAt)
+		t.connectionLatency(imageGraphID, pending.connection).Regeneration.observe(d)
+		if t.observer != nil {
+			t.observer.ObserveRegenerationLatency(d)
+		}
+	}
+	delete(t.pendingRegenerations, nodeID)
+
+	key := outputKey{imageGraphID, nodeID, outputName}
+	if previous, ok := t.latestOutputs[key]; ok {
+		delete(t.pendingOutputs, previous)
+	}
+
+	t.latestOutputs[key] = imageID
+	t.pendingOutputs[imageID] = pendingOutput{
+		imageGraphID: imageGraphID,
+		nodeID:       nodeID,
+		outputName:   outputName,
+		setAt:        at,
+	}
+}
+
+// InputSet records an image arriving at a node's input from an upstream
+// output
+func (t *PropagationLatencyTracker) InputSet(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	inputName imagegraph.InputName,
+	imageID imagegraph.ImageID,
+	at time.Time,
+) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	output, ok := t.pendingOutputs[imageID]
+	if !ok || output.imageGraphID != imageGraphID {
+		return
+	}
+
+	connection := PropagationConnection{
+		FromNodeID:     output.nodeID,
+		FromOutputName: output.outputName,
+		ToNodeID:       nodeID,
+		ToInputName:    inputName,
+	}
+
+	d := at.Sub(output.setAt)
+	t.connectionLatency(imageGraphID, connection).Input.observe(d)
+	if t.observer != nil {
+		t.observer.ObserveInputLatency(d)
+	}
+
+	// A newer image on the same connection supersedes one that is still
+	// waiting for the node to regenerate
+	pending := slices.DeleteFunc(t.pendingRegenerations[nodeID], func(p pendingRegeneration) bool {
+		return p.connection == connection
+	})
+	t.pendingRegenerations[nodeID] = append(pending, pendingRegeneration{connection: connection, setAt: output.setAt})
+}
+
+// GenerationFailed abandons the regeneration timing of a node's connections
+func (t *PropagationLatencyTracker) GenerationFailed(nodeID imagegraph.NodeID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pendingRegenerations, nodeID)
+}
+
+// NodeRemoved discards all tracking state involving a node
+func (t *PropagationLatencyTracker) NodeRemoved(
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pendingRegenerations, nodeID)
+
+	for key, imageID := range t.latestOutputs {
+		if key.imageGraphID == imageGraphID && key.nodeID == nodeID {
+			delete(t.pendingOutputs, imageID)
+			delete(t.latestOutputs, key)
+		}
+	}
+
+	for connection := range t.connections[imageGraphID] {
+		if connection.FromNodeID == nodeID || connection.ToNodeID == nodeID {
+			delete(t.connections[imageGraphID], connection)
+		}
+	}
+}
+
+// Report returns the latencies observed for the connections of an
+// ImageGraph, sorted by mean regeneration latency with the slowest first
+func (t *PropagationLatencyTracker) Report(
+	imageGraphID imagegraph.ImageGraphID,
+) PropagationLatencyReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := PropagationLatencyReport{
+		ImageGraphID: imageGraphID,
+		Connections:  make([]ConnectionLatency, 0, len(t.connections[imageGraphID])),
+	}
+
+	for _, latency := range t.connections[imageGraphID] {
+		report.Input.merge(latency.Input)
+		report.Regeneration.merge(latency.Regeneration)
+		report.Connections = append(report.Connections, *latency)
+	}
+
+	sort.Slice(report.Connections, func(i, j int) bool {
+		return report.Connections[i].Regeneration.Mean > report.Connections[j].Regeneration.Mean
+	})
+
+	return report
+}
+
+type PropagationLatencyEventHandlers struct {
+	tracker *PropagationLatencyTracker
+}
+
+// NewPropagationLatencyEventHandlers initializes the handlers struct that
+// feeds ImageGraph Events into the propagation latency tracker and registers
+// all handlers with the provided message bus
+func NewPropagationLatencyEventHandlers(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	tracker *PropagationLatencyTracker,
+) (
+	*PropagationLatencyEventHandlers,
+	error,
+) {
+	handlers := &PropagationLatencyEventHandlers{
+		tracker: tracker,
+	}
+
+	err := errors.Join(
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeOutputImageSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeInputImageSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeGenerationFailedEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeRemovedEvent),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create propagation latency event handlers: %w", err)
+	}
+
+	return handlers, nil
+}
+
+func (h *PropagationLatencyEventHandlers) HandleNodeOutputImageSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeOutputImageSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.tracker.OutputSet(
+		event.ImageGraphID,
+		event.NodeID,
+		event.OutputName,
+		event.ImageID,
+		event.GetTimestamp(),
+	)
+
+	return nil, nil
+}
+
+func (h *PropagationLatencyEventHandlers) HandleNodeInputImageSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeInputImageSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.tracker.InputSet(
+		event.ImageGraphID,
+		event.NodeID,
+		event.InputName,
+		event.ImageID,
+		event.GetTimestamp(),
+	)
+
+	return nil, nil
+}
+
+func (h *PropagationLatencyEventHandlers) HandleNodeGenerationFailedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeGenerationFailedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.tracker.GenerationFailed(event.NodeID)
+
+	return nil, nil
+}
+
+func (h *PropagationLatencyEventHandlers) HandleNodeRemovedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeRemovedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.tracker.NodeRemoved(event.ImageGraphID, event.NodeID)
+
+	return nil, nil
+}
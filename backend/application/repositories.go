@@ -17,11 +17,11 @@ type ImageGraphRepository interface {
 }
 
 type LayoutRepository interface {
-	Get(graphID imagegraph.ImageGraphID) (*ui.Layout, error)
+	Get(graphID imagegraph.ImageGraphID, userID string) (*ui.Layout, error)
 	Add(layout *ui.Layout) error
 }
 
 type ViewportRepository interface {
-	Get(graphID imagegraph.ImageGraphID) (*ui.Viewport, error)
+	Get(graphID imagegraph.ImageGraphID, userID string) (*ui.Viewport, error)
 	Add(viewport *ui.Viewport) error
 }
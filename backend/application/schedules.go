@@ -0,0 +1,218 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dmpettyp/artwork/cron"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// How a ScheduledRun went
+const (
+	// The graph's pipeline was started again
+	ScheduledRunStarted = "started"
+	// The graph was still regenerating, so the run was skipped rather than
+	// overlapping the one in progress
+	ScheduledRunSkipped = "skipped"
+	// The graph couldn't be regenerated
+	ScheduledRunFailed = "failed"
+)
+
+// Schedule re-runs an ImageGraph's pipeline at the times a cron expression
+// matches, in UTC, e.g. to reprocess the images of a watched folder nightly
+type Schedule struct {
+	ImageGraphID imagegraph.ImageGraphID
+	Cron         string
+	Enabled      bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// ScheduledRun records a time a Schedule was due
+type ScheduledRun struct {
+	ImageGraphID imagegraph.ImageGraphID
+	ScheduledFor time.Time
+	StartedAt    time.Time
+	Status       string
+	Error        string
+}
+
+// ScheduleStore persists the Schedules of ImageGraphs, at most one each,
+// and the history of their runs
+type ScheduleStore interface {
+	// Set adds or replaces an ImageGraph's Schedule
+	Set(ctx context.Context, schedule Schedule) error
+	Get(ctx context.Context, imageGraphID imagegraph.ImageGraphID) (Schedule, error)
+	Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID) error
+	ListEnabled(ctx context.Context) ([]Schedule, error)
+
+	AddRun(ctx context.Context, run ScheduledRun) error
+	// ListRuns retrieves the most recent runs of an ImageGraph's Schedule,
+	// newest first
+	ListRuns(ctx context.Context, imageGraphID imagegraph.ImageGraphID, limit int) ([]ScheduledRun, error)
+}
+
+// Scheduler regenerates ImageGraphs as their Schedules come due. Only one
+// process should run a Scheduler over a store.
+type Scheduler struct {
+	mb              CommandHandler
+	schedules       ScheduleStore
+	imageGraphViews ImageGraphViews
+	logger          *slog.Logger
+	interval        time.Duration
+	clock           Clock
+
+	cancel func()
+	wg     sync.WaitGroup
+}
+
+// SchedulerOption configures optional Scheduler behavior
+type SchedulerOption func(*Scheduler)
+
+// WithSchedulerClock sets the Clock the Scheduler checks Schedules against
+// and records runs as started at
+func WithSchedulerClock(clock Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// NewScheduler creates a Scheduler that regenerates ImageGraphs through the
+// provided message bus
+func NewScheduler(
+	mb CommandHandler,
+	schedules ScheduleStore,
+	imageGraphViews ImageGraphViews,
+	logger *slog.Logger,
+	opts ...SchedulerOption,
+) *Scheduler {
+	scheduler := &Scheduler{
+		mb:              mb,
+		schedules:       schedules,
+		imageGraphViews: imageGraphViews,
+		logger:          logger,
+		interval:        15 * time.Second,
+		clock:           SystemClock,
+	}
+
+	for _, opt := range opts {
+		opt(scheduler)
+	}
+
+	return scheduler
+}
+
+// Start runs the Schedules that come due from now on until Stop is called.
+// Schedules that came due while the Scheduler wasn't running are not
+// caught up on. The message bus must be running.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		last := s.clock.Now().UTC()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			now := s.clock.Now().UTC()
+			s.RunDue(ctx, last, now)
+			last = now
+		}
+	}()
+}
+
+// Stop stops the Scheduler and waits for the runs being started to finish
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.logger.Info("stopping scheduler")
+
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop scheduler: %w", ctx.Err())
+	}
+}
+
+// RunDue runs the enabled Schedules that came due after from and up to to,
+// once each however many times they came due
+func (s *Scheduler) RunDue(ctx context.Context, from time.Time, to time.Time) {
+	schedules, err := s.schedules.ListEnabled(ctx)
+	if err != nil {
+		s.logger.Error("could not list schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		parsed, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			s.logger.Error("invalid schedule", "error", err, "image_graph_id", schedule.ImageGraphID)
+			continue
+		}
+
+		due := parsed.Next(from)
+		if due.IsZero() || due.After(to) {
+			continue
+		}
+
+		run := s.run(ctx, schedule.ImageGraphID)
+		run.ScheduledFor = due
+
+		if err := s.schedules.AddRun(ctx, run); err != nil {
+			s.logger.Error("could not record scheduled run", "error", err, "image_graph_id", schedule.ImageGraphID)
+		}
+	}
+}
+
+// run regenerates an ImageGraph unless it is still regenerating
+func (s *Scheduler) run(ctx context.Context, imageGraphID imagegraph.ImageGraphID) ScheduledRun {
+	run := ScheduledRun{
+		ImageGraphID: imageGraphID,
+		StartedAt:    s.clock.Now().UTC(),
+		Status:       ScheduledRunStarted,
+	}
+
+	ig, err := s.imageGraphViews.Get(ctx, imageGraphID)
+	if err != nil {
+		run.Status = ScheduledRunFailed
+		run.Error = err.Error()
+		return run
+	}
+
+	if !ig.Settled() {
+		run.Status = ScheduledRunSkipped
+		run.Error = "previous run still in progress"
+		return run
+	}
+
+	err = s.mb.HandleCommand(ctx, NewRegenerateImageGraphCommand(imageGraphID))
+	if err != nil {
+		s.logger.Error("could not run schedule", "error", err, "image_graph_id", imageGraphID)
+		run.Status = ScheduledRunFailed
+		run.Error = err.Error()
+	}
+
+	return run
+}
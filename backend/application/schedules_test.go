@@ -0,0 +1,108 @@
+package application
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/infratest"
+	"github.com/dmpettyp/artwork/testsupport"
+)
+
+type schedules struct {
+	ScheduleStore
+	enabled []Schedule
+	runs    []ScheduledRun
+}
+
+func (s *schedules) ListEnabled(context.Context) ([]Schedule, error) {
+	return s.enabled, nil
+}
+
+func (s *schedules) AddRun(_ context.Context, run ScheduledRun) error {
+	s.runs = append(s.runs, run)
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(imagegraph.MustNewImageID()).
+		WithBlur(2).
+		ConnectAll()
+	ig := b.MustBuild(t)
+	blurID := b.NodeID("blur")
+
+	mb := messagebus.New()
+	var regenerated []imagegraph.ImageGraphID
+	err := registerCommandHandler(mb, nil, func(_ context.Context, command *RegenerateImageGraphCommand) ([]messages.Event, error) {
+		regenerated = append(regenerated, command.ImageGraphID)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register handler: %v", err)
+	}
+	busCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go mb.Start(busCtx)
+
+	from := time.Date(2026, 5, 1, 2, 59, 30, 0, time.UTC)
+	clock := infratest.NewClock(from.Add(45 * time.Second))
+
+	store := &schedules{enabled: []Schedule{{ImageGraphID: ig.ID, Cron: "0 3 * * *", Enabled: true}}}
+	scheduler := NewScheduler(
+		mb, store, graphView{ig: ig}, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithSchedulerClock(clock),
+	)
+
+	t.Run("waits until the schedule is due", func(t *testing.T) {
+		scheduler.RunDue(ctx, from, from.Add(20*time.Second))
+
+		if len(store.runs) != 0 || len(regenerated) != 0 {
+			t.Errorf("expected no runs, got %+v", store.runs)
+		}
+	})
+
+	t.Run("skips runs that would overlap one in progress", func(t *testing.T) {
+		scheduler.RunDue(ctx, from, from.Add(time.Minute))
+
+		if len(store.runs) != 1 || store.runs[0].Status != ScheduledRunSkipped {
+			t.Fatalf("expected a skipped run while the blur node generates, got %+v", store.runs)
+		}
+		if len(regenerated) != 0 {
+			t.Error("expected the graph not to regenerate")
+		}
+	})
+
+	blur, _ := ig.Nodes.Get(blurID)
+	if err := ig.SetNodeOutputImage(blurID, "blurred", imagegraph.MustNewImageID(), blur.Version); err != nil {
+		t.Fatalf("failed to set output image: %v", err)
+	}
+
+	t.Run("regenerates the graph when due", func(t *testing.T) {
+		store.runs = nil
+
+		// Due three times, but run once
+		scheduler.RunDue(ctx, from, from.Add(72*time.Hour))
+
+		if len(store.runs) != 1 || store.runs[0].Status != ScheduledRunStarted {
+			t.Fatalf("expected a started run, got %+v", store.runs)
+		}
+		if want := time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC); !store.runs[0].ScheduledFor.Equal(want) {
+			t.Errorf("expected the run scheduled for %v, got %v", want, store.runs[0].ScheduledFor)
+		}
+		if !store.runs[0].StartedAt.Equal(clock.Now()) {
+			t.Errorf("expected the run started at %v, got %v", clock.Now(), store.runs[0].StartedAt)
+		}
+		if len(regenerated) != 1 || regenerated[0] != ig.ID {
+			t.Errorf("expected the graph to regenerate once, got %v", regenerated)
+		}
+	})
+}
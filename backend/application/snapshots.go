@@ -0,0 +1,243 @@
+package application
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Snapshot records the results of an ImageGraph under a name: the config of
+// each node and the images its outputs held. Unlike the graph's own history
+// it's about results rather than topology, so they can be compared after the
+// graph changes. Snapshots never change once taken.
+type Snapshot struct {
+	ID           string
+	ImageGraphID imagegraph.ImageGraphID
+	Name         string
+	CreatedAt    time.Time
+	Nodes        []SnapshotNode
+}
+
+// SnapshotNode is a node as it was when a Snapshot was taken
+type SnapshotNode struct {
+	NodeID  imagegraph.NodeID
+	Name    string
+	Type    imagegraph.NodeType
+	Config  json.RawMessage
+	Outputs []SnapshotOutput
+}
+
+// SnapshotOutput is the image a node output held when a Snapshot was taken.
+// ImageID is the snapshot's own copy of the image, so it outlives the
+// output's history; SourceImageID is the image the output held.
+type SnapshotOutput struct {
+	Name          imagegraph.OutputName
+	ImageID       imagegraph.ImageID
+	SourceImageID imagegraph.ImageID
+}
+
+// SnapshotStore persists the Snapshots of ImageGraphs
+type SnapshotStore interface {
+	// Add stores a Snapshot, returning ErrSnapshotNameTaken if the
+	// ImageGraph already has a Snapshot with its name
+	Add(ctx context.Context, snapshot Snapshot) error
+	Get(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) (Snapshot, error)
+	// ListByImageGraph retrieves the Snapshots of an ImageGraph, oldest
+	// first
+	ListByImageGraph(ctx context.Context, imageGraphID imagegraph.ImageGraphID) ([]Snapshot, error)
+}
+
+// NewSnapshot captures the nodes of an ImageGraph, sorted by name. Its
+// outputs refer to the graph's images; they're replaced by copies before
+// the Snapshot is stored.
+func NewSnapshot(
+	ig *imagegraph.ImageGraph,
+	id string,
+	name string,
+	createdAt time.Time,
+) (
+	Snapshot,
+	error,
+) {
+	snapshot := Snapshot{
+		ID:           id,
+		ImageGraphID: ig.ID,
+		Name:         name,
+		CreatedAt:    createdAt,
+		Nodes:        make([]SnapshotNode, 0, len(ig.Nodes)),
+	}
+
+	for _, node := range ig.Nodes {
+		config, err := json.Marshal(node.Config)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("could not snapshot config of node %q: %w", node.ID, err)
+		}
+
+		snapshotNode := SnapshotNode{
+			NodeID: node.ID,
+			Name:   node.Name,
+			Type:   node.Type,
+			Config: config,
+		}
+
+		for _, outputName := range slices.Sorted(maps.Keys(node.Outputs)) {
+			imageID := node.Outputs[outputName].ImageID
+			snapshotNode.Outputs = append(snapshotNode.Outputs, SnapshotOutput{
+				Name:          outputName,
+				ImageID:       imageID,
+				SourceImageID: imageID,
+			})
+		}
+
+		snapshot.Nodes = append(snapshot.Nodes, snapshotNode)
+	}
+
+	slices.SortFunc(snapshot.Nodes, func(a, b SnapshotNode) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.NodeID.String(), b.NodeID.String()))
+	})
+
+	return snapshot, nil
+}
+
+// ImageCount is the number of output images in the Snapshot
+func (s Snapshot) ImageCount() int {
+	count := 0
+	for _, node := range s.Nodes {
+		for _, output := range node.Outputs {
+			if !output.ImageID.IsNil() {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Kinds of change between the nodes of two Snapshots
+const (
+	SnapshotNodeAdded     = "added"
+	SnapshotNodeRemoved   = "removed"
+	SnapshotNodeChanged   = "changed"
+	SnapshotNodeUnchanged = "unchanged"
+)
+
+// SnapshotNodeChange compares a node between two Snapshots. From or To is
+// nil when the node is only in one of them.
+type SnapshotNodeChange struct {
+	NodeID        imagegraph.NodeID
+	Change        string
+	From          *SnapshotNode
+	To            *SnapshotNode
+	ConfigChanged bool
+	Outputs       []SnapshotOutputChange
+}
+
+// SnapshotOutputChange compares the image of a node output between two
+// Snapshots. The images are the ones to look at, the Snapshots' copies.
+type SnapshotOutputChange struct {
+	Name        imagegraph.OutputName
+	FromImageID imagegraph.ImageID
+	ToImageID   imagegraph.ImageID
+	Changed     bool
+}
+
+// CompareSnapshots lists how the nodes of to differ from those of from, in
+// to's order followed by the nodes that were removed
+func CompareSnapshots(from, to Snapshot) []SnapshotNodeChange {
+	fromNodes := make(map[imagegraph.NodeID]*SnapshotNode, len(from.Nodes))
+	for i := range from.Nodes {
+		fromNodes[from.Nodes[i].NodeID] = &from.Nodes[i]
+	}
+
+	changes := make([]SnapshotNodeChange, 0, len(to.Nodes))
+
+	for i := range to.Nodes {
+		toNode := &to.Nodes[i]
+		fromNode, ok := fromNodes[toNode.NodeID]
+
+		if !ok {
+			changes = append(changes, SnapshotNodeChange{
+				NodeID:  toNode.NodeID,
+				Change:  SnapshotNodeAdded,
+				To:      toNode,
+				Outputs: compareSnapshotOutputs(nil, toNode.Outputs),
+			})
+			continue
+		}
+
+		delete(fromNodes, toNode.NodeID)
+
+		change := SnapshotNodeChange{
+			NodeID:        toNode.NodeID,
+			Change:        SnapshotNodeUnchanged,
+			From:          fromNode,
+			To:            toNode,
+			ConfigChanged: fromNode.Type != toNode.Type || !bytes.Equal(fromNode.Config, toNode.Config),
+			Outputs:       compareSnapshotOutputs(fromNode.Outputs, toNode.Outputs),
+		}
+
+		if change.ConfigChanged || fromNode.Name != toNode.Name ||
+			slices.ContainsFunc(change.Outputs, func(o SnapshotOutputChange) bool { return o.Changed }) {
+			change.Change = SnapshotNodeChanged
+		}
+
+		changes = append(changes, change)
+	}
+
+	for i := range from.Nodes {
+		fromNode := &from.Nodes[i]
+		if _, removed := fromNodes[fromNode.NodeID]; !removed {
+			continue
+		}
+		changes = append(changes, SnapshotNodeChange{
+			NodeID:  fromNode.NodeID,
+			Change:  SnapshotNodeRemoved,
+			From:    fromNode,
+			Outputs: compareSnapshotOutputs(fromNode.Outputs, nil),
+		})
+	}
+
+	return changes
+}
+
+// compareSnapshotOutputs pairs the outputs of a node in two Snapshots by
+// name. Outputs count as changed when they came from different images.
+func compareSnapshotOutputs(from, to []SnapshotOutput) []SnapshotOutputChange {
+	var changes []SnapshotOutputChange
+
+	fromOutputs := make(map[imagegraph.OutputName]SnapshotOutput, len(from))
+	for _, output := range from {
+		fromOutputs[output.Name] = output
+	}
+
+	for _, toOutput := range to {
+		fromOutput, ok := fromOutputs[toOutput.Name]
+		delete(fromOutputs, toOutput.Name)
+
+		changes = append(changes, SnapshotOutputChange{
+			Name:        toOutput.Name,
+			FromImageID: fromOutput.ImageID,
+			ToImageID:   toOutput.ImageID,
+			Changed:     !ok || fromOutput.SourceImageID != toOutput.SourceImageID,
+		})
+	}
+
+	for _, fromOutput := range from {
+		if _, removed := fromOutputs[fromOutput.Name]; !removed {
+			continue
+		}
+		changes = append(changes, SnapshotOutputChange{
+			Name:        fromOutput.Name,
+			FromImageID: fromOutput.ImageID,
+			Changed:     true,
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,56 @@
+package application
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+func TestCompareSnapshots(t *testing.T) {
+	kept, edited, removed, added := imagegraph.MustNewNodeID(), imagegraph.MustNewNodeID(), imagegraph.MustNewNodeID(), imagegraph.MustNewNodeID()
+	keptImage, oldImage, newImage := imagegraph.MustNewImageID(), imagegraph.MustNewImageID(), imagegraph.MustNewImageID()
+
+	output := func(sourceImageID imagegraph.ImageID) []SnapshotOutput {
+		// Each snapshot has its own copy of the images
+		return []SnapshotOutput{{Name: "final", ImageID: imagegraph.MustNewImageID(), SourceImageID: sourceImageID}}
+	}
+
+	from := Snapshot{Nodes: []SnapshotNode{
+		{NodeID: kept, Name: "kept", Config: json.RawMessage(`{}`), Outputs: output(keptImage)},
+		{NodeID: edited, Name: "edited", Config: json.RawMessage(`{"radius":1}`), Outputs: output(oldImage)},
+		{NodeID: removed, Name: "removed", Config: json.RawMessage(`{}`)},
+	}}
+	to := Snapshot{Nodes: []SnapshotNode{
+		{NodeID: added, Name: "added", Config: json.RawMessage(`{}`)},
+		{NodeID: edited, Name: "edited", Config: json.RawMessage(`{"radius":2}`), Outputs: output(newImage)},
+		{NodeID: kept, Name: "kept", Config: json.RawMessage(`{}`), Outputs: output(keptImage)},
+	}}
+
+	changes := CompareSnapshots(from, to)
+
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes, got %d", len(changes))
+	}
+
+	for i, expected := range []struct {
+		nodeID imagegraph.NodeID
+		change string
+	}{
+		{added, SnapshotNodeAdded},
+		{edited, SnapshotNodeChanged},
+		{kept, SnapshotNodeUnchanged},
+		{removed, SnapshotNodeRemoved},
+	} {
+		if changes[i].NodeID != expected.nodeID || changes[i].Change != expected.change {
+			t.Errorf("expected change %d to be %s, got %s", i, expected.change, changes[i].Change)
+		}
+	}
+
+	if !changes[1].ConfigChanged || len(changes[1].Outputs) != 1 || !changes[1].Outputs[0].Changed {
+		t.Errorf("expected the edited node's config and output to change, got %+v", changes[1])
+	}
+	if changes[2].Outputs[0].Changed {
+		t.Error("expected copies of the same image not to count as a change")
+	}
+}
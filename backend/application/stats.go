@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GraphStats counts what the stored ImageGraphs hold, for capacity
+// monitoring
+type GraphStats struct {
+	ImageGraphs int
+	NodesByType map[imagegraph.NodeType]int
+
+	// The images the ImageGraphs and their Snapshots refer to
+	ReferencedImages map[imagegraph.ImageID]bool
+
+	// How many committed events are waiting to be published
+	QueueDepth int
+}
+
+// NewGraphStats creates empty GraphStats for a StatsCollector to add to
+func NewGraphStats() GraphStats {
+	return GraphStats{
+		NodesByType:      make(map[imagegraph.NodeType]int),
+		ReferencedImages: make(map[imagegraph.ImageID]bool),
+	}
+}
+
+// AddImageGraph counts an ImageGraph, its nodes and the images it refers to
+func (s *GraphStats) AddImageGraph(ig *imagegraph.ImageGraph) {
+	s.ImageGraphs++
+
+	for _, node := range ig.Nodes {
+		s.NodesByType[node.Type]++
+	}
+
+	for _, imageID := range ig.ImageIDs() {
+		s.ReferencedImages[imageID] = true
+	}
+}
+
+// AddSnapshot counts the images a Snapshot keeps copies of
+func (s *GraphStats) AddSnapshot(snapshot Snapshot) {
+	for _, node := range snapshot.Nodes {
+		for _, output := range node.Outputs {
+			if !output.ImageID.IsNil() {
+				s.ReferencedImages[output.ImageID] = true
+			}
+		}
+	}
+}
+
+// StatsCollector computes GraphStats over every stored ImageGraph. Each
+// storage backend implements it.
+type StatsCollector interface {
+	CollectGraphStats(ctx context.Context) (GraphStats, error)
+}
+
+// Stats reports the stored ImageGraphs and images
+type Stats struct {
+	GraphStats
+
+	StoredImages     int
+	StoredImageBytes int64
+
+	// Stored images no ImageGraph or Snapshot refers to. Images saved by
+	// generation that hasn't set them on their node yet are counted too.
+	OrphanedImages     int
+	OrphanedImageBytes int64
+}
+
+// CollectStats combines the collector's GraphStats with the sizes of the
+// stored images, listed before the graphs are so that images referenced by
+// the time the graphs are read aren't reported as orphaned
+func CollectStats(
+	ctx context.Context,
+	collector StatsCollector,
+	storedImages map[imagegraph.ImageID]int64,
+) (
+	Stats,
+	error,
+) {
+	graphStats, err := collector.CollectGraphStats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{GraphStats: graphStats}
+
+	for imageID, size := range storedImages {
+		stats.StoredImages++
+		stats.StoredImageBytes += size
+
+		if !graphStats.ReferencedImages[imageID] {
+			stats.OrphanedImages++
+			stats.OrphanedImageBytes += size
+		}
+	}
+
+	return stats, nil
+}
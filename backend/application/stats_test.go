@@ -0,0 +1,40 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+type fakeStatsCollector GraphStats
+
+func (c fakeStatsCollector) CollectGraphStats(context.Context) (GraphStats, error) {
+	return GraphStats(c), nil
+}
+
+func TestCollectStats(t *testing.T) {
+	outputImage, snapshotImage, orphanImage := imagegraph.MustNewImageID(), imagegraph.MustNewImageID(), imagegraph.MustNewImageID()
+
+	graphStats := NewGraphStats()
+	graphStats.ReferencedImages[outputImage] = true
+	graphStats.AddSnapshot(Snapshot{Nodes: []SnapshotNode{
+		{Outputs: []SnapshotOutput{{Name: "final", ImageID: snapshotImage, SourceImageID: outputImage}}},
+	}})
+
+	stats, err := CollectStats(context.Background(), fakeStatsCollector(graphStats), map[imagegraph.ImageID]int64{
+		outputImage:   100,
+		snapshotImage: 20,
+		orphanImage:   3,
+	})
+	if err != nil {
+		t.Fatalf("failed to collect stats: %v", err)
+	}
+
+	if stats.StoredImages != 3 || stats.StoredImageBytes != 123 {
+		t.Errorf("expected 3 images of 123 bytes, got %d of %d", stats.StoredImages, stats.StoredImageBytes)
+	}
+	if stats.OrphanedImages != 1 || stats.OrphanedImageBytes != 3 {
+		t.Errorf("expected the one unreferenced image orphaned, got %d of %d bytes", stats.OrphanedImages, stats.OrphanedImageBytes)
+	}
+}
@@ -0,0 +1,117 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dmpettyp/artwork/tracing"
+)
+
+var tracer = otel.Tracer("github.com/dmpettyp/artwork/application")
+
+// registerCommandHandler registers a command handler with the message bus,
+// tracing each command it handles in a span named after the handler. The
+// span is a child of the span in the context the command was sent with, and
+// the parent of the spans of the events the command emits. The events are
+// counted in the GraphQueues, if any, as they queue.
+func registerCommandHandler[C messages.Command](
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	handler func(context.Context, C) ([]messages.Event, error),
+) error {
+	name := handlerName(handler)
+
+	return messagebus.RegisterCommandHandler(mb, func(ctx context.Context, command C) ([]messages.Event, error) {
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(
+			attribute.String("artwork.command", command.GetType()),
+		))
+
+		events, err := handler(ctx, command)
+		tracing.End(span, err)
+
+		// The bus only dispatches the events of commands that succeed
+		if err == nil {
+			queues.queueEvents(events)
+		}
+
+		return events, err
+	})
+}
+
+// registerEventHandler registers an event handler with the message bus,
+// tracing each event it handles in a span named after the handler. Commands
+// the handler sends wait for room in full queues rather than fail.
+func registerEventHandler[E messages.Event](
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	handler func(context.Context, E) ([]messages.Event, error),
+) error {
+	return registerNamedEventHandler(mb, queues, deadLetters, handlerName(handler), handler)
+}
+
+// registerNamedEventHandler registers an event handler like
+// registerEventHandler, naming its spans for handlers that wrap another. A
+// handler that keeps failing is dead-lettered under the same name by the
+// DeadLetterQueue, if any. The GraphQueues, if any, count the event as
+// dispatched and the events the handler returns as queued.
+func registerNamedEventHandler[E messages.Event](
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	name string,
+	handler func(context.Context, E) ([]messages.Event, error),
+) error {
+	handle := deadLettered(deadLetters, name, func(ctx context.Context, event E) ([]messages.Event, error) {
+		ctx = waitWhenBusy(ctx)
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(
+			attribute.String("artwork.event", event.GetType()),
+			attribute.String("artwork.entity_type", event.GetEntityType()),
+			attribute.String("artwork.entity_id", event.GetEntityID().String()),
+		))
+
+		events, err := handler(ctx, event)
+		tracing.End(span, err)
+
+		return events, err
+	})
+
+	err := messagebus.RegisterEventHandler(mb, func(ctx context.Context, event E) ([]messages.Event, error) {
+		queues.dequeueEvent(event)
+		events, err := handle(ctx, event)
+		queues.queueEvents(events)
+
+		return events, err
+	})
+	if err != nil {
+		return err
+	}
+
+	queues.countEventHandler(reflect.TypeFor[E]())
+
+	return nil
+}
+
+// handlerName names a handler method after its type and method, e.g.
+// "ImageGraphEventHandlers.HandleNodeAddedEvent"
+func handlerName(handler any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if fn == nil {
+		return "handler"
+	}
+
+	name := fn.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimPrefix(name, "application.")
+	name = strings.TrimSuffix(name, "-fm")
+
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
@@ -0,0 +1,77 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// User is an authenticated user of the service
+type User struct {
+	ID   string
+	Name string
+
+	// Admins have the owner role on every ImageGraph, whoever owns it
+	Admin bool
+
+	// The API key the user was authenticated with, nil for interactive
+	// sessions
+	APIKey *APIKey
+}
+
+// HasScope reports whether the user's credentials allow an APIKeyScope.
+// Interactive sessions have every scope.
+func (u User) HasScope(scope APIKeyScope) bool {
+	return u.APIKey == nil || u.APIKey.HasScope(scope)
+}
+
+// Role returns the user's role on an ImageGraph: owner for the ImageGraphs
+// they own, the granted role for ImageGraphs shared with them, and owner for
+// every ImageGraph if they're an admin
+func (u User) Role(ig *imagegraph.ImageGraph) imagegraph.Role {
+	if u.Admin {
+		return imagegraph.RoleOwner
+	}
+	return ig.RoleOf(u.ID)
+}
+
+// SummaryRole returns the role the user has on a listed ImageGraph
+func (u User) SummaryRole(s ImageGraphSummary) imagegraph.Role {
+	if u.Admin {
+		return imagegraph.RoleOwner
+	}
+	return s.RoleOf(u.ID)
+}
+
+// CanAccess reports whether the user may view an ImageGraph
+func (u User) CanAccess(ig *imagegraph.ImageGraph) bool {
+	return u.Role(ig) >= imagegraph.RoleViewer
+}
+
+type userContextKey struct{}
+
+// ContextWithUser returns a copy of the context carrying the user a request
+// was authenticated as
+func ContextWithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+// UserFromContext returns the user a request was authenticated as, and false
+// when authentication is disabled
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(User)
+	return user, ok
+}
+
+// authorize checks that the user a command was issued by has at least the
+// given role on an ImageGraph. Commands issued without a user, by the
+// service itself or with authentication disabled, are always authorized.
+func authorize(ctx context.Context, ig *imagegraph.ImageGraph, role imagegraph.Role) error {
+	user, ok := UserFromContext(ctx)
+	if !ok || user.Role(ig) >= role {
+		return nil
+	}
+
+	return fmt.Errorf("user %q can't change ImageGraph %q: %w", user.ID, ig.ID, ErrPermissionDenied)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/domain/ui"
 	"github.com/dmpettyp/dorky/messagebus"
 	"github.com/dmpettyp/dorky/messages"
@@ -19,6 +20,7 @@ type ViewportCommandHandlers struct {
 // message bus
 func NewViewportCommandHandlers(
 	mb *messagebus.MessageBus,
+	queues *GraphQueues,
 	uow UnitOfWork,
 ) (
 	*ViewportCommandHandlers,
@@ -26,7 +28,11 @@ func NewViewportCommandHandlers(
 ) {
 	handlers := &ViewportCommandHandlers{uow: uow}
 
-	err := messagebus.RegisterCommandHandler(mb, handlers.HandleUpdateViewportCommand)
+	err := errors.Join(
+		registerCommandHandler(mb, queues, handlers.HandleUpdateViewportCommand),
+		registerCommandHandler(mb, queues, handlers.HandleSaveViewportBookmarkCommand),
+		registerCommandHandler(mb, queues, handlers.HandleDeleteViewportBookmarkCommand),
+	)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create viewport command handlers: %w", err)
@@ -43,23 +49,9 @@ func (h *ViewportCommandHandlers) HandleUpdateViewportCommand(
 	error,
 ) {
 	return h.uow.Run(ctx, func(repos *Repos) error {
-		// Try to get existing viewport, or create and add new if it doesn't exist
-		viewport, err := repos.ViewportRepository.Get(command.GraphID)
-
+		viewport, err := getOrAddViewport(repos, command.GraphID, command.UserID)
 		if err != nil {
-			if !errors.Is(err, ErrViewportNotFound) {
-				return fmt.Errorf("could not get Viewport for ImageGraph %q: %w", command.GraphID, err)
-			}
-
-			viewport, err = ui.NewViewport(command.GraphID)
-			if err != nil {
-				return fmt.Errorf("could not create Viewport for ImageGraph %q: %w", command.GraphID, err)
-			}
-
-			err = repos.ViewportRepository.Add(viewport)
-			if err != nil {
-				return fmt.Errorf("could not add Viewport for ImageGraph %q: %w", command.GraphID, err)
-			}
+			return err
 		}
 
 		// Update viewport using domain method (emits event internally)
@@ -71,3 +63,79 @@ func (h *ViewportCommandHandlers) HandleUpdateViewportCommand(
 		return nil
 	})
 }
+
+func (h *ViewportCommandHandlers) HandleSaveViewportBookmarkCommand(
+	ctx context.Context,
+	command *SaveViewportBookmarkCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		viewport, err := getOrAddViewport(repos, command.GraphID, command.UserID)
+		if err != nil {
+			return err
+		}
+
+		err = viewport.SaveBookmark(command.Bookmark)
+		if err != nil {
+			return fmt.Errorf("could not save bookmark %q for ImageGraph %q: %w", command.Bookmark.Name, command.GraphID, err)
+		}
+
+		return nil
+	})
+}
+
+func (h *ViewportCommandHandlers) HandleDeleteViewportBookmarkCommand(
+	ctx context.Context,
+	command *DeleteViewportBookmarkCommand,
+) (
+	[]messages.Event,
+	error,
+) {
+	return h.uow.Run(ctx, func(repos *Repos) error {
+		viewport, err := repos.ViewportRepository.Get(command.GraphID, command.UserID)
+		if errors.Is(err, ErrViewportNotFound) {
+			return fmt.Errorf("could not delete bookmark %q for ImageGraph %q: %w", command.Name, command.GraphID, ErrViewportBookmarkNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("could not get Viewport for ImageGraph %q: %w", command.GraphID, err)
+		}
+
+		if _, ok := viewport.Bookmark(command.Name); !ok {
+			return fmt.Errorf("could not delete bookmark %q for ImageGraph %q: %w", command.Name, command.GraphID, ErrViewportBookmarkNotFound)
+		}
+
+		err = viewport.DeleteBookmark(command.Name)
+		if err != nil {
+			return fmt.Errorf("could not delete bookmark %q for ImageGraph %q: %w", command.Name, command.GraphID, err)
+		}
+
+		return nil
+	})
+}
+
+// getOrAddViewport gets the user's Viewport of the ImageGraph, adding a
+// default one if they don't have one yet
+func getOrAddViewport(repos *Repos, graphID imagegraph.ImageGraphID, userID string) (*ui.Viewport, error) {
+	viewport, err := repos.ViewportRepository.Get(graphID, userID)
+	if err == nil {
+		return viewport, nil
+	}
+
+	if !errors.Is(err, ErrViewportNotFound) {
+		return nil, fmt.Errorf("could not get Viewport for ImageGraph %q: %w", graphID, err)
+	}
+
+	viewport, err = ui.NewViewport(graphID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create Viewport for ImageGraph %q: %w", graphID, err)
+	}
+
+	err = repos.ViewportRepository.Add(viewport)
+	if err != nil {
+		return nil, fmt.Errorf("could not add Viewport for ImageGraph %q: %w", graphID, err)
+	}
+
+	return viewport, nil
+}
@@ -2,11 +2,99 @@ package application
 
 import (
 	"context"
+	"time"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/domain/ui"
 )
 
+// ImageGraphSort is the field a list of ImageGraphs is ordered by
+type ImageGraphSort string
+
+const (
+	SortImageGraphsByName    ImageGraphSort = "name"
+	SortImageGraphsByCreated ImageGraphSort = "created"
+	SortImageGraphsByUpdated ImageGraphSort = "updated"
+)
+
+// ListImageGraphsOptions filters, orders and pages a list of ImageGraphs
+type ListImageGraphsOptions struct {
+	// Tag lists only the ImageGraphs labelled with the normalized tag, when
+	// set
+	Tag string
+
+	// AccessibleTo lists only the ImageGraphs the user ID owns or that are
+	// shared with it, when set
+	AccessibleTo string
+
+	Sort       ImageGraphSort
+	Descending bool
+
+	// Limit is the most ImageGraphs to return, or 0 for all of them
+	Limit  int
+	Offset int
+}
+
+// ImageGraphSummary is the read model ImageGraphs are listed with. It's a
+// projection of the ImageGraph kept up to date by event handlers, so lists
+// don't need to load every ImageGraph's nodes.
+type ImageGraphSummary struct {
+	ID      imagegraph.ImageGraphID
+	Version imagegraph.ImageGraphVersion
+	Name    string
+	Owner   string
+	Public  bool
+	Tags    imagegraph.Tags
+	Shares  imagegraph.Shares
+
+	NodeCount int
+
+	// ThumbnailImageID is nil until an Output node has generated its final
+	// image
+	ThumbnailImageID imagegraph.ImageID
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewImageGraphSummary projects an ImageGraph into its summary
+func NewImageGraphSummary(ig *imagegraph.ImageGraph) ImageGraphSummary {
+	return ImageGraphSummary{
+		ID:               ig.ID,
+		Version:          ig.Version,
+		Name:             ig.Name,
+		Owner:            ig.Owner,
+		Public:           ig.Public,
+		Tags:             ig.Tags,
+		Shares:           ig.Shares,
+		NodeCount:        len(ig.Nodes),
+		ThumbnailImageID: ig.Thumbnail(),
+		CreatedAt:        ig.CreatedAt,
+		UpdatedAt:        ig.UpdatedAt,
+	}
+}
+
+// RoleOf returns the role a user has on the summarized ImageGraph
+func (s ImageGraphSummary) RoleOf(userID string) imagegraph.Role {
+	return s.Shares.RoleOf(s.Owner, userID)
+}
+
+// ImageGraphSummaryStore persists the ImageGraphSummary projections that
+// lists of ImageGraphs are read from
+type ImageGraphSummaryStore interface {
+	// Put stores a summary, unless a summary of a later version of the
+	// ImageGraph is already stored
+	Put(ctx context.Context, summary ImageGraphSummary) error
+}
+
+// ImageGraphPage is a page of a list of ImageGraphs
+type ImageGraphPage struct {
+	ImageGraphs []ImageGraphSummary
+
+	// Total counts the ImageGraphs on all pages of the list
+	Total int
+}
+
 type ImageGraphViews interface {
 	Get(
 		ctx context.Context,
@@ -16,26 +104,61 @@ type ImageGraphViews interface {
 		error,
 	)
 
-	List(ctx context.Context) (
+	GetByExternalID(
+		ctx context.Context,
+		externalID string,
+	) (
+		*imagegraph.ImageGraph,
+		error,
+	)
+
+	// List returns a page of the ImageGraphs selected by the options. Ties
+	// in the sort order are broken by ID so that pages are stable.
+	List(
+		ctx context.Context,
+		opts ListImageGraphsOptions,
+	) (
+		*ImageGraphPage,
+		error,
+	)
+
+	ListPublic(ctx context.Context) (
+		[]*imagegraph.ImageGraph,
+		error,
+	)
+
+	// Search returns the ImageGraphs with a name or tag, or a node name or
+	// tag, containing the normalized query. Implementations may return extra
+	// candidates; ImageGraph.Search decides what matched.
+	Search(
+		ctx context.Context,
+		query string,
+	) (
 		[]*imagegraph.ImageGraph,
 		error,
 	)
 }
 
+// LayoutViews reads the layout each user saved for an ImageGraph. The
+// empty user ID is the layout shared by everyone.
 type LayoutViews interface {
 	Get(
 		ctx context.Context,
 		graphID imagegraph.ImageGraphID,
+		userID string,
 	) (
 		*ui.Layout,
 		error,
 	)
 }
 
+// ViewportViews reads the viewport each user saved for an ImageGraph. The
+// empty user ID is the viewport shared by everyone.
 type ViewportViews interface {
 	Get(
 		ctx context.Context,
 		graphID imagegraph.ImageGraphID,
+		userID string,
 	) (
 		*ui.Viewport,
 		error,
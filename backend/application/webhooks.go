@@ -0,0 +1,267 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Webhook is an endpoint notified each time an ImageGraph's pipeline
+// completes. Notifications are signed with the webhook's secret so the
+// endpoint can verify where they came from.
+type Webhook struct {
+	ID           string
+	ImageGraphID imagegraph.ImageGraphID
+	URL          string
+	Secret       string
+	CreatedAt    time.Time
+}
+
+// WebhookStore persists the Webhooks registered on ImageGraphs
+type WebhookStore interface {
+	Add(ctx context.Context, webhook Webhook) error
+	ListByImageGraph(ctx context.Context, imageGraphID imagegraph.ImageGraphID) ([]Webhook, error)
+	Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) error
+}
+
+// PipelineCompletion describes an ImageGraph whose Output nodes have all
+// generated their final images
+type PipelineCompletion struct {
+	ImageGraphID imagegraph.ImageGraphID
+	CompletedAt  time.Time
+
+	// Duration is how long the pipeline ran, from the first node that
+	// needed outputs to the last Output node generating, or 0 when the start
+	// wasn't seen
+	Duration time.Duration
+
+	Outputs []CompletedOutput
+}
+
+// CompletedOutput is the final image of an Output node in a completed
+// pipeline
+type CompletedOutput struct {
+	Name    string
+	NodeID  imagegraph.NodeID
+	ImageID imagegraph.ImageID
+
+	// Duration is how long after the pipeline started the output generated
+	Duration time.Duration
+}
+
+// WebhookDispatcher delivers pipeline completions to webhooks. Dispatch must
+// not block on delivery.
+type WebhookDispatcher interface {
+	Dispatch(webhooks []Webhook, completion PipelineCompletion)
+}
+
+// pipelineRun tracks an ImageGraph's pipeline between completions
+type pipelineRun struct {
+	running   bool
+	startedAt time.Time
+
+	// notified identifies the exports of the last completion dispatched, so
+	// the same completion isn't dispatched twice
+	notified string
+}
+
+// WebhookEventHandlers watches ImageGraph events for pipelines completing
+// and dispatches the completions to the ImageGraph's webhooks
+type WebhookEventHandlers struct {
+	webhooks        WebhookStore
+	imageGraphViews ImageGraphViews
+	dispatcher      WebhookDispatcher
+	clock           Clock
+
+	mu   sync.Mutex
+	runs map[imagegraph.ImageGraphID]*pipelineRun
+}
+
+// WebhookEventHandlersOption configures optional WebhookEventHandlers
+// behavior
+type WebhookEventHandlersOption func(*WebhookEventHandlers)
+
+// WithWebhookClock sets the Clock pipelines are timed with
+func WithWebhookClock(clock Clock) WebhookEventHandlersOption {
+	return func(h *WebhookEventHandlers) {
+		h.clock = clock
+	}
+}
+
+// NewWebhookEventHandlers initializes the handlers struct that dispatches
+// pipeline completions to webhooks and registers all handlers with the
+// provided message bus
+func NewWebhookEventHandlers(
+	mb *messagebus.MessageBus,
+	queues *GraphQueues,
+	deadLetters *DeadLetterQueue,
+	webhooks WebhookStore,
+	imageGraphViews ImageGraphViews,
+	dispatcher WebhookDispatcher,
+	opts ...WebhookEventHandlersOption,
+) (
+	*WebhookEventHandlers,
+	error,
+) {
+	handlers := &WebhookEventHandlers{
+		webhooks:        webhooks,
+		imageGraphViews: imageGraphViews,
+		dispatcher:      dispatcher,
+		clock:           SystemClock,
+		runs:            make(map[imagegraph.ImageGraphID]*pipelineRun),
+	}
+
+	for _, opt := range opts {
+		opt(handlers)
+	}
+
+	err := errors.Join(
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeNeedsOutputsEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeOutputImageSetEvent),
+		registerEventHandler(mb, queues, deadLetters, handlers.HandleNodeGenerationFailedEvent),
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create webhook event handlers: %w", err)
+	}
+
+	return handlers, nil
+}
+
+// HandleNodeNeedsOutputsEvent starts timing the pipeline when the first of
+// its nodes needs outputs
+func (h *WebhookEventHandlers) HandleNodeNeedsOutputsEvent(
+	ctx context.Context,
+	event *imagegraph.NodeNeedsOutputsEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	run := h.run(event.ImageGraphID)
+	if !run.running {
+		run.running = true
+		run.startedAt = h.clock.Now()
+	}
+
+	return nil, nil
+}
+
+// HandleNodeOutputImageSetEvent checks whether the image completed the
+// pipeline
+func (h *WebhookEventHandlers) HandleNodeOutputImageSetEvent(
+	ctx context.Context,
+	event *imagegraph.NodeOutputImageSetEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.checkCompletion(ctx, event.ImageGraphID)
+}
+
+// HandleNodeGenerationFailedEvent checks whether the pipeline completed,
+// since a failing node that no Output node depends on may be the last to
+// finish
+func (h *WebhookEventHandlers) HandleNodeGenerationFailedEvent(
+	ctx context.Context,
+	event *imagegraph.NodeGenerationFailedEvent,
+) (
+	[]messages.Event,
+	error,
+) {
+	return nil, h.checkCompletion(ctx, event.ImageGraphID)
+}
+
+// checkCompletion dispatches the ImageGraph's completion to its webhooks if
+// its pipeline has completed since the last completion was dispatched
+func (h *WebhookEventHandlers) checkCompletion(ctx context.Context, imageGraphID imagegraph.ImageGraphID) error {
+	ig, err := h.imageGraphViews.Get(ctx, imageGraphID)
+	if err != nil {
+		return fmt.Errorf("could not check pipeline completion of ImageGraph %q: %w", imageGraphID, err)
+	}
+
+	if !ig.PipelineComplete() {
+		return nil
+	}
+
+	exports := ig.Exports()
+	signature := exportsSignature(exports)
+	completedAt := h.clock.Now()
+
+	h.mu.Lock()
+	run := h.run(imageGraphID)
+	if run.notified == signature {
+		h.mu.Unlock()
+		return nil
+	}
+	run.notified = signature
+	running, startedAt := run.running, run.startedAt
+	run.running = false
+	h.mu.Unlock()
+
+	webhooks, err := h.webhooks.ListByImageGraph(ctx, imageGraphID)
+	if err != nil {
+		return fmt.Errorf("could not list webhooks of ImageGraph %q: %w", imageGraphID, err)
+	}
+
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	completion := PipelineCompletion{
+		ImageGraphID: imageGraphID,
+		CompletedAt:  completedAt.UTC(),
+		Outputs:      make([]CompletedOutput, 0, len(exports)),
+	}
+
+	if running {
+		completion.Duration = completedAt.Sub(startedAt)
+	}
+
+	for _, export := range exports {
+		output := CompletedOutput{
+			Name:    export.Name,
+			NodeID:  export.NodeID,
+			ImageID: export.ImageID,
+		}
+		if running && export.GeneratedAt.After(startedAt) {
+			output.Duration = export.GeneratedAt.Sub(startedAt)
+		}
+		completion.Outputs = append(completion.Outputs, output)
+	}
+
+	h.dispatcher.Dispatch(webhooks, completion)
+
+	return nil
+}
+
+// run returns the ImageGraph's pipeline run. The caller must hold h.mu.
+func (h *WebhookEventHandlers) run(imageGraphID imagegraph.ImageGraphID) *pipelineRun {
+	run, ok := h.runs[imageGraphID]
+	if !ok {
+		run = &pipelineRun{}
+		h.runs[imageGraphID] = run
+	}
+	return run
+}
+
+// exportsSignature identifies a set of exports by their names and images
+func exportsSignature(exports []imagegraph.Export) string {
+	parts := make([]string, len(exports))
+	for i, export := range exports {
+		parts[i] = export.Name + "=" + export.ImageID.String()
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,427 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListImageGraphs lists the image graphs the caller can access
+func (c *Client) ListImageGraphs(ctx context.Context, opts ListImageGraphsOptions) (*ImageGraphList, error) {
+	query := url.Values{}
+	if opts.Tag != "" {
+		query.Set("tag", opts.Tag)
+	}
+	if opts.Sort != "" {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Order != "" {
+		query.Set("order", opts.Order)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+
+	p := path("imagegraphs")
+	if len(query) > 0 {
+		p += "?" + query.Encode()
+	}
+
+	var list ImageGraphList
+	if err := c.doJSON(ctx, http.MethodGet, p, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateImageGraph creates an image graph, returning its ID
+func (c *Client) CreateImageGraph(ctx context.Context, graph NewImageGraph) (string, error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs"), graph, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// GetImageGraph gets an image graph and its nodes
+func (c *Client) GetImageGraph(ctx context.Context, graphID string) (*ImageGraph, error) {
+	var graph ImageGraph
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID), nil, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+// GetImageGraphSummary gets an image graph whose nodes carry only their ID,
+// name, type, state and preview, which is much smaller for large graphs
+func (c *Client) GetImageGraphSummary(ctx context.Context, graphID string) (*ImageGraph, error) {
+	var graph ImageGraph
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID)+"?view=summary", nil, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+// GetFullImageGraph gets an image graph along with its layout, viewport and
+// the node type schemas in one request
+func (c *Client) GetFullImageGraph(ctx context.Context, graphID string) (*FullImageGraph, error) {
+	var full FullImageGraph
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "full"), nil, &full); err != nil {
+		return nil, err
+	}
+	return &full, nil
+}
+
+// ValidateImageGraph checks the kinds and sizes of the images carried by
+// every connection of a graph
+func (c *Client) ValidateImageGraph(ctx context.Context, graphID string) (*GraphValidation, error) {
+	var validation GraphValidation
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "validate"), nil, &validation); err != nil {
+		return nil, err
+	}
+	return &validation, nil
+}
+
+// GetDiagnostics reports the problems that keep a graph from being complete
+func (c *Client) GetDiagnostics(ctx context.Context, graphID string) (*Diagnostics, error) {
+	var diagnostics Diagnostics
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "diagnostics"), nil, &diagnostics); err != nil {
+		return nil, err
+	}
+	return &diagnostics, nil
+}
+
+// EstimateImageGraph estimates the output sizes, memory and time of
+// regenerating every node of a graph from the images on its input nodes
+func (c *Client) EstimateImageGraph(ctx context.Context, graphID string) (*GraphEstimate, error) {
+	var estimate GraphEstimate
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "estimate"), nil, &estimate); err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
+// GetImageGraphByExternalID gets the image graph created with an external ID
+func (c *Client) GetImageGraphByExternalID(ctx context.Context, externalID string) (*ImageGraph, error) {
+	p := path("imagegraphs", "by-external-id") + "?" + url.Values{"external_id": {externalID}}.Encode()
+
+	var graph ImageGraph
+	if err := c.doJSON(ctx, http.MethodGet, p, nil, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
+
+// SetImageGraphPublic publishes an image graph to the gallery or withdraws it
+func (c *Client) SetImageGraphPublic(ctx context.Context, graphID string, public bool) error {
+	body := struct {
+		Public bool `json:"public"`
+	}{public}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "public"), body, nil)
+}
+
+// SetColorManagement sets what happens to the ICC profiles of source images
+// in an image graph's final images: "preserve", "strip" or "convert_srgb"
+func (c *Client) SetColorManagement(ctx context.Context, graphID, colorManagement string) error {
+	body := struct {
+		ColorManagement string `json:"color_management"`
+	}{colorManagement}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "color-management"), body, nil)
+}
+
+// SetParameters replaces the parameters of an image graph, which node
+// expressions refer to by name
+func (c *Client) SetParameters(ctx context.Context, graphID string, parameters map[string]float64) error {
+	body := struct {
+		Parameters map[string]float64 `json:"parameters"`
+	}{parameters}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "parameters"), body, nil)
+}
+
+// SetSeed sets the seed an image graph's stochastic nodes generate with
+// when they have no seed of their own. A nil seed clears it.
+func (c *Client) SetSeed(ctx context.Context, graphID string, seed *int) error {
+	body := struct {
+		Seed *int `json:"seed"`
+	}{seed}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "seed"), body, nil)
+}
+
+// SetPerformanceMode sets whether an image graph's intermediate nodes skip
+// their previews during generation. Skipped previews are generated by
+// GetNodePreview.
+func (c *Client) SetPerformanceMode(ctx context.Context, graphID string, performanceMode bool) error {
+	body := struct {
+		PerformanceMode bool `json:"performance_mode"`
+	}{performanceMode}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "performance-mode"), body, nil)
+}
+
+// AddImageGraphTag tags an image graph
+func (c *Client) AddImageGraphTag(ctx context.Context, graphID, tag string) error {
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "tags", tag), nil, nil)
+}
+
+// RemoveImageGraphTag untags an image graph
+func (c *Client) RemoveImageGraphTag(ctx context.Context, graphID, tag string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "tags", tag), nil, nil)
+}
+
+// ListShares lists the users an image graph is shared with
+func (c *Client) ListShares(ctx context.Context, graphID string) ([]Share, error) {
+	var resp struct {
+		Shares []Share `json:"shares"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "shares"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Shares, nil
+}
+
+// ShareImageGraph shares an image graph with a user as a viewer or editor
+func (c *Client) ShareImageGraph(ctx context.Context, graphID, userID, role string) error {
+	body := struct {
+		Role string `json:"role"`
+	}{role}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "shares", userID), body, nil)
+}
+
+// UnshareImageGraph stops sharing an image graph with a user
+func (c *Client) UnshareImageGraph(ctx context.Context, graphID, userID string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "shares", userID), nil, nil)
+}
+
+// ListWebhooks lists the webhooks notified when an image graph's pipeline
+// completes
+func (c *Client) ListWebhooks(ctx context.Context, graphID string) ([]Webhook, error) {
+	var resp struct {
+		Webhooks []Webhook `json:"webhooks"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "webhooks"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Webhooks, nil
+}
+
+// CreateWebhook registers a webhook notified when an image graph's pipeline
+// completes. Deliveries are signed with secret, or with a generated secret
+// when it's empty; the returned webhook is the only one with its Secret set.
+func (c *Client) CreateWebhook(ctx context.Context, graphID, url, secret string) (*Webhook, error) {
+	body := struct {
+		URL    string `json:"url"`
+		Secret string `json:"secret,omitempty"`
+	}{url, secret}
+
+	var webhook Webhook
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "webhooks"), body, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook from an image graph
+func (c *Client) DeleteWebhook(ctx context.Context, graphID, webhookID string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "webhooks", webhookID), nil, nil)
+}
+
+// ListGraphLinks lists the links feeding an image graph's input nodes from
+// other graphs
+func (c *Client) ListGraphLinks(ctx context.Context, graphID string) ([]GraphLink, error) {
+	var resp struct {
+		Links []GraphLink `json:"links"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "links"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Links, nil
+}
+
+// CreateGraphLink links an input node of an image graph to a node output of
+// another graph. The input node is set to a copy of each image the output
+// is set to, starting with its current one.
+func (c *Client) CreateGraphLink(ctx context.Context, graphID string, link GraphLink) (*GraphLink, error) {
+	body := struct {
+		NodeID             string `json:"node_id"`
+		SourceImageGraphID string `json:"source_image_graph_id"`
+		SourceNodeID       string `json:"source_node_id"`
+		SourceOutputName   string `json:"source_output_name"`
+	}{link.NodeID, link.SourceImageGraphID, link.SourceNodeID, link.SourceOutputName}
+
+	var created GraphLink
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "links"), body, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteGraphLink removes a link feeding an image graph's input node
+func (c *Client) DeleteGraphLink(ctx context.Context, graphID, linkID string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "links", linkID), nil, nil)
+}
+
+// ListRuns lists the recent runs of an image graph's pipeline, newest
+// first. A limit of 0 lists the server's default number of runs.
+func (c *Client) ListRuns(ctx context.Context, graphID string, limit int) ([]PipelineRun, error) {
+	p := path("imagegraphs", graphID, "runs")
+	if limit > 0 {
+		p += "?" + url.Values{"limit": {strconv.Itoa(limit)}}.Encode()
+	}
+
+	var resp struct {
+		Runs []PipelineRun `json:"runs"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, p, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Runs, nil
+}
+
+// GetSchedule gets the cron schedule an image graph regenerates on
+func (c *Client) GetSchedule(ctx context.Context, graphID string) (*Schedule, error) {
+	var schedule Schedule
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "schedule"), nil, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// SetSchedule sets the cron expression an image graph regenerates on.
+// enabled enables or disables the schedule; nil keeps its current setting,
+// or enables a new schedule.
+func (c *Client) SetSchedule(ctx context.Context, graphID, cron string, enabled *bool) (*Schedule, error) {
+	body := struct {
+		Cron    string `json:"cron"`
+		Enabled *bool  `json:"enabled,omitempty"`
+	}{cron, enabled}
+
+	var schedule Schedule
+	if err := c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "schedule"), body, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule removes an image graph's schedule
+func (c *Client) DeleteSchedule(ctx context.Context, graphID string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "schedule"), nil, nil)
+}
+
+// ListScheduledRuns lists the recent times an image graph's schedule came
+// due, newest first. A limit of 0 lists the server's default number.
+func (c *Client) ListScheduledRuns(ctx context.Context, graphID string, limit int) ([]ScheduledRun, error) {
+	p := path("imagegraphs", graphID, "schedule", "runs")
+	if limit > 0 {
+		p += "?" + url.Values{"limit": {strconv.Itoa(limit)}}.Encode()
+	}
+
+	var resp struct {
+		Runs []ScheduledRun `json:"runs"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, p, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Runs, nil
+}
+
+// ListSnapshots lists the named snapshots of an image graph's results,
+// oldest first
+func (c *Client) ListSnapshots(ctx context.Context, graphID string) ([]SnapshotSummary, error) {
+	var resp struct {
+		Snapshots []SnapshotSummary `json:"snapshots"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "snapshots"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Snapshots, nil
+}
+
+// CreateSnapshot records the configs and output images of an image graph's
+// nodes under a name
+func (c *Client) CreateSnapshot(ctx context.Context, graphID, name string) (*Snapshot, error) {
+	body := struct {
+		Name string `json:"name"`
+	}{name}
+
+	var snapshot Snapshot
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "snapshots"), body, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetSnapshot retrieves a snapshot of an image graph
+func (c *Client) GetSnapshot(ctx context.Context, graphID, snapshotID string) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "snapshots", snapshotID), nil, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// CompareSnapshots lists how the nodes of the snapshot toSnapshotID, or of
+// the image graph as it is now when it's empty, differ from a snapshot
+func (c *Client) CompareSnapshots(ctx context.Context, graphID, fromSnapshotID, toSnapshotID string) (*SnapshotComparison, error) {
+	p := path("imagegraphs", graphID, "snapshots", fromSnapshotID, "compare")
+	if toSnapshotID != "" {
+		p += "?" + url.Values{"to": {toSnapshotID}}.Encode()
+	}
+
+	var comparison SnapshotComparison
+	if err := c.doJSON(ctx, http.MethodGet, p, nil, &comparison); err != nil {
+		return nil, err
+	}
+	return &comparison, nil
+}
+
+// ListExports lists the images of an image graph's output nodes
+func (c *Client) ListExports(ctx context.Context, graphID string) ([]Export, error) {
+	var resp struct {
+		Exports []Export `json:"exports"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "exports"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Exports, nil
+}
+
+// DownloadExportsArchive downloads the images of an image graph's output
+// nodes as a ZIP archive
+func (c *Client) DownloadExportsArchive(ctx context.Context, graphID string) ([]byte, error) {
+	return c.download(ctx, path("imagegraphs", graphID, "exports", "archive"))
+}
+
+// GetPropagationLatency gets how long images take to propagate through an
+// image graph
+func (c *Client) GetPropagationLatency(ctx context.Context, graphID string) (*PropagationLatency, error) {
+	var latency PropagationLatency
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "latency"), nil, &latency); err != nil {
+		return nil, err
+	}
+	return &latency, nil
+}
+
+// ListNodeTypes lists the node types and their config schemas
+func (c *Client) ListNodeTypes(ctx context.Context) ([]NodeType, error) {
+	var resp struct {
+		NodeTypes []NodeType `json:"node_types"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("node-types"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.NodeTypes, nil
+}
+
+// GetAdminStats reports how many graphs, nodes and images the server stores
+func (c *Client) GetAdminStats(ctx context.Context) (*AdminStats, error) {
+	var stats AdminStats
+	if err := c.doJSON(ctx, http.MethodGet, path("admin", "stats"), nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
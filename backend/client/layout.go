@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// GetLayout gets how an image graph's nodes are drawn in the editor
+func (c *Client) GetLayout(ctx context.Context, graphID string) (*Layout, error) {
+	var layout Layout
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "layout"), nil, &layout); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// UpdateLayout sets how an image graph's nodes are drawn in the editor,
+// replacing the layout of every node
+func (c *Client) UpdateLayout(ctx context.Context, graphID string, positions []NodePosition) error {
+	body := struct {
+		NodePositions []NodePosition `json:"node_positions"`
+	}{positions}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "layout"), body, nil)
+}
+
+// AutoLayout arranges an image graph's nodes in layers following their
+// connections, saves the positions as its layout and returns them
+func (c *Client) AutoLayout(ctx context.Context, graphID string) (*Layout, error) {
+	var layout Layout
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "layout", "auto"), nil, &layout); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// GetViewport gets the editor's saved zoom and pan for an image graph
+func (c *Client) GetViewport(ctx context.Context, graphID string) (*Viewport, error) {
+	var viewport Viewport
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "viewport"), nil, &viewport); err != nil {
+		return nil, err
+	}
+	return &viewport, nil
+}
+
+// UpdateViewport saves the editor's zoom and pan for an image graph
+func (c *Client) UpdateViewport(ctx context.Context, graphID string, zoom, panX, panY float64) error {
+	body := struct {
+		Zoom float64 `json:"zoom"`
+		PanX float64 `json:"pan_x"`
+		PanY float64 `json:"pan_y"`
+	}{zoom, panX, panY}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "viewport"), body, nil)
+}
+
+// ListViewportBookmarks lists an image graph's viewport bookmarks in the
+// order they were added
+func (c *Client) ListViewportBookmarks(ctx context.Context, graphID string) ([]ViewportBookmark, error) {
+	var resp struct {
+		Bookmarks []ViewportBookmark `json:"bookmarks"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "viewport", "bookmarks"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Bookmarks, nil
+}
+
+// SaveViewportBookmark adds a viewport bookmark, or replaces the bookmark with
+// the same name. Saving a default bookmark clears the default flag of the
+// others.
+func (c *Client) SaveViewportBookmark(ctx context.Context, graphID string, bookmark ViewportBookmark) (*ViewportBookmark, error) {
+	body := struct {
+		Zoom    float64 `json:"zoom"`
+		PanX    float64 `json:"pan_x"`
+		PanY    float64 `json:"pan_y"`
+		Default bool    `json:"default"`
+	}{bookmark.Zoom, bookmark.PanX, bookmark.PanY, bookmark.Default}
+
+	var saved ViewportBookmark
+	if err := c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "viewport", "bookmarks", bookmark.Name), body, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteViewportBookmark removes a viewport bookmark
+func (c *Client) DeleteViewportBookmark(ctx context.Context, graphID, name string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "viewport", "bookmarks", name), nil, nil)
+}
+
+// GetImage downloads an image
+func (c *Client) GetImage(ctx context.Context, imageID string) ([]byte, error) {
+	return c.download(ctx, path("images", imageID))
+}
+
+// GetNodePreview downloads the preview of a node, generating it if the node
+// skipped it in performance mode
+func (c *Client) GetNodePreview(ctx context.Context, graphID, nodeID string) ([]byte, error) {
+	return c.download(ctx, path("imagegraphs", graphID, "nodes", nodeID, "preview"))
+}
+
+// GetOutputImage downloads the current image of a node output
+func (c *Client) GetOutputImage(ctx context.Context, graphID, nodeID, outputName string) ([]byte, error) {
+	return c.download(ctx, path("imagegraphs", graphID, "nodes", nodeID, "outputs", outputName, "image"))
+}
+
+// GetImageMetadata gets the metadata stored with an image
+func (c *Client) GetImageMetadata(ctx context.Context, imageID string) (*ImageMetadata, error) {
+	var metadata ImageMetadata
+	if err := c.doJSON(ctx, http.MethodGet, path("images", imageID, "metadata"), nil, &metadata); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// GetImageProvenance traces an image back through the nodes and images it
+// was generated from
+func (c *Client) GetImageProvenance(ctx context.Context, imageID string) (*ImageProvenance, error) {
+	var provenance ImageProvenance
+	if err := c.doJSON(ctx, http.MethodGet, path("images", imageID, "provenance"), nil, &provenance); err != nil {
+		return nil, err
+	}
+	return &provenance, nil
+}
+
+// download gets the raw body of a file response
+func (c *Client) download(ctx context.Context, p string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, p, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read GET %s response: %w", p, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,369 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// AddNode adds a node to an image graph, returning its ID
+func (c *Client) AddNode(ctx context.Context, graphID string, node NewNode) (string, error) {
+	if node.Config == nil {
+		node.Config = json.RawMessage("{}")
+	}
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes"), node, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// GetNodeByExternalID gets the node of an image graph added with an external
+// ID
+func (c *Client) GetNodeByExternalID(ctx context.Context, graphID, externalID string) (*Node, error) {
+	var node Node
+	p := path("imagegraphs", graphID, "nodes", "by-external-id") + "?" + url.Values{"external_id": {externalID}}.Encode()
+	err := c.doJSON(ctx, http.MethodGet, p, nil, &node)
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// UpdateNode changes the fields of a node that are set in the update
+func (c *Client) UpdateNode(ctx context.Context, graphID, nodeID string, update NodeUpdate) error {
+	return c.doJSON(ctx, http.MethodPatch, path("imagegraphs", graphID, "nodes", nodeID), update, nil)
+}
+
+// DeleteNode removes a node from an image graph
+func (c *Client) DeleteNode(ctx context.Context, graphID, nodeID string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "nodes", nodeID), nil, nil)
+}
+
+// ValidateNodeConfig checks a config against a node without changing it
+func (c *Client) ValidateNodeConfig(ctx context.Context, graphID, nodeID string, config json.RawMessage) (*ValidationResult, error) {
+	body := struct {
+		Config json.RawMessage `json:"config,omitempty"`
+	}{config}
+
+	var result ValidationResult
+	err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "validate"), body, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpgradeNode upgrades a node to the latest implementation of its type
+func (c *Client) UpgradeNode(ctx context.Context, graphID, nodeID string) error {
+	return c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "upgrade"), nil, nil)
+}
+
+// GetNodeDiff measures how much the inputs of a diff node differ
+func (c *Client) GetNodeDiff(ctx context.Context, graphID, nodeID string) (*NodeDiff, error) {
+	var diff NodeDiff
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "nodes", nodeID, "diff"), nil, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// GetNodeStats reports how long the recent generations of a node took and
+// the pixels they worked on
+func (c *Client) GetNodeStats(ctx context.Context, graphID, nodeID string) (*NodeStats, error) {
+	var stats NodeStats
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "nodes", nodeID, "stats"), nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// DuplicateNode copies a node's type, name, config and added inputs, but not
+// its connections, into a new node and returns the new node's ID
+func (c *Client) DuplicateNode(ctx context.Context, graphID, nodeID string, duplicate NodeDuplicate) (string, error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "duplicate"), duplicate, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// SweepNode adds a copy of a node for each value of a config field sweep,
+// connected like the node, and returns the IDs of the copies
+func (c *Client) SweepNode(ctx context.Context, graphID, nodeID string, sweep NodeSweep) ([]string, error) {
+	var resp struct {
+		NodeIDs []string `json:"node_ids"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "sweep"), sweep, &resp); err != nil {
+		return nil, err
+	}
+	return resp.NodeIDs, nil
+}
+
+// AddNodeInput adds an input to a node whose type can grow inputs, returning
+// the input's name. An empty name adds the input after the node's highest
+// numbered one.
+func (c *Client) AddNodeInput(ctx context.Context, graphID, nodeID, inputName string) (string, error) {
+	req := struct {
+		InputName string `json:"input_name,omitempty"`
+	}{InputName: inputName}
+	var resp struct {
+		InputName string `json:"input_name"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "inputs"), req, &resp); err != nil {
+		return "", err
+	}
+	return resp.InputName, nil
+}
+
+// RemoveNodeInput removes an added input from a node, disconnecting it first
+func (c *Client) RemoveNodeInput(ctx context.Context, graphID, nodeID, inputName string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "nodes", nodeID, "inputs", inputName), nil, nil)
+}
+
+// AddNodeTag tags a node
+func (c *Client) AddNodeTag(ctx context.Context, graphID, nodeID, tag string) error {
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "nodes", nodeID, "tags", tag), nil, nil)
+}
+
+// RemoveNodeTag untags a node
+func (c *Client) RemoveNodeTag(ctx context.Context, graphID, nodeID, tag string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "nodes", nodeID, "tags", tag), nil, nil)
+}
+
+// ConnectNodes connects a node output to a node input
+func (c *Client) ConnectNodes(ctx context.Context, graphID string, connection Connection) error {
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "connectNodes"), connection, nil)
+}
+
+// DisconnectNodes disconnects a node output from a node input
+func (c *Client) DisconnectNodes(ctx context.Context, graphID string, connection Connection) error {
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "disconnectNodes"), connection, nil)
+}
+
+// UploadOutputImage sets a node output to an uploaded image, returning the
+// new image's ID. The node is renamed after the file, as when uploading in
+// the editor. The image's content type is detected from its data.
+func (c *Client) UploadOutputImage(ctx context.Context, graphID, nodeID, outputName, filename string, image []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="image"; filename=%q`, filename))
+	header.Set("Content-Type", http.DetectContentType(image))
+
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return "", fmt.Errorf("could not create image upload: %w", err)
+	}
+	if _, err := part.Write(image); err != nil {
+		return "", fmt.Errorf("could not create image upload: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("could not create image upload: %w", err)
+	}
+
+	p := path("imagegraphs", graphID, "nodes", nodeID, "outputs", outputName)
+	resp, err := c.do(ctx, http.MethodPut, p, form.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var upload struct {
+		ImageID string `json:"image_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&upload); err != nil {
+		return "", fmt.Errorf("could not decode PUT %s response: %w", p, err)
+	}
+	return upload.ImageID, nil
+}
+
+// ImportOutputImage sets a node output to a copy of another image, such as
+// the output of a node in another graph, returning the copy's ID
+func (c *Client) ImportOutputImage(ctx context.Context, graphID, nodeID, outputName string, source ImageSource) (string, error) {
+	var resp struct {
+		ImageID string `json:"image_id"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "outputs", outputName, "import"), source, &resp); err != nil {
+		return "", err
+	}
+	return resp.ImageID, nil
+}
+
+// GetOutputHistory lists the current image of a node output and the images
+// it replaced
+func (c *Client) GetOutputHistory(ctx context.Context, graphID, nodeID, outputName string) (*OutputHistory, error) {
+	var history OutputHistory
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "nodes", nodeID, "outputs", outputName, "history"), nil, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// PromoteOutputVariant makes a previous image of a node output its current
+// image again. Downstream nodes regenerate from it.
+func (c *Client) PromoteOutputVariant(ctx context.Context, graphID, nodeID, outputName, imageID string) error {
+	return c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "outputs", outputName, "history", imageID, "promote"), nil, nil)
+}
+
+// ClearOutputImage unsets a node output's current image, which moves into its
+// history. The inputs connected to the output are cleared downstream.
+func (c *Client) ClearOutputImage(ctx context.Context, graphID, nodeID, outputName string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "nodes", nodeID, "outputs", outputName), nil, nil)
+}
+
+// UploadInputs creates an Input node for each uploaded image, and each image
+// in uploaded ZIP archives, returning the new nodes' IDs in upload order
+func (c *Client) UploadInputs(ctx context.Context, graphID string, uploads []InputUpload, opts UploadInputsOptions) ([]string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	for _, upload := range uploads {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="images"; filename=%q`, upload.Filename))
+		header.Set("Content-Type", http.DetectContentType(upload.Data))
+
+		part, err := form.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("could not create input upload: %w", err)
+		}
+		if _, err := part.Write(upload.Data); err != nil {
+			return nil, fmt.Errorf("could not create input upload: %w", err)
+		}
+	}
+	if opts.ConnectTo != "" {
+		if err := form.WriteField("connect_to", opts.ConnectTo); err != nil {
+			return nil, fmt.Errorf("could not create input upload: %w", err)
+		}
+	}
+	if opts.ConnectInput != "" {
+		if err := form.WriteField("connect_input", opts.ConnectInput); err != nil {
+			return nil, fmt.Errorf("could not create input upload: %w", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("could not create input upload: %w", err)
+	}
+
+	p := path("imagegraphs", graphID, "inputs")
+	resp, err := c.do(ctx, http.MethodPost, p, form.FormDataContentType(), &body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		NodeIDs []string `json:"node_ids"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, fmt.Errorf("could not decode POST %s response: %w", p, err)
+	}
+	return created.NodeIDs, nil
+}
+
+// ExportPalette downloads the palette a node outputs as a file in the given
+// format: "gpl", "aco" or "hex". An empty format downloads a GIMP palette.
+func (c *Client) ExportPalette(ctx context.Context, graphID, nodeID, format string) ([]byte, error) {
+	p := path("imagegraphs", graphID, "nodes", nodeID, "palette")
+	if format != "" {
+		p += "?" + url.Values{"format": {format}}.Encode()
+	}
+	return c.download(ctx, p)
+}
+
+// ImportPalette creates a PaletteCreate node from a GIMP .gpl, Adobe .aco or
+// hex text palette file, returning the new node's ID. The format is detected
+// from the file name and contents. The node is named after the palette, or
+// the file if the palette has no name.
+func (c *Client) ImportPalette(ctx context.Context, graphID, filename string, data []byte) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return "", fmt.Errorf("could not create palette upload: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("could not create palette upload: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("could not create palette upload: %w", err)
+	}
+
+	p := path("imagegraphs", graphID, "palettes")
+	resp, err := c.do(ctx, http.MethodPost, p, form.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("could not decode POST %s response: %w", p, err)
+	}
+	return created.ID, nil
+}
+
+// ListPaletteSwatches lists the colors of a palette edit node in order
+func (c *Client) ListPaletteSwatches(ctx context.Context, graphID, nodeID string) ([]PaletteSwatch, error) {
+	var resp struct {
+		Swatches []PaletteSwatch `json:"swatches"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path("imagegraphs", graphID, "nodes", nodeID, "swatches"), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Swatches, nil
+}
+
+// AddPaletteSwatch adds a color, written as #rrggbb, to a palette edit node
+// at index, or at the end if index is nil
+func (c *Client) AddPaletteSwatch(ctx context.Context, graphID, nodeID, color string, enabled bool, index *int) error {
+	body := struct {
+		Color   string `json:"color"`
+		Enabled bool   `json:"enabled"`
+		Index   *int   `json:"index,omitempty"`
+	}{color, enabled, index}
+	return c.doJSON(ctx, http.MethodPost, path("imagegraphs", graphID, "nodes", nodeID, "swatches"), body, nil)
+}
+
+// SetPaletteSwatchEnabled enables or disables a color of a palette edit node
+func (c *Client) SetPaletteSwatchEnabled(ctx context.Context, graphID, nodeID, color string, enabled bool) error {
+	body := struct {
+		Enabled bool `json:"enabled"`
+	}{enabled}
+	return c.doJSON(ctx, http.MethodPatch, path("imagegraphs", graphID, "nodes", nodeID, "swatches", swatchPathColor(color)), body, nil)
+}
+
+// RemovePaletteSwatch removes a color from a palette edit node
+func (c *Client) RemovePaletteSwatch(ctx context.Context, graphID, nodeID, color string) error {
+	return c.doJSON(ctx, http.MethodDelete, path("imagegraphs", graphID, "nodes", nodeID, "swatches", swatchPathColor(color)), nil, nil)
+}
+
+// ReorderPaletteSwatches puts a palette edit node's colors in the given
+// order, which must list each of them once
+func (c *Client) ReorderPaletteSwatches(ctx context.Context, graphID, nodeID string, colors []string) error {
+	body := struct {
+		Colors []string `json:"colors"`
+	}{colors}
+	return c.doJSON(ctx, http.MethodPut, path("imagegraphs", graphID, "nodes", nodeID, "swatches", "order"), body, nil)
+}
+
+// swatchPathColor drops the # of a color, which would otherwise be escaped
+// in the URL path
+func swatchPathColor(color string) string {
+	return strings.TrimPrefix(color, "#")
+}
@@ -0,0 +1,705 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Request types
+
+// NewImageGraph describes an image graph to create
+type NewImageGraph struct {
+	Name       string `json:"name"`
+	ExternalID string `json:"external_id,omitempty"`
+}
+
+// NewNode describes a node to add to an image graph. A nil Config creates
+// the node with its type's default config.
+type NewNode struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Config     json.RawMessage `json:"config"`
+	ExternalID string          `json:"external_id,omitempty"`
+}
+
+// ImageSource names an image to import: the current image of NodeID's
+// OutputName, or ImageID, in ImageGraphID or the graph being imported into
+// when that is empty
+type ImageSource struct {
+	ImageGraphID string `json:"image_graph_id,omitempty"`
+	NodeID       string `json:"node_id,omitempty"`
+	OutputName   string `json:"output_name,omitempty"`
+	ImageID      string `json:"image_id,omitempty"`
+}
+
+// NodeUpdate changes the fields of a node that are set. Expressions
+// replaces the node's config expressions when non-nil, and an empty map
+// removes them.
+type NodeUpdate struct {
+	Name           *string           `json:"name,omitempty"`
+	Description    *string           `json:"description,omitempty"`
+	Config         json.RawMessage   `json:"config,omitempty"`
+	Bypassed       *bool             `json:"bypassed,omitempty"`
+	Pinned         *bool             `json:"pinned,omitempty"`
+	Implementation *int              `json:"implementation,omitempty"`
+	Expressions    map[string]string `json:"expressions"`
+}
+
+// Connection connects a node output to a node input
+type Connection struct {
+	FromNodeID string `json:"from_node_id"`
+	OutputName string `json:"output_name"`
+	ToNodeID   string `json:"to_node_id"`
+	InputName  string `json:"input_name"`
+	// Transform is made to images passed along the connection, e.g.
+	// "invert" or "alpha"
+	Transform string `json:"transform,omitempty"`
+}
+
+// NodeSweep sweeps a node config field through Values, or through the
+// numeric range From to To in steps of Step, which defaults to 1
+type NodeSweep struct {
+	Field  string  `json:"field"`
+	Values []any   `json:"values,omitempty"`
+	From   float64 `json:"from,omitempty"`
+	To     float64 `json:"to,omitempty"`
+	Step   float64 `json:"step,omitempty"`
+}
+
+// NodeDuplicate says where the copy of a node goes: into TargetGraphID, or
+// the node's own graph when empty, at the node's position plus Offset. The
+// offset defaults to a little down and right of the node in its own graph.
+type NodeDuplicate struct {
+	TargetGraphID string          `json:"target_graph_id,omitempty"`
+	Offset        *PositionOffset `json:"offset,omitempty"`
+}
+
+// PositionOffset moves a position on the canvas
+type PositionOffset struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// InputUpload is an image, or ZIP archive of images, to upload as new
+// Input nodes
+type InputUpload struct {
+	Filename string
+	Data     []byte
+}
+
+// UploadInputsOptions connects uploaded Input nodes to the free inputs of
+// ConnectTo, starting at ConnectInput. Zero values don't connect them.
+type UploadInputsOptions struct {
+	ConnectTo    string
+	ConnectInput string
+}
+
+// ListImageGraphsOptions filters, sorts and pages an image graph list. Zero
+// values use the API's defaults.
+type ListImageGraphsOptions struct {
+	Tag    string
+	Sort   string
+	Order  string
+	Limit  int
+	Offset int
+}
+
+// Response types
+
+// ImageGraph is an image graph and its nodes
+type ImageGraph struct {
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	ExternalID      string             `json:"external_id,omitempty"`
+	Owner           string             `json:"owner,omitempty"`
+	Role            string             `json:"role,omitempty"`
+	Shares          []Share            `json:"shares,omitempty"`
+	Public          bool               `json:"public,omitempty"`
+	ColorManagement string             `json:"color_management"`
+	PerformanceMode bool               `json:"performance_mode,omitempty"`
+	Parameters      map[string]float64 `json:"parameters,omitempty"`
+	Seed            *int               `json:"seed,omitempty"`
+	Tags            []string           `json:"tags,omitempty"`
+	Version         int                `json:"version"`
+	CreatedAt       time.Time          `json:"created_at,omitzero"`
+	UpdatedAt       time.Time          `json:"updated_at,omitzero"`
+	Complexity      Complexity         `json:"complexity"`
+	Nodes           []Node             `json:"nodes"`
+}
+
+// Node returns the image graph's node with the given ID
+func (ig *ImageGraph) Node(id string) (Node, bool) {
+	for _, node := range ig.Nodes {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return Node{}, false
+}
+
+// Complexity reports the live complexity counters of an image graph and the
+// limits it's held to
+type Complexity struct {
+	Nodes              int `json:"nodes"`
+	Connections        int `json:"connections"`
+	PendingGenerations int `json:"pending_generations"`
+	MaxNodes           int `json:"max_nodes,omitempty"`
+	MaxConnections     int `json:"max_connections,omitempty"`
+}
+
+// Node is a node of an image graph
+type Node struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	Description          string            `json:"description,omitempty"`
+	Tags                 []string          `json:"tags,omitempty"`
+	ExternalID           string            `json:"external_id,omitempty"`
+	Type                 string            `json:"type"`
+	Version              int               `json:"version"`
+	ImageVersion         int               `json:"image_version,omitempty"`
+	Config               json.RawMessage   `json:"config"`
+	Expressions          map[string]string `json:"expressions,omitempty"`
+	EffectiveSeed        *int              `json:"effective_seed,omitempty"`
+	Implementation       int               `json:"implementation"`
+	LatestImplementation int               `json:"latest_implementation"`
+	Bypassed             bool              `json:"bypassed,omitempty"`
+	Pinned               bool              `json:"pinned,omitempty"`
+	Stale                bool              `json:"stale,omitempty"`
+	State                string            `json:"state"`
+	Error                string            `json:"error,omitempty"`
+	Preview              string            `json:"preview,omitempty"`
+	CreatedAt            time.Time         `json:"created_at,omitzero"`
+	UpdatedAt            time.Time         `json:"updated_at,omitzero"`
+	Inputs               []Input           `json:"inputs"`
+	Outputs              []Output          `json:"outputs"`
+}
+
+// Output returns the node's output with the given name
+func (n *Node) Output(name string) (Output, bool) {
+	for _, output := range n.Outputs {
+		if output.Name == name {
+			return output, true
+		}
+	}
+	return Output{}, false
+}
+
+// Input is a node input and the output connected to it
+type Input struct {
+	Name       string           `json:"name"`
+	ImageID    string           `json:"image_id,omitempty"`
+	Connected  bool             `json:"connected"`
+	Optional   bool             `json:"optional,omitempty"`
+	Connection *InputConnection `json:"connection,omitempty"`
+}
+
+// InputConnection is the node output an input is connected to
+type InputConnection struct {
+	NodeID     string `json:"node_id"`
+	OutputName string `json:"output_name"`
+	Transform  string `json:"transform,omitempty"`
+}
+
+// Output is a node output and the inputs connected to it
+type Output struct {
+	Name        string             `json:"name"`
+	ImageID     string             `json:"image_id,omitempty"`
+	History     []OutputVariant    `json:"history,omitempty"`
+	Connections []OutputConnection `json:"connections"`
+}
+
+// OutputVariant is an image an output held before it was replaced
+type OutputVariant struct {
+	ImageID     string    `json:"image_id"`
+	URL         string    `json:"url"`
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+}
+
+// PaletteSwatch is a color of a palette edit node's palette. Disabled
+// swatches are left out of the node's output.
+type PaletteSwatch struct {
+	Color   string `json:"color"`
+	Enabled bool   `json:"enabled"`
+}
+
+// OutputHistory is the current image of a node output followed by the images
+// it replaced, most recent first
+type OutputHistory struct {
+	OutputName  string          `json:"output_name"`
+	ImageID     string          `json:"image_id,omitempty"`
+	GeneratedAt time.Time       `json:"generated_at,omitzero"`
+	History     []OutputVariant `json:"history"`
+}
+
+// OutputConnection is a node input an output is connected to
+type OutputConnection struct {
+	NodeID    string `json:"node_id"`
+	InputName string `json:"input_name"`
+}
+
+// ImageGraphList is a page of image graph summaries
+type ImageGraphList struct {
+	ImageGraphs []ImageGraphSummary `json:"imagegraphs"`
+	Total       int                 `json:"total"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
+}
+
+// ImageGraphSummary describes an image graph without its nodes
+type ImageGraphSummary struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Owner     string   `json:"owner,omitempty"`
+	Role      string   `json:"role,omitempty"`
+	Public    bool     `json:"public,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	NodeCount int      `json:"node_count"`
+
+	// ThumbnailImageID is the preview of the graph's first export, empty
+	// until an output node has generated its final image
+	ThumbnailImageID string `json:"thumbnail_image_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+// Share is a user an image graph is shared with and the role they were
+// granted
+type Share struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// Webhook is an endpoint notified when an image graph's pipeline completes
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GraphLink feeds an input node, NodeID, the images set on a node output of
+// another image graph
+type GraphLink struct {
+	ID                 string    `json:"id"`
+	NodeID             string    `json:"node_id"`
+	SourceImageGraphID string    `json:"source_image_graph_id"`
+	SourceNodeID       string    `json:"source_node_id"`
+	SourceOutputName   string    `json:"source_output_name"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// Schedule is the cron expression, in UTC, an image graph regenerates on.
+// NextRunAt is set while it's enabled.
+type Schedule struct {
+	Cron      string     `json:"cron"`
+	Enabled   bool       `json:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ScheduledRun is a time an image graph's schedule came due. Status is
+// started, skipped (the graph was still regenerating) or failed.
+type ScheduledRun struct {
+	ScheduledFor time.Time `json:"scheduled_for"`
+	StartedAt    time.Time `json:"started_at"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// SnapshotSummary describes a snapshot without its nodes
+type SnapshotSummary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Nodes     int       `json:"nodes"`
+	Images    int       `json:"images"`
+}
+
+// Snapshot is the configs and output images of an image graph's nodes,
+// recorded under a name
+type Snapshot struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	CreatedAt time.Time      `json:"created_at"`
+	Nodes     []SnapshotNode `json:"nodes"`
+}
+
+// SnapshotNode is a node as it was when a snapshot was taken
+type SnapshotNode struct {
+	NodeID  string           `json:"node_id"`
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	Config  json.RawMessage  `json:"config"`
+	Outputs []SnapshotOutput `json:"outputs"`
+}
+
+// SnapshotOutput is the snapshot's copy of a node output's image
+type SnapshotOutput struct {
+	Name    string `json:"name"`
+	ImageID string `json:"image_id,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// SnapshotComparison lists how the nodes of a snapshot, or of the image
+// graph as it is now when ToSnapshotID is empty, differ from another
+// snapshot
+type SnapshotComparison struct {
+	FromSnapshotID string               `json:"from_snapshot_id"`
+	ToSnapshotID   string               `json:"to_snapshot_id,omitempty"`
+	Added          int                  `json:"added"`
+	Removed        int                  `json:"removed"`
+	Changed        int                  `json:"changed"`
+	Unchanged      int                  `json:"unchanged"`
+	Nodes          []SnapshotNodeChange `json:"nodes"`
+}
+
+// SnapshotNodeChange compares a node between two snapshots. Change is
+// added, removed, changed or unchanged.
+type SnapshotNodeChange struct {
+	NodeID        string                 `json:"node_id"`
+	Name          string                 `json:"name"`
+	Type          string                 `json:"type"`
+	Change        string                 `json:"change"`
+	ConfigChanged bool                   `json:"config_changed"`
+	FromConfig    json.RawMessage        `json:"from_config,omitempty"`
+	ToConfig      json.RawMessage        `json:"to_config,omitempty"`
+	Outputs       []SnapshotOutputChange `json:"outputs"`
+}
+
+// SnapshotOutputChange compares a node output's image between two
+// snapshots, with how much the images differ when both are there
+type SnapshotOutputChange struct {
+	Name        string    `json:"name"`
+	Changed     bool      `json:"changed"`
+	FromImageID string    `json:"from_image_id,omitempty"`
+	FromURL     string    `json:"from_url,omitempty"`
+	ToImageID   string    `json:"to_image_id,omitempty"`
+	ToURL       string    `json:"to_url,omitempty"`
+	Difference  *NodeDiff `json:"difference,omitempty"`
+}
+
+// Export is the image of an output node
+type Export struct {
+	Name        string    `json:"name"`
+	NodeID      string    `json:"node_id"`
+	ImageID     string    `json:"image_id"`
+	URL         string    `json:"url"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	Format      string    `json:"format,omitempty"`
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+}
+
+// ValidationResult reports whether a node config is valid
+type ValidationResult struct {
+	Valid    bool     `json:"valid"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings"`
+}
+
+// GraphValidation lists the connections of a graph that static validation
+// found problems with. The graph is valid if none have errors.
+type GraphValidation struct {
+	Valid       bool                   `json:"valid"`
+	Connections []ConnectionValidation `json:"connections"`
+}
+
+// Diagnostics lists the problems that keep a graph from being complete,
+// errors first
+type Diagnostics struct {
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Diagnostic is a problem with a node of a graph, or with one of its inputs
+// or outputs when Port is set
+type Diagnostic struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	Port     string `json:"port,omitempty"`
+	Message  string `json:"message"`
+}
+
+// GraphEstimate is what regenerating a graph is expected to take.
+// DurationMs totals the nodes whose duration could be estimated, and
+// PeakMemoryBytes is the most any one node needs.
+type GraphEstimate struct {
+	DurationMs      float64        `json:"duration_ms"`
+	PeakMemoryBytes int64          `json:"peak_memory_bytes"`
+	Nodes           []NodeEstimate `json:"nodes"`
+}
+
+// NodeEstimate is what regenerating a node is expected to take. Output and
+// DurationMs are nil when they couldn't be estimated.
+type NodeEstimate struct {
+	NodeID           string               `json:"node_id"`
+	NodeName         string               `json:"node_name"`
+	Type             string               `json:"type"`
+	Inputs           map[string]ImageSize `json:"inputs"`
+	Output           *ImageSize           `json:"output"`
+	MemoryBytes      int64                `json:"memory_bytes"`
+	DurationMs       *float64             `json:"duration_ms"`
+	TimedGenerations int                  `json:"timed_generations"`
+}
+
+// NodeStats summarises the recent generation runs of a node, with the runs
+// newest first. Durations and pixels only count the runs that succeeded.
+type NodeStats struct {
+	NodeID           string          `json:"node_id"`
+	Runs             int             `json:"runs"`
+	Failures         int             `json:"failures"`
+	LastMs           float64         `json:"last_ms"`
+	MeanMs           float64         `json:"mean_ms"`
+	MinMs            float64         `json:"min_ms"`
+	MaxMs            float64         `json:"max_ms"`
+	MeanInputPixels  int64           `json:"mean_input_pixels"`
+	MeanOutputPixels int64           `json:"mean_output_pixels"`
+	MsPerMegapixel   float64         `json:"ms_per_megapixel"`
+	History          []GenerationRun `json:"history"`
+}
+
+// GenerationRun is one run of a node's output generation
+type GenerationRun struct {
+	NodeVersion  int64     `json:"node_version"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   float64   `json:"duration_ms"`
+	InputPixels  int64     `json:"input_pixels"`
+	OutputPixels int64     `json:"output_pixels"`
+	Failed       bool      `json:"failed"`
+}
+
+// PipelineRun is a run of an image graph's pipeline, from an image being set
+// on an input node until the graph settled. Status is completed, failed,
+// incomplete or superseded.
+type PipelineRun struct {
+	ID             string              `json:"id"`
+	TriggerNodeID  string              `json:"trigger_node_id"`
+	TriggerImageID string              `json:"trigger_image_id"`
+	StartedAt      time.Time           `json:"started_at"`
+	EndedAt        time.Time           `json:"ended_at"`
+	DurationMs     float64             `json:"duration_ms"`
+	Status         string              `json:"status"`
+	Nodes          []PipelineRunNode   `json:"nodes"`
+	Outputs        []PipelineRunOutput `json:"outputs"`
+}
+
+// PipelineRunNode is a node a pipeline run regenerated
+type PipelineRunNode struct {
+	NodeID      string    `json:"node_id"`
+	Type        string    `json:"type"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  float64   `json:"duration_ms"`
+	Generations int       `json:"generations"`
+	Failed      bool      `json:"failed"`
+}
+
+// PipelineRunOutput is a final image a pipeline run produced
+type PipelineRunOutput struct {
+	Name       string  `json:"name"`
+	NodeID     string  `json:"node_id"`
+	ImageID    string  `json:"image_id"`
+	URL        string  `json:"url"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// ImageSize is the dimensions of an image
+type ImageSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ConnectionValidation is what static validation found about a connection
+type ConnectionValidation struct {
+	FromNodeID string   `json:"from_node_id"`
+	OutputName string   `json:"output_name"`
+	ToNodeID   string   `json:"to_node_id"`
+	InputName  string   `json:"input_name"`
+	Errors     []string `json:"errors"`
+	Warnings   []string `json:"warnings"`
+}
+
+// NodeDiff reports how much the inputs of a diff node differ. RMSE runs from
+// 0 for identical images to 1, SSIM from 1 for identical images down to -1.
+type NodeDiff struct {
+	RMSE float64 `json:"rmse"`
+	SSIM float64 `json:"ssim"`
+}
+
+// ImageMetadata is the metadata stored with an image. Generated images carry
+// the filename, DPI and capture data of the images they were made from.
+type ImageMetadata struct {
+	Filename    string    `json:"filename,omitempty"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	DPI         float64   `json:"dpi,omitempty"`
+	CapturedAt  time.Time `json:"captured_at,omitzero"`
+	CameraMake  string    `json:"camera_make,omitempty"`
+	CameraModel string    `json:"camera_model,omitempty"`
+}
+
+// ImageProvenance is the chain of images an image was generated from,
+// nearest first, each listed once
+type ImageProvenance struct {
+	ImageID   string           `json:"image_id"`
+	Steps     []ProvenanceStep `json:"steps"`
+	Truncated bool             `json:"truncated,omitempty"`
+}
+
+// ProvenanceStep is an image of a provenance chain. Images generated in
+// graphs the caller can't view are Hidden; images without a producer were
+// uploaded, from Filename if it's set.
+type ProvenanceStep struct {
+	ImageID    string         `json:"image_id"`
+	Filename   string         `json:"filename,omitempty"`
+	Hidden     bool           `json:"hidden,omitempty"`
+	ProducedBy *ImageProducer `json:"produced_by,omitempty"`
+}
+
+// ImageProducer is the node version that generated an image
+type ImageProducer struct {
+	ImageGraphID   string            `json:"image_graph_id"`
+	NodeID         string            `json:"node_id"`
+	NodeName       string            `json:"node_name,omitempty"`
+	NodeType       string            `json:"node_type"`
+	NodeVersion    int               `json:"node_version"`
+	OutputName     string            `json:"output_name"`
+	Implementation int               `json:"implementation"`
+	Bypassed       bool              `json:"bypassed,omitempty"`
+	Config         json.RawMessage   `json:"config"`
+	Inputs         []ProvenanceInput `json:"inputs"`
+	ProducedAt     time.Time         `json:"produced_at"`
+}
+
+// ProvenanceInput is an image a node generated from
+type ProvenanceInput struct {
+	Name      string `json:"name"`
+	ImageID   string `json:"image_id"`
+	Transform string `json:"transform,omitempty"`
+}
+
+// Layout is how each node of an image graph is drawn in the editor
+type Layout struct {
+	GraphID       string         `json:"graph_id"`
+	NodePositions []NodePosition `json:"node_positions"`
+}
+
+// NodePosition is the position of a node in the editor, along with its size,
+// collapsed state, color label and stacking order. A zero Width or Height is
+// the editor's default size, and Color is empty or one of red, orange,
+// yellow, green, blue, purple and gray.
+type NodePosition struct {
+	NodeID    string  `json:"node_id"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Width     float64 `json:"width,omitempty"`
+	Height    float64 `json:"height,omitempty"`
+	Collapsed bool    `json:"collapsed,omitempty"`
+	Color     string  `json:"color,omitempty"`
+	ZOrder    int     `json:"z_order,omitempty"`
+}
+
+// Viewport is the editor's saved zoom and pan for an image graph
+type Viewport struct {
+	GraphID   string             `json:"graph_id"`
+	Zoom      float64            `json:"zoom"`
+	PanX      float64            `json:"pan_x"`
+	PanY      float64            `json:"pan_y"`
+	Bookmarks []ViewportBookmark `json:"bookmarks"`
+}
+
+// ViewportBookmark is a named zoom and pan the editor can jump to. The
+// default bookmark is where the editor opens the graph.
+type ViewportBookmark struct {
+	Name    string  `json:"name"`
+	Zoom    float64 `json:"zoom"`
+	PanX    float64 `json:"pan_x"`
+	PanY    float64 `json:"pan_y"`
+	Default bool    `json:"default"`
+}
+
+// FullImageGraph is an image graph with everything the editor loads for it
+type FullImageGraph struct {
+	ImageGraph ImageGraph `json:"imagegraph"`
+	Layout     Layout     `json:"layout"`
+	Viewport   Viewport   `json:"viewport"`
+	NodeTypes  []NodeType `json:"node_types"`
+}
+
+// NodeType describes a node type and its config schema
+type NodeType struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Category    string `json:"category"`
+	// Deprecated, if set, says what to use instead of the node type
+	Deprecated string         `json:"deprecated,omitempty"`
+	Schema     NodeTypeSchema `json:"schema"`
+}
+
+// NodeTypeSchema lists the inputs, outputs and config fields of a node type.
+// Node types with DynamicInputs can have inputs named like it with a number
+// after, up to MaxInputs in all.
+type NodeTypeSchema struct {
+	Inputs               []string      `json:"inputs"`
+	OptionalInputs       []string      `json:"optional_inputs,omitempty"`
+	DynamicInputs        string        `json:"dynamic_inputs,omitempty"`
+	MaxInputs            int           `json:"max_inputs,omitempty"`
+	Outputs              []string      `json:"outputs"`
+	NameRequired         bool          `json:"name_required"`
+	LatestImplementation int           `json:"latest_implementation"`
+	ConfigVersion        int           `json:"config_version"`
+	Fields               []ConfigField `json:"fields"`
+}
+
+// ConfigField describes a node config field
+type ConfigField struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+	Default  any      `json:"default,omitempty"`
+}
+
+// PropagationLatency reports how long images take to reach an image graph's
+// nodes and to be regenerated by them
+type PropagationLatency struct {
+	ImageGraphID string              `json:"image_graph_id"`
+	Input        LatencyStats        `json:"input"`
+	Regeneration LatencyStats        `json:"regeneration"`
+	Connections  []ConnectionLatency `json:"connections"`
+}
+
+// ConnectionLatency reports the propagation latency of one connection
+type ConnectionLatency struct {
+	FromNodeID     string       `json:"from_node_id"`
+	FromOutputName string       `json:"from_output_name"`
+	ToNodeID       string       `json:"to_node_id"`
+	ToInputName    string       `json:"to_input_name"`
+	Input          LatencyStats `json:"input"`
+	Regeneration   LatencyStats `json:"regeneration"`
+}
+
+// LatencyStats summarizes latency samples
+type LatencyStats struct {
+	Count  int     `json:"count"`
+	LastMs float64 `json:"last_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// AdminStats reports what a server stores. The image counts are nil when
+// its image storage can't list its images.
+type AdminStats struct {
+	ImageGraphs        int            `json:"image_graphs"`
+	NodesByType        map[string]int `json:"nodes_by_type"`
+	StoredImages       *int           `json:"stored_images"`
+	StoredImageBytes   *int64         `json:"stored_image_bytes"`
+	OrphanedImages     *int           `json:"orphaned_images"`
+	OrphanedImageBytes *int64         `json:"orphaned_image_bytes"`
+	QueueDepth         int            `json:"queue_depth"`
+}
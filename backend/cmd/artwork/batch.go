@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+	"github.com/dmpettyp/artwork/infrastructure/inmem"
+	"github.com/dmpettyp/artwork/metrics"
+	"github.com/dmpettyp/artwork/pipeline"
+)
+
+// batchPollInterval is how often a batch run checks whether an image has
+// finished generating
+const batchPollInterval = 20 * time.Millisecond
+
+// batchImageExtensions are the input files a batch run processes
+var batchImageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// batchConfig configures a one-shot batch run
+type batchConfig struct {
+	SpecPath  string
+	InputDir  string
+	OutputDir string
+
+	// InputNode names the spec's input node that receives each image. It
+	// defaults to the spec's only input node without an image of its own.
+	InputNode string
+
+	GenerationTimeout time.Duration
+}
+
+// batchApp is the application wired for a batch run: in-memory repositories
+// and temporary image storage, with no HTTP server
+type batchApp struct {
+	messageBus      *messagebus.MessageBus
+	imageGraphViews application.ImageGraphViews
+	imageStorage    *filestorage.FilesystemImageStorage
+}
+
+// batchNotifier discards graph notifications; a batch run has no clients
+type batchNotifier struct{}
+
+func (batchNotifier) BroadcastNodeUpdate(imagegraph.ImageGraphID, any)      {}
+func (batchNotifier) BroadcastLayoutUpdate(imagegraph.ImageGraphID)         {}
+func (batchNotifier) BroadcastGraphListUpdate(imagegraph.ImageGraphID, any) {}
+
+// runBatch processes each image in a directory through a pipeline spec, one
+// at a time, writing the images of the spec's output nodes to the output
+// directory. Each image gets a fresh graph. Images that fail are logged and
+// skipped, and the run returns an error once all images have been tried.
+func runBatch(ctx context.Context, logger *slog.Logger, cfg batchConfig) error {
+	spec, err := pipeline.Load(cfg.SpecPath)
+	if err != nil {
+		return err
+	}
+
+	if err := spec.CheckNodes(); err != nil {
+		return fmt.Errorf("invalid pipeline spec %s: %w", cfg.SpecPath, err)
+	}
+
+	inputNode, err := spec.InputNode(cfg.InputNode)
+	if err != nil {
+		return err
+	}
+
+	outputNodes := 0
+	for _, node := range spec.Nodes {
+		if nodeType, _, _ := node.Parse(); nodeType == imagegraph.NodeTypeOutput {
+			outputNodes++
+		}
+	}
+	if outputNodes == 0 {
+		return fmt.Errorf("pipeline spec %s has no output nodes", cfg.SpecPath)
+	}
+
+	inputs, err := listBatchImages(cfg.InputDir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
+		return fmt.Errorf("could not create output directory: %w", err)
+	}
+
+	storageDir, err := os.MkdirTemp("", "artwork-batch-")
+	if err != nil {
+		return fmt.Errorf("could not create image storage: %w", err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	app, err := newBatchApp(logger, storageDir, cfg.GenerationTimeout)
+	if err != nil {
+		return err
+	}
+
+	go app.messageBus.Start(ctx)
+	defer app.messageBus.Stop()
+
+	logger.Info("starting batch run", "spec", cfg.SpecPath, "images", len(inputs))
+
+	failed := 0
+	for _, input := range inputs {
+		written, err := app.process(ctx, spec, inputNode, input, cfg.OutputDir, outputNodes > 1)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			failed++
+			logger.Error("could not process image", "input", input, "error", err)
+			continue
+		}
+
+		logger.Info("processed image", "input", input, "outputs", written)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed", failed, len(inputs))
+	}
+
+	logger.Info("batch run complete", "images", len(inputs), "output", cfg.OutputDir)
+
+	return nil
+}
+
+func newBatchApp(logger *slog.Logger, storageDir string, generationTimeout time.Duration) (*batchApp, error) {
+	uow, err := inmem.NewUnitOfWork()
+	if err != nil {
+		return nil, fmt.Errorf("could not create in-memory unit of work: %w", err)
+	}
+
+	imageStorage, err := filestorage.NewFilesystemImageStorage(storageDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not create image storage: %w", err)
+	}
+
+	messageBus := messagebus.New(messagebus.WithLogger(logger))
+
+	imageGen := imagegen.NewImageGen(
+		imageStorage,
+		application.NewNodeUpdater(messageBus),
+		logger,
+		metrics.NewAppMetrics().ImageGen,
+		imageGenOptions()...,
+	)
+
+	_, err = application.NewImageGraphCommandHandlers(messageBus, nil, uow)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = application.NewImageGraphEventHandlers(
+		messageBus,
+		nil,
+		nil,
+		uow,
+		imageGen,
+		imageStorage,
+		batchNotifier{},
+		logger,
+		application.WithGenerationTimeout(generationTimeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batchApp{
+		messageBus:      messageBus,
+		imageGraphViews: uow.ImageGraphViews,
+		imageStorage:    imageStorage,
+	}, nil
+}
+
+// process runs one input image through a new graph built from the spec and
+// writes its exports, returning the paths written
+func (app *batchApp) process(
+	ctx context.Context,
+	spec *pipeline.Spec,
+	inputNode string,
+	input string,
+	outputDir string,
+	suffixExports bool,
+) (
+	[]string,
+	error,
+) {
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+
+	graphID := imagegraph.MustNewImageGraphID()
+
+	nodeIDs, err := pipeline.Build(ctx, app.messageBus, spec, graphID, spec.Name+" "+base, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Images named by the spec are uploaded before the batch image, as they
+	// would be to a graph built by artworkctl
+	for _, node := range spec.Nodes {
+		if node.Image == "" {
+			continue
+		}
+		if err := app.setImage(ctx, graphID, nodeIDs[node.Name], node.ImageOutput(), node.Image); err != nil {
+			return nil, fmt.Errorf("could not set image of node %q: %w", node.Name, err)
+		}
+	}
+
+	for _, node := range spec.Nodes {
+		if node.Name != inputNode {
+			continue
+		}
+		if err := app.setImage(ctx, graphID, nodeIDs[node.Name], node.ImageOutput(), input); err != nil {
+			return nil, err
+		}
+	}
+
+	ig, err := app.wait(ctx, graphID)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	for _, node := range ig.Nodes {
+		if node.State.Get() == imagegraph.Failed {
+			failures = append(failures, fmt.Sprintf("%s: %s", node.Name, node.Error))
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return nil, fmt.Errorf("nodes failed: %s", strings.Join(failures, "; "))
+	}
+
+	exports := ig.Exports()
+	if len(exports) == 0 {
+		return nil, errors.New("no output node generated an image")
+	}
+
+	var written []string
+	for _, export := range exports {
+		data, err := app.imageStorage.Get(export.ImageID)
+		if err != nil {
+			return written, fmt.Errorf("could not read export %q: %w", export.Name, err)
+		}
+
+		// Generated images are always encoded as PNGs
+		name := base
+		if suffixExports {
+			name += "-" + strings.NewReplacer("/", "_", `\`, "_").Replace(export.Name)
+		}
+		path := filepath.Join(outputDir, name+".png")
+
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return written, fmt.Errorf("could not write export %q: %w", export.Name, err)
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
+
+// setImage stores an image file and sets it as a node output
+func (app *batchApp) setImage(
+	ctx context.Context,
+	graphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName string,
+	path string,
+) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	imageID := imagegraph.MustNewImageID()
+
+	if err := filestorage.SaveUpload(app.imageStorage, imageID, filepath.Base(path), data); err != nil {
+		return fmt.Errorf("could not save image: %w", err)
+	}
+
+	command := application.NewSetImageGraphNodeOutputImageCommand(
+		graphID,
+		nodeID,
+		imagegraph.OutputName(outputName),
+		imageID,
+		0, // allow command handler to resolve to current node version
+	)
+
+	return app.messageBus.HandleCommand(ctx, command)
+}
+
+// wait polls a graph until it has settled, returning it
+func (app *batchApp) wait(ctx context.Context, graphID imagegraph.ImageGraphID) (*imagegraph.ImageGraph, error) {
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ig, err := app.imageGraphViews.Get(ctx, graphID)
+		if err != nil {
+			return nil, err
+		}
+
+		if ig.Settled() {
+			return ig, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// listBatchImages lists the images in a directory, in name order. Outputs are
+// named after their input, so inputs may not share a name without extension.
+func listBatchImages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read input directory: %w", err)
+	}
+
+	var images []string
+	bases := make(map[string]string)
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !batchImageExtensions[strings.ToLower(ext)] {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ext)
+		if other, ok := bases[base]; ok {
+			return nil, fmt.Errorf("input images %s and %s would write the same outputs", other, entry.Name())
+		}
+		bases[base] = entry.Name()
+
+		images = append(images, filepath.Join(dir, entry.Name()))
+	}
+
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images found in %s", dir)
+	}
+
+	return images, nil
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/domain/ui"
-	"github.com/dmpettyp/dorky/messagebus"
 )
 
 func ptr[T any](v T) *T {
@@ -15,7 +14,7 @@ func ptr[T any](v T) *T {
 }
 
 // bootstrap creates a default ImageGraph extracted from the running server
-func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.MessageBus) error {
+func bootstrap(ctx context.Context, logger *slog.Logger, messageBus application.CommandHandler) error {
 	logger.Info("bootstrapping application with default ImageGraph")
 
 	// Generate IDs for the graph and nodes
@@ -33,7 +32,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 	imageID, _ := imagegraph.ParseImageID("117284ec-f712-42e9-827e-342bd61368db")
 
 	// Create the ImageGraph
-	createGraphCmd := application.NewCreateImageGraphCommand(graphID, "Default Pipeline")
+	createGraphCmd := application.NewCreateImageGraphCommand(graphID, "Default Pipeline", "", "")
 	if err := messageBus.HandleCommand(ctx, createGraphCmd); err != nil {
 		return err
 	}
@@ -46,6 +45,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeInput,
 		"",
 		imagegraph.NewNodeConfigInput(),
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addInputCmd); err != nil {
 		return err
@@ -64,6 +64,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeCrop,
 		"",
 		cropConfig,
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addCropCmd); err != nil {
 		return err
@@ -80,6 +81,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeResize,
 		"shrink",
 		resizeShrinkConfig,
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addResizeShrinkCmd); err != nil {
 		return err
@@ -95,6 +97,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeBlur,
 		"",
 		blurConfig,
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addBlurCmd); err != nil {
 		return err
@@ -111,6 +114,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeResize,
 		"grow to 500w",
 		resizeGrowConfig,
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addResizeGrowCmd); err != nil {
 		return err
@@ -126,6 +130,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeResizeMatch,
 		"",
 		resizeMatchConfig,
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addResizeMatchCmd); err != nil {
 		return err
@@ -143,6 +148,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypePixelInflate,
 		"",
 		pixelInflateConfig,
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addPixelInflateCmd); err != nil {
 		return err
@@ -156,6 +162,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeOutput,
 		"Width 500",
 		imagegraph.NewNodeConfigOutput(),
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addOutput1Cmd); err != nil {
 		return err
@@ -169,6 +176,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeOutput,
 		"Output with original size",
 		imagegraph.NewNodeConfigOutput(),
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addOutput2Cmd); err != nil {
 		return err
@@ -182,6 +190,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 		imagegraph.NodeTypeOutput,
 		"no lines",
 		imagegraph.NewNodeConfigOutput(),
+		"",
 	)
 	if err := messageBus.HandleCommand(ctx, addOutput3Cmd); err != nil {
 		return err
@@ -321,7 +330,8 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 	// Set node layout positions
 	layoutCmd := application.NewUpdateLayoutCommand(
 		graphID,
-		[]ui.NodePosition{
+		"",
+		[]ui.NodeLayout{
 			{NodeID: inputNodeID, X: -530.6755718206077, Y: 697.8155894863006},
 			{NodeID: cropNodeID, X: -203.67722892973154, Y: 467.9825097594408},
 			{NodeID: resizeShrinkNodeID, X: 88.46872385139525, Y: 140.27954065464667},
@@ -342,6 +352,7 @@ func bootstrap(ctx context.Context, logger *slog.Logger, messageBus *messagebus.
 	// Set viewport state
 	viewportCmd := application.NewUpdateViewportCommand(
 		graphID,
+		"",
 		0.7105532272722948,
 		423.4652138758026,
 		166.63734119709807,
@@ -3,26 +3,78 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dmpettyp/dorky/messagebus"
 
 	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
 	httpgateway "github.com/dmpettyp/artwork/gateways/http"
+	"github.com/dmpettyp/artwork/gateways/watchfolder"
 	"github.com/dmpettyp/artwork/infrastructure/filestorage"
 	"github.com/dmpettyp/artwork/infrastructure/imagegen"
 	"github.com/dmpettyp/artwork/infrastructure/inmem"
 	"github.com/dmpettyp/artwork/infrastructure/postgres"
+	"github.com/dmpettyp/artwork/infrastructure/webhooks"
+	"github.com/dmpettyp/artwork/logging"
 	"github.com/dmpettyp/artwork/metrics"
+	"github.com/dmpettyp/artwork/nodetypes"
+	"github.com/dmpettyp/artwork/pipeline"
+	"github.com/dmpettyp/artwork/tracing"
+	"github.com/dmpettyp/artwork/webui"
 )
 
 func main() {
 	storeBackend := flag.String("store", "postgres", "storage backend: postgres or inmem")
 	bootstrapFlag := flag.Bool("bootstrap", false, "seed a default graph on startup")
+	galleryFlag := flag.Bool("gallery", false, "serve the public gallery of graphs marked public")
+	galleryRate := flag.Int("gallery-rate", 60, "gallery requests allowed per client per minute")
+	galleryBurst := flag.Int("gallery-burst", 20, "gallery requests a client may make in a burst")
+	maxNodes := flag.Int("max-nodes", 0, "maximum nodes per graph (0 for unlimited)")
+	maxConnections := flag.Int("max-connections", 0, "maximum connections per graph (0 for unlimited)")
+	requestTimeout := flag.Duration("request-timeout", 0, "deadline for API requests, carried into the generation they trigger (0 for none)")
+	generationTimeout := flag.Duration("generation-timeout", 0, "maximum time to generate a node's outputs (0 for unlimited)")
+	decodeCacheMB := flag.Int("decode-cache-mb", 256, "megabytes of decoded images kept to reuse across the nodes an image feeds (0 to disable)")
+	maxImageSide := flag.Int("max-image-side", 20000, "maximum width or height of images decoded or uploaded (0 for unlimited)")
+	maxImageMegapixels := flag.Int("max-image-megapixels", 100, "maximum megapixels of images decoded or uploaded (0 for unlimited)")
+	pixelWorkers := flag.Int("pixel-workers", 0, "goroutines per-pixel operations are split across (0 for GOMAXPROCS)")
+	recoverAfter := flag.Duration("recover-after", 10*time.Minute, "on startup, recover nodes that have been generating for longer than this (0 to skip)")
+	recoverFail := flag.Bool("recover-fail", false, "fail recovered nodes instead of resuming their generation")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "how long shutdown waits for in-flight generation before leaving it to resume on restart")
+	frontendDir := flag.String("frontend", "", "directory to serve the UI from, or \"embedded\" for the copy built in with -tags embedfrontend (default: embedded if built in, otherwise ../frontend)")
+	usersFile := flag.String("users", "", "JSON file of users and their token hashes; enables authentication")
+	nodeTypesFile := flag.String("node-types", "", "JSON file of node types to register alongside the built-in ones")
+	corsOrigins := flag.String("cors-origins", "", "comma-separated origins allowed to call the API from a browser, or * for any")
+	corsMethods := flag.String("cors-methods", "", "comma-separated methods allowed cross-origin (default GET,POST,PUT,PATCH,DELETE)")
+	corsHeaders := flag.String("cors-headers", "", "comma-separated request headers allowed cross-origin (default Content-Type,Authorization,X-API-Key,X-Request-ID)")
+	corsMaxAge := flag.Duration("cors-max-age", 0, "how long browsers may cache CORS preflight responses (0 for the browser default)")
+	trustedProxies := flag.String("trusted-proxies", "", "comma-separated proxy IPs and CIDRs whose X-Forwarded-* headers are honored")
+	runSpec := flag.String("run", "", "pipeline spec to process -run-input's images through, then exit without serving")
+	runInput := flag.String("run-input", "", "directory of images to process with -run")
+	runOutput := flag.String("run-output", "", "directory to write the -run output images to")
+	watchDir := flag.String("watch-dir", "", "directory to watch for images to ingest")
+	watchGraph := flag.String("watch-graph", "", "graph of the -watch-node that ingested images are set on")
+	watchNode := flag.String("watch-node", "", "input node that ingested images are set on")
+	watchOutput := flag.String("watch-output", "original", "output of the -watch-node that ingested images are set on")
+	watchTemplate := flag.String("watch-template", "", "pipeline spec to instantiate a graph from for each ingested image, instead of -watch-node")
+	watchTemplateNode := flag.String("watch-template-node", "", "input node of the -watch-template that receives the image (default: its only input node without an image)")
+	watchOwner := flag.String("watch-owner", "", "user ID that owns graphs instantiated from the -watch-template")
+	watchInterval := flag.Duration("watch-interval", 2*time.Second, "how often the -watch-dir is scanned")
+	watchSettle := flag.Duration("watch-settle", 2*time.Second, "how long an image must be unchanged before it's ingested")
+	publicURL := flag.String("public-url", "http://localhost:8080", "URL the API is reachable at, for the image links in webhook payloads")
+	webhookAttempts := flag.Int("webhook-attempts", 5, "attempts to deliver each webhook notification before giving up")
+	graphQueueLimit := flag.Int("graph-queue-limit", 0, "commands and events that may wait behind the command running for each graph, holding senders back when full (0 for unlimited)")
+	graphBusyRetryAfter := flag.Duration("graph-busy-retry-after", 0, "answer API requests that find their graph's command queue full with 503 and this Retry-After, rather than holding them (0 to hold them)")
+	eventAttempts := flag.Int("event-attempts", 3, "attempts at handling an event before it is dead-lettered")
+	eventBackoff := flag.Duration("event-backoff", 100*time.Millisecond, "wait after an event handler first fails, doubling after each retry")
+	schedulerFlag := flag.Bool("scheduler", true, "regenerate graphs on their cron schedules (run on only one instance sharing a postgres store)")
+	runNode := flag.String("run-node", "", "input node of the -run spec that receives each image (default: its only input node without an image)")
 	flag.Parse()
 
 	// Set log level based on LOG_LEVEL environment variable (default: INFO)
@@ -34,17 +86,81 @@ func main() {
 		}
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	}))
+	// LOG_FORMAT=json writes a JSON object per record for log aggregators
+	logger, err := logging.NewLogger(os.Stdout, os.Getenv("LOG_FORMAT"), logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	logger.Info("this is artwork")
 
+	// Registered node types are needed by batch runs as well as the server,
+	// and must exist before any graph is loaded
+	if *nodeTypesFile != "" {
+		defs, err := nodetypes.Load(*nodeTypesFile)
+		if err != nil {
+			logger.Error("could not load node types", "error", err)
+			os.Exit(2)
+		}
+
+		registered, err := nodetypes.Register(defs)
+		if err != nil {
+			logger.Error("could not register node types", "error", err)
+			os.Exit(2)
+		}
+
+		for _, r := range registered {
+			httpgateway.RegisterNodeType(r.NodeType, r.Definition.Name, r.Definition.DisplayName, r.Definition.Category)
+		}
+
+		logger.Info("registered node types", "count", len(registered))
+	}
+
+	// Batch runs process a directory of images in memory and exit; they
+	// don't need a store or any of the servers
+	if *runSpec != "" {
+		if *runInput == "" || *runOutput == "" {
+			logger.Error("-run requires -run-input and -run-output")
+			os.Exit(2)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := runBatch(ctx, logger, batchConfig{
+			SpecPath:          *runSpec,
+			InputDir:          *runInput,
+			OutputDir:         *runOutput,
+			InputNode:         *runNode,
+			GenerationTimeout: *generationTimeout,
+		})
+		if err != nil {
+			logger.Error("batch run failed", "error", err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+
 	var (
 		uow             application.UnitOfWork
 		imageGraphViews application.ImageGraphViews
 		layoutViews     application.LayoutViews
 		viewportViews   application.ViewportViews
+		apiKeyStore     application.APIKeyStore
+		webhookStore    application.WebhookStore
+		graphLinkStore  application.GraphLinkStore
+		scheduleStore   application.ScheduleStore
+		deadLetterStore application.DeadLetterStore
+		pendingStore    application.PendingGenerationStore
+		summaryStore    application.ImageGraphSummaryStore
+		outbox          application.Outbox
+		processedEvents application.ProcessedEventStore
+		generationRuns  application.GenerationRunStore
+		snapshotStore   application.SnapshotStore
+		pipelineRuns    application.PipelineRunStore
+		statsCollector  application.StatsCollector
 	)
 
 	switch *storeBackend {
@@ -54,10 +170,24 @@ func main() {
 			logger.Error("could not create postgres db connection", "error", err)
 			return
 		}
-		uow = postgres.NewUnitOfWork(db)
+		pgOutbox := postgres.NewOutbox(db)
+		outbox = pgOutbox
+		uow = postgres.NewUnitOfWork(db, postgres.WithOutbox(pgOutbox))
 		imageGraphViews = postgres.NewImageGraphViews(db)
 		layoutViews = postgres.NewLayoutViews(db)
 		viewportViews = postgres.NewViewportViews(db)
+		apiKeyStore = postgres.NewAPIKeyStore(db)
+		webhookStore = postgres.NewWebhookStore(db)
+		graphLinkStore = postgres.NewGraphLinkStore(db)
+		scheduleStore = postgres.NewScheduleStore(db)
+		deadLetterStore = postgres.NewDeadLetterStore(db)
+		pendingStore = postgres.NewPendingGenerationStore(db)
+		summaryStore = postgres.NewImageGraphSummaryStore(db)
+		processedEvents = postgres.NewProcessedEventStore(db)
+		generationRuns = postgres.NewGenerationRunStore(db)
+		snapshotStore = postgres.NewSnapshotStore(db)
+		pipelineRuns = postgres.NewPipelineRunStore(db)
+		statsCollector = postgres.NewStatsCollector(db)
 		logger.Info("using postgres backend")
 	case "inmem":
 		inmemUOW, err := inmem.NewUnitOfWork()
@@ -69,18 +199,51 @@ func main() {
 		imageGraphViews = inmemUOW.ImageGraphViews
 		layoutViews = inmemUOW.LayoutViews
 		viewportViews = inmemUOW.ViewportViews
+		apiKeyStore = inmem.NewAPIKeyStore()
+		webhookStore = inmem.NewWebhookStore()
+		graphLinkStore = inmem.NewGraphLinkStore()
+		scheduleStore = inmem.NewScheduleStore()
+		deadLetterStore = inmem.NewDeadLetterStore()
+		pendingStore = inmem.NewPendingGenerationStore()
+		generationRuns = inmem.NewGenerationRunStore()
+		inmemSnapshots := inmem.NewSnapshotStore()
+		snapshotStore = inmemSnapshots
+		pipelineRuns = inmem.NewPipelineRunStore()
+		statsCollector = inmem.NewStatsCollector(inmemUOW.ImageGraphViews, inmemSnapshots)
 		logger.Info("using in-memory backend")
 	default:
 		logger.Error("invalid store backend", "value", *storeBackend)
 		return
 	}
 
+	// Tracing stays off unless an OTLP endpoint is configured, e.g.
+	// OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4318 for a local Jaeger
+	shutdownTracing := func(context.Context) error { return nil }
+	if tracing.Enabled() {
+		shutdown, err := tracing.Setup(context.Background(), "artwork")
+		if err != nil {
+			logger.Error("could not set up tracing", "error", err)
+			return
+		}
+		shutdownTracing = shutdown
+		logger.Info("exporting traces over OTLP")
+	}
+
 	appMetrics := metrics.NewAppMetrics()
 	messageBus := messagebus.New(
 		messagebus.WithLogger(logger),
 		messagebus.WithMetricsHook(appMetrics.MessageBus),
 	)
 
+	// Everything that sends commands for image graphs sends them through the
+	// graph queues, which order and bound them per graph. The handlers count
+	// the events they queue in them.
+	graphQueues := application.NewGraphQueues(
+		messageBus,
+		application.WithGraphQueueLimit(*graphQueueLimit),
+		application.WithGraphQueueObserver(appMetrics.MessageBus),
+	)
+
 	// Create image storage
 	imageStorage, err := filestorage.NewFilesystemImageStorage("uploads")
 
@@ -90,12 +253,49 @@ func main() {
 	}
 
 	// Create node updater for ImageGen
-	nodeUpdater := application.NewNodeUpdater(messageBus)
+	nodeUpdater := application.NewNodeUpdater(graphQueues)
+
+	// Images are rejected from their headers before decoding allocates
+	// their pixels
+	imageLimits := imagegraph.ImageLimits{
+		MaxWidth:  *maxImageSide,
+		MaxHeight: *maxImageSide,
+		MaxPixels: *maxImageMegapixels * 1_000_000,
+	}
 
 	// Create ImageGen with dependencies
-	imageGen := imagegen.NewImageGen(imageStorage, nodeUpdater, logger, appMetrics.ImageGen)
+	imageGenOpts := append(imageGenOptions(),
+		imagegen.WithDecodeCache(int64(*decodeCacheMB)<<20),
+		imagegen.WithPixelWorkers(*pixelWorkers),
+		imagegen.WithImageLimits(imageLimits),
+	)
+	imageGen := imagegen.NewImageGen(imageStorage, nodeUpdater, logger, appMetrics.ImageGen, imageGenOpts...)
 
-	_, err = application.NewImageGraphCommandHandlers(messageBus, uow)
+	// Events whose handlers keep failing are kept for admins to inspect and
+	// replay
+	deadLetters, err := application.NewDeadLetterQueue(
+		messageBus,
+		graphQueues,
+		deadLetterStore,
+		logger,
+		application.WithDeadLetterRetries(*eventAttempts, *eventBackoff),
+	)
+	if err != nil {
+		logger.Error("could not create dead letter queue", "error", err)
+		return
+	}
+
+	graphLimits := application.GraphLimits{
+		MaxNodes:       *maxNodes,
+		MaxConnections: *maxConnections,
+	}
+
+	_, err = application.NewImageGraphCommandHandlers(
+		messageBus,
+		graphQueues,
+		uow,
+		application.WithGraphLimits(graphLimits),
+	)
 
 	if err != nil {
 		logger.Error("could not create image graph command handlers", "error", err)
@@ -105,12 +305,19 @@ func main() {
 	// Create notifier for real-time graph updates
 	notifier := httpgateway.NewImageGraphNotifier(logger)
 
-	_, err = application.NewImageGraphEventHandlers(
+	imageGraphEventHandlers, err := application.NewImageGraphEventHandlers(
 		messageBus,
+		graphQueues,
+		deadLetters,
 		uow,
 		imageGen,
 		imageStorage,
 		notifier,
+		logger,
+		application.WithGenerationTimeout(*generationTimeout),
+		// Only the postgres outbox redelivers events
+		application.WithProcessedEvents(processedEvents),
+		application.WithGenerationRuns(generationRuns),
 	)
 
 	if err != nil {
@@ -118,36 +325,161 @@ func main() {
 		return
 	}
 
-	_, err = application.NewLayoutCommandHandlers(messageBus, uow)
+	propagationLatency := application.NewPropagationLatencyTracker(appMetrics.Propagation)
+
+	_, err = application.NewPropagationLatencyEventHandlers(messageBus, graphQueues, deadLetters, propagationLatency)
+
+	if err != nil {
+		logger.Error("could not create propagation latency event handlers", "error", err)
+		return
+	}
+
+	_, err = application.NewLayoutCommandHandlers(messageBus, graphQueues, uow)
 
 	if err != nil {
 		logger.Error("could not create layout command handlers", "error", err)
 		return
 	}
 
-	_, err = application.NewLayoutEventHandlers(messageBus, notifier)
+	_, err = application.NewLayoutEventHandlers(messageBus, graphQueues, deadLetters, notifier)
 
 	if err != nil {
 		logger.Error("could not create layout event handlers", "error", err)
 		return
 	}
 
-	_, err = application.NewViewportCommandHandlers(messageBus, uow)
+	_, err = application.NewViewportCommandHandlers(messageBus, graphQueues, uow)
 
 	if err != nil {
 		logger.Error("could not create viewport command handlers", "error", err)
 		return
 	}
 
+	webhookDispatcher := webhooks.NewDispatcher(
+		logger,
+		*publicURL,
+		webhooks.WithRetries(*webhookAttempts, time.Second),
+	)
+
+	_, err = application.NewWebhookEventHandlers(messageBus, graphQueues, deadLetters, webhookStore, imageGraphViews, webhookDispatcher)
+
+	if err != nil {
+		logger.Error("could not create webhook event handlers", "error", err)
+		return
+	}
+
+	_, err = application.NewGraphLinkEventHandlers(messageBus, graphQueues, deadLetters, uow, graphLinkStore, imageStorage)
+
+	if err != nil {
+		logger.Error("could not create graph link event handlers", "error", err)
+		return
+	}
+
+	_, err = application.NewPipelineRunEventHandlers(messageBus, graphQueues, deadLetters, pipelineRuns, imageGraphViews)
+
+	if err != nil {
+		logger.Error("could not create pipeline run event handlers", "error", err)
+		return
+	}
+
+	// Events committed with postgres are published by the outbox relay, which
+	// also publishes those a crash left unpublished
+	var outboxRelay *application.OutboxRelay
+	if outbox != nil {
+		outboxRelay, err = application.NewOutboxRelay(messageBus, graphQueues, outbox, logger)
+
+		if err != nil {
+			logger.Error("could not create outbox relay", "error", err)
+			return
+		}
+	}
+
+	// The in-memory backend lists graphs straight from the repository, so
+	// only postgres keeps summaries for listing up to date
+	if summaryStore != nil {
+		_, err = application.NewImageGraphSummaryEventHandlers(messageBus, graphQueues, deadLetters, imageGraphViews, summaryStore)
+
+		if err != nil {
+			logger.Error("could not create image graph summary event handlers", "error", err)
+			return
+		}
+	}
+
+	serverOpts := []httpgateway.ServerOption{
+		httpgateway.WithPropagationLatencyReporter(propagationLatency),
+		httpgateway.WithImageComparer(imageGen),
+		httpgateway.WithGenerationTimingReporter(imageGen),
+		httpgateway.WithPreviewGenerator(imageGen),
+		httpgateway.WithGraphLimits(graphLimits),
+		httpgateway.WithImageLimits(imageLimits),
+		httpgateway.WithRequestTimeout(*requestTimeout),
+		httpgateway.WithWebhooks(webhookStore),
+		httpgateway.WithGraphLinks(graphLinkStore),
+		httpgateway.WithSchedules(scheduleStore),
+		httpgateway.WithDeadLetters(deadLetters),
+		httpgateway.WithGenerationRuns(generationRuns),
+		httpgateway.WithSnapshots(snapshotStore),
+		httpgateway.WithPipelineRuns(pipelineRuns),
+		httpgateway.WithStats(statsCollector),
+	}
+
+	if *graphBusyRetryAfter > 0 {
+		serverOpts = append(serverOpts, httpgateway.WithBusyRejection(*graphBusyRetryAfter))
+	}
+	if *galleryFlag {
+		serverOpts = append(serverOpts, httpgateway.WithGallery(*galleryRate, *galleryBurst))
+	}
+
+	if *corsOrigins != "" {
+		serverOpts = append(serverOpts, httpgateway.WithCORS(httpgateway.CORSConfig{
+			AllowedOrigins: splitList(*corsOrigins),
+			AllowedMethods: splitList(*corsMethods),
+			AllowedHeaders: splitList(*corsHeaders),
+			MaxAge:         *corsMaxAge,
+		}))
+	}
+
+	switch embedded, ok := webui.FS(); {
+	case *frontendDir == "embedded" && !ok:
+		logger.Error("-frontend=embedded needs a binary built with -tags embedfrontend")
+		return
+	case *frontendDir == "embedded", *frontendDir == "" && ok:
+		serverOpts = append(serverOpts, httpgateway.WithFrontend(embedded))
+	case *frontendDir != "":
+		serverOpts = append(serverOpts, httpgateway.WithFrontend(os.DirFS(*frontendDir)))
+	}
+
+	if *trustedProxies != "" {
+		proxies, err := httpgateway.ParseTrustedProxies(*trustedProxies)
+		if err != nil {
+			logger.Error("invalid trusted proxies", "error", err)
+			return
+		}
+		serverOpts = append(serverOpts, httpgateway.WithTrustedProxies(proxies))
+	}
+
+	if *usersFile != "" {
+		authenticator, err := httpgateway.LoadTokenAuthenticator(*usersFile)
+		if err != nil {
+			logger.Error("could not load users", "error", err)
+			return
+		}
+		serverOpts = append(serverOpts,
+			httpgateway.WithAuthenticator(authenticator),
+			httpgateway.WithAPIKeys(apiKeyStore, authenticator),
+		)
+	}
+
 	httpServer := httpgateway.NewHTTPServer(
 		logger,
-		messageBus,
+		graphQueues,
 		imageGraphViews,
 		layoutViews,
 		viewportViews,
 		imageStorage,
 		notifier,
 		appMetrics,
+		serverOpts...,
 	)
 
 	httpServer.Start()
@@ -162,11 +494,89 @@ func main() {
 		metrics.NewMetricsHandler(appMetrics),
 	)
 
+	webhookDispatcher.Start()
+
 	go messageBus.Start(context.Background())
 
+	if outboxRelay != nil {
+		outboxRelay.Start()
+	}
+
+	// Resume the generation the last shutdown didn't wait for
+	resumed, err := application.ResumePendingGenerations(context.Background(), graphQueues, pendingStore)
+	if err != nil {
+		logger.Error("could not resume all pending generation", "error", err)
+	}
+	if resumed > 0 {
+		logger.Info("resumed pending generation", "nodes", resumed)
+	}
+
+	// Recover generation lost to a crash, which leaves nodes generating
+	// forever. Other instances sharing the store may still be generating
+	// recently changed nodes, so only nodes older than -recover-after count.
+	if *recoverAfter > 0 {
+		recovered, err := application.RecoverStuckGenerations(
+			context.Background(),
+			graphQueues,
+			imageGraphViews,
+			time.Now().Add(-*recoverAfter),
+			*recoverFail,
+		)
+		if err != nil {
+			logger.Error("could not recover all stuck generation", "error", err)
+		}
+		if recovered > 0 {
+			logger.Info("recovered stuck generation", "nodes", recovered, "failed", *recoverFail)
+		}
+	}
+
+	var watcher *watchfolder.Watcher
+	if *watchDir != "" {
+		watchConfig := watchfolder.Config{
+			Dir:          *watchDir,
+			Output:       imagegraph.OutputName(*watchOutput),
+			TemplateNode: *watchTemplateNode,
+			Owner:        *watchOwner,
+			PollInterval: *watchInterval,
+			SettleTime:   *watchSettle,
+		}
+
+		if *watchGraph != "" {
+			if watchConfig.GraphID, err = imagegraph.ParseImageGraphID(*watchGraph); err != nil {
+				logger.Error("invalid -watch-graph", "error", err)
+				return
+			}
+		}
+		if *watchNode != "" {
+			if watchConfig.NodeID, err = imagegraph.ParseNodeID(*watchNode); err != nil {
+				logger.Error("invalid -watch-node", "error", err)
+				return
+			}
+		}
+		if *watchTemplate != "" {
+			if watchConfig.Template, err = pipeline.Load(*watchTemplate); err != nil {
+				logger.Error("could not load watch template", "error", err)
+				return
+			}
+		}
+
+		watcher, err = watchfolder.NewWatcher(logger, graphQueues, imageStorage, watchConfig)
+		if err != nil {
+			logger.Error("could not create folder watcher", "error", err)
+			return
+		}
+		watcher.Start()
+	}
+
+	var scheduler *application.Scheduler
+	if *schedulerFlag {
+		scheduler = application.NewScheduler(graphQueues, scheduleStore, imageGraphViews, logger)
+		scheduler.Start()
+	}
+
 	// Bootstrap the application with default ImageGraph if requested
 	if *bootstrapFlag {
-		if err := bootstrap(context.Background(), logger, messageBus); err != nil {
+		if err := bootstrap(context.Background(), logger, graphQueues); err != nil {
 			logger.Error("bootstrap failed", "error", err)
 			return
 		}
@@ -178,17 +588,88 @@ func main() {
 
 	logger.Info("shutting down gracefully...")
 
-	messageBus.Stop()
-
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Leave time to stop everything else after draining generation
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *drainTimeout+5*time.Second)
 	defer cancel()
 
+	// Stop ingesting before the message bus stops handling commands
+	if watcher != nil {
+		if err := watcher.Stop(shutdownCtx); err != nil {
+			logger.Error("error stopping folder watcher", "error", err)
+		}
+	}
+	if scheduler != nil {
+		if err := scheduler.Stop(shutdownCtx); err != nil {
+			logger.Error("error stopping scheduler", "error", err)
+		}
+	}
+
+	// Stop accepting requests, then give in-flight generation a chance to
+	// finish while the message bus is still running to record its outputs
 	if err := httpServer.Stop(shutdownCtx); err != nil {
 		logger.Error("error stopping HTTP server", "error", err)
 	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), *drainTimeout)
+	pending := imageGraphEventHandlers.Drain(drainCtx)
+	cancelDrain()
+
+	if len(pending) > 0 {
+		if err := pendingStore.Add(shutdownCtx, pending); err != nil {
+			logger.Error("could not record pending generation", "error", err)
+		} else {
+			logger.Info("generation will resume on restart", "nodes", len(pending))
+		}
+	}
+
+	// Events committed after the relay stops are published on restart
+	if outboxRelay != nil {
+		if err := outboxRelay.Stop(shutdownCtx); err != nil {
+			logger.Error("error stopping outbox relay", "error", err)
+		}
+	}
+
+	messageBus.Stop()
+
+	if err := webhookDispatcher.Stop(shutdownCtx); err != nil {
+		logger.Error("error stopping webhook dispatcher", "error", err)
+	}
 	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
 		logger.Error("error stopping metrics server", "error", err)
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("error flushing traces", "error", err)
+	}
 
 	logger.Info("shutdown complete")
 }
+
+// imageGenOptions enables the external providers used by generate nodes
+// that are configured in the environment
+func imageGenOptions() []imagegen.Option {
+	var opts []imagegen.Option
+	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+		opts = append(opts, imagegen.WithOpenAI(apiKey))
+	}
+	if apiKey := os.Getenv("STABILITY_API_KEY"); apiKey != "" {
+		opts = append(opts, imagegen.WithStability(apiKey))
+	}
+	if comfyURL := os.Getenv("COMFYUI_URL"); comfyURL != "" {
+		opts = append(opts, imagegen.WithComfyUI(comfyURL))
+	}
+	if upscalerURL := os.Getenv("UPSCALER_URL"); upscalerURL != "" {
+		opts = append(opts, imagegen.WithUpscaler(upscalerURL))
+	}
+	return opts
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dmpettyp/artwork/client"
+	"github.com/dmpettyp/artwork/pipeline"
+)
+
+// pollInterval is how often wait checks whether a graph has finished
+// generating
+const pollInterval = 500 * time.Millisecond
+
+func runList(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error {
+	tag := flags.String("tag", "", "only list graphs with this tag")
+	flags.Parse(args)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTAGS\tUPDATED")
+
+	for offset := 0; ; {
+		list, err := c.ListImageGraphs(ctx, client.ListImageGraphsOptions{Tag: *tag, Sort: "name", Limit: 500, Offset: offset})
+		if err != nil {
+			return err
+		}
+
+		for _, graph := range list.ImageGraphs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				graph.ID, graph.Name, strings.Join(graph.Tags, ","), graph.UpdatedAt.Local().Format(time.DateTime))
+		}
+
+		offset += len(list.ImageGraphs)
+		if len(list.ImageGraphs) == 0 || offset >= list.Total {
+			break
+		}
+	}
+
+	return w.Flush()
+}
+
+func runCreate(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error {
+	name := flags.String("name", "", "graph name")
+	externalID := flags.String("external-id", "", "ID the graph can be looked up by")
+	flags.Parse(args)
+
+	if *name == "" {
+		return usageError("-name is required")
+	}
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: *name, ExternalID: *externalID})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(graphID)
+	return nil
+}
+
+func runApply(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error {
+	wait := flags.Bool("wait", false, "wait for the graph to finish generating")
+	timeout := flags.Duration("timeout", 10*time.Minute, "how long to wait for generation")
+	out := flags.String("out", "", "download the output images to this directory (implies -wait)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return usageError("a pipeline spec is required")
+	}
+
+	spec, err := pipeline.Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	graphID, err := applyPipeline(ctx, c, spec)
+	if err != nil {
+		if graphID != "" {
+			return fmt.Errorf("%w (graph %s was left partially built)", err, graphID)
+		}
+		return err
+	}
+
+	fmt.Println(graphID)
+
+	if !*wait && *out == "" {
+		return nil
+	}
+
+	if err := waitForGeneration(ctx, c, graphID, *timeout); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return nil
+	}
+
+	return downloadOutputs(ctx, c, graphID, *out)
+}
+
+func runUpload(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error {
+	graphID := flags.String("graph", "", "graph ID")
+	node := flags.String("node", "", "node ID, external ID or name")
+	output := flags.String("output", "original", "node output to set")
+	flags.Parse(args)
+
+	if *graphID == "" || *node == "" {
+		return usageError("-graph and -node are required")
+	}
+	if flags.NArg() != 1 {
+		return usageError("an image file is required")
+	}
+
+	graph, err := c.GetImageGraph(ctx, *graphID)
+	if err != nil {
+		return err
+	}
+
+	nodeID, err := resolveNode(graph, *node)
+	if err != nil {
+		return err
+	}
+
+	return uploadImageFile(ctx, c, *graphID, nodeID, *output, flags.Arg(0))
+}
+
+func runWait(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error {
+	graphID := flags.String("graph", "", "graph ID")
+	timeout := flags.Duration("timeout", 10*time.Minute, "how long to wait")
+	flags.Parse(args)
+
+	if *graphID == "" {
+		return usageError("-graph is required")
+	}
+
+	return waitForGeneration(ctx, c, *graphID, *timeout)
+}
+
+func runDownload(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error {
+	graphID := flags.String("graph", "", "graph ID")
+	out := flags.String("out", ".", "directory to write the images to")
+	flags.Parse(args)
+
+	if *graphID == "" {
+		return usageError("-graph is required")
+	}
+
+	return downloadOutputs(ctx, c, *graphID, *out)
+}
+
+// resolveNode finds a node of the graph by ID, external ID or unique name
+func resolveNode(graph *client.ImageGraph, ref string) (string, error) {
+	var byName []string
+
+	for _, node := range graph.Nodes {
+		if node.ID == ref || (node.ExternalID != "" && node.ExternalID == ref) {
+			return node.ID, nil
+		}
+		if node.Name == ref {
+			byName = append(byName, node.ID)
+		}
+	}
+
+	switch len(byName) {
+	case 0:
+		return "", fmt.Errorf("graph %s has no node %q", graph.ID, ref)
+	case 1:
+		return byName[0], nil
+	default:
+		return "", fmt.Errorf("graph %s has %d nodes named %q; use a node ID", graph.ID, len(byName), ref)
+	}
+}
+
+// waitForGeneration polls a graph until no node is generating or about to,
+// failing if any node failed
+func waitForGeneration(ctx context.Context, c *client.Client, graphID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			return err
+		}
+
+		if !generationPending(graph) {
+			return generationFailures(graph)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("graph %s is still generating: %w", graphID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// generationPending reports whether any node is generating, is waiting with
+// all of its inputs set and so is about to, or has an output image that
+// hasn't reached a connected input yet. Nodes without inputs, and nodes
+// waiting on an input that isn't set, never start by themselves.
+func generationPending(graph *client.ImageGraph) bool {
+	for _, node := range graph.Nodes {
+		switch node.State {
+		case "generating":
+			return true
+		case "waiting":
+			if len(node.Inputs) > 0 && inputsSet(node) {
+				return true
+			}
+		}
+
+		for _, output := range node.Outputs {
+			if output.ImageID == "" {
+				continue
+			}
+			for _, connection := range output.Connections {
+				if inputImage(graph, connection) != output.ImageID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func inputImage(graph *client.ImageGraph, connection client.OutputConnection) string {
+	node, ok := graph.Node(connection.NodeID)
+	if !ok {
+		return ""
+	}
+	for _, input := range node.Inputs {
+		if input.Name == connection.InputName {
+			return input.ImageID
+		}
+	}
+	return ""
+}
+
+func inputsSet(node client.Node) bool {
+	for _, input := range node.Inputs {
+		if !input.Optional && input.ImageID == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func generationFailures(graph *client.ImageGraph) error {
+	var failures []string
+	for _, node := range graph.Nodes {
+		if node.State == "failed" {
+			failures = append(failures, fmt.Sprintf("%s: %s", node.Name, node.Error))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("graph %s has failed nodes:\n  %s", graph.ID, strings.Join(failures, "\n  "))
+}
+
+// downloadOutputs writes the images of a graph's output nodes to a
+// directory, named after the output nodes as in the exports archive
+func downloadOutputs(ctx context.Context, c *client.Client, graphID, dir string) error {
+	data, err := c.DownloadExportsArchive(ctx, graphID)
+	if err != nil {
+		return err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("could not read exports archive: %w", err)
+	}
+
+	if len(archive.File) == 0 {
+		return fmt.Errorf("graph %s has no generated outputs", graphID)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, file := range archive.File {
+		path := filepath.Join(dir, filepath.Base(file.Name))
+		if err := extractFile(file, path); err != nil {
+			return fmt.Errorf("could not write %s: %w", path, err)
+		}
+		fmt.Println(path)
+	}
+
+	return nil
+}
+
+func extractFile(file *zip.File, path string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
@@ -0,0 +1,114 @@
+// artworkctl manages artwork image graphs from the command line, for CI and
+// batch jobs that don't use the web UI:
+//
+//	artworkctl apply -wait -out dist pipeline.yaml
+//
+// Run artworkctl -h for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dmpettyp/artwork/client"
+)
+
+// command is an artworkctl subcommand
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, c *client.Client, flags *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"list", "[-tag TAG]", "list graphs", runList},
+	{"create", "-name NAME [-external-id ID]", "create an empty graph and print its ID", runCreate},
+	{"apply", "[-wait] [-timeout D] [-out DIR] SPEC", "build a graph from a YAML or JSON pipeline spec and print its ID", runApply},
+	{"upload", "-graph ID -node NODE [-output NAME] FILE", "upload an image to a node output", runUpload},
+	{"wait", "-graph ID [-timeout D]", "wait until a graph has finished generating", runWait},
+	{"download", "-graph ID [-out DIR]", "download the images of a graph's output nodes", runDownload},
+}
+
+func main() {
+	server := flag.String("server", envOr("ARTWORK_SERVER", "http://localhost:8080"), "artwork server URL (env ARTWORK_SERVER)")
+	token := flag.String("token", os.Getenv("ARTWORK_TOKEN"), "access token (env ARTWORK_TOKEN)")
+	apiKey := flag.String("api-key", os.Getenv("ARTWORK_API_KEY"), "API key, used instead of a token (env ARTWORK_API_KEY)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flag.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "artworkctl: unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	var opts []client.Option
+	if *apiKey != "" {
+		opts = append(opts, client.WithAPIKey(*apiKey))
+	} else if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: artworkctl %s %s\n\n%s\n\n", cmd.name, cmd.args, cmd.summary)
+		flags.PrintDefaults()
+	}
+
+	err := cmd.run(ctx, client.New(*server, opts...), flags, flag.Args()[1:])
+
+	var usageErr usageError
+	switch {
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "artworkctl %s: %v\n\n", cmd.name, err)
+		flags.Usage()
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "artworkctl %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: artworkctl [flags] COMMAND [command flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// usageError is returned by commands called with missing or invalid
+// arguments
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dmpettyp/artwork/client"
+	"github.com/dmpettyp/artwork/pipeline"
+)
+
+// applyPipeline creates the spec's image graph, returning its ID. Images are
+// uploaded last, once the graph is connected, so each generates once.
+func applyPipeline(ctx context.Context, c *client.Client, spec *pipeline.Spec) (string, error) {
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: spec.Name, ExternalID: spec.ExternalID})
+	if err != nil {
+		return "", fmt.Errorf("could not create graph %q: %w", spec.Name, err)
+	}
+
+	for _, tag := range spec.Tags {
+		if err := c.AddImageGraphTag(ctx, graphID, tag); err != nil {
+			return graphID, fmt.Errorf("could not tag graph with %q: %w", tag, err)
+		}
+	}
+
+	if len(spec.Parameters) > 0 {
+		if err := c.SetParameters(ctx, graphID, spec.Parameters); err != nil {
+			return graphID, fmt.Errorf("could not set graph parameters: %w", err)
+		}
+	}
+
+	if spec.Seed != nil {
+		if err := c.SetSeed(ctx, graphID, spec.Seed); err != nil {
+			return graphID, fmt.Errorf("could not set graph seed: %w", err)
+		}
+	}
+
+	nodeIDs := map[string]string{}
+	for _, node := range spec.Nodes {
+		nodeID, err := c.AddNode(ctx, graphID, client.NewNode{
+			Name:       node.Name,
+			Type:       node.Type,
+			Config:     node.Config,
+			ExternalID: node.ExternalID,
+		})
+		if err != nil {
+			return graphID, fmt.Errorf("could not add node %q: %w", node.Name, err)
+		}
+		nodeIDs[node.Name] = nodeID
+
+		if node.Description != "" {
+			err := c.UpdateNode(ctx, graphID, nodeID, client.NodeUpdate{Description: &node.Description})
+			if err != nil {
+				return graphID, fmt.Errorf("could not describe node %q: %w", node.Name, err)
+			}
+		}
+
+		if len(node.Expressions) > 0 {
+			err := c.UpdateNode(ctx, graphID, nodeID, client.NodeUpdate{Expressions: node.Expressions})
+			if err != nil {
+				return graphID, fmt.Errorf("could not set expressions of node %q: %w", node.Name, err)
+			}
+		}
+	}
+
+	for _, connection := range spec.Connections {
+		fromNode, output, _ := pipeline.SplitPort(connection.From)
+		toNode, input, _ := pipeline.SplitPort(connection.To)
+
+		err := c.ConnectNodes(ctx, graphID, client.Connection{
+			FromNodeID: nodeIDs[fromNode],
+			OutputName: output,
+			ToNodeID:   nodeIDs[toNode],
+			InputName:  input,
+			Transform:  connection.Transform,
+		})
+		if err != nil {
+			return graphID, fmt.Errorf("could not connect %s to %s: %w", connection.From, connection.To, err)
+		}
+	}
+
+	for _, node := range spec.Nodes {
+		if node.Image == "" {
+			continue
+		}
+
+		if err := uploadImageFile(ctx, c, graphID, nodeIDs[node.Name], node.ImageOutput(), node.Image); err != nil {
+			return graphID, fmt.Errorf("could not upload image to node %q: %w", node.Name, err)
+		}
+	}
+
+	return graphID, nil
+}
+
+func uploadImageFile(ctx context.Context, c *client.Client, graphID, nodeID, output, path string) error {
+	image, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.UploadOutputImage(ctx, graphID, nodeID, output, filepath.Base(path), image)
+	return err
+}
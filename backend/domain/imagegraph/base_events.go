@@ -7,19 +7,26 @@ import (
 // Base event type that all ImageGraph domain events extend
 type ImageGraphEvent struct {
 	messages.BaseEvent
+	EventID           EventID           `json:"event_id"`
 	ImageGraphID      ImageGraphID      `json:"image_graph_id"`
 	ImageGraphVersion ImageGraphVersion `json:"image_graph_version"`
 }
 
 func (e *ImageGraphEvent) applyImageGraph(ig *ImageGraph) {
+	e.EventID = MustNewEventID()
 	e.ImageGraphID = ig.ID
 	e.ImageGraphVersion = ig.Version.Next()
+	ig.UpdatedAt = e.GetTimestamp()
 }
 
 func (e *ImageGraphEvent) GetAggregateVersion() int64 {
 	return int64(e.ImageGraphVersion)
 }
 
+func (e *ImageGraphEvent) GetEventID() EventID {
+	return e.EventID
+}
+
 type Event interface {
 	messages.Event
 	applyImageGraph(ig *ImageGraph)
@@ -39,4 +46,5 @@ func (e *NodeEvent) applyNode(n *Node) {
 	e.NodeType = n.Type
 	e.NodeState = n.State.Get()
 	e.NodeVersion = n.Version.Next()
+	n.UpdatedAt = e.GetTimestamp()
 }
@@ -0,0 +1,39 @@
+package imagegraph
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Color management settings control what happens to the ICC profiles
+// embedded in source images when an ImageGraph's Output nodes encode their
+// final images. Intermediate images always keep the profile of the image
+// they were made from.
+const (
+	// ColorManagementPreserve embeds the source profile in final images
+	ColorManagementPreserve = "preserve"
+
+	// ColorManagementStrip drops the source profile, leaving the colors to
+	// be read as sRGB
+	ColorManagementStrip = "strip"
+
+	// ColorManagementConvertSRGB converts the colors of final images from
+	// the source profile to sRGB and drops the profile
+	ColorManagementConvertSRGB = "convert_srgb"
+)
+
+// ColorManagementOptions lists the valid color management settings
+var ColorManagementOptions = []string{
+	ColorManagementPreserve,
+	ColorManagementStrip,
+	ColorManagementConvertSRGB,
+}
+
+// ValidateColorManagement checks that a color management setting is one of
+// the ColorManagementOptions
+func ValidateColorManagement(mode string) error {
+	if !slices.Contains(ColorManagementOptions, mode) {
+		return fmt.Errorf("color management must be one of %v, got %q", ColorManagementOptions, mode)
+	}
+	return nil
+}
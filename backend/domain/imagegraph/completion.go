@@ -0,0 +1,74 @@
+package imagegraph
+
+import "time"
+
+// Settled reports whether the ImageGraph has stopped changing by itself: no
+// node is generating, and every node output's image, or lack of one, has
+// reached the inputs it's connected to. Output images are propagated by
+// event handlers after they're set or unset, so until then a downstream node
+// may still be showing the results of an older image.
+//
+// Input nodes are ignored; their images are uploaded rather than generated,
+// and one without an image waits in the Generating state for an upload.
+func (ig *ImageGraph) Settled() bool {
+	for _, node := range ig.Nodes {
+		if node.Type != NodeTypeInput && node.State.Get() == Generating {
+			return false
+		}
+
+		for _, output := range node.Outputs {
+			for connection := range output.Connections {
+				target, ok := ig.Nodes.Get(connection.NodeID)
+				if !ok {
+					continue
+				}
+
+				input, err := target.Inputs.Get(connection.InputName)
+				if err != nil || input.ImageID != output.ImageID {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// PipelineComplete reports whether the ImageGraph has settled with every
+// Output node generated, so its exports are the final images of the current
+// inputs. An ImageGraph without Output nodes is never complete.
+func (ig *ImageGraph) PipelineComplete() bool {
+	outputs := 0
+
+	for _, node := range ig.Nodes {
+		if node.Type != NodeTypeOutput {
+			continue
+		}
+
+		if node.State.Get() != Generated {
+			return false
+		}
+
+		outputs++
+	}
+
+	return outputs > 0 && ig.Settled()
+}
+
+// GeneratingSince returns the nodes that have been generating their outputs
+// without any change since before the cutoff. Their generation is presumed
+// lost, e.g. to a crash of the process that was running it. Input nodes
+// waiting for an upload are ignored.
+func (ig *ImageGraph) GeneratingSince(cutoff time.Time) []*Node {
+	var nodes []*Node
+
+	for _, node := range ig.Nodes {
+		if node.Type != NodeTypeInput &&
+			node.State.Get() == Generating &&
+			node.UpdatedAt.Before(cutoff) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes
+}
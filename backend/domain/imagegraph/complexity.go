@@ -0,0 +1,28 @@
+package imagegraph
+
+// Complexity counts the parts of an ImageGraph that make it more expensive
+// to store and regenerate
+type Complexity struct {
+	Nodes              int
+	Connections        int
+	PendingGenerations int
+}
+
+// Complexity returns the current node, connection and pending generation
+// counts of the ImageGraph. Pending generations are nodes that are waiting
+// for their outputs to be generated.
+func (ig *ImageGraph) Complexity() Complexity {
+	c := Complexity{Nodes: len(ig.Nodes)}
+
+	for _, node := range ig.Nodes {
+		for _, output := range node.Outputs {
+			c.Connections += len(output.Connections)
+		}
+
+		if node.State.Get() == Generating {
+			c.PendingGenerations++
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,173 @@
+package imagegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// ConfigExpressions maps numeric config fields of a node to expressions
+// that compute their values from the sizes of the node's input images when
+// it generates, e.g. "width": "input.width / 2". Expressions refer to
+// "<input>.width" and "<input>.height" for each of the node's inputs,
+// "input.width" and "input.height" for its primary input, and the graph's
+// parameters by name.
+type ConfigExpressions map[string]string
+
+// expressionInput is the name expressions use for a node's primary input
+const expressionInput = "input"
+
+// expressionProperties are the properties of input images expressions can
+// refer to
+var expressionProperties = []string{"width", "height"}
+
+// Validate checks that each expression parses, sets a numeric field of the
+// node's config and refers only to the sizes of the node's inputs and the
+// given graph parameters
+func (exprs ConfigExpressions) Validate(n *Node, params GraphParameters) error {
+	schema := NewNodeConfig(n.Type).Schema()
+
+	for _, field := range slices.Sorted(maps.Keys(exprs)) {
+		i := slices.IndexFunc(schema, func(f FieldSchema) bool { return f.Name == field })
+		if i < 0 {
+			return fmt.Errorf("%w: %s is not a config field", ErrInvalidExpression, field)
+		}
+		if schema[i].Type != FieldTypeInt && schema[i].Type != FieldTypeFloat {
+			return fmt.Errorf("%w: %s is not a numeric config field", ErrInvalidExpression, field)
+		}
+
+		expr, err := ParseExpression(exprs[field])
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+
+		for _, name := range expr.Variables() {
+			if isParameterVariable(name) {
+				if _, ok := params[name]; !ok {
+					return fmt.Errorf(
+						"%s: %w: %s is not a graph parameter",
+						field, ErrInvalidExpression, name,
+					)
+				}
+				continue
+			}
+			if !n.hasExpressionVariable(name) {
+				return fmt.Errorf(
+					"%s: %w: %s is not the width or height of an input",
+					field, ErrInvalidExpression, name,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isParameterVariable reports whether an expression variable names a graph
+// parameter rather than a property of an input, which always has a dot
+func isParameterVariable(name string) bool {
+	return !strings.Contains(name, ".")
+}
+
+// hasExpressionVariable reports whether name is the width or height of one
+// of the node's inputs
+func (n *Node) hasExpressionVariable(name string) bool {
+	input, property, ok := strings.Cut(name, ".")
+	if !ok || !slices.Contains(expressionProperties, property) {
+		return false
+	}
+	if input == expressionInput {
+		return NodeTypeDefs[n.Type].PrimaryInput() != ""
+	}
+	return n.HasInput(InputName(input))
+}
+
+// ExpressionVariables names the sizes of a node's input images and the
+// graph's parameters for its expressions. Inputs whose size isn't known are
+// left out.
+func ExpressionVariables(
+	nodeType NodeType,
+	sizes map[InputName]ImageSize,
+	params GraphParameters,
+) map[string]float64 {
+	vars := make(map[string]float64, 2*len(sizes)+2+len(params))
+	maps.Copy(vars, params)
+
+	add := func(input string, size ImageSize) {
+		vars[input+".width"] = float64(size.Width)
+		vars[input+".height"] = float64(size.Height)
+	}
+
+	for name, size := range sizes {
+		add(string(name), size)
+	}
+	if size, ok := sizes[NodeTypeDefs[nodeType].PrimaryInput()]; ok {
+		add(expressionInput, size)
+	}
+
+	return vars
+}
+
+// Resolve returns a copy of config with each field that has an expression
+// set to the expression's value, rounded for integer fields. The resolved
+// config must be valid.
+func (exprs ConfigExpressions) Resolve(config NodeConfig, vars map[string]float64) (NodeConfig, error) {
+	if len(exprs) == 0 {
+		return config, nil
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve config expressions: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("could not resolve config expressions: %w", err)
+	}
+
+	for _, field := range config.Schema() {
+		source, ok := exprs[field.Name]
+		if !ok {
+			continue
+		}
+
+		expr, err := ParseExpression(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Name, err)
+		}
+
+		value, err := expr.Eval(vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s = %s: %w", field.Name, source, err)
+		}
+
+		if field.Type == FieldTypeInt {
+			value = math.Round(value)
+		}
+
+		fields[field.Name], err = json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s = %s: %w", field.Name, source, err)
+		}
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve config expressions: %w", err)
+	}
+
+	resolved := NewNodeConfig(config.NodeType())
+	if err := json.Unmarshal(data, resolved); err != nil {
+		return nil, fmt.Errorf("could not resolve config expressions: %w", err)
+	}
+
+	if err := resolved.Validate(); err != nil {
+		return nil, fmt.Errorf("config computed from expressions is invalid: %w", err)
+	}
+
+	return resolved, nil
+}
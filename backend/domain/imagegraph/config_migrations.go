@@ -0,0 +1,72 @@
+package imagegraph
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ConfigMigration upgrades a node config, as decoded from JSON, from one
+// version of its node type's config schema to the next, such as by renaming
+// a field or giving a new required field a value. Warnings describe changes
+// the graph's author should check, and are reported by diagnostics.
+type ConfigMigration func(config map[string]any) (warnings []string, err error)
+
+// ConfigVersion is the current version of the node type's config schema,
+// which configs are stored with
+func (def NodeTypeDef) ConfigVersion() int {
+	return len(def.ConfigMigrations) + 1
+}
+
+// DecodeNodeConfig decodes a node config stored at the given version of its
+// node type's config schema, migrating it to the current version first.
+// Configs stored before schemas were versioned are at version 1.
+func DecodeNodeConfig(nodeType NodeType, version int, data []byte) (NodeConfig, []string, error) {
+	def, ok := NodeTypeDefs[nodeType]
+	if !ok || def.NewConfig == nil {
+		return nil, nil, fmt.Errorf("no config for node type %q", NodeTypeMapper.FromWithDefault(nodeType, "unknown"))
+	}
+
+	if version == 0 {
+		version = 1
+	}
+	if version > def.ConfigVersion() {
+		return nil, nil, fmt.Errorf(
+			"config version %d of node type %q is newer than the supported version %d",
+			version, NodeTypeMapper.FromWithDefault(nodeType, "unknown"), def.ConfigVersion(),
+		)
+	}
+
+	var warnings []string
+
+	if version < def.ConfigVersion() {
+		config := make(map[string]any)
+		if len(data) > 0 && string(data) != "null" {
+			if err := json.Unmarshal(data, &config); err != nil {
+				return nil, nil, fmt.Errorf("could not decode config to migrate: %w", err)
+			}
+		}
+
+		for v := version; v < def.ConfigVersion(); v++ {
+			migrated, err := def.ConfigMigrations[v-1](config)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not migrate config from version %d to %d: %w", v, v+1, err)
+			}
+			warnings = append(warnings, migrated...)
+		}
+
+		var err error
+		data, err = json.Marshal(config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not encode migrated config: %w", err)
+		}
+	}
+
+	config := def.NewConfig()
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, nil, fmt.Errorf("could not unmarshal %q node config: %w", NodeTypeMapper.FromWithDefault(nodeType, "unknown"), err)
+		}
+	}
+
+	return config, warnings, nil
+}
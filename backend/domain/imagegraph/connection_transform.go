@@ -0,0 +1,57 @@
+package imagegraph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrPaletteTransformed is returned when a transform is set on a connection
+// that carries a palette, which a transform would turn into an ordinary image
+var ErrPaletteTransformed = errors.New("palette connections cannot be transformed")
+
+// Connection transforms are lightweight changes made to an image as it
+// passes along a connection, so that pulling a channel out of an image or
+// inverting it doesn't take a node of its own. A connection feeds a single
+// input, so its transform is kept on the input.
+const (
+	// ConnectionTransformNone passes the image on unchanged
+	ConnectionTransformNone = ""
+
+	// ConnectionTransformInvert inverts the color channels, keeping alpha
+	ConnectionTransformInvert = "invert"
+
+	// ConnectionTransformAlpha passes on the alpha channel as an opaque
+	// grayscale image
+	ConnectionTransformAlpha = "alpha"
+
+	// ConnectionTransformLuminance passes on the luminance of the colors as
+	// a grayscale image, keeping alpha
+	ConnectionTransformLuminance = "luminance"
+
+	// ConnectionTransformRed, ConnectionTransformGreen and
+	// ConnectionTransformBlue pass on a color channel as a grayscale image,
+	// keeping alpha
+	ConnectionTransformRed   = "red"
+	ConnectionTransformGreen = "green"
+	ConnectionTransformBlue  = "blue"
+)
+
+// ConnectionTransformOptions lists the transforms a connection can make
+var ConnectionTransformOptions = []string{
+	ConnectionTransformInvert,
+	ConnectionTransformAlpha,
+	ConnectionTransformLuminance,
+	ConnectionTransformRed,
+	ConnectionTransformGreen,
+	ConnectionTransformBlue,
+}
+
+// ValidateConnectionTransform checks that a connection transform is one of
+// the ConnectionTransformOptions or ConnectionTransformNone
+func ValidateConnectionTransform(transform string) error {
+	if transform != ConnectionTransformNone && !slices.Contains(ConnectionTransformOptions, transform) {
+		return fmt.Errorf("connection transform must be one of %v, got %q", ConnectionTransformOptions, transform)
+	}
+	return nil
+}
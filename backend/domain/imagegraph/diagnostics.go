@@ -0,0 +1,209 @@
+package imagegraph
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of problem a diagnostic reports
+const (
+	DiagnosticUnconnectedInput  = "unconnected_input"
+	DiagnosticDanglingOutput    = "dangling_output"
+	DiagnosticUnreachableOutput = "unreachable_output"
+	DiagnosticMissingImage      = "missing_image"
+	DiagnosticStuckGenerating   = "stuck_generating"
+	DiagnosticFailed            = "failed"
+	DiagnosticBrokenConnection  = "broken_connection"
+	DiagnosticCycle             = "cycle"
+	DiagnosticDeprecatedType    = "deprecated_type"
+	DiagnosticMigratedConfig    = "migrated_config"
+)
+
+// Severities of diagnostics. Errors keep the graph from producing its
+// outputs; warnings are likely mistakes that don't.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found with an ImageGraph. Port is the input or
+// output of the node the problem is with, if it's with one.
+type Diagnostic struct {
+	Kind     string
+	Severity string
+	NodeID   NodeID
+	Port     string
+	Message  string
+}
+
+// Diagnostics checks the ImageGraph for problems that keep it from being
+// complete: required inputs that aren't connected, outputs that lead
+// nowhere, Output nodes that no input reaches, Input nodes without an
+// image, nodes that failed or have been generating since before stuckBefore,
+// and connections that are inconsistent or form a cycle, which the graph's
+// own checks should never allow. Nodes of deprecated types and nodes whose
+// configs were migrated are warned about. Errors come first, then warnings,
+// each ordered by node.
+func (ig *ImageGraph) Diagnostics(stuckBefore time.Time) []Diagnostic {
+	var diagnostics []Diagnostic
+	add := func(kind, severity string, nodeID NodeID, port string, format string, args ...any) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Kind:     kind,
+			Severity: severity,
+			NodeID:   nodeID,
+			Port:     port,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	fed := make(map[NodeID]bool)
+
+	for _, node := range ig.Nodes {
+		for name, input := range node.Inputs {
+			switch {
+			case !input.Connected && !input.Optional:
+				add(DiagnosticUnconnectedInput, SeverityError, node.ID, string(name),
+					"input %q is not connected", name)
+			case input.Connected && !ig.connectionExists(input.InputConnection.NodeID, input.InputConnection.OutputName, node.ID, name):
+				add(DiagnosticBrokenConnection, SeverityError, node.ID, string(name),
+					"input %q is connected to an output that isn't connected to it", name)
+			}
+		}
+
+		for name, output := range node.Outputs {
+			if len(output.Connections) == 0 && node.Type != NodeTypeOutput {
+				add(DiagnosticDanglingOutput, SeverityWarning, node.ID, string(name),
+					"output %q is not connected to anything", name)
+			}
+
+			for connection := range output.Connections {
+				target, ok := ig.Nodes.Get(connection.NodeID)
+				if !ok {
+					add(DiagnosticBrokenConnection, SeverityError, node.ID, string(name),
+						"output %q is connected to a node that doesn't exist", name)
+					continue
+				}
+				input, err := target.Inputs.Get(connection.InputName)
+				if err != nil || !input.Connected || input.InputConnection != (InputConnection{NodeID: node.ID, OutputName: name}) {
+					add(DiagnosticBrokenConnection, SeverityError, node.ID, string(name),
+						"output %q is connected to input %q of a node that isn't connected to it", name, connection.InputName)
+				}
+			}
+		}
+
+		if node.Type == NodeTypeOutput && !ig.isFed(node.ID, fed, make(map[NodeID]bool)) {
+			add(DiagnosticUnreachableOutput, SeverityError, node.ID, "",
+				"no input reaches this output node")
+		}
+
+		switch state := node.State.Get(); {
+		case node.Type == NodeTypeInput && node.Outputs.AllSet():
+		case node.Type == NodeTypeInput:
+			add(DiagnosticMissingImage, SeverityWarning, node.ID, "",
+				"input node has no image")
+		case state == Failed:
+			add(DiagnosticFailed, SeverityError, node.ID, "",
+				"generation failed: %s", node.Error)
+		case state == Generating && node.UpdatedAt.Before(stuckBefore):
+			add(DiagnosticStuckGenerating, SeverityWarning, node.ID, "",
+				"generating since %s", node.UpdatedAt.UTC().Format(time.RFC3339))
+		}
+
+		if deprecated := NodeTypeDefs[node.Type].Deprecated; deprecated != "" {
+			add(DiagnosticDeprecatedType, SeverityWarning, node.ID, "",
+				"node type %s is deprecated: %s", NodeTypeMapper.FromWithDefault(node.Type, "unknown"), deprecated)
+		}
+
+		for _, warning := range node.ConfigWarnings {
+			add(DiagnosticMigratedConfig, SeverityWarning, node.ID, "",
+				"config was migrated: %s", warning)
+		}
+	}
+
+	for _, nodeID := range ig.cycleNodes() {
+		add(DiagnosticCycle, SeverityError, nodeID, "",
+			"node is part of a cycle of connections")
+	}
+
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		if a.Severity != b.Severity {
+			if a.Severity == SeverityError {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(a.NodeID.String(), b.NodeID.String()); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Port, b.Port)
+	})
+
+	return diagnostics
+}
+
+// connectionExists reports whether a node output records a connection to
+// a node input
+func (ig *ImageGraph) connectionExists(fromNodeID NodeID, outputName OutputName, toNodeID NodeID, inputName InputName) bool {
+	from, ok := ig.Nodes.Get(fromNodeID)
+	if !ok {
+		return false
+	}
+	connected, err := from.IsOutputConnectedTo(outputName, toNodeID, inputName)
+	return err == nil && connected
+}
+
+// isFed reports whether every required input of a node is connected, and
+// the nodes they're connected to are fed in turn, back to nodes without
+// required inputs. visiting guards against cycles.
+func (ig *ImageGraph) isFed(nodeID NodeID, fed map[NodeID]bool, visiting map[NodeID]bool) bool {
+	if result, ok := fed[nodeID]; ok {
+		return result
+	}
+	if visiting[nodeID] {
+		return false
+	}
+	visiting[nodeID] = true
+
+	node, ok := ig.Nodes.Get(nodeID)
+	result := ok
+	if ok {
+		for _, input := range node.Inputs {
+			if input.Optional && !input.Connected {
+				continue
+			}
+			if !input.Connected || !ig.isFed(input.InputConnection.NodeID, fed, visiting) {
+				result = false
+				break
+			}
+		}
+	}
+
+	fed[nodeID] = result
+	return result
+}
+
+// cycleNodes returns the nodes that can reach themselves by following
+// output connections
+func (ig *ImageGraph) cycleNodes() []NodeID {
+	var nodes []NodeID
+
+	for id, node := range ig.Nodes {
+		for _, output := range node.Outputs {
+			for connection := range output.Connections {
+				if connection.NodeID == id || ig.Nodes.HasPathBetween(connection.NodeID, id) {
+					nodes = append(nodes, id)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(nodes, func(a, b NodeID) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return slices.Compact(nodes)
+}
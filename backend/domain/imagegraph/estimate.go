@@ -0,0 +1,115 @@
+package imagegraph
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// bytesPerPixel is the memory a decoded pixel takes, as 8-bit RGBA
+const bytesPerPixel = 4
+
+// GenerationTiming is what's been recorded about how long generating a node
+// type takes. PerMegapixel is the mean time taken per megapixel of the images
+// generation loaded, and zero if none of the generations loaded any.
+type GenerationTiming struct {
+	Count        int
+	Mean         time.Duration
+	PerMegapixel time.Duration
+}
+
+// Duration estimates how long a generation that loads pixels takes, scaling
+// PerMegapixel where it's known and falling back on Mean otherwise
+func (t GenerationTiming) Duration(pixels int) time.Duration {
+	if t.PerMegapixel > 0 && pixels > 0 {
+		return time.Duration(float64(t.PerMegapixel) * float64(pixels) / 1e6)
+	}
+	return t.Mean
+}
+
+// NodeEstimate is what regenerating a node is expected to take. Inputs holds
+// the sizes of the images reaching the node's inputs that could be worked
+// out, and Output the size of its primary output if it could be. Memory is
+// the bytes taken by those images once decoded. Duration is only set if
+// generations of the node type have been timed.
+type NodeEstimate struct {
+	NodeID         NodeID
+	Inputs         map[InputName]ImageSize
+	Output         ImageSize
+	OutputKnown    bool
+	MemoryBytes    int64
+	Duration       time.Duration
+	DurationKnown  bool
+	DurationSample int
+}
+
+// Estimate works out what regenerating every node of the ImageGraph would
+// take, without generating anything. Sizes are propagated from the images
+// set on Input nodes, found in sizes, through each node's config; the other
+// images in sizes are ignored, as regeneration replaces them. Durations are
+// scaled by the pixels the node loads from the timings recorded for the
+// node in nodeTimings, or else for its type in timings. Bypassed nodes only
+// pass on their input, and take no time. Estimates are ordered by node.
+func (ig *ImageGraph) Estimate(
+	sizes map[ImageID]ImageSize,
+	timings map[NodeType]GenerationTiming,
+	nodeTimings map[NodeID]GenerationTiming,
+) []NodeEstimate {
+	sources := make(map[ImageID]ImageSize)
+	for _, node := range ig.Nodes {
+		if node.Type != NodeTypeInput {
+			continue
+		}
+		for _, output := range node.Outputs {
+			if size, ok := sizes[output.ImageID]; ok && !output.ImageID.IsNil() {
+				sources[output.ImageID] = size
+			}
+		}
+	}
+
+	var estimates []NodeEstimate
+
+	for _, node := range ig.Nodes {
+		e := NodeEstimate{
+			NodeID: node.ID,
+			Inputs: ig.inputSizes(node, sources),
+		}
+
+		pixels := 0
+		for _, size := range e.Inputs {
+			pixels += size.Width * size.Height
+		}
+
+		e.Output, e.OutputKnown = ig.outputSize(node, NodeTypeDefs[node.Type].PrimaryOutput(), sources)
+		if len(node.Inputs) == 0 && e.OutputKnown {
+			// Input nodes load the image they hold
+			pixels = e.Output.Width * e.Output.Height
+		}
+
+		e.MemoryBytes = int64(pixels) * bytesPerPixel
+		if len(node.Inputs) > 0 && e.OutputKnown {
+			e.MemoryBytes += int64(e.Output.Width*e.Output.Height) * bytesPerPixel
+		}
+
+		timing, ok := nodeTimings[node.ID]
+		if !ok || timing.Count == 0 {
+			timing = timings[node.Type]
+		}
+
+		if node.Bypassed {
+			e.DurationKnown = true
+		} else if timing.Count > 0 {
+			e.Duration = timing.Duration(pixels)
+			e.DurationKnown = true
+			e.DurationSample = timing.Count
+		}
+
+		estimates = append(estimates, e)
+	}
+
+	slices.SortFunc(estimates, func(a, b NodeEstimate) int {
+		return strings.Compare(a.NodeID.String(), b.NodeID.String())
+	})
+
+	return estimates
+}
@@ -0,0 +1,11 @@
+package imagegraph
+
+import "github.com/dmpettyp/dorky/id"
+
+// EventID identifies an ImageGraph event. It stays the same when the event is
+// delivered again, so handlers can recognize events they already processed.
+type EventID struct{ id.ID }
+
+var NewEventID, MustNewEventID, ParseEventID = id.Create(
+	func(id id.ID) EventID { return EventID{ID: id} },
+)
@@ -1,20 +1,146 @@
 package imagegraph
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 type CreatedEvent struct {
 	ImageGraphEvent
-	Name string `json:"name"`
+	Name       string `json:"name"`
+	ExternalID string `json:"external_id,omitempty"`
+	Owner      string `json:"owner,omitempty"`
 }
 
 func NewCreatedEvent(ig *ImageGraph) *CreatedEvent {
 	e := &CreatedEvent{
-		Name: ig.Name,
+		Name:       ig.Name,
+		ExternalID: ig.ExternalID,
+		Owner:      ig.Owner,
 	}
 	e.Init("Created")
 	return e
 }
 
+type PublicSetEvent struct {
+	ImageGraphEvent
+	Public bool `json:"public"`
+}
+
+func NewPublicSetEvent(ig *ImageGraph) *PublicSetEvent {
+	e := &PublicSetEvent{
+		Public: ig.Public,
+	}
+	e.Init("PublicSet")
+	return e
+}
+
+type PerformanceModeSetEvent struct {
+	ImageGraphEvent
+	PerformanceMode bool `json:"performance_mode"`
+}
+
+func NewPerformanceModeSetEvent(ig *ImageGraph) *PerformanceModeSetEvent {
+	e := &PerformanceModeSetEvent{
+		PerformanceMode: ig.PerformanceMode,
+	}
+	e.Init("PerformanceModeSet")
+	return e
+}
+
+type ColorManagementSetEvent struct {
+	ImageGraphEvent
+	ColorManagement string `json:"color_management"`
+}
+
+func NewColorManagementSetEvent(ig *ImageGraph) *ColorManagementSetEvent {
+	e := &ColorManagementSetEvent{
+		ColorManagement: ig.ColorManagement,
+	}
+	e.Init("ColorManagementSet")
+	return e
+}
+
+type ParametersSetEvent struct {
+	ImageGraphEvent
+	Parameters GraphParameters `json:"parameters"`
+}
+
+func NewParametersSetEvent(ig *ImageGraph) *ParametersSetEvent {
+	e := &ParametersSetEvent{
+		Parameters: ig.Parameters,
+	}
+	e.Init("ParametersSet")
+	return e
+}
+
+type SeedSetEvent struct {
+	ImageGraphEvent
+	Seed *int `json:"seed"`
+}
+
+func NewSeedSetEvent(ig *ImageGraph) *SeedSetEvent {
+	e := &SeedSetEvent{
+		Seed: ig.Seed,
+	}
+	e.Init("SeedSet")
+	return e
+}
+
+type TagAddedEvent struct {
+	ImageGraphEvent
+	Tag string `json:"tag"`
+}
+
+func NewTagAddedEvent(tag string) *TagAddedEvent {
+	e := &TagAddedEvent{
+		Tag: tag,
+	}
+	e.Init("TagAdded")
+	return e
+}
+
+type TagRemovedEvent struct {
+	ImageGraphEvent
+	Tag string `json:"tag"`
+}
+
+func NewTagRemovedEvent(tag string) *TagRemovedEvent {
+	e := &TagRemovedEvent{
+		Tag: tag,
+	}
+	e.Init("TagRemoved")
+	return e
+}
+
+type SharedEvent struct {
+	ImageGraphEvent
+	UserID string `json:"user_id"`
+	Role   Role   `json:"role"`
+}
+
+func NewSharedEvent(userID string, role Role) *SharedEvent {
+	e := &SharedEvent{
+		UserID: userID,
+		Role:   role,
+	}
+	e.Init("Shared")
+	return e
+}
+
+type UnsharedEvent struct {
+	ImageGraphEvent
+	UserID string `json:"user_id"`
+}
+
+func NewUnsharedEvent(userID string) *UnsharedEvent {
+	e := &UnsharedEvent{
+		UserID: userID,
+	}
+	e.Init("Unshared")
+	return e
+}
+
 type NodeAddedEvent struct {
 	ImageGraphEvent
 	NodeID NodeID `json:"node_id"`
@@ -43,14 +169,16 @@ func NewNodeRemovedEvent(ig *ImageGraph, n *Node) *NodeRemovedEvent {
 
 type NodeCreatedEvent struct {
 	NodeEvent
-	NodeType NodeType `json:"node_type"`
-	NodeName string   `json:"node_name"`
+	NodeType       NodeType `json:"node_type"`
+	NodeName       string   `json:"node_name"`
+	NodeExternalID string   `json:"node_external_id,omitempty"`
 }
 
 func NewNodeCreatedEvent(n *Node) *NodeCreatedEvent {
 	e := &NodeCreatedEvent{
-		NodeType: n.Type,
-		NodeName: n.Name,
+		NodeType:       n.Type,
+		NodeName:       n.Name,
+		NodeExternalID: n.ExternalID,
 	}
 	e.Init("NodeCreated")
 	e.applyNode(n)
@@ -62,6 +190,7 @@ type NodeInputConnectedEvent struct {
 	InputName      InputName  `json:"input_name"`
 	FromNodeID     NodeID     `json:"from_node_id"`
 	FromOutputName OutputName `json:"from_output_name"`
+	Transform      string     `json:"transform,omitempty"`
 }
 
 func NewInputConnectedEvent(
@@ -69,17 +198,69 @@ func NewInputConnectedEvent(
 	inputName InputName,
 	fromNodeID NodeID,
 	fromOutputName OutputName,
+	transform string,
 ) *NodeInputConnectedEvent {
 	e := &NodeInputConnectedEvent{
 		InputName:      inputName,
 		FromNodeID:     fromNodeID,
 		FromOutputName: fromOutputName,
+		Transform:      transform,
 	}
 	e.Init("NodeInputConnected")
 	e.applyNode(n)
 	return e
 }
 
+type NodeInputTransformSetEvent struct {
+	NodeEvent
+	InputName InputName `json:"input_name"`
+	Transform string    `json:"transform"`
+}
+
+func NewInputTransformSetEvent(
+	n *Node,
+	inputName InputName,
+	transform string,
+) *NodeInputTransformSetEvent {
+	e := &NodeInputTransformSetEvent{
+		InputName: inputName,
+		Transform: transform,
+	}
+	e.Init("NodeInputTransformSet")
+	e.applyNode(n)
+	return e
+}
+
+// NodeInputAddedEvent records an input added to a node whose type lets it
+// grow extra inputs
+type NodeInputAddedEvent struct {
+	NodeEvent
+	InputName InputName `json:"input_name"`
+}
+
+func NewInputAddedEvent(n *Node, inputName InputName) *NodeInputAddedEvent {
+	e := &NodeInputAddedEvent{
+		InputName: inputName,
+	}
+	e.Init("NodeInputAdded")
+	e.applyNode(n)
+	return e
+}
+
+type NodeInputRemovedEvent struct {
+	NodeEvent
+	InputName InputName `json:"input_name"`
+}
+
+func NewInputRemovedEvent(n *Node, inputName InputName) *NodeInputRemovedEvent {
+	e := &NodeInputRemovedEvent{
+		InputName: inputName,
+	}
+	e.Init("NodeInputRemoved")
+	e.applyNode(n)
+	return e
+}
+
 type NodeInputDisconnectedEvent struct {
 	NodeEvent
 	InputName      InputName  `json:"input_name"`
@@ -154,17 +335,21 @@ type NodeOutputImageSetEvent struct {
 	OutputName   OutputName  `json:"output_name"`
 	ImageID      ImageID     `json:"image_id"`
 	ImageVersion NodeVersion `json:"image_version"`
+	// Upload describes the image when it was uploaded rather than generated
+	Upload *UploadedImage `json:"upload,omitempty"`
 }
 
 func NewOutputImageSetEvent(
 	n *Node,
 	outputName OutputName,
 	imageID ImageID,
+	upload *UploadedImage,
 ) *NodeOutputImageSetEvent {
 	e := &NodeOutputImageSetEvent{
 		OutputName:   outputName,
 		ImageID:      imageID,
 		ImageVersion: n.ImageVersion,
+		Upload:       upload,
 	}
 	e.Init("NodeOutputImageSet")
 	e.applyNode(n)
@@ -176,6 +361,9 @@ type NodeOutputImageUnsetEvent struct {
 	OutputName   OutputName  `json:"output_name"`
 	ImageID      ImageID     `json:"image_id"`
 	ImageVersion NodeVersion `json:"image_version"`
+	// Retained images stay in the output's history and must not be removed.
+	// Events stored before outputs kept a history leave it unset.
+	Retained bool `json:"retained,omitempty"`
 }
 
 func NewOutputImageUnsetEvent(
@@ -187,12 +375,35 @@ func NewOutputImageUnsetEvent(
 		OutputName:   outputName,
 		ImageID:      imageID,
 		ImageVersion: n.ImageVersion,
+		Retained:     true,
 	}
 	e.Init("NodeOutputImageUnset")
 	e.applyNode(n)
 	return e
 }
 
+// NodeOutputVariantsDroppedEvent is emitted when previous images of an
+// output no longer fit in its history
+type NodeOutputVariantsDroppedEvent struct {
+	NodeEvent
+	OutputName OutputName `json:"output_name"`
+	ImageIDs   []ImageID  `json:"image_ids"`
+}
+
+func NewNodeOutputVariantsDroppedEvent(
+	n *Node,
+	outputName OutputName,
+	imageIDs []ImageID,
+) *NodeOutputVariantsDroppedEvent {
+	e := &NodeOutputVariantsDroppedEvent{
+		OutputName: outputName,
+		ImageIDs:   imageIDs,
+	}
+	e.Init("NodeOutputVariantsDropped")
+	e.applyNode(n)
+	return e
+}
+
 type NodeInputImageSetEvent struct {
 	NodeEvent
 	InputName InputName `json:"input_name"`
@@ -244,6 +455,27 @@ func NewNodeConfigSetEvent(n *Node) *NodeConfigSetEvent {
 	return e
 }
 
+// UnmarshalJSON decodes the config into the config type of the node's type
+func (e *NodeConfigSetEvent) UnmarshalJSON(data []byte) error {
+	type event NodeConfigSetEvent
+	aux := struct {
+		*event
+		Config json.RawMessage `json:"config"`
+	}{event: (*event)(e)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	config, err := unmarshalNodeConfig(e.NodeType, aux.Config)
+	if err != nil {
+		return err
+	}
+
+	e.Config = config
+	return nil
+}
+
 type NodeNameSetEvent struct {
 	NodeEvent
 	Name string `json:"name"`
@@ -258,6 +490,121 @@ func NewNodeNameSetEvent(n *Node) *NodeNameSetEvent {
 	return e
 }
 
+type NodeDescriptionSetEvent struct {
+	NodeEvent
+	Description string `json:"description"`
+}
+
+func NewNodeDescriptionSetEvent(n *Node) *NodeDescriptionSetEvent {
+	e := &NodeDescriptionSetEvent{
+		Description: n.Description,
+	}
+	e.Init("NodeDescriptionSet")
+	e.applyNode(n)
+	return e
+}
+
+type NodeTagAddedEvent struct {
+	NodeEvent
+	Tag  string `json:"tag"`
+	Tags Tags   `json:"tags"`
+}
+
+func NewNodeTagAddedEvent(n *Node, tag string) *NodeTagAddedEvent {
+	e := &NodeTagAddedEvent{
+		Tag:  tag,
+		Tags: n.Tags,
+	}
+	e.Init("NodeTagAdded")
+	e.applyNode(n)
+	return e
+}
+
+type NodeTagRemovedEvent struct {
+	NodeEvent
+	Tag  string `json:"tag"`
+	Tags Tags   `json:"tags"`
+}
+
+func NewNodeTagRemovedEvent(n *Node, tag string) *NodeTagRemovedEvent {
+	e := &NodeTagRemovedEvent{
+		Tag:  tag,
+		Tags: n.Tags,
+	}
+	e.Init("NodeTagRemoved")
+	e.applyNode(n)
+	return e
+}
+
+type NodeBypassSetEvent struct {
+	NodeEvent
+	Bypassed bool `json:"bypassed"`
+}
+
+func NewNodeBypassSetEvent(n *Node) *NodeBypassSetEvent {
+	e := &NodeBypassSetEvent{
+		Bypassed: n.Bypassed,
+	}
+	e.Init("NodeBypassSet")
+	e.applyNode(n)
+	return e
+}
+
+type NodeImplementationSetEvent struct {
+	NodeEvent
+	Implementation int `json:"implementation"`
+}
+
+func NewNodeImplementationSetEvent(n *Node) *NodeImplementationSetEvent {
+	e := &NodeImplementationSetEvent{
+		Implementation: n.Implementation,
+	}
+	e.Init("NodeImplementationSet")
+	e.applyNode(n)
+	return e
+}
+
+type NodeExpressionsSetEvent struct {
+	NodeEvent
+	Expressions ConfigExpressions `json:"expressions"`
+}
+
+func NewNodeExpressionsSetEvent(n *Node) *NodeExpressionsSetEvent {
+	e := &NodeExpressionsSetEvent{
+		Expressions: n.Expressions,
+	}
+	e.Init("NodeExpressionsSet")
+	e.applyNode(n)
+	return e
+}
+
+type NodePinnedSetEvent struct {
+	NodeEvent
+	Pinned bool `json:"pinned"`
+}
+
+func NewNodePinnedSetEvent(n *Node) *NodePinnedSetEvent {
+	e := &NodePinnedSetEvent{
+		Pinned: n.Pinned,
+	}
+	e.Init("NodePinnedSet")
+	e.applyNode(n)
+	return e
+}
+
+// NodeRegenerationSuppressedEvent is emitted the first time a pinned node
+// skips regenerating its outputs, leaving them stale
+type NodeRegenerationSuppressedEvent struct {
+	NodeEvent
+}
+
+func NewNodeRegenerationSuppressedEvent(n *Node) *NodeRegenerationSuppressedEvent {
+	e := &NodeRegenerationSuppressedEvent{}
+	e.Init("NodeRegenerationSuppressed")
+	e.applyNode(n)
+	return e
+}
+
 type NodePreviewSetEvent struct {
 	NodeEvent
 	ImageID      ImageID     `json:"image_id"`
@@ -288,36 +635,115 @@ func NewNodePreviewUnsetEvent(n *Node) *NodePreviewUnsetEvent {
 	return e
 }
 
+type NodeGenerationFailedEvent struct {
+	NodeEvent
+	Message      string      `json:"message"`
+	ImageVersion NodeVersion `json:"image_version"`
+}
+
+func NewNodeGenerationFailedEvent(n *Node) *NodeGenerationFailedEvent {
+	e := &NodeGenerationFailedEvent{
+		Message:      n.Error,
+		ImageVersion: n.ImageVersion,
+	}
+	e.Init("NodeGenerationFailed")
+	e.applyNode(n)
+	return e
+}
+
 type nodeInput struct {
-	Name    InputName `json:"name"`
-	ImageID ImageID   `json:"image_id"`
+	Name      InputName `json:"name"`
+	ImageID   ImageID   `json:"image_id"`
+	Transform string    `json:"transform,omitempty"`
+	// FromNodeName is the name of the node the input is connected from
+	FromNodeName string `json:"from_node_name,omitempty"`
 }
 
 type NodeNeedsOutputsEvent struct {
 	NodeEvent
-	NodeConfig NodeConfig  `json:"node_config"`
-	Inputs     []nodeInput `json:"inputs"`
+	NodeConfig     NodeConfig  `json:"node_config"`
+	Implementation int         `json:"implementation"`
+	Bypassed       bool        `json:"bypassed,omitempty"`
+	Inputs         []nodeInput `json:"inputs"`
+
+	// The node's config expressions, evaluated against the sizes of its
+	// input images before it generates
+	Expressions ConfigExpressions `json:"expressions,omitempty"`
+
+	// The values of the graph parameters the node's expressions refer to
+	Parameters GraphParameters `json:"parameters,omitempty"`
+
+	// The seed of the ImageGraph, which stochastic nodes without a seed of
+	// their own generate with
+	GraphSeed *int `json:"graph_seed,omitempty"`
+
+	// The color management setting of the ImageGraph, which Output nodes
+	// apply to their final images
+	ColorManagement string `json:"color_management,omitempty"`
+
+	// Whether the node skips generating its preview, as intermediate nodes
+	// of ImageGraphs in performance mode do
+	SkipPreview bool `json:"skip_preview,omitempty"`
 }
 
 func NewNodeNeedsOutputsEvent(n *Node) *NodeNeedsOutputsEvent {
 	e := &NodeNeedsOutputsEvent{
-		NodeConfig: n.Config,
+		NodeConfig:     n.Config,
+		Implementation: n.Implementation,
+		Bypassed:       n.Bypassed,
+		Expressions:    n.Expressions,
 	}
 	e.Init("NodeNeedsOutputs")
 	e.applyNode(n)
 
-	for name, input := range n.Inputs {
+	for _, name := range n.InputNames() {
+		input := n.Inputs[name]
 		e.Inputs = append(
 			e.Inputs,
 			nodeInput{
-				Name:    name,
-				ImageID: input.ImageID,
+				Name:      name,
+				ImageID:   input.ImageID,
+				Transform: input.Transform,
 			},
 		)
 	}
 	return e
 }
 
+// applyImageGraph also records the graph-level settings that output
+// generation depends on
+func (e *NodeNeedsOutputsEvent) applyImageGraph(ig *ImageGraph) {
+	e.NodeEvent.applyImageGraph(ig)
+	e.ColorManagement = ig.ColorManagement
+	e.SkipPreview = ig.PerformanceMode && e.NodeType != NodeTypeOutput
+
+	for _, name := range e.Expressions.Parameters() {
+		if value, ok := ig.Parameters[name]; ok {
+			if e.Parameters == nil {
+				e.Parameters = make(GraphParameters)
+			}
+			e.Parameters[name] = value
+		}
+	}
+
+	node, ok := ig.Nodes.Get(e.NodeID)
+	if !ok {
+		return
+	}
+	if _, ok := node.Config.(NodeConfigSeeder); ok {
+		e.GraphSeed = ig.Seed
+	}
+	for i := range e.Inputs {
+		input, ok := node.Inputs[e.Inputs[i].Name]
+		if !ok || !input.Connected {
+			continue
+		}
+		if from, ok := ig.Nodes.Get(input.InputConnection.NodeID); ok {
+			e.Inputs[i].FromNodeName = from.Name
+		}
+	}
+}
+
 // GetInput retrieves an input image by name, returning an error if not found or nil
 func (e *NodeNeedsOutputsEvent) GetInput(name InputName) (ImageID, error) {
 	for _, input := range e.Inputs {
@@ -330,3 +756,39 @@ func (e *NodeNeedsOutputsEvent) GetInput(name InputName) (ImageID, error) {
 	}
 	return ImageID{}, fmt.Errorf("input %q not found", name)
 }
+
+// UnmarshalJSON decodes the config into the config type of the node's type
+func (e *NodeNeedsOutputsEvent) UnmarshalJSON(data []byte) error {
+	type event NodeNeedsOutputsEvent
+	aux := struct {
+		*event
+		NodeConfig json.RawMessage `json:"node_config"`
+	}{event: (*event)(e)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	config, err := unmarshalNodeConfig(e.NodeType, aux.NodeConfig)
+	if err != nil {
+		return err
+	}
+
+	e.NodeConfig = config
+	return nil
+}
+
+func unmarshalNodeConfig(nodeType NodeType, data json.RawMessage) (NodeConfig, error) {
+	config := NewNodeConfig(nodeType)
+	if config == nil {
+		return nil, fmt.Errorf("no config for node type %q", NodeTypeMapper.FromWithDefault(nodeType, "unknown"))
+	}
+
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("could not unmarshal %q node config: %w", NodeTypeMapper.FromWithDefault(nodeType, "unknown"), err)
+		}
+	}
+
+	return config, nil
+}
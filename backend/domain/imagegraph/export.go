@@ -0,0 +1,92 @@
+package imagegraph
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Export is a final image published by an Output node under an export name
+type Export struct {
+	Name        string
+	NodeID      NodeID
+	ImageID     ImageID
+	GeneratedAt time.Time
+}
+
+// exportName is the name an Output node publishes its final image under
+func (n *Node) exportName() string {
+	if config, ok := n.Config.(*NodeConfigOutput); ok && config.ExportName != "" {
+		return config.ExportName
+	}
+	return n.Name
+}
+
+// Exports lists the final images of the ImageGraph's Output nodes that have
+// been generated, ordered by export name
+func (ig *ImageGraph) Exports() []Export {
+	outputName := NodeTypeDefs[NodeTypeOutput].PrimaryOutput()
+
+	var exports []Export
+
+	for _, node := range ig.Nodes {
+		if node.Type != NodeTypeOutput {
+			continue
+		}
+
+		output, ok := node.Outputs[outputName]
+		if !ok || !output.HasImage() {
+			continue
+		}
+
+		exports = append(exports, Export{
+			Name:        node.exportName(),
+			NodeID:      node.ID,
+			ImageID:     output.ImageID,
+			GeneratedAt: output.GeneratedAt,
+		})
+	}
+
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].Name < exports[j].Name
+	})
+
+	return exports
+}
+
+// Thumbnail returns the preview image of the Output node whose export comes
+// first, to represent the ImageGraph in lists. It's nil until an Output node
+// has generated its final image.
+func (ig *ImageGraph) Thumbnail() ImageID {
+	for _, export := range ig.Exports() {
+		if node, ok := ig.Nodes.Get(export.NodeID); ok && !node.Preview.IsNil() {
+			return node.Preview
+		}
+	}
+
+	return ImageID{}
+}
+
+// checkExportName verifies that an explicit export name for a node isn't
+// already used by another Output node in the ImageGraph
+func (ig *ImageGraph) checkExportName(nodeID NodeID, config NodeConfig) error {
+	outputConfig, ok := config.(*NodeConfigOutput)
+	if !ok || outputConfig.ExportName == "" {
+		return nil
+	}
+
+	for _, node := range ig.Nodes {
+		if node.ID == nodeID || node.Type != NodeTypeOutput {
+			continue
+		}
+
+		if node.exportName() == outputConfig.ExportName {
+			return fmt.Errorf(
+				"export name %q is already used by node %q",
+				outputConfig.ExportName, node.ID,
+			)
+		}
+	}
+
+	return nil
+}
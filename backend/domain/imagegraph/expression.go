@@ -0,0 +1,409 @@
+package imagegraph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidExpression is returned when a config expression can't be parsed
+// or refers to something a node doesn't have
+var ErrInvalidExpression = errors.New("invalid expression")
+
+// MaxExpressionLength is the longest a config expression can be, in bytes
+const MaxExpressionLength = 256
+
+// Expression is an arithmetic expression over named numbers, such as
+// "input.width / 2". Expressions support + - * / %, parentheses, unary minus
+// and the functions min, max, round, floor, ceil and abs.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// ParseExpression parses an expression
+func ParseExpression(source string) (Expression, error) {
+	if len(source) > MaxExpressionLength {
+		return Expression{}, fmt.Errorf(
+			"%w: longer than %d bytes", ErrInvalidExpression, MaxExpressionLength,
+		)
+	}
+
+	p := &exprParser{}
+	if err := p.tokenize(source); err != nil {
+		return Expression{}, fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+
+	root, err := p.parseSum()
+	if err != nil {
+		return Expression{}, fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return Expression{}, fmt.Errorf("%w: unexpected %q", ErrInvalidExpression, tok.text)
+	}
+
+	return Expression{source: source, root: root}, nil
+}
+
+// String returns the expression's source
+func (e Expression) String() string {
+	return e.source
+}
+
+// Variables lists the names the expression refers to, in order of first use
+func (e Expression) Variables() []string {
+	var names []string
+	e.root.variables(func(name string) {
+		for _, seen := range names {
+			if seen == name {
+				return
+			}
+		}
+		names = append(names, name)
+	})
+	return names
+}
+
+// Eval computes the expression's value with the given values of its
+// variables
+func (e Expression) Eval(vars map[string]float64) (float64, error) {
+	value, err := e.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%s is not a number", e.source)
+	}
+	return value, nil
+}
+
+type exprNode interface {
+	eval(vars map[string]float64) (float64, error)
+	variables(add func(string))
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, error) { return float64(n), nil }
+func (n numberNode) variables(func(string))                   {}
+
+type variableNode string
+
+func (n variableNode) eval(vars map[string]float64) (float64, error) {
+	value, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("%s is not known", string(n))
+	}
+	return value, nil
+}
+
+func (n variableNode) variables(add func(string)) { add(string(n)) }
+
+type negateNode struct {
+	operand exprNode
+}
+
+func (n negateNode) eval(vars map[string]float64) (float64, error) {
+	value, err := n.operand.eval(vars)
+	return -value, err
+}
+
+func (n negateNode) variables(add func(string)) { n.operand.variables(add) }
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return left / right, nil
+	default:
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return math.Mod(left, right), nil
+	}
+}
+
+func (n binaryNode) variables(add func(string)) {
+	n.left.variables(add)
+	n.right.variables(add)
+}
+
+// exprFunctions are the functions expressions can call, with the number of
+// arguments they take; -1 is any number above zero
+var exprFunctions = map[string]struct {
+	args int
+	fn   func(args []float64) float64
+}{
+	"min":   {-1, func(args []float64) float64 { return minOf(args) }},
+	"max":   {-1, func(args []float64) float64 { return maxOf(args) }},
+	"round": {1, func(args []float64) float64 { return math.Round(args[0]) }},
+	"floor": {1, func(args []float64) float64 { return math.Floor(args[0]) }},
+	"ceil":  {1, func(args []float64) float64 { return math.Ceil(args[0]) }},
+	"abs":   {1, func(args []float64) float64 { return math.Abs(args[0]) }},
+}
+
+func minOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Min(result, v)
+	}
+	return result
+}
+
+func maxOf(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Max(result, v)
+	}
+	return result
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n callNode) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	return exprFunctions[n.name].fn(args), nil
+}
+
+func (n callNode) variables(add func(string)) {
+	for _, arg := range n.args {
+		arg.variables(add)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+// exprParser is a recursive descent parser over the tokens of an expression
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) tokenize(source string) error {
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return fmt.Errorf("bad number %q", text)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenNumber, text: text, value: value})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			if strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+				return fmt.Errorf("bad name %q", text)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenName, text: text})
+		case strings.ContainsRune("+-*/%(),", r):
+			p.tokens = append(p.tokens, token{kind: tokenOperator, text: string(r)})
+			i++
+		default:
+			return fmt.Errorf("unexpected %q", string(r))
+		}
+	}
+
+	if len(p.tokens) == 0 {
+		return errors.New("empty")
+	}
+
+	return nil
+}
+
+func (p *exprParser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEnd, text: "end"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *exprParser) isOperator(ops string) (byte, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOperator || !strings.Contains(ops, tok.text) {
+		return 0, false
+	}
+	return tok.text[0], true
+}
+
+// parseSum parses terms joined by + and -
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.isOperator("+-")
+		if !ok {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+// parseProduct parses factors joined by *, / and %
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.isOperator("*/%")
+		if !ok {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+// parseFactor parses a number, name, function call, negation or
+// parenthesized expression
+func (p *exprParser) parseFactor() (exprNode, error) {
+	tok := p.next()
+
+	switch {
+	case tok.kind == tokenNumber:
+		return numberNode(tok.value), nil
+
+	case tok.kind == tokenName:
+		if _, ok := p.isOperator("("); !ok {
+			return variableNode(tok.text), nil
+		}
+		return p.parseCall(tok.text)
+
+	case tok.kind == tokenOperator && tok.text == "-":
+		operand, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+
+	case tok.kind == tokenOperator && tok.text == "(":
+		inner, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.isOperator(")"); !ok {
+			return nil, errors.New("missing )")
+		}
+		p.next()
+		return inner, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
+
+// parseCall parses the arguments of a call to a function
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+	fn, ok := exprFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	p.next()
+
+	var args []exprNode
+	for {
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if _, ok := p.isOperator(","); !ok {
+			break
+		}
+		p.next()
+	}
+
+	if _, ok := p.isOperator(")"); !ok {
+		return nil, fmt.Errorf("missing ) after arguments of %s", name)
+	}
+	p.next()
+
+	if fn.args >= 0 && len(args) != fn.args {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", name, fn.args, len(args))
+	}
+
+	return callNode{name: name, args: args}, nil
+}
@@ -1,9 +1,124 @@
 package imagegraph
 
-import "github.com/dmpettyp/dorky/id"
+import (
+	"fmt"
+	"time"
+
+	"github.com/dmpettyp/dorky/id"
+)
 
 type ImageID struct{ id.ID }
 
 var NewImageID, MustNewImageID, ParseImageID = id.Create(
 	func(id id.ID) ImageID { return ImageID{ID: id} },
 )
+
+// ImageSize is the pixel dimensions of an image
+type ImageSize struct {
+	Width  int
+	Height int
+}
+
+// UploadedImage describes an image uploaded as a node output, as detected
+// from its data rather than from what the upload claimed it to be
+type UploadedImage struct {
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int    `json:"bytes"`
+}
+
+// ImageLimits caps the size of the images that are decoded, so that an
+// image whose header claims huge dimensions is rejected before its pixels
+// are allocated. A zero limit is unlimited.
+type ImageLimits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int
+}
+
+// Check returns an error describing the limit an image of the given size
+// exceeds, if any
+func (l ImageLimits) Check(size ImageSize) error {
+	if l.MaxWidth > 0 && size.Width > l.MaxWidth {
+		return fmt.Errorf("image is %d pixels wide, the limit is %d", size.Width, l.MaxWidth)
+	}
+
+	if l.MaxHeight > 0 && size.Height > l.MaxHeight {
+		return fmt.Errorf("image is %d pixels high, the limit is %d", size.Height, l.MaxHeight)
+	}
+
+	if pixels := int64(size.Width) * int64(size.Height); l.MaxPixels > 0 && pixels > int64(l.MaxPixels) {
+		return fmt.Errorf("image has %d pixels, the limit is %d", pixels, l.MaxPixels)
+	}
+
+	return nil
+}
+
+// CheckFrames is Check for an image with the given number of frames, each
+// of which is decoded onto a canvas of the image's size, so their pixels
+// together must not exceed MaxPixels
+func (l ImageLimits) CheckFrames(size ImageSize, frames int) error {
+	if err := l.Check(size); err != nil {
+		return err
+	}
+
+	pixels := int64(size.Width) * int64(size.Height)
+	if l.MaxPixels > 0 && frames > 1 && pixels > 0 && int64(frames) > int64(l.MaxPixels)/pixels {
+		return fmt.Errorf("image has %d frames of %d pixels, the limit is %d pixels across all frames", frames, pixels, l.MaxPixels)
+	}
+
+	return nil
+}
+
+// ImageDifference measures how much two images of the same size differ.
+// RMSE is the root-mean-square error of their color channels, from 0 for
+// identical images to 1. SSIM is their mean structural similarity, from 1 for
+// identical images down to -1.
+type ImageDifference struct {
+	RMSE float64
+	SSIM float64
+}
+
+// ImageMetadata describes a stored image beyond its pixels. Filename, DPI
+// and the capture fields are carried from source images to the images
+// generated from them; Width and Height always describe the image itself.
+// Fields that aren't known are left empty.
+type ImageMetadata struct {
+	// The name of the file the image was uploaded from
+	Filename string
+
+	Width  int
+	Height int
+
+	// The horizontal resolution the image is meant to be printed at
+	DPI float64
+
+	// When and with what camera the photo was taken, from its EXIF data
+	CapturedAt  time.Time
+	CameraMake  string
+	CameraModel string
+}
+
+// ImageIDs returns every image the graph refers to: the images of its
+// nodes' outputs, the earlier images kept in their history and the nodes'
+// previews
+func (ig *ImageGraph) ImageIDs() []ImageID {
+	var imageIDs []ImageID
+
+	for _, node := range ig.Nodes {
+		for _, output := range node.Outputs {
+			if !output.ImageID.IsNil() {
+				imageIDs = append(imageIDs, output.ImageID)
+			}
+			for _, variant := range output.History {
+				imageIDs = append(imageIDs, variant.ImageID)
+			}
+		}
+		if !node.Preview.IsNil() {
+			imageIDs = append(imageIDs, node.Preview)
+		}
+	}
+
+	return imageIDs
+}
@@ -2,6 +2,8 @@ package imagegraph
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/dmpettyp/dorky/aggregate"
 )
@@ -17,18 +19,73 @@ type ImageGraph struct {
 	// Author-created name for the ImageGraph
 	Name string
 
+	// Optional client-supplied identifier used to map the ImageGraph to an
+	// entity in an external system
+	ExternalID string
+
+	// The ID of the user that owns the ImageGraph, empty for ImageGraphs
+	// created without authentication
+	Owner string
+
+	// Public ImageGraphs expose the images of their Output nodes through the
+	// read-only gallery
+	Public bool
+
+	// What happens to the ICC profiles of source images when Output nodes
+	// encode their final images, one of ColorManagementOptions
+	ColorManagement string
+
+	// In performance mode, nodes other than Output nodes skip generating
+	// previews; their previews are generated when they are asked for
+	PerformanceMode bool
+
+	// Named numbers that node config expressions can refer to
+	Parameters GraphParameters
+
+	// The seed that stochastic nodes without a seed of their own generate
+	// with, nil to leave them to their node type's default
+	Seed *int
+
+	// Labels used to organize and filter ImageGraphs
+	Tags Tags
+
+	// The users, besides the owner, that can access the ImageGraph
+	Shares Shares
+
 	// The version of the ImageGraph. Every time the ImageGraph is updated its
 	// version is incremented
 	Version ImageGraphVersion
 
+	// When the ImageGraph was created, and when it last emitted an event
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
 	// The list of transform Nodes that exist in the image graph
 	Nodes Nodes
 }
 
+// ImageGraphOption configures optional ImageGraph properties at creation
+type ImageGraphOption func(*ImageGraph)
+
+// WithExternalID assigns an external ID to a new ImageGraph
+func WithExternalID(externalID string) ImageGraphOption {
+	return func(ig *ImageGraph) {
+		ig.ExternalID = externalID
+	}
+}
+
+// WithOwner assigns the user that owns a new ImageGraph
+func WithOwner(owner string) ImageGraphOption {
+	return func(ig *ImageGraph) {
+		ig.Owner = owner
+	}
+}
+
 // NewImageGraph creates and initializes a new ImageGraph
 func NewImageGraph(
 	id ImageGraphID,
 	name string,
+	opts ...ImageGraphOption,
 ) (
 	*ImageGraph,
 	error,
@@ -42,22 +99,155 @@ func NewImageGraph(
 	}
 
 	ig := &ImageGraph{
-		ID:      id,
-		Name:    name,
-		Version: 0,
-		Nodes:   NewNodes(),
+		ID:              id,
+		Name:            name,
+		ColorManagement: ColorManagementPreserve,
+		Version:         0,
+		Nodes:           NewNodes(),
+	}
+
+	for _, opt := range opts {
+		opt(ig)
 	}
 
 	ig.AddEvent(NewCreatedEvent(ig))
+	ig.CreatedAt = ig.UpdatedAt
 
 	return ig, nil
 }
 
+// SetPublic controls whether the ImageGraph is published to the gallery
+func (ig *ImageGraph) SetPublic(public bool) error {
+	if ig.Public == public {
+		return nil
+	}
+
+	ig.Public = public
+
+	ig.AddEvent(NewPublicSetEvent(ig))
+
+	return nil
+}
+
+// SetPerformanceMode turns performance mode on or off. It applies to
+// generation from then on; previews that already exist are kept.
+func (ig *ImageGraph) SetPerformanceMode(performanceMode bool) error {
+	if ig.PerformanceMode == performanceMode {
+		return nil
+	}
+
+	ig.PerformanceMode = performanceMode
+
+	ig.AddEvent(NewPerformanceModeSetEvent(ig))
+
+	return nil
+}
+
+// SetColorManagement changes what happens to the ICC profiles of source
+// images in the ImageGraph's final images, regenerating the images of its
+// Output nodes
+func (ig *ImageGraph) SetColorManagement(mode string) error {
+	if err := ValidateColorManagement(mode); err != nil {
+		return fmt.Errorf("cannot set color management: %w", err)
+	}
+
+	if ig.ColorManagement == mode {
+		return nil
+	}
+
+	ig.ColorManagement = mode
+
+	ig.AddEvent(NewColorManagementSetEvent(ig))
+
+	for _, node := range ig.Nodes {
+		if node.Type != NodeTypeOutput {
+			continue
+		}
+		if err := node.regenerateOutputs(); err != nil {
+			return fmt.Errorf("cannot set color management: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Regenerate runs the ImageGraph's pipeline again from the current images
+// of its Input nodes. Only the nodes that aren't fed by other generated
+// nodes regenerate here; the rest regenerate as new images reach them.
+func (ig *ImageGraph) Regenerate() error {
+	for _, node := range ig.Nodes {
+		if node.Type == NodeTypeInput || ig.fedByGeneratedNode(node) {
+			continue
+		}
+		if err := node.regenerateOutputs(); err != nil {
+			return fmt.Errorf("cannot regenerate ImageGraph %q: %w", ig.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// fedByGeneratedNode reports whether any of a node's inputs is connected to
+// a node other than an Input node
+func (ig *ImageGraph) fedByGeneratedNode(node *Node) bool {
+	for _, input := range node.Inputs {
+		if !input.Connected {
+			continue
+		}
+		if from, ok := ig.Nodes[input.InputConnection.NodeID]; ok && from.Type != NodeTypeInput {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddTag labels the ImageGraph with a tag. Adding a tag the ImageGraph
+// already has does nothing.
+func (ig *ImageGraph) AddTag(tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return fmt.Errorf("couldn't add tag to ImageGraph %q: %w", ig.ID, err)
+	}
+
+	tags, added := ig.Tags.with(tag)
+	if !added {
+		return nil
+	}
+
+	ig.Tags = tags
+
+	ig.AddEvent(NewTagAddedEvent(tag))
+
+	return nil
+}
+
+// RemoveTag removes a tag from the ImageGraph. Removing a tag the ImageGraph
+// doesn't have does nothing.
+func (ig *ImageGraph) RemoveTag(tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return fmt.Errorf("couldn't remove tag from ImageGraph %q: %w", ig.ID, err)
+	}
+
+	tags, removed := ig.Tags.without(tag)
+	if !removed {
+		return nil
+	}
+
+	ig.Tags = tags
+
+	ig.AddEvent(NewTagRemovedEvent(tag))
+
+	return nil
+}
+
 func (ig *ImageGraph) Clone() *ImageGraph {
 	clone := *ig
+	clone.Nodes = make(Nodes, len(ig.Nodes))
 
 	for nodeID, n := range ig.Nodes {
-		c := &(*n)
+		c := n.clone()
 		c.SetEventAdder(clone.AddEvent)
 		clone.Nodes[nodeID] = c
 	}
@@ -76,13 +266,23 @@ func (ig *ImageGraph) AddNode(
 	id NodeID,
 	nodeType NodeType,
 	name string,
+	opts ...NodeOption,
 ) error {
-	n, err := NewNode(ig.AddEvent, id, nodeType, name)
+	n, err := NewNode(ig.AddEvent, id, nodeType, name, opts...)
 
 	if err != nil {
 		return fmt.Errorf("could not create node for ImageGraph %q: %w", ig.ID, err)
 	}
 
+	if n.ExternalID != "" {
+		if existing, ok := ig.Nodes.FindByExternalID(n.ExternalID); ok {
+			return fmt.Errorf(
+				"could not add node to ImageGraph %q: node %q already has external ID %q",
+				ig.ID, existing.ID, n.ExternalID,
+			)
+		}
+	}
+
 	err = ig.Nodes.Add(n)
 
 	if err != nil {
@@ -163,6 +363,22 @@ func (ig *ImageGraph) ConnectNodes(
 	outputName OutputName,
 	toNodeID NodeID,
 	inputName InputName,
+) error {
+	return ig.ConnectNodesWithTransform(
+		fromNodeID, outputName, toNodeID, inputName, ConnectionTransformNone,
+	)
+}
+
+// ConnectNodesWithTransform creates a connection from one node's output to
+// another node's input that transforms the output's image on the way.
+// Connecting nodes that are already connected changes the connection's
+// transform.
+func (ig *ImageGraph) ConnectNodesWithTransform(
+	fromNodeID NodeID,
+	outputName OutputName,
+	toNodeID NodeID,
+	inputName InputName,
+	transform string,
 ) error {
 	if fromNodeID.IsNil() {
 		return fmt.Errorf("cannot connect from node with nil ID in ImageGraph %q", ig.ID)
@@ -226,7 +442,18 @@ func (ig *ImageGraph) ConnectNodes(
 	}
 
 	//
-	// If this connection already exists, do nothing
+	// Ensure that the output produces a kind of image the input can take
+	//
+	if err := ig.checkConnectionKinds(fromNode, outputName, toNode, inputName); err != nil {
+		return fmt.Errorf("%s: %w", baseError, err)
+	}
+
+	if err := ig.checkConnectionTransform(fromNode, outputName, toNode, inputName, transform); err != nil {
+		return fmt.Errorf("%s: %w", baseError, err)
+	}
+
+	//
+	// If this connection already exists, only its transform can change
 	//
 	connectionExists, err := fromNode.IsOutputConnectedTo(
 		outputName,
@@ -239,6 +466,9 @@ func (ig *ImageGraph) ConnectNodes(
 	}
 
 	if connectionExists {
+		if err := toNode.SetInputTransform(inputName, transform); err != nil {
+			return fmt.Errorf("%s: %w", baseError, err)
+		}
 		return nil
 	}
 
@@ -295,7 +525,7 @@ func (ig *ImageGraph) ConnectNodes(
 	//
 	// Connect the target input from the sources output and emit an event
 	//
-	err = toNode.ConnectInputFrom(inputName, fromNodeID, outputName)
+	err = toNode.ConnectInputFrom(inputName, fromNodeID, outputName, transform)
 
 	if err != nil {
 		return fmt.Errorf(
@@ -421,6 +651,61 @@ func (ig *ImageGraph) DisconnectNodes(
 	return nil
 }
 
+// AddNodeInput adds a dynamic input to a node whose type lets it grow extra
+// inputs
+func (ig *ImageGraph) AddNodeInput(nodeID NodeID, inputName InputName) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.AddInput(inputName)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't add input to node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// RemoveNodeInput removes a dynamic input from a node, disconnecting it
+// first if it is connected
+func (ig *ImageGraph) RemoveNodeInput(nodeID NodeID, inputName InputName) error {
+	node, exists := ig.Nodes.Get(nodeID)
+
+	if !exists {
+		return fmt.Errorf("couldn't remove input from node %q: node doesn't exist", nodeID)
+	}
+
+	input, err := node.Inputs.Get(inputName)
+
+	if err != nil {
+		return fmt.Errorf("couldn't remove input from node %q: %w", nodeID, err)
+	}
+
+	if slices.Contains(NodeTypeDefs[node.Type].Inputs, inputName) {
+		return fmt.Errorf(
+			"couldn't remove input %q from node %q: %w", inputName, nodeID, ErrFixedInput,
+		)
+	}
+
+	if input.Connected {
+		err := ig.DisconnectNodes(
+			input.InputConnection.NodeID,
+			input.InputConnection.OutputName,
+			nodeID,
+			inputName,
+		)
+
+		if err != nil {
+			return fmt.Errorf("couldn't remove input from node %q: %w", nodeID, err)
+		}
+	}
+
+	if err := node.RemoveInput(inputName); err != nil {
+		return fmt.Errorf("couldn't remove input from node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
 // SetNodeOutputImage sets the image for a specific node's output.
 // Downstream propagation is handled by event handlers.
 func (ig *ImageGraph) SetNodeOutputImage(
@@ -440,6 +725,56 @@ func (ig *ImageGraph) SetNodeOutputImage(
 	return nil
 }
 
+// SetNodeUploadedOutputImage sets a node output to an uploaded image
+func (ig *ImageGraph) SetNodeUploadedOutputImage(
+	nodeID NodeID,
+	outputName OutputName,
+	imageID ImageID,
+	nodeVersion NodeVersion,
+	upload UploadedImage,
+) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetUploadedOutputImage(outputName, imageID, nodeVersion, upload)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set output image for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// SetNodeGenerationFailed records that a node could not generate its outputs
+func (ig *ImageGraph) SetNodeGenerationFailed(
+	nodeID NodeID,
+	message string,
+	nodeVersion NodeVersion,
+) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetGenerationFailed(message, nodeVersion)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set generation failure for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// ResumeNodeGeneration requests the outputs of a node whose generation was
+// interrupted again
+func (ig *ImageGraph) ResumeNodeGeneration(nodeID NodeID) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.ResumeGeneration()
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't resume generation for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
 // PropagateOutputImageToConnections propagates an output image to all
 // downstream nodes connected to this output
 func (ig *ImageGraph) PropagateOutputImageToConnections(
@@ -478,6 +813,24 @@ func (ig *ImageGraph) UnsetNodeOutputImage(
 	return nil
 }
 
+// PromoteNodeOutputVariant makes a previous image of a node's output its
+// current image again. Downstream propagation is handled by event handlers.
+func (ig *ImageGraph) PromoteNodeOutputVariant(
+	nodeID NodeID,
+	outputName OutputName,
+	imageID ImageID,
+) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.PromoteOutputVariant(outputName, imageID)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't promote output image for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
 func (ig *ImageGraph) UnsetNodeOutputConnections(
 	nodeID NodeID,
 	outputName OutputName,
@@ -530,6 +883,10 @@ func (ig *ImageGraph) UnsetNodePreview(
 
 // SetNodeConfig sets the configuration for a specific node
 func (ig *ImageGraph) SetNodeConfig(nodeID NodeID, config NodeConfig) error {
+	if err := ig.checkExportName(nodeID, config); err != nil {
+		return fmt.Errorf("couldn't set config for node %q: %w", nodeID, err)
+	}
+
 	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
 		return n.SetConfig(config)
 	})
@@ -541,6 +898,111 @@ func (ig *ImageGraph) SetNodeConfig(nodeID NodeID, config NodeConfig) error {
 	return nil
 }
 
+// SetNodeDescription sets the notes for a specific node
+func (ig *ImageGraph) SetNodeDescription(nodeID NodeID, description string) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetDescription(description)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set description for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// AddNodeTag labels a specific node with a tag
+func (ig *ImageGraph) AddNodeTag(nodeID NodeID, tag string) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.AddTag(tag)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't add tag to node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// RemoveNodeTag removes a tag from a specific node
+func (ig *ImageGraph) RemoveNodeTag(nodeID NodeID, tag string) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.RemoveTag(tag)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't remove tag from node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// SetNodeBypassed enables or disables the bypass of a specific node
+func (ig *ImageGraph) SetNodeBypassed(nodeID NodeID, bypassed bool) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetBypassed(bypassed)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set bypass for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// SetNodeImplementation selects the implementation version used by a
+// specific node
+func (ig *ImageGraph) SetNodeImplementation(nodeID NodeID, implementation int) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetImplementation(implementation)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set implementation for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// SetNodeExpressions replaces the config expressions of a specific node
+func (ig *ImageGraph) SetNodeExpressions(nodeID NodeID, exprs ConfigExpressions) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetExpressions(exprs, ig.Parameters)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set expressions for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// UpgradeNodeImplementation migrates a specific node to the latest
+// implementation of its node type
+func (ig *ImageGraph) UpgradeNodeImplementation(nodeID NodeID) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.UpgradeImplementation()
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't upgrade implementation for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+func (ig *ImageGraph) SetNodePinned(nodeID NodeID, pinned bool) error {
+	err := ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		return n.SetPinned(pinned)
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set pin for node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
 // SetNodeName sets the name for a specific node
 func (ig *ImageGraph) SetNodeName(
 	nodeID NodeID,
@@ -1,9 +1,15 @@
 package imagegraph_test
 
 import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/testsupport"
 )
 
 func currentNodeVersion(t *testing.T, ig *imagegraph.ImageGraph, nodeID imagegraph.NodeID) imagegraph.NodeVersion {
@@ -383,6 +389,79 @@ func TestImageGraph_SetNodeName(t *testing.T) {
 	})
 }
 
+func TestImageGraph_SetNodeDescription(t *testing.T) {
+	t.Run("sets description without regenerating the node", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeBlur, "blur")
+		ig.ResetEvents()
+
+		err := ig.SetNodeDescription(nodeID, "Softens the *background*")
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(nodeID)
+		if node.Description != "Softens the *background*" {
+			t.Errorf("expected description %q, got %q", "Softens the *background*", node.Description)
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+
+		descriptionSetEvent, ok := events[0].(*imagegraph.NodeDescriptionSetEvent)
+		if !ok {
+			t.Fatalf("expected NodeDescriptionSetEvent, got %T", events[0])
+		}
+
+		if descriptionSetEvent.Description != "Softens the *background*" {
+			t.Errorf("expected event description %q, got %q", "Softens the *background*", descriptionSetEvent.Description)
+		}
+	})
+
+	t.Run("does not emit an event when unchanged", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+		ig.SetNodeDescription(nodeID, "notes")
+		ig.ResetEvents()
+
+		if err := ig.SetNodeDescription(nodeID, "notes"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if events := ig.GetEvents(); len(events) != 0 {
+			t.Errorf("expected no events, got %d", len(events))
+		}
+	})
+
+	t.Run("returns error for overly long description", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+
+		description := make([]byte, imagegraph.MaxNodeDescriptionLength+1)
+		for i := range description {
+			description[i] = 'a'
+		}
+
+		if err := ig.SetNodeDescription(nodeID, string(description)); err == nil {
+			t.Fatal("expected error for overly long description, got nil")
+		}
+	})
+
+	t.Run("returns error for non-existent node", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+
+		if err := ig.SetNodeDescription(imagegraph.MustNewNodeID(), "notes"); err == nil {
+			t.Fatal("expected error for non-existent node, got nil")
+		}
+	})
+}
+
 func TestImageGraph_SetNodePreview(t *testing.T) {
 	t.Run("sets preview image for existing node", func(t *testing.T) {
 		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
@@ -694,11 +773,9 @@ func TestImageGraph_RemoveNode(t *testing.T) {
 	})
 
 	t.Run("disconnects upstream connections", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Connect input → scale
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
@@ -732,11 +809,9 @@ func TestImageGraph_RemoveNode(t *testing.T) {
 	})
 
 	t.Run("disconnects downstream connections", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Set an image before connecting to verify it gets unset
 		imageID := imagegraph.MustNewImageID()
@@ -825,11 +900,9 @@ func TestImageGraph_RemoveNode(t *testing.T) {
 
 func TestImageGraph_ConnectNodes(t *testing.T) {
 	t.Run("connects nodes successfully", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		err := ig.ConnectNodes(inputID, "original", resizeID, "original")
 
@@ -915,11 +988,9 @@ func TestImageGraph_ConnectNodes(t *testing.T) {
 	})
 
 	t.Run("returns error for invalid output name", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		err := ig.ConnectNodes(inputID, "invalid", resizeID, "original")
 
@@ -929,11 +1000,9 @@ func TestImageGraph_ConnectNodes(t *testing.T) {
 	})
 
 	t.Run("returns error for invalid input name", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		err := ig.ConnectNodes(inputID, "original", resizeID, "invalid")
 
@@ -943,11 +1012,9 @@ func TestImageGraph_ConnectNodes(t *testing.T) {
 	})
 
 	t.Run("is idempotent", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		err := ig.ConnectNodes(inputID, "original", resizeID, "original")
 		if err != nil {
@@ -999,11 +1066,9 @@ func TestImageGraph_ConnectNodes(t *testing.T) {
 	})
 
 	t.Run("emits connection events", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 		ig.ResetEvents()
 
 		err := ig.ConnectNodes(inputID, "original", resizeID, "original")
@@ -1074,11 +1139,9 @@ func TestImageGraph_ConnectNodes(t *testing.T) {
 
 func TestImageGraph_DisconnectNodes(t *testing.T) {
 	t.Run("disconnects nodes successfully", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Connect nodes first
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
@@ -1132,11 +1195,9 @@ func TestImageGraph_DisconnectNodes(t *testing.T) {
 	})
 
 	t.Run("returns error for invalid output name", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		err := ig.DisconnectNodes(inputID, "invalid", resizeID, "original")
 
@@ -1146,11 +1207,9 @@ func TestImageGraph_DisconnectNodes(t *testing.T) {
 	})
 
 	t.Run("returns error for invalid input name", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		err := ig.DisconnectNodes(inputID, "original", resizeID, "invalid")
 
@@ -1160,11 +1219,9 @@ func TestImageGraph_DisconnectNodes(t *testing.T) {
 	})
 
 	t.Run("is idempotent", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Connect nodes
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
@@ -1189,11 +1246,9 @@ func TestImageGraph_DisconnectNodes(t *testing.T) {
 	})
 
 	t.Run("emits disconnection events", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Connect nodes first
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
@@ -1221,11 +1276,9 @@ func TestImageGraph_DisconnectNodes(t *testing.T) {
 	})
 
 	t.Run("unsets input image when disconnecting", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Set image first, then connect (ConnectNodes will synchronously propagate)
 		imageID := imagegraph.MustNewImageID()
@@ -1379,11 +1432,9 @@ func TestImageGraph_SetNodeOutputImage(t *testing.T) {
 	})
 
 	t.Run("does not propagate image synchronously (event-driven)", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Connect input → resize
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
@@ -1475,12 +1526,37 @@ func TestImageGraph_SetNodeOutputImage(t *testing.T) {
 		}
 	})
 
-	t.Run("emits only NodeOutputImageSet event (no downstream events)", func(t *testing.T) {
+	t.Run("records uploads on the NodeOutputImageSet event", func(t *testing.T) {
 		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+		ig.ResetEvents()
+
+		upload := imagegraph.UploadedImage{Format: "png", Width: 640, Height: 480, Bytes: 1234}
+
+		err := ig.SetNodeUploadedOutputImage(nodeID, "original", imagegraph.MustNewImageID(), currentNodeVersion(t, ig, nodeID), upload)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+
+		e, ok := events[0].(*imagegraph.NodeOutputImageSetEvent)
+		if !ok {
+			t.Fatalf("expected NodeOutputImageSetEvent, got %T", events[0])
+		}
+		if e.Upload == nil || *e.Upload != upload {
+			t.Errorf("expected upload %+v on the event, got %+v", upload, e.Upload)
+		}
+	})
+
+	t.Run("emits only NodeOutputImageSet event (no downstream events)", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
 		ig.ResetEvents()
@@ -1526,11 +1602,9 @@ func TestImageGraph_SetNodeOutputImage(t *testing.T) {
 	})
 
 	t.Run("does not affect unconnected nodes", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Do NOT connect the nodes
 
@@ -1606,11 +1680,9 @@ func TestImageGraph_UnsetNodeOutputImage(t *testing.T) {
 	})
 
 	t.Run("unsets output image without propagating to downstream", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		// Set image first, then connect (ConnectNodes will synchronously propagate)
 		imageID := imagegraph.MustNewImageID()
@@ -1695,11 +1767,9 @@ func TestImageGraph_UnsetNodeOutputImage(t *testing.T) {
 	})
 
 	t.Run("emits only NodeOutputImageUnset event without downstream propagation", func(t *testing.T) {
-		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
-		inputID := imagegraph.MustNewNodeID()
-		resizeID := imagegraph.MustNewNodeID()
-		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
-		ig.AddNode(resizeID, imagegraph.NodeTypeResize, "resize")
+		b := testsupport.NewGraphBuilder().WithInput().WithNode(imagegraph.NodeTypeResize)
+		ig := b.MustBuild(t)
+		inputID, resizeID := b.NodeID("input"), b.NodeID("resize")
 
 		ig.ConnectNodes(inputID, "original", resizeID, "original")
 		imageID := imagegraph.MustNewImageID()
@@ -1749,4 +1819,2764 @@ func TestImageGraph_UnsetNodeOutputImage(t *testing.T) {
 			t.Fatal("expected error for nil node ID, got nil")
 		}
 	})
+
+	t.Run("rejects unsetting a pinned node's output image", func(t *testing.T) {
+		blurredImageID := imagegraph.MustNewImageID()
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(imagegraph.MustNewImageID()).
+			WithBlur(2).WithImage(blurredImageID).
+			ConnectAll()
+		ig := b.MustBuild(t)
+
+		if err := ig.SetNodePinned(b.NodeID("blur"), true); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err := ig.UnsetNodeOutputImage(b.NodeID("blur"), "blurred")
+		if !errors.Is(err, imagegraph.ErrNodePinned) {
+			t.Fatalf("expected ErrNodePinned, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(b.NodeID("blur"))
+		if imageID, _ := node.Outputs.GetImage("blurred"); imageID != blurredImageID {
+			t.Error("expected the pinned node to keep its output image")
+		}
+	})
+}
+
+func TestImageGraph_OptionalInputs(t *testing.T) {
+	t.Run("generates without optional input connected", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+	})
+
+	t.Run("waits for image when optional input is connected", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		inputID := imagegraph.MustNewNodeID()
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+
+		err := ig.ConnectNodes(inputID, "original", generateID, "reference")
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Waiting {
+			t.Errorf("expected state Waiting, got %v", node.State.Get())
+		}
+
+		imageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, inputID, "original", imageID)
+		err = ig.PropagateOutputImageToConnections(inputID, "original", imageID)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+	})
+
+	t.Run("regenerates when optional input is disconnected", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		inputID := imagegraph.MustNewNodeID()
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+		ig.ConnectNodes(inputID, "original", generateID, "reference")
+		ig.ResetEvents()
+
+		err := ig.DisconnectNodes(inputID, "original", generateID, "reference")
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+
+		foundNeedsOutputs := false
+		for _, event := range ig.GetEvents() {
+			if _, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				foundNeedsOutputs = true
+			}
+		}
+		if !foundNeedsOutputs {
+			t.Error("expected NodeNeedsOutputsEvent to be emitted")
+		}
+	})
+}
+
+func TestImageGraph_SetNodeGenerationFailed(t *testing.T) {
+	t.Run("moves generating node into failed state", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+		ig.ResetEvents()
+
+		version := currentNodeVersion(t, ig, generateID)
+		err := ig.SetNodeGenerationFailed(generateID, "provider unavailable", version)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Failed {
+			t.Errorf("expected state Failed, got %v", node.State.Get())
+		}
+		if node.Error != "provider unavailable" {
+			t.Errorf("expected error %q, got %q", "provider unavailable", node.Error)
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		if _, ok := events[0].(*imagegraph.NodeGenerationFailedEvent); !ok {
+			t.Errorf("expected NodeGenerationFailedEvent, got %T", events[0])
+		}
+	})
+
+	t.Run("ignores failures for stale versions", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+
+		staleVersion := currentNodeVersion(t, ig, generateID)
+
+		config := imagegraph.NewNodeConfigGenerate()
+		config.Prompt = "a lighthouse at dusk"
+		if err := ig.SetNodeConfig(generateID, config); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := ig.SetNodeGenerationFailed(generateID, "newer", currentNodeVersion(t, ig, generateID)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		err := ig.SetNodeGenerationFailed(generateID, "stale", staleVersion)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.Error != "newer" {
+			t.Errorf("expected error %q, got %q", "newer", node.Error)
+		}
+	})
+
+	t.Run("clears failure when generation is retriggered", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+		ig.SetNodeGenerationFailed(generateID, "prompt must be set", currentNodeVersion(t, ig, generateID))
+
+		config := imagegraph.NewNodeConfigGenerate()
+		config.Prompt = "a lighthouse at dusk"
+		if err := ig.SetNodeConfig(generateID, config); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+		if node.Error != "" {
+			t.Errorf("expected error to be cleared, got %q", node.Error)
+		}
+	})
+}
+
+func TestImageGraph_ResumeNodeGeneration(t *testing.T) {
+	t.Run("requests outputs of generating node again", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+		ig.ResetEvents()
+
+		err := ig.ResumeNodeGeneration(generateID)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		event, ok := events[0].(*imagegraph.NodeNeedsOutputsEvent)
+		if !ok {
+			t.Fatalf("expected NodeNeedsOutputsEvent, got %T", events[0])
+		}
+		if event.NodeVersion != currentNodeVersion(t, ig, generateID) {
+			t.Errorf("expected current node version, got %v", event.NodeVersion)
+		}
+	})
+
+	t.Run("leaves node that is no longer generating", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		generateID := imagegraph.MustNewNodeID()
+		ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+		ig.SetNodeGenerationFailed(generateID, "provider unavailable", currentNodeVersion(t, ig, generateID))
+		ig.ResetEvents()
+
+		err := ig.ResumeNodeGeneration(generateID)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(generateID)
+		if node.State.Get() != imagegraph.Failed {
+			t.Errorf("expected state Failed, got %v", node.State.Get())
+		}
+		if events := ig.GetEvents(); len(events) != 0 {
+			t.Errorf("expected no events, got %d", len(events))
+		}
+	})
+
+	t.Run("returns error for unknown node", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+
+		err := ig.ResumeNodeGeneration(imagegraph.MustNewNodeID())
+
+		if err == nil {
+			t.Fatal("expected error for unknown node, got nil")
+		}
+	})
+}
+
+func TestNodeConfigLint(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	inputs := map[imagegraph.InputName]imagegraph.ImageSize{
+		"original": {Width: 200, Height: 100},
+	}
+
+	tests := []struct {
+		name         string
+		config       imagegraph.NodeConfigLinter
+		inputs       map[imagegraph.InputName]imagegraph.ImageSize
+		wantWarnings int
+	}{
+		{
+			name:         "crop within bounds",
+			config:       &imagegraph.NodeConfigCrop{Left: intPtr(10), Right: intPtr(200), Top: intPtr(0), Bottom: intPtr(100)},
+			inputs:       inputs,
+			wantWarnings: 0,
+		},
+		{
+			name:         "crop outside bounds",
+			config:       &imagegraph.NodeConfigCrop{Left: intPtr(10), Right: intPtr(300), Top: intPtr(100), Bottom: intPtr(150)},
+			inputs:       inputs,
+			wantWarnings: 3,
+		},
+		{
+			name:         "crop without input image",
+			config:       &imagegraph.NodeConfigCrop{Right: intPtr(300)},
+			inputs:       nil,
+			wantWarnings: 0,
+		},
+		{
+			name:         "resize within scale factor",
+			config:       &imagegraph.NodeConfigResize{Width: intPtr(800), Interpolation: "Bilinear"},
+			inputs:       inputs,
+			wantWarnings: 0,
+		},
+		{
+			name:         "resize beyond scale factor",
+			config:       &imagegraph.NodeConfigResize{Width: intPtr(5000), Height: intPtr(5), Interpolation: "Bilinear"},
+			inputs:       inputs,
+			wantWarnings: 2,
+		},
+		{
+			name:         "pixel inflate smaller than source",
+			config:       &imagegraph.NodeConfigPixelInflate{Width: 100, LineWidth: 1, LineColor: "#FFFFFF"},
+			inputs:       inputs,
+			wantWarnings: 1,
+		},
+		{
+			name:         "pixel inflate larger than source",
+			config:       &imagegraph.NodeConfigPixelInflate{Width: 1000, LineWidth: 1, LineColor: "#FFFFFF"},
+			inputs:       inputs,
+			wantWarnings: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := tt.config.Lint(tt.inputs)
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("expected %d warnings, got %d: %v", tt.wantWarnings, len(warnings), warnings)
+			}
+		})
+	}
+}
+
+func TestImageGraph_ExternalIDs(t *testing.T) {
+	t.Run("assigns external ID to new graph", func(t *testing.T) {
+		ig, err := imagegraph.NewImageGraph(
+			imagegraph.MustNewImageGraphID(),
+			"test",
+			imagegraph.WithExternalID("asset-1"),
+		)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if ig.ExternalID != "asset-1" {
+			t.Errorf("expected external ID %q, got %q", "asset-1", ig.ExternalID)
+		}
+
+		events := ig.GetEvents()
+		created, ok := events[0].(*imagegraph.CreatedEvent)
+		if !ok {
+			t.Fatalf("expected CreatedEvent, got %T", events[0])
+		}
+		if created.ExternalID != "asset-1" {
+			t.Errorf("expected event external ID %q, got %q", "asset-1", created.ExternalID)
+		}
+	})
+
+	t.Run("finds node by external ID", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+
+		err := ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input", imagegraph.WithNodeExternalID("layer-1"))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, ok := ig.Nodes.FindByExternalID("layer-1")
+		if !ok {
+			t.Fatal("expected node to be found by external ID")
+		}
+		if node.ID != nodeID {
+			t.Errorf("expected node %v, got %v", nodeID, node.ID)
+		}
+	})
+
+	t.Run("rejects duplicate node external ID", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		ig.AddNode(imagegraph.MustNewNodeID(), imagegraph.NodeTypeInput, "input", imagegraph.WithNodeExternalID("layer-1"))
+
+		err := ig.AddNode(imagegraph.MustNewNodeID(), imagegraph.NodeTypeInput, "input", imagegraph.WithNodeExternalID("layer-1"))
+
+		if err == nil {
+			t.Fatal("expected error for duplicate external ID")
+		}
+		if len(ig.Nodes) != 1 {
+			t.Errorf("expected 1 node, got %d", len(ig.Nodes))
+		}
+	})
+}
+
+func TestImageGraph_SetNodeBypassed(t *testing.T) {
+	t.Run("bypassing a ready node requests outputs in bypass mode", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(imagegraph.MustNewImageID()).
+			WithBlur(2).WithImage(imagegraph.MustNewImageID()).
+			ConnectAll()
+		ig := b.MustBuild(t)
+		blurID := b.NodeID("blur")
+
+		err := ig.SetNodeBypassed(blurID, true)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(blurID)
+		if !node.Bypassed {
+			t.Error("expected node to be bypassed")
+		}
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+		if !node.Outputs["blurred"].ImageID.IsNil() {
+			t.Error("expected previous output to be unset")
+		}
+
+		var needsOutputs *imagegraph.NodeNeedsOutputsEvent
+		var bypassSet bool
+		for _, event := range ig.GetEvents() {
+			switch e := event.(type) {
+			case *imagegraph.NodeNeedsOutputsEvent:
+				needsOutputs = e
+			case *imagegraph.NodeBypassSetEvent:
+				bypassSet = e.Bypassed
+			}
+		}
+		if !bypassSet {
+			t.Error("expected NodeBypassSetEvent with bypassed set")
+		}
+		if needsOutputs == nil || !needsOutputs.Bypassed {
+			t.Fatal("expected bypassed NodeNeedsOutputsEvent")
+		}
+
+		setNodeOutput(t, ig, blurID, "blurred", imagegraph.MustNewImageID())
+
+		if node.State.Get() != imagegraph.Generated {
+			t.Errorf("expected state Generated, got %v", node.State.Get())
+		}
+	})
+
+	t.Run("setting the same bypass value emits no events", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		blurID := imagegraph.MustNewNodeID()
+		ig.AddNode(blurID, imagegraph.NodeTypeBlur, "blur")
+		ig.ResetEvents()
+
+		if err := ig.SetNodeBypassed(blurID, false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(ig.GetEvents()) != 0 {
+			t.Errorf("expected no events, got %d", len(ig.GetEvents()))
+		}
+	})
+
+	t.Run("rejects bypass for node types without inputs", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		inputID := imagegraph.MustNewNodeID()
+		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+
+		if err := ig.SetNodeBypassed(inputID, true); err == nil {
+			t.Fatal("expected error bypassing input node")
+		}
+	})
+}
+
+func TestImageGraph_NodeImplementation(t *testing.T) {
+	t.Run("new nodes use the latest implementation", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		paletteID := imagegraph.MustNewNodeID()
+		blurID := imagegraph.MustNewNodeID()
+		ig.AddNode(paletteID, imagegraph.NodeTypePaletteExtract, "palette")
+		ig.AddNode(blurID, imagegraph.NodeTypeBlur, "blur")
+
+		latest := imagegraph.NodeTypeDefs[imagegraph.NodeTypePaletteExtract].LatestImplementation()
+		if latest < 2 {
+			t.Fatalf("expected palette extract to have multiple implementations, got %d", latest)
+		}
+		if ig.Nodes[paletteID].Implementation != latest {
+			t.Errorf("expected implementation %d, got %d", latest, ig.Nodes[paletteID].Implementation)
+		}
+		if ig.Nodes[blurID].Implementation != 1 {
+			t.Errorf("expected implementation 1, got %d", ig.Nodes[blurID].Implementation)
+		}
+	})
+
+	t.Run("selecting an older implementation regenerates outputs", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		inputID := imagegraph.MustNewNodeID()
+		paletteID := imagegraph.MustNewNodeID()
+		ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+		ig.AddNode(paletteID, imagegraph.NodeTypePaletteExtract, "palette")
+		ig.ConnectNodes(inputID, "original", paletteID, "source")
+		setNodeOutput(t, ig, inputID, "original", imagegraph.MustNewImageID())
+		ig.PropagateOutputImageToConnections(inputID, "original", ig.Nodes[inputID].Outputs["original"].ImageID)
+		setNodeOutput(t, ig, paletteID, "palette", imagegraph.MustNewImageID())
+		ig.ResetEvents()
+
+		if err := ig.SetNodeImplementation(paletteID, 1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(paletteID)
+		if node.Implementation != 1 {
+			t.Errorf("expected implementation 1, got %d", node.Implementation)
+		}
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+		if !node.Outputs["palette"].ImageID.IsNil() {
+			t.Error("expected previous output to be unset")
+		}
+
+		var needsOutputs *imagegraph.NodeNeedsOutputsEvent
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				needsOutputs = e
+			}
+		}
+		if needsOutputs == nil || needsOutputs.Implementation != 1 {
+			t.Fatal("expected NodeNeedsOutputsEvent for implementation 1")
+		}
+	})
+
+	t.Run("upgrade moves a node to the latest implementation", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		paletteID := imagegraph.MustNewNodeID()
+		ig.AddNode(paletteID, imagegraph.NodeTypePaletteExtract, "palette")
+		ig.SetNodeImplementation(paletteID, 1)
+		ig.ResetEvents()
+
+		if err := ig.UpgradeNodeImplementation(paletteID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		latest := imagegraph.NodeTypeDefs[imagegraph.NodeTypePaletteExtract].LatestImplementation()
+		if ig.Nodes[paletteID].Implementation != latest {
+			t.Errorf("expected implementation %d, got %d", latest, ig.Nodes[paletteID].Implementation)
+		}
+
+		var implementationSet bool
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeImplementationSetEvent); ok {
+				implementationSet = e.Implementation == latest
+			}
+		}
+		if !implementationSet {
+			t.Error("expected NodeImplementationSetEvent for the latest implementation")
+		}
+	})
+
+	t.Run("rejects unknown implementations", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		blurID := imagegraph.MustNewNodeID()
+		ig.AddNode(blurID, imagegraph.NodeTypeBlur, "blur")
+
+		if err := ig.SetNodeImplementation(blurID, 2); err == nil {
+			t.Error("expected error selecting implementation 2 for blur node")
+		}
+		if err := ig.SetNodeImplementation(blurID, 0); err == nil {
+			t.Error("expected error selecting implementation 0")
+		}
+	})
+}
+
+func TestImageGraph_Complexity(t *testing.T) {
+	ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+	inputID := imagegraph.MustNewNodeID()
+	blurID := imagegraph.MustNewNodeID()
+	outputID := imagegraph.MustNewNodeID()
+	ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+	ig.AddNode(blurID, imagegraph.NodeTypeBlur, "blur")
+	ig.AddNode(outputID, imagegraph.NodeTypeOutput, "output")
+	ig.ConnectNodes(inputID, "original", blurID, "original")
+	ig.ConnectNodes(blurID, "blurred", outputID, "input")
+
+	complexity := ig.Complexity()
+
+	if complexity.Nodes != 3 {
+		t.Errorf("expected 3 nodes, got %d", complexity.Nodes)
+	}
+	if complexity.Connections != 2 {
+		t.Errorf("expected 2 connections, got %d", complexity.Connections)
+	}
+	// The input node has no inputs, so it starts generating when added
+	if complexity.PendingGenerations != 1 {
+		t.Errorf("expected 1 pending generation, got %d", complexity.PendingGenerations)
+	}
+
+	setNodeOutput(t, ig, inputID, "original", imagegraph.MustNewImageID())
+	ig.PropagateOutputImageToConnections(inputID, "original", ig.Nodes[inputID].Outputs["original"].ImageID)
+
+	complexity = ig.Complexity()
+
+	if complexity.PendingGenerations != 1 {
+		t.Errorf("expected only the blur node to be pending, got %d", complexity.PendingGenerations)
+	}
+	if ig.Nodes[blurID].State.Get() != imagegraph.Generating {
+		t.Errorf("expected blur node to be generating, got %v", ig.Nodes[blurID].State.Get())
+	}
+}
+
+func TestImageGraph_SetNodePinned(t *testing.T) {
+	setup := func(t *testing.T) (*imagegraph.ImageGraph, imagegraph.NodeID, imagegraph.NodeID, imagegraph.ImageID) {
+		t.Helper()
+
+		blurredImageID := imagegraph.MustNewImageID()
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(imagegraph.MustNewImageID()).
+			WithBlur(2).WithImage(blurredImageID).
+			ConnectAll()
+
+		return b.MustBuild(t), b.NodeID("input"), b.NodeID("blur"), blurredImageID
+	}
+
+	t.Run("pinned node keeps its outputs when upstream changes", func(t *testing.T) {
+		ig, inputID, blurID, blurredImageID := setup(t)
+
+		if err := ig.SetNodePinned(blurID, true); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		ig.ResetEvents()
+
+		newImageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, inputID, "original", newImageID)
+		ig.PropagateOutputImageToConnections(inputID, "original", newImageID)
+
+		node, _ := ig.Nodes.Get(blurID)
+		if node.State.Get() != imagegraph.Generated {
+			t.Errorf("expected state Generated, got %v", node.State.Get())
+		}
+		if node.Outputs["blurred"].ImageID != blurredImageID {
+			t.Error("expected pinned output to be unchanged")
+		}
+		if !node.Stale {
+			t.Error("expected pinned node to be stale")
+		}
+
+		var suppressed bool
+		for _, event := range ig.GetEvents() {
+			switch event.(type) {
+			case *imagegraph.NodeNeedsOutputsEvent:
+				t.Error("expected no NodeNeedsOutputsEvent for pinned node")
+			case *imagegraph.NodeRegenerationSuppressedEvent:
+				suppressed = true
+			}
+		}
+		if !suppressed {
+			t.Error("expected NodeRegenerationSuppressedEvent")
+		}
+	})
+
+	t.Run("unpinning a stale node regenerates its outputs", func(t *testing.T) {
+		ig, inputID, blurID, _ := setup(t)
+		ig.SetNodePinned(blurID, true)
+
+		newImageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, inputID, "original", newImageID)
+		ig.PropagateOutputImageToConnections(inputID, "original", newImageID)
+		ig.ResetEvents()
+
+		if err := ig.SetNodePinned(blurID, false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(blurID)
+		if node.Stale {
+			t.Error("expected node to no longer be stale")
+		}
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+
+		var needsOutputs bool
+		for _, event := range ig.GetEvents() {
+			if _, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				needsOutputs = true
+			}
+		}
+		if !needsOutputs {
+			t.Error("expected NodeNeedsOutputsEvent after unpinning")
+		}
+	})
+
+	t.Run("rejects pinning a node without generated outputs", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		blurID := imagegraph.MustNewNodeID()
+		ig.AddNode(blurID, imagegraph.NodeTypeBlur, "blur")
+
+		if err := ig.SetNodePinned(blurID, true); err == nil {
+			t.Fatal("expected error pinning waiting node")
+		}
+	})
+}
+
+func TestImageGraph_Exports(t *testing.T) {
+	setup := func(t *testing.T) (*imagegraph.ImageGraph, imagegraph.NodeID, imagegraph.NodeID) {
+		t.Helper()
+
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(imagegraph.MustNewImageID()).
+			WithOutput().Named("poster").
+			WithOutput().Named("thumbnail").
+			Connect("input", "poster").
+			Connect("input", "thumbnail")
+
+		return b.MustBuild(t), b.NodeID("poster"), b.NodeID("thumbnail")
+	}
+
+	t.Run("lists generated output images by export name", func(t *testing.T) {
+		ig, posterID, thumbID := setup(t)
+
+		config := imagegraph.NewNodeConfigOutput()
+		config.ExportName = "a-cover"
+		if err := ig.SetNodeConfig(thumbID, config); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		posterImageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, posterID, "final", posterImageID)
+
+		exports := ig.Exports()
+		if len(exports) != 1 {
+			t.Fatalf("expected 1 export before thumbnail is generated, got %d", len(exports))
+		}
+
+		setNodeOutput(t, ig, thumbID, "final", imagegraph.MustNewImageID())
+
+		exports = ig.Exports()
+		if len(exports) != 2 {
+			t.Fatalf("expected 2 exports, got %d", len(exports))
+		}
+		if exports[0].Name != "a-cover" || exports[1].Name != "poster" {
+			t.Errorf("expected exports [a-cover poster], got [%s %s]", exports[0].Name, exports[1].Name)
+		}
+		if exports[1].ImageID != posterImageID {
+			t.Errorf("expected poster image %v, got %v", posterImageID, exports[1].ImageID)
+		}
+		if exports[1].GeneratedAt.IsZero() {
+			t.Error("expected export to record when it was generated")
+		}
+	})
+
+	t.Run("uses preview of first export as thumbnail", func(t *testing.T) {
+		ig, posterID, thumbID := setup(t)
+
+		if !ig.Thumbnail().IsNil() {
+			t.Fatalf("expected no thumbnail before outputs are generated, got %v", ig.Thumbnail())
+		}
+
+		setPreview := func(nodeID imagegraph.NodeID) imagegraph.ImageID {
+			t.Helper()
+			setNodeOutput(t, ig, nodeID, "final", imagegraph.MustNewImageID())
+			previewID := imagegraph.MustNewImageID()
+			if err := ig.SetNodePreview(nodeID, previewID, currentNodeVersion(t, ig, nodeID)); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			return previewID
+		}
+
+		thumbPreviewID := setPreview(thumbID)
+		if ig.Thumbnail() != thumbPreviewID {
+			t.Errorf("expected thumbnail %v, got %v", thumbPreviewID, ig.Thumbnail())
+		}
+
+		posterPreviewID := setPreview(posterID)
+		if ig.Thumbnail() != posterPreviewID {
+			t.Errorf("expected thumbnail of first export %v, got %v", posterPreviewID, ig.Thumbnail())
+		}
+	})
+
+	t.Run("rejects duplicate export names", func(t *testing.T) {
+		ig, _, thumbID := setup(t)
+
+		config := imagegraph.NewNodeConfigOutput()
+		config.ExportName = "poster"
+
+		if err := ig.SetNodeConfig(thumbID, config); err == nil {
+			t.Fatal("expected error for export name used by another output node")
+		}
+	})
+
+	t.Run("rejects invalid export names", func(t *testing.T) {
+		config := imagegraph.NewNodeConfigOutput()
+		config.ExportName = "../poster"
+
+		if err := config.Validate(); err == nil {
+			t.Fatal("expected error for export name with path characters")
+		}
+	})
+
+	t.Run("rejects unknown animation formats", func(t *testing.T) {
+		config := imagegraph.NewNodeConfigOutput()
+		config.AnimationFormat = "webp"
+
+		if err := config.Validate(); err == nil {
+			t.Fatal("expected error for unknown animation format")
+		}
+
+		config.AnimationFormat = "gif"
+		if err := config.Validate(); err != nil {
+			t.Fatalf("expected no error for gif, got %v", err)
+		}
+	})
+}
+
+func TestImageGraph_PipelineComplete(t *testing.T) {
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(imagegraph.MustNewImageID()).
+		WithOutput().Named("poster").
+		WithOutput().Named("thumbnail").
+		Connect("input", "poster").
+		Connect("input", "thumbnail")
+
+	ig := b.MustBuild(t)
+	inputID, posterID, thumbID := b.NodeID("input"), b.NodeID("poster"), b.NodeID("thumbnail")
+
+	check := func(t *testing.T, settled, complete bool) {
+		t.Helper()
+		if got := ig.Settled(); got != settled {
+			t.Errorf("expected Settled() = %v, got %v", settled, got)
+		}
+		if got := ig.PipelineComplete(); got != complete {
+			t.Errorf("expected PipelineComplete() = %v, got %v", complete, got)
+		}
+	}
+
+	t.Run("is incomplete while output nodes generate", func(t *testing.T) {
+		check(t, false, false)
+
+		setNodeOutput(t, ig, posterID, "final", imagegraph.MustNewImageID())
+		check(t, false, false)
+	})
+
+	t.Run("is complete once every output node is generated", func(t *testing.T) {
+		setNodeOutput(t, ig, thumbID, "final", imagegraph.MustNewImageID())
+		check(t, true, true)
+	})
+
+	t.Run("is incomplete until a new image has propagated", func(t *testing.T) {
+		imageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, inputID, "original", imageID)
+		check(t, false, false)
+
+		if err := ig.PropagateOutputImageToConnections(inputID, "original", imageID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		check(t, false, false)
+
+		setNodeOutput(t, ig, posterID, "final", imagegraph.MustNewImageID())
+		setNodeOutput(t, ig, thumbID, "final", imagegraph.MustNewImageID())
+		check(t, true, true)
+	})
+
+	t.Run("is never complete without output nodes", func(t *testing.T) {
+		ig := testsupport.NewGraphBuilder().WithInput().MustBuild(t)
+
+		if !ig.Settled() {
+			t.Error("expected an idle graph to be settled")
+		}
+		if ig.PipelineComplete() {
+			t.Error("expected a graph without output nodes to be incomplete")
+		}
+	})
+}
+
+func TestImageGraph_GeneratingSince(t *testing.T) {
+	ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+	inputID := imagegraph.MustNewNodeID()
+	generateID := imagegraph.MustNewNodeID()
+	ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+	ig.AddNode(generateID, imagegraph.NodeTypeGenerate, "generate")
+
+	t.Run("returns nodes generating since before the cutoff", func(t *testing.T) {
+		nodes := ig.GeneratingSince(time.Now().Add(time.Minute))
+
+		if len(nodes) != 1 {
+			t.Fatalf("expected 1 node, got %d", len(nodes))
+		}
+		if nodes[0].ID != generateID {
+			t.Errorf("expected generate node, got %v", nodes[0].ID)
+		}
+	})
+
+	t.Run("ignores nodes that changed after the cutoff", func(t *testing.T) {
+		if nodes := ig.GeneratingSince(time.Now().Add(-time.Minute)); len(nodes) != 0 {
+			t.Errorf("expected no nodes, got %d", len(nodes))
+		}
+	})
+
+	t.Run("ignores nodes that are no longer generating", func(t *testing.T) {
+		ig.SetNodeGenerationFailed(generateID, "provider unavailable", currentNodeVersion(t, ig, generateID))
+
+		if nodes := ig.GeneratingSince(time.Now().Add(time.Minute)); len(nodes) != 0 {
+			t.Errorf("expected no nodes, got %d", len(nodes))
+		}
+	})
+}
+
+func TestImageGraph_Regenerate(t *testing.T) {
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(imagegraph.MustNewImageID()).
+		WithBlur(3).
+		WithOutput().
+		ConnectAll()
+	ig := b.MustBuild(t)
+	inputID, blurID, outputID := b.NodeID("input"), b.NodeID("blur"), b.NodeID("output")
+	blurredID := imagegraph.MustNewImageID()
+	setNodeOutput(t, ig, blurID, "blurred", blurredID)
+	if err := ig.PropagateOutputImageToConnections(blurID, "blurred", blurredID); err != nil {
+		t.Fatalf("failed to propagate: %v", err)
+	}
+	setNodeOutput(t, ig, outputID, "final", imagegraph.MustNewImageID())
+	ig.ResetEvents()
+
+	if err := ig.Regenerate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var regenerated []imagegraph.NodeID
+	for _, event := range ig.GetEvents() {
+		if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+			regenerated = append(regenerated, e.NodeID)
+		}
+	}
+	if len(regenerated) != 1 || regenerated[0] != blurID {
+		t.Errorf("expected only the node fed by the input node to regenerate, got %v", regenerated)
+	}
+
+	input, _ := ig.Nodes.Get(inputID)
+	if !input.Outputs.AllSet() {
+		t.Error("expected the input node to keep its image")
+	}
+}
+
+func TestImageGraph_ColorManagement(t *testing.T) {
+	t.Run("new graphs preserve profiles", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+
+		if ig.ColorManagement != imagegraph.ColorManagementPreserve {
+			t.Errorf("expected color management %q, got %q", imagegraph.ColorManagementPreserve, ig.ColorManagement)
+		}
+	})
+
+	t.Run("regenerates output nodes with the new setting", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(imagegraph.MustNewImageID()).
+			WithOutput().
+			ConnectAll()
+		ig := b.MustBuild(t)
+		outputID := b.NodeID("output")
+		setNodeOutput(t, ig, outputID, "final", imagegraph.MustNewImageID())
+		ig.ResetEvents()
+
+		if err := ig.SetColorManagement(imagegraph.ColorManagementConvertSRGB); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(outputID)
+		if node.State.Get() != imagegraph.Generating {
+			t.Errorf("expected state Generating, got %v", node.State.Get())
+		}
+
+		var needsOutputs *imagegraph.NodeNeedsOutputsEvent
+		var colorManagementSet bool
+		for _, event := range ig.GetEvents() {
+			switch e := event.(type) {
+			case *imagegraph.NodeNeedsOutputsEvent:
+				needsOutputs = e
+			case *imagegraph.ColorManagementSetEvent:
+				colorManagementSet = e.ColorManagement == imagegraph.ColorManagementConvertSRGB
+			}
+		}
+		if !colorManagementSet {
+			t.Error("expected ColorManagementSetEvent with the new setting")
+		}
+		if needsOutputs == nil || needsOutputs.ColorManagement != imagegraph.ColorManagementConvertSRGB {
+			t.Fatal("expected NodeNeedsOutputsEvent carrying the new setting")
+		}
+	})
+
+	t.Run("setting the same value emits no events", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		ig.ResetEvents()
+
+		if err := ig.SetColorManagement(imagegraph.ColorManagementPreserve); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(ig.GetEvents()) != 0 {
+			t.Errorf("expected no events, got %d", len(ig.GetEvents()))
+		}
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+
+		if err := ig.SetColorManagement("cmyk"); err == nil {
+			t.Fatal("expected error for unknown color management setting")
+		}
+	})
+}
+
+func TestImageGraph_PerformanceMode(t *testing.T) {
+	t.Run("skips previews of intermediate nodes only", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().
+			WithBlur(2).
+			WithOutput().
+			ConnectAll()
+		ig := b.MustBuild(t)
+		blurID, outputID := b.NodeID("blur"), b.NodeID("output")
+
+		if err := ig.SetPerformanceMode(true); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		needsOutputs := func() map[imagegraph.NodeID]*imagegraph.NodeNeedsOutputsEvent {
+			events := map[imagegraph.NodeID]*imagegraph.NodeNeedsOutputsEvent{}
+			for _, event := range ig.GetEvents() {
+				if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+					events[e.NodeID] = e
+				}
+			}
+			return events
+		}
+
+		ig.ResetEvents()
+		inputImageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, b.NodeID("input"), "original", inputImageID)
+		ig.PropagateOutputImageToConnections(b.NodeID("input"), "original", inputImageID)
+
+		if e := needsOutputs()[blurID]; e == nil || !e.SkipPreview {
+			t.Error("expected the blur node to skip its preview")
+		}
+
+		ig.ResetEvents()
+		blurredImageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, blurID, "blurred", blurredImageID)
+		ig.PropagateOutputImageToConnections(blurID, "blurred", blurredImageID)
+
+		if e := needsOutputs()[outputID]; e == nil || e.SkipPreview {
+			t.Error("expected the output node to keep its preview")
+		}
+	})
+
+	t.Run("setting the same value emits no events", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		ig.ResetEvents()
+
+		if err := ig.SetPerformanceMode(false); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(ig.GetEvents()) != 0 {
+			t.Errorf("expected no events, got %d", len(ig.GetEvents()))
+		}
+	})
+}
+
+func TestImageGraph_Tags(t *testing.T) {
+	t.Run("normalizes and sorts graph tags", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		ig.ResetEvents()
+
+		for _, tag := range []string{"PixelArt", " landscape ", "pixelart"} {
+			if err := ig.AddTag(tag); err != nil {
+				t.Fatalf("expected no error adding %q, got %v", tag, err)
+			}
+		}
+
+		if len(ig.Tags) != 2 || ig.Tags[0] != "landscape" || ig.Tags[1] != "pixelart" {
+			t.Errorf("expected tags [landscape pixelart], got %v", ig.Tags)
+		}
+
+		if events := ig.GetEvents(); len(events) != 2 {
+			t.Errorf("expected 2 events for 2 distinct tags, got %d", len(events))
+		}
+	})
+
+	t.Run("removes graph tags", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		ig.AddTag("pixelart")
+		ig.ResetEvents()
+
+		if err := ig.RemoveTag("PIXELART"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if ig.Tags.Has("pixelart") {
+			t.Error("expected tag to be removed")
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		if _, ok := events[0].(*imagegraph.TagRemovedEvent); !ok {
+			t.Errorf("expected TagRemovedEvent, got %T", events[0])
+		}
+
+		ig.ResetEvents()
+		if err := ig.RemoveTag("pixelart"); err != nil {
+			t.Fatalf("expected removing a missing tag to succeed, got %v", err)
+		}
+		if events := ig.GetEvents(); len(events) != 0 {
+			t.Errorf("expected no events, got %d", len(events))
+		}
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+
+		for _, tag := range []string{"", "   ", "has space", "emoji🙂"} {
+			if err := ig.AddTag(tag); err == nil {
+				t.Errorf("expected error adding %q, got nil", tag)
+			}
+		}
+	})
+
+	t.Run("tags nodes", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+		ig.ResetEvents()
+
+		if err := ig.AddNodeTag(nodeID, "source"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		node, _ := ig.Nodes.Get(nodeID)
+		if !node.Tags.Has("source") {
+			t.Errorf("expected node to be tagged source, got %v", node.Tags)
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(events))
+		}
+		if _, ok := events[0].(*imagegraph.NodeTagAddedEvent); !ok {
+			t.Errorf("expected NodeTagAddedEvent, got %T", events[0])
+		}
+
+		if err := ig.RemoveNodeTag(nodeID, "source"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if node.Tags.Has("source") {
+			t.Error("expected node tag to be removed")
+		}
+	})
+
+	t.Run("returns error tagging non-existent node", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+
+		if err := ig.AddNodeTag(imagegraph.MustNewNodeID(), "source"); err == nil {
+			t.Fatal("expected error for non-existent node, got nil")
+		}
+	})
+}
+
+func TestImageGraph_Shares(t *testing.T) {
+	newGraph := func() *imagegraph.ImageGraph {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test", imagegraph.WithOwner("alice"))
+		ig.ResetEvents()
+		return ig
+	}
+
+	t.Run("grants and replaces roles", func(t *testing.T) {
+		ig := newGraph()
+
+		if err := ig.Share("bob", imagegraph.RoleViewer); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if role := ig.RoleOf("bob"); role != imagegraph.RoleViewer {
+			t.Errorf("expected bob to be a viewer, got %v", role)
+		}
+
+		clone := ig.Clone()
+
+		if err := ig.Share("bob", imagegraph.RoleEditor); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if role := ig.RoleOf("bob"); role != imagegraph.RoleEditor {
+			t.Errorf("expected bob to be an editor, got %v", role)
+		}
+		if role := clone.RoleOf("bob"); role != imagegraph.RoleViewer {
+			t.Errorf("expected the clone to keep bob as a viewer, got %v", role)
+		}
+
+		if err := ig.Share("bob", imagegraph.RoleEditor); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		events := ig.GetEvents()
+		if len(events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(events))
+		}
+		if _, ok := events[1].(*imagegraph.SharedEvent); !ok {
+			t.Errorf("expected SharedEvent, got %T", events[1])
+		}
+	})
+
+	t.Run("reports roles", func(t *testing.T) {
+		ig := newGraph()
+		ig.Share("bob", imagegraph.RoleEditor)
+
+		for userID, expected := range map[string]imagegraph.Role{
+			"alice": imagegraph.RoleOwner,
+			"bob":   imagegraph.RoleEditor,
+			"carol": imagegraph.RoleNone,
+			"":      imagegraph.RoleNone,
+		} {
+			if role := ig.RoleOf(userID); role != expected {
+				t.Errorf("expected %q to have role %v, got %v", userID, expected, role)
+			}
+		}
+	})
+
+	t.Run("revokes roles", func(t *testing.T) {
+		ig := newGraph()
+		ig.Share("bob", imagegraph.RoleViewer)
+		ig.ResetEvents()
+
+		if err := ig.Unshare("bob"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if role := ig.RoleOf("bob"); role != imagegraph.RoleNone {
+			t.Errorf("expected bob to have no role, got %v", role)
+		}
+		if ig.Shares != nil {
+			t.Errorf("expected no shares, got %v", ig.Shares)
+		}
+
+		if err := ig.Unshare("bob"); err != nil {
+			t.Fatalf("expected unsharing twice to succeed, got %v", err)
+		}
+		if events := ig.GetEvents(); len(events) != 1 {
+			t.Errorf("expected 1 event, got %d", len(events))
+		}
+	})
+
+	t.Run("rejects invalid shares", func(t *testing.T) {
+		ig := newGraph()
+
+		if err := ig.Share("", imagegraph.RoleViewer); err == nil {
+			t.Error("expected error sharing with an empty user ID")
+		}
+		if err := ig.Share("bob", imagegraph.RoleOwner); err == nil {
+			t.Error("expected error granting the owner role")
+		}
+		if err := ig.Share("bob", imagegraph.RoleNone); err == nil {
+			t.Error("expected error granting no role")
+		}
+		if err := ig.Share("alice", imagegraph.RoleViewer); err == nil {
+			t.Error("expected error sharing with the owner")
+		}
+	})
+}
+
+func TestImageGraph_Search(t *testing.T) {
+	b := testsupport.NewGraphBuilder().
+		WithName("Sunset Landscape").
+		WithInput().Named("Sunset Photo").
+		WithBlur(3).Named("Soften")
+	ig := b.MustBuild(t)
+	ig.AddTag("landscape")
+	ig.AddNodeTag(b.NodeID("Soften"), "sunset-fx")
+
+	t.Run("matches names and tags of the graph and its nodes", func(t *testing.T) {
+		matches := ig.Search("sunset")
+		if len(matches) != 3 {
+			t.Fatalf("expected 3 matches, got %d: %+v", len(matches), matches)
+		}
+
+		// Prefix matches on the graph come before those on its nodes
+		if !matches[0].NodeID.IsNil() || matches[0].Field != imagegraph.SearchFieldName {
+			t.Errorf("expected the graph name first, got %+v", matches[0])
+		}
+		if matches[1].NodeID != b.NodeID("Sunset Photo") || matches[1].Field != imagegraph.SearchFieldName {
+			t.Errorf("expected the input node name second, got %+v", matches[1])
+		}
+		if matches[2].NodeID != b.NodeID("Soften") || matches[2].Field != imagegraph.SearchFieldTag {
+			t.Errorf("expected the blur node tag third, got %+v", matches[2])
+		}
+	})
+
+	t.Run("lists prefix matches before substring matches", func(t *testing.T) {
+		matches := ig.Search("land")
+		if len(matches) != 2 {
+			t.Fatalf("expected 2 matches, got %d: %+v", len(matches), matches)
+		}
+		if !matches[0].Prefix || matches[0].Field != imagegraph.SearchFieldTag {
+			t.Errorf("expected the prefix tag match first, got %+v", matches[0])
+		}
+		if matches[1].Prefix || matches[1].Value != "Sunset Landscape" {
+			t.Errorf("expected the substring name match second, got %+v", matches[1])
+		}
+	})
+
+	t.Run("returns no matches for unknown queries", func(t *testing.T) {
+		if matches := ig.Search("portrait"); len(matches) != 0 {
+			t.Errorf("expected no matches, got %+v", matches)
+		}
+	})
+
+	t.Run("normalizes queries", func(t *testing.T) {
+		query, err := imagegraph.NormalizeSearchQuery("  SunSet ")
+		if err != nil || query != "sunset" {
+			t.Errorf("expected sunset, got %q (%v)", query, err)
+		}
+
+		if _, err := imagegraph.NormalizeSearchQuery("   "); err == nil {
+			t.Error("expected error for an empty query, got nil")
+		}
+	})
+}
+
+func TestImageGraph_Timestamps(t *testing.T) {
+	before := time.Now()
+	b := testsupport.NewGraphBuilder().WithInput().WithBlur(3).ConnectAll()
+	ig := b.MustBuild(t)
+
+	if ig.CreatedAt.Before(before) || ig.UpdatedAt.Before(ig.CreatedAt) {
+		t.Fatalf("expected graph timestamps after %v, got created %v updated %v", before, ig.CreatedAt, ig.UpdatedAt)
+	}
+
+	input, _ := ig.Nodes.Get(b.NodeID("input"))
+	blur, _ := ig.Nodes.Get(b.NodeID("blur"))
+	if input.CreatedAt.Before(ig.CreatedAt) || blur.CreatedAt.Before(input.CreatedAt) {
+		t.Errorf("expected nodes to be created in order after the graph, got %v and %v", input.CreatedAt, blur.CreatedAt)
+	}
+
+	graphCreated, inputUpdated, blurUpdated := ig.CreatedAt, input.UpdatedAt, blur.UpdatedAt
+
+	if err := ig.SetNodeName(b.NodeID("blur"), "soften"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if ig.CreatedAt != graphCreated {
+		t.Errorf("expected graph creation time to be unchanged, got %v", ig.CreatedAt)
+	}
+	if !blur.UpdatedAt.After(blurUpdated) {
+		t.Errorf("expected renamed node to be updated after %v, got %v", blurUpdated, blur.UpdatedAt)
+	}
+	if ig.UpdatedAt != blur.UpdatedAt {
+		t.Errorf("expected graph to be updated with its node at %v, got %v", blur.UpdatedAt, ig.UpdatedAt)
+	}
+	if input.UpdatedAt != inputUpdated {
+		t.Errorf("expected untouched node to keep its update time, got %v", input.UpdatedAt)
+	}
+}
+
+func TestImageLimits_Check(t *testing.T) {
+	limits := imagegraph.ImageLimits{MaxWidth: 1000, MaxHeight: 800, MaxPixels: 500000}
+
+	tests := []struct {
+		size    imagegraph.ImageSize
+		wantErr bool
+	}{
+		{imagegraph.ImageSize{Width: 700, Height: 700}, false},
+		{imagegraph.ImageSize{Width: 1001, Height: 10}, true},
+		{imagegraph.ImageSize{Width: 10, Height: 801}, true},
+		{imagegraph.ImageSize{Width: 1000, Height: 800}, true},
+	}
+
+	for _, tt := range tests {
+		if err := limits.Check(tt.size); (err != nil) != tt.wantErr {
+			t.Errorf("expected error %v for %v, got %v", tt.wantErr, tt.size, err)
+		}
+	}
+
+	if err := (imagegraph.ImageLimits{}).Check(imagegraph.ImageSize{Width: 30000, Height: 30000}); err != nil {
+		t.Errorf("expected zero limits to be unlimited, got %v", err)
+	}
+}
+
+func TestImageLimits_CheckFrames(t *testing.T) {
+	limits := imagegraph.ImageLimits{MaxPixels: 500000}
+	size := imagegraph.ImageSize{Width: 500, Height: 500}
+
+	if err := limits.CheckFrames(size, 2); err != nil {
+		t.Errorf("expected frames within the limit to be allowed, got %v", err)
+	}
+	if err := limits.CheckFrames(size, 3); err == nil {
+		t.Error("expected frames past the limit together to be rejected")
+	}
+	if err := limits.CheckFrames(imagegraph.ImageSize{Width: 1000, Height: 1000}, 1); err == nil {
+		t.Error("expected a single frame past the limit to be rejected")
+	}
+	if err := (imagegraph.ImageLimits{}).CheckFrames(size, 1<<30); err != nil {
+		t.Errorf("expected zero limits to be unlimited, got %v", err)
+	}
+}
+
+func TestImageGraph_ConnectionKinds(t *testing.T) {
+	b := testsupport.NewGraphBuilder().
+		WithInput().
+		WithNode(imagegraph.NodeTypePaletteExtract).
+		WithNode(imagegraph.NodeTypePaletteApply).
+		WithNode(imagegraph.NodeTypeResize).
+		WithOutput().
+		Connect("input", "palette_extract").
+		ConnectPorts("palette_extract", "palette", "output", "input")
+	ig := b.MustBuild(t)
+
+	t.Run("rejects palettes on raster inputs", func(t *testing.T) {
+		err := ig.ConnectNodes(b.NodeID("palette_extract"), "palette", b.NodeID("resize"), "original")
+		if !errors.Is(err, imagegraph.ErrIncompatibleImageKinds) {
+			t.Fatalf("expected ErrIncompatibleImageKinds, got %v", err)
+		}
+		if connected, _ := ig.Nodes[b.NodeID("resize")].IsInputConnected("original"); connected {
+			t.Error("expected the rejected connection not to be made")
+		}
+	})
+
+	t.Run("rejects rasters on palette inputs", func(t *testing.T) {
+		err := ig.ConnectNodes(b.NodeID("input"), "original", b.NodeID("palette_apply"), "palette")
+		if !errors.Is(err, imagegraph.ErrIncompatibleImageKinds) {
+			t.Fatalf("expected ErrIncompatibleImageKinds, got %v", err)
+		}
+	})
+
+	t.Run("follows outputs that pass on their input's kind", func(t *testing.T) {
+		err := ig.ConnectNodes(b.NodeID("output"), "final", b.NodeID("resize"), "original")
+		if !errors.Is(err, imagegraph.ErrIncompatibleImageKinds) {
+			t.Fatalf("expected the output of a palette to be rejected, got %v", err)
+		}
+
+		if err := ig.ConnectNodes(b.NodeID("output"), "final", b.NodeID("palette_apply"), "palette"); err != nil {
+			t.Fatalf("expected the output of a palette to connect to a palette input, got %v", err)
+		}
+	})
+
+	t.Run("accepts matching kinds", func(t *testing.T) {
+		if err := ig.ConnectNodes(b.NodeID("input"), "original", b.NodeID("palette_apply"), "source"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := ig.ConnectNodes(b.NodeID("palette_apply"), "mapped", b.NodeID("resize"), "original"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+}
+
+func TestImageGraph_ValidateConnections(t *testing.T) {
+	base, compare := imagegraph.MustNewImageID(), imagegraph.MustNewImageID()
+
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(base).
+		WithInput().WithImage(compare).
+		WithBlur(3).
+		WithNode(imagegraph.NodeTypeDiff).
+		Connect("input", "blur").
+		ConnectPorts("blur", "blurred", "diff", "base").
+		ConnectPorts("input2", "original", "diff", "compare")
+	ig := b.MustBuild(t)
+
+	t.Run("propagates sizes through nodes that preserve them", func(t *testing.T) {
+		validations := ig.ValidateConnections(map[imagegraph.ImageID]imagegraph.ImageSize{
+			base:    {Width: 640, Height: 480},
+			compare: {Width: 320, Height: 240},
+		})
+
+		if len(validations) != 1 {
+			t.Fatalf("expected 1 connection with problems, got %+v", validations)
+		}
+		v := validations[0]
+		if v.ToNodeID != b.NodeID("diff") || v.InputName != "compare" {
+			t.Errorf("expected the diff compare connection, got %s %s", v.ToNodeID, v.InputName)
+		}
+		if len(v.Errors) != 0 || len(v.Warnings) != 1 {
+			t.Errorf("expected a size warning and no errors, got %+v", v)
+		}
+	})
+
+	t.Run("skips sizes that aren't known", func(t *testing.T) {
+		validations := ig.ValidateConnections(map[imagegraph.ImageID]imagegraph.ImageSize{
+			compare: {Width: 320, Height: 240},
+		})
+		if len(validations) != 0 {
+			t.Errorf("expected no problems, got %+v", validations)
+		}
+	})
+}
+
+func TestImageGraph_Diagnostics(t *testing.T) {
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(imagegraph.MustNewImageID()).
+		WithBlur(3).
+		WithOutput().
+		WithResize(100).
+		WithOutput().
+		Connect("input", "blur").
+		Connect("blur", "output")
+	ig := b.MustBuild(t)
+
+	found := func(diagnostics []imagegraph.Diagnostic) map[string]bool {
+		kinds := make(map[string]bool)
+		for _, d := range diagnostics {
+			kinds[d.Kind+" "+d.NodeID.String()+" "+d.Port] = true
+		}
+		return kinds
+	}
+
+	t.Run("reports incomplete parts of the graph", func(t *testing.T) {
+		diagnostics := ig.Diagnostics(time.Now().Add(-time.Hour))
+		kinds := found(diagnostics)
+
+		want := []string{
+			imagegraph.DiagnosticUnconnectedInput + " " + b.NodeID("resize").String() + " original",
+			imagegraph.DiagnosticDanglingOutput + " " + b.NodeID("resize").String() + " resized",
+			imagegraph.DiagnosticUnconnectedInput + " " + b.NodeID("output2").String() + " input",
+			imagegraph.DiagnosticUnreachableOutput + " " + b.NodeID("output2").String() + " ",
+		}
+		for _, w := range want {
+			if !kinds[w] {
+				t.Errorf("expected %q in %+v", w, diagnostics)
+			}
+		}
+		if len(diagnostics) != len(want) {
+			t.Errorf("expected %d diagnostics, got %+v", len(want), diagnostics)
+		}
+
+		for i, d := range diagnostics {
+			if d.Severity == imagegraph.SeverityError && i > 0 && diagnostics[i-1].Severity == imagegraph.SeverityWarning {
+				t.Errorf("expected errors before warnings, got %+v", diagnostics)
+			}
+		}
+	})
+
+	t.Run("reports input nodes without an image", func(t *testing.T) {
+		ig := testsupport.NewGraphBuilder().WithInput().WithOutput().ConnectAll().MustBuild(t)
+
+		diagnostics := ig.Diagnostics(time.Now().Add(-time.Hour))
+		if len(diagnostics) != 1 || diagnostics[0].Kind != imagegraph.DiagnosticMissingImage {
+			t.Errorf("expected a missing image diagnostic, got %+v", diagnostics)
+		}
+	})
+
+	t.Run("reports nodes generating since before the cutoff", func(t *testing.T) {
+		kinds := found(ig.Diagnostics(time.Now().Add(time.Hour)))
+
+		for id, node := range ig.Nodes {
+			stuck := node.Type != imagegraph.NodeTypeInput && node.State.Get() == imagegraph.Generating
+			if got := kinds[imagegraph.DiagnosticStuckGenerating+" "+id.String()+" "]; got != stuck {
+				t.Errorf("expected %s stuck %v, got %v", node.Name, stuck, got)
+			}
+		}
+	})
+}
+
+func TestDecodeNodeConfig(t *testing.T) {
+	// Pretend blur's radius was once called size, and that blur was
+	// deprecated since
+	def := imagegraph.NodeTypeDefs[imagegraph.NodeTypeBlur]
+	t.Cleanup(func() { imagegraph.NodeTypeDefs[imagegraph.NodeTypeBlur] = def })
+
+	migrated := def
+	migrated.ConfigMigrations = []imagegraph.ConfigMigration{
+		func(config map[string]any) ([]string, error) {
+			size, ok := config["size"]
+			if !ok {
+				return nil, errors.New("size is missing")
+			}
+			config["radius"] = size
+			delete(config, "size")
+			return []string{"size was renamed to radius"}, nil
+		},
+	}
+	migrated.Deprecated = "use a declared blur node"
+	imagegraph.NodeTypeDefs[imagegraph.NodeTypeBlur] = migrated
+
+	t.Run("migrates configs stored at older versions", func(t *testing.T) {
+		for _, version := range []int{0, 1} {
+			config, warnings, err := imagegraph.DecodeNodeConfig(imagegraph.NodeTypeBlur, version, []byte(`{"size": 7}`))
+			if err != nil {
+				t.Fatalf("failed to decode version %d config: %v", version, err)
+			}
+			if radius := config.(*imagegraph.NodeConfigBlur).Radius; radius != 7 {
+				t.Errorf("expected the size to become the radius, got %d", radius)
+			}
+			if !slices.Equal(warnings, []string{"size was renamed to radius"}) {
+				t.Errorf("expected the migration's warning, got %v", warnings)
+			}
+		}
+	})
+
+	t.Run("decodes current configs as they are", func(t *testing.T) {
+		config, warnings, err := imagegraph.DecodeNodeConfig(imagegraph.NodeTypeBlur, 2, []byte(`{"radius": 4}`))
+		if err != nil {
+			t.Fatalf("failed to decode config: %v", err)
+		}
+		if config.(*imagegraph.NodeConfigBlur).Radius != 4 || len(warnings) != 0 {
+			t.Errorf("expected radius 4 without warnings, got %+v and %v", config, warnings)
+		}
+	})
+
+	t.Run("rejects configs that can't be migrated", func(t *testing.T) {
+		if _, _, err := imagegraph.DecodeNodeConfig(imagegraph.NodeTypeBlur, 1, []byte(`{"radius": 4}`)); err == nil {
+			t.Error("expected the failed migration to be reported")
+		}
+		if _, _, err := imagegraph.DecodeNodeConfig(imagegraph.NodeTypeBlur, 3, []byte(`{"radius": 4}`)); err == nil {
+			t.Error("expected a config newer than the schema to be rejected")
+		}
+	})
+
+	t.Run("reports migrations and deprecations until the config is set", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithBlur(3)
+		ig := b.MustBuild(t)
+		blurID := b.NodeID("blur")
+
+		node, _ := ig.Nodes.Get(blurID)
+		node.ConfigWarnings = []string{"size was renamed to radius"}
+
+		kinds := make(map[string]int)
+		for _, d := range ig.Diagnostics(time.Now().Add(-time.Hour)) {
+			kinds[d.Kind]++
+		}
+		if kinds[imagegraph.DiagnosticMigratedConfig] != 1 || kinds[imagegraph.DiagnosticDeprecatedType] != 1 {
+			t.Errorf("expected migrated config and deprecated type warnings, got %v", kinds)
+		}
+
+		if err := ig.SetNodeConfig(blurID, &imagegraph.NodeConfigBlur{Radius: 5}); err != nil {
+			t.Fatalf("failed to set config: %v", err)
+		}
+		if node.ConfigWarnings != nil {
+			t.Errorf("expected setting the config to clear its warnings, got %v", node.ConfigWarnings)
+		}
+	})
+}
+
+func TestImageGraph_Estimate(t *testing.T) {
+	source := imagegraph.MustNewImageID()
+
+	b := testsupport.NewGraphBuilder().
+		WithInput().WithImage(source).
+		WithResize(1000).
+		WithBlur(3).
+		WithNode(imagegraph.NodeTypeUpscale).
+		Connect("input", "resize").
+		Connect("resize", "blur").
+		Connect("input", "upscale")
+	ig := b.MustBuild(t)
+
+	sizes := map[imagegraph.ImageID]imagegraph.ImageSize{
+		source: {Width: 4000, Height: 3000},
+	}
+	timings := map[imagegraph.NodeType]imagegraph.GenerationTiming{
+		imagegraph.NodeTypeBlur: {Count: 2, Mean: time.Second, PerMegapixel: 100 * time.Millisecond},
+	}
+
+	estimates := make(map[imagegraph.NodeID]imagegraph.NodeEstimate)
+	for _, e := range ig.Estimate(sizes, timings, nil) {
+		estimates[e.NodeID] = e
+	}
+
+	t.Run("propagates sizes through node configs", func(t *testing.T) {
+		want := map[string]imagegraph.ImageSize{
+			"input":   {Width: 4000, Height: 3000},
+			"resize":  {Width: 1000, Height: 750},
+			"blur":    {Width: 1000, Height: 750},
+			"upscale": {Width: 16000, Height: 12000},
+		}
+		for name, size := range want {
+			e := estimates[b.NodeID(name)]
+			if !e.OutputKnown || e.Output != size {
+				t.Errorf("expected %s to output %v, got %v (known %v)", name, size, e.Output, e.OutputKnown)
+			}
+		}
+	})
+
+	t.Run("counts the memory of inputs and output", func(t *testing.T) {
+		if got := estimates[b.NodeID("resize")].MemoryBytes; got != (12_000_000+750_000)*4 {
+			t.Errorf("expected resize to need %d bytes, got %d", (12_000_000+750_000)*4, got)
+		}
+		if got := estimates[b.NodeID("input")].MemoryBytes; got != 12_000_000*4 {
+			t.Errorf("expected input to need %d bytes, got %d", 12_000_000*4, got)
+		}
+	})
+
+	t.Run("scales recorded timings by pixels", func(t *testing.T) {
+		blur := estimates[b.NodeID("blur")]
+		if !blur.DurationKnown || blur.Duration != 75*time.Millisecond {
+			t.Errorf("expected blur to take 75ms, got %v (known %v)", blur.Duration, blur.DurationKnown)
+		}
+		if blur.DurationSample != 2 {
+			t.Errorf("expected the estimate to come from 2 generations, got %d", blur.DurationSample)
+		}
+		if estimates[b.NodeID("resize")].DurationKnown {
+			t.Error("expected no duration for a node type that hasn't been timed")
+		}
+	})
+
+	t.Run("prefers the timings of the node itself", func(t *testing.T) {
+		nodeTimings := map[imagegraph.NodeID]imagegraph.GenerationTiming{
+			b.NodeID("blur"): {Count: 5, Mean: 40 * time.Millisecond},
+		}
+		for _, e := range ig.Estimate(sizes, timings, nodeTimings) {
+			if e.NodeID == b.NodeID("blur") && (e.Duration != 40*time.Millisecond || e.DurationSample != 5) {
+				t.Errorf("expected blur's own timing of 40ms from 5 runs, got %v from %d", e.Duration, e.DurationSample)
+			}
+		}
+	})
+
+	t.Run("ignores images that regeneration replaces", func(t *testing.T) {
+		for _, e := range ig.Estimate(map[imagegraph.ImageID]imagegraph.ImageSize{}, nil, nil) {
+			if e.OutputKnown {
+				t.Errorf("expected no sizes without input images, got %v for %s", e.Output, e.NodeID)
+			}
+		}
+	})
+}
+
+func TestImageGraph_OutputHistory(t *testing.T) {
+	t.Run("keeps the images an output replaced, most recent first", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+
+		var imageIDs []imagegraph.ImageID
+		for range imagegraph.OutputHistorySize + 2 {
+			imageID := imagegraph.MustNewImageID()
+			imageIDs = append(imageIDs, imageID)
+			setNodeOutput(t, ig, nodeID, "original", imageID)
+		}
+
+		output := ig.Nodes[nodeID].Outputs["original"]
+		if output.ImageID != imageIDs[len(imageIDs)-1] {
+			t.Errorf("expected the last image to be current, got %s", output.ImageID)
+		}
+		if len(output.History) != imagegraph.OutputHistorySize {
+			t.Fatalf("expected %d previous images, got %d", imagegraph.OutputHistorySize, len(output.History))
+		}
+		if output.History[0].ImageID != imageIDs[len(imageIDs)-2] {
+			t.Errorf("expected the most recently replaced image first, got %s", output.History[0].ImageID)
+		}
+		if output.History[0].ReplacedAt.IsZero() {
+			t.Error("expected the replaced image to record when it was replaced")
+		}
+
+		var dropped []imagegraph.ImageID
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeOutputVariantsDroppedEvent); ok {
+				dropped = append(dropped, e.ImageIDs...)
+			}
+		}
+		if len(dropped) != 1 || dropped[0] != imageIDs[0] {
+			t.Errorf("expected the oldest image to be dropped, got %v", dropped)
+		}
+	})
+
+	t.Run("unsetting an output keeps its image in the history", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+
+		imageID := imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, nodeID, "original", imageID)
+		ig.ResetEvents()
+
+		if err := ig.UnsetNodeOutputImage(nodeID, "original"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		output := ig.Nodes[nodeID].Outputs["original"]
+		if output.HasImage() || len(output.History) != 1 || output.History[0].ImageID != imageID {
+			t.Errorf("expected the unset image in the history, got %+v", output)
+		}
+
+		e, ok := ig.GetEvents()[0].(*imagegraph.NodeOutputImageUnsetEvent)
+		if !ok || !e.Retained {
+			t.Errorf("expected a retained NodeOutputImageUnsetEvent, got %v", ig.GetEvents()[0])
+		}
+	})
+
+	t.Run("promotes a previous image back to current", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput().WithBlur(2).ConnectAll()
+		ig := b.MustBuild(t)
+		inputID := b.NodeID("input")
+
+		first, second := imagegraph.MustNewImageID(), imagegraph.MustNewImageID()
+		setNodeOutput(t, ig, inputID, "original", first)
+		setNodeOutput(t, ig, inputID, "original", second)
+		ig.ResetEvents()
+		inFlightVersion := ig.Nodes[inputID].Version
+
+		if err := ig.PromoteNodeOutputVariant(inputID, "original", first); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		output := ig.Nodes[inputID].Outputs["original"]
+		if output.ImageID != first {
+			t.Errorf("expected the promoted image to be current, got %s", output.ImageID)
+		}
+		if len(output.History) != 1 || output.History[0].ImageID != second {
+			t.Errorf("expected the replaced image in the history, got %+v", output.History)
+		}
+
+		var set *imagegraph.NodeOutputImageSetEvent
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeOutputImageSetEvent); ok {
+				set = e
+			}
+		}
+		if set == nil || set.ImageID != first {
+			t.Errorf("expected a NodeOutputImageSetEvent for the promoted image, got %v", set)
+		}
+
+		// A generation started before the promotion must not replace it
+		err := ig.SetNodeOutputImage(inputID, "original", imagegraph.MustNewImageID(), inFlightVersion)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if ig.Nodes[inputID].Outputs["original"].ImageID != first {
+			t.Error("expected a stale output to be ignored")
+		}
+	})
+
+	t.Run("rejects images not in the history", func(t *testing.T) {
+		ig, _ := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "test")
+		nodeID := imagegraph.MustNewNodeID()
+		ig.AddNode(nodeID, imagegraph.NodeTypeInput, "input")
+		setNodeOutput(t, ig, nodeID, "original", imagegraph.MustNewImageID())
+
+		err := ig.PromoteNodeOutputVariant(nodeID, "original", imagegraph.MustNewImageID())
+
+		if !errors.Is(err, imagegraph.ErrOutputVariantNotFound) {
+			t.Errorf("expected ErrOutputVariantNotFound, got %v", err)
+		}
+	})
+}
+
+func TestImageGraph_ConnectionTransforms(t *testing.T) {
+	t.Run("connecting with a transform keeps it on the input", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput().WithBlur(2)
+		ig := b.MustBuild(t)
+		ig.ResetEvents()
+
+		err := ig.ConnectNodesWithTransform(
+			b.NodeID("input"), "original", b.NodeID("blur"), "original", imagegraph.ConnectionTransformAlpha,
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if got := ig.Nodes[b.NodeID("blur")].Inputs["original"].Transform; got != imagegraph.ConnectionTransformAlpha {
+			t.Errorf("expected the input to be transformed by alpha, got %q", got)
+		}
+
+		var connected *imagegraph.NodeInputConnectedEvent
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeInputConnectedEvent); ok {
+				connected = e
+			}
+		}
+		if connected == nil || connected.Transform != imagegraph.ConnectionTransformAlpha {
+			t.Errorf("expected a NodeInputConnectedEvent with the transform, got %v", connected)
+		}
+	})
+
+	t.Run("reconnecting changes the transform and regenerates", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput().WithImage(imagegraph.MustNewImageID()).WithBlur(2).ConnectAll()
+		ig := b.MustBuild(t)
+		blurID := b.NodeID("blur")
+		if err := ig.PropagateOutputImageToConnections(b.NodeID("input"), "original", ig.Nodes[b.NodeID("input")].Outputs["original"].ImageID); err != nil {
+			t.Fatalf("failed to propagate image: %v", err)
+		}
+		ig.ResetEvents()
+
+		err := ig.ConnectNodesWithTransform(
+			b.NodeID("input"), "original", blurID, "original", imagegraph.ConnectionTransformInvert,
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var set *imagegraph.NodeInputTransformSetEvent
+		var needs *imagegraph.NodeNeedsOutputsEvent
+		for _, event := range ig.GetEvents() {
+			switch e := event.(type) {
+			case *imagegraph.NodeInputTransformSetEvent:
+				set = e
+			case *imagegraph.NodeNeedsOutputsEvent:
+				needs = e
+			}
+		}
+		if set == nil || set.InputName != "original" || set.Transform != imagegraph.ConnectionTransformInvert {
+			t.Errorf("expected a NodeInputTransformSetEvent, got %v", set)
+		}
+		if needs == nil || needs.NodeID != blurID {
+			t.Fatalf("expected the node to regenerate, got %v", needs)
+		}
+		if needs.Inputs[0].Transform != imagegraph.ConnectionTransformInvert {
+			t.Errorf("expected the regeneration to carry the transform, got %+v", needs.Inputs)
+		}
+
+		ig.ResetEvents()
+		err = ig.ConnectNodesWithTransform(
+			b.NodeID("input"), "original", blurID, "original", imagegraph.ConnectionTransformInvert,
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(ig.GetEvents()) != 0 {
+			t.Errorf("expected an unchanged transform to do nothing, got %v", ig.GetEvents())
+		}
+	})
+
+	t.Run("disconnecting clears the transform", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput().WithBlur(2)
+		ig := b.MustBuild(t)
+
+		err := ig.ConnectNodesWithTransform(
+			b.NodeID("input"), "original", b.NodeID("blur"), "original", imagegraph.ConnectionTransformRed,
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := ig.DisconnectNodes(b.NodeID("input"), "original", b.NodeID("blur"), "original"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if got := ig.Nodes[b.NodeID("blur")].Inputs["original"].Transform; got != imagegraph.ConnectionTransformNone {
+			t.Errorf("expected no transform, got %q", got)
+		}
+	})
+
+	t.Run("rejects unknown transforms", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput().WithBlur(2)
+		ig := b.MustBuild(t)
+
+		err := ig.ConnectNodesWithTransform(b.NodeID("input"), "original", b.NodeID("blur"), "original", "sepia")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if connected, _ := ig.Nodes[b.NodeID("blur")].IsInputConnected("original"); connected {
+			t.Error("expected the rejected connection not to be made")
+		}
+	})
+
+	t.Run("rejects transforming palettes", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().
+			WithNode(imagegraph.NodeTypePaletteExtract).
+			WithNode(imagegraph.NodeTypePaletteApply).
+			Connect("input", "palette_extract")
+		ig := b.MustBuild(t)
+
+		err := ig.ConnectNodesWithTransform(
+			b.NodeID("palette_extract"), "palette", b.NodeID("palette_apply"), "palette", imagegraph.ConnectionTransformInvert,
+		)
+		if !errors.Is(err, imagegraph.ErrPaletteTransformed) {
+			t.Fatalf("expected ErrPaletteTransformed, got %v", err)
+		}
+	})
+}
+
+func TestImageGraph_DynamicInputs(t *testing.T) {
+	build := func(t *testing.T) (*testsupport.GraphBuilder, *imagegraph.ImageGraph) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().Named("first").WithImage(imagegraph.MustNewImageID()).
+			WithInput().Named("second").WithImage(imagegraph.MustNewImageID()).
+			WithNode(imagegraph.NodeTypeContactSheet).
+			ConnectPorts("first", "original", "contact_sheet", "image_1")
+		return b, b.MustBuild(t)
+	}
+
+	// addInput adds the node's next input
+	addInput := func(ig *imagegraph.ImageGraph, nodeID imagegraph.NodeID) (imagegraph.InputName, error) {
+		name, err := ig.Nodes[nodeID].NextInputName()
+		if err != nil {
+			return "", err
+		}
+		return name, ig.AddNodeInput(nodeID, name)
+	}
+
+	t.Run("adds optional inputs numbered after the others", func(t *testing.T) {
+		b, ig := build(t)
+		sheetID := b.NodeID("contact_sheet")
+
+		name, err := addInput(ig, sheetID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if name != "image_3" {
+			t.Errorf("expected image_3, got %q", name)
+		}
+
+		input := ig.Nodes[sheetID].Inputs[name]
+		if input == nil || !input.Optional {
+			t.Fatalf("expected an optional input, got %+v", input)
+		}
+
+		e, ok := ig.GetEvents()[0].(*imagegraph.NodeInputAddedEvent)
+		if !ok || e.InputName != name {
+			t.Errorf("expected a NodeInputAddedEvent, got %v", ig.GetEvents()[0])
+		}
+
+		if state := ig.Nodes[sheetID].State.Get(); state != imagegraph.Generating {
+			t.Errorf("expected adding an input not to stop the node generating, got %v", state)
+		}
+	})
+
+	t.Run("orders inputs by number", func(t *testing.T) {
+		b, ig := build(t)
+		sheetID := b.NodeID("contact_sheet")
+
+		for range 9 {
+			if _, err := addInput(ig, sheetID); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		names := ig.Nodes[sheetID].InputNames()
+		if len(names) != 11 || names[0] != "image_1" || names[9] != "image_10" || names[10] != "image_11" {
+			t.Errorf("expected inputs in numeric order, got %v", names)
+		}
+	})
+
+	t.Run("removing a connected input disconnects it", func(t *testing.T) {
+		b, ig := build(t)
+		sheetID := b.NodeID("contact_sheet")
+
+		name, _ := addInput(ig, sheetID)
+		if err := ig.ConnectNodes(b.NodeID("second"), "original", sheetID, name); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		ig.ResetEvents()
+
+		if err := ig.RemoveNodeInput(sheetID, name); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if ig.Nodes[sheetID].HasInput(name) {
+			t.Error("expected the input to be removed")
+		}
+		if connected, _ := ig.Nodes[b.NodeID("second")].IsOutputConnectedTo("original", sheetID, name); connected {
+			t.Error("expected the upstream output to be disconnected")
+		}
+
+		var disconnected, removed bool
+		for _, event := range ig.GetEvents() {
+			switch e := event.(type) {
+			case *imagegraph.NodeInputDisconnectedEvent:
+				disconnected = e.InputName == name
+			case *imagegraph.NodeInputRemovedEvent:
+				removed = e.InputName == name
+			}
+		}
+		if !disconnected || !removed {
+			t.Errorf("expected disconnected and removed events, got %v", ig.GetEvents())
+		}
+	})
+
+	t.Run("rejects inputs not named like the node type's", func(t *testing.T) {
+		b, ig := build(t)
+		sheetID := b.NodeID("contact_sheet")
+
+		for _, name := range []imagegraph.InputName{"layer_3", "image_03", "image_0", "image_2"} {
+			if err := ig.AddNodeInput(sheetID, name); !errors.Is(err, imagegraph.ErrInvalidInputName) {
+				t.Errorf("expected %q to be rejected with ErrInvalidInputName, got %v", name, err)
+			}
+		}
+
+		if err := ig.AddNodeInput(sheetID, "image_7"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if name, _ := ig.Nodes[sheetID].NextInputName(); name != "image_8" {
+			t.Errorf("expected inputs to be numbered on from the highest, got %q", name)
+		}
+	})
+
+	t.Run("rejects removing inputs the node type declares", func(t *testing.T) {
+		b, ig := build(t)
+
+		err := ig.RemoveNodeInput(b.NodeID("contact_sheet"), "image_2")
+		if !errors.Is(err, imagegraph.ErrFixedInput) {
+			t.Fatalf("expected ErrFixedInput, got %v", err)
+		}
+	})
+
+	t.Run("rejects adding inputs to node types with fixed inputs", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithBlur(2)
+		ig := b.MustBuild(t)
+
+		err := ig.AddNodeInput(b.NodeID("blur"), "original_2")
+		if !errors.Is(err, imagegraph.ErrFixedInput) {
+			t.Fatalf("expected ErrFixedInput, got %v", err)
+		}
+	})
+
+	t.Run("rejects adding inputs past the node type's limit", func(t *testing.T) {
+		b, ig := build(t)
+		sheetID := b.NodeID("contact_sheet")
+
+		var err error
+		for range imagegraph.NodeTypeDefs[imagegraph.NodeTypeContactSheet].MaxInputs {
+			if _, err = addInput(ig, sheetID); err != nil {
+				break
+			}
+		}
+
+		if !errors.Is(err, imagegraph.ErrInputLimit) {
+			t.Fatalf("expected ErrInputLimit, got %v", err)
+		}
+		if got, max := len(ig.Nodes[sheetID].Inputs), imagegraph.NodeTypeDefs[imagegraph.NodeTypeContactSheet].MaxInputs; got != max {
+			t.Errorf("expected %d inputs, got %d", max, got)
+		}
+	})
+
+	t.Run("labels regenerated inputs with the nodes they come from", func(t *testing.T) {
+		b, ig := build(t)
+		sheetID := b.NodeID("contact_sheet")
+
+		if err := ig.ConnectNodes(b.NodeID("second"), "original", sheetID, "image_2"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		imageID := ig.Nodes[b.NodeID("second")].Outputs["original"].ImageID
+		if err := ig.PropagateOutputImageToConnections(b.NodeID("second"), "original", imageID); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var needs *imagegraph.NodeNeedsOutputsEvent
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				needs = e
+			}
+		}
+		if needs == nil {
+			t.Fatal("expected the node to regenerate")
+		}
+		if len(needs.Inputs) != 2 || needs.Inputs[0].FromNodeName != "first" || needs.Inputs[1].FromNodeName != "second" {
+			t.Errorf("expected inputs in order labelled with their nodes, got %+v", needs.Inputs)
+		}
+	})
+}
+
+func TestImageGraph_Switch(t *testing.T) {
+	build := func(t *testing.T) (*testsupport.GraphBuilder, *imagegraph.ImageGraph) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().Named("first").WithImage(imagegraph.MustNewImageID()).
+			WithInput().Named("second").WithImage(imagegraph.MustNewImageID()).
+			WithNode(imagegraph.NodeTypeSwitch).
+			ConnectPorts("first", "original", "switch", "input_1").
+			ConnectPorts("second", "original", "switch", "input_2")
+		return b, b.MustBuild(t)
+	}
+
+	t.Run("regenerates when the selection changes", func(t *testing.T) {
+		b, ig := build(t)
+		switchID := b.NodeID("switch")
+		ig.ResetEvents()
+
+		config := imagegraph.NewNodeConfigSwitch()
+		config.Selected = 2
+		if err := ig.SetNodeConfig(switchID, config); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var needsOutputs *imagegraph.NodeNeedsOutputsEvent
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				needsOutputs = e
+			}
+		}
+		if needsOutputs == nil {
+			t.Fatal("expected NodeNeedsOutputsEvent to be emitted")
+		}
+
+		selected, _ := needsOutputs.NodeConfig.(*imagegraph.NodeConfigSwitch)
+		if selected == nil || selected.SelectedInput() != "input_2" {
+			t.Errorf("expected input_2 to be selected, got %+v", needsOutputs.NodeConfig)
+		}
+	})
+
+	t.Run("rejects selections out of range", func(t *testing.T) {
+		for _, selected := range []int{0, imagegraph.MaxSwitchInputs + 1} {
+			config := &imagegraph.NodeConfigSwitch{Selected: selected}
+			if err := config.Validate(); err == nil {
+				t.Errorf("expected selected %d to be rejected", selected)
+			}
+		}
+	})
+
+	t.Run("takes the size of the selected input", func(t *testing.T) {
+		config := &imagegraph.NodeConfigSwitch{Selected: 2}
+		inputs := map[imagegraph.InputName]imagegraph.ImageSize{
+			"input_1": {Width: 100, Height: 50},
+			"input_2": {Width: 30, Height: 40},
+		}
+
+		size, ok := config.OutputSize(inputs)
+		if !ok || size != inputs["input_2"] {
+			t.Errorf("expected size %v, got %v", inputs["input_2"], size)
+		}
+		if warnings := config.Lint(inputs); len(warnings) != 0 {
+			t.Errorf("expected no warnings, got %v", warnings)
+		}
+
+		config.Selected = 3
+		if warnings := config.Lint(inputs); len(warnings) != 1 {
+			t.Errorf("expected a warning that input_3 has no image, got %v", warnings)
+		}
+	})
+}
+
+func TestExpression(t *testing.T) {
+	vars := map[string]float64{"input.width": 640, "input.height": 480}
+
+	t.Run("evaluates arithmetic over variables", func(t *testing.T) {
+		tests := map[string]float64{
+			"input.width / 2":                       320,
+			"input.height - 10":                     470,
+			"-input.width + 2 * (input.height % 7)": -632,
+			"min(input.width, input.height, 500)":   480,
+			"max(input.width, input.height)":        640,
+			"round(input.width / 3)":                213,
+			"floor(input.height / 7) + ceil(0.2)":   69,
+			"abs(input.height - input.width) * 1.5": 240,
+		}
+		for source, want := range tests {
+			expr, err := imagegraph.ParseExpression(source)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", source, err)
+			}
+			got, err := expr.Eval(vars)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", source, err)
+			}
+			if got != want {
+				t.Errorf("%s: expected %v, got %v", source, want, got)
+			}
+		}
+	})
+
+	t.Run("lists variables once each", func(t *testing.T) {
+		expr, err := imagegraph.ParseExpression("input.width / input.height * input.width")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []string{"input.width", "input.height"}
+		if got := expr.Variables(); !slices.Equal(got, want) {
+			t.Errorf("expected variables %v, got %v", want, got)
+		}
+	})
+
+	t.Run("rejects malformed expressions", func(t *testing.T) {
+		for _, source := range []string{
+			"",
+			"input.width /",
+			"(input.width",
+			"input.width 2",
+			"sqrt(4)",
+			"round(1, 2)",
+			"input.",
+			"1.2.3",
+			"input.width & 1",
+			strings.Repeat("1+", imagegraph.MaxExpressionLength),
+		} {
+			if _, err := imagegraph.ParseExpression(source); !errors.Is(err, imagegraph.ErrInvalidExpression) {
+				t.Errorf("%q: expected ErrInvalidExpression, got %v", source, err)
+			}
+		}
+	})
+
+	t.Run("fails on division by zero and unknown variables", func(t *testing.T) {
+		for _, source := range []string{"input.width / 0", "input.width % (input.height - 480)", "other.width"} {
+			expr, err := imagegraph.ParseExpression(source)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", source, err)
+			}
+			if _, err := expr.Eval(vars); err == nil {
+				t.Errorf("%s: expected an error", source)
+			}
+		}
+	})
+}
+
+func TestImageGraph_ConfigExpressions(t *testing.T) {
+	source := imagegraph.MustNewImageID()
+
+	build := func(t *testing.T) (*testsupport.GraphBuilder, *imagegraph.ImageGraph) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(source).
+			WithResize(1000).
+			Connect("input", "resize")
+		return b, b.MustBuild(t)
+	}
+
+	t.Run("regenerates the node with its expressions", func(t *testing.T) {
+		b, ig := build(t)
+		ig.ResetEvents()
+
+		exprs := imagegraph.ConfigExpressions{"width": "input.width / 2"}
+		if err := ig.SetNodeExpressions(b.NodeID("resize"), exprs); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var set *imagegraph.NodeExpressionsSetEvent
+		var needsOutputs *imagegraph.NodeNeedsOutputsEvent
+		for _, event := range ig.GetEvents() {
+			switch e := event.(type) {
+			case *imagegraph.NodeExpressionsSetEvent:
+				set = e
+			case *imagegraph.NodeNeedsOutputsEvent:
+				needsOutputs = e
+			}
+		}
+		if set == nil || set.Expressions["width"] != "input.width / 2" {
+			t.Errorf("expected NodeExpressionsSetEvent with the expressions, got %+v", set)
+		}
+		if needsOutputs == nil || needsOutputs.Expressions["width"] != "input.width / 2" {
+			t.Errorf("expected NodeNeedsOutputsEvent with the expressions, got %+v", needsOutputs)
+		}
+
+		ig.ResetEvents()
+		if err := ig.SetNodeExpressions(b.NodeID("resize"), exprs); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if events := ig.GetEvents(); len(events) != 0 {
+			t.Errorf("expected setting the same expressions to be a no-op, got %d events", len(events))
+		}
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		for name, exprs := range map[string]imagegraph.ConfigExpressions{
+			"unknown field":     {"depth": "input.width"},
+			"non-numeric field": {"interpolation": "input.width"},
+			"bad syntax":        {"width": "input.width /"},
+			"unknown input":     {"width": "mask.width"},
+			"unknown property":  {"width": "input.depth"},
+		} {
+			b, ig := build(t)
+			err := ig.SetNodeExpressions(b.NodeID("resize"), exprs)
+			if !errors.Is(err, imagegraph.ErrInvalidExpression) {
+				t.Errorf("%s: expected ErrInvalidExpression, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("resolves expressions against input sizes", func(t *testing.T) {
+		config := imagegraph.NewNodeConfigResize()
+		width := 1000
+		config.Width = &width
+		config.Interpolation = "Lanczos3"
+
+		exprs := imagegraph.ConfigExpressions{"height": "input.height / 3"}
+		vars := imagegraph.ExpressionVariables(imagegraph.NodeTypeResize, map[imagegraph.InputName]imagegraph.ImageSize{
+			"original": {Width: 640, Height: 480},
+		}, nil)
+
+		resolved, err := exprs.Resolve(config, vars)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		resize := resolved.(*imagegraph.NodeConfigResize)
+		if resize.Width == nil || *resize.Width != 1000 || resize.Height == nil || *resize.Height != 160 {
+			t.Errorf("expected 1000x160, got %+v", resize)
+		}
+		if config.Height != nil {
+			t.Error("expected the original config to be left alone")
+		}
+
+		exprs = imagegraph.ConfigExpressions{"height": "input.height - 480"}
+		if _, err := exprs.Resolve(config, vars); err == nil {
+			t.Error("expected an error when the resolved config is invalid")
+		}
+	})
+
+	t.Run("estimates sizes from resolved configs", func(t *testing.T) {
+		b, ig := build(t)
+		exprs := imagegraph.ConfigExpressions{"width": "input.width / 2"}
+		if err := ig.SetNodeExpressions(b.NodeID("resize"), exprs); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		sizes := map[imagegraph.ImageID]imagegraph.ImageSize{source: {Width: 4000, Height: 3000}}
+		for _, e := range ig.Estimate(sizes, nil, nil) {
+			if e.NodeID != b.NodeID("resize") {
+				continue
+			}
+			want := imagegraph.ImageSize{Width: 2000, Height: 1500}
+			if !e.OutputKnown || e.Output != want {
+				t.Errorf("expected resize to output %v, got %v (known %v)", want, e.Output, e.OutputKnown)
+			}
+		}
+	})
+}
+
+func TestImageGraph_Parameters(t *testing.T) {
+	source := imagegraph.MustNewImageID()
+
+	build := func(t *testing.T) (*testsupport.GraphBuilder, *imagegraph.ImageGraph) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(source).
+			WithResize(1000).Named("sized").
+			WithResize(1000).Named("fixed").
+			Connect("input", "sized").
+			Connect("input", "fixed")
+		ig := b.MustBuild(t)
+
+		if err := ig.SetParameters(imagegraph.GraphParameters{"target_width": 800, "unused": 1}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		exprs := imagegraph.ConfigExpressions{"width": "min(target_width, input.width)"}
+		if err := ig.SetNodeExpressions(b.NodeID("sized"), exprs); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		ig.ResetEvents()
+		return b, ig
+	}
+
+	needsOutputs := func(ig *imagegraph.ImageGraph) map[imagegraph.NodeID]*imagegraph.NodeNeedsOutputsEvent {
+		events := make(map[imagegraph.NodeID]*imagegraph.NodeNeedsOutputsEvent)
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				events[e.NodeID] = e
+			}
+		}
+		return events
+	}
+
+	t.Run("regenerates the nodes that use a changed parameter", func(t *testing.T) {
+		b, ig := build(t)
+
+		if err := ig.SetParameters(imagegraph.GraphParameters{"target_width": 640, "unused": 1}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		events := needsOutputs(ig)
+		sized := events[b.NodeID("sized")]
+		if sized == nil {
+			t.Fatal("expected the node using target_width to regenerate")
+		}
+		if len(sized.Parameters) != 1 || sized.Parameters["target_width"] != 640 {
+			t.Errorf("expected the event to carry target_width 640, got %v", sized.Parameters)
+		}
+		if _, ok := events[b.NodeID("fixed")]; ok {
+			t.Error("expected the node without expressions not to regenerate")
+		}
+	})
+
+	t.Run("leaves nodes alone when unused parameters change", func(t *testing.T) {
+		_, ig := build(t)
+
+		if err := ig.SetParameters(imagegraph.GraphParameters{"target_width": 800, "unused": 2}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if events := needsOutputs(ig); len(events) != 0 {
+			t.Errorf("expected no regeneration, got %d", len(events))
+		}
+		var set *imagegraph.ParametersSetEvent
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.ParametersSetEvent); ok {
+				set = e
+			}
+		}
+		if set == nil || set.Parameters["unused"] != 2 {
+			t.Errorf("expected ParametersSetEvent with the parameters, got %+v", set)
+		}
+	})
+
+	t.Run("won't remove a parameter that expressions use", func(t *testing.T) {
+		_, ig := build(t)
+
+		err := ig.SetParameters(imagegraph.GraphParameters{"unused": 1})
+		if !errors.Is(err, imagegraph.ErrParameterInUse) {
+			t.Errorf("expected ErrParameterInUse, got %v", err)
+		}
+		if ig.Parameters["target_width"] != 800 {
+			t.Errorf("expected the parameters to be left alone, got %v", ig.Parameters)
+		}
+	})
+
+	t.Run("rejects bad names and values", func(t *testing.T) {
+		_, ig := build(t)
+
+		for _, params := range []imagegraph.GraphParameters{
+			{"": 1},
+			{"Width": 1},
+			{"2x": 1},
+			{"target-width": 1},
+			{"round": 1},
+			{"inf": math.Inf(1)},
+		} {
+			if err := ig.SetParameters(params); !errors.Is(err, imagegraph.ErrInvalidParameter) {
+				t.Errorf("expected ErrInvalidParameter for %v, got %v", params, err)
+			}
+		}
+	})
+
+	t.Run("rejects expressions using unknown parameters", func(t *testing.T) {
+		b, ig := build(t)
+
+		exprs := imagegraph.ConfigExpressions{"width": "palette_size * 10"}
+		err := ig.SetNodeExpressions(b.NodeID("fixed"), exprs)
+		if !errors.Is(err, imagegraph.ErrInvalidExpression) {
+			t.Errorf("expected ErrInvalidExpression, got %v", err)
+		}
+	})
+
+	t.Run("estimates sizes with parameter values", func(t *testing.T) {
+		b, ig := build(t)
+
+		sizes := map[imagegraph.ImageID]imagegraph.ImageSize{source: {Width: 4000, Height: 3000}}
+		for _, e := range ig.Estimate(sizes, nil, nil) {
+			if e.NodeID != b.NodeID("sized") {
+				continue
+			}
+			want := imagegraph.ImageSize{Width: 800, Height: 600}
+			if !e.OutputKnown || e.Output != want {
+				t.Errorf("expected sized to output %v, got %v (known %v)", want, e.Output, e.OutputKnown)
+			}
+		}
+	})
+}
+
+func TestImageGraph_Seed(t *testing.T) {
+	own := 7
+
+	build := func(t *testing.T) (*testsupport.GraphBuilder, *imagegraph.ImageGraph) {
+		b := testsupport.NewGraphBuilder().
+			WithInput().WithImage(imagegraph.MustNewImageID()).
+			WithNode(imagegraph.NodeTypePaletteExtract).Named("inherits").
+			WithNode(imagegraph.NodeTypePaletteExtract).Named("seeded").
+			WithConfig(&imagegraph.NodeConfigPaletteExtract{NumColors: 8, Method: "oklab_clusters", Seed: &own}).
+			WithResize(100).
+			ConnectPorts("input", "original", "inherits", "source").
+			ConnectPorts("input", "original", "seeded", "source").
+			Connect("input", "resize")
+		ig := b.MustBuild(t)
+		ig.ResetEvents()
+		return b, ig
+	}
+
+	t.Run("regenerates the nodes that inherit the seed", func(t *testing.T) {
+		b, ig := build(t)
+
+		seed := 1234
+		if err := ig.SetSeed(&seed); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		regenerated := make(map[imagegraph.NodeID]*imagegraph.NodeNeedsOutputsEvent)
+		for _, event := range ig.GetEvents() {
+			if e, ok := event.(*imagegraph.NodeNeedsOutputsEvent); ok {
+				regenerated[e.NodeID] = e
+			}
+		}
+		if len(regenerated) != 1 {
+			t.Fatalf("expected only the inheriting node to regenerate, got %d nodes", len(regenerated))
+		}
+		inherits := regenerated[b.NodeID("inherits")]
+		if inherits == nil || inherits.GraphSeed == nil || *inherits.GraphSeed != seed {
+			t.Errorf("expected the inheriting node to regenerate with graph seed %d, got %+v", seed, inherits)
+		}
+
+		ig.ResetEvents()
+		if err := ig.SetSeed(&seed); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if events := ig.GetEvents(); len(events) != 0 {
+			t.Errorf("expected setting the same seed to be a no-op, got %d events", len(events))
+		}
+	})
+
+	t.Run("prefers the node's seed, then the graph's, then the default", func(t *testing.T) {
+		b, ig := build(t)
+		inherits, _ := ig.Nodes.Get(b.NodeID("inherits"))
+		seeded, _ := ig.Nodes.Get(b.NodeID("seeded"))
+		resize, _ := ig.Nodes.Get(b.NodeID("resize"))
+
+		if seed, ok := imagegraph.EffectiveSeed(inherits.Config, nil); !ok || seed != imagegraph.DefaultPaletteExtractSeed {
+			t.Errorf("expected the default seed, got %d (%v)", seed, ok)
+		}
+
+		graphSeed := 99
+		if seed, _ := imagegraph.EffectiveSeed(inherits.Config, &graphSeed); seed != graphSeed {
+			t.Errorf("expected the graph seed, got %d", seed)
+		}
+		if seed, _ := imagegraph.EffectiveSeed(seeded.Config, &graphSeed); seed != own {
+			t.Errorf("expected the node's own seed, got %d", seed)
+		}
+		if _, ok := imagegraph.EffectiveSeed(resize.Config, &graphSeed); ok {
+			t.Error("expected no seed for a node without stochastic behavior")
+		}
+		if _, ok := imagegraph.EffectiveSeed(imagegraph.NewNodeConfigGenerate(), nil); ok {
+			t.Error("expected generate nodes without a seed to draw a fresh one")
+		}
+	})
+
+	t.Run("rejects negative seeds", func(t *testing.T) {
+		_, ig := build(t)
+
+		seed := -1
+		if err := ig.SetSeed(&seed); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestNodeConfigPaletteExtract_Method(t *testing.T) {
+	var options []string
+	for _, field := range imagegraph.NewNodeConfigPaletteExtract().Schema() {
+		if field.Name == "method" {
+			options = field.Options
+		}
+	}
+	if len(options) == 0 {
+		t.Fatal("expected method options in the schema")
+	}
+
+	for _, option := range options {
+		config := imagegraph.NewNodeConfigPaletteExtract()
+		config.Method = option
+		if err := config.Validate(); err != nil {
+			t.Errorf("%s: expected no error, got %v", option, err)
+		}
+		name, err := imagegraph.PaletteExtractMethodMapper.From(config.ExtractMethod())
+		if err != nil || name != option {
+			t.Errorf("%s: expected the method to map back to its name, got %q (%v)", option, name, err)
+		}
+	}
+
+	config := imagegraph.NewNodeConfigPaletteExtract()
+	config.Method = "Perceptual"
+	if err := config.Validate(); err == nil {
+		t.Error("expected an unknown method to be rejected")
+	}
+}
+
+func TestNodeConfigPaletteApply_AlphaThreshold(t *testing.T) {
+	for _, threshold := range []int{0, 1, 128, 255} {
+		config := imagegraph.NewNodeConfigPaletteApply()
+		config.AlphaThreshold = threshold
+		if err := config.Validate(); err != nil {
+			t.Errorf("%d: expected no error, got %v", threshold, err)
+		}
+	}
+
+	for _, threshold := range []int{-1, 256} {
+		config := imagegraph.NewNodeConfigPaletteApply()
+		config.AlphaThreshold = threshold
+		if err := config.Validate(); err == nil {
+			t.Errorf("%d: expected an out of range threshold to be rejected", threshold)
+		}
+	}
+
+	if got := imagegraph.NodeTypeDefs[imagegraph.NodeTypePaletteApply].LatestImplementation(); got != 2 {
+		t.Errorf("expected new palette apply nodes to keep alpha on implementation 2, got %d", got)
+	}
+}
+
+func TestImageGraph_PaletteSwatches(t *testing.T) {
+	build := func(t *testing.T) (imagegraph.NodeID, *imagegraph.ImageGraph) {
+		b := testsupport.NewGraphBuilder().
+			WithNode(imagegraph.NodeTypePaletteEdit).Named("edit").
+			WithConfig(&imagegraph.NodeConfigPaletteEdit{Colors: "#ff0000,!#00ff00,#0000ff"})
+		ig := b.MustBuild(t)
+		ig.ResetEvents()
+		return b.NodeID("edit"), ig
+	}
+
+	colors := func(t *testing.T, ig *imagegraph.ImageGraph, nodeID imagegraph.NodeID) string {
+		t.Helper()
+		node, _ := ig.Nodes.Get(nodeID)
+		return node.Config.(*imagegraph.NodeConfigPaletteEdit).Colors
+	}
+
+	t.Run("lists swatches", func(t *testing.T) {
+		config := &imagegraph.NodeConfigPaletteEdit{Colors: "#ff0000, !#00ff00"}
+		swatches, err := config.Swatches()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []imagegraph.PaletteSwatch{{Color: "#ff0000", Enabled: true}, {Color: "#00ff00", Enabled: false}}
+		if !slices.Equal(swatches, want) {
+			t.Errorf("expected %v, got %v", want, swatches)
+		}
+	})
+
+	t.Run("toggles a swatch", func(t *testing.T) {
+		nodeID, ig := build(t)
+
+		if err := ig.SetPaletteSwatchEnabled(nodeID, "#00ff00", true); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := colors(t, ig, nodeID); got != "#ff0000,#00ff00,#0000ff" {
+			t.Errorf("expected the color enabled, got %q", got)
+		}
+
+		found := false
+		for _, event := range ig.GetEvents() {
+			if _, ok := event.(*imagegraph.NodeConfigSetEvent); ok {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("expected the node's config to be set")
+		}
+
+		if err := ig.SetPaletteSwatchEnabled(nodeID, "#123456", false); !errors.Is(err, imagegraph.ErrSwatchNotFound) {
+			t.Errorf("expected ErrSwatchNotFound, got %v", err)
+		}
+	})
+
+	t.Run("adds a swatch", func(t *testing.T) {
+		nodeID, ig := build(t)
+
+		if err := ig.AddPaletteSwatch(nodeID, "#ffffff", true, 1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := ig.AddPaletteSwatch(nodeID, "#000000", false, -1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := colors(t, ig, nodeID); got != "#ff0000,#ffffff,!#00ff00,#0000ff,!#000000" {
+			t.Errorf("expected the colors added, got %q", got)
+		}
+
+		if err := ig.AddPaletteSwatch(nodeID, "#FF0000", true, 0); !errors.Is(err, imagegraph.ErrSwatchExists) {
+			t.Errorf("expected ErrSwatchExists, got %v", err)
+		}
+	})
+
+	t.Run("removes a swatch", func(t *testing.T) {
+		nodeID, ig := build(t)
+
+		if err := ig.RemovePaletteSwatch(nodeID, "#00ff00"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := colors(t, ig, nodeID); got != "#ff0000,#0000ff" {
+			t.Errorf("expected the color removed, got %q", got)
+		}
+
+		if err := ig.RemovePaletteSwatch(nodeID, "#00ff00"); !errors.Is(err, imagegraph.ErrSwatchNotFound) {
+			t.Errorf("expected ErrSwatchNotFound, got %v", err)
+		}
+	})
+
+	t.Run("reorders swatches", func(t *testing.T) {
+		nodeID, ig := build(t)
+
+		if err := ig.ReorderPaletteSwatches(nodeID, []string{"#0000ff", "#00ff00", "#ff0000"}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := colors(t, ig, nodeID); got != "#0000ff,!#00ff00,#ff0000" {
+			t.Errorf("expected the colors reordered, got %q", got)
+		}
+
+		for _, order := range [][]string{
+			{"#0000ff", "#ff0000"},
+			{"#0000ff", "#0000ff", "#ff0000"},
+			{"#0000ff", "#00ff00", "#123456"},
+		} {
+			if err := ig.ReorderPaletteSwatches(nodeID, order); !errors.Is(err, imagegraph.ErrInvalidSwatchOrder) {
+				t.Errorf("%v: expected ErrInvalidSwatchOrder, got %v", order, err)
+			}
+		}
+	})
+
+	t.Run("only edits palette edit nodes", func(t *testing.T) {
+		b := testsupport.NewGraphBuilder().WithInput()
+		ig := b.MustBuild(t)
+
+		if err := ig.AddPaletteSwatch(b.NodeID("input"), "#ffffff", true, -1); !errors.Is(err, imagegraph.ErrNotPaletteEdit) {
+			t.Errorf("expected ErrNotPaletteEdit, got %v", err)
+		}
+	})
+}
+
+func TestParseSwatchColor(t *testing.T) {
+	for input, want := range map[string]string{"#FF8800": "#ff8800", "ff8800": "#ff8800", " #abcdef ": "#abcdef"} {
+		if got, err := imagegraph.ParseSwatchColor(input); err != nil || got != want {
+			t.Errorf("ParseSwatchColor(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "#fff", "##ff8800", "#gg8800"} {
+		if _, err := imagegraph.ParseSwatchColor(input); err == nil {
+			t.Errorf("ParseSwatchColor(%q): expected an error", input)
+		}
+	}
 }
@@ -1,6 +1,21 @@
 package imagegraph
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFixedInput is returned when adding an input to a node whose type has
+// a fixed set of inputs, or removing one its type declares
+var ErrFixedInput = errors.New("input is fixed by the node type")
+
+// ErrInvalidInputName is returned when adding an input that isn't named
+// like the dynamic inputs of the node's type
+var ErrInvalidInputName = errors.New("invalid input name")
+
+// ErrInputLimit is returned when adding an input to a node that already has
+// as many as its type allows
+var ErrInputLimit = errors.New("node has as many inputs as its type allows")
 
 type InputName string
 
@@ -14,6 +29,13 @@ type Input struct {
 	ImageID         ImageID
 	Connected       bool
 	InputConnection InputConnection
+
+	// Transform is made to the connected output's image before the node
+	// uses it, one of the ConnectionTransformOptions or none
+	Transform string
+
+	// Optional inputs don't block output generation while disconnected
+	Optional bool
 }
 
 func MakeInput(name InputName) Input {
@@ -41,6 +63,7 @@ func (i *Input) Disconnect() error {
 
 	i.Connected = false
 	i.InputConnection = InputConnection{}
+	i.Transform = ConnectionTransformNone
 
 	return nil
 }
@@ -81,6 +104,37 @@ func (inputs Inputs) Add(name InputName) error {
 	return nil
 }
 
+// Remove removes an input that isn't connected
+func (inputs Inputs) Remove(name InputName) error {
+	input, ok := inputs[name]
+
+	if !ok {
+		return fmt.Errorf("input %q does not exist", name)
+	}
+
+	if input.Connected {
+		return fmt.Errorf("input %q is connected", name)
+	}
+
+	delete(inputs, name)
+
+	return nil
+}
+
+func (inputs Inputs) SetOptional(names []InputName) error {
+	for _, name := range names {
+		input, ok := inputs[name]
+
+		if !ok {
+			return fmt.Errorf("input %q does not exist", name)
+		}
+
+		input.Optional = true
+	}
+
+	return nil
+}
+
 func (inputs Inputs) Exists(name InputName) bool {
 	_, ok := inputs[name]
 	return ok
@@ -171,6 +225,9 @@ func (inputs Inputs) UnsetImage(inputName InputName) error {
 func (inputs Inputs) AllSet() bool {
 	for _, input := range inputs {
 		if !input.Connected {
+			if input.Optional {
+				continue
+			}
 			return false
 		}
 
@@ -181,3 +238,15 @@ func (inputs Inputs) AllSet() bool {
 
 	return true
 }
+
+// clone copies the inputs so that changes to the copies leave them unchanged
+func (inputs Inputs) clone() Inputs {
+	clone := make(Inputs, len(inputs))
+
+	for name, input := range inputs {
+		c := *input
+		clone[name] = &c
+	}
+
+	return clone
+}
@@ -2,7 +2,9 @@ package imagegraph
 
 import "github.com/dmpettyp/dorky/mapper"
 
-var NodeTypeMapper = mapper.MustNew[string, NodeType](
+// nodeTypeNames pairs the name of each node type with its NodeType. Node
+// types registered at startup are added to it.
+var nodeTypeNames = []any{
 	"input", NodeTypeInput,
 	"output", NodeTypeOutput,
 	"crop", NodeTypeCrop,
@@ -14,10 +16,34 @@ var NodeTypeMapper = mapper.MustNew[string, NodeType](
 	"palette_apply", NodeTypePaletteApply,
 	"palette_create", NodeTypePaletteCreate,
 	"palette_edit", NodeTypePaletteEdit,
-)
+	"generate", NodeTypeGenerate,
+	"upscale", NodeTypeUpscale,
+	"diff", NodeTypeDiff,
+	"auto_contrast", NodeTypeAutoContrast,
+	"color_space", NodeTypeColorSpace,
+	"contact_sheet", NodeTypeContactSheet,
+	"switch", NodeTypeSwitch,
+}
+
+var NodeTypeMapper = mapper.MustNew[string, NodeType](nodeTypeNames...)
 
 var NodeStateMapper = mapper.MustNew[string, NodeState](
 	"waiting", Waiting,
 	"generating", Generating,
 	"generated", Generated,
+	"failed", Failed,
+)
+
+// PaletteExtractMethodMapper maps the method names of PaletteExtract configs
+// to the algorithms they select. Its names are paletteExtractMethodOptions.
+var PaletteExtractMethodMapper = mapper.MustNew[string, PaletteExtractMethod](
+	"oklab_clusters", PaletteExtractOKLabClusters,
+	"dominant_frequency", PaletteExtractDominantFrequency,
+	"median_cut", PaletteExtractMedianCut,
+)
+
+var RoleMapper = mapper.MustNew[string, Role](
+	"viewer", RoleViewer,
+	"editor", RoleEditor,
+	"owner", RoleOwner,
 )
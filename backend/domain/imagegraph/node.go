@@ -1,11 +1,20 @@
 package imagegraph
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/dmpettyp/dorky/state"
 )
 
+// ErrNodePinned is returned when changing the output images of a pinned
+// node, which keeps its outputs until it is unpinned
+var ErrNodePinned = errors.New("node is pinned")
+
 // Node represents a node in the ImageGraph that define the image pipeline.
 // Node are connected to upstream nodes through thier inputs, and to their
 // downstream nodes through their outputs.
@@ -17,6 +26,10 @@ type Node struct {
 	// the node's version is incremented by one
 	Version NodeVersion
 
+	// When the node was created, and when it last emitted an event
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
 	// The type of the node, representing the kind of transformations it
 	// performs on its inputs to generate its outputs
 	Type NodeType
@@ -24,11 +37,51 @@ type Node struct {
 	// The name assigned to the node, chosen by the ImageGraph author
 	Name string
 
+	// Free-form notes documenting the node, written in markdown
+	Description string
+
+	// Labels used to organize the node
+	Tags Tags
+
+	// Optional client-supplied identifier, unique within the ImageGraph, used
+	// to map the node to an entity in an external system
+	ExternalID string
+
 	State state.State[NodeState]
 
+	// The reason the node's most recent output generation failed, only set
+	// while the node is in the Failed state
+	Error string
+
 	// Config is the typed configuration for the node.
 	Config NodeConfig
 
+	// ConfigWarnings describe what migrating the node's stored config to
+	// its node type's current config schema changed. They are kept until
+	// the node's config is next set.
+	ConfigWarnings []string
+
+	// Expressions compute config fields from the sizes of the node's input
+	// images each time it generates, overriding the fields' values in Config
+	Expressions ConfigExpressions
+
+	// Implementation is the version of the node type's algorithm used to
+	// generate the node's outputs. It is recorded when the node is created
+	// so that algorithm changes don't alter the results of existing graphs.
+	Implementation int
+
+	// Bypassed nodes skip generation and forward their primary input image
+	// to their primary output unchanged
+	Bypassed bool
+
+	// Pinned nodes keep their current outputs; changes that would normally
+	// regenerate them are suppressed until the node is unpinned
+	Pinned bool
+
+	// Stale is set when a change was suppressed while the node was pinned,
+	// meaning its outputs no longer reflect its inputs and config
+	Stale bool
+
 	// The preview image for the node
 	Preview ImageID
 
@@ -48,11 +101,22 @@ type Node struct {
 	addEvent func(Event)
 }
 
+// NodeOption configures optional Node properties at creation
+type NodeOption func(*Node)
+
+// WithNodeExternalID assigns an external ID to a new Node
+func WithNodeExternalID(externalID string) NodeOption {
+	return func(n *Node) {
+		n.ExternalID = externalID
+	}
+}
+
 func NewNode(
 	eventAdder func(Event),
 	id NodeID,
 	nodeType NodeType,
 	name string,
+	opts ...NodeOption,
 ) (
 	*Node,
 	error,
@@ -80,24 +144,34 @@ func NewNode(
 		return nil, fmt.Errorf("could not create node: %w", err)
 	}
 
+	if err := inputs.SetOptional(cfg.OptionalInputs); err != nil {
+		return nil, fmt.Errorf("could not create node: %w", err)
+	}
+
 	outputs, err := NewOutputs(cfg.Outputs)
 	if err != nil {
 		return nil, fmt.Errorf("could not create node: %w", err)
 	}
 
 	n := &Node{
-		ID:       id,
-		State:    initState,
-		addEvent: eventAdder,
-		Version:  0,
-		Type:     nodeType,
-		Name:     name,
-		Config:   cfg.NewConfig(),
-		Inputs:   inputs,
-		Outputs:  outputs,
+		ID:             id,
+		State:          initState,
+		addEvent:       eventAdder,
+		Version:        0,
+		Type:           nodeType,
+		Name:           name,
+		Config:         cfg.NewConfig(),
+		Inputs:         inputs,
+		Outputs:        outputs,
+		Implementation: cfg.LatestImplementation(),
+	}
+
+	for _, opt := range opts {
+		opt(n)
 	}
 
 	n.addEvent(NewNodeCreatedEvent(n))
+	n.CreatedAt = n.UpdatedAt
 
 	// For nodes with no inputs (like Input), trigger output generation right away
 	if err = n.triggerOutputsIfReady(); err != nil {
@@ -111,6 +185,18 @@ func (n *Node) SetEventAdder(eventAdder func(Event)) {
 	n.addEvent = eventAdder
 }
 
+// clone copies the node, with its inputs, outputs and the rest of its
+// mutable fields, so that changes to the copy leave the node unchanged
+func (n *Node) clone() *Node {
+	c := *n
+	c.Tags = slices.Clone(n.Tags)
+	c.ConfigWarnings = slices.Clone(n.ConfigWarnings)
+	c.Expressions = maps.Clone(n.Expressions)
+	c.Inputs = n.Inputs.clone()
+	c.Outputs = n.Outputs.clone()
+	return &c
+}
+
 func (n *Node) SetConfig(config NodeConfig) error {
 	if config == nil {
 		return fmt.Errorf("config cannot be nil")
@@ -130,6 +216,7 @@ func (n *Node) SetConfig(config NodeConfig) error {
 	}
 
 	n.Config = config
+	n.ConfigWarnings = nil
 
 	n.addEvent(NewNodeConfigSetEvent(n))
 
@@ -142,6 +229,185 @@ func (n *Node) SetConfig(config NodeConfig) error {
 	return nil
 }
 
+// SetBypassed enables or disables the bypass of the node. Outputs generated
+// before the change are discarded and regenerated if the node's inputs are
+// ready.
+func (n *Node) SetBypassed(bypassed bool) error {
+	if bypassed && !NodeTypeDefs[n.Type].CanBypass() {
+		return fmt.Errorf("cannot bypass node %q: node type has no input to forward", n.ID)
+	}
+
+	if n.Bypassed == bypassed {
+		return nil
+	}
+
+	n.Bypassed = bypassed
+
+	n.addEvent(NewNodeBypassSetEvent(n))
+
+	if n.Pinned {
+		n.suppressRegeneration()
+		return nil
+	}
+
+	n.resetOutputImages()
+
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return fmt.Errorf(
+			"could not set bypass for node %q: %w", n.ID, err,
+		)
+	}
+
+	return nil
+}
+
+// regenerateOutputs regenerates the node's outputs after a graph-level
+// setting they depend on changes. Pinned nodes keep their outputs.
+func (n *Node) regenerateOutputs() error {
+	if n.Pinned {
+		n.suppressRegeneration()
+		return nil
+	}
+
+	n.resetOutputImages()
+
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return fmt.Errorf("could not regenerate outputs for node %q: %w", n.ID, err)
+	}
+
+	return nil
+}
+
+// SetImplementation switches the node to the given implementation version of
+// its node type's algorithm, regenerating its outputs if the version changes.
+// Older versions may be selected to keep a node on previous behavior.
+func (n *Node) SetImplementation(implementation int) error {
+	latest := NodeTypeDefs[n.Type].LatestImplementation()
+
+	if implementation < 1 || implementation > latest {
+		return fmt.Errorf(
+			"cannot set implementation for node %q: version %d is not between 1 and %d",
+			n.ID, implementation, latest,
+		)
+	}
+
+	if n.Implementation == implementation {
+		return nil
+	}
+
+	n.Implementation = implementation
+
+	n.addEvent(NewNodeImplementationSetEvent(n))
+
+	if n.Pinned {
+		n.suppressRegeneration()
+		return nil
+	}
+
+	n.resetOutputImages()
+
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return fmt.Errorf(
+			"could not set implementation for node %q: %w", n.ID, err,
+		)
+	}
+
+	return nil
+}
+
+// SetExpressions replaces the node's config expressions, regenerating its
+// outputs with them if they change. An empty set removes them all. The
+// expressions may refer to the given graph parameters.
+func (n *Node) SetExpressions(exprs ConfigExpressions, params GraphParameters) error {
+	if err := exprs.Validate(n, params); err != nil {
+		return fmt.Errorf("cannot set expressions for node %q: %w", n.ID, err)
+	}
+
+	if maps.Equal(n.Expressions, exprs) {
+		return nil
+	}
+
+	n.Expressions = nil
+	if len(exprs) > 0 {
+		n.Expressions = maps.Clone(exprs)
+	}
+
+	n.addEvent(NewNodeExpressionsSetEvent(n))
+
+	if n.Pinned {
+		n.suppressRegeneration()
+		return nil
+	}
+
+	n.resetOutputImages()
+
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return fmt.Errorf(
+			"could not set expressions for node %q: %w", n.ID, err,
+		)
+	}
+
+	return nil
+}
+
+// resolvedConfig is the node's config with its expressions evaluated for
+// input images of the given sizes and the given graph parameters, or its
+// config as it is if they can't be evaluated
+func (n *Node) resolvedConfig(sizes map[InputName]ImageSize, params GraphParameters) NodeConfig {
+	resolved, err := n.Expressions.Resolve(n.Config, ExpressionVariables(n.Type, sizes, params))
+	if err != nil {
+		return n.Config
+	}
+	return resolved
+}
+
+// UpgradeImplementation migrates the node to the latest implementation of
+// its node type
+func (n *Node) UpgradeImplementation() error {
+	return n.SetImplementation(NodeTypeDefs[n.Type].LatestImplementation())
+}
+
+// SetPinned pins or unpins the node. Only nodes that have generated their
+// outputs can be pinned. Unpinning a stale node regenerates its outputs from
+// its current inputs.
+func (n *Node) SetPinned(pinned bool) error {
+	if n.Pinned == pinned {
+		return nil
+	}
+
+	if pinned && n.State.Get() != Generated {
+		return fmt.Errorf("cannot pin node %q: node has no generated outputs", n.ID)
+	}
+
+	n.Pinned = pinned
+
+	n.addEvent(NewNodePinnedSetEvent(n))
+
+	if pinned || !n.Stale {
+		return nil
+	}
+
+	n.Stale = false
+
+	if n.Inputs.AllSet() {
+		if err := n.triggerOutputsIfReady(); err != nil {
+			return fmt.Errorf("could not unpin node %q: %w", n.ID, err)
+		}
+		return nil
+	}
+
+	n.Preview = ImageID{}
+	n.Error = ""
+
+	if err := n.State.Transition(Waiting); err != nil {
+		return fmt.Errorf("could not unpin node %q: %w", n.ID, err)
+	}
+
+	n.resetOutputImages()
+
+	return nil
+}
+
 func (n *Node) SetName(name string) error {
 	if NodeTypeDefs[n.Type].NameRequired && len(name) == 0 {
 		return fmt.Errorf("cannot set node name to empty string")
@@ -154,6 +420,69 @@ func (n *Node) SetName(name string) error {
 	return nil
 }
 
+// MaxNodeDescriptionLength is the longest description, in bytes, that can be
+// set on a node
+const MaxNodeDescriptionLength = 10000
+
+// SetDescription sets the node's notes. Descriptions document the pipeline
+// and don't affect the node's outputs.
+func (n *Node) SetDescription(description string) error {
+	if len(description) > MaxNodeDescriptionLength {
+		return fmt.Errorf(
+			"cannot set description for node %q: description is longer than %d bytes",
+			n.ID, MaxNodeDescriptionLength,
+		)
+	}
+
+	if n.Description == description {
+		return nil
+	}
+
+	n.Description = description
+
+	n.addEvent(NewNodeDescriptionSetEvent(n))
+
+	return nil
+}
+
+// AddTag labels the node with a tag. Tags don't affect the node's outputs.
+func (n *Node) AddTag(tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	tags, added := n.Tags.with(tag)
+	if !added {
+		return nil
+	}
+
+	n.Tags = tags
+
+	n.addEvent(NewNodeTagAddedEvent(n, tag))
+
+	return nil
+}
+
+// RemoveTag removes a tag from the node
+func (n *Node) RemoveTag(tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	tags, removed := n.Tags.without(tag)
+	if !removed {
+		return nil
+	}
+
+	n.Tags = tags
+
+	n.addEvent(NewNodeTagRemovedEvent(n, tag))
+
+	return nil
+}
+
 func (n *Node) SetPreview(imageID ImageID, version NodeVersion) error {
 	if imageID.IsNil() {
 		return fmt.Errorf("cannot set preview to nil image, use UnsetPreview instead")
@@ -207,24 +536,48 @@ func (n *Node) SetOutputImage(
 	outputName OutputName,
 	imageID ImageID,
 	version NodeVersion,
+) error {
+	return n.setOutputImage(outputName, imageID, version, nil)
+}
+
+// SetUploadedOutputImage updates a node's output to an uploaded image,
+// recording what was detected about the upload
+func (n *Node) SetUploadedOutputImage(
+	outputName OutputName,
+	imageID ImageID,
+	version NodeVersion,
+	upload UploadedImage,
+) error {
+	return n.setOutputImage(outputName, imageID, version, &upload)
+}
+
+func (n *Node) setOutputImage(
+	outputName OutputName,
+	imageID ImageID,
+	version NodeVersion,
+	upload *UploadedImage,
 ) error {
 	if version == 0 {
 		return fmt.Errorf("node version must be provided for output")
 	}
-	if version < n.ImageVersion {
+	if version < n.ImageVersion || n.Pinned {
 		return nil
 	}
 	n.ImageVersion = version
 
-	if err := n.Outputs.SetImage(outputName, imageID); err != nil {
+	e := NewOutputImageSetEvent(n, outputName, imageID, upload)
+
+	dropped, err := n.Outputs.SetImage(outputName, imageID, e.GetTimestamp())
+	if err != nil {
 		return fmt.Errorf(
 			"could not set output %q for node %q: %w", outputName, n.ID, err,
 		)
 	}
 
-	n.addEvent(NewOutputImageSetEvent(n, outputName, imageID))
+	n.addEvent(e)
+	n.dropOutputVariants(outputName, dropped)
 
-	if n.Outputs.AllSet() {
+	if n.outputsComplete() {
 		err := n.State.Transition(Generated)
 
 		if err != nil {
@@ -237,24 +590,141 @@ func (n *Node) SetOutputImage(
 	return nil
 }
 
+// SetGenerationFailed moves a node that is generating its outputs into the
+// Failed state. Failures reported for an older version of the node, or for a
+// node that is no longer generating, are ignored.
+func (n *Node) SetGenerationFailed(message string, version NodeVersion) error {
+	if version == 0 {
+		return fmt.Errorf("node version must be provided for generation failure")
+	}
+	if version < n.ImageVersion {
+		return nil
+	}
+	if n.State.Get() != Generating {
+		return nil
+	}
+	n.ImageVersion = version
+
+	if err := n.State.Transition(Failed); err != nil {
+		return fmt.Errorf(
+			"could not set generation failure for node %q: %w", n.ID, err,
+		)
+	}
+
+	n.Error = message
+
+	n.addEvent(NewNodeGenerationFailedEvent(n))
+
+	return nil
+}
+
+// ResumeGeneration requests the outputs of a generating node again, after
+// the generation producing them was interrupted. Nodes that are no longer
+// generating are left alone.
+func (n *Node) ResumeGeneration() error {
+	if n.State.Get() != Generating {
+		return nil
+	}
+
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return fmt.Errorf("could not resume generation for node %q: %w", n.ID, err)
+	}
+
+	return nil
+}
+
 func (n *Node) UnsetOutputImage(outputName OutputName) error {
-	oldImageID, err := n.Outputs.UnsetImage(outputName)
+	output, ok := n.Outputs[outputName]
 
-	if err != nil {
-		return fmt.Errorf("could not unset node %q output image: %w", n.ID, err)
+	if !ok {
+		return fmt.Errorf(
+			"could not unset node %q output image: no output named %q exists",
+			n.ID, outputName,
+		)
 	}
 
-	if !oldImageID.IsNil() {
-		// Keep image version at least at current node version
-		if n.ImageVersion < n.Version {
-			n.ImageVersion = n.Version
-		}
-		n.addEvent(NewOutputImageUnsetEvent(n, outputName, oldImageID))
+	if n.Pinned {
+		return fmt.Errorf("could not unset node %q output image: %w", n.ID, ErrNodePinned)
+	}
+
+	if !output.HasImage() {
+		return nil
+	}
+
+	// Keep image version at least at current node version
+	if n.ImageVersion < n.Version {
+		n.ImageVersion = n.Version
 	}
 
+	n.retireOutputImage(output)
+
 	return nil
 }
 
+// PromoteOutputVariant makes an image from an output's history the output's
+// current image again, moving the image it replaces into the history.
+// Downstream nodes regenerate from the promoted image.
+func (n *Node) PromoteOutputVariant(outputName OutputName, imageID ImageID) error {
+	output, ok := n.Outputs[outputName]
+
+	if !ok {
+		return fmt.Errorf(
+			"could not promote node %q output image: no output named %q exists",
+			n.ID, outputName,
+		)
+	}
+
+	if n.State.Get() != Generated {
+		return fmt.Errorf(
+			"could not promote node %q output image: node has no generated outputs",
+			n.ID,
+		)
+	}
+
+	if n.Pinned {
+		return fmt.Errorf("could not promote node %q output image: %w", n.ID, ErrNodePinned)
+	}
+
+	variant, err := output.takeVariant(imageID)
+	if err != nil {
+		return fmt.Errorf("could not promote node %q output image: %w", n.ID, err)
+	}
+
+	// Outputs of generations still in flight are older than the promoted
+	// image and must not replace it
+	n.ImageVersion = n.Version + 1
+
+	e := NewOutputImageSetEvent(n, outputName, variant.ImageID, nil)
+
+	_ = output.retireImage(e.GetTimestamp())
+	output.SetImage(variant.ImageID, variant.GeneratedAt)
+
+	n.addEvent(e)
+
+	// The preview shows the replaced image, it's generated again on demand
+	return n.UnsetPreview()
+}
+
+// retireOutputImage unsets an output's image, keeping it in the output's
+// history
+func (n *Node) retireOutputImage(output *Output) {
+	e := NewOutputImageUnsetEvent(n, output.Name, output.ImageID)
+	dropped := output.retireImage(e.GetTimestamp())
+
+	n.addEvent(e)
+	n.dropOutputVariants(output.Name, dropped)
+}
+
+// dropOutputVariants records that images fell out of an output's history so
+// they can be removed
+func (n *Node) dropOutputVariants(outputName OutputName, imageIDs []ImageID) {
+	if len(imageIDs) == 0 {
+		return
+	}
+
+	n.addEvent(NewNodeOutputVariantsDroppedEvent(n, outputName, imageIDs))
+}
+
 type withNode func(id NodeID, f func(*Node) error) error
 
 func (n *Node) UnsetOutputConnections(
@@ -347,19 +817,187 @@ func (n *Node) HasInput(inputName InputName) bool {
 	return n.Inputs.Exists(inputName)
 }
 
+// InputNames lists the node's inputs in order: those its type declares,
+// then the dynamic inputs it added by number
+func (n *Node) InputNames() []InputName {
+	def := NodeTypeDefs[n.Type]
+	names := make([]InputName, 0, len(n.Inputs))
+
+	for _, name := range def.Inputs {
+		if n.Inputs.Exists(name) {
+			names = append(names, name)
+		}
+	}
+
+	var added []InputName
+	for name := range n.Inputs {
+		if !slices.Contains(def.Inputs, name) {
+			added = append(added, name)
+		}
+	}
+
+	slices.SortFunc(added, func(a, b InputName) int {
+		numberA, _ := def.DynamicInputNumber(a)
+		numberB, _ := def.DynamicInputNumber(b)
+		return cmp.Or(cmp.Compare(numberA, numberB), cmp.Compare(a, b))
+	})
+
+	return append(names, added...)
+}
+
+// NextInputName names the next dynamic input of a node whose type lets it
+// grow extra inputs, numbered on from the highest it has
+func (n *Node) NextInputName() (InputName, error) {
+	def := NodeTypeDefs[n.Type]
+
+	if def.DynamicInputs == "" {
+		return "", fmt.Errorf(
+			"could not name input of node %q: %w", n.ID, ErrFixedInput,
+		)
+	}
+
+	next := 1
+	for name := range n.Inputs {
+		if number, ok := def.DynamicInputNumber(name); ok && number >= next {
+			next = number + 1
+		}
+	}
+
+	return def.DynamicInputName(next), nil
+}
+
+// AddInput adds a dynamic input to a node whose type allows it. The input
+// must be named like the type's dynamic inputs, and is optional so the node
+// doesn't wait for it to be connected.
+func (n *Node) AddInput(inputName InputName) error {
+	def := NodeTypeDefs[n.Type]
+
+	if def.DynamicInputs == "" {
+		return fmt.Errorf(
+			"could not add input to node %q: %w", n.ID, ErrFixedInput,
+		)
+	}
+
+	if _, ok := def.DynamicInputNumber(inputName); !ok {
+		return fmt.Errorf(
+			"could not add input to node %q: %w: inputs are named like %q",
+			n.ID, ErrInvalidInputName, def.DynamicInputName(1),
+		)
+	}
+
+	if n.HasInput(inputName) {
+		return fmt.Errorf(
+			"could not add input to node %q: %w: input %q already exists",
+			n.ID, ErrInvalidInputName, inputName,
+		)
+	}
+
+	if len(n.Inputs) >= def.MaxInputs {
+		return fmt.Errorf(
+			"could not add input to node %q: %w", n.ID, ErrInputLimit,
+		)
+	}
+
+	if err := n.Inputs.Add(inputName); err != nil {
+		return fmt.Errorf("could not add input to node %q: %w", n.ID, err)
+	}
+
+	n.Inputs[inputName].Optional = true
+
+	n.addEvent(NewInputAddedEvent(n, inputName))
+
+	return nil
+}
+
+// RemoveInput removes a dynamic input that isn't connected from the node
+func (n *Node) RemoveInput(inputName InputName) error {
+	if slices.Contains(NodeTypeDefs[n.Type].Inputs, inputName) {
+		return fmt.Errorf(
+			"could not remove input %q from node %q: %w", inputName, n.ID, ErrFixedInput,
+		)
+	}
+
+	if err := n.Inputs.Remove(inputName); err != nil {
+		return fmt.Errorf("could not remove input from node %q: %w", n.ID, err)
+	}
+
+	n.addEvent(NewInputRemovedEvent(n, inputName))
+
+	return nil
+}
+
 func (n *Node) ConnectInputFrom(
 	inputName InputName,
 	fromNodeID NodeID,
 	outputName OutputName,
+	transform string,
 ) error {
+	wasAllSet := n.Inputs.AllSet()
+
 	if err := n.Inputs.ConnectFrom(inputName, fromNodeID, outputName); err != nil {
 		return err
 	}
 
+	n.Inputs[inputName].Transform = transform
+
 	n.addEvent(
-		NewInputConnectedEvent(n, inputName, fromNodeID, outputName),
+		NewInputConnectedEvent(n, inputName, fromNodeID, outputName, transform),
 	)
 
+	// Connecting an optional input means the node has to wait for the
+	// input's image before it can generate again
+	if wasAllSet && !n.Inputs.AllSet() && n.Pinned {
+		n.suppressRegeneration()
+	} else if wasAllSet && !n.Inputs.AllSet() {
+		n.Preview = ImageID{}
+		n.Error = ""
+
+		err := n.State.Transition(Waiting)
+
+		if err != nil {
+			return fmt.Errorf(
+				"could not connect input %q for node %q: %w", inputName, n.ID, err,
+			)
+		}
+
+		n.resetOutputImages()
+	}
+
+	return nil
+}
+
+// SetInputTransform changes the transform made to the image of a connected
+// input, regenerating the node's outputs if its inputs are ready
+func (n *Node) SetInputTransform(inputName InputName, transform string) error {
+	if err := ValidateConnectionTransform(transform); err != nil {
+		return fmt.Errorf("could not set input transform for node %q: %w", n.ID, err)
+	}
+
+	input, err := n.Inputs.Get(inputName)
+	if err != nil {
+		return fmt.Errorf("could not set input transform for node %q: %w", n.ID, err)
+	}
+
+	if !input.Connected {
+		return fmt.Errorf(
+			"could not set input transform for node %q: input %q is not connected", n.ID, inputName,
+		)
+	}
+
+	if input.Transform == transform {
+		return nil
+	}
+
+	input.Transform = transform
+
+	n.addEvent(NewInputTransformSetEvent(n, inputName, transform))
+
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return fmt.Errorf(
+			"could not set input transform for node %q: %w", n.ID, err,
+		)
+	}
+
 	return nil
 }
 
@@ -393,26 +1031,35 @@ func (n *Node) DisconnectInput(inputName InputName) (
 		),
 	)
 
-	// If input didn't have an image, we're done
-	if !hadImage {
-		return inputConnection, nil
-	}
+	if hadImage && n.Pinned {
+		n.addEvent(NewInputImageUnsetEvent(n, inputName))
+		n.suppressRegeneration()
+	} else if hadImage {
+		n.addEvent(NewInputImageUnsetEvent(n, inputName))
 
-	n.addEvent(NewInputImageUnsetEvent(n, inputName))
+		if wasAllSet {
+			n.Preview = ImageID{}
+			n.Error = ""
 
-	if wasAllSet {
-		n.Preview = ImageID{}
-
-		err := n.State.Transition(Waiting)
+			err := n.State.Transition(Waiting)
 
-		if err != nil {
-			return inputConnection, fmt.Errorf(
-				"could not disconnect input %q from node %q: %w", inputName, n.ID, err,
-			)
+			if err != nil {
+				return inputConnection, fmt.Errorf(
+					"could not disconnect input %q from node %q: %w", inputName, n.ID, err,
+				)
+			}
 		}
+
+		n.resetOutputImages()
 	}
 
-	n.resetOutputImages()
+	// Disconnecting an optional input may leave the node with everything it
+	// needs to generate its outputs
+	if err := n.triggerOutputsIfReady(); err != nil {
+		return inputConnection, fmt.Errorf(
+			"could not disconnect input %q from node %q: %w", inputName, n.ID, err,
+		)
+	}
 
 	return inputConnection, nil
 }
@@ -454,8 +1101,14 @@ func (n *Node) UnsetInputImage(
 
 	n.addEvent(NewInputImageUnsetEvent(n, inputName))
 
+	if n.Pinned {
+		n.suppressRegeneration()
+		return nil
+	}
+
 	if wasAllSet {
 		n.Preview = ImageID{}
+		n.Error = ""
 
 		err := n.State.Transition(Waiting)
 
@@ -476,26 +1129,55 @@ func (n *Node) triggerOutputsIfReady() error {
 		return nil
 	}
 
+	if n.Pinned {
+		n.suppressRegeneration()
+		return nil
+	}
+
 	err := n.State.Transition(Generating)
 
 	if err != nil {
 		return err
 	}
 
+	n.Error = ""
+
 	n.addEvent(NewNodeNeedsOutputsEvent(n))
 
 	return nil
 }
 
+// suppressRegeneration marks a pinned node as stale instead of regenerating
+// its outputs
+func (n *Node) suppressRegeneration() {
+	if n.Stale {
+		return
+	}
+
+	n.Stale = true
+
+	n.addEvent(NewNodeRegenerationSuppressedEvent(n))
+}
+
+// outputsComplete reports whether the node has set all of the outputs it
+// produces. A bypassed node only produces its primary output.
+func (n *Node) outputsComplete() bool {
+	if !n.Bypassed {
+		return n.Outputs.AllSet()
+	}
+
+	imageID, err := n.Outputs.GetImage(NodeTypeDefs[n.Type].PrimaryOutput())
+
+	return err == nil && !imageID.IsNil()
+}
+
 func (n *Node) resetOutputImages() {
 	_ = n.Outputs.Each(func(output *Output) error {
 		if output.ImageID.IsNil() {
 			return nil
 		}
 
-		n.addEvent(NewOutputImageUnsetEvent(n, output.Name, output.ImageID))
-
-		output.ResetImage()
+		n.retireOutputImage(output)
 
 		return nil
 	})
@@ -8,6 +8,7 @@ const (
 	Waiting NodeState = iota
 	Generating
 	Generated
+	Failed
 )
 
 func (s NodeState) MarshalJSON() ([]byte, error) {
@@ -15,11 +16,27 @@ func (s NodeState) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+func (s *NodeState) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	parsed, err := NodeStateMapper.To(str)
+	if err != nil {
+		return err
+	}
+
+	*s = parsed
+	return nil
+}
+
 func (s NodeState) Transitions() map[NodeState][]NodeState {
 	return map[NodeState][]NodeState{
 		Waiting:    {Generating, Waiting},
-		Generating: {Generated, Waiting, Generating},
+		Generating: {Generated, Waiting, Generating, Failed},
 		Generated:  {Waiting, Generating, Generated},
+		Failed:     {Waiting, Generating, Failed},
 	}
 }
 
@@ -28,5 +45,6 @@ func AllNodeStates() []NodeState {
 		Waiting,
 		Generating,
 		Generated,
+		Failed,
 	}
 }
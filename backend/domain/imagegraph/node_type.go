@@ -2,6 +2,9 @@ package imagegraph
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
 type NodeType int
@@ -19,6 +22,13 @@ const (
 	NodeTypePaletteApply
 	NodeTypePaletteCreate
 	NodeTypePaletteEdit
+	NodeTypeGenerate
+	NodeTypeUpscale
+	NodeTypeDiff
+	NodeTypeAutoContrast
+	NodeTypeColorSpace
+	NodeTypeContactSheet
+	NodeTypeSwitch
 )
 
 func (nt NodeType) MarshalJSON() ([]byte, error) {
@@ -26,12 +36,129 @@ func (nt NodeType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+func (nt *NodeType) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	if str == "unknown" {
+		*nt = NodeTypeNone
+		return nil
+	}
+
+	parsed, err := NodeTypeMapper.To(str)
+	if err != nil {
+		return err
+	}
+
+	*nt = parsed
+	return nil
+}
+
 // NodeTypeDef defines the structure of a node type
 type NodeTypeDef struct {
-	Inputs       []InputName
-	Outputs      []OutputName
-	NameRequired bool
-	NewConfig    func() NodeConfig
+	Inputs  []InputName
+	Outputs []OutputName
+	// OptionalInputs lists the inputs that don't need to be connected for
+	// the node to generate its outputs
+	OptionalInputs []InputName
+	NameRequired   bool
+	NewConfig      func() NodeConfig
+	// Implementations is the number of algorithm versions available for the
+	// node type. Zero means the type has a single implementation.
+	Implementations int
+	// InputKinds and OutputKinds are the kinds of image the node type's
+	// inputs take and outputs produce. Those left out are raster images.
+	InputKinds  map[InputName]ImageKind
+	OutputKinds map[OutputName]ImageKind
+	// MatchSize maps inputs that are expected to be the same size as another
+	// input of the node
+	MatchSize map[InputName]InputName
+	// PreservesSize is set for node types whose primary output is the size
+	// of their primary input
+	PreservesSize bool
+	// DynamicInputs is set for node types whose nodes can add inputs at
+	// runtime, up to MaxInputs in all. Added inputs are optional and named
+	// after DynamicInputs, numbered on from the inputs already there, e.g.
+	// "image_3".
+	DynamicInputs InputName
+	MaxInputs     int
+	// ConfigMigrations upgrade stored configs through the versions of the
+	// node type's config schema: the first from version 1 to 2, and so on.
+	// A migration is added whenever a change to the config would break
+	// configs stored before it.
+	ConfigMigrations []ConfigMigration
+	// Deprecated, if set, says what to use instead of the node type.
+	// Existing nodes keep working and are reported by diagnostics.
+	Deprecated string
+}
+
+// InputKind is the kind of image an input of the node type takes
+func (def NodeTypeDef) InputKind(name InputName) ImageKind {
+	return def.InputKinds[name]
+}
+
+// OutputKind is the kind of image an output of the node type produces
+func (def NodeTypeDef) OutputKind(name OutputName) ImageKind {
+	return def.OutputKinds[name]
+}
+
+// LatestImplementation is the newest implementation version of the node type.
+// New nodes are created with it, existing nodes keep the version they were
+// created with until they are explicitly upgraded.
+func (def NodeTypeDef) LatestImplementation() int {
+	if def.Implementations < 1 {
+		return 1
+	}
+	return def.Implementations
+}
+
+// CanBypass reports whether nodes of the type can be bypassed, which requires
+// a primary input whose image can be forwarded to a primary output
+func (def NodeTypeDef) CanBypass() bool {
+	return len(def.Inputs) > 0 && len(def.Outputs) > 0
+}
+
+// DynamicInputNumber returns the number of an input named like the node
+// type's dynamic inputs, e.g. 3 for "image_3"
+func (def NodeTypeDef) DynamicInputNumber(name InputName) (int, bool) {
+	if def.DynamicInputs == "" {
+		return 0, false
+	}
+
+	suffix, ok := strings.CutPrefix(string(name), string(def.DynamicInputs)+"_")
+	if !ok {
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(suffix)
+	if err != nil || number < 1 || strconv.Itoa(number) != suffix {
+		return 0, false
+	}
+
+	return number, true
+}
+
+// DynamicInputName names the node type's dynamic input with a number
+func (def NodeTypeDef) DynamicInputName(number int) InputName {
+	return InputName(fmt.Sprintf("%s_%d", def.DynamicInputs, number))
+}
+
+// PrimaryInput is the first input declared for the node type
+func (def NodeTypeDef) PrimaryInput() InputName {
+	if len(def.Inputs) == 0 {
+		return ""
+	}
+	return def.Inputs[0]
+}
+
+// PrimaryOutput is the first output declared for the node type
+func (def NodeTypeDef) PrimaryOutput() OutputName {
+	if len(def.Outputs) == 0 {
+		return ""
+	}
+	return def.Outputs[0]
 }
 
 // NodeTypeDefs maps node types to their definitions
@@ -41,10 +168,13 @@ var NodeTypeDefs = map[NodeType]NodeTypeDef{
 		NewConfig: func() NodeConfig { return NewNodeConfigInput() },
 	},
 	NodeTypeOutput: {
-		Inputs:       []InputName{"input"},
-		Outputs:      []OutputName{"final"},
-		NameRequired: true,
-		NewConfig:    func() NodeConfig { return NewNodeConfigOutput() },
+		Inputs:        []InputName{"input"},
+		Outputs:       []OutputName{"final"},
+		NameRequired:  true,
+		NewConfig:     func() NodeConfig { return NewNodeConfigOutput() },
+		InputKinds:    map[InputName]ImageKind{"input": ImageKindAny},
+		OutputKinds:   map[OutputName]ImageKind{"final": ImageKindAny},
+		PreservesSize: true,
 	},
 	NodeTypeCrop: {
 		Inputs:    []InputName{"original"},
@@ -52,9 +182,10 @@ var NodeTypeDefs = map[NodeType]NodeTypeDef{
 		NewConfig: func() NodeConfig { return NewNodeConfigCrop() },
 	},
 	NodeTypeBlur: {
-		Inputs:    []InputName{"original"},
-		Outputs:   []OutputName{"blurred"},
-		NewConfig: func() NodeConfig { return NewNodeConfigBlur() },
+		Inputs:        []InputName{"original"},
+		Outputs:       []OutputName{"blurred"},
+		NewConfig:     func() NodeConfig { return NewNodeConfigBlur() },
+		PreservesSize: true,
 	},
 	NodeTypeResize: {
 		Inputs:    []InputName{"original"},
@@ -72,22 +203,78 @@ var NodeTypeDefs = map[NodeType]NodeTypeDef{
 		NewConfig: func() NodeConfig { return NewNodeConfigPixelInflate() },
 	},
 	NodeTypePaletteExtract: {
-		Inputs:    []InputName{"source"},
-		Outputs:   []OutputName{"palette"},
-		NewConfig: func() NodeConfig { return NewNodeConfigPaletteExtract() },
+		Inputs:      []InputName{"source"},
+		Outputs:     []OutputName{"palette"},
+		NewConfig:   func() NodeConfig { return NewNodeConfigPaletteExtract() },
+		OutputKinds: map[OutputName]ImageKind{"palette": ImageKindPalette},
+		// 2: k-means clustering weighted by how often each color occurs
+		Implementations: 2,
 	},
 	NodeTypePaletteApply: {
-		Inputs:    []InputName{"source", "palette"},
-		Outputs:   []OutputName{"mapped"},
-		NewConfig: func() NodeConfig { return NewNodeConfigPaletteApply() },
+		Inputs:        []InputName{"source", "palette"},
+		Outputs:       []OutputName{"mapped"},
+		NewConfig:     func() NodeConfig { return NewNodeConfigPaletteApply() },
+		InputKinds:    map[InputName]ImageKind{"palette": ImageKindPalette},
+		PreservesSize: true,
+		// 2: keeps the source alpha, matching colors before premultiplying
+		Implementations: 2,
 	},
 	NodeTypePaletteCreate: {
-		Outputs:   []OutputName{"palette"},
-		NewConfig: func() NodeConfig { return NewNodeConfigPaletteCreate() },
+		Outputs:     []OutputName{"palette"},
+		NewConfig:   func() NodeConfig { return NewNodeConfigPaletteCreate() },
+		OutputKinds: map[OutputName]ImageKind{"palette": ImageKindPalette},
 	},
 	NodeTypePaletteEdit: {
-		Inputs:    []InputName{"source"},
-		Outputs:   []OutputName{"palette"},
-		NewConfig: func() NodeConfig { return NewNodeConfigPaletteEdit() },
+		Inputs:      []InputName{"source"},
+		Outputs:     []OutputName{"palette"},
+		NewConfig:   func() NodeConfig { return NewNodeConfigPaletteEdit() },
+		InputKinds:  map[InputName]ImageKind{"source": ImageKindPalette},
+		OutputKinds: map[OutputName]ImageKind{"palette": ImageKindPalette},
+	},
+	NodeTypeGenerate: {
+		Inputs:         []InputName{"reference"},
+		OptionalInputs: []InputName{"reference"},
+		Outputs:        []OutputName{"generated"},
+		NewConfig:      func() NodeConfig { return NewNodeConfigGenerate() },
+	},
+	NodeTypeUpscale: {
+		Inputs:    []InputName{"original"},
+		Outputs:   []OutputName{"upscaled"},
+		NewConfig: func() NodeConfig { return NewNodeConfigUpscale() },
+	},
+	NodeTypeDiff: {
+		Inputs:        []InputName{"base", "compare"},
+		Outputs:       []OutputName{"diff"},
+		NewConfig:     func() NodeConfig { return NewNodeConfigDiff() },
+		MatchSize:     map[InputName]InputName{"compare": "base"},
+		PreservesSize: true,
+	},
+	NodeTypeAutoContrast: {
+		Inputs:        []InputName{"original"},
+		Outputs:       []OutputName{"adjusted"},
+		NewConfig:     func() NodeConfig { return NewNodeConfigAutoContrast() },
+		PreservesSize: true,
+	},
+	NodeTypeColorSpace: {
+		Inputs:        []InputName{"original"},
+		Outputs:       []OutputName{"converted"},
+		NewConfig:     func() NodeConfig { return NewNodeConfigColorSpace() },
+		PreservesSize: true,
+	},
+	NodeTypeContactSheet: {
+		Inputs:         []InputName{"image_1", "image_2"},
+		OptionalInputs: []InputName{"image_2"},
+		Outputs:        []OutputName{"sheet"},
+		NewConfig:      func() NodeConfig { return NewNodeConfigContactSheet() },
+		DynamicInputs:  "image",
+		MaxInputs:      64,
+	},
+	NodeTypeSwitch: {
+		Inputs:         []InputName{"input_1", "input_2"},
+		OptionalInputs: []InputName{"input_2"},
+		Outputs:        []OutputName{"output"},
+		NewConfig:      func() NodeConfig { return NewNodeConfigSwitch() },
+		DynamicInputs:  "input",
+		MaxInputs:      MaxSwitchInputs,
 	},
 }
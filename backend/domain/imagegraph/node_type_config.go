@@ -32,6 +32,26 @@ type NodeConfig interface {
 	Schema() []FieldSchema
 }
 
+// NodeConfigLinter is implemented by configs that can be checked against the
+// sizes of the images connected to their inputs. Lint returns warnings for
+// settings that are valid on their own but won't work as intended with those
+// images. Inputs without an image are omitted from the map.
+type NodeConfigLinter interface {
+	Lint(inputs map[InputName]ImageSize) []string
+}
+
+// NodeConfigSizer is implemented by configs of node types whose output is a
+// different size from their input. OutputSize returns the dimensions of the
+// primary output given the sizes of the images reaching the inputs, if they
+// can be worked out. Inputs without a known size are omitted from the map.
+type NodeConfigSizer interface {
+	OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool)
+}
+
+// maxResizeFactor is the largest scale factor a resize can apply before it
+// is flagged as a likely mistake
+const maxResizeFactor = 16
+
 // Shared options for interpolation fields
 var interpolationOptions = []string{
 	"NearestNeighbor",
@@ -42,7 +62,35 @@ var interpolationOptions = []string{
 	"Lanczos3",
 }
 
-var paletteExtractMethodOptions = []string{"oklab_clusters", "dominant_frequency"}
+// PaletteExtractMethod is the algorithm a PaletteExtract node finds its
+// colors with. Configs name methods through PaletteExtractMethodMapper.
+type PaletteExtractMethod int
+
+const (
+	// PaletteExtractOKLabClusters clusters colors with k-means in OKLab space
+	PaletteExtractOKLabClusters PaletteExtractMethod = iota + 1
+	// PaletteExtractDominantFrequency picks the most common distinct colors
+	PaletteExtractDominantFrequency
+	// PaletteExtractMedianCut splits the colors into boxes at their median
+	// along the widest RGB channel, which stays fast for big palettes
+	PaletteExtractMedianCut
+)
+
+var paletteExtractMethodOptions = []string{"oklab_clusters", "dominant_frequency", "median_cut"}
+
+var generateProviderOptions = []string{"openai", "stability", "comfyui"}
+
+var animationFormatOptions = []string{"apng", "gif"}
+
+var autoContrastModeOptions = []string{"stretch", "equalize"}
+
+var colorSpaceOptions = []string{"srgb", "linear_rgb", "grayscale", "grayscale_linear"}
+
+var upscaleModelOptions = []string{
+	"realesrgan-x4plus",
+	"realesrgan-x4plus-anime",
+	"realesr-general-x4v3",
+}
 
 func isValidHexColor(color string) bool {
 	if len(color) != 7 || color[0] != '#' {
@@ -84,23 +132,61 @@ func (c *NodeConfigInput) Schema() []FieldSchema {
 	return []FieldSchema{}
 }
 
-// NodeConfigOutput is the configuration for output nodes.
-type NodeConfigOutput struct{}
+// NodeConfigOutput is the configuration for output nodes. ExportName names
+// the node's final image in the graph's export manifest, defaulting to the
+// node's name when empty. AnimationFormat is the format animated final
+// images are assembled in, APNG when empty; still images are always PNGs.
+// StripMetadata drops the metadata carried from source images, such as
+// their filename, DPI and capture data, from the final image.
+type NodeConfigOutput struct {
+	ExportName      string `json:"export_name,omitempty"`
+	AnimationFormat string `json:"animation_format,omitempty"`
+	StripMetadata   bool   `json:"strip_metadata,omitempty"`
+}
+
+const maxExportNameLength = 100
 
 func NewNodeConfigOutput() *NodeConfigOutput {
 	return &NodeConfigOutput{}
 }
 
 func (c *NodeConfigOutput) Validate() error {
+	if c.AnimationFormat != "" && !slices.Contains(animationFormatOptions, c.AnimationFormat) {
+		return fmt.Errorf("animation_format must be one of: %v", animationFormatOptions)
+	}
+
+	if c.ExportName == "" {
+		return nil
+	}
+
+	if len(c.ExportName) > maxExportNameLength {
+		return fmt.Errorf("export_name must be at most %d characters", maxExportNameLength)
+	}
+
+	for _, r := range c.ExportName {
+		if !isExportNameRune(r) {
+			return fmt.Errorf("export_name may only contain letters, digits, '.', '-' and '_'")
+		}
+	}
+
 	return nil
 }
 
+func isExportNameRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+		(r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_'
+}
+
 func (c *NodeConfigOutput) NodeType() NodeType {
 	return NodeTypeOutput
 }
 
 func (c *NodeConfigOutput) Schema() []FieldSchema {
-	return []FieldSchema{}
+	return []FieldSchema{
+		{Name: "export_name", Type: FieldTypeString, Required: false},
+		{Name: "animation_format", Type: FieldTypeOption, Required: false, Options: animationFormatOptions, Default: "apng"},
+		{Name: "strip_metadata", Type: FieldTypeBool, Required: false},
+	}
 }
 
 // NodeConfigCrop is the configuration for crop nodes.
@@ -182,6 +268,55 @@ func (c *NodeConfigCrop) Validate() error {
 	return nil
 }
 
+func (c *NodeConfigCrop) Lint(inputs map[InputName]ImageSize) []string {
+	size, ok := inputs["original"]
+	if !ok {
+		return nil
+	}
+
+	var warnings []string
+
+	if c.Left != nil && *c.Left >= size.Width {
+		warnings = append(warnings, fmt.Sprintf("left %d is outside the %dpx wide image", *c.Left, size.Width))
+	}
+	if c.Right != nil && *c.Right > size.Width {
+		warnings = append(warnings, fmt.Sprintf("right %d is outside the %dpx wide image", *c.Right, size.Width))
+	}
+	if c.Top != nil && *c.Top >= size.Height {
+		warnings = append(warnings, fmt.Sprintf("top %d is outside the %dpx tall image", *c.Top, size.Height))
+	}
+	if c.Bottom != nil && *c.Bottom > size.Height {
+		warnings = append(warnings, fmt.Sprintf("bottom %d is outside the %dpx tall image", *c.Bottom, size.Height))
+	}
+
+	return warnings
+}
+
+// OutputSize fills in missing bounds from the image and clamps them to it,
+// as generation does
+func (c *NodeConfigCrop) OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool) {
+	size, ok := inputs["original"]
+	if !ok {
+		return ImageSize{}, false
+	}
+
+	left, right, top, bottom := 0, size.Width, 0, size.Height
+	if c.Left != nil {
+		left = max(*c.Left, 0)
+	}
+	if c.Right != nil {
+		right = min(*c.Right, size.Width)
+	}
+	if c.Top != nil {
+		top = max(*c.Top, 0)
+	}
+	if c.Bottom != nil {
+		bottom = min(*c.Bottom, size.Height)
+	}
+
+	return ImageSize{Width: max(right-left, 0), Height: max(bottom-top, 0)}, true
+}
+
 func (c *NodeConfigCrop) NodeType() NodeType {
 	return NodeTypeCrop
 }
@@ -197,9 +332,11 @@ func (c *NodeConfigCrop) Schema() []FieldSchema {
 	}
 }
 
-// NodeConfigBlur is the configuration for blur nodes.
+// NodeConfigBlur is the configuration for blur nodes. Linear blurs in linear
+// light rather than sRGB.
 type NodeConfigBlur struct {
-	Radius int `json:"radius"`
+	Radius int  `json:"radius"`
+	Linear bool `json:"linear,omitempty"`
 }
 
 func NewNodeConfigBlur() *NodeConfigBlur {
@@ -223,14 +360,17 @@ func (c *NodeConfigBlur) NodeType() NodeType {
 func (c *NodeConfigBlur) Schema() []FieldSchema {
 	return []FieldSchema{
 		{Name: "radius", Type: FieldTypeInt, Required: true, Default: 2},
+		{Name: "linear", Type: FieldTypeBool, Required: false},
 	}
 }
 
-// NodeConfigResize is the configuration for resize nodes.
+// NodeConfigResize is the configuration for resize nodes. Linear resizes in
+// linear light rather than sRGB, which keeps fine detail from darkening.
 type NodeConfigResize struct {
 	Width         *int   `json:"width,omitempty"`
 	Height        *int   `json:"height,omitempty"`
 	Interpolation string `json:"interpolation"`
+	Linear        bool   `json:"linear,omitempty"`
 }
 
 func NewNodeConfigResize() *NodeConfigResize {
@@ -270,6 +410,56 @@ func (c *NodeConfigResize) Validate() error {
 	return nil
 }
 
+func (c *NodeConfigResize) Lint(inputs map[InputName]ImageSize) []string {
+	size, ok := inputs["original"]
+	if !ok || size.Width == 0 || size.Height == 0 {
+		return nil
+	}
+
+	var warnings []string
+
+	checkFactor := func(field string, target, source int) {
+		factor := float64(target) / float64(source)
+		if factor > maxResizeFactor || factor < 1.0/maxResizeFactor {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s %d scales the %dpx source by %.3gx, outside 1/%dx to %dx",
+				field, target, source, factor, maxResizeFactor, maxResizeFactor,
+			))
+		}
+	}
+
+	if c.Width != nil {
+		checkFactor("width", *c.Width, size.Width)
+	}
+	if c.Height != nil {
+		checkFactor("height", *c.Height, size.Height)
+	}
+
+	return warnings
+}
+
+// OutputSize keeps the aspect ratio of the image when only one of width or
+// height is set
+func (c *NodeConfigResize) OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool) {
+	if c.Width != nil && c.Height != nil {
+		return ImageSize{Width: *c.Width, Height: *c.Height}, true
+	}
+
+	size, ok := inputs["original"]
+	if !ok || size.Width == 0 || size.Height == 0 {
+		return ImageSize{}, false
+	}
+
+	switch {
+	case c.Width != nil:
+		return ImageSize{Width: *c.Width, Height: *c.Width * size.Height / size.Width}, true
+	case c.Height != nil:
+		return ImageSize{Width: *c.Height * size.Width / size.Height, Height: *c.Height}, true
+	default:
+		return ImageSize{}, false
+	}
+}
+
 func (c *NodeConfigResize) NodeType() NodeType {
 	return NodeTypeResize
 }
@@ -279,12 +469,15 @@ func (c *NodeConfigResize) Schema() []FieldSchema {
 		{Name: "width", Type: FieldTypeInt, Required: false},
 		{Name: "height", Type: FieldTypeInt, Required: false},
 		{Name: "interpolation", Type: FieldTypeOption, Required: true, Options: interpolationOptions},
+		{Name: "linear", Type: FieldTypeBool, Required: false},
 	}
 }
 
-// NodeConfigResizeMatch is the configuration for resize-match nodes.
+// NodeConfigResizeMatch is the configuration for resize-match nodes. Linear
+// resizes in linear light rather than sRGB.
 type NodeConfigResizeMatch struct {
 	Interpolation string `json:"interpolation"`
+	Linear        bool   `json:"linear,omitempty"`
 }
 
 func NewNodeConfigResizeMatch() *NodeConfigResizeMatch {
@@ -298,6 +491,11 @@ func (c *NodeConfigResizeMatch) Validate() error {
 	return nil
 }
 
+func (c *NodeConfigResizeMatch) OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool) {
+	size, ok := inputs["size_match"]
+	return size, ok
+}
+
 func (c *NodeConfigResizeMatch) NodeType() NodeType {
 	return NodeTypeResizeMatch
 }
@@ -305,6 +503,7 @@ func (c *NodeConfigResizeMatch) NodeType() NodeType {
 func (c *NodeConfigResizeMatch) Schema() []FieldSchema {
 	return []FieldSchema{
 		{Name: "interpolation", Type: FieldTypeOption, Required: true, Options: interpolationOptions},
+		{Name: "linear", Type: FieldTypeBool, Required: false},
 	}
 }
 
@@ -341,6 +540,30 @@ func (c *NodeConfigPixelInflate) Validate() error {
 	return nil
 }
 
+func (c *NodeConfigPixelInflate) Lint(inputs map[InputName]ImageSize) []string {
+	size, ok := inputs["original"]
+	if !ok {
+		return nil
+	}
+
+	if c.Width < size.Width {
+		return []string{fmt.Sprintf(
+			"width %d is smaller than the %dpx source, pixels will be dropped",
+			c.Width, size.Width,
+		)}
+	}
+
+	return nil
+}
+
+func (c *NodeConfigPixelInflate) OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool) {
+	size, ok := inputs["original"]
+	if !ok || size.Width == 0 {
+		return ImageSize{}, false
+	}
+	return ImageSize{Width: c.Width, Height: c.Width * size.Height / size.Width}, true
+}
+
 func (c *NodeConfigPixelInflate) NodeType() NodeType {
 	return NodeTypePixelInflate
 }
@@ -354,15 +577,25 @@ func (c *NodeConfigPixelInflate) Schema() []FieldSchema {
 }
 
 // NodeConfigPaletteExtract is the configuration for palette-extract nodes.
+// MaxSamples bounds how many pixels colors are gathered from, sampled evenly
+// across larger images; 0 gathers colors from every pixel, as nodes created
+// before sampling did.
 type NodeConfigPaletteExtract struct {
-	NumColors int    `json:"num_colors"`
-	Method    string `json:"method"`
+	NumColors  int    `json:"num_colors"`
+	Method     string `json:"method"`
+	MaxSamples int    `json:"max_samples,omitempty"`
+	Seed       *int   `json:"seed,omitempty"`
 }
 
+// DefaultPaletteMaxSamples keeps palette extraction fast on photographs
+// while sampling enough pixels to find the same colors
+const DefaultPaletteMaxSamples = 100000
+
 func NewNodeConfigPaletteExtract() *NodeConfigPaletteExtract {
 	return &NodeConfigPaletteExtract{
-		NumColors: 16,
-		Method:    "oklab_clusters",
+		NumColors:  16,
+		Method:     "oklab_clusters",
+		MaxSamples: DefaultPaletteMaxSamples,
 	}
 }
 
@@ -378,11 +611,23 @@ func (c *NodeConfigPaletteExtract) Validate() error {
 		c.Method = "oklab_clusters"
 	}
 
-	if !slices.Contains(paletteExtractMethodOptions, c.Method) {
+	if _, err := PaletteExtractMethodMapper.To(c.Method); err != nil {
 		return fmt.Errorf("method must be one of: %v", paletteExtractMethodOptions)
 	}
 
-	return nil
+	if c.MaxSamples < 0 {
+		return fmt.Errorf("max_samples must be 0 or more")
+	}
+	if c.MaxSamples > 0 && c.MaxSamples < c.NumColors {
+		return fmt.Errorf("max_samples must be at least num_colors")
+	}
+
+	return ValidateSeed(c.Seed)
+}
+
+// ExtractMethod is the algorithm the config's method names
+func (c *NodeConfigPaletteExtract) ExtractMethod() PaletteExtractMethod {
+	return PaletteExtractMethodMapper.ToWithDefault(c.Method, PaletteExtractOKLabClusters)
 }
 
 func (c *NodeConfigPaletteExtract) NodeType() NodeType {
@@ -393,16 +638,37 @@ func (c *NodeConfigPaletteExtract) Schema() []FieldSchema {
 	return []FieldSchema{
 		{Name: "num_colors", Type: FieldTypeInt, Required: true, Default: 16},
 		{Name: "method", Type: FieldTypeOption, Required: true, Options: paletteExtractMethodOptions, Default: "oklab_clusters"},
+		{Name: "max_samples", Type: FieldTypeInt, Required: false, Default: DefaultPaletteMaxSamples},
+		{Name: "seed", Type: FieldTypeInt, Required: false},
 	}
 }
 
+func (c *NodeConfigPaletteExtract) ConfigSeed() *int {
+	return c.Seed
+}
+
+func (c *NodeConfigPaletteExtract) DefaultSeed() (int, bool) {
+	return DefaultPaletteExtractSeed, true
+}
+
 // NodeConfigPaletteApply is the configuration for palette-apply nodes.
+// Distance is the color space pixels are matched to their nearest palette
+// color in; OKLab matches colors as they look rather than by their values.
+//
+// Nodes on implementation 2 and later keep the source alpha: KeepTransparent
+// copies pixels that aren't fully opaque from the source rather than mapping
+// them, and an AlphaThreshold above 0 makes the output fully opaque where
+// the source alpha reaches it and fully transparent elsewhere, as sprites
+// need.
 type NodeConfigPaletteApply struct {
-	Normalize string `json:"normalize"`
+	Normalize       string `json:"normalize"`
+	Distance        string `json:"distance,omitempty"`
+	KeepTransparent bool   `json:"keep_transparent,omitempty"`
+	AlphaThreshold  int    `json:"alpha_threshold,omitempty"`
 }
 
 func NewNodeConfigPaletteApply() *NodeConfigPaletteApply {
-	return &NodeConfigPaletteApply{Normalize: "none"}
+	return &NodeConfigPaletteApply{Normalize: "none", Distance: "rgb"}
 }
 
 func (c *NodeConfigPaletteApply) Validate() error {
@@ -412,6 +678,15 @@ func (c *NodeConfigPaletteApply) Validate() error {
 	if !slices.Contains([]string{"none", "lightness"}, c.Normalize) {
 		return fmt.Errorf("normalize must be one of: none, lightness")
 	}
+	if c.Distance == "" {
+		c.Distance = "rgb"
+	}
+	if !slices.Contains([]string{"rgb", "oklab"}, c.Distance) {
+		return fmt.Errorf("distance must be one of: rgb, oklab")
+	}
+	if c.AlphaThreshold < 0 || c.AlphaThreshold > 255 {
+		return fmt.Errorf("alpha_threshold must be between 0 and 255")
+	}
 	return nil
 }
 
@@ -422,6 +697,9 @@ func (c *NodeConfigPaletteApply) NodeType() NodeType {
 func (c *NodeConfigPaletteApply) Schema() []FieldSchema {
 	return []FieldSchema{
 		{Name: "normalize", Type: FieldTypeOption, Required: false, Options: []string{"none", "lightness"}, Default: "none"},
+		{Name: "distance", Type: FieldTypeOption, Required: false, Options: []string{"rgb", "oklab"}, Default: "rgb"},
+		{Name: "keep_transparent", Type: FieldTypeBool, Required: false},
+		{Name: "alpha_threshold", Type: FieldTypeInt, Required: false, Default: 0},
 	}
 }
 
@@ -533,3 +811,373 @@ func (c *NodeConfigPaletteEdit) ColorsList() ([]string, error) {
 func (c *NodeConfigPaletteEdit) ColorsRawList() ([]string, error) {
 	return parseColorsList(c.Colors)
 }
+
+// NodeConfigGenerate is the configuration for generate nodes, which produce
+// an image from a prompt using an external image generation service.
+type NodeConfigGenerate struct {
+	Provider       string  `json:"provider"`
+	Prompt         string  `json:"prompt"`
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	Model          string  `json:"model,omitempty"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	Seed           *int    `json:"seed,omitempty"`
+	Strength       float64 `json:"strength"`
+}
+
+func NewNodeConfigGenerate() *NodeConfigGenerate {
+	return &NodeConfigGenerate{
+		Provider: "openai",
+		Width:    1024,
+		Height:   1024,
+		Strength: 0.6,
+	}
+}
+
+func (c *NodeConfigGenerate) Validate() error {
+	if !slices.Contains(generateProviderOptions, c.Provider) {
+		return fmt.Errorf("provider must be one of: %v", generateProviderOptions)
+	}
+
+	if strings.TrimSpace(c.Prompt) == "" {
+		return fmt.Errorf("prompt must be set")
+	}
+	if len(c.Prompt) > 4000 {
+		return fmt.Errorf("prompt must be 4000 characters or less")
+	}
+
+	if c.Width < 64 || c.Width > 2048 {
+		return fmt.Errorf("width must be between 64 and 2048")
+	}
+	if c.Height < 64 || c.Height > 2048 {
+		return fmt.Errorf("height must be between 64 and 2048")
+	}
+
+	if err := ValidateSeed(c.Seed); err != nil {
+		return err
+	}
+
+	if c.Strength < 0 || c.Strength > 1 {
+		return fmt.Errorf("strength must be between 0 and 1")
+	}
+
+	return nil
+}
+
+func (c *NodeConfigGenerate) OutputSize(map[InputName]ImageSize) (ImageSize, bool) {
+	return ImageSize{Width: c.Width, Height: c.Height}, true
+}
+
+func (c *NodeConfigGenerate) NodeType() NodeType {
+	return NodeTypeGenerate
+}
+
+func (c *NodeConfigGenerate) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "provider", Type: FieldTypeOption, Required: true, Options: generateProviderOptions, Default: "openai"},
+		{Name: "prompt", Type: FieldTypeString, Required: true},
+		{Name: "negative_prompt", Type: FieldTypeString, Required: false},
+		{Name: "model", Type: FieldTypeString, Required: false},
+		{Name: "width", Type: FieldTypeInt, Required: true, Default: 1024},
+		{Name: "height", Type: FieldTypeInt, Required: true, Default: 1024},
+		{Name: "seed", Type: FieldTypeInt, Required: false},
+		{Name: "strength", Type: FieldTypeFloat, Required: false, Default: 0.6},
+	}
+}
+
+func (c *NodeConfigGenerate) ConfigSeed() *int {
+	return c.Seed
+}
+
+// DefaultSeed is unset for Generate nodes, which leave drawing a seed to
+// the provider
+func (c *NodeConfigGenerate) DefaultSeed() (int, bool) {
+	return 0, false
+}
+
+// NodeConfigUpscale is the configuration for upscale nodes, which enlarge an
+// image using an ESRGAN-style super-resolution model.
+type NodeConfigUpscale struct {
+	Scale int    `json:"scale"`
+	Model string `json:"model"`
+}
+
+func NewNodeConfigUpscale() *NodeConfigUpscale {
+	return &NodeConfigUpscale{
+		Scale: 4,
+		Model: "realesrgan-x4plus",
+	}
+}
+
+func (c *NodeConfigUpscale) Validate() error {
+	if c.Scale != 2 && c.Scale != 4 {
+		return fmt.Errorf("scale must be 2 or 4")
+	}
+
+	if !slices.Contains(upscaleModelOptions, c.Model) {
+		return fmt.Errorf("model must be one of: %v", upscaleModelOptions)
+	}
+
+	return nil
+}
+
+func (c *NodeConfigUpscale) OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool) {
+	size, ok := inputs["original"]
+	if !ok {
+		return ImageSize{}, false
+	}
+	return ImageSize{Width: size.Width * c.Scale, Height: size.Height * c.Scale}, true
+}
+
+func (c *NodeConfigUpscale) NodeType() NodeType {
+	return NodeTypeUpscale
+}
+
+func (c *NodeConfigUpscale) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "scale", Type: FieldTypeInt, Required: true, Default: 4},
+		{Name: "model", Type: FieldTypeOption, Required: true, Options: upscaleModelOptions, Default: "realesrgan-x4plus"},
+	}
+}
+
+// NodeConfigDiff is the configuration for diff nodes, which render a heat map
+// of the per-pixel difference between two images. Amplify scales the
+// differences so that subtle ones stand out.
+type NodeConfigDiff struct {
+	Amplify int `json:"amplify"`
+}
+
+func NewNodeConfigDiff() *NodeConfigDiff {
+	return &NodeConfigDiff{Amplify: 1}
+}
+
+func (c *NodeConfigDiff) Validate() error {
+	if c.Amplify < 1 {
+		return fmt.Errorf("amplify must be at least 1")
+	}
+	if c.Amplify > 100 {
+		return fmt.Errorf("amplify must be 100 or less")
+	}
+	return nil
+}
+
+func (c *NodeConfigDiff) Lint(inputs map[InputName]ImageSize) []string {
+	base, ok := inputs["base"]
+	if !ok {
+		return nil
+	}
+	compare, ok := inputs["compare"]
+	if !ok || compare == base {
+		return nil
+	}
+
+	return []string{fmt.Sprintf(
+		"compare image is %dx%d but base is %dx%d, compare will be resized to match",
+		compare.Width, compare.Height, base.Width, base.Height,
+	)}
+}
+
+func (c *NodeConfigDiff) NodeType() NodeType {
+	return NodeTypeDiff
+}
+
+func (c *NodeConfigDiff) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "amplify", Type: FieldTypeInt, Required: true, Default: 1},
+	}
+}
+
+// NodeConfigAutoContrast is the configuration for auto-contrast nodes. The
+// stretch mode rescales each channel to the full range after clipping
+// ClipPercent of its darkest and brightest values; the equalize mode
+// flattens each channel's histogram and ignores ClipPercent.
+type NodeConfigAutoContrast struct {
+	Mode        string  `json:"mode"`
+	ClipPercent float64 `json:"clip_percent"`
+}
+
+func NewNodeConfigAutoContrast() *NodeConfigAutoContrast {
+	return &NodeConfigAutoContrast{Mode: "stretch", ClipPercent: 0.5}
+}
+
+func (c *NodeConfigAutoContrast) Validate() error {
+	if !slices.Contains(autoContrastModeOptions, c.Mode) {
+		return fmt.Errorf("mode must be one of: %v", autoContrastModeOptions)
+	}
+
+	if c.ClipPercent < 0 || c.ClipPercent >= 50 {
+		return fmt.Errorf("clip_percent must be at least 0 and less than 50")
+	}
+
+	return nil
+}
+
+func (c *NodeConfigAutoContrast) NodeType() NodeType {
+	return NodeTypeAutoContrast
+}
+
+func (c *NodeConfigAutoContrast) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "mode", Type: FieldTypeOption, Required: true, Options: autoContrastModeOptions, Default: "stretch"},
+		{Name: "clip_percent", Type: FieldTypeFloat, Required: true, Default: 0.5},
+	}
+}
+
+// NodeConfigColorSpace is the configuration for color space nodes, which
+// reinterpret an image encoded in the From color space and encode it in the
+// To color space. Grayscale uses the luminance of the linear colors, gamma
+// encoded like sRGB unless it is grayscale_linear.
+type NodeConfigColorSpace struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func NewNodeConfigColorSpace() *NodeConfigColorSpace {
+	return &NodeConfigColorSpace{From: "srgb", To: "linear_rgb"}
+}
+
+func (c *NodeConfigColorSpace) Validate() error {
+	if !slices.Contains(colorSpaceOptions, c.From) {
+		return fmt.Errorf("from must be one of: %v", colorSpaceOptions)
+	}
+
+	if !slices.Contains(colorSpaceOptions, c.To) {
+		return fmt.Errorf("to must be one of: %v", colorSpaceOptions)
+	}
+
+	return nil
+}
+
+func (c *NodeConfigColorSpace) NodeType() NodeType {
+	return NodeTypeColorSpace
+}
+
+func (c *NodeConfigColorSpace) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "from", Type: FieldTypeOption, Required: true, Options: colorSpaceOptions, Default: "srgb"},
+		{Name: "to", Type: FieldTypeOption, Required: true, Options: colorSpaceOptions, Default: "linear_rgb"},
+	}
+}
+
+// NodeConfigContactSheet is the configuration for contact sheet nodes, which
+// arrange the images of their connected inputs in a grid of Columns columns.
+// Each image is scaled to fit a CellSize square, Spacing apart on a
+// Background colored sheet, and labelled with the name of the node it came
+// from when Labels is set.
+type NodeConfigContactSheet struct {
+	Columns    int    `json:"columns"`
+	CellSize   int    `json:"cell_size"`
+	Spacing    int    `json:"spacing"`
+	Labels     bool   `json:"labels"`
+	Background string `json:"background"`
+}
+
+func NewNodeConfigContactSheet() *NodeConfigContactSheet {
+	return &NodeConfigContactSheet{
+		Columns:    4,
+		CellSize:   256,
+		Spacing:    8,
+		Labels:     true,
+		Background: "#FFFFFF",
+	}
+}
+
+func (c *NodeConfigContactSheet) Validate() error {
+	if c.Columns < 1 {
+		return fmt.Errorf("columns must be at least 1")
+	}
+	if c.Columns > 32 {
+		return fmt.Errorf("columns must be 32 or less")
+	}
+
+	if c.CellSize < 16 {
+		return fmt.Errorf("cell_size must be at least 16")
+	}
+	if c.CellSize > 2048 {
+		return fmt.Errorf("cell_size must be 2048 or less")
+	}
+
+	if c.Spacing < 0 {
+		return fmt.Errorf("spacing must be at least 0")
+	}
+	if c.Spacing > 256 {
+		return fmt.Errorf("spacing must be 256 or less")
+	}
+
+	if !isValidHexColor(c.Background) {
+		return fmt.Errorf("background must be in #RRGGBB format")
+	}
+
+	return nil
+}
+
+func (c *NodeConfigContactSheet) NodeType() NodeType {
+	return NodeTypeContactSheet
+}
+
+func (c *NodeConfigContactSheet) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "columns", Type: FieldTypeInt, Required: true, Default: 4},
+		{Name: "cell_size", Type: FieldTypeInt, Required: true, Default: 256},
+		{Name: "spacing", Type: FieldTypeInt, Required: true, Default: 8},
+		{Name: "labels", Type: FieldTypeBool, Required: false, Default: true},
+		{Name: "background", Type: FieldTypeColor, Required: true, Default: "#FFFFFF"},
+	}
+}
+
+// MaxSwitchInputs is the most inputs a switch node can have
+const MaxSwitchInputs = 16
+
+// NodeConfigSwitch is the configuration for switch nodes, which forward the
+// image on input Selected, e.g. 2 for "input_2", to their output as it is
+type NodeConfigSwitch struct {
+	Selected int `json:"selected"`
+}
+
+func NewNodeConfigSwitch() *NodeConfigSwitch {
+	return &NodeConfigSwitch{
+		Selected: 1,
+	}
+}
+
+func (c *NodeConfigSwitch) Validate() error {
+	if c.Selected < 1 {
+		return fmt.Errorf("selected must be at least 1")
+	}
+	if c.Selected > MaxSwitchInputs {
+		return fmt.Errorf("selected must be %d or less", MaxSwitchInputs)
+	}
+
+	return nil
+}
+
+// SelectedInput is the name of the input the switch forwards
+func (c *NodeConfigSwitch) SelectedInput() InputName {
+	return NodeTypeDefs[NodeTypeSwitch].DynamicInputName(c.Selected)
+}
+
+func (c *NodeConfigSwitch) Lint(inputs map[InputName]ImageSize) []string {
+	if len(inputs) == 0 {
+		return nil
+	}
+	if _, ok := inputs[c.SelectedInput()]; ok {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s is selected but has no image", c.SelectedInput())}
+}
+
+func (c *NodeConfigSwitch) OutputSize(inputs map[InputName]ImageSize) (ImageSize, bool) {
+	size, ok := inputs[c.SelectedInput()]
+	return size, ok
+}
+
+func (c *NodeConfigSwitch) NodeType() NodeType {
+	return NodeTypeSwitch
+}
+
+func (c *NodeConfigSwitch) Schema() []FieldSchema {
+	return []FieldSchema{
+		{Name: "selected", Type: FieldTypeInt, Required: true, Default: 1},
+	}
+}
@@ -0,0 +1,229 @@
+package imagegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+
+	"github.com/dmpettyp/dorky/mapper"
+)
+
+// firstRegisteredNodeType is the NodeType given to the first node type
+// registered at startup, leaving room for more built-in types below it.
+// NodeTypes are only used in memory, graphs store node types by name.
+const firstRegisteredNodeType NodeType = 1000
+
+var nextRegisteredNodeType = firstRegisteredNodeType
+
+var nodeTypeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// RegisterNodeType adds a node type declared outside the code, such as in a
+// registry file, whose nodes are configured with the given fields through a
+// NodeConfigDeclared. The definition's NewConfig is set by the registration.
+// Node types must be registered at startup, before any graph is loaded.
+func RegisterNodeType(name string, def NodeTypeDef, fields []FieldSchema) (NodeType, error) {
+	if !nodeTypeNamePattern.MatchString(name) {
+		return NodeTypeNone, fmt.Errorf("node type name %q must be lowercase letters, digits and '_', starting with a letter", name)
+	}
+	if _, err := NodeTypeMapper.To(name); err == nil || name == "unknown" {
+		return NodeTypeNone, fmt.Errorf("node type %q already exists", name)
+	}
+
+	if err := validateDeclaredNodeTypeDef(def); err != nil {
+		return NodeTypeNone, fmt.Errorf("node type %q: %w", name, err)
+	}
+	if err := validateDeclaredFields(fields); err != nil {
+		return NodeTypeNone, fmt.Errorf("node type %q: %w", name, err)
+	}
+
+	nodeType := nextRegisteredNodeType
+	nextRegisteredNodeType++
+
+	fields = slices.Clone(fields)
+	def.NewConfig = func() NodeConfig { return newNodeConfigDeclared(nodeType, fields) }
+	NodeTypeDefs[nodeType] = def
+
+	nodeTypeNames = append(nodeTypeNames, name, nodeType)
+	NodeTypeMapper = mapper.MustNew[string, NodeType](nodeTypeNames...)
+
+	return nodeType, nil
+}
+
+// IsRegisteredNodeType reports whether a node type was registered at
+// startup rather than built in
+func IsRegisteredNodeType(nodeType NodeType) bool {
+	return nodeType >= firstRegisteredNodeType
+}
+
+func validateDeclaredNodeTypeDef(def NodeTypeDef) error {
+	if len(def.Outputs) == 0 {
+		return fmt.Errorf("at least one output is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range def.Inputs {
+		if !nodeTypeNamePattern.MatchString(string(name)) || seen[string(name)] {
+			return fmt.Errorf("input %q must be a unique lowercase name", name)
+		}
+		seen[string(name)] = true
+	}
+	for _, name := range def.OptionalInputs {
+		if !slices.Contains(def.Inputs, name) {
+			return fmt.Errorf("optional input %q is not an input", name)
+		}
+	}
+
+	clear(seen)
+	for _, name := range def.Outputs {
+		if !nodeTypeNamePattern.MatchString(string(name)) || seen[string(name)] {
+			return fmt.Errorf("output %q must be a unique lowercase name", name)
+		}
+		seen[string(name)] = true
+	}
+
+	return nil
+}
+
+func validateDeclaredFields(fields []FieldSchema) error {
+	seen := make(map[string]bool)
+
+	for _, field := range fields {
+		if !nodeTypeNamePattern.MatchString(field.Name) || seen[field.Name] {
+			return fmt.Errorf("config field %q must be a unique lowercase name", field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case FieldTypeInt, FieldTypeFloat, FieldTypeString, FieldTypeBool, FieldTypeColor:
+		case FieldTypeOption:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("option field %s must list its options", field.Name)
+			}
+		default:
+			return fmt.Errorf("config field %s has unknown type %q", field.Name, field.Type)
+		}
+
+		if field.Default != nil {
+			if err := validateDeclaredValue(field, field.Default); err != nil {
+				return fmt.Errorf("default of %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// NodeConfigDeclared is the configuration of registered node types, whose
+// fields are described by a schema rather than a struct. It marshals to a
+// JSON object of the values of its fields, which start at their defaults.
+type NodeConfigDeclared struct {
+	nodeType NodeType
+	fields   []FieldSchema
+	Values   map[string]any
+}
+
+func newNodeConfigDeclared(nodeType NodeType, fields []FieldSchema) *NodeConfigDeclared {
+	values := make(map[string]any)
+	for _, field := range fields {
+		if field.Default != nil {
+			values[field.Name] = field.Default
+		}
+	}
+
+	return &NodeConfigDeclared{
+		nodeType: nodeType,
+		fields:   fields,
+		Values:   values,
+	}
+}
+
+func (c *NodeConfigDeclared) NodeType() NodeType {
+	return c.nodeType
+}
+
+func (c *NodeConfigDeclared) Schema() []FieldSchema {
+	return c.fields
+}
+
+func (c *NodeConfigDeclared) Validate() error {
+	for _, name := range slices.Sorted(maps.Keys(c.Values)) {
+		if !slices.ContainsFunc(c.fields, func(f FieldSchema) bool { return f.Name == name }) {
+			return fmt.Errorf("%s is not a config field", name)
+		}
+	}
+
+	for _, field := range c.fields {
+		value, ok := c.Values[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				return fmt.Errorf("%s is required", field.Name)
+			}
+			continue
+		}
+
+		if err := validateDeclaredValue(field, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateDeclaredValue checks a value, as decoded from JSON, against the
+// type of its field
+func validateDeclaredValue(field FieldSchema, value any) error {
+	switch field.Type {
+	case FieldTypeInt:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return fmt.Errorf("%s must be an integer", field.Name)
+		}
+	case FieldTypeFloat:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s must be a number", field.Name)
+		}
+	case FieldTypeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", field.Name)
+		}
+	case FieldTypeString:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s must be a string", field.Name)
+		}
+	case FieldTypeOption:
+		option, ok := value.(string)
+		if !ok || !slices.Contains(field.Options, option) {
+			return fmt.Errorf("%s must be one of: %v", field.Name, field.Options)
+		}
+	case FieldTypeColor:
+		color, ok := value.(string)
+		if !ok || !isValidHexColor(color) {
+			return fmt.Errorf("%s must be a hex color like #RRGGBB", field.Name)
+		}
+	}
+
+	return nil
+}
+
+func (c *NodeConfigDeclared) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Values)
+}
+
+// UnmarshalJSON sets the fields present in the JSON object, leaving the
+// others as they were, as for the configs of built-in node types
+func (c *NodeConfigDeclared) UnmarshalJSON(data []byte) error {
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	if c.Values == nil {
+		c.Values = make(map[string]any, len(values))
+	}
+	maps.Copy(c.Values, values)
+
+	return nil
+}
@@ -39,6 +39,16 @@ func (nodes Nodes) Get(id NodeID) (*Node, bool) {
 	return node, ok
 }
 
+// FindByExternalID returns the node with the given external ID
+func (nodes Nodes) FindByExternalID(externalID string) (*Node, bool) {
+	for _, node := range nodes {
+		if node.ExternalID == externalID {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
 func (nodes Nodes) WithNode(id NodeID, f func(*Node) error) error {
 	if f == nil {
 		return fmt.Errorf(
@@ -1,21 +1,42 @@
 package imagegraph
 
 import (
+	"errors"
 	"fmt"
 	"maps"
 	"slices"
+	"time"
 )
 
 type OutputName string
 
+// OutputHistorySize is the number of replaced images an output keeps, so
+// earlier results can be compared with the current one and promoted back
+const OutputHistorySize = 5
+
+// ErrOutputVariantNotFound is returned when promoting an image that isn't in
+// an output's history
+var ErrOutputVariantNotFound = errors.New("output variant not found")
+
+// OutputVariant is an image an output held before it was replaced
+type OutputVariant struct {
+	ImageID     ImageID
+	GeneratedAt time.Time
+	ReplacedAt  time.Time
+}
+
 type OutputConnection struct {
 	NodeID    NodeID
 	InputName InputName
 }
 
 type Output struct {
-	Name        OutputName
-	ImageID     ImageID
+	Name    OutputName
+	ImageID ImageID
+	// When the current image was set on the output
+	GeneratedAt time.Time
+	// The images the output held before, most recently replaced first
+	History     []OutputVariant
 	Connections map[OutputConnection]struct{}
 }
 
@@ -77,12 +98,60 @@ func (o *Output) Disconnect(nodeID NodeID, inputName InputName) error {
 	return nil
 }
 
-func (o *Output) SetImage(imageID ImageID) {
+func (o *Output) SetImage(imageID ImageID, generatedAt time.Time) {
 	o.ImageID = imageID
+	o.GeneratedAt = generatedAt
 }
 
 func (o *Output) ResetImage() {
 	o.ImageID = ImageID{}
+	o.GeneratedAt = time.Time{}
+}
+
+// retireImage moves the current image into the output's history, returning
+// the images that no longer fit in it
+func (o *Output) retireImage(replacedAt time.Time) []ImageID {
+	if o.ImageID.IsNil() {
+		return nil
+	}
+
+	o.History = slices.Insert(o.History, 0, OutputVariant{
+		ImageID:     o.ImageID,
+		GeneratedAt: o.GeneratedAt,
+		ReplacedAt:  replacedAt,
+	})
+	o.ResetImage()
+
+	if len(o.History) <= OutputHistorySize {
+		return nil
+	}
+
+	var dropped []ImageID
+	for _, variant := range o.History[OutputHistorySize:] {
+		dropped = append(dropped, variant.ImageID)
+	}
+	o.History = slices.Clip(o.History[:OutputHistorySize])
+
+	return dropped
+}
+
+// takeVariant removes an image from the output's history
+func (o *Output) takeVariant(imageID ImageID) (OutputVariant, error) {
+	i := slices.IndexFunc(o.History, func(variant OutputVariant) bool {
+		return variant.ImageID == imageID
+	})
+
+	if i < 0 {
+		return OutputVariant{}, fmt.Errorf(
+			"%w: output %q has no previous image %q",
+			ErrOutputVariantNotFound, o.Name, imageID,
+		)
+	}
+
+	variant := o.History[i]
+	o.History = slices.Delete(o.History, i, i+1)
+
+	return variant, nil
 }
 
 func (o *Output) HasImage() bool {
@@ -150,41 +219,34 @@ func (outputs Outputs) GetImage(name OutputName) (ImageID, error) {
 	return output.ImageID, nil
 }
 
+// SetImage sets the image of an output, moving the image it replaces into the
+// output's history. It returns the images that no longer fit in the history.
 func (outputs Outputs) SetImage(
 	outputName OutputName,
 	imageID ImageID,
-) error {
+	generatedAt time.Time,
+) (
+	[]ImageID,
+	error,
+) {
 	if imageID.IsNil() {
-		return fmt.Errorf("cannot set output %q to nil", outputName)
+		return nil, fmt.Errorf("cannot set output %q to nil", outputName)
 	}
 
 	output, ok := outputs[outputName]
 
 	if !ok {
-		return fmt.Errorf("no output named %q exists", outputName)
-	}
-
-	output.SetImage(imageID)
-
-	return nil
-}
-
-func (outputs Outputs) UnsetImage(outputName OutputName) (ImageID, error) {
-	output, ok := outputs[outputName]
-
-	if !ok {
-		return ImageID{}, fmt.Errorf("no output named %q exists", outputName)
+		return nil, fmt.Errorf("no output named %q exists", outputName)
 	}
 
-	if output.ImageID.IsNil() {
-		return ImageID{}, nil
+	var dropped []ImageID
+	if output.ImageID != imageID {
+		dropped = output.retireImage(generatedAt)
 	}
 
-	oldImageID := output.ImageID
-
-	output.ResetImage()
+	output.SetImage(imageID, generatedAt)
 
-	return oldImageID, nil
+	return dropped, nil
 }
 
 func (outputs Outputs) Connections(
@@ -247,3 +309,18 @@ func (outputs Outputs) AllSet() bool {
 
 	return true
 }
+
+// clone copies the outputs, with their history and connections, so that
+// changes to the copies leave them unchanged
+func (outputs Outputs) clone() Outputs {
+	clone := make(Outputs, len(outputs))
+
+	for name, output := range outputs {
+		c := *output
+		c.History = slices.Clone(output.History)
+		c.Connections = maps.Clone(output.Connections)
+		clone[name] = &c
+	}
+
+	return clone
+}
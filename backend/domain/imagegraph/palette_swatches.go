@@ -0,0 +1,188 @@
+package imagegraph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrNotPaletteEdit is returned when editing the swatches of a node that
+// isn't a PaletteEdit node
+var ErrNotPaletteEdit = errors.New("node is not a palette edit node")
+
+// ErrSwatchNotFound is returned when editing a swatch the palette doesn't
+// have
+var ErrSwatchNotFound = errors.New("swatch not found")
+
+// ErrSwatchExists is returned when adding a color the palette already has
+var ErrSwatchExists = errors.New("palette already has the color")
+
+// ErrInvalidSwatchOrder is returned when reordering swatches with a list
+// that isn't the palette's colors in a new order
+var ErrInvalidSwatchOrder = errors.New("order must list each swatch color once")
+
+// PaletteSwatch is a color of a PaletteEdit node's palette. Disabled
+// swatches are left out of the node's output.
+type PaletteSwatch struct {
+	Color   string
+	Enabled bool
+}
+
+// ParseSwatchColor normalizes a color written as #rrggbb or rrggbb, in
+// either case, to lowercase #rrggbb as palette edit nodes generate them
+func ParseSwatchColor(color string) (string, error) {
+	normalized := "#" + strings.ToLower(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if !isValidHexColor(normalized) {
+		return "", fmt.Errorf("color %q must be in #RRGGBB format", color)
+	}
+	return normalized, nil
+}
+
+// Swatches returns the palette's colors in order, parsed from Colors, where
+// disabled colors are prefixed with "!"
+func (c *NodeConfigPaletteEdit) Swatches() ([]PaletteSwatch, error) {
+	raw, err := parseColorsList(c.Colors)
+	if err != nil {
+		return nil, err
+	}
+
+	swatches := make([]PaletteSwatch, len(raw))
+	for i, col := range raw {
+		swatches[i] = PaletteSwatch{
+			Color:   strings.TrimPrefix(col, "!"),
+			Enabled: !strings.HasPrefix(col, "!"),
+		}
+	}
+	return swatches, nil
+}
+
+// SetSwatches writes the swatches to Colors
+func (c *NodeConfigPaletteEdit) SetSwatches(swatches []PaletteSwatch) {
+	raw := make([]string, len(swatches))
+	for i, swatch := range swatches {
+		raw[i] = swatch.Color
+		if !swatch.Enabled {
+			raw[i] = "!" + swatch.Color
+		}
+	}
+	c.Colors = strings.Join(raw, ",")
+}
+
+// AddPaletteSwatch adds a color to a PaletteEdit node's palette at index,
+// or at the end if index is out of range
+func (ig *ImageGraph) AddPaletteSwatch(nodeID NodeID, color string, enabled bool, index int) error {
+	err := ig.editPaletteSwatches(nodeID, func(swatches []PaletteSwatch) ([]PaletteSwatch, error) {
+		if findSwatch(swatches, color) >= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrSwatchExists, color)
+		}
+		if index < 0 || index > len(swatches) {
+			index = len(swatches)
+		}
+		return slices.Insert(swatches, index, PaletteSwatch{Color: color, Enabled: enabled}), nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't add swatch to node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// SetPaletteSwatchEnabled enables or disables a color of a PaletteEdit
+// node's palette
+func (ig *ImageGraph) SetPaletteSwatchEnabled(nodeID NodeID, color string, enabled bool) error {
+	err := ig.editPaletteSwatches(nodeID, func(swatches []PaletteSwatch) ([]PaletteSwatch, error) {
+		i := findSwatch(swatches, color)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrSwatchNotFound, color)
+		}
+		swatches[i].Enabled = enabled
+		return swatches, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't set swatch of node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// RemovePaletteSwatch removes a color from a PaletteEdit node's palette.
+// Colors of the node's source image come back when the node regenerates,
+// so those should be disabled instead.
+func (ig *ImageGraph) RemovePaletteSwatch(nodeID NodeID, color string) error {
+	err := ig.editPaletteSwatches(nodeID, func(swatches []PaletteSwatch) ([]PaletteSwatch, error) {
+		i := findSwatch(swatches, color)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrSwatchNotFound, color)
+		}
+		return slices.Delete(swatches, i, i+1), nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't remove swatch from node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// ReorderPaletteSwatches puts a PaletteEdit node's palette in the order of
+// colors, which must list each of its colors once
+func (ig *ImageGraph) ReorderPaletteSwatches(nodeID NodeID, colors []string) error {
+	err := ig.editPaletteSwatches(nodeID, func(swatches []PaletteSwatch) ([]PaletteSwatch, error) {
+		if len(colors) != len(swatches) {
+			return nil, ErrInvalidSwatchOrder
+		}
+
+		reordered := make([]PaletteSwatch, 0, len(swatches))
+		for _, color := range colors {
+			i := findSwatch(swatches, color)
+			if i < 0 || findSwatch(reordered, color) >= 0 {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidSwatchOrder, color)
+			}
+			reordered = append(reordered, swatches[i])
+		}
+		return reordered, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("couldn't reorder swatches of node %q: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// editPaletteSwatches sets a PaletteEdit node's config to one with its
+// swatches edited, which regenerates the node's outputs
+func (ig *ImageGraph) editPaletteSwatches(
+	nodeID NodeID,
+	edit func([]PaletteSwatch) ([]PaletteSwatch, error),
+) error {
+	return ig.Nodes.WithNode(nodeID, func(n *Node) error {
+		config, ok := n.Config.(*NodeConfigPaletteEdit)
+		if !ok {
+			return ErrNotPaletteEdit
+		}
+
+		swatches, err := config.Swatches()
+		if err != nil {
+			return err
+		}
+
+		swatches, err = edit(swatches)
+		if err != nil {
+			return err
+		}
+
+		edited := NewNodeConfigPaletteEdit()
+		edited.SetSwatches(swatches)
+		return n.SetConfig(edited)
+	})
+}
+
+func findSwatch(swatches []PaletteSwatch, color string) int {
+	return slices.IndexFunc(swatches, func(swatch PaletteSwatch) bool {
+		return strings.EqualFold(swatch.Color, color)
+	})
+}
@@ -0,0 +1,141 @@
+package imagegraph
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// ErrInvalidParameter is returned when a graph parameter has a bad name or
+// value
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+// ErrParameterInUse is returned when removing a graph parameter that node
+// expressions still refer to
+var ErrParameterInUse = errors.New("parameter is used by node expressions")
+
+// MaxParameters is the most parameters an ImageGraph can have
+const MaxParameters = 64
+
+// MaxParameterNameLength is the longest a parameter name can be, in bytes
+const MaxParameterNameLength = 64
+
+// GraphParameters are named numbers set on an ImageGraph, such as
+// "target_width" or "palette_size", that node config expressions can refer
+// to by name. Changing one regenerates the nodes whose expressions use it.
+type GraphParameters map[string]float64
+
+// Validate checks the number of parameters, that each name is lowercase
+// letters, digits and '_' starting with a letter, and isn't a function
+// expressions can call, and that each value is finite
+func (params GraphParameters) Validate() error {
+	if len(params) > MaxParameters {
+		return fmt.Errorf("%w: more than %d parameters", ErrInvalidParameter, MaxParameters)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		if err := validateParameterName(name); err != nil {
+			return err
+		}
+		if value := params[name]; math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%w: %s is not a number", ErrInvalidParameter, name)
+		}
+	}
+
+	return nil
+}
+
+func validateParameterName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidParameter)
+	}
+
+	if len(name) > MaxParameterNameLength {
+		return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidParameter, name, MaxParameterNameLength)
+	}
+
+	if name[0] < 'a' || name[0] > 'z' {
+		return fmt.Errorf("%w: %q must start with a letter", ErrInvalidParameter, name)
+	}
+
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return fmt.Errorf("%w: %q may only contain letters, digits and '_'", ErrInvalidParameter, name)
+		}
+	}
+
+	if _, ok := exprFunctions[name]; ok {
+		return fmt.Errorf("%w: %q is the name of a function", ErrInvalidParameter, name)
+	}
+
+	return nil
+}
+
+// Parameters lists the graph parameters the expressions refer to, sorted.
+// Expressions that don't parse are skipped.
+func (exprs ConfigExpressions) Parameters() []string {
+	var names []string
+	for _, source := range exprs {
+		expr, err := ParseExpression(source)
+		if err != nil {
+			continue
+		}
+		for _, name := range expr.Variables() {
+			if isParameterVariable(name) && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SetParameters replaces the ImageGraph's parameters and regenerates the
+// nodes whose expressions use a parameter whose value changed. Parameters
+// that node expressions use can't be removed.
+func (ig *ImageGraph) SetParameters(params GraphParameters) error {
+	if err := params.Validate(); err != nil {
+		return fmt.Errorf("cannot set parameters: %w", err)
+	}
+
+	if maps.Equal(ig.Parameters, params) {
+		return nil
+	}
+
+	var affected []*Node
+	for _, node := range ig.Nodes {
+		changed := false
+		for _, name := range node.Expressions.Parameters() {
+			value, ok := params[name]
+			if !ok {
+				return fmt.Errorf(
+					"cannot set parameters: %w: node %q uses %s",
+					ErrParameterInUse, node.ID, name,
+				)
+			}
+			if previous, ok := ig.Parameters[name]; !ok || previous != value {
+				changed = true
+			}
+		}
+		if changed {
+			affected = append(affected, node)
+		}
+	}
+
+	ig.Parameters = nil
+	if len(params) > 0 {
+		ig.Parameters = maps.Clone(params)
+	}
+
+	ig.AddEvent(NewParametersSetEvent(ig))
+
+	for _, node := range affected {
+		if err := node.regenerateOutputs(); err != nil {
+			return fmt.Errorf("cannot set parameters: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package imagegraph
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ImageProvenance records how a generated image was produced: which version
+// of which node generated it, from which input images and with which
+// config. Uploaded images have none.
+type ImageProvenance struct {
+	ImageGraphID   ImageGraphID      `json:"image_graph_id"`
+	NodeID         NodeID            `json:"node_id"`
+	NodeType       NodeType          `json:"node_type"`
+	NodeVersion    NodeVersion       `json:"node_version"`
+	OutputName     OutputName        `json:"output_name"`
+	Implementation int               `json:"implementation"`
+	Bypassed       bool              `json:"bypassed,omitempty"`
+	Inputs         []ProvenanceInput `json:"inputs"`
+
+	// The config the node generated with, its expressions resolved
+	Config NodeConfig `json:"config"`
+
+	ProducedAt time.Time `json:"produced_at"`
+}
+
+// ProvenanceInput is an image a node generated from. Transform is the
+// transform the input's connection applied to the image on the way in.
+type ProvenanceInput struct {
+	Name      InputName `json:"name"`
+	ImageID   ImageID   `json:"image_id"`
+	Transform string    `json:"transform,omitempty"`
+}
+
+// Provenance returns the provenance of the outputs the node generates from
+// the event, generating with config. The output name and production time
+// are filled in as each output is saved. Inputs without an image are left
+// out.
+func (e *NodeNeedsOutputsEvent) Provenance(config NodeConfig) ImageProvenance {
+	provenance := ImageProvenance{
+		ImageGraphID:   e.ImageGraphID,
+		NodeID:         e.NodeID,
+		NodeType:       e.NodeType,
+		NodeVersion:    e.NodeVersion,
+		Implementation: e.Implementation,
+		Bypassed:       e.Bypassed,
+		Inputs:         []ProvenanceInput{},
+		Config:         config,
+	}
+
+	for _, input := range e.Inputs {
+		if input.ImageID.IsNil() {
+			continue
+		}
+		provenance.Inputs = append(provenance.Inputs, ProvenanceInput{
+			Name:      input.Name,
+			ImageID:   input.ImageID,
+			Transform: input.Transform,
+		})
+	}
+
+	return provenance
+}
+
+// UnmarshalJSON decodes the config into the config type of the node's type
+func (p *ImageProvenance) UnmarshalJSON(data []byte) error {
+	type provenance ImageProvenance
+	aux := struct {
+		*provenance
+		Config json.RawMessage `json:"config"`
+	}{provenance: (*provenance)(p)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	config, err := unmarshalNodeConfig(p.NodeType, aux.Config)
+	if err != nil {
+		return err
+	}
+
+	p.Config = config
+	return nil
+}
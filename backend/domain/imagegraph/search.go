@@ -0,0 +1,108 @@
+package imagegraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxSearchQueryLength is the longest search query, in bytes
+const MaxSearchQueryLength = 256
+
+// SearchField is the part of an ImageGraph or Node that matched a search
+type SearchField string
+
+const (
+	SearchFieldName SearchField = "name"
+	SearchFieldTag  SearchField = "tag"
+)
+
+// SearchMatch is a name or tag of an ImageGraph, or of one of its Nodes,
+// that contains a search query
+type SearchMatch struct {
+	// NodeID is nil when the match is on the ImageGraph itself
+	NodeID NodeID
+	Field  SearchField
+	Value  string
+
+	// Prefix is set when the value starts with the query
+	Prefix bool
+}
+
+// NormalizeSearchQuery returns the canonical form of a search query: trimmed
+// and lowercased
+func NormalizeSearchQuery(query string) (string, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	if len(query) == 0 {
+		return "", fmt.Errorf("search query cannot be empty")
+	}
+
+	if len(query) > MaxSearchQueryLength {
+		return "", fmt.Errorf("search query is longer than %d bytes", MaxSearchQueryLength)
+	}
+
+	return query, nil
+}
+
+// SearchTerms lists the names and tags of the ImageGraph and its Nodes,
+// lowercased, which are the values a search is matched against
+func (ig *ImageGraph) SearchTerms() []string {
+	terms := []string{strings.ToLower(ig.Name)}
+	terms = append(terms, ig.Tags...)
+
+	for _, node := range ig.Nodes {
+		terms = append(terms, strings.ToLower(node.Name))
+		terms = append(terms, node.Tags...)
+	}
+
+	return terms
+}
+
+// Search finds the names and tags of the ImageGraph and its Nodes that
+// contain the normalized query, ignoring case. Prefix matches are listed
+// first, then matches on the ImageGraph before those on its Nodes.
+func (ig *ImageGraph) Search(query string) []SearchMatch {
+	var matches []SearchMatch
+
+	match := func(nodeID NodeID, field SearchField, value string) {
+		lower := strings.ToLower(value)
+		if !strings.Contains(lower, query) {
+			return
+		}
+		matches = append(matches, SearchMatch{
+			NodeID: nodeID,
+			Field:  field,
+			Value:  value,
+			Prefix: strings.HasPrefix(lower, query),
+		})
+	}
+
+	match(NodeID{}, SearchFieldName, ig.Name)
+	for _, tag := range ig.Tags {
+		match(NodeID{}, SearchFieldTag, tag)
+	}
+
+	for _, node := range ig.Nodes {
+		match(node.ID, SearchFieldName, node.Name)
+		for _, tag := range node.Tags {
+			match(node.ID, SearchFieldTag, tag)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Prefix != b.Prefix {
+			return a.Prefix
+		}
+		if a.NodeID.IsNil() != b.NodeID.IsNil() {
+			return a.NodeID.IsNil()
+		}
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+		return a.NodeID.String() < b.NodeID.String()
+	})
+
+	return matches
+}
@@ -0,0 +1,85 @@
+package imagegraph
+
+import "fmt"
+
+// DefaultPaletteExtractSeed seeds the k-means clustering of PaletteExtract
+// nodes that have no seed of their own and whose graph has none
+const DefaultPaletteExtractSeed = 42
+
+// NodeConfigSeeder is implemented by the configs of node types with
+// stochastic behavior, which take a seed so their outputs can be reproduced
+type NodeConfigSeeder interface {
+	NodeConfig
+
+	// ConfigSeed is the seed set on the config, or nil to use the graph's
+	ConfigSeed() *int
+
+	// DefaultSeed is the seed used when neither the config nor the graph
+	// sets one. Without one, every generation draws a fresh seed.
+	DefaultSeed() (int, bool)
+}
+
+// ValidateSeed checks that a seed is not negative
+func ValidateSeed(seed *int) error {
+	if seed != nil && *seed < 0 {
+		return fmt.Errorf("seed must be non-negative")
+	}
+	return nil
+}
+
+// EffectiveSeed is the seed a node with the given config generates with: the
+// config's seed, or else the graph's, or else its node type's default. It's
+// false for node types without stochastic behavior and nodes that draw a
+// fresh seed each time.
+func EffectiveSeed(config NodeConfig, graphSeed *int) (int, bool) {
+	seeder, ok := config.(NodeConfigSeeder)
+	if !ok {
+		return 0, false
+	}
+	if seed := seeder.ConfigSeed(); seed != nil {
+		return *seed, true
+	}
+	if graphSeed != nil {
+		return *graphSeed, true
+	}
+	return seeder.DefaultSeed()
+}
+
+// inheritsSeed reports whether the node uses the graph's seed
+func (n *Node) inheritsSeed() bool {
+	seeder, ok := n.Config.(NodeConfigSeeder)
+	return ok && seeder.ConfigSeed() == nil
+}
+
+// SetSeed sets the seed that stochastic nodes without a seed of their own
+// generate with, regenerating those nodes. A nil seed leaves them to their
+// node type's default.
+func (ig *ImageGraph) SetSeed(seed *int) error {
+	if err := ValidateSeed(seed); err != nil {
+		return fmt.Errorf("cannot set seed: %w", err)
+	}
+
+	if (ig.Seed == nil && seed == nil) ||
+		(ig.Seed != nil && seed != nil && *ig.Seed == *seed) {
+		return nil
+	}
+
+	ig.Seed = nil
+	if seed != nil {
+		value := *seed
+		ig.Seed = &value
+	}
+
+	ig.AddEvent(NewSeedSetEvent(ig))
+
+	for _, node := range ig.Nodes {
+		if !node.inheritsSeed() {
+			continue
+		}
+		if err := node.regenerateOutputs(); err != nil {
+			return fmt.Errorf("cannot set seed: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package imagegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+)
+
+// Role is the access a user has to an ImageGraph. Roles are ordered: each
+// role can do everything the roles below it can.
+type Role int
+
+const (
+	// RoleNone users can't see the ImageGraph at all
+	RoleNone Role = iota
+
+	// RoleViewer users can see the ImageGraph and its images
+	RoleViewer
+
+	// RoleEditor users can also change the ImageGraph's nodes, connections,
+	// tags and layout
+	RoleEditor
+
+	// RoleOwner users can also publish the ImageGraph and manage its shares
+	RoleOwner
+)
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	str := RoleMapper.FromWithDefault(r, "none")
+	return json.Marshal(str)
+}
+
+func (r *Role) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	if str == "none" {
+		*r = RoleNone
+		return nil
+	}
+
+	parsed, err := RoleMapper.To(str)
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+	return nil
+}
+
+// Shares maps the IDs of the users an ImageGraph is shared with to the role
+// they were granted. Shares are replaced rather than modified, so clones of
+// an ImageGraph can safely share them.
+type Shares map[string]Role
+
+// Share grants a user a role on the ImageGraph, replacing any role they were
+// granted before. Only viewer and editor roles can be granted, and never to
+// the ImageGraph's owner.
+func (ig *ImageGraph) Share(userID string, role Role) error {
+	if userID == "" {
+		return fmt.Errorf("couldn't share ImageGraph %q: user ID cannot be empty", ig.ID)
+	}
+
+	if role != RoleViewer && role != RoleEditor {
+		return fmt.Errorf("couldn't share ImageGraph %q: only viewer and editor roles can be granted", ig.ID)
+	}
+
+	if userID == ig.Owner {
+		return fmt.Errorf("couldn't share ImageGraph %q: user %q already owns it", ig.ID, userID)
+	}
+
+	if current, ok := ig.Shares[userID]; ok && current == role {
+		return nil
+	}
+
+	shares := maps.Clone(ig.Shares)
+	if shares == nil {
+		shares = make(Shares, 1)
+	}
+	shares[userID] = role
+	ig.Shares = shares
+
+	ig.AddEvent(NewSharedEvent(userID, role))
+
+	return nil
+}
+
+// Unshare revokes the role a user was granted on the ImageGraph. Unsharing
+// with a user the ImageGraph isn't shared with does nothing.
+func (ig *ImageGraph) Unshare(userID string) error {
+	if _, ok := ig.Shares[userID]; !ok {
+		return nil
+	}
+
+	shares := maps.Clone(ig.Shares)
+	delete(shares, userID)
+	if len(shares) == 0 {
+		shares = nil
+	}
+	ig.Shares = shares
+
+	ig.AddEvent(NewUnsharedEvent(userID))
+
+	return nil
+}
+
+// RoleOf returns the role a user has on the ImageGraph
+func (ig *ImageGraph) RoleOf(userID string) Role {
+	return ig.Shares.RoleOf(ig.Owner, userID)
+}
+
+// RoleOf returns the role a user has on an ImageGraph with the owner and
+// these shares
+func (s Shares) RoleOf(owner string, userID string) Role {
+	if userID == "" {
+		return RoleNone
+	}
+
+	if userID == owner {
+		return RoleOwner
+	}
+
+	if role, ok := s[userID]; ok {
+		return role
+	}
+
+	return RoleNone
+}
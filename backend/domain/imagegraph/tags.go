@@ -0,0 +1,60 @@
+package imagegraph
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MaxTagLength is the longest tag, in bytes, that can be added to an
+// ImageGraph or a Node
+const MaxTagLength = 64
+
+// Tags is a sorted set of labels used to organize ImageGraphs and Nodes
+type Tags []string
+
+// NormalizeTag returns the canonical form of a tag: trimmed and lowercased.
+// Tags may contain letters, digits, '-' and '_'.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	if len(tag) == 0 {
+		return "", fmt.Errorf("tag cannot be empty")
+	}
+
+	if len(tag) > MaxTagLength {
+		return "", fmt.Errorf("tag %q is longer than %d bytes", tag, MaxTagLength)
+	}
+
+	for _, r := range tag {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return "", fmt.Errorf("tag %q may only contain letters, digits, '-' and '_'", tag)
+		}
+	}
+
+	return tag, nil
+}
+
+// Has reports whether the set contains the tag
+func (t Tags) Has(tag string) bool {
+	_, found := slices.BinarySearch(t, tag)
+	return found
+}
+
+// with returns the set with the tag added, and whether it was added
+func (t Tags) with(tag string) (Tags, bool) {
+	i, found := slices.BinarySearch(t, tag)
+	if found {
+		return t, false
+	}
+	return slices.Insert(slices.Clone(t), i, tag), true
+}
+
+// without returns the set with the tag removed, and whether it was removed
+func (t Tags) without(tag string) (Tags, bool) {
+	i, found := slices.BinarySearch(t, tag)
+	if !found {
+		return t, false
+	}
+	return slices.Delete(slices.Clone(t), i, i+1), true
+}
@@ -0,0 +1,245 @@
+package imagegraph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ImageKind is the kind of image a node input takes or an output produces
+type ImageKind int
+
+const (
+	// ImageKindRaster is a picture, the kind of most inputs and outputs
+	ImageKindRaster ImageKind = iota
+	// ImageKindPalette is an image of palette colors, as produced by the
+	// palette nodes
+	ImageKindPalette
+	// ImageKindAny is taken by inputs that accept either kind, and produced
+	// by outputs that pass on the kind of image their primary input receives
+	ImageKindAny
+)
+
+func (k ImageKind) String() string {
+	switch k {
+	case ImageKindRaster:
+		return "raster"
+	case ImageKindPalette:
+		return "palette"
+	default:
+		return "any"
+	}
+}
+
+// ErrIncompatibleImageKinds is returned when connecting an output to an
+// input that can't take the kind of image it produces
+var ErrIncompatibleImageKinds = errors.New("incompatible image kinds")
+
+// ConnectionValidation is what static validation found about a connection.
+// Errors are connections that can't produce a meaningful image; warnings
+// are connections that may not do what was intended.
+type ConnectionValidation struct {
+	FromNodeID NodeID
+	OutputName OutputName
+	ToNodeID   NodeID
+	InputName  InputName
+	Errors     []string
+	Warnings   []string
+}
+
+// ValidateConnections checks the kind and dimensions of the images every
+// connection of the ImageGraph carries, without generating anything. sizes
+// holds the dimensions of images that are known; the sizes of images that
+// haven't been generated yet are propagated through nodes that preserve
+// size or whose config sets it, where possible. Only connections with errors or warnings are
+// returned, ordered by the node and input they connect to.
+func (ig *ImageGraph) ValidateConnections(sizes map[ImageID]ImageSize) []ConnectionValidation {
+	var validations []ConnectionValidation
+
+	for _, node := range ig.Nodes {
+		def := NodeTypeDefs[node.Type]
+
+		for inputName, input := range node.Inputs {
+			if !input.Connected {
+				continue
+			}
+
+			v := ConnectionValidation{
+				FromNodeID: input.InputConnection.NodeID,
+				OutputName: input.InputConnection.OutputName,
+				ToNodeID:   node.ID,
+				InputName:  inputName,
+			}
+
+			if fromNode, ok := ig.Nodes.Get(v.FromNodeID); ok {
+				if err := ig.checkConnectionKinds(fromNode, v.OutputName, node, inputName); err != nil {
+					v.Errors = append(v.Errors, err.Error())
+				}
+			}
+
+			if other, ok := def.MatchSize[inputName]; ok {
+				size, known := ig.inputSize(node, inputName, sizes)
+				otherSize, otherKnown := ig.inputSize(node, other, sizes)
+				if known && otherKnown && size != otherSize {
+					v.Warnings = append(v.Warnings, fmt.Sprintf(
+						"image is %dx%d but %s is %dx%d",
+						size.Width, size.Height, other, otherSize.Width, otherSize.Height,
+					))
+				}
+			}
+
+			if len(v.Errors) > 0 || len(v.Warnings) > 0 {
+				validations = append(validations, v)
+			}
+		}
+	}
+
+	slices.SortFunc(validations, func(a, b ConnectionValidation) int {
+		if c := strings.Compare(a.ToNodeID.String(), b.ToNodeID.String()); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.InputName), string(b.InputName))
+	})
+
+	return validations
+}
+
+// checkConnectionKinds returns an error if a node output produces a kind of
+// image the input it's connected to can't take
+func (ig *ImageGraph) checkConnectionKinds(
+	fromNode *Node,
+	outputName OutputName,
+	toNode *Node,
+	inputName InputName,
+) error {
+	outputKind := ig.outputKind(fromNode, outputName)
+	inputKind := NodeTypeDefs[toNode.Type].InputKind(inputName)
+
+	if outputKind == ImageKindAny || inputKind == ImageKindAny || outputKind == inputKind {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: %s output %q cannot connect to %s input %q",
+		ErrIncompatibleImageKinds, outputKind, outputName, inputKind, inputName,
+	)
+}
+
+// checkConnectionTransform ensures that a connection's transform is valid
+// and that it doesn't transform palettes, which transforms would turn into
+// ordinary images
+func (ig *ImageGraph) checkConnectionTransform(
+	fromNode *Node,
+	outputName OutputName,
+	toNode *Node,
+	inputName InputName,
+	transform string,
+) error {
+	if err := ValidateConnectionTransform(transform); err != nil {
+		return err
+	}
+
+	if transform == ConnectionTransformNone {
+		return nil
+	}
+
+	if ig.outputKind(fromNode, outputName) == ImageKindPalette ||
+		NodeTypeDefs[toNode.Type].InputKind(inputName) == ImageKindPalette {
+		return fmt.Errorf(
+			"%w: output %q of node %q feeds a palette to input %q of node %q",
+			ErrPaletteTransformed, outputName, fromNode.ID, inputName, toNode.ID,
+		)
+	}
+
+	return nil
+}
+
+// outputKind resolves the kind of image a node output produces. Outputs
+// that pass on their input's kind, including the primary output of a
+// bypassed node, are followed upstream; they are ImageKindAny if nothing
+// is connected.
+func (ig *ImageGraph) outputKind(node *Node, outputName OutputName) ImageKind {
+	for {
+		def := NodeTypeDefs[node.Type]
+
+		kind := def.OutputKind(outputName)
+		if node.Bypassed && outputName == def.PrimaryOutput() {
+			kind = ImageKindAny
+		}
+		if kind != ImageKindAny {
+			return kind
+		}
+
+		input, ok := node.Inputs[def.PrimaryInput()]
+		if !ok || !input.Connected {
+			return ImageKindAny
+		}
+		upstream, ok := ig.Nodes.Get(input.InputConnection.NodeID)
+		if !ok {
+			return ImageKindAny
+		}
+		node, outputName = upstream, input.InputConnection.OutputName
+	}
+}
+
+// inputSize returns the dimensions of the image an input receives: the
+// size of the image set on it, or else what reaches it from upstream
+func (ig *ImageGraph) inputSize(node *Node, inputName InputName, sizes map[ImageID]ImageSize) (ImageSize, bool) {
+	input, ok := node.Inputs[inputName]
+	if !ok {
+		return ImageSize{}, false
+	}
+	if size, ok := sizes[input.ImageID]; ok && !input.ImageID.IsNil() {
+		return size, true
+	}
+	if !input.Connected {
+		return ImageSize{}, false
+	}
+
+	upstream, ok := ig.Nodes.Get(input.InputConnection.NodeID)
+	if !ok {
+		return ImageSize{}, false
+	}
+	return ig.outputSize(upstream, input.InputConnection.OutputName, sizes)
+}
+
+// outputSize returns the dimensions of the image an output produces: the
+// size of its current image, or else its primary input's size if the node
+// preserves size or is bypassed, or what the node's config makes of the
+// sizes of its inputs
+func (ig *ImageGraph) outputSize(node *Node, outputName OutputName, sizes map[ImageID]ImageSize) (ImageSize, bool) {
+	if output, ok := node.Outputs[outputName]; ok && !output.ImageID.IsNil() {
+		if size, ok := sizes[output.ImageID]; ok {
+			return size, true
+		}
+	}
+
+	def := NodeTypeDefs[node.Type]
+	if outputName != def.PrimaryOutput() {
+		return ImageSize{}, false
+	}
+	if def.PreservesSize || node.Bypassed {
+		return ig.inputSize(node, def.PrimaryInput(), sizes)
+	}
+
+	inputs := ig.inputSizes(node, sizes)
+
+	sizer, ok := node.resolvedConfig(inputs, ig.Parameters).(NodeConfigSizer)
+	if !ok {
+		return ImageSize{}, false
+	}
+	return sizer.OutputSize(inputs)
+}
+
+// inputSizes returns the dimensions of the images every input of a node
+// receives, leaving out inputs whose size isn't known
+func (ig *ImageGraph) inputSizes(node *Node, sizes map[ImageID]ImageSize) map[InputName]ImageSize {
+	inputs := make(map[InputName]ImageSize)
+	for name := range node.Inputs {
+		if size, ok := ig.inputSize(node, name, sizes); ok {
+			inputs[name] = size
+		}
+	}
+	return inputs
+}
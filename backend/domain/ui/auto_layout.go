@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Spacing between the columns and rows of an automatic layout, wide enough
+// for the editor's node cards
+const (
+	AutoLayoutLayerSpacing = 320.0
+	AutoLayoutNodeSpacing  = 240.0
+)
+
+// autoLayoutSweeps is how many times the ordering of the layers is refined
+const autoLayoutSweeps = 8
+
+// AutoLayout arranges the nodes of an ImageGraph in layers, left to right,
+// following the direction of their connections. Every node is placed one
+// layer after the furthest of its upstream nodes, and the nodes of each layer
+// are ordered to keep connected nodes level with each other, which keeps
+// connections from crossing. Each layer is centred vertically on Y = 0.
+// Nodes keep the size, color and other state they have in current; only
+// their positions change.
+func AutoLayout(ig *imagegraph.ImageGraph, current []NodeLayout) []NodeLayout {
+	ids := make([]imagegraph.NodeID, 0, len(ig.Nodes))
+	for id := range ig.Nodes {
+		ids = append(ids, id)
+	}
+
+	// Start from a stable order so the same graph is always laid out the
+	// same way
+	slices.SortFunc(ids, func(a, b imagegraph.NodeID) int {
+		return cmp.Or(
+			cmp.Compare(ig.Nodes[a].Name, ig.Nodes[b].Name),
+			cmp.Compare(a.String(), b.String()),
+		)
+	})
+
+	upstream := make(map[imagegraph.NodeID][]imagegraph.NodeID, len(ids))
+	downstream := make(map[imagegraph.NodeID][]imagegraph.NodeID, len(ids))
+	for _, id := range ids {
+		for _, input := range ig.Nodes[id].Inputs {
+			from := input.InputConnection.NodeID
+			if !input.Connected || slices.Contains(upstream[id], from) {
+				continue
+			}
+			if _, ok := ig.Nodes[from]; !ok {
+				continue
+			}
+			upstream[id] = append(upstream[id], from)
+			downstream[from] = append(downstream[from], id)
+		}
+	}
+
+	layers := assignLayers(ids, upstream)
+	orderLayers(layers, upstream, downstream)
+
+	existing := make(map[imagegraph.NodeID]NodeLayout, len(current))
+	for _, nl := range current {
+		existing[nl.NodeID] = nl
+	}
+
+	nodeLayouts := make([]NodeLayout, 0, len(ids))
+	for l, layer := range layers {
+		offset := float64(len(layer)-1) / 2
+		for i, id := range layer {
+			nl := existing[id]
+			nl.NodeID = id
+			nl.X = float64(l) * AutoLayoutLayerSpacing
+			nl.Y = (float64(i) - offset) * AutoLayoutNodeSpacing
+			nodeLayouts = append(nodeLayouts, nl)
+		}
+	}
+
+	return nodeLayouts
+}
+
+// assignLayers places each node in the layer after the furthest of its
+// upstream nodes, so nodes without inputs connected are in the first layer
+func assignLayers(
+	ids []imagegraph.NodeID,
+	upstream map[imagegraph.NodeID][]imagegraph.NodeID,
+) [][]imagegraph.NodeID {
+	depth := make(map[imagegraph.NodeID]int, len(ids))
+	visiting := make(map[imagegraph.NodeID]bool)
+
+	var depthOf func(imagegraph.NodeID) int
+	depthOf = func(id imagegraph.NodeID) int {
+		if d, ok := depth[id]; ok {
+			return d
+		}
+
+		// ImageGraphs don't allow cycles, but don't recurse forever if one
+		// got in anyway
+		if visiting[id] {
+			return 0
+		}
+		visiting[id] = true
+
+		d := 0
+		for _, from := range upstream[id] {
+			d = max(d, depthOf(from)+1)
+		}
+
+		depth[id] = d
+		return d
+	}
+
+	var layers [][]imagegraph.NodeID
+	for _, id := range ids {
+		d := depthOf(id)
+		for len(layers) <= d {
+			layers = append(layers, nil)
+		}
+		layers[d] = append(layers[d], id)
+	}
+
+	return layers
+}
+
+// orderLayers reorders the nodes of each layer by the average position of
+// the nodes they are connected to, sweeping forwards using upstream nodes and
+// backwards using downstream nodes
+func orderLayers(
+	layers [][]imagegraph.NodeID,
+	upstream map[imagegraph.NodeID][]imagegraph.NodeID,
+	downstream map[imagegraph.NodeID][]imagegraph.NodeID,
+) {
+	// Positions are fractions of the layer's height so that layers with
+	// different numbers of nodes can be compared
+	position := make(map[imagegraph.NodeID]float64)
+	place := func(layer []imagegraph.NodeID) {
+		for i, id := range layer {
+			position[id] = (float64(i) + 0.5) / float64(len(layer))
+		}
+	}
+
+	for _, layer := range layers {
+		place(layer)
+	}
+
+	sortLayer := func(layer []imagegraph.NodeID, neighbours map[imagegraph.NodeID][]imagegraph.NodeID) {
+		barycenter := make(map[imagegraph.NodeID]float64, len(layer))
+		for _, id := range layer {
+			if len(neighbours[id]) == 0 {
+				barycenter[id] = position[id]
+				continue
+			}
+
+			sum := 0.0
+			for _, n := range neighbours[id] {
+				sum += position[n]
+			}
+			barycenter[id] = sum / float64(len(neighbours[id]))
+		}
+
+		slices.SortStableFunc(layer, func(a, b imagegraph.NodeID) int {
+			return cmp.Compare(barycenter[a], barycenter[b])
+		})
+		place(layer)
+	}
+
+	for range autoLayoutSweeps {
+		for l := 1; l < len(layers); l++ {
+			sortLayer(layers[l], upstream)
+		}
+		for l := len(layers) - 2; l >= 0; l-- {
+			sortLayer(layers[l], downstream)
+		}
+	}
+}
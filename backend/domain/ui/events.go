@@ -9,20 +9,22 @@ import (
 type LayoutEvent struct {
 	messages.BaseEvent
 	GraphID imagegraph.ImageGraphID
+	UserID  string
 }
 
-// LayoutUpdatedEvent is emitted when node positions are updated
+// LayoutUpdatedEvent is emitted when node layouts are updated
 type LayoutUpdatedEvent struct {
 	LayoutEvent
-	NodePositions []NodePosition
+	NodeLayouts []NodeLayout
 }
 
 func NewLayoutUpdatedEvent(layout *Layout) *LayoutUpdatedEvent {
 	e := &LayoutUpdatedEvent{
 		LayoutEvent: LayoutEvent{
 			GraphID: layout.GraphID,
+			UserID:  layout.UserID,
 		},
-		NodePositions: append([]NodePosition{}, layout.NodePositions...),
+		NodeLayouts: append([]NodeLayout{}, layout.NodeLayouts...),
 	}
 	e.Init("LayoutUpdated")
 	return e
@@ -32,6 +34,7 @@ func NewLayoutUpdatedEvent(layout *Layout) *LayoutUpdatedEvent {
 type ViewportEvent struct {
 	messages.BaseEvent
 	GraphID imagegraph.ImageGraphID
+	UserID  string
 }
 
 // ViewportUpdatedEvent is emitted when viewport is updated
@@ -46,6 +49,7 @@ func NewViewportUpdatedEvent(viewport *Viewport) *ViewportUpdatedEvent {
 	e := &ViewportUpdatedEvent{
 		ViewportEvent: ViewportEvent{
 			GraphID: viewport.GraphID,
+			UserID:  viewport.UserID,
 		},
 		Zoom: viewport.Zoom,
 		PanX: viewport.PanX,
@@ -54,3 +58,40 @@ func NewViewportUpdatedEvent(viewport *Viewport) *ViewportUpdatedEvent {
 	e.Init("ViewportUpdated")
 	return e
 }
+
+// ViewportBookmarkSavedEvent is emitted when a viewport bookmark is added or
+// replaced
+type ViewportBookmarkSavedEvent struct {
+	ViewportEvent
+	Bookmark ViewportBookmark
+}
+
+func NewViewportBookmarkSavedEvent(viewport *Viewport, bookmark ViewportBookmark) *ViewportBookmarkSavedEvent {
+	e := &ViewportBookmarkSavedEvent{
+		ViewportEvent: ViewportEvent{
+			GraphID: viewport.GraphID,
+			UserID:  viewport.UserID,
+		},
+		Bookmark: bookmark,
+	}
+	e.Init("ViewportBookmarkSaved")
+	return e
+}
+
+// ViewportBookmarkDeletedEvent is emitted when a viewport bookmark is removed
+type ViewportBookmarkDeletedEvent struct {
+	ViewportEvent
+	Name string
+}
+
+func NewViewportBookmarkDeletedEvent(viewport *Viewport, name string) *ViewportBookmarkDeletedEvent {
+	e := &ViewportBookmarkDeletedEvent{
+		ViewportEvent: ViewportEvent{
+			GraphID: viewport.GraphID,
+			UserID:  viewport.UserID,
+		},
+		Name: name,
+	}
+	e.Init("ViewportBookmarkDeleted")
+	return e
+}
@@ -2,58 +2,139 @@ package ui
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/dorky/aggregate"
 )
 
-// NodePosition represents the 2D position of a node on the canvas
-type NodePosition struct {
+// NodeColors are the color labels a node can be marked with on the canvas.
+// An empty color leaves the node unmarked.
+var NodeColors = []string{"red", "orange", "yellow", "green", "blue", "purple", "gray"}
+
+// NodeLayout represents how a node is drawn on the canvas
+type NodeLayout struct {
 	NodeID imagegraph.NodeID
-	X      float64
-	Y      float64
+
+	// Position of the node's top-left corner
+	X float64
+	Y float64
+
+	// Size the node was resized to, zero for the canvas's default size
+	Width  float64
+	Height float64
+
+	// Collapsed nodes are drawn as just their title bar
+	Collapsed bool
+
+	// Color label, one of NodeColors or empty
+	Color string
+
+	// Nodes with a higher ZOrder are drawn on top of overlapping nodes
+	ZOrder int
+}
+
+// Validate checks that the node's position and size are finite numbers, its
+// size isn't negative and its color label is valid
+func (nl NodeLayout) Validate() error {
+	errs := &ValidationError{}
+	nl.validate("", errs)
+	return errs.err()
+}
+
+// validate adds an error to errs for each invalid field, naming the fields
+// with the given prefix
+func (nl NodeLayout) validate(prefix string, errs *ValidationError) {
+	errs.checkFinite(prefix+"x", nl.X)
+	errs.checkFinite(prefix+"y", nl.Y)
+	errs.checkFinite(prefix+"width", nl.Width)
+	errs.checkFinite(prefix+"height", nl.Height)
+
+	if nl.Width < 0 {
+		errs.add(prefix+"width", "cannot be negative, got %g", nl.Width)
+	}
+	if nl.Height < 0 {
+		errs.add(prefix+"height", "cannot be negative, got %g", nl.Height)
+	}
+
+	if nl.Color != "" && !slices.Contains(NodeColors, nl.Color) {
+		errs.add(prefix+"color", "must be one of %s, got %q", strings.Join(NodeColors, ", "), nl.Color)
+	}
 }
 
 // Layout represents the node positioning layout for an ImageGraph
-// This is an aggregate root identified by GraphID
+// This is an aggregate root identified by GraphID and UserID
 type Layout struct {
 	aggregate.Aggregate
 
-	// The ImageGraph this layout belongs to (serves as the aggregate ID)
+	// The ImageGraph this layout belongs to
 	GraphID imagegraph.ImageGraphID
 
-	// Node positions on the canvas
-	NodePositions []NodePosition
+	// The user this layout belongs to, so collaborators arrange the canvas
+	// independently. Empty for the layout shared by everyone, which is saved
+	// when authentication is disabled.
+	UserID string
+
+	// How each node is drawn on the canvas
+	NodeLayouts []NodeLayout
 }
 
-// NewLayout creates a new Layout with empty node positions
+// NewLayout creates a new Layout for a user with no node layouts
 func NewLayout(
 	graphID imagegraph.ImageGraphID,
+	userID string,
 ) (*Layout, error) {
 	if graphID.IsNil() {
 		return nil, fmt.Errorf("cannot create Layout with nil GraphID")
 	}
 
 	return &Layout{
-		GraphID:       graphID,
-		NodePositions: []NodePosition{},
+		GraphID:     graphID,
+		UserID:      userID,
+		NodeLayouts: []NodeLayout{},
 	}, nil
 }
 
-// SetNodePositions replaces all node positions and emits a LayoutUpdatedEvent
-func (l *Layout) SetNodePositions(nodePositions []NodePosition) {
-	l.NodePositions = nodePositions
+// SetNodeLayouts replaces all node layouts and emits a LayoutUpdatedEvent.
+// Each node layout must be valid and for a different one of the graph's
+// nodes, otherwise a *ValidationError lists the problems and nothing changes.
+func (l *Layout) SetNodeLayouts(nodeLayouts []NodeLayout, nodes imagegraph.Nodes) error {
+	errs := &ValidationError{}
+	seen := make(map[imagegraph.NodeID]bool, len(nodeLayouts))
+
+	for i, nl := range nodeLayouts {
+		prefix := fmt.Sprintf("node_positions[%d].", i)
+
+		if _, ok := nodes.Get(nl.NodeID); !ok {
+			errs.add(prefix+"node_id", "node %q does not exist in the graph", nl.NodeID)
+		} else if seen[nl.NodeID] {
+			errs.add(prefix+"node_id", "node %q is positioned more than once", nl.NodeID)
+		}
+		seen[nl.NodeID] = true
+
+		nl.validate(prefix, errs)
+	}
+
+	if err := errs.err(); err != nil {
+		return fmt.Errorf("could not set node layouts: %w", err)
+	}
+
+	l.NodeLayouts = nodeLayouts
 	l.AddEvent(NewLayoutUpdatedEvent(l))
+
+	return nil
 }
 
 // Clone creates a deep copy of the Layout
 func (l *Layout) Clone() *Layout {
 	clone := &Layout{
-		GraphID:       l.GraphID,
-		NodePositions: make([]NodePosition, len(l.NodePositions)),
+		GraphID:     l.GraphID,
+		UserID:      l.UserID,
+		NodeLayouts: make([]NodeLayout, len(l.NodeLayouts)),
 	}
 
-	copy(clone.NodePositions, l.NodePositions)
+	copy(clone.NodeLayouts, l.NodeLayouts)
 
 	return clone
 }
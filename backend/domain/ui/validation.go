@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FieldError is a problem with one field of an update, named as it is in the
+// API, such as "zoom" or "node_positions[2].x"
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError is returned when an update to a Layout or Viewport has
+// invalid fields, listing every problem rather than just the first
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Field + ": " + f.Message
+	}
+	return "invalid " + strings.Join(problems, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the ValidationError if any field was invalid, nil otherwise
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// checkFinite adds an error for the field if the value is NaN or infinite
+func (e *ValidationError) checkFinite(field string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		e.add(field, "must be a finite number, got %g", value)
+	}
+}
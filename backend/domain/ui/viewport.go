@@ -2,20 +2,27 @@ package ui
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/dorky/aggregate"
 )
 
 // Viewport represents the canvas viewport state (zoom and pan) for an ImageGraph
-// This is an aggregate root identified by GraphID
+// This is an aggregate root identified by GraphID and UserID
 type Viewport struct {
 	aggregate.Aggregate
 
-	// The ImageGraph this viewport belongs to (serves as the aggregate ID)
+	// The ImageGraph this viewport belongs to
 	GraphID imagegraph.ImageGraphID
 
-	// Zoom level (must be > 0)
+	// The user this viewport belongs to, empty for the viewport shared by
+	// everyone
+	UserID string
+
+	// Zoom level, between MinZoom and MaxZoom
 	Zoom float64
 
 	// Pan offset X
@@ -23,11 +30,37 @@ type Viewport struct {
 
 	// Pan offset Y
 	PanY float64
+
+	// Named viewports users can jump back to, in the order they were added
+	Bookmarks []ViewportBookmark
 }
 
-// NewViewport creates a new Viewport with default settings
+// MinZoom and MaxZoom are how far the canvas can be zoomed out and in
+const (
+	MinZoom = 0.1
+	MaxZoom = 5.0
+)
+
+// MaxViewportBookmarks is how many bookmarks a Viewport can have
+const MaxViewportBookmarks = 50
+
+// maxBookmarkNameLength is the longest a bookmark name can be, in bytes
+const maxBookmarkNameLength = 64
+
+// ViewportBookmark is a named zoom and pan on the canvas. At most one
+// bookmark of a Viewport is the default, which the editor opens the graph at.
+type ViewportBookmark struct {
+	Name    string
+	Zoom    float64
+	PanX    float64
+	PanY    float64
+	Default bool
+}
+
+// NewViewport creates a new Viewport for a user with default settings
 func NewViewport(
 	graphID imagegraph.ImageGraphID,
+	userID string,
 ) (*Viewport, error) {
 	if graphID.IsNil() {
 		return nil, fmt.Errorf("cannot create Viewport with nil GraphID")
@@ -35,16 +68,21 @@ func NewViewport(
 
 	return &Viewport{
 		GraphID: graphID,
+		UserID:  userID,
 		Zoom:    1.0,
 		PanX:    0,
 		PanY:    0,
 	}, nil
 }
 
-// Set updates all viewport properties at once and emits a ViewportUpdatedEvent
+// Set updates all viewport properties at once and emits a ViewportUpdatedEvent.
+// The zoom must be in range and the pan offsets finite, otherwise a
+// *ValidationError lists the problems and nothing changes.
 func (v *Viewport) Set(zoom, panX, panY float64) error {
-	if zoom <= 0 {
-		return fmt.Errorf("zoom must be greater than 0, got %f", zoom)
+	errs := &ValidationError{}
+	validateZoomAndPan(errs, zoom, panX, panY)
+	if err := errs.err(); err != nil {
+		return err
 	}
 
 	v.Zoom = zoom
@@ -58,12 +96,99 @@ func (v *Viewport) Set(zoom, panX, panY float64) error {
 	return nil
 }
 
+// Validate checks the bookmark's name, zoom and pan offsets, returning a
+// *ValidationError that lists the problems
+func (b ViewportBookmark) Validate() error {
+	errs := &ValidationError{}
+
+	if strings.TrimSpace(b.Name) == "" {
+		errs.add("name", "cannot be empty")
+	} else if len(b.Name) > maxBookmarkNameLength {
+		errs.add("name", "cannot be longer than %d bytes", maxBookmarkNameLength)
+	}
+
+	validateZoomAndPan(errs, b.Zoom, b.PanX, b.PanY)
+
+	return errs.err()
+}
+
+// validateZoomAndPan adds an error to errs for a zoom out of range or a pan
+// offset that isn't finite
+func validateZoomAndPan(errs *ValidationError, zoom, panX, panY float64) {
+	if math.IsNaN(zoom) || zoom < MinZoom || zoom > MaxZoom {
+		errs.add("zoom", "must be between %g and %g, got %g", MinZoom, MaxZoom, zoom)
+	}
+
+	errs.checkFinite("pan_x", panX)
+	errs.checkFinite("pan_y", panY)
+}
+
+// Bookmark returns the bookmark with the given name
+func (v *Viewport) Bookmark(name string) (ViewportBookmark, bool) {
+	i := slices.IndexFunc(v.Bookmarks, func(b ViewportBookmark) bool { return b.Name == name })
+	if i < 0 {
+		return ViewportBookmark{}, false
+	}
+	return v.Bookmarks[i], true
+}
+
+// SaveBookmark adds a bookmark, or replaces the bookmark with the same name,
+// and emits a ViewportBookmarkSavedEvent. Making a bookmark the default
+// clears the default flag of the others.
+func (v *Viewport) SaveBookmark(bookmark ViewportBookmark) error {
+	if err := bookmark.Validate(); err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(v.Bookmarks, func(b ViewportBookmark) bool { return b.Name == bookmark.Name })
+	if i < 0 && len(v.Bookmarks) >= MaxViewportBookmarks {
+		return fmt.Errorf("cannot have more than %d bookmarks", MaxViewportBookmarks)
+	}
+
+	if bookmark.Default {
+		for j := range v.Bookmarks {
+			v.Bookmarks[j].Default = false
+		}
+	}
+
+	if i < 0 {
+		v.Bookmarks = append(v.Bookmarks, bookmark)
+	} else {
+		v.Bookmarks[i] = bookmark
+	}
+
+	event := NewViewportBookmarkSavedEvent(v, bookmark)
+	event.SetEntity("Viewport", v.GraphID.ID)
+	v.AddEvent(event)
+
+	return nil
+}
+
+// DeleteBookmark removes the bookmark with the given name and emits a
+// ViewportBookmarkDeletedEvent
+func (v *Viewport) DeleteBookmark(name string) error {
+	i := slices.IndexFunc(v.Bookmarks, func(b ViewportBookmark) bool { return b.Name == name })
+	if i < 0 {
+		return fmt.Errorf("bookmark %q does not exist", name)
+	}
+
+	v.Bookmarks = slices.Delete(v.Bookmarks, i, i+1)
+
+	event := NewViewportBookmarkDeletedEvent(v, name)
+	event.SetEntity("Viewport", v.GraphID.ID)
+	v.AddEvent(event)
+
+	return nil
+}
+
 // Clone creates a copy of the Viewport
 func (v *Viewport) Clone() *Viewport {
 	return &Viewport{
-		GraphID: v.GraphID,
-		Zoom:    v.Zoom,
-		PanX:    v.PanX,
-		PanY:    v.PanY,
+		GraphID:   v.GraphID,
+		UserID:    v.UserID,
+		Zoom:      v.Zoom,
+		PanX:      v.PanX,
+		PanY:      v.PanY,
+		Bookmarks: slices.Clone(v.Bookmarks),
 	}
 }
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+)
+
+// handleGetAdminStats reports how many graphs, nodes and images are stored,
+// how much of the image storage no graph refers to, and how many events are
+// waiting to be published
+func (s *HTTPServer) handleGetAdminStats(w http.ResponseWriter, r *http.Request) {
+	// Images are listed before the graphs are read, so that images
+	// referenced in between aren't reported as orphaned
+	var storedImages map[imagegraph.ImageID]int64
+	inventory, hasInventory := s.imageStorage.(filestorage.ImageInventory)
+	if hasInventory {
+		var err error
+		if storedImages, err = inventory.Inventory(); err != nil {
+			s.logger.Error("failed to list stored images", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to collect stats"})
+			return
+		}
+	}
+
+	stats, err := application.CollectStats(r.Context(), s.stats, storedImages)
+	if err != nil {
+		s.logger.Error("failed to collect stats", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to collect stats"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapStatsToResponse(stats, hasInventory))
+}
+
+func mapStatsToResponse(stats application.Stats, hasInventory bool) adminStatsResponse {
+	response := adminStatsResponse{
+		ImageGraphs: stats.ImageGraphs,
+		NodesByType: make(map[string]int, len(stats.NodesByType)),
+		QueueDepth:  stats.QueueDepth,
+	}
+
+	for nodeType, count := range stats.NodesByType {
+		response.NodesByType[imagegraph.NodeTypeMapper.FromWithDefault(nodeType, "unknown")] += count
+	}
+
+	if hasInventory {
+		response.StoredImages = &stats.StoredImages
+		response.StoredImageBytes = &stats.StoredImageBytes
+		response.OrphanedImages = &stats.OrphanedImages
+		response.OrphanedImageBytes = &stats.OrphanedImageBytes
+	}
+
+	return response
+}
@@ -0,0 +1,280 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// APIKeyHeader is the header scripts send their API key in
+const APIKeyHeader = "X-API-Key"
+
+const (
+	// apiKeyPrefix starts every API key, so leaked keys are easy to spot
+	apiKeyPrefix = "awk_"
+
+	// apiKeyDisplayLength is how many characters of a key are kept to tell
+	// keys apart
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+	maxAPIKeyNameLength     = 100
+	defaultAPIKeyRateLimit  = 120
+	maxAPIKeyRateLimit      = 6000
+	maxAPIKeysPerUser       = 50
+	apiKeySecretRandomBytes = 32
+)
+
+// UserDirectory looks up users by ID, so API keys act as the current version
+// of the user that issued them
+type UserDirectory interface {
+	User(id string) (application.User, bool)
+}
+
+// apiKeyAuthenticator authenticates requests that carry an API key as the
+// user that issued it, deferring every other request to next
+type apiKeyAuthenticator struct {
+	keys  application.APIKeyStore
+	users UserDirectory
+	next  Authenticator
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (application.User, error) {
+	secret := r.Header.Get(APIKeyHeader)
+	if secret == "" {
+		return a.next.Authenticate(r)
+	}
+
+	key, err := a.keys.GetBySecretSHA256(r.Context(), hashAPIKeySecret(secret))
+	if err != nil {
+		if errors.Is(err, application.ErrAPIKeyNotFound) {
+			return application.User{}, ErrUnauthenticated
+		}
+		return application.User{}, err
+	}
+
+	// Keys stop working when the user that issued them is removed
+	user, ok := a.users.User(key.UserID)
+	if !ok {
+		return application.User{}, ErrUnauthenticated
+	}
+
+	user.APIKey = &key
+
+	return user, nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// apiKeyLimiters rate limits each API key to the rate it was issued with
+type apiKeyLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+func newAPIKeyLimiters() *apiKeyLimiters {
+	return &apiKeyLimiters{limiters: make(map[string]*rateLimiter)}
+}
+
+func (l *apiKeyLimiters) allow(key *application.APIKey) (bool, time.Duration) {
+	l.mu.Lock()
+	limiter, ok := l.limiters[key.ID]
+	if !ok {
+		limiter = newRateLimiter(key.RateLimit, key.RateLimit)
+		l.limiters[key.ID] = limiter
+	}
+	l.mu.Unlock()
+
+	return limiter.allow(key.ID)
+}
+
+func (l *apiKeyLimiters) forget(keyID string) {
+	l.mu.Lock()
+	delete(l.limiters, keyID)
+	l.mu.Unlock()
+}
+
+// apiKeyMiddleware enforces the rate limit and scopes of requests
+// authenticated with an API key. Reads need the read scope and everything
+// else the write scope. Keys can't manage keys, so a leaked key can't be
+// used to mint more.
+func (s *HTTPServer) apiKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := application.UserFromContext(r.Context())
+		if !ok || user.APIKey == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed, wait := s.apiKeyLimiters.allow(user.APIKey); !allowed {
+			seconds := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			respondJSON(w, http.StatusTooManyRequests, errorResponse{Error: "rate limit exceeded"})
+			return
+		}
+
+		if r.URL.Path == "/api/keys" || strings.HasPrefix(r.URL.Path, "/api/keys/") {
+			respondJSON(w, http.StatusForbidden, errorResponse{Error: "API keys can't manage API keys"})
+			return
+		}
+
+		scope := application.APIKeyScopeWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			scope = application.APIKeyScopeRead
+		}
+
+		if !user.HasScope(scope) {
+			respondJSON(w, http.StatusForbidden, errorResponse{Error: "API key lacks the " + string(scope) + " scope"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *HTTPServer) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, _ := application.UserFromContext(r.Context())
+
+	keys, err := s.apiKeys.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("failed to list API keys", "error", err, "user_id", user.ID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list API keys"})
+		return
+	}
+
+	response := listAPIKeysResponse{Keys: make([]apiKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, mapAPIKeyToResponse(key))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (s *HTTPServer) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, _ := application.UserFromContext(r.Context())
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPIKeyNameLength {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "name is required and may be at most 100 bytes"})
+		return
+	}
+
+	scopes, ok := parseAPIKeyScopes(req.Scopes)
+	if !ok {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "scopes must be a non-empty list of read and write"})
+		return
+	}
+
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultAPIKeyRateLimit
+	}
+	if rateLimit < 1 || rateLimit > maxAPIKeyRateLimit {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "rate_limit must be between 1 and 6000 requests per minute"})
+		return
+	}
+
+	existing, err := s.apiKeys.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		s.logger.Error("failed to list API keys", "error", err, "user_id", user.ID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to create API key"})
+		return
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "too many API keys; revoke one first"})
+		return
+	}
+
+	random := make([]byte, apiKeySecretRandomBytes)
+	if _, err := rand.Read(random); err != nil {
+		s.logger.Error("failed to generate API key", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to create API key"})
+		return
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key := application.APIKey{
+		ID:           uuid.NewString(),
+		UserID:       user.ID,
+		Name:         req.Name,
+		Scopes:       scopes,
+		RateLimit:    rateLimit,
+		SecretSHA256: hashAPIKeySecret(secret),
+		Prefix:       secret[:apiKeyDisplayLength],
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	if err := s.apiKeys.Add(r.Context(), key); err != nil {
+		s.logger.Error("failed to add API key", "error", err, "user_id", user.ID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to create API key"})
+		return
+	}
+
+	// The secret is only ever returned here
+	respondJSON(w, http.StatusCreated, createAPIKeyResponse{
+		apiKeyResponse: mapAPIKeyToResponse(key),
+		Key:            secret,
+	})
+}
+
+func (s *HTTPServer) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, _ := application.UserFromContext(r.Context())
+	keyID := r.PathValue("key_id")
+
+	if err := s.apiKeys.Delete(r.Context(), user.ID, keyID); err != nil {
+		if errors.Is(err, application.ErrAPIKeyNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "API key not found"})
+			return
+		}
+		s.logger.Error("failed to delete API key", "error", err, "user_id", user.ID, "key_id", keyID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to revoke API key"})
+		return
+	}
+
+	s.apiKeyLimiters.forget(keyID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseAPIKeyScopes validates and deduplicates requested scopes
+func parseAPIKeyScopes(requested []string) ([]application.APIKeyScope, bool) {
+	if len(requested) == 0 {
+		return nil, false
+	}
+
+	var scopes []application.APIKeyScope
+	for _, str := range requested {
+		scope := application.APIKeyScope(str)
+		if !slices.Contains(application.AllAPIKeyScopes(), scope) {
+			return nil, false
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, true
+}
@@ -0,0 +1,254 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ErrUnauthenticated is returned by Authenticators when a request carries no
+// valid credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// TokenCookieName is the cookie browsers send their access token in, since
+// image and WebSocket requests can't carry an Authorization header
+const TokenCookieName = "artwork_token"
+
+// Authenticator identifies the user making a request
+type Authenticator interface {
+	Authenticate(r *http.Request) (application.User, error)
+}
+
+// TokenUser is a user who authenticates with an access token. Only the
+// SHA-256 hash of the token is kept, so a users file holds no secrets.
+type TokenUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Admin       bool   `json:"admin"`
+	TokenSHA256 string `json:"token_sha256"`
+}
+
+// TokenAuthenticator authenticates requests by the access token in their
+// Authorization bearer header or token cookie
+type TokenAuthenticator struct {
+	users     map[string]application.User
+	usersByID map[string]application.User
+}
+
+// NewTokenAuthenticator creates a TokenAuthenticator for the users
+func NewTokenAuthenticator(users []TokenUser) (*TokenAuthenticator, error) {
+	a := &TokenAuthenticator{
+		users:     make(map[string]application.User, len(users)),
+		usersByID: make(map[string]application.User, len(users)),
+	}
+
+	for _, u := range users {
+		if u.ID == "" {
+			return nil, fmt.Errorf("user has no ID")
+		}
+		if _, ok := a.usersByID[u.ID]; ok {
+			return nil, fmt.Errorf("user %q is listed more than once", u.ID)
+		}
+
+		hash := strings.ToLower(u.TokenSHA256)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("user %q has an invalid token_sha256", u.ID)
+		}
+		if _, ok := a.users[hash]; ok {
+			return nil, fmt.Errorf("user %q shares a token with another user", u.ID)
+		}
+
+		user := application.User{ID: u.ID, Name: u.Name, Admin: u.Admin}
+		a.users[hash] = user
+		a.usersByID[u.ID] = user
+	}
+
+	return a, nil
+}
+
+// LoadTokenAuthenticator creates a TokenAuthenticator for the users listed
+// in a JSON file of the form {"users": [TokenUser, ...]}
+func LoadTokenAuthenticator(path string) (*TokenAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	var file struct {
+		Users []TokenUser `json:"users"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse users file: %w", err)
+	}
+
+	return NewTokenAuthenticator(file.Users)
+}
+
+// Authenticate returns the user whose token the request carries
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (application.User, error) {
+	token := requestToken(r)
+	if token == "" {
+		return application.User{}, ErrUnauthenticated
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	user, ok := a.users[hex.EncodeToString(hash[:])]
+	if !ok {
+		return application.User{}, ErrUnauthenticated
+	}
+
+	return user, nil
+}
+
+// User returns the user with the given ID
+func (a *TokenAuthenticator) User(id string) (application.User, bool) {
+	user, ok := a.usersByID[id]
+	return user, ok
+}
+
+// requestToken returns the bearer token or token cookie of a request
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	if cookie, err := r.Cookie(TokenCookieName); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// isPublicAPIPath reports whether an API path is served without credentials:
+// the gallery and the description of the API itself
+func isPublicAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/gallery") ||
+		path == "/api/openapi.json" ||
+		path == "/api/docs"
+}
+
+// authMiddleware authenticates API requests, adding the user to the request
+// context. Public API paths and static frontend files carry no private data,
+// so none of them need credentials.
+func authMiddleware(authenticator Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || isPublicAPIPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := authenticator.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondJSON(w, http.StatusUnauthorized, errorResponse{Error: "authentication required"})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(application.ContextWithUser(r.Context(), user)))
+	})
+}
+
+// canAccess reports whether the requesting user may view an image graph.
+// Every request can access every graph when authentication is disabled.
+func canAccess(r *http.Request, ig *imagegraph.ImageGraph) bool {
+	user, ok := application.UserFromContext(r.Context())
+	return !ok || user.CanAccess(ig)
+}
+
+// requestRole returns the requesting user's role on an image graph, or
+// RoleNone when authentication is disabled
+func requestRole(r *http.Request, ig *imagegraph.ImageGraph) imagegraph.Role {
+	user, ok := application.UserFromContext(r.Context())
+	if !ok {
+		return imagegraph.RoleNone
+	}
+	return user.Role(ig)
+}
+
+// requestSummaryRole returns the role the requesting user has on a listed
+// graph
+func requestSummaryRole(r *http.Request, summary application.ImageGraphSummary) imagegraph.Role {
+	user, ok := application.UserFromContext(r.Context())
+	if !ok {
+		return imagegraph.RoleNone
+	}
+	return user.SummaryRole(summary)
+}
+
+// authorizeGraph wraps the handler of an /api/imagegraphs/{id}/... route,
+// requiring the requesting user to have at least the given role on the
+// graph. Users without any role are answered as if the graph doesn't exist.
+func (s *HTTPServer) authorizeGraph(role imagegraph.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := application.UserFromContext(r.Context())
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		// Invalid IDs are reported by the handler
+		imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+		if err != nil {
+			next(w, r)
+			return
+		}
+
+		ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+		if err != nil && !errors.Is(err, application.ErrImageGraphNotFound) {
+			s.logger.Error("failed to get image graph", "error", err, "image_graph_id", imageGraphID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+			return
+		}
+
+		if err == nil {
+			userRole := user.Role(ig)
+			if userRole == imagegraph.RoleNone {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			if userRole < role {
+				respondJSON(w, http.StatusForbidden, errorResponse{Error: "permission denied"})
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// authorizeAdmin wraps the handler of an /api/admin/... route, requiring the
+// requesting user to be an admin when authentication is enabled
+func authorizeAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := application.UserFromContext(r.Context()); ok && !user.Admin {
+			respondJSON(w, http.StatusForbidden, errorResponse{Error: "permission denied"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// handleGetCurrentUser reports who the request is authenticated as
+func (s *HTTPServer) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := application.UserFromContext(r.Context())
+	if !ok {
+		respondJSON(w, http.StatusOK, currentUserResponse{})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, currentUserResponse{
+		AuthEnabled: true,
+		ID:          user.ID,
+		Name:        user.Name,
+		Admin:       user.Admin,
+	})
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// busyRejectionMiddleware marks the commands of API requests to be rejected
+// rather than wait when their image graph's command queue is full
+func busyRejectionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(application.RejectWhenBusy(r.Context())))
+	})
+}
+
+// respondGraphBusy responds with 503 Service Unavailable and a Retry-After
+// header if a command was rejected because its image graph's command queue
+// was full, reporting whether it did
+func (s *HTTPServer) respondGraphBusy(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, application.ErrImageGraphBusy) {
+		return false
+	}
+
+	seconds := int(math.Ceil(s.busyRetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	respondJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "image graph is busy, retry later"})
+
+	return true
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+func TestRespondGraphBusy(t *testing.T) {
+	s := &HTTPServer{busyRetryAfter: 1500 * time.Millisecond}
+
+	w := httptest.NewRecorder()
+	if s.respondGraphBusy(w, errors.New("failed")) {
+		t.Fatal("expected other errors to be left to the handler")
+	}
+
+	err := fmt.Errorf("could not set output: %w", application.ErrImageGraphBusy)
+	if !s.respondGraphBusy(w, err) {
+		t.Fatal("expected a busy graph to be responded to")
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After rounded up to 2, got %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// imageGraphETag identifies a version of a graph as the requesting user sees
+// it in a view. Every change to a graph bumps its version, and the response
+// carries the user's role on it. It's weak because nodes are listed in no
+// particular order, so the same version isn't always encoded byte for byte
+// the same.
+func imageGraphETag(ig *imagegraph.ImageGraph, role imagegraph.Role, view string) string {
+	return fmt.Sprintf(`W/"%d-%d-%s"`, ig.Version, role, view)
+}
+
+// notModified sets the response's ETag and reports whether the request's
+// If-None-Match already names it, in which case it responds with 304
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header names the ETag, using
+// the weak comparison that If-None-Match calls for
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,26 @@
+package http
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	etag := `W/"3-2"`
+
+	tests := []struct {
+		ifNoneMatch string
+		matches     bool
+	}{
+		{`W/"3-2"`, true},
+		{`"3-2"`, true},
+		{`"2-2", W/"3-2"`, true},
+		{`*`, true},
+		{`W/"3-1"`, false},
+		{`W/"4-2"`, false},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, etag); got != tt.matches {
+			t.Errorf("etagMatches(%q) = %v, expected %v", tt.ifNoneMatch, got, tt.matches)
+		}
+	}
+}
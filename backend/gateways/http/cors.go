@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures which cross-origin browser frontends may call the
+// API. Requests from listed origins may carry the token cookie; the "*"
+// origin allows any origin, but without credentials.
+type CORSConfig struct {
+	AllowedOrigins []string
+
+	// Defaults to GET, POST, PUT, PATCH and DELETE when empty
+	AllowedMethods []string
+
+	// Defaults to Content-Type, Authorization, X-API-Key, X-Request-ID and
+	// If-None-Match when empty
+	AllowedHeaders []string
+
+	// How long browsers may cache preflight responses, 0 for the browser
+	// default
+	MaxAge time.Duration
+}
+
+// corsExposedHeaders are the response headers cross-origin frontends may read
+var corsExposedHeaders = []string{"Retry-After", "X-Request-ID", "ETag"}
+
+func (c CORSConfig) withDefaults() CORSConfig {
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = []string{
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		}
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = []string{"Content-Type", "Authorization", APIKeyHeader, "X-Request-ID", "If-None-Match"}
+	}
+	return c
+}
+
+// allowsAnyOrigin reports whether the "*" origin is configured
+func (c CORSConfig) allowsAnyOrigin() bool {
+	return slices.Contains(c.AllowedOrigins, "*")
+}
+
+// websocketOriginPatterns returns the host patterns WebSocket upgrades
+// accept, matching the allowed origins
+func (c CORSConfig) websocketOriginPatterns() []string {
+	var patterns []string
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			return []string{"*"}
+		}
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			patterns = append(patterns, u.Host)
+		}
+	}
+	return patterns
+}
+
+// corsMiddleware adds CORS headers to responses for allowed origins and
+// answers their preflight requests. It runs before authentication since
+// preflight requests carry no credentials.
+func corsMiddleware(config CORSConfig, next http.Handler) http.Handler {
+	config = config.withDefaults()
+	anyOrigin := config.allowsAnyOrigin()
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(corsExposedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		listed := slices.Contains(config.AllowedOrigins, origin)
+
+		if !listed && !anyOrigin {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if listed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", exposed)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+		if config.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// handleListDeadLetters lists the events whose handlers kept failing, newest
+// first
+func (s *HTTPServer) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultListLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", maxListLimit)})
+			return
+		}
+	}
+
+	letters, err := s.deadLetters.List(r.Context(), limit)
+	if err != nil {
+		s.logger.Error("failed to list dead letters", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list dead letters"})
+		return
+	}
+
+	response := listDeadLettersResponse{DeadLetters: make([]deadLetterResponse, 0, len(letters))}
+	for _, letter := range letters {
+		letterResponse, err := mapDeadLetterToResponse(letter)
+		if err != nil {
+			s.logger.Error("failed to map dead letter", "error", err, "dead_letter_id", letter.ID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list dead letters"})
+			return
+		}
+		response.DeadLetters = append(response.DeadLetters, letterResponse)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (s *HTTPServer) handleGetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("dead_letter_id"), 10, 64)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid dead letter ID"})
+		return
+	}
+
+	letter, err := s.deadLetters.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, application.ErrDeadLetterNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "dead letter not found"})
+			return
+		}
+		s.logger.Error("failed to get dead letter", "error", err, "dead_letter_id", id)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get dead letter"})
+		return
+	}
+
+	response, err := mapDeadLetterToResponse(letter)
+	if err != nil {
+		s.logger.Error("failed to map dead letter", "error", err, "dead_letter_id", id)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get dead letter"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleReplayDeadLetter hands a dead letter's event to the handler that
+// failed it again. The dead letter is removed if the handler succeeds, and
+// otherwise kept with the new error, which is reported with 409 Conflict.
+func (s *HTTPServer) handleReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("dead_letter_id"), 10, 64)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid dead letter ID"})
+		return
+	}
+
+	if err := s.deadLetters.Replay(r.Context(), id); err != nil {
+		if errors.Is(err, application.ErrDeadLetterNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "dead letter not found"})
+			return
+		}
+		if errors.Is(err, application.ErrDeadLetterReplayFailed) {
+			respondJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+			return
+		}
+		s.logger.Error("failed to replay dead letter", "error", err, "dead_letter_id", id)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to replay dead letter"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDiscardDeadLetter removes a dead letter without handling its event
+func (s *HTTPServer) handleDiscardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("dead_letter_id"), 10, 64)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid dead letter ID"})
+		return
+	}
+
+	if err := s.deadLetters.Discard(r.Context(), id); err != nil {
+		if errors.Is(err, application.ErrDeadLetterNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "dead letter not found"})
+			return
+		}
+		s.logger.Error("failed to discard dead letter", "error", err, "dead_letter_id", id)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to discard dead letter"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func mapDeadLetterToResponse(letter application.DeadLetter) (deadLetterResponse, error) {
+	payload, err := json.Marshal(letter.Event)
+	if err != nil {
+		return deadLetterResponse{}, fmt.Errorf("failed to marshal %s event: %w", letter.Event.GetType(), err)
+	}
+
+	return deadLetterResponse{
+		ID:            letter.ID,
+		Handler:       letter.Handler,
+		EventType:     letter.Event.GetType(),
+		EntityType:    letter.Event.GetEntityType(),
+		EntityID:      letter.Event.GetEntityID().String(),
+		Payload:       payload,
+		Error:         letter.Error,
+		Attempts:      letter.Attempts,
+		FirstFailedAt: letter.FirstFailedAt,
+		LastFailedAt:  letter.LastFailedAt,
+	}, nil
+}
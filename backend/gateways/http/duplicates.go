@@ -0,0 +1,206 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/domain/ui"
+)
+
+// defaultDuplicateOffset is how far a copy in the same graph is placed from
+// the node it copies, so that it doesn't hide it. Copies into another graph
+// are placed where the node is.
+var defaultDuplicateOffset = positionOffset{X: 40, Y: 40}
+
+// handleDuplicateNode adds a copy of a node, with its type, name, config and
+// added inputs but none of its connections, to the same graph or the graph
+// named by target_graph_id. The copy is placed at the node's position plus an
+// offset in the requesting user's layout of that graph.
+func (s *HTTPServer) handleDuplicateNode(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	var req duplicateNodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	targetGraphID := imageGraphID
+	if req.TargetGraphID != "" {
+		if targetGraphID, err = imagegraph.ParseImageGraphID(req.TargetGraphID); err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid target image graph ID"})
+			return
+		}
+	}
+
+	offset := positionOffset{}
+	if targetGraphID == imageGraphID {
+		offset = defaultDuplicateOffset
+	}
+	if req.Offset != nil {
+		offset = *req.Offset
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to duplicate node"})
+		return
+	}
+
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	if !s.authorizeDuplicateTarget(w, r, targetGraphID) {
+		return
+	}
+
+	config, err := copyNodeConfig(node)
+	if err != nil {
+		s.logger.Error("failed to copy node config", "error", err, "id", imageGraphID, "node_id", nodeID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to duplicate node"})
+		return
+	}
+
+	copyID, err := s.idGenerator.NewNodeID()
+	if err != nil {
+		s.logger.Error("failed to generate node ID", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to duplicate node"})
+		return
+	}
+
+	command := application.NewAddImageGraphNodeCommand(targetGraphID, copyID, node.Type, node.Name, config, "")
+	// Inputs added to the node are added to the copy too
+	for _, name := range node.InputNames() {
+		if !slices.Contains(imagegraph.NodeTypeDefs[node.Type].Inputs, name) {
+			command.Inputs = append(command.Inputs, name)
+		}
+	}
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "target image graph not found"})
+			return
+		}
+		if errors.Is(err, application.ErrGraphLimitExceeded) {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "image graph node limit reached"})
+			return
+		}
+		s.logger.Error("failed to duplicate node", "error", err, "id", imageGraphID, "node_id", nodeID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to duplicate node"})
+		return
+	}
+
+	if err := s.layOutDuplicate(r, imageGraphID, nodeID, targetGraphID, copyID, offset); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		s.logger.Error("failed to lay out duplicate", "error", err, "id", targetGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update layout"})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, duplicateNodeResponse{ID: copyID.String(), GraphID: targetGraphID.String()})
+}
+
+// authorizeDuplicateTarget checks that the requesting user can edit the graph
+// a node is copied into, responding with an error if not
+func (s *HTTPServer) authorizeDuplicateTarget(w http.ResponseWriter, r *http.Request, targetGraphID imagegraph.ImageGraphID) bool {
+	target, err := s.imageGraphViews.Get(r.Context(), targetGraphID)
+	if err == nil && !canAccess(r, target) {
+		err = application.ErrImageGraphNotFound
+	}
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "target image graph not found"})
+			return false
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", targetGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to duplicate node"})
+		return false
+	}
+
+	if _, ok := application.UserFromContext(r.Context()); ok && requestRole(r, target) < imagegraph.RoleEditor {
+		respondJSON(w, http.StatusForbidden, errorResponse{Error: "permission denied"})
+		return false
+	}
+
+	return true
+}
+
+// copyNodeConfig returns a copy of a node's config that shares nothing with it
+func copyNodeConfig(node *imagegraph.Node) (imagegraph.NodeConfig, error) {
+	data, err := json.Marshal(node.Config)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config of node %q: %w", node.ID, err)
+	}
+
+	config := imagegraph.NewNodeConfig(node.Type)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not copy config of node %q: %w", node.ID, err)
+	}
+
+	return config, nil
+}
+
+// layOutDuplicate places the copy of a node at the node's position plus the
+// offset, with the node's size and color, in the requesting user's layout of
+// the graph the copy was added to
+func (s *HTTPServer) layOutDuplicate(
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	targetGraphID imagegraph.ImageGraphID,
+	copyID imagegraph.NodeID,
+	offset positionOffset,
+) error {
+	source, err := s.getUserNodeLayouts(r.Context(), imageGraphID)
+	if err != nil {
+		return err
+	}
+
+	var copyLayout ui.NodeLayout
+	if i := slices.IndexFunc(source, func(nl ui.NodeLayout) bool { return nl.NodeID == nodeID }); i >= 0 {
+		copyLayout = source[i]
+	}
+	copyLayout.NodeID = copyID
+	copyLayout.X += offset.X
+	copyLayout.Y += offset.Y
+
+	current, err := s.getUserNodeLayouts(r.Context(), targetGraphID)
+	if err != nil {
+		return err
+	}
+
+	user, _ := application.UserFromContext(r.Context())
+	command := application.NewUpdateLayoutCommand(targetGraphID, user.ID, append(current, copyLayout))
+
+	return s.messageBus.HandleCommand(r.Context(), command)
+}
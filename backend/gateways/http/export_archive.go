@@ -0,0 +1,162 @@
+package http
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// imageOpener is implemented by image storages that can stream images
+// rather than returning them fully read into memory
+type imageOpener interface {
+	Open(imageID imagegraph.ImageID) (io.ReadCloser, error)
+}
+
+// imageExtensions maps sniffed image content types to archive file extensions
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// handleDownloadExportsArchive streams a ZIP of the graph's exported Output
+// node images. Images are read from storage and written to the response one
+// at a time, so the archive is never held in memory.
+func (s *HTTPServer) handleDownloadExportsArchive(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": archiveFileName(ig.Name, "exports") + ".zip",
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are sent, so failures from here on can only cut the archive
+	// short; clients see a truncated ZIP rather than an error response
+	archive := zip.NewWriter(w)
+	usedNames := make(map[string]int)
+
+	for _, export := range ig.Exports() {
+		if err := s.writeArchiveImage(archive, export, usedNames); err != nil {
+			s.logger.Error("failed to write export to archive",
+				"error", err, "id", imageGraphID, "image_id", export.ImageID)
+			return
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		s.logger.Error("failed to finish export archive", "error", err, "id", imageGraphID)
+	}
+}
+
+// writeArchiveImage adds an exported image to the archive, naming it after
+// its export with an extension sniffed from the image content
+func (s *HTTPServer) writeArchiveImage(
+	archive *zip.Writer,
+	export imagegraph.Export,
+	usedNames map[string]int,
+) error {
+	src, err := s.openImage(export.ImageID)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	buffered := bufio.NewReader(src)
+	head, err := buffered.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not read image: %w", err)
+	}
+
+	ext, ok := imageExtensions[http.DetectContentType(head)]
+	if !ok {
+		ext = ".bin"
+	}
+
+	name := archiveFileName(export.Name, "output")
+	usedNames[name]++
+	if n := usedNames[name]; n > 1 {
+		name = fmt.Sprintf("%s-%d", name, n)
+	}
+
+	// Images are already compressed, so they're stored as is
+	dst, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name + ext,
+		Method:   zip.Store,
+		Modified: export.GeneratedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("could not add %q to archive: %w", name+ext, err)
+	}
+
+	if _, err := io.Copy(dst, buffered); err != nil {
+		return fmt.Errorf("could not add %q to archive: %w", name+ext, err)
+	}
+
+	return nil
+}
+
+// openImage streams the image from storage when the storage supports it
+func (s *HTTPServer) openImage(imageID imagegraph.ImageID) (io.ReadCloser, error) {
+	if opener, ok := s.imageStorage.(imageOpener); ok {
+		return opener.Open(imageID)
+	}
+
+	data, err := s.imageStorage.Get(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// archiveFileName turns a node or graph name into a portable file name,
+// replacing path separators and other unsafe characters
+func archiveFileName(name string, fallback string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return r
+		case r == '-', r == '_', r == '.':
+			return r
+		case r == ' ':
+			return '_'
+		default:
+			return -1
+		}
+	}, strings.TrimSpace(name))
+
+	safe = strings.Trim(safe, ".")
+	if safe == "" {
+		return fallback
+	}
+
+	return safe
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// frontendHandler serves the frontend's files. Page routes the frontend
+// handles itself, which have no file of their own, are answered with
+// index.html so that they can be loaded and reloaded directly. API paths
+// and missing assets are left to 404.
+func frontendHandler(frontend fs.FS) http.Handler {
+	files := http.FileServerFS(frontend)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isFrontendRoute(frontend, r) {
+			http.ServeFileFS(w, r, frontend, "index.html")
+			return
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+// isFrontendRoute reports whether a request is for a page of the frontend
+// rather than one of its files
+func isFrontendRoute(frontend fs.FS, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" || path.Ext(name) != "" {
+		return false
+	}
+
+	_, err := fs.Stat(frontend, name)
+	return errors.Is(err, fs.ErrNotExist)
+}
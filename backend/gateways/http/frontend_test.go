@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFrontendHandler(t *testing.T) {
+	handler := frontendHandler(fstest.MapFS{
+		"index.html":   {Data: []byte("<html>app</html>")},
+		"gallery.html": {Data: []byte("<html>gallery</html>")},
+		"js/main.js":   {Data: []byte("console.log('app')")},
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/", http.StatusOK, "<html>app</html>"},
+		{http.MethodGet, "/gallery.html", http.StatusOK, "<html>gallery</html>"},
+		{http.MethodGet, "/js/main.js", http.StatusOK, "console.log('app')"},
+		{http.MethodGet, "/graphs/123", http.StatusOK, "<html>app</html>"},
+		{http.MethodHead, "/graphs/123", http.StatusOK, ""},
+		{http.MethodGet, "/js/missing.js", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/unknown", http.StatusNotFound, ""},
+		{http.MethodPost, "/graphs/123", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+			if tt.body != "" && !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("expected %q, got %q", tt.body, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// The gallery exposes the Output node images of public image graphs through
+// a small read-only API. Graph structure is never included in its responses.
+
+func (s *HTTPServer) handleGalleryIndex(w http.ResponseWriter, r *http.Request) {
+	imageGraphs, err := s.imageGraphViews.ListPublic(r.Context())
+	if err != nil {
+		s.logger.Error("failed to list public image graphs", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list gallery"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapGalleryIndexToResponse(imageGraphs))
+}
+
+func (s *HTTPServer) handleGetGalleryGraph(w http.ResponseWriter, r *http.Request) {
+	ig, ok := s.getPublicImageGraph(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapGalleryGraphToResponse(ig))
+}
+
+func (s *HTTPServer) handleGetGalleryImage(w http.ResponseWriter, r *http.Request) {
+	ig, ok := s.getPublicImageGraph(w, r)
+	if !ok {
+		return
+	}
+
+	imageID, err := imagegraph.ParseImageID(r.PathValue("image_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image ID"})
+		return
+	}
+
+	// Only the images of Output nodes are published
+	published := false
+	for _, image := range galleryImages(ig) {
+		if image.imageID == imageID {
+			published = true
+			break
+		}
+	}
+
+	if !published {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image not found"})
+		return
+	}
+
+	s.serveImage(w, imageID)
+}
+
+// getPublicImageGraph loads the image graph named in the request path,
+// responding with 404 unless it exists and is public
+func (s *HTTPServer) getPublicImageGraph(
+	w http.ResponseWriter,
+	r *http.Request,
+) (
+	*imagegraph.ImageGraph,
+	bool,
+) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return nil, false
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "gallery not found"})
+			return nil, false
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve gallery"})
+		return nil, false
+	}
+
+	if !ig.Public {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "gallery not found"})
+		return nil, false
+	}
+
+	return ig, true
+}
@@ -0,0 +1,254 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+)
+
+func (s *HTTPServer) handleListGraphLinks(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	links, err := s.graphLinks.ListByImageGraph(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to list graph links", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list graph links"})
+		return
+	}
+
+	response := listGraphLinksResponse{Links: make([]graphLinkResponse, 0, len(links))}
+	for _, link := range links {
+		response.Links = append(response.Links, mapGraphLinkToResponse(link))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleCreateGraphLink links an Input node to a node output of another
+// graph the caller can view. The Input node is set to a copy of the
+// output's current image, if it has one, and then to a copy of each image
+// the output is set to.
+func (s *HTTPServer) handleCreateGraphLink(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req createGraphLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(req.NodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	sourceGraphID, err := imagegraph.ParseImageGraphID(req.SourceImageGraphID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid source image graph ID"})
+		return
+	}
+
+	sourceNodeID, err := imagegraph.ParseNodeID(req.SourceNodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid source node ID"})
+		return
+	}
+
+	// Nodes of the same graph are connected rather than linked
+	if sourceGraphID == imageGraphID {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "source must be a node of another image graph"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+	if node.Type != imagegraph.NodeTypeInput {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "only input nodes can be linked"})
+		return
+	}
+
+	existing, err := s.graphLinks.ListByImageGraph(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to list graph links", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return
+	}
+	if slices.ContainsFunc(existing, func(link application.GraphLink) bool { return link.TargetNodeID == nodeID }) {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "node is already linked; remove its link first"})
+		return
+	}
+
+	source, err := s.imageGraphViews.Get(r.Context(), sourceGraphID)
+	if err != nil && !errors.Is(err, application.ErrImageGraphNotFound) {
+		s.logger.Error("failed to get image graph", "error", err, "id", sourceGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return
+	}
+
+	// Graphs the caller can't view are reported as missing, as they are
+	// by the graph endpoints
+	user, authenticated := application.UserFromContext(r.Context())
+	if err != nil || (authenticated && !user.CanAccess(source)) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "source image graph not found"})
+		return
+	}
+
+	sourceNode, exists := source.Nodes[sourceNodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "source node not found"})
+		return
+	}
+
+	sourceOutput := imagegraph.OutputName(req.SourceOutputName)
+	if !sourceNode.HasOutput(sourceOutput) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "source output not found"})
+		return
+	}
+
+	cycle, err := application.GraphLinkCreatesCycle(r.Context(), s.graphLinks, sourceGraphID, imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to check graph links for cycles", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return
+	}
+	if cycle {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "link would feed the source graph's own output back into it"})
+		return
+	}
+
+	if imageID, err := sourceNode.Outputs.GetImage(sourceOutput); err == nil && !imageID.IsNil() {
+		if !s.setLinkedImage(w, r, imageGraphID, nodeID, imageID) {
+			return
+		}
+	}
+
+	link := application.GraphLink{
+		ID:                 uuid.NewString(),
+		SourceImageGraphID: sourceGraphID,
+		SourceNodeID:       sourceNodeID,
+		SourceOutputName:   sourceOutput,
+		TargetImageGraphID: imageGraphID,
+		TargetNodeID:       nodeID,
+		CreatedAt:          time.Now().UTC(),
+	}
+
+	if err := s.graphLinks.Add(r.Context(), link); err != nil {
+		s.logger.Error("failed to add graph link", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, mapGraphLinkToResponse(link))
+}
+
+// setLinkedImage sets a newly linked Input node to a copy of the source
+// output's current image, responding with an error and returning false if
+// it fails
+func (s *HTTPServer) setLinkedImage(
+	w http.ResponseWriter,
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	sourceImageID imagegraph.ImageID,
+) bool {
+	imageData, err := s.imageStorage.Get(sourceImageID)
+	if err != nil {
+		s.logger.Error("failed to get image from storage", "error", err, "image_id", sourceImageID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return false
+	}
+
+	upload, err := s.inspectUpload(imageData)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return false
+	}
+
+	imageID := imagegraph.MustNewImageID()
+
+	if err := filestorage.CopyImage(s.imageStorage, sourceImageID, imageID); err != nil {
+		s.logger.Error("failed to copy image", "error", err, "image_id", sourceImageID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return false
+	}
+
+	command := application.NewSetImageGraphNodeOutputImageCommand(
+		imageGraphID,
+		nodeID,
+		"original",
+		imageID,
+		0, // allow command handler to resolve to current node version
+	)
+	command.Upload = &upload
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return false
+		}
+		s.logger.Error("failed to handle SetImageGraphNodeOutputImageCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to link node"})
+		return false
+	}
+
+	return true
+}
+
+func (s *HTTPServer) handleDeleteGraphLink(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	linkID := r.PathValue("link_id")
+
+	// Graph link IDs are UUIDs, so anything else can't be one
+	if _, err := uuid.Parse(linkID); err != nil {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "graph link not found"})
+		return
+	}
+
+	if err := s.graphLinks.Delete(r.Context(), imageGraphID, linkID); err != nil {
+		if errors.Is(err, application.ErrGraphLinkNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "graph link not found"})
+			return
+		}
+		s.logger.Error("failed to delete graph link", "error", err, "id", imageGraphID, "link_id", linkID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to remove graph link"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
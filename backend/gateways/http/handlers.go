@@ -1,14 +1,25 @@
 package http
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/domain/ui"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
 )
 
 func (s *HTTPServer) handleGetNodeTypeSchemas(w http.ResponseWriter, r *http.Request) {
@@ -18,22 +29,50 @@ func (s *HTTPServer) handleGetNodeTypeSchemas(w http.ResponseWriter, r *http.Req
 }
 
 func (s *HTTPServer) handleListImageGraphs(w http.ResponseWriter, r *http.Request) {
-	imageGraphs, err := s.imageGraphViews.List(r.Context())
+	opts, err := parseListImageGraphsOptions(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	// Users list the graphs they own or that are shared with them; admins
+	// list every graph
+	if user, ok := application.UserFromContext(r.Context()); ok && !user.Admin {
+		opts.AccessibleTo = user.ID
+	}
+
+	page, err := s.imageGraphViews.List(r.Context(), opts)
 	if err != nil {
 		s.logger.Error("failed to list image graphs", "error", err)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list image graphs"})
 		return
 	}
 
-	summaries := make([]imageGraphSummary, 0, len(imageGraphs))
-	for _, ig := range imageGraphs {
-		summaries = append(summaries, imageGraphSummary{
-			ID:   ig.ID.String(),
-			Name: ig.Name,
-		})
+	summaries := make([]imageGraphSummary, 0, len(page.ImageGraphs))
+	for _, summary := range page.ImageGraphs {
+		response := imageGraphSummary{
+			ID:        summary.ID.String(),
+			Name:      summary.Name,
+			Owner:     summary.Owner,
+			Role:      requestSummaryRole(r, summary),
+			Public:    summary.Public,
+			Tags:      summary.Tags,
+			NodeCount: summary.NodeCount,
+			CreatedAt: summary.CreatedAt,
+			UpdatedAt: summary.UpdatedAt,
+		}
+		if !summary.ThumbnailImageID.IsNil() {
+			response.ThumbnailImageID = summary.ThumbnailImageID.String()
+		}
+		summaries = append(summaries, response)
 	}
 
-	respondJSON(w, http.StatusOK, listImageGraphsResponse{ImageGraphs: summaries})
+	respondJSON(w, http.StatusOK, listImageGraphsResponse{
+		ImageGraphs: summaries,
+		Total:       page.Total,
+		Limit:       opts.Limit,
+		Offset:      opts.Offset,
+	})
 }
 
 func (s *HTTPServer) handleCreateImageGraph(w http.ResponseWriter, r *http.Request) {
@@ -45,10 +84,29 @@ func (s *HTTPServer) handleCreateImageGraph(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	imageGraphID := imagegraph.MustNewImageGraphID()
-	command := application.NewCreateImageGraphCommand(imageGraphID, req.Name)
+	// Creating a graph with an external ID that's already in use returns the
+	// existing graph, so clients can safely retry creates
+	if req.ExternalID != "" {
+		if s.respondExistingImageGraph(w, r, req.ExternalID) {
+			return
+		}
+	}
+
+	imageGraphID, err := s.idGenerator.NewImageGraphID()
+	if err != nil {
+		s.logger.Error("failed to generate image graph ID", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to create image graph"})
+		return
+	}
+
+	user, _ := application.UserFromContext(r.Context())
+	command := application.NewCreateImageGraphCommand(imageGraphID, req.Name, req.ExternalID, user.ID)
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		// Another request created the graph after the lookup above
+		if errors.Is(err, application.ErrDuplicateExternalID) && s.respondExistingImageGraph(w, r, req.ExternalID) {
+			return
+		}
 		s.logger.Error("failed to handle CreateImageGraphCommand", "error", err)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to create image graph"})
 		return
@@ -57,6 +115,65 @@ func (s *HTTPServer) handleCreateImageGraph(w http.ResponseWriter, r *http.Reque
 	respondJSON(w, http.StatusCreated, createImageGraphResponse{ID: imageGraphID.String()})
 }
 
+// respondExistingImageGraph responds with the ID of the image graph with the
+// given external ID, returning false without responding if there isn't one
+func (s *HTTPServer) respondExistingImageGraph(w http.ResponseWriter, r *http.Request, externalID string) bool {
+	ig, err := s.imageGraphViews.GetByExternalID(r.Context(), externalID)
+	if err != nil {
+		if !errors.Is(err, application.ErrImageGraphNotFound) {
+			s.logger.Error("failed to get image graph by external ID", "error", err, "external_id", externalID)
+		}
+		return false
+	}
+
+	// Don't reveal another user's graph
+	if !canAccess(r, ig) {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "external ID is already in use"})
+		return true
+	}
+
+	respondJSON(w, http.StatusOK, createImageGraphResponse{ID: ig.ID.String()})
+	return true
+}
+
+// Views of a graph that are returned for ?view=, full by default. The summary
+// view lists only the ID, name, type, state and preview of each node, for
+// list-like UIs.
+const (
+	imageGraphViewFull    = "full"
+	imageGraphViewSummary = "summary"
+)
+
+// respondImageGraph responds with the graph in the requested view, or 304 if
+// the request's If-None-Match names the ETag of that view
+func (s *HTTPServer) respondImageGraph(w http.ResponseWriter, r *http.Request, ig *imagegraph.ImageGraph) {
+	view := r.URL.Query().Get("view")
+	if view == "" {
+		view = imageGraphViewFull
+	}
+	if view != imageGraphViewFull && view != imageGraphViewSummary {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "view must be full or summary"})
+		return
+	}
+
+	role := requestRole(r, ig)
+	if notModified(w, r, imageGraphETag(ig, role, view)) {
+		return
+	}
+
+	if view == imageGraphViewSummary {
+		response := mapImageGraphToSummaryViewResponse(ig, s.graphLimits)
+		response.Role = role
+		respondJSON(w, http.StatusOK, response)
+		return
+	}
+
+	response := mapImageGraphToResponse(ig, s.graphLimits)
+	response.Role = role
+
+	respondJSON(w, http.StatusOK, response)
+}
+
 func (s *HTTPServer) handleGetImageGraph(w http.ResponseWriter, r *http.Request) {
 	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
@@ -75,330 +192,1394 @@ func (s *HTTPServer) handleGetImageGraph(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, mapImageGraphToResponse(ig))
+	s.respondImageGraph(w, r, ig)
 }
 
-func (s *HTTPServer) handleAddNode(w http.ResponseWriter, r *http.Request) {
-	imageGraphIDStr := r.PathValue("id")
+// handleGetFullImageGraph returns everything the editor loads for a graph in
+// one response
+func (s *HTTPServer) handleGetFullImageGraph(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
 
-	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	layout, err := s.getLayoutResponse(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to get layout", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve layout"})
+		return
+	}
+
+	viewport, err := s.getViewportResponse(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to get viewport", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve viewport"})
+		return
+	}
+
+	graph := mapImageGraphToResponse(ig, s.graphLimits)
+	graph.Role = requestRole(r, ig)
+
+	respondJSON(w, http.StatusOK, fullImageGraphResponse{
+		ImageGraph: graph,
+		Layout:     layout,
+		Viewport:   viewport,
+		NodeTypes:  buildNodeTypeSchemas(),
+	})
+}
+
+func (s *HTTPServer) handleGetImageGraphByExternalID(w http.ResponseWriter, r *http.Request) {
+	externalID := r.URL.Query().Get("external_id")
+	if externalID == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "external_id is required"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.GetByExternalID(r.Context(), externalID)
+	if err == nil && !canAccess(r, ig) {
+		err = application.ErrImageGraphNotFound
+	}
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph by external ID", "error", err, "external_id", externalID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	s.respondImageGraph(w, r, ig)
+}
+
+func (s *HTTPServer) handleGetNodeByExternalID(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
 		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	var req addNodeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.logger.Error("failed to parse request body", "error", err)
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+	externalID := r.URL.Query().Get("external_id")
+	if externalID == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "external_id is required"})
 		return
 	}
 
-	if req.Type == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "type is required"})
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
 		return
 	}
-	if req.Config == nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "config is required"})
+
+	node, ok := ig.Nodes.FindByExternalID(externalID)
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
 		return
 	}
 
-	nodeType, err := imagegraph.NodeTypeMapper.To(req.Type)
+	respondJSON(w, http.StatusOK, mapNodeToResponse(node, ig.Seed))
+}
 
+func (s *HTTPServer) handleSetImageGraphPublic(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
-		s.logger.Error("failed to parse request body", "error", err)
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node type"})
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	config := imagegraph.NewNodeConfig(nodeType)
-	if err := json.Unmarshal(req.Config, config); err != nil {
-		s.logger.Error("failed to parse config", "error", err)
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid config"})
+	var req setImageGraphPublicRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
 		return
 	}
 
-	nodeID := imagegraph.MustNewNodeID()
+	if req.Public == nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "public is required"})
+		return
+	}
 
-	command := application.NewAddImageGraphNodeCommand(
-		imageGraphID,
-		nodeID,
-		nodeType,
-		req.Name,
-		config,
-	)
+	command := application.NewSetImageGraphPublicCommand(imageGraphID, *req.Public)
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
 		if errors.Is(err, application.ErrImageGraphNotFound) {
 			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
 			return
 		}
-		s.logger.Error("failed to handle AddImageGraphNodeCommand", "error", err)
-		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to add node"})
+		s.logger.Error("failed to handle SetImageGraphPublicCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update image graph"})
 		return
 	}
 
-	respondJSON(w, http.StatusCreated, addNodeResponse{ID: nodeID.String()})
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *HTTPServer) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
-	imageGraphIDStr := r.PathValue("id")
-
-	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+func (s *HTTPServer) handleSetColorManagement(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
 		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	nodeIDStr := r.PathValue("node_id")
+	var req setColorManagementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
 
-	nodeID, err := imagegraph.ParseNodeID(nodeIDStr)
-	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+	if err := imagegraph.ValidateColorManagement(req.ColorManagement); err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
 		return
 	}
 
-	command := application.NewRemoveImageGraphNodeCommand(imageGraphID, nodeID)
+	command := application.NewSetImageGraphColorManagementCommand(imageGraphID, req.ColorManagement)
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
 		if errors.Is(err, application.ErrImageGraphNotFound) {
 			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
 			return
 		}
-		s.logger.Error("failed to handle RemoveImageGraphNodeCommand", "error", err)
-		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to delete node"})
+		s.logger.Error("failed to handle SetImageGraphColorManagementCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update image graph"})
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *HTTPServer) handleConnectNodes(w http.ResponseWriter, r *http.Request) {
-	imageGraphIDStr := r.PathValue("id")
-
-	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+func (s *HTTPServer) handleSetParameters(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
 		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	var req connectionRequest
+	var req setParametersRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.logger.Error("failed to parse request body", "error", err)
 		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
 		return
 	}
 
-	if req.FromNodeID == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "from_node_id is required"})
+	params := imagegraph.GraphParameters(req.Parameters)
+	if err := params.Validate(); err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
 		return
 	}
-	if req.OutputName == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "output_name is required"})
+
+	command := application.NewSetImageGraphParametersCommand(imageGraphID, params)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, imagegraph.ErrParameterInUse) {
+			respondJSON(w, http.StatusConflict, errorResponse{Error: "node expressions still use a removed parameter"})
+			return
+		}
+		s.logger.Error("failed to handle SetImageGraphParametersCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update image graph"})
 		return
 	}
-	if req.ToNodeID == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "to_node_id is required"})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) handleSetSeed(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
-	if req.InputName == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "input_name is required"})
+
+	var req setSeedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
 		return
 	}
 
-	fromNodeID, err := imagegraph.ParseNodeID(req.FromNodeID)
-	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from_node_id"})
+	if err := imagegraph.ValidateSeed(req.Seed); err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
 		return
 	}
 
-	toNodeID, err := imagegraph.ParseNodeID(req.ToNodeID)
+	command := application.NewSetImageGraphSeedCommand(imageGraphID, req.Seed)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to handle SetImageGraphSeedCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update image graph"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) handleSetPerformanceMode(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to_node_id"})
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	command := application.NewConnectImageGraphNodesCommand(
-		imageGraphID,
-		fromNodeID,
-		imagegraph.OutputName(req.OutputName),
-		toNodeID,
-		imagegraph.InputName(req.InputName),
-	)
+	var req setPerformanceModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if req.PerformanceMode == nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "performance_mode is required"})
+		return
+	}
+
+	command := application.NewSetImageGraphPerformanceModeCommand(imageGraphID, *req.PerformanceMode)
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
 		if errors.Is(err, application.ErrImageGraphNotFound) {
 			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
 			return
 		}
-		s.logger.Error("failed to handle ConnectImageGraphNodesCommand", "error", err)
-		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to connect nodes"})
+		s.logger.Error("failed to handle SetImageGraphPerformanceModeCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update image graph"})
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *HTTPServer) handleDisconnectNodes(w http.ResponseWriter, r *http.Request) {
-	imageGraphIDStr := r.PathValue("id")
+func (s *HTTPServer) handleListExports(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	exports := ig.Exports()
+	response := listExportsResponse{
+		Exports: make([]exportResponse, 0, len(exports)),
+	}
+
+	for _, export := range exports {
+		exportResp := exportResponse{
+			Name:        export.Name,
+			NodeID:      export.NodeID.String(),
+			ImageID:     export.ImageID.String(),
+			URL:         "/api/images/" + export.ImageID.String(),
+			GeneratedAt: export.GeneratedAt,
+		}
+
+		// Dimensions and format are read from the stored image header; an
+		// export whose image can't be read is still listed without them
+		imageData, err := s.imageStorage.Get(export.ImageID)
+		if err == nil {
+			var cfg image.Config
+			cfg, exportResp.Format, err = image.DecodeConfig(bytes.NewReader(imageData))
+			exportResp.Width, exportResp.Height = cfg.Width, cfg.Height
+		}
+		if err != nil {
+			s.logger.Warn("failed to read export image", "error", err, "image_id", export.ImageID)
+		}
+
+		response.Exports = append(response.Exports, exportResp)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (s *HTTPServer) handleGetPropagationLatency(w http.ResponseWriter, r *http.Request) {
+	if s.latencyReporter == nil {
+		respondJSON(w, http.StatusNotImplemented, errorResponse{Error: "propagation latency tracking is not enabled"})
+		return
+	}
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	if _, err := s.imageGraphViews.Get(r.Context(), imageGraphID); err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	report := s.latencyReporter.Report(imageGraphID)
+
+	respondJSON(w, http.StatusOK, mapPropagationLatencyToResponse(report))
+}
+
+func (s *HTTPServer) handleAddNode(w http.ResponseWriter, r *http.Request) {
+	imageGraphIDStr := r.PathValue("id")
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req addNodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if req.Type == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "type is required"})
+		return
+	}
+	if req.Config == nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "config is required"})
+		return
+	}
+
+	nodeType, err := imagegraph.NodeTypeMapper.To(req.Type)
+
+	if err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node type"})
+		return
+	}
+
+	config := imagegraph.NewNodeConfig(nodeType)
+	if err := json.Unmarshal(req.Config, config); err != nil {
+		s.logger.Error("failed to parse config", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid config"})
+		return
+	}
+
+	// Adding a node with an external ID that's already in use in the graph
+	// returns the existing node, so clients can safely retry adds
+	if req.ExternalID != "" {
+		ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+		if err != nil {
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to add node"})
+			return
+		}
+
+		if existing, ok := ig.Nodes.FindByExternalID(req.ExternalID); ok {
+			if existing.Type != nodeType {
+				respondJSON(w, http.StatusConflict, errorResponse{Error: "external ID is in use by a node of a different type"})
+				return
+			}
+			respondJSON(w, http.StatusOK, addNodeResponse{ID: existing.ID.String()})
+			return
+		}
+	}
+
+	nodeID, err := s.idGenerator.NewNodeID()
+	if err != nil {
+		s.logger.Error("failed to generate node ID", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to add node"})
+		return
+	}
+
+	command := application.NewAddImageGraphNodeCommand(
+		imageGraphID,
+		nodeID,
+		nodeType,
+		req.Name,
+		config,
+		req.ExternalID,
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, application.ErrGraphLimitExceeded) {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "image graph node limit reached"})
+			return
+		}
+		s.logger.Error("failed to handle AddImageGraphNodeCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to add node"})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, addNodeResponse{ID: nodeID.String()})
+}
+
+func (s *HTTPServer) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
+	imageGraphIDStr := r.PathValue("id")
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeIDStr := r.PathValue("node_id")
+
+	nodeID, err := imagegraph.ParseNodeID(nodeIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	command := application.NewRemoveImageGraphNodeCommand(imageGraphID, nodeID)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to handle RemoveImageGraphNodeCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to delete node"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) handleConnectNodes(w http.ResponseWriter, r *http.Request) {
+	imageGraphIDStr := r.PathValue("id")
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req connectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if req.FromNodeID == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "from_node_id is required"})
+		return
+	}
+	if req.OutputName == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "output_name is required"})
+		return
+	}
+	if req.ToNodeID == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "to_node_id is required"})
+		return
+	}
+	if req.InputName == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "input_name is required"})
+		return
+	}
+	if err := imagegraph.ValidateConnectionTransform(req.Transform); err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	fromNodeID, err := imagegraph.ParseNodeID(req.FromNodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from_node_id"})
+		return
+	}
+
+	toNodeID, err := imagegraph.ParseNodeID(req.ToNodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to_node_id"})
+		return
+	}
+
+	command := application.NewConnectImageGraphNodesCommand(
+		imageGraphID,
+		fromNodeID,
+		imagegraph.OutputName(req.OutputName),
+		toNodeID,
+		imagegraph.InputName(req.InputName),
+	)
+	command.Transform = req.Transform
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, application.ErrGraphLimitExceeded) {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "image graph connection limit reached"})
+			return
+		}
+		if errors.Is(err, imagegraph.ErrIncompatibleImageKinds) {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "output produces a kind of image the input can't take"})
+			return
+		}
+		if errors.Is(err, imagegraph.ErrPaletteTransformed) {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "palette connections cannot be transformed"})
+			return
+		}
+		s.logger.Error("failed to handle ConnectImageGraphNodesCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to connect nodes"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) handleDisconnectNodes(w http.ResponseWriter, r *http.Request) {
+	imageGraphIDStr := r.PathValue("id")
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req connectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	if req.FromNodeID == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "from_node_id is required"})
+		return
+	}
+	if req.OutputName == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "output_name is required"})
+		return
+	}
+	if req.ToNodeID == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "to_node_id is required"})
+		return
+	}
+	if req.InputName == "" {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "input_name is required"})
+		return
+	}
+
+	fromNodeID, err := imagegraph.ParseNodeID(req.FromNodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from_node_id"})
+		return
+	}
+
+	toNodeID, err := imagegraph.ParseNodeID(req.ToNodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to_node_id"})
+		return
+	}
+
+	command := application.NewDisconnectImageGraphNodesCommand(
+		imageGraphID,
+		fromNodeID,
+		imagegraph.OutputName(req.OutputName),
+		toNodeID,
+		imagegraph.InputName(req.InputName),
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to handle DisconnectImageGraphNodesCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to disconnect nodes"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) handleUpdateNode(w http.ResponseWriter, r *http.Request) {
+	imageGraphIDStr := r.PathValue("id")
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeIDStr := r.PathValue("node_id")
+
+	nodeID, err := imagegraph.ParseNodeID(nodeIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	var req updateNodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	// Validate that at least one field is provided
+	if req.Name == nil && req.Description == nil && req.Config == nil &&
+		req.Bypassed == nil && req.Pinned == nil && req.Implementation == nil &&
+		req.Expressions == nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "at least one of name, description, config, expressions, implementation, bypassed or pinned must be provided"})
+		return
+	}
+
+	if req.Description != nil && len(*req.Description) > imagegraph.MaxNodeDescriptionLength {
+		respondJSON(w, http.StatusBadRequest, errorResponse{
+			Error: fmt.Sprintf("description must be at most %d bytes", imagegraph.MaxNodeDescriptionLength),
+		})
+		return
+	}
+
+	// A dry run reports how the config would validate without applying it
+	if r.URL.Query().Get("dry_run") == "true" {
+		if req.Config == nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "config must be provided for a dry run"})
+			return
+		}
+		s.respondNodeConfigValidation(w, r, imageGraphID, nodeID, req.Config)
+		return
+	}
+
+	// Update name if provided
+	if req.Name != nil {
+		command := application.NewSetImageGraphNodeNameCommand(
+			imageGraphID,
+			nodeID,
+			*req.Name,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodeNameCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node name"})
+			return
+		}
+	}
+
+	// Update description if provided
+	if req.Description != nil {
+		command := application.NewSetImageGraphNodeDescriptionCommand(
+			imageGraphID,
+			nodeID,
+			*req.Description,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodeDescriptionCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node description"})
+			return
+		}
+	}
+
+	// Update config if provided
+	if req.Config != nil {
+		// Look up the image graph to get the node's type
+		ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+		if err != nil {
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to get image graph", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+			return
+		}
+
+		node, exists := ig.Nodes[nodeID]
+		if !exists {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+			return
+		}
+
+		config := imagegraph.NewNodeConfig(node.Type)
+		if err := json.Unmarshal(req.Config, config); err != nil {
+			s.logger.Error("failed to parse config", "error", err)
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid config"})
+			return
+		}
+
+		command := application.NewSetImageGraphNodeConfigCommand(
+			imageGraphID,
+			nodeID,
+			config,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodeConfigCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node config"})
+			return
+		}
+	}
+
+	// Update expressions if provided
+	if req.Expressions != nil {
+		ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+		if err != nil {
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to get image graph", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+			return
+		}
+
+		node, exists := ig.Nodes[nodeID]
+		if !exists {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+			return
+		}
+
+		expressions := imagegraph.ConfigExpressions(req.Expressions)
+		if err := expressions.Validate(node, ig.Parameters); err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		command := application.NewSetImageGraphNodeExpressionsCommand(
+			imageGraphID,
+			nodeID,
+			expressions,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodeExpressionsCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node expressions"})
+			return
+		}
+	}
+
+	// Update implementation if provided
+	if req.Implementation != nil {
+		command := application.NewSetImageGraphNodeImplementationCommand(
+			imageGraphID,
+			nodeID,
+			*req.Implementation,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodeImplementationCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node implementation"})
+			return
+		}
+	}
+
+	// Update bypass if provided
+	if req.Bypassed != nil {
+		command := application.NewSetImageGraphNodeBypassCommand(
+			imageGraphID,
+			nodeID,
+			*req.Bypassed,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodeBypassCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node bypass"})
+			return
+		}
+	}
+
+	// Update pin if provided
+	if req.Pinned != nil {
+		command := application.NewSetImageGraphNodePinnedCommand(
+			imageGraphID,
+			nodeID,
+			*req.Pinned,
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to handle SetImageGraphNodePinnedCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node pin"})
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUpgradeNode migrates a node to the latest implementation of its node
+// type, regenerating its outputs if the implementation changed
+func (s *HTTPServer) handleUpgradeNode(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	command := application.NewUpgradeImageGraphNodeImplementationCommand(
+		imageGraphID,
+		nodeID,
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to handle UpgradeImageGraphNodeImplementationCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to upgrade node"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetNodeDiff measures how much the images on the inputs of a diff
+// node differ
+func (s *HTTPServer) handleGetNodeDiff(w http.ResponseWriter, r *http.Request) {
+	if s.imageComparer == nil {
+		respondJSON(w, http.StatusNotImplemented, errorResponse{Error: "image comparison is not enabled"})
+		return
+	}
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to diff node inputs"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	if node.Type != imagegraph.NodeTypeDiff {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "node is not a diff node"})
+		return
+	}
+
+	base, compare := node.Inputs["base"], node.Inputs["compare"]
+	if !base.HasImage() || !compare.HasImage() {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "diff node inputs have no images yet"})
+		return
+	}
+
+	diff, err := s.imageComparer.CompareImages(r.Context(), base.ImageID, compare.ImageID)
+	if err != nil {
+		s.logger.Error("failed to compare images", "error", err, "id", imageGraphID, "node_id", nodeID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to diff node inputs"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, nodeDiffResponse{RMSE: diff.RMSE, SSIM: diff.SSIM})
+}
+
+func (s *HTTPServer) handleValidateNodeConfig(w http.ResponseWriter, r *http.Request) {
+	imageGraphIDStr := r.PathValue("id")
+
+	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeIDStr := r.PathValue("node_id")
+
+	nodeID, err := imagegraph.ParseNodeID(nodeIDStr)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	// The body is optional, without a config the node's current config is
+	// validated
+	var req validateNodeConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	s.respondNodeConfigValidation(w, r, imageGraphID, nodeID, req.Config)
+}
+
+// respondNodeConfigValidation validates a config for a node, and lints it
+// against the images currently connected to the node's inputs. A nil
+// rawConfig validates the node's current config.
+func (s *HTTPServer) respondNodeConfigValidation(
+	w http.ResponseWriter,
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	rawConfig json.RawMessage,
+) {
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	config := node.Config
+	if rawConfig != nil {
+		config = imagegraph.NewNodeConfig(node.Type)
+		if err := json.Unmarshal(rawConfig, config); err != nil {
+			s.logger.Error("failed to parse config", "error", err)
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid config"})
+			return
+		}
+	}
+
+	resp := validateNodeConfigResponse{Valid: true, Warnings: []string{}}
+
+	if err := config.Validate(); err != nil {
+		resp.Valid = false
+		resp.Error = err.Error()
+	} else if linter, ok := config.(imagegraph.NodeConfigLinter); ok {
+		if warnings := linter.Lint(s.inputImageSizes(node)); len(warnings) > 0 {
+			resp.Warnings = warnings
+		}
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// handleValidateImageGraph statically validates every connection of a graph,
+// checking that each input can take the kind of image connected to it and
+// that inputs expected to match in size do, as far as sizes are known
+func (s *HTTPServer) handleValidateImageGraph(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+		return
+	}
+
+	resp := validateImageGraphResponse{Valid: true, Connections: []connectionValidationResponse{}}
+
+	for _, v := range ig.ValidateConnections(s.graphImageSizes(ig)) {
+		if len(v.Errors) > 0 {
+			resp.Valid = false
+		}
+		resp.Connections = append(resp.Connections, connectionValidationResponse{
+			FromNodeID: v.FromNodeID.String(),
+			OutputName: string(v.OutputName),
+			ToNodeID:   v.ToNodeID.String(),
+			InputName:  string(v.InputName),
+			Errors:     append([]string{}, v.Errors...),
+			Warnings:   append([]string{}, v.Warnings...),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// defaultStuckAfter is how long a node can be generating before diagnostics
+// report it as stuck, matching how long startup waits before recovering
+// generation
+const defaultStuckAfter = 10 * time.Minute
+
+// handleGetDiagnostics reports the problems that keep a graph from being
+// complete, for a problems panel. stuck_after sets how long a node can be
+// generating before it's reported as stuck.
+func (s *HTTPServer) handleGetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	stuckAfter := defaultStuckAfter
+	if raw := r.URL.Query().Get("stuck_after"); raw != "" {
+		stuckAfter, err = time.ParseDuration(raw)
+		if err != nil || stuckAfter < 0 {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid stuck_after"})
+			return
+		}
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+		return
+	}
+
+	resp := diagnosticsResponse{Diagnostics: []diagnosticResponse{}}
+
+	for _, d := range ig.Diagnostics(time.Now().Add(-stuckAfter)) {
+		if d.Severity == imagegraph.SeverityError {
+			resp.Errors++
+		} else {
+			resp.Warnings++
+		}
+
+		var nodeName string
+		if node, ok := ig.Nodes.Get(d.NodeID); ok {
+			nodeName = node.Name
+		}
+
+		resp.Diagnostics = append(resp.Diagnostics, diagnosticResponse{
+			Kind:     d.Kind,
+			Severity: d.Severity,
+			NodeID:   d.NodeID.String(),
+			NodeName: nodeName,
+			Port:     d.Port,
+			Message:  d.Message,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
 
-	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
+// handleEstimateImageGraph estimates the output sizes, memory and time of
+// regenerating every node of a graph from the images on its input nodes,
+// so a huge image can be checked before it's sent through
+func (s *HTTPServer) handleEstimateImageGraph(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
 		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	var req connectionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.logger.Error("failed to parse request body", "error", err)
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
 		return
 	}
 
-	if req.FromNodeID == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "from_node_id is required"})
-		return
+	var timings map[imagegraph.NodeType]imagegraph.GenerationTiming
+	if s.timingReporter != nil {
+		timings = s.timingReporter.GenerationTimings()
 	}
-	if req.OutputName == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "output_name is required"})
-		return
+
+	nodeTimings := make(map[imagegraph.NodeID]imagegraph.GenerationTiming)
+	if s.generationRuns != nil {
+		for nodeID := range ig.Nodes {
+			runs, err := s.generationRuns.ListByNode(r.Context(), imageGraphID, nodeID, defaultStatsRuns)
+			if err != nil {
+				s.logger.Error("failed to list generation runs", "error", err, "node_id", nodeID)
+				respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to estimate image graph"})
+				return
+			}
+			nodeTimings[nodeID] = application.SummarizeGenerationRuns(runs).Timing()
+		}
 	}
-	if req.ToNodeID == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "to_node_id is required"})
-		return
+
+	resp := estimateResponse{Nodes: []nodeEstimateResponse{}}
+
+	for _, e := range ig.Estimate(s.graphImageSizes(ig), timings, nodeTimings) {
+		node, _ := ig.Nodes.Get(e.NodeID)
+
+		nodeResp := nodeEstimateResponse{
+			NodeID:           e.NodeID.String(),
+			NodeName:         node.Name,
+			Type:             imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
+			Inputs:           make(map[string]imageSizeResponse),
+			MemoryBytes:      e.MemoryBytes,
+			TimedGenerations: e.DurationSample,
+		}
+		for name, size := range e.Inputs {
+			nodeResp.Inputs[string(name)] = imageSizeResponse{Width: size.Width, Height: size.Height}
+		}
+		if e.OutputKnown {
+			nodeResp.Output = &imageSizeResponse{Width: e.Output.Width, Height: e.Output.Height}
+		}
+		if e.DurationKnown {
+			ms := float64(e.Duration) / float64(time.Millisecond)
+			nodeResp.DurationMs = &ms
+			resp.DurationMs += ms
+		}
+		resp.PeakMemoryBytes = max(resp.PeakMemoryBytes, e.MemoryBytes)
+
+		resp.Nodes = append(resp.Nodes, nodeResp)
 	}
-	if req.InputName == "" {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "input_name is required"})
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// defaultStatsRuns and maxStatsRuns bound how many of a node's most recent
+// generation runs its stats summarise
+const (
+	defaultStatsRuns = 100
+	maxStatsRuns     = 1000
+)
+
+// handleGetNodeStats reports how long the recent generations of a node took
+// and the pixels they worked on, for tuning pipelines
+func (s *HTTPServer) handleGetNodeStats(w http.ResponseWriter, r *http.Request) {
+	if s.generationRuns == nil {
+		respondJSON(w, http.StatusNotImplemented, errorResponse{Error: "generation history is not enabled"})
 		return
 	}
 
-	fromNodeID, err := imagegraph.ParseNodeID(req.FromNodeID)
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from_node_id"})
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
 		return
 	}
 
-	toNodeID, err := imagegraph.ParseNodeID(req.ToNodeID)
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to_node_id"})
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
 		return
 	}
 
-	command := application.NewDisconnectImageGraphNodesCommand(
-		imageGraphID,
-		fromNodeID,
-		imagegraph.OutputName(req.OutputName),
-		toNodeID,
-		imagegraph.InputName(req.InputName),
-	)
+	limit := defaultStatsRuns
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxStatsRuns {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", maxStatsRuns)})
+			return
+		}
+	}
 
-	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
 		if errors.Is(err, application.ErrImageGraphNotFound) {
 			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
 			return
 		}
-		s.logger.Error("failed to handle DisconnectImageGraphNodesCommand", "error", err)
-		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to disconnect nodes"})
+		s.logger.Error("failed to get image graph", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (s *HTTPServer) handleUpdateNode(w http.ResponseWriter, r *http.Request) {
-	imageGraphIDStr := r.PathValue("id")
-
-	imageGraphID, err := imagegraph.ParseImageGraphID(imageGraphIDStr)
-	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+	if _, exists := ig.Nodes[nodeID]; !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
 		return
 	}
 
-	nodeIDStr := r.PathValue("node_id")
-
-	nodeID, err := imagegraph.ParseNodeID(nodeIDStr)
+	runs, err := s.generationRuns.ListByNode(r.Context(), imageGraphID, nodeID, limit)
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
-		return
-	}
-
-	var req updateNodeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.logger.Error("failed to parse request body", "error", err)
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		s.logger.Error("failed to list generation runs", "error", err, "node_id", nodeID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get node stats"})
 		return
 	}
 
-	// Validate that at least one field is provided
-	if req.Name == nil && req.Config == nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "at least one of name or config must be provided"})
-		return
-	}
+	respondJSON(w, http.StatusOK, mapGenerationRunsToResponse(nodeID, runs))
+}
 
-	// Update name if provided
-	if req.Name != nil {
-		command := application.NewSetImageGraphNodeNameCommand(
-			imageGraphID,
-			nodeID,
-			*req.Name,
-		)
+// graphImageSizes reads the dimensions of the images set on the inputs and
+// outputs of a graph's nodes. Images that can't be read are left out.
+func (s *HTTPServer) graphImageSizes(ig *imagegraph.ImageGraph) map[imagegraph.ImageID]imagegraph.ImageSize {
+	sizes := make(map[imagegraph.ImageID]imagegraph.ImageSize)
 
-		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
-			if errors.Is(err, application.ErrImageGraphNotFound) {
-				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
-				return
-			}
-			s.logger.Error("failed to handle SetImageGraphNodeNameCommand", "error", err)
-			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node name"})
+	read := func(imageID imagegraph.ImageID) {
+		if _, ok := sizes[imageID]; ok || imageID.IsNil() {
 			return
 		}
-	}
 
-	// Update config if provided
-	if req.Config != nil {
-		// Look up the image graph to get the node's type
-		ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+		imageData, err := s.imageStorage.Get(imageID)
 		if err != nil {
-			if errors.Is(err, application.ErrImageGraphNotFound) {
-				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
-				return
-			}
-			s.logger.Error("failed to get image graph", "error", err)
-			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image graph"})
+			s.logger.Warn("failed to get image from storage", "error", err, "image_id", imageID)
 			return
 		}
 
-		node, exists := ig.Nodes[nodeID]
-		if !exists {
-			respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+		if err != nil {
+			s.logger.Warn("failed to decode image", "error", err, "image_id", imageID)
 			return
 		}
 
-		config := imagegraph.NewNodeConfig(node.Type)
-		if err := json.Unmarshal(req.Config, config); err != nil {
-			s.logger.Error("failed to parse config", "error", err)
-			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid config"})
-			return
+		sizes[imageID] = imagegraph.ImageSize{Width: cfg.Width, Height: cfg.Height}
+	}
+
+	for _, node := range ig.Nodes {
+		for _, input := range node.Inputs {
+			read(input.ImageID)
 		}
+		for _, output := range node.Outputs {
+			read(output.ImageID)
+		}
+	}
 
-		command := application.NewSetImageGraphNodeConfigCommand(
-			imageGraphID,
-			nodeID,
-			config,
-		)
+	return sizes
+}
 
-		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
-			if errors.Is(err, application.ErrImageGraphNotFound) {
-				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
-				return
-			}
-			s.logger.Error("failed to handle SetImageGraphNodeConfigCommand", "error", err)
-			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update node config"})
-			return
+// inputImageSizes reads the dimensions of the images set on a node's inputs.
+// Inputs whose image can't be read are left out.
+func (s *HTTPServer) inputImageSizes(node *imagegraph.Node) map[imagegraph.InputName]imagegraph.ImageSize {
+	sizes := make(map[imagegraph.InputName]imagegraph.ImageSize)
+
+	for name, input := range node.Inputs {
+		if input.ImageID.IsNil() {
+			continue
+		}
+
+		imageData, err := s.imageStorage.Get(input.ImageID)
+		if err != nil {
+			s.logger.Warn("failed to get input image from storage", "error", err, "image_id", input.ImageID)
+			continue
+		}
+
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+		if err != nil {
+			s.logger.Warn("failed to decode input image", "error", err, "image_id", input.ImageID)
+			continue
 		}
+
+		sizes[name] = imagegraph.ImageSize{Width: cfg.Width, Height: cfg.Height}
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	return sizes
 }
 
 func (s *HTTPServer) handleUploadNodeOutputImage(w http.ResponseWriter, r *http.Request) {
@@ -462,9 +1643,15 @@ func (s *HTTPServer) handleUploadNodeOutputImage(w http.ResponseWriter, r *http.
 		return
 	}
 
+	upload, err := s.inspectUpload(imageData)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
 	imageID := imagegraph.MustNewImageID()
 
-	if err := s.imageStorage.Save(imageID, imageData); err != nil {
+	if err := filestorage.SaveUpload(s.imageStorage, imageID, header.Filename, imageData); err != nil {
 		s.logger.Error("failed to save image to storage", "error", err, "image_id", imageID)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to save image"})
 		return
@@ -477,8 +1664,12 @@ func (s *HTTPServer) handleUploadNodeOutputImage(w http.ResponseWriter, r *http.
 		imageID,
 		0, // allow command handler to resolve to current node version
 	)
+	command.Upload = &upload
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
 		if errors.Is(err, application.ErrImageGraphNotFound) {
 			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
 			return
@@ -495,6 +1686,9 @@ func (s *HTTPServer) handleUploadNodeOutputImage(w http.ResponseWriter, r *http.
 	)
 
 	if err := s.messageBus.HandleCommand(r.Context(), setNameCommand); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
 		if errors.Is(err, application.ErrImageGraphNotFound) {
 			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
 			return
@@ -514,6 +1708,23 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// respondValidationError responds with 400 and the invalid fields if err is a
+// layout or viewport validation error, and reports whether it did
+func respondValidationError(w http.ResponseWriter, err error) bool {
+	var validationErr *ui.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+
+	fields := make([]fieldErrorResponse, len(validationErr.Fields))
+	for i, f := range validationErr.Fields {
+		fields[i] = fieldErrorResponse{Field: f.Field, Error: f.Message}
+	}
+	respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid fields", Fields: fields})
+
+	return true
+}
+
 // Layout Handlers
 
 func (s *HTTPServer) handleGetLayout(w http.ResponseWriter, r *http.Request) {
@@ -525,36 +1736,99 @@ func (s *HTTPServer) handleGetLayout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	layout, err := s.layoutViews.Get(r.Context(), imageGraphID)
+	response, err := s.getLayoutResponse(r.Context(), imageGraphID)
 	if err != nil {
-		// If not found, return empty layout with 200 OK
-		if errors.Is(err, application.ErrLayoutNotFound) {
-			respondJSON(w, http.StatusOK, layoutResponse{
-				GraphID:       imageGraphID.String(),
-				NodePositions: []nodePosition{},
-			})
-			return
-		}
 		s.logger.Error("failed to get layout", "error", err, "id", imageGraphID)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve layout"})
 		return
 	}
 
-	nodePositions := make([]nodePosition, 0, len(layout.NodePositions))
-	for _, pos := range layout.NodePositions {
-		nodePositions = append(nodePositions, nodePosition{
-			NodeID: pos.NodeID.String(),
-			X:      pos.X,
-			Y:      pos.Y,
-		})
+	respondJSON(w, http.StatusOK, response)
+}
+
+// getForUser reads the layout or viewport the requesting user sees: their
+// own, else the ImageGraph owner's, else the one shared by everyone. Until
+// they save their own, collaborators see the canvas as the owner left it.
+func getForUser[T any](
+	ctx context.Context,
+	s *HTTPServer,
+	imageGraphID imagegraph.ImageGraphID,
+	notFound error,
+	get func(userID string) (T, error),
+) (T, error) {
+	user, _ := application.UserFromContext(ctx)
+
+	result, err := get(user.ID)
+	if user.ID == "" || !errors.Is(err, notFound) {
+		return result, err
+	}
+
+	ig, igErr := s.imageGraphViews.Get(ctx, imageGraphID)
+	if igErr == nil && ig.Owner != "" && ig.Owner != user.ID {
+		result, err = get(ig.Owner)
+		if !errors.Is(err, notFound) {
+			return result, err
+		}
 	}
 
-	response := layoutResponse{
-		GraphID:       layout.GraphID.String(),
-		NodePositions: nodePositions,
+	return get("")
+}
+
+// getUserLayout gets the layout the requesting user sees
+func (s *HTTPServer) getUserLayout(ctx context.Context, imageGraphID imagegraph.ImageGraphID) (*ui.Layout, error) {
+	return getForUser(ctx, s, imageGraphID, application.ErrLayoutNotFound, func(userID string) (*ui.Layout, error) {
+		return s.layoutViews.Get(ctx, imageGraphID, userID)
+	})
+}
+
+// getUserNodeLayouts gets the node layouts the requesting user sees, without
+// the positions of removed nodes that layouts keep, so that they can be
+// saved back. Graphs that were never laid out have none.
+func (s *HTTPServer) getUserNodeLayouts(ctx context.Context, imageGraphID imagegraph.ImageGraphID) ([]ui.NodeLayout, error) {
+	ig, err := s.imageGraphViews.Get(ctx, imageGraphID)
+	if err != nil {
+		return nil, err
 	}
 
-	respondJSON(w, http.StatusOK, response)
+	layout, err := s.getUserLayout(ctx, imageGraphID)
+	if errors.Is(err, application.ErrLayoutNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(slices.Clone(layout.NodeLayouts), func(nl ui.NodeLayout) bool {
+		_, ok := ig.Nodes.Get(nl.NodeID)
+		return !ok
+	}), nil
+}
+
+// getUserViewport gets the viewport the requesting user sees
+func (s *HTTPServer) getUserViewport(ctx context.Context, imageGraphID imagegraph.ImageGraphID) (*ui.Viewport, error) {
+	return getForUser(ctx, s, imageGraphID, application.ErrViewportNotFound, func(userID string) (*ui.Viewport, error) {
+		return s.viewportViews.Get(ctx, imageGraphID, userID)
+	})
+}
+
+// getLayoutResponse maps the layout the requesting user sees to its
+// response, which is empty for graphs that were never laid out
+func (s *HTTPServer) getLayoutResponse(ctx context.Context, imageGraphID imagegraph.ImageGraphID) (layoutResponse, error) {
+	layout, err := s.getUserLayout(ctx, imageGraphID)
+	if errors.Is(err, application.ErrLayoutNotFound) {
+		return layoutResponse{
+			GraphID:       imageGraphID.String(),
+			NodePositions: []nodeLayout{},
+		}, nil
+	}
+	if err != nil {
+		return layoutResponse{}, err
+	}
+
+	return layoutResponse{
+		GraphID:       layout.GraphID.String(),
+		NodePositions: mapNodeLayoutsToResponse(layout.NodeLayouts),
+	}, nil
 }
 
 func (s *HTTPServer) handleUpdateLayout(w http.ResponseWriter, r *http.Request) {
@@ -573,18 +1847,23 @@ func (s *HTTPServer) handleUpdateLayout(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	nodePositions, err := req.toDomain()
+	nodeLayouts, err := req.toDomain()
 	if err != nil {
-		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		respondValidationError(w, err)
 		return
 	}
 
+	user, _ := application.UserFromContext(r.Context())
 	command := application.NewUpdateLayoutCommand(
 		imageGraphID,
-		nodePositions,
+		user.ID,
+		nodeLayouts,
 	)
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) || respondValidationError(w, err) {
+			return
+		}
 		s.logger.Error("failed to handle UpdateLayoutCommand", "error", err)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update layout"})
 		return
@@ -593,6 +1872,56 @@ func (s *HTTPServer) handleUpdateLayout(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleAutoLayout arranges a graph's nodes in layers following their
+// connections, saves the arrangement as the graph's layout and returns it
+func (s *HTTPServer) handleAutoLayout(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	var current []ui.NodeLayout
+	layout, err := s.getUserLayout(r.Context(), imageGraphID)
+	if err == nil {
+		current = layout.NodeLayouts
+	} else if !errors.Is(err, application.ErrLayoutNotFound) {
+		s.logger.Error("failed to get layout", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve layout"})
+		return
+	}
+
+	nodeLayouts := ui.AutoLayout(ig, current)
+
+	user, _ := application.UserFromContext(r.Context())
+	command := application.NewUpdateLayoutCommand(imageGraphID, user.ID, nodeLayouts)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		s.logger.Error("failed to handle UpdateLayoutCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update layout"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, layoutResponse{
+		GraphID:       imageGraphID.String(),
+		NodePositions: mapNodeLayoutsToResponse(nodeLayouts),
+	})
+}
+
 func (s *HTTPServer) handleGetViewport(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 
@@ -602,31 +1931,41 @@ func (s *HTTPServer) handleGetViewport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	viewport, err := s.viewportViews.Get(r.Context(), imageGraphID)
+	response, err := s.getViewportResponse(r.Context(), imageGraphID)
 	if err != nil {
-		// If not found, return default viewport with 200 OK
-		if errors.Is(err, application.ErrViewportNotFound) {
-			respondJSON(w, http.StatusOK, viewportResponse{
-				GraphID: imageGraphID.String(),
-				Zoom:    1.0,
-				PanX:    0,
-				PanY:    0,
-			})
-			return
-		}
 		s.logger.Error("failed to get viewport", "error", err, "id", imageGraphID)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve viewport"})
 		return
 	}
 
-	response := viewportResponse{
-		GraphID: viewport.GraphID.String(),
-		Zoom:    viewport.Zoom,
-		PanX:    viewport.PanX,
-		PanY:    viewport.PanY,
+	respondJSON(w, http.StatusOK, response)
+}
+
+// getViewportResponse maps the viewport the requesting user sees to its
+// response, which is the default viewport for graphs whose viewport was
+// never saved
+func (s *HTTPServer) getViewportResponse(ctx context.Context, imageGraphID imagegraph.ImageGraphID) (viewportResponse, error) {
+	viewport, err := s.getUserViewport(ctx, imageGraphID)
+	if errors.Is(err, application.ErrViewportNotFound) {
+		return viewportResponse{
+			GraphID:   imageGraphID.String(),
+			Zoom:      1.0,
+			PanX:      0,
+			PanY:      0,
+			Bookmarks: []viewportBookmarkResponse{},
+		}, nil
+	}
+	if err != nil {
+		return viewportResponse{}, err
 	}
 
-	respondJSON(w, http.StatusOK, response)
+	return viewportResponse{
+		GraphID:   viewport.GraphID.String(),
+		Zoom:      viewport.Zoom,
+		PanX:      viewport.PanX,
+		PanY:      viewport.PanY,
+		Bookmarks: mapViewportBookmarksToResponse(viewport.Bookmarks),
+	}, nil
 }
 
 func (s *HTTPServer) handleUpdateViewport(w http.ResponseWriter, r *http.Request) {
@@ -645,14 +1984,19 @@ func (s *HTTPServer) handleUpdateViewport(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	user, _ := application.UserFromContext(r.Context())
 	command := application.NewUpdateViewportCommand(
 		imageGraphID,
+		user.ID,
 		req.Zoom,
 		req.PanX,
 		req.PanY,
 	)
 
 	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) || respondValidationError(w, err) {
+			return
+		}
 		s.logger.Error("failed to handle UpdateViewportCommand", "error", err)
 		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update viewport"})
 		return
@@ -672,6 +2016,11 @@ func (s *HTTPServer) handleGetImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.serveImage(w, imageID)
+}
+
+// serveImage writes a stored image with its detected content type
+func (s *HTTPServer) serveImage(w http.ResponseWriter, imageID imagegraph.ImageID) {
 	imageData, err := s.imageStorage.Get(imageID)
 	if err != nil {
 		s.logger.Error("failed to get image from storage", "error", err, "image_id", imageID)
@@ -679,7 +2028,30 @@ func (s *HTTPServer) handleGetImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Type", http.DetectContentType(imageData))
 	w.WriteHeader(http.StatusOK)
 	w.Write(imageData)
 }
+
+func (s *HTTPServer) handleGetImageMetadata(w http.ResponseWriter, r *http.Request) {
+	imageID, err := imagegraph.ParseImageID(r.PathValue("image_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image ID"})
+		return
+	}
+
+	metadataStorage, ok := s.imageStorage.(filestorage.ImageMetadataStorage)
+	if !ok {
+		respondJSON(w, http.StatusNotImplemented, errorResponse{Error: "image metadata is not stored"})
+		return
+	}
+
+	metadata, err := metadataStorage.GetMetadata(imageID)
+	if err != nil {
+		s.logger.Error("failed to get image metadata from storage", "error", err, "image_id", imageID)
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image not found"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapImageMetadataToResponse(metadata))
+}
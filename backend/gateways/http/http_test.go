@@ -1,67 +1,94 @@
 package http_test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net"
 	"net/http"
 	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
 	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/client"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	httpgateway "github.com/dmpettyp/artwork/gateways/http"
 	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+	"github.com/dmpettyp/artwork/infrastructure/infratest"
 	"github.com/dmpettyp/artwork/infrastructure/inmem"
 	"github.com/dmpettyp/artwork/metrics"
+	"github.com/dmpettyp/artwork/testsupport"
 	"github.com/dmpettyp/dorky/messagebus"
 )
 
-// mockImageStorage is a simple in-memory image storage for testing
-type mockImageStorage struct {
-	data map[string][]byte
+// testServer wraps HTTPServer with test utilities
+type testServer struct {
+	server     *httpgateway.HTTPServer
+	httpServer *http.Server
+	listener   net.Listener
+	baseURL    string
+	messageBus *messagebus.MessageBus
+	queues     *application.GraphQueues
+	bus        *infratest.Bus
+	generation *application.ImageGraphEventHandlers
 }
 
-func (m *mockImageStorage) Save(imageID imagegraph.ImageID, imageData []byte) error {
-	m.data[imageID.String()] = imageData
-	return nil
-}
+func setupTestServer(t *testing.T, opts ...httpgateway.ServerOption) *testServer {
+	t.Helper()
 
-func (m *mockImageStorage) Get(imageID imagegraph.ImageID) ([]byte, error) {
-	data, ok := m.data[imageID.String()]
-	if !ok {
-		return nil, fmt.Errorf("image not found: %s", imageID.String())
-	}
-	return data, nil
+	return setupTestServerWithGraphLimits(t, application.GraphLimits{}, opts...)
 }
 
-func (m *mockImageStorage) Exists(imageID imagegraph.ImageID) (bool, error) {
-	_, ok := m.data[imageID.String()]
-	return ok, nil
-}
+// setupTestServerWithGraphLimits creates a test server whose command handlers
+// enforce the provided graph complexity limits
+func setupTestServerWithGraphLimits(
+	t *testing.T,
+	limits application.GraphLimits,
+	opts ...httpgateway.ServerOption,
+) *testServer {
+	t.Helper()
 
-func (m *mockImageStorage) Remove(imageID imagegraph.ImageID) error {
-	delete(m.data, imageID.String())
-	return nil
+	return newTestServer(t, limits, nil, opts...)
 }
 
-// testServer wraps HTTPServer with test utilities
-type testServer struct {
-	server     *httpgateway.HTTPServer
-	httpServer *http.Server
-	listener   net.Listener
-	baseURL    string
-	messageBus *messagebus.MessageBus
-	cancelFunc context.CancelFunc
+// setupTestServerWithGraphQueues creates a test server whose commands are
+// queued in front of the message bus with the provided options
+func setupTestServerWithGraphQueues(
+	t *testing.T,
+	queueOpts []application.GraphQueuesOption,
+	opts ...httpgateway.ServerOption,
+) *testServer {
+	t.Helper()
+
+	return newTestServer(t, application.GraphLimits{}, queueOpts, opts...)
 }
 
-func setupTestServer(t *testing.T) *testServer {
+func newTestServer(
+	t *testing.T,
+	limits application.GraphLimits,
+	queueOpts []application.GraphQueuesOption,
+	opts ...httpgateway.ServerOption,
+) *testServer {
 	t.Helper()
 
 	// Create logger that discards output during tests
@@ -73,14 +100,16 @@ func setupTestServer(t *testing.T) *testServer {
 		t.Fatalf("failed to create unit of work: %v", err)
 	}
 
-	// Create message bus
+	// Create message bus, with the queues commands are sent through and the
+	// handlers count their events in
 	mb := messagebus.New()
+	queues := application.NewGraphQueues(mb, queueOpts...)
 
-	// Create mock image storage
-	imageStorage := &mockImageStorage{data: make(map[string][]byte)}
+	// Create in-memory image storage
+	imageStorage := infratest.NewImageStorage()
 
 	// Create node updater for ImageGen
-	nodeUpdater := application.NewNodeUpdater(mb)
+	nodeUpdater := application.NewNodeUpdater(queues)
 
 	// Create ImageGen with dependencies
 	imageGen := imagegen.NewImageGen(imageStorage, nodeUpdater, logger, nil)
@@ -89,33 +118,65 @@ func setupTestServer(t *testing.T) *testServer {
 	notifier := httpgateway.NewImageGraphNotifier(logger)
 
 	// Register command handlers
-	_, err = application.NewImageGraphCommandHandlers(mb, uow)
+	_, err = application.NewImageGraphCommandHandlers(mb, queues, uow, application.WithGraphLimits(limits))
 	if err != nil {
 		t.Fatalf("failed to create command handlers: %v", err)
 	}
 
 	// Register event handlers
-	_, err = application.NewImageGraphEventHandlers(mb, uow, imageGen, imageStorage, notifier)
+	eventHandlers, err := application.NewImageGraphEventHandlers(mb, queues, nil, uow, imageGen, imageStorage, notifier, logger)
 	if err != nil {
 		t.Fatalf("failed to create event handlers: %v", err)
 	}
 
+	// Register graph link handlers
+	graphLinks := inmem.NewGraphLinkStore()
+	if _, err = application.NewGraphLinkEventHandlers(mb, queues, nil, uow, graphLinks, imageStorage); err != nil {
+		t.Fatalf("failed to create graph link event handlers: %v", err)
+	}
+
+	// Register layout and viewport handlers
+	if _, err = application.NewLayoutCommandHandlers(mb, queues, uow); err != nil {
+		t.Fatalf("failed to create layout command handlers: %v", err)
+	}
+	if _, err = application.NewLayoutEventHandlers(mb, queues, nil, notifier); err != nil {
+		t.Fatalf("failed to create layout event handlers: %v", err)
+	}
+	if _, err = application.NewViewportCommandHandlers(mb, queues, uow); err != nil {
+		t.Fatalf("failed to create viewport command handlers: %v", err)
+	}
+
 	// Create HTTP server
 	appMetrics := metrics.NewAppMetrics()
+
+	propagationLatency := application.NewPropagationLatencyTracker(appMetrics.Propagation)
+	_, err = application.NewPropagationLatencyEventHandlers(mb, queues, nil, propagationLatency)
+	if err != nil {
+		t.Fatalf("failed to create propagation latency event handlers: %v", err)
+	}
+
 	httpServer := httpgateway.NewHTTPServer(
 		logger,
-		mb,
+		queues,
 		uow.ImageGraphViews,
 		uow.LayoutViews,
 		uow.ViewportViews,
 		imageStorage,
 		notifier,
 		appMetrics,
+		append([]httpgateway.ServerOption{
+			httpgateway.WithPropagationLatencyReporter(propagationLatency),
+			httpgateway.WithImageComparer(imageGen),
+			httpgateway.WithGenerationTimingReporter(imageGen),
+			httpgateway.WithPreviewGenerator(imageGen),
+			httpgateway.WithGraphLimits(limits),
+			httpgateway.WithStats(inmem.NewStatsCollector(uow.ImageGraphViews, inmem.NewSnapshotStore())),
+			httpgateway.WithGraphLinks(graphLinks),
+		}, opts...)...,
 	)
 
-	// Start the message bus
-	ctx, cancel := context.WithCancel(context.Background())
-	go mb.Start(ctx)
+	// Start the message bus, stopped when the test ends
+	bus := infratest.StartBus(t, mb)
 
 	// Create test server bound to IPv4 (tcp6 may be disallowed in some environments)
 	ln, err := net.Listen("tcp4", "127.0.0.1:0")
@@ -134,21 +195,36 @@ func setupTestServer(t *testing.T) *testServer {
 		listener:   ln,
 		baseURL:    "http://" + ln.Addr().String(),
 		messageBus: mb,
-		cancelFunc: cancel,
+		queues:     queues,
+		bus:        bus,
+		generation: eventHandlers,
 	}
 }
 
 func (ts *testServer) Stop() {
+	// Let generation still running finish before the bus it reports to stops
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = ts.bus.Settle(ctx, ts.generation)
+
 	if ts.httpServer != nil {
 		_ = ts.httpServer.Shutdown(context.Background())
 	}
 	if ts.listener != nil {
 		_ = ts.listener.Close()
 	}
-	ts.cancelFunc()
 	ts.messageBus.Stop()
 }
 
+// settle waits until every command sent so far has been handled along with
+// the events it led to, and every node output generation they started has
+// finished, so the pipeline's results can be checked without polling
+func (ts *testServer) settle(t *testing.T) {
+	t.Helper()
+
+	ts.bus.MustSettle(ts.generation)
+}
+
 func (ts *testServer) URL() string {
 	return ts.baseURL
 }
@@ -259,6 +335,36 @@ func (ts *testServer) connectNodes(t *testing.T, graphID, fromNodeID, outputName
 	}
 }
 
+// buildGraph replays a graph builder's nodes and connections through the
+// API, returning the new graph's ID and the IDs of its nodes by name. Images
+// added to the builder are not uploaded.
+func (ts *testServer) buildGraph(t *testing.T, b *testsupport.GraphBuilder) (string, map[string]string) {
+	t.Helper()
+
+	graphID := ts.createImageGraph(t, b.Name())
+	nodeIDs := make(map[string]string)
+
+	for _, n := range b.Nodes() {
+		config := "{}"
+		if n.Config != nil {
+			configJSON, err := json.Marshal(n.Config)
+			if err != nil {
+				t.Fatalf("failed to marshal config: %v", err)
+			}
+			config = string(configJSON)
+		}
+
+		nodeType := imagegraph.NodeTypeMapper.FromWithDefault(n.Type, "unknown")
+		nodeIDs[n.Name] = ts.addNode(t, graphID, nodeType, n.Name, config)
+	}
+
+	for _, c := range b.Connections() {
+		ts.connectNodes(t, graphID, nodeIDs[c.From], string(c.OutputName), nodeIDs[c.To], string(c.InputName))
+	}
+
+	return graphID, nodeIDs
+}
+
 func (ts *testServer) getImageGraph(t *testing.T, graphID string) map[string]interface{} {
 	t.Helper()
 
@@ -490,8 +596,8 @@ func TestStateTransitionAndEventPropagation(t *testing.T) {
 	// Set output image on input node
 	imageID := server.setNodeOutputImage(t, graphID, inputNodeID, "original", "")
 
-	// Wait a bit for event propagation (message bus processes async)
-	time.Sleep(100 * time.Millisecond)
+	// Wait for the image to propagate and the resize node to generate
+	server.settle(t)
 
 	// Get the graph and verify propagation
 	graph := server.getImageGraph(t, graphID)
@@ -519,10 +625,8 @@ func TestStateTransitionAndEventPropagation(t *testing.T) {
 		t.Errorf("expected input image_id %s, got %s", imageID, input["image_id"])
 	}
 
-	// Verify state is either "generating" or "generated" (depends on timing)
-	state := resizeNode["state"].(string)
-	if state != "generating" && state != "generated" {
-		t.Errorf("expected state 'generating' or 'generated', got %s", state)
+	if state := resizeNode["state"].(string); state != "generated" {
+		t.Errorf("expected state 'generated', got %s", state)
 	}
 }
 
@@ -552,14 +656,170 @@ func TestNodeConfigUpdate(t *testing.T) {
 	}
 }
 
-func TestErrorScenarios(t *testing.T) {
+func TestNodeConfigValidation(t *testing.T) {
 	server := setupTestServer(t)
 	defer server.Stop()
 
-	t.Run("404 for non-existent graph", func(t *testing.T) {
-		fakeID := imagegraph.MustNewImageGraphID().String()
+	graphID := server.createImageGraph(t, "Test Graph")
+	nodeID := server.addNode(t, graphID, "resize", "Resize Node", `{"width": 800, "interpolation": "Bilinear"}`)
 
-		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s", server.URL(), fakeID))
+	type validationResult struct {
+		Valid    bool     `json:"valid"`
+		Error    string   `json:"error"`
+		Warnings []string `json:"warnings"`
+	}
+
+	validate := func(t *testing.T, method, path, body string) validationResult {
+		t.Helper()
+
+		req, _ := http.NewRequest(method, server.URL()+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, string(bodyBytes))
+		}
+
+		var result validationResult
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	validatePath := fmt.Sprintf("/api/imagegraphs/%s/nodes/%s/validate", graphID, nodeID)
+
+	t.Run("validates current config without a body", func(t *testing.T) {
+		result := validate(t, http.MethodPost, validatePath, "")
+		if !result.Valid {
+			t.Errorf("expected current config to be valid, got error %q", result.Error)
+		}
+	})
+
+	t.Run("reports invalid config", func(t *testing.T) {
+		result := validate(t, http.MethodPost, validatePath, `{"config": {"interpolation": "Bilinear"}}`)
+		if result.Valid {
+			t.Error("expected config without width or height to be invalid")
+		}
+		if result.Error == "" {
+			t.Error("expected validation error message")
+		}
+	})
+
+	t.Run("dry run does not apply config", func(t *testing.T) {
+		result := validate(
+			t,
+			http.MethodPatch,
+			fmt.Sprintf("/api/imagegraphs/%s/nodes/%s?dry_run=true", graphID, nodeID),
+			`{"config": {"width": 300, "interpolation": "Bilinear"}}`,
+		)
+		if !result.Valid {
+			t.Errorf("expected config to be valid, got error %q", result.Error)
+		}
+
+		graph := server.getImageGraph(t, graphID)
+		node := graph["nodes"].([]interface{})[0].(map[string]interface{})
+		config := node["config"].(map[string]interface{})
+		if config["width"].(float64) != 800 {
+			t.Errorf("expected width to remain 800, got %v", config["width"])
+		}
+	})
+}
+
+func TestExternalIDs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	post := func(t *testing.T, path string, reqBody map[string]interface{}) (int, string) {
+		t.Helper()
+
+		body, _ := json.Marshal(reqBody)
+		resp, err := http.Post(server.URL()+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var response struct {
+			ID string `json:"id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&response)
+		return resp.StatusCode, response.ID
+	}
+
+	graphBody := map[string]interface{}{"name": "Imported", "external_id": "asset-1"}
+
+	status, graphID := post(t, "/api/imagegraphs", graphBody)
+	if status != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", status)
+	}
+
+	t.Run("create with existing external ID returns existing graph", func(t *testing.T) {
+		status, id := post(t, "/api/imagegraphs", graphBody)
+		if status != http.StatusOK {
+			t.Errorf("expected status 200, got %d", status)
+		}
+		if id != graphID {
+			t.Errorf("expected graph %s, got %s", graphID, id)
+		}
+	})
+
+	t.Run("looks up graph by external ID", func(t *testing.T) {
+		resp, err := http.Get(server.URL() + "/api/imagegraphs/by-external-id?external_id=asset-1")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var graph map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&graph)
+		if graph["id"] != graphID {
+			t.Errorf("expected graph %s, got %v", graphID, graph["id"])
+		}
+	})
+
+	t.Run("add node with existing external ID returns existing node", func(t *testing.T) {
+		nodeBody := map[string]interface{}{"type": "input", "config": map[string]interface{}{}, "external_id": "layer-1"}
+		nodesPath := fmt.Sprintf("/api/imagegraphs/%s/nodes", graphID)
+
+		status, nodeID := post(t, nodesPath, nodeBody)
+		if status != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d", status)
+		}
+
+		status, retryID := post(t, nodesPath, nodeBody)
+		if status != http.StatusOK {
+			t.Errorf("expected status 200, got %d", status)
+		}
+		if retryID != nodeID {
+			t.Errorf("expected node %s, got %s", nodeID, retryID)
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/nodes/by-external-id?external_id=layer-1", server.URL(), graphID))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var node map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&node)
+		if node["id"] != nodeID {
+			t.Errorf("expected node %s, got %v", nodeID, node["id"])
+		}
+	})
+
+	t.Run("404 for unknown external ID", func(t *testing.T) {
+		resp, err := http.Get(server.URL() + "/api/imagegraphs/by-external-id?external_id=missing")
 		if err != nil {
 			t.Fatalf("request failed: %v", err)
 		}
@@ -569,41 +829,4161 @@ func TestErrorScenarios(t *testing.T) {
 			t.Errorf("expected status 404, got %d", resp.StatusCode)
 		}
 	})
+}
 
-	t.Run("400 for invalid UUID", func(t *testing.T) {
-		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/not-a-uuid", server.URL()))
+func TestPropagationLatency(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	graphID := server.createImageGraph(t, "Latency Graph")
+
+	t.Run("reports empty latency for new graph", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/latency", server.URL(), graphID))
 		if err != nil {
 			t.Fatalf("request failed: %v", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusBadRequest {
-			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var report struct {
+			ImageGraphID string        `json:"image_graph_id"`
+			Connections  []interface{} `json:"connections"`
+		}
+		json.NewDecoder(resp.Body).Decode(&report)
+		if report.ImageGraphID != graphID {
+			t.Errorf("expected graph %s, got %s", graphID, report.ImageGraphID)
+		}
+		if len(report.Connections) != 0 {
+			t.Errorf("expected no connections, got %d", len(report.Connections))
 		}
 	})
 
-	t.Run("400 for invalid config JSON", func(t *testing.T) {
-		graphID := server.createImageGraph(t, "Test Graph")
-		nodeID := server.addNode(t, graphID, "input", "Input Node", `{}`)
+	t.Run("404 for unknown graph", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/latency", server.URL(), imagegraph.MustNewImageGraphID()))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
 
-		reqBody := map[string]string{"config": "not valid json"}
-		body, _ := json.Marshal(reqBody)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestGraphLimits(t *testing.T) {
+	server := setupTestServerWithGraphLimits(t, application.GraphLimits{MaxNodes: 3, MaxConnections: 1})
+	defer server.Stop()
+
+	graphID, nodeIDs := server.buildGraph(t, testsupport.NewGraphBuilder().
+		WithName("Limited Graph").
+		WithInput().
+		WithBlur(5).
+		WithBlur(3).
+		Connect("input", "blur"))
+	inputID, blurID, otherBlurID := nodeIDs["input"], nodeIDs["blur"], nodeIDs["blur2"]
 
+	t.Run("graph response reports complexity and limits", func(t *testing.T) {
+		graph := server.getImageGraph(t, graphID)
+
+		complexity, ok := graph["complexity"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("expected complexity in graph response, got %v", graph["complexity"])
+		}
+
+		expected := map[string]float64{
+			"nodes":           3,
+			"connections":     1,
+			"max_nodes":       3,
+			"max_connections": 1,
+		}
+		for key, want := range expected {
+			if got := complexity[key]; got != want {
+				t.Errorf("expected %s %v, got %v", key, want, got)
+			}
+		}
+		if _, ok := complexity["pending_generations"]; !ok {
+			t.Error("expected pending_generations in complexity")
+		}
+	})
+
+	t.Run("rejects nodes past the limit", func(t *testing.T) {
+		body := `{"name": "Extra", "type": "blur", "config": {"radius": 1}}`
+		resp, err := http.Post(
+			fmt.Sprintf("%s/api/imagegraphs/%s/nodes", server.URL(), graphID),
+			"application/json",
+			bytes.NewReader([]byte(body)),
+		)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %d", resp.StatusCode)
+		}
+
+		graph := server.getImageGraph(t, graphID)
+		if nodes := graph["nodes"].([]interface{}); len(nodes) != 3 {
+			t.Errorf("expected rejected node not to be added, got %d nodes", len(nodes))
+		}
+	})
+
+	t.Run("rejects connections past the limit", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{
+			"from_node_id": inputID,
+			"output_name":  "original",
+			"to_node_id":   otherBlurID,
+			"input_name":   "original",
+		})
 		req, _ := http.NewRequest(
-			http.MethodPatch,
-			fmt.Sprintf("%s/api/imagegraphs/%s/nodes/%s", server.URL(), graphID, nodeID),
+			http.MethodPut,
+			fmt.Sprintf("%s/api/imagegraphs/%s/connectNodes", server.URL(), graphID),
 			bytes.NewReader(body),
 		)
-		req.Header.Set("Content-Type", "application/json")
-
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			t.Fatalf("request failed: %v", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusBadRequest {
-			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("allows replacing a connection at the limit", func(t *testing.T) {
+		server.connectNodes(t, graphID, otherBlurID, "blurred", blurID, "original")
+	})
+}
+
+func TestImageLimits(t *testing.T) {
+	server := setupTestServer(t, httpgateway.WithImageLimits(imagegraph.ImageLimits{MaxWidth: 100, MaxPixels: 2000}))
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Image Limits"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	encode := func(width, height int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	t.Run("rejects images past the limits", func(t *testing.T) {
+		for _, size := range []image.Point{{200, 4}, {50, 50}} {
+			_, err := c.UploadInputs(ctx, graphID, []client.InputUpload{
+				{Filename: "large.png", Data: encode(size.X, size.Y)},
+			}, client.UploadInputsOptions{})
+			if err == nil {
+				t.Errorf("expected a %v image to be rejected", size)
+			}
+		}
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if len(graph.Nodes) != 0 {
+			t.Errorf("expected no input nodes for rejected images, got %d", len(graph.Nodes))
+		}
+	})
+
+	t.Run("rejects animations whose frames together pass the limits", func(t *testing.T) {
+		frame := image.NewPaletted(image.Rect(0, 0, 40, 40), color.Palette{color.Black, color.White})
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+			t.Fatalf("failed to encode animation: %v", err)
+		}
+
+		_, err := c.UploadInputs(ctx, graphID, []client.InputUpload{
+			{Filename: "animated.gif", Data: buf.Bytes()},
+		}, client.UploadInputsOptions{})
+		if err == nil {
+			t.Error("expected an animation of two 40x40 frames to be rejected")
+		}
+	})
+
+	t.Run("accepts images within the limits", func(t *testing.T) {
+		nodeIDs, err := c.UploadInputs(ctx, graphID, []client.InputUpload{
+			{Filename: "small.png", Data: encode(40, 40)},
+		}, client.UploadInputsOptions{})
+		if err != nil {
+			t.Fatalf("failed to upload input: %v", err)
+		}
+		if len(nodeIDs) != 1 {
+			t.Errorf("expected 1 input node, got %v", nodeIDs)
+		}
+	})
+}
+
+func TestGraphValidation(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Validated"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	addNode := func(nodeType string) string {
+		node := client.NewNode{Name: nodeType, Type: nodeType}
+		if nodeType == "resize" {
+			node.Config = json.RawMessage(`{"width": 800, "interpolation": "Bilinear"}`)
+		}
+		nodeID, err := c.AddNode(ctx, graphID, node)
+		if err != nil {
+			t.Fatalf("failed to add %s node: %v", nodeType, err)
+		}
+		return nodeID
+	}
+	inputID, paletteID, resizeID, diffID := addNode("input"), addNode("palette_extract"), addNode("resize"), addNode("diff")
+
+	t.Run("rejects connections of the wrong image kind", func(t *testing.T) {
+		if err := c.ConnectNodes(ctx, graphID, client.Connection{
+			FromNodeID: inputID, OutputName: "original", ToNodeID: paletteID, InputName: "source",
+		}); err != nil {
+			t.Fatalf("failed to connect nodes: %v", err)
+		}
+
+		err := c.ConnectNodes(ctx, graphID, client.Connection{
+			FromNodeID: paletteID, OutputName: "palette", ToNodeID: resizeID, InputName: "original",
+		})
+		if client.StatusCode(err) != http.StatusUnprocessableEntity {
+			t.Errorf("expected a 422 error connecting a palette to a raster input, got %v", err)
+		}
+	})
+
+	t.Run("warns about inputs that don't match in size", func(t *testing.T) {
+		encode := func(width, height int) []byte {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+				t.Fatalf("failed to encode image: %v", err)
+			}
+			return buf.Bytes()
+		}
+
+		if _, err := c.UploadInputs(ctx, graphID, []client.InputUpload{
+			{Filename: "base.png", Data: encode(8, 6)},
+			{Filename: "compare.png", Data: encode(4, 3)},
+		}, client.UploadInputsOptions{ConnectTo: diffID}); err != nil {
+			t.Fatalf("failed to upload inputs: %v", err)
+		}
+
+		validation, err := c.ValidateImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to validate graph: %v", err)
+		}
+		if !validation.Valid {
+			t.Errorf("expected a graph with only warnings to be valid, got %+v", validation)
+		}
+		if len(validation.Connections) != 1 {
+			t.Fatalf("expected 1 connection with problems, got %+v", validation.Connections)
+		}
+		if v := validation.Connections[0]; v.ToNodeID != diffID || v.InputName != "compare" || len(v.Warnings) != 1 {
+			t.Errorf("expected a size warning on the diff compare input, got %+v", v)
+		}
+	})
+}
+
+func TestDiagnostics(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Diagnosed"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	resizeID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Shrink", Type: "resize", Config: json.RawMessage(`{"width": 800, "interpolation": "Bilinear"}`)})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	t.Run("reports problems with their nodes", func(t *testing.T) {
+		diagnostics, err := c.GetDiagnostics(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get diagnostics: %v", err)
+		}
+		if diagnostics.Errors != 1 || diagnostics.Warnings != 1 || len(diagnostics.Diagnostics) != 2 {
+			t.Fatalf("expected an error and a warning, got %+v", diagnostics)
+		}
+
+		d := diagnostics.Diagnostics[0]
+		if d.Kind != "unconnected_input" || d.Severity != "error" || d.NodeID != resizeID || d.NodeName != "Shrink" || d.Port != "original" {
+			t.Errorf("expected the resize node's unconnected input first, got %+v", d)
+		}
+	})
+
+	t.Run("rejects invalid stuck_after", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/diagnostics?stuck_after=soon", server.URL(), graphID))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestEstimate(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Estimated"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	upscaleID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Enlarge", Type: "upscale"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 6))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	if _, err := c.UploadInputs(ctx, graphID, []client.InputUpload{
+		{Filename: "small.png", Data: buf.Bytes()},
+	}, client.UploadInputsOptions{ConnectTo: upscaleID}); err != nil {
+		t.Fatalf("failed to upload inputs: %v", err)
+	}
+
+	estimate, err := c.EstimateImageGraph(ctx, graphID)
+	if err != nil {
+		t.Fatalf("failed to estimate graph: %v", err)
+	}
+	if len(estimate.Nodes) != 2 {
+		t.Fatalf("expected estimates for 2 nodes, got %+v", estimate.Nodes)
+	}
+
+	var upscale *client.NodeEstimate
+	for i := range estimate.Nodes {
+		if estimate.Nodes[i].NodeID == upscaleID {
+			upscale = &estimate.Nodes[i]
+		}
+	}
+	if upscale == nil {
+		t.Fatal("expected an estimate for the upscale node")
+	}
+
+	if upscale.NodeName != "Enlarge" || upscale.Type != "upscale" {
+		t.Errorf("expected the upscale node's name and type, got %+v", upscale)
+	}
+	if upscale.Inputs["original"] != (client.ImageSize{Width: 8, Height: 6}) {
+		t.Errorf("expected an 8x6 input, got %+v", upscale.Inputs)
+	}
+	if upscale.Output == nil || *upscale.Output != (client.ImageSize{Width: 32, Height: 24}) {
+		t.Errorf("expected a 32x24 output, got %+v", upscale.Output)
+	}
+	if upscale.MemoryBytes != (8*6+32*24)*4 {
+		t.Errorf("expected %d bytes, got %d", (8*6+32*24)*4, upscale.MemoryBytes)
+	}
+	if estimate.PeakMemoryBytes < upscale.MemoryBytes {
+		t.Errorf("expected a peak of at least %d bytes, got %d", upscale.MemoryBytes, estimate.PeakMemoryBytes)
+	}
+}
+
+func TestNodeStats(t *testing.T) {
+	runs := inmem.NewGenerationRunStore()
+	server := setupTestServer(t, httpgateway.WithGenerationRuns(runs))
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Timed"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	blurID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Soften", Type: "blur"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	parsedGraphID, err := imagegraph.ParseImageGraphID(graphID)
+	if err != nil {
+		t.Fatalf("failed to parse graph ID: %v", err)
+	}
+	parsedBlurID, err := imagegraph.ParseNodeID(blurID)
+	if err != nil {
+		t.Fatalf("failed to parse node ID: %v", err)
+	}
+
+	startedAt := time.Now().Add(-time.Minute)
+	for i, duration := range []time.Duration{200 * time.Millisecond, 400 * time.Millisecond} {
+		if err := runs.Add(ctx, application.GenerationRun{
+			ImageGraphID: parsedGraphID,
+			NodeID:       parsedBlurID,
+			NodeType:     imagegraph.NodeTypeBlur,
+			NodeVersion:  imagegraph.NodeVersion(i + 1),
+			StartedAt:    startedAt.Add(time.Duration(i) * time.Second),
+			Duration:     duration,
+			InputPixels:  1_000_000,
+			OutputPixels: 1_000_000,
+		}); err != nil {
+			t.Fatalf("failed to add run: %v", err)
+		}
+	}
+
+	t.Run("summarises the node's runs", func(t *testing.T) {
+		stats, err := c.GetNodeStats(ctx, graphID, blurID)
+		if err != nil {
+			t.Fatalf("failed to get node stats: %v", err)
+		}
+
+		if stats.Runs != 2 || stats.MeanMs != 300 || stats.LastMs != 400 || stats.MsPerMegapixel != 300 {
+			t.Errorf("expected 2 runs averaging 300ms, got %+v", stats)
+		}
+		if len(stats.History) != 2 || stats.History[0].NodeVersion != 2 {
+			t.Errorf("expected the newest run first, got %+v", stats.History)
+		}
+	})
+
+	t.Run("feeds the node's timings to estimates", func(t *testing.T) {
+		estimate, err := c.EstimateImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to estimate graph: %v", err)
+		}
+		if len(estimate.Nodes) != 1 || estimate.Nodes[0].TimedGenerations != 2 {
+			t.Errorf("expected the estimate to use the node's 2 runs, got %+v", estimate.Nodes)
+		}
+	})
+
+	t.Run("rejects an invalid limit", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/nodes/%s/stats?limit=0", server.URL(), graphID, blurID))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("returns 404 for unknown nodes", func(t *testing.T) {
+		_, err := c.GetNodeStats(ctx, graphID, imagegraph.MustNewNodeID().String())
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error, got %v", err)
+		}
+	})
+}
+
+func TestImageGraphConditionalGet(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Polled"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	get := func(ifNoneMatch string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL()+"/api/imagegraphs/"+graphID, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get("")
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", resp.StatusCode, etag)
+	}
+
+	if resp := get(etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged graph, got %d", resp.StatusCode)
+	}
+
+	if _, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Photo", Type: "input"}); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	resp = get(etag)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for a changed graph, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") == etag {
+		t.Errorf("expected a new ETag for a changed graph, got %q", etag)
+	}
+}
+
+func TestImageGraphSummaryView(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Listed"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	nodeID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Photo", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	graph, err := c.GetImageGraphSummary(ctx, graphID)
+	if err != nil {
+		t.Fatalf("failed to get graph summary: %v", err)
+	}
+	if graph.Name != "Listed" || len(graph.Nodes) != 1 {
+		t.Fatalf("expected the graph with its node, got %+v", graph)
+	}
+	node := graph.Nodes[0]
+	if node.ID != nodeID || node.Name != "Photo" || node.Type != "input" || node.State == "" {
+		t.Errorf("expected the node's ID, name, type and state, got %+v", node)
+	}
+	if node.Outputs != nil || node.Inputs != nil {
+		t.Errorf("expected no inputs or outputs, got %+v", node)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL()+"/api/imagegraphs/"+graphID+"?view=everything", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to get graph: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for an unknown view, got %d", resp.StatusCode)
+	}
+}
+
+func TestOutputHistory(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Revised"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Photo", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	var uploads []string
+	for width := range 2 {
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, width+1, 1))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		imageID, err := c.UploadOutputImage(ctx, graphID, inputID, "original", "photo.png", photo.Bytes())
+		if err != nil {
+			t.Fatalf("failed to upload image: %v", err)
+		}
+		uploads = append(uploads, imageID)
+	}
+
+	t.Run("lists the images an output replaced", func(t *testing.T) {
+		history, err := c.GetOutputHistory(ctx, graphID, inputID, "original")
+		if err != nil {
+			t.Fatalf("failed to get output history: %v", err)
+		}
+
+		if history.ImageID != uploads[1] {
+			t.Errorf("expected the last upload to be current, got %s", history.ImageID)
+		}
+		if len(history.History) != 1 || history.History[0].ImageID != uploads[0] {
+			t.Fatalf("expected the first upload in the history, got %+v", history.History)
+		}
+		if history.History[0].URL != "/api/images/"+uploads[0] {
+			t.Errorf("expected the previous image's URL, got %q", history.History[0].URL)
+		}
+
+		if _, err := c.GetImage(ctx, uploads[0]); err != nil {
+			t.Errorf("expected the replaced image to be kept, got %v", err)
+		}
+	})
+
+	t.Run("promotes a previous image", func(t *testing.T) {
+		if err := c.PromoteOutputVariant(ctx, graphID, inputID, "original", uploads[0]); err != nil {
+			t.Fatalf("failed to promote image: %v", err)
+		}
+
+		history, err := c.GetOutputHistory(ctx, graphID, inputID, "original")
+		if err != nil {
+			t.Fatalf("failed to get output history: %v", err)
+		}
+		if history.ImageID != uploads[0] {
+			t.Errorf("expected the promoted image to be current, got %s", history.ImageID)
+		}
+		if len(history.History) != 1 || history.History[0].ImageID != uploads[1] {
+			t.Errorf("expected the replaced upload in the history, got %+v", history.History)
+		}
+	})
+
+	t.Run("returns 404 for images not in the history", func(t *testing.T) {
+		err := c.PromoteOutputVariant(ctx, graphID, inputID, "original", imagegraph.MustNewImageID().String())
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error, got %v", err)
+		}
+
+		_, err = c.GetOutputHistory(ctx, graphID, inputID, "missing")
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error for an unknown output, got %v", err)
+		}
+	})
+
+	t.Run("serves the current image of an output", func(t *testing.T) {
+		current, err := c.GetOutputImage(ctx, graphID, inputID, "original")
+		if err != nil {
+			t.Fatalf("failed to get output image: %v", err)
+		}
+		promoted, err := c.GetImage(ctx, uploads[0])
+		if err != nil {
+			t.Fatalf("failed to get image: %v", err)
+		}
+		if !bytes.Equal(current, promoted) {
+			t.Error("expected the output's current image")
+		}
+
+		_, err = c.GetOutputImage(ctx, graphID, inputID, "missing")
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error for an unknown output, got %v", err)
+		}
+	})
+
+	t.Run("clears an output's image downstream", func(t *testing.T) {
+		resizeID, err := c.AddNode(ctx, graphID, client.NewNode{
+			Name: "Resize", Type: "resize", Config: json.RawMessage(`{"width": 800, "interpolation": "Bilinear"}`),
+		})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		if err := c.ConnectNodes(ctx, graphID, client.Connection{
+			FromNodeID: inputID, OutputName: "original", ToNodeID: resizeID, InputName: "original",
+		}); err != nil {
+			t.Fatalf("failed to connect nodes: %v", err)
+		}
+		server.settle(t)
+
+		pinned, unpinned := true, false
+		if err := c.UpdateNode(ctx, graphID, inputID, client.NodeUpdate{Pinned: &pinned}); err != nil {
+			t.Fatalf("failed to pin node: %v", err)
+		}
+		err = c.ClearOutputImage(ctx, graphID, inputID, "original")
+		if client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected a 409 error clearing a pinned node's output, got %v", err)
+		}
+		if err := c.UpdateNode(ctx, graphID, inputID, client.NodeUpdate{Pinned: &unpinned}); err != nil {
+			t.Fatalf("failed to unpin node: %v", err)
+		}
+		server.settle(t)
+
+		if err := c.ClearOutputImage(ctx, graphID, inputID, "original"); err != nil {
+			t.Fatalf("failed to clear output image: %v", err)
+		}
+		server.settle(t)
+
+		history, err := c.GetOutputHistory(ctx, graphID, inputID, "original")
+		if err != nil {
+			t.Fatalf("failed to get output history: %v", err)
+		}
+		if history.ImageID != "" || len(history.History) != 2 {
+			t.Errorf("expected the cleared image in the history, got %+v", history)
+		}
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		resize, _ := graph.Node(resizeID)
+		if len(resize.Inputs) == 0 || resize.Inputs[0].ImageID != "" {
+			t.Errorf("expected the downstream input to be cleared, got %+v", resize.Inputs)
+		}
+
+		_, err = c.GetOutputImage(ctx, graphID, inputID, "original")
+		if client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected a 409 error for a cleared output, got %v", err)
+		}
+
+		err = c.ClearOutputImage(ctx, graphID, inputID, "missing")
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error for an unknown output, got %v", err)
+		}
+	})
+}
+
+func TestSnapshots(t *testing.T) {
+	server := setupTestServer(t, httpgateway.WithSnapshots(inmem.NewSnapshotStore()))
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Compared"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Photo", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	upload := func(width int) string {
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, width, 1))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		imageID, err := c.UploadOutputImage(ctx, graphID, inputID, "original", "photo.png", photo.Bytes())
+		if err != nil {
+			t.Fatalf("failed to upload image: %v", err)
+		}
+		return imageID
+	}
+
+	firstImageID := upload(1)
+
+	before, err := c.CreateSnapshot(ctx, graphID, " Before ")
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+
+	t.Run("copies the output images", func(t *testing.T) {
+		if before.Name != "Before" || len(before.Nodes) != 1 || len(before.Nodes[0].Outputs) != 1 {
+			t.Fatalf("expected a snapshot of the input node, got %+v", before)
+		}
+
+		output := before.Nodes[0].Outputs[0]
+		if output.ImageID == "" || output.ImageID == firstImageID {
+			t.Errorf("expected a copy of the output image, got %q", output.ImageID)
+		}
+		if _, err := c.GetImage(ctx, output.ImageID); err != nil {
+			t.Errorf("expected the copy to be stored, got %v", err)
+		}
+	})
+
+	t.Run("rejects taken and empty names", func(t *testing.T) {
+		_, err := c.CreateSnapshot(ctx, graphID, "Before")
+		if client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected a 409 error, got %v", err)
+		}
+
+		_, err = c.CreateSnapshot(ctx, graphID, "  ")
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error, got %v", err)
+		}
+	})
+
+	upload(2)
+
+	after, err := c.CreateSnapshot(ctx, graphID, "After")
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+
+	t.Run("lists snapshots oldest first", func(t *testing.T) {
+		snapshots, err := c.ListSnapshots(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to list snapshots: %v", err)
+		}
+		if len(snapshots) != 2 || snapshots[0].ID != before.ID || snapshots[1].ID != after.ID {
+			t.Fatalf("expected both snapshots in order, got %+v", snapshots)
+		}
+		if snapshots[0].Nodes != 1 || snapshots[0].Images != 1 {
+			t.Errorf("expected 1 node with 1 image, got %+v", snapshots[0])
+		}
+	})
+
+	t.Run("compares two snapshots", func(t *testing.T) {
+		comparison, err := c.CompareSnapshots(ctx, graphID, before.ID, after.ID)
+		if err != nil {
+			t.Fatalf("failed to compare snapshots: %v", err)
+		}
+
+		if comparison.Changed != 1 || len(comparison.Nodes) != 1 {
+			t.Fatalf("expected the input node to have changed, got %+v", comparison)
+		}
+		output := comparison.Nodes[0].Outputs[0]
+		if !output.Changed || output.FromImageID != before.Nodes[0].Outputs[0].ImageID || output.ToImageID != after.Nodes[0].Outputs[0].ImageID {
+			t.Errorf("expected the snapshots' copies to be compared, got %+v", output)
+		}
+		if output.Difference == nil {
+			t.Error("expected the images' difference to be measured")
+		}
+	})
+
+	t.Run("compares a snapshot with the current graph", func(t *testing.T) {
+		comparison, err := c.CompareSnapshots(ctx, graphID, after.ID, "")
+		if err != nil {
+			t.Fatalf("failed to compare snapshot: %v", err)
+		}
+
+		if comparison.ToSnapshotID != "" || comparison.Unchanged != 1 {
+			t.Errorf("expected the graph to be unchanged since the last snapshot, got %+v", comparison)
+		}
+	})
+
+	t.Run("returns 404 for unknown snapshots", func(t *testing.T) {
+		_, err := c.GetSnapshot(ctx, graphID, "not-a-snapshot")
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error, got %v", err)
+		}
+
+		_, err = c.CompareSnapshots(ctx, graphID, before.ID, imagegraph.MustNewImageID().String())
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error comparing with an unknown snapshot, got %v", err)
+		}
+	})
+}
+
+func TestPipelineRuns(t *testing.T) {
+	runs := inmem.NewPipelineRunStore()
+	server := setupTestServer(t, httpgateway.WithPipelineRuns(runs))
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Runs"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	parsedGraphID, err := imagegraph.ParseImageGraphID(graphID)
+	if err != nil {
+		t.Fatalf("failed to parse graph ID: %v", err)
+	}
+
+	outputNodeID := imagegraph.MustNewNodeID()
+	outputImageID := imagegraph.MustNewImageID()
+	startedAt := time.Now().Add(-time.Minute).UTC()
+
+	for i, status := range []string{application.PipelineRunSuperseded, application.PipelineRunCompleted} {
+		run := application.PipelineRun{
+			ID:             imagegraph.MustNewImageID().String(),
+			ImageGraphID:   parsedGraphID,
+			TriggerNodeID:  imagegraph.MustNewNodeID(),
+			TriggerImageID: imagegraph.MustNewImageID(),
+			StartedAt:      startedAt.Add(time.Duration(i) * time.Second),
+			EndedAt:        startedAt.Add(time.Duration(i)*time.Second + 500*time.Millisecond),
+			Status:         status,
+		}
+		if status == application.PipelineRunCompleted {
+			run.Nodes = []application.PipelineRunNode{{
+				NodeID:      outputNodeID,
+				NodeType:    imagegraph.NodeTypeOutput,
+				StartedAt:   run.StartedAt,
+				EndedAt:     run.StartedAt.Add(200 * time.Millisecond),
+				Generations: 1,
+			}}
+			run.Outputs = []application.CompletedOutput{{
+				Name:     "poster",
+				NodeID:   outputNodeID,
+				ImageID:  outputImageID,
+				Duration: 200 * time.Millisecond,
+			}}
+		}
+		if err := runs.Add(ctx, run); err != nil {
+			t.Fatalf("failed to add run: %v", err)
+		}
+	}
+
+	t.Run("lists the graph's runs newest first", func(t *testing.T) {
+		listed, err := c.ListRuns(ctx, graphID, 0)
+		if err != nil {
+			t.Fatalf("failed to list runs: %v", err)
+		}
+
+		if len(listed) != 2 {
+			t.Fatalf("expected 2 runs, got %d", len(listed))
+		}
+
+		run := listed[0]
+		if run.Status != application.PipelineRunCompleted || run.DurationMs != 500 {
+			t.Errorf("expected the completed run first taking 500ms, got %+v", run)
+		}
+		if len(run.Nodes) != 1 || run.Nodes[0].Type != "output" || run.Nodes[0].DurationMs != 200 {
+			t.Errorf("expected the output node taking 200ms, got %+v", run.Nodes)
+		}
+		if len(run.Outputs) != 1 || run.Outputs[0].URL != "/api/images/"+outputImageID.String() {
+			t.Errorf("expected the poster output with its URL, got %+v", run.Outputs)
+		}
+		if listed[1].Status != application.PipelineRunSuperseded || listed[1].Nodes == nil {
+			t.Errorf("expected the superseded run last with empty nodes, got %+v", listed[1])
+		}
+	})
+
+	t.Run("limits the runs listed", func(t *testing.T) {
+		listed, err := c.ListRuns(ctx, graphID, 1)
+		if err != nil {
+			t.Fatalf("failed to list runs: %v", err)
+		}
+		if len(listed) != 1 {
+			t.Errorf("expected 1 run, got %d", len(listed))
+		}
+	})
+
+	t.Run("rejects an invalid limit", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/runs?limit=0", server.URL(), graphID))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("returns 404 for unknown graphs", func(t *testing.T) {
+		_, err := c.ListRuns(ctx, imagegraph.MustNewImageGraphID().String(), 0)
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error, got %v", err)
+		}
+	})
+}
+
+func TestExportsArchive(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	graphID := server.createImageGraph(t, "Archive Graph")
+	server.addNode(t, graphID, "output", "Not Generated", "{}")
+
+	t.Run("streams a zip of generated exports", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/exports/archive", server.URL(), graphID))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/zip" {
+			t.Errorf("expected application/zip content type, got %q", ct)
+		}
+		if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "Archive_Graph.zip") {
+			t.Errorf("expected archive to be named after the graph, got %q", cd)
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("failed to open archive: %v", err)
+		}
+
+		// Output nodes without a final image aren't exported
+		if len(archive.File) != 0 {
+			t.Errorf("expected empty archive, got %d entries", len(archive.File))
+		}
+	})
+
+	t.Run("404 for unknown graph", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s/exports/archive", server.URL(), imagegraph.MustNewImageGraphID()))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestGallery(t *testing.T) {
+	server := setupTestServer(t, httpgateway.WithGallery(60, 5))
+	defer server.Stop()
+
+	graphID := server.createImageGraph(t, "Gallery Graph")
+
+	get := func(t *testing.T, path string) *http.Response {
+		t.Helper()
+
+		resp, err := http.Get(server.URL() + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	setPublic := func(t *testing.T, public bool) {
+		t.Helper()
+
+		body, _ := json.Marshal(map[string]bool{"public": public})
+		req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/api/imagegraphs/%s/public", server.URL(), graphID), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", resp.StatusCode)
+		}
+	}
+
+	t.Run("private graphs are not in the gallery", func(t *testing.T) {
+		resp := get(t, "/api/gallery/"+graphID)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("public graphs are listed without their structure", func(t *testing.T) {
+		setPublic(t, true)
+
+		resp := get(t, "/api/gallery")
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var index struct {
+			Graphs []map[string]interface{} `json:"graphs"`
+		}
+		json.NewDecoder(resp.Body).Decode(&index)
+		if len(index.Graphs) != 1 || index.Graphs[0]["id"] != graphID {
+			t.Fatalf("expected gallery to list graph %s, got %v", graphID, index.Graphs)
+		}
+		if _, ok := index.Graphs[0]["nodes"]; ok {
+			t.Error("expected gallery listing to omit graph structure")
+		}
+	})
+
+	t.Run("rate limits gallery requests per client", func(t *testing.T) {
+		var lastStatus int
+		for range 10 {
+			resp := get(t, "/api/gallery")
+			resp.Body.Close()
+			lastStatus = resp.StatusCode
+		}
+
+		if lastStatus != http.StatusTooManyRequests {
+			t.Errorf("expected status 429, got %d", lastStatus)
+		}
+	})
+}
+
+func TestTags(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	pixelGraphID := server.createImageGraph(t, "Pixel Graph")
+	otherGraphID := server.createImageGraph(t, "Other Graph")
+	nodeID := server.addNode(t, pixelGraphID, "input", "Input", "{}")
+
+	send := func(t *testing.T, method, path string) int {
+		t.Helper()
+
+		req, _ := http.NewRequest(method, server.URL()+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	listTagged := func(t *testing.T, tag string) []map[string]interface{} {
+		t.Helper()
+
+		resp, err := http.Get(server.URL() + "/api/imagegraphs?tag=" + tag)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var list struct {
+			ImageGraphs []map[string]interface{} `json:"imagegraphs"`
+		}
+		json.NewDecoder(resp.Body).Decode(&list)
+		return list.ImageGraphs
+	}
+
+	t.Run("lists graphs by tag", func(t *testing.T) {
+		if status := send(t, http.MethodPut, "/api/imagegraphs/"+pixelGraphID+"/tags/PixelArt"); status != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", status)
+		}
+
+		graphs := listTagged(t, "pixelart")
+		if len(graphs) != 1 || graphs[0]["id"] != pixelGraphID {
+			t.Fatalf("expected only graph %s to be tagged, got %v", pixelGraphID, graphs)
+		}
+
+		graph := server.getImageGraph(t, pixelGraphID)
+		tags, _ := graph["tags"].([]interface{})
+		if len(tags) != 1 || tags[0] != "pixelart" {
+			t.Errorf("expected normalized tags [pixelart], got %v", graph["tags"])
+		}
+
+		if graphs := listTagged(t, "landscape"); len(graphs) != 0 {
+			t.Errorf("expected no graphs tagged landscape, got %v", graphs)
+		}
+	})
+
+	t.Run("removing a tag unlists the graph", func(t *testing.T) {
+		if status := send(t, http.MethodDelete, "/api/imagegraphs/"+pixelGraphID+"/tags/pixelart"); status != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", status)
+		}
+
+		if graphs := listTagged(t, "pixelart"); len(graphs) != 0 {
+			t.Errorf("expected no graphs tagged pixelart, got %v", graphs)
+		}
+	})
+
+	t.Run("tags nodes", func(t *testing.T) {
+		path := fmt.Sprintf("/api/imagegraphs/%s/nodes/%s/tags/source", pixelGraphID, nodeID)
+		if status := send(t, http.MethodPut, path); status != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", status)
+		}
+
+		graph := server.getImageGraph(t, pixelGraphID)
+		node := graph["nodes"].([]interface{})[0].(map[string]interface{})
+		tags, _ := node["tags"].([]interface{})
+		if len(tags) != 1 || tags[0] != "source" {
+			t.Errorf("expected node tags [source], got %v", node["tags"])
+		}
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		if status := send(t, http.MethodPut, "/api/imagegraphs/"+otherGraphID+"/tags/no%20spaces"); status != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", status)
+		}
+
+		resp, err := http.Get(server.URL() + "/api/imagegraphs?tag=no%21")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestListImageGraphsPagination(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	charlieID := server.createImageGraph(t, "Charlie")
+	server.createImageGraph(t, "Alpha")
+	server.createImageGraph(t, "Bravo")
+
+	list := func(t *testing.T, query string) (int, []string, int) {
+		t.Helper()
+
+		resp, err := http.Get(server.URL() + "/api/imagegraphs?" + query)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			ImageGraphs []map[string]interface{} `json:"imagegraphs"`
+			Total       int                      `json:"total"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+
+		var names []string
+		for _, graph := range result.ImageGraphs {
+			names = append(names, graph["name"].(string))
+		}
+		return resp.StatusCode, names, result.Total
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantNames []string
+	}{
+		{"newest first by default", "", []string{"Bravo", "Alpha", "Charlie"}},
+		{"by name ascending", "sort=name", []string{"Alpha", "Bravo", "Charlie"}},
+		{"by name descending", "sort=name&order=desc", []string{"Charlie", "Bravo", "Alpha"}},
+		{"oldest first", "sort=created&order=asc", []string{"Charlie", "Alpha", "Bravo"}},
+		{"first page", "sort=name&limit=2", []string{"Alpha", "Bravo"}},
+		{"second page", "sort=name&limit=2&offset=2", []string{"Charlie"}},
+		{"past the end", "offset=10", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, names, total := list(t, tt.query)
+			if status != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", status)
+			}
+			if !slices.Equal(names, tt.wantNames) {
+				t.Errorf("expected %v, got %v", tt.wantNames, names)
+			}
+			if total != 3 {
+				t.Errorf("expected total 3, got %d", total)
+			}
+		})
+	}
+
+	t.Run("sorts by last update", func(t *testing.T) {
+		server.addNode(t, charlieID, "input", "Input", "{}")
+
+		_, names, _ := list(t, "sort=updated")
+		if len(names) != 3 || names[0] != "Charlie" {
+			t.Errorf("expected the updated graph first, got %v", names)
+		}
+	})
+
+	t.Run("counts nodes", func(t *testing.T) {
+		resp, err := http.Get(server.URL() + "/api/imagegraphs?sort=name&order=desc&limit=1")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			ImageGraphs []struct {
+				Name      string `json:"name"`
+				NodeCount int    `json:"node_count"`
+			} `json:"imagegraphs"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+
+		if len(result.ImageGraphs) != 1 || result.ImageGraphs[0].NodeCount != 1 {
+			t.Errorf("expected Charlie to have 1 node, got %+v", result.ImageGraphs)
+		}
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=501", "offset=-1", "sort=size", "order=up"} {
+			if status, _, _ := list(t, query); status != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %q, got %d", query, status)
+			}
+		}
+	})
+}
+
+// setupAuthTestServer starts a test server that authenticates the users
+// alice, bob and carol and the admin root, whose tokens are their ID
+// followed by "-token", and lets them issue API keys
+func setupAuthTestServer(t *testing.T, opts ...httpgateway.ServerOption) *testServer {
+	t.Helper()
+
+	tokenHash := func(token string) string {
+		hash := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(hash[:])
+	}
+
+	authenticator, err := httpgateway.NewTokenAuthenticator([]httpgateway.TokenUser{
+		{ID: "alice", TokenSHA256: tokenHash("alice-token")},
+		{ID: "bob", TokenSHA256: tokenHash("bob-token")},
+		{ID: "carol", TokenSHA256: tokenHash("carol-token")},
+		{ID: "root", Admin: true, TokenSHA256: tokenHash("root-token")},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	return setupTestServer(t, append([]httpgateway.ServerOption{
+		httpgateway.WithAuthenticator(authenticator),
+		httpgateway.WithAPIKeys(inmem.NewAPIKeyStore(), authenticator),
+	}, opts...)...)
+}
+
+// sendAs sends a JSON request with a bearer token, or an API key when the
+// token starts with "awk_", returning the status and decoded response body
+func sendAs(
+	t *testing.T,
+	server *testServer,
+	token, method, path string,
+	body any,
+) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+
+	req, _ := http.NewRequest(method, server.URL()+path, reader)
+	if strings.HasPrefix(token, "awk_") {
+		req.Header.Set(httpgateway.APIKeyHeader, token)
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestAuthentication(t *testing.T) {
+	server := setupAuthTestServer(t)
+	defer server.Stop()
+
+	send := func(t *testing.T, token, method, path string, body any) (int, map[string]interface{}) {
+		t.Helper()
+		return sendAs(t, server, token, method, path, body)
+	}
+
+	listNames := func(t *testing.T, token string) []string {
+		t.Helper()
+
+		status, result := send(t, token, http.MethodGet, "/api/imagegraphs", nil)
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", status)
+		}
+
+		var names []string
+		for _, graph := range result["imagegraphs"].([]interface{}) {
+			names = append(names, graph.(map[string]interface{})["name"].(string))
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	_, created := send(t, "alice-token", http.MethodPost, "/api/imagegraphs", map[string]string{"name": "Alice Graph"})
+	aliceGraphID, _ := created["id"].(string)
+	send(t, "bob-token", http.MethodPost, "/api/imagegraphs", map[string]string{"name": "Bob Graph"})
+
+	t.Run("requires credentials", func(t *testing.T) {
+		if status, _ := send(t, "", http.MethodGet, "/api/imagegraphs", nil); status != http.StatusUnauthorized {
+			t.Errorf("expected status 401 without a token, got %d", status)
+		}
+		if status, _ := send(t, "wrong-token", http.MethodGet, "/api/imagegraphs", nil); status != http.StatusUnauthorized {
+			t.Errorf("expected status 401 with an unknown token, got %d", status)
+		}
+	})
+
+	t.Run("reports the current user", func(t *testing.T) {
+		_, me := send(t, "alice-token", http.MethodGet, "/api/me", nil)
+		if me["auth_enabled"] != true || me["id"] != "alice" {
+			t.Errorf("expected alice, got %v", me)
+		}
+	})
+
+	t.Run("accepts the token cookie", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL()+"/api/imagegraphs/"+aliceGraphID, nil)
+		req.AddCookie(&http.Cookie{Name: httpgateway.TokenCookieName, Value: "alice-token"})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status 200, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("scopes lists to the owner", func(t *testing.T) {
+		if names := listNames(t, "alice-token"); !slices.Equal(names, []string{"Alice Graph"}) {
+			t.Errorf("expected alice to list only her graph, got %v", names)
+		}
+		if names := listNames(t, "root-token"); !slices.Equal(names, []string{"Alice Graph", "Bob Graph"}) {
+			t.Errorf("expected the admin to list every graph, got %v", names)
+		}
+	})
+
+	t.Run("hides other users' graphs", func(t *testing.T) {
+		status, graph := send(t, "alice-token", http.MethodGet, "/api/imagegraphs/"+aliceGraphID, nil)
+		if status != http.StatusOK || graph["owner"] != "alice" {
+			t.Errorf("expected alice to get her graph, got %d %v", status, graph)
+		}
+
+		if status, _ := send(t, "bob-token", http.MethodGet, "/api/imagegraphs/"+aliceGraphID, nil); status != http.StatusNotFound {
+			t.Errorf("expected status 404 for another user's graph, got %d", status)
+		}
+
+		addNode := map[string]interface{}{"type": "input", "name": "Input", "config": map[string]interface{}{}}
+		if status, _ := send(t, "bob-token", http.MethodPost, "/api/imagegraphs/"+aliceGraphID+"/nodes", addNode); status != http.StatusNotFound {
+			t.Errorf("expected status 404 changing another user's graph, got %d", status)
+		}
+
+		if status, _ := send(t, "root-token", http.MethodGet, "/api/imagegraphs/"+aliceGraphID, nil); status != http.StatusOK {
+			t.Errorf("expected the admin to get any graph, got %d", status)
+		}
+	})
+
+	t.Run("keeps the gallery public", func(t *testing.T) {
+		if status, _ := send(t, "", http.MethodGet, "/api/gallery", nil); status == http.StatusUnauthorized {
+			t.Error("expected the gallery not to require credentials")
+		}
+	})
+}
+
+func TestSharing(t *testing.T) {
+	server := setupAuthTestServer(t)
+	defer server.Stop()
+
+	_, created := sendAs(t, server, "alice-token", http.MethodPost, "/api/imagegraphs", map[string]string{"name": "Shared Graph"})
+	graphID, _ := created["id"].(string)
+	graphPath := "/api/imagegraphs/" + graphID
+	addNode := map[string]interface{}{"type": "input", "name": "Input", "config": map[string]interface{}{}}
+
+	share := func(t *testing.T, token, userID, role string) int {
+		t.Helper()
+		status, _ := sendAs(t, server, token, http.MethodPut, graphPath+"/shares/"+userID, map[string]string{"role": role})
+		return status
+	}
+
+	if status := share(t, "alice-token", "bob", "viewer"); status != http.StatusNoContent {
+		t.Fatalf("expected status 204 sharing, got %d", status)
+	}
+	if status := share(t, "alice-token", "carol", "editor"); status != http.StatusNoContent {
+		t.Fatalf("expected status 204 sharing, got %d", status)
+	}
+
+	t.Run("lists shares", func(t *testing.T) {
+		_, result := sendAs(t, server, "bob-token", http.MethodGet, graphPath+"/shares", nil)
+		shares, _ := result["shares"].([]interface{})
+		if len(shares) != 2 {
+			t.Fatalf("expected 2 shares, got %v", result)
+		}
+		first := shares[0].(map[string]interface{})
+		if first["user_id"] != "bob" || first["role"] != "viewer" {
+			t.Errorf("expected bob as a viewer first, got %v", first)
+		}
+	})
+
+	t.Run("viewers can read but not change", func(t *testing.T) {
+		status, graph := sendAs(t, server, "bob-token", http.MethodGet, graphPath, nil)
+		if status != http.StatusOK || graph["role"] != "viewer" {
+			t.Errorf("expected bob to view the graph as a viewer, got %d %v", status, graph)
+		}
+
+		_, list := sendAs(t, server, "bob-token", http.MethodGet, "/api/imagegraphs", nil)
+		if graphs, _ := list["imagegraphs"].([]interface{}); len(graphs) != 1 {
+			t.Errorf("expected bob to list the shared graph, got %v", list)
+		}
+
+		if status, _ := sendAs(t, server, "bob-token", http.MethodPost, graphPath+"/nodes", addNode); status != http.StatusForbidden {
+			t.Errorf("expected status 403 adding a node as a viewer, got %d", status)
+		}
+	})
+
+	t.Run("editors can change but not share", func(t *testing.T) {
+		if status, _ := sendAs(t, server, "carol-token", http.MethodPost, graphPath+"/nodes", addNode); status != http.StatusCreated {
+			t.Errorf("expected status 201 adding a node as an editor, got %d", status)
+		}
+
+		if status := share(t, "carol-token", "bob", "editor"); status != http.StatusForbidden {
+			t.Errorf("expected status 403 sharing as an editor, got %d", status)
+		}
+
+		if status, _ := sendAs(t, server, "carol-token", http.MethodPut, graphPath+"/public", map[string]bool{"public": true}); status != http.StatusForbidden {
+			t.Errorf("expected status 403 publishing as an editor, got %d", status)
+		}
+	})
+
+	t.Run("rejects invalid shares", func(t *testing.T) {
+		if status := share(t, "alice-token", "bob", "owner"); status != http.StatusBadRequest {
+			t.Errorf("expected status 400 granting ownership, got %d", status)
+		}
+		if status := share(t, "alice-token", "alice", "viewer"); status != http.StatusBadRequest {
+			t.Errorf("expected status 400 sharing with the owner, got %d", status)
+		}
+	})
+
+	t.Run("revokes shares", func(t *testing.T) {
+		for range 2 {
+			if status, _ := sendAs(t, server, "alice-token", http.MethodDelete, graphPath+"/shares/bob", nil); status != http.StatusNoContent {
+				t.Errorf("expected status 204 unsharing, got %d", status)
+			}
+		}
+
+		if status, _ := sendAs(t, server, "bob-token", http.MethodGet, graphPath, nil); status != http.StatusNotFound {
+			t.Errorf("expected status 404 after unsharing, got %d", status)
+		}
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	server := setupAuthTestServer(t)
+	defer server.Stop()
+
+	issue := func(t *testing.T, body map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		status, key := sendAs(t, server, "alice-token", http.MethodPost, "/api/keys", body)
+		if status != http.StatusCreated {
+			t.Fatalf("expected status 201 issuing a key, got %d: %v", status, key)
+		}
+		return key
+	}
+
+	writeKey := issue(t, map[string]interface{}{"name": "pipeline", "scopes": []string{"read", "write"}})
+	readKey := issue(t, map[string]interface{}{"name": "reporting", "scopes": []string{"read"}})
+	secret, _ := writeKey["key"].(string)
+
+	t.Run("issues keys", func(t *testing.T) {
+		if !strings.HasPrefix(secret, "awk_") || !strings.HasPrefix(secret, writeKey["prefix"].(string)) {
+			t.Errorf("expected an awk_ key starting with its prefix, got %v", writeKey)
+		}
+		if writeKey["rate_limit"] != float64(120) {
+			t.Errorf("expected the default rate limit, got %v", writeKey["rate_limit"])
+		}
+
+		_, list := sendAs(t, server, "alice-token", http.MethodGet, "/api/keys", nil)
+		keys, _ := list["keys"].([]interface{})
+		if len(keys) != 2 {
+			t.Fatalf("expected 2 keys, got %v", list)
+		}
+		if _, ok := keys[0].(map[string]interface{})["key"]; ok {
+			t.Error("expected listed keys to omit their secret")
+		}
+
+		_, bobList := sendAs(t, server, "bob-token", http.MethodGet, "/api/keys", nil)
+		if keys, _ := bobList["keys"].([]interface{}); len(keys) != 0 {
+			t.Errorf("expected bob to have no keys, got %v", bobList)
+		}
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"name": "", "scopes": []string{"read"}},
+			{"name": "no scopes"},
+			{"name": "bad scope", "scopes": []string{"admin"}},
+			{"name": "too fast", "scopes": []string{"read"}, "rate_limit": 100000},
+		} {
+			if status, _ := sendAs(t, server, "alice-token", http.MethodPost, "/api/keys", body); status != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %v, got %d", body, status)
+			}
+		}
+	})
+
+	t.Run("acts as the issuing user", func(t *testing.T) {
+		status, created := sendAs(t, server, secret, http.MethodPost, "/api/imagegraphs", map[string]string{"name": "Scripted"})
+		if status != http.StatusCreated {
+			t.Fatalf("expected status 201 creating a graph with a key, got %d", status)
+		}
+
+		_, graph := sendAs(t, server, "alice-token", http.MethodGet, "/api/imagegraphs/"+created["id"].(string), nil)
+		if graph["owner"] != "alice" {
+			t.Errorf("expected the graph to be owned by alice, got %v", graph["owner"])
+		}
+	})
+
+	t.Run("enforces scopes", func(t *testing.T) {
+		readSecret := readKey["key"].(string)
+
+		if status, _ := sendAs(t, server, readSecret, http.MethodGet, "/api/imagegraphs", nil); status != http.StatusOK {
+			t.Errorf("expected status 200 reading with a read key, got %d", status)
+		}
+		if status, _ := sendAs(t, server, readSecret, http.MethodPost, "/api/imagegraphs", map[string]string{"name": "x"}); status != http.StatusForbidden {
+			t.Errorf("expected status 403 writing with a read key, got %d", status)
+		}
+		if status, _ := sendAs(t, server, secret, http.MethodPost, "/api/keys", map[string]interface{}{"name": "more", "scopes": []string{"read"}}); status != http.StatusForbidden {
+			t.Errorf("expected status 403 issuing a key with a key, got %d", status)
+		}
+	})
+
+	t.Run("rate limits each key", func(t *testing.T) {
+		slowSecret := issue(t, map[string]interface{}{"name": "slow", "scopes": []string{"read"}, "rate_limit": 1})["key"].(string)
+
+		if status, _ := sendAs(t, server, slowSecret, http.MethodGet, "/api/imagegraphs", nil); status != http.StatusOK {
+			t.Errorf("expected the first request to succeed, got %d", status)
+		}
+		if status, _ := sendAs(t, server, slowSecret, http.MethodGet, "/api/imagegraphs", nil); status != http.StatusTooManyRequests {
+			t.Errorf("expected status 429 past the key's rate, got %d", status)
+		}
+		if status, _ := sendAs(t, server, secret, http.MethodGet, "/api/imagegraphs", nil); status != http.StatusOK {
+			t.Errorf("expected other keys to be unaffected, got %d", status)
+		}
+	})
+
+	t.Run("revokes keys", func(t *testing.T) {
+		keyPath := "/api/keys/" + writeKey["id"].(string)
+
+		if status, _ := sendAs(t, server, "bob-token", http.MethodDelete, keyPath, nil); status != http.StatusNotFound {
+			t.Errorf("expected status 404 revoking another user's key, got %d", status)
+		}
+		if status, _ := sendAs(t, server, "alice-token", http.MethodDelete, keyPath, nil); status != http.StatusNoContent {
+			t.Errorf("expected status 204 revoking, got %d", status)
+		}
+		if status, _ := sendAs(t, server, secret, http.MethodGet, "/api/imagegraphs", nil); status != http.StatusUnauthorized {
+			t.Errorf("expected status 401 with a revoked key, got %d", status)
+		}
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	server := setupAuthTestServer(t, httpgateway.WithWebhooks(inmem.NewWebhookStore()))
+	defer server.Stop()
+
+	_, created := sendAs(t, server, "alice-token", http.MethodPost, "/api/imagegraphs", map[string]string{"name": "Notifying Graph"})
+	webhooksPath := "/api/imagegraphs/" + created["id"].(string) + "/webhooks"
+
+	if status, _ := sendAs(t, server, "alice-token", http.MethodPut, "/api/imagegraphs/"+created["id"].(string)+"/shares/bob", map[string]string{"role": "viewer"}); status != http.StatusNoContent {
+		t.Fatalf("expected status 204 sharing, got %d", status)
+	}
+
+	status, generated := sendAs(t, server, "alice-token", http.MethodPost, webhooksPath, map[string]string{"url": "https://hooks.example.com/artwork"})
+	if status != http.StatusCreated {
+		t.Fatalf("expected status 201 registering a webhook, got %d: %v", status, generated)
+	}
+
+	t.Run("generates a secret when none is given", func(t *testing.T) {
+		if secret, _ := generated["secret"].(string); len(secret) < 32 {
+			t.Errorf("expected a generated secret, got %v", generated)
+		}
+
+		_, chosen := sendAs(t, server, "alice-token", http.MethodPost, webhooksPath, map[string]string{"url": "http://localhost:9000/hook", "secret": "s3cret"})
+		if chosen["secret"] != "s3cret" {
+			t.Errorf("expected the given secret, got %v", chosen)
+		}
+	})
+
+	t.Run("lists webhooks without their secrets", func(t *testing.T) {
+		_, list := sendAs(t, server, "alice-token", http.MethodGet, webhooksPath, nil)
+		webhooks, _ := list["webhooks"].([]interface{})
+		if len(webhooks) != 2 {
+			t.Fatalf("expected 2 webhooks, got %v", list)
+		}
+
+		first := webhooks[0].(map[string]interface{})
+		if first["url"] != "https://hooks.example.com/artwork" {
+			t.Errorf("expected webhooks oldest first, got %v", webhooks)
+		}
+		if _, ok := first["secret"]; ok {
+			t.Error("expected listed webhooks to omit their secret")
+		}
+	})
+
+	t.Run("rejects invalid webhooks", func(t *testing.T) {
+		for _, body := range []map[string]string{
+			{"url": ""},
+			{"url": "/relative"},
+			{"url": "ftp://files.example.com/hook"},
+			{"url": "https://hooks.example.com", "secret": strings.Repeat("s", 300)},
+		} {
+			if status, _ := sendAs(t, server, "alice-token", http.MethodPost, webhooksPath, body); status != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %v, got %d", body, status)
+			}
+		}
+	})
+
+	t.Run("requires the editor role", func(t *testing.T) {
+		if status, _ := sendAs(t, server, "bob-token", http.MethodGet, webhooksPath, nil); status != http.StatusForbidden {
+			t.Errorf("expected status 403 listing as a viewer, got %d", status)
+		}
+		if status, _ := sendAs(t, server, "carol-token", http.MethodGet, webhooksPath, nil); status != http.StatusNotFound {
+			t.Errorf("expected status 404 listing without access, got %d", status)
+		}
+	})
+
+	t.Run("removes webhooks", func(t *testing.T) {
+		webhookPath := webhooksPath + "/" + generated["id"].(string)
+
+		if status, _ := sendAs(t, server, "alice-token", http.MethodDelete, webhookPath, nil); status != http.StatusNoContent {
+			t.Errorf("expected status 204 removing, got %d", status)
+		}
+		if status, _ := sendAs(t, server, "alice-token", http.MethodDelete, webhookPath, nil); status != http.StatusNotFound {
+			t.Errorf("expected status 404 removing twice, got %d", status)
+		}
+		if status, _ := sendAs(t, server, "alice-token", http.MethodDelete, webhooksPath+"/not-a-webhook", nil); status != http.StatusNotFound {
+			t.Errorf("expected status 404 for an invalid ID, got %d", status)
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	server := setupTestServer(t, httpgateway.WithCORS(httpgateway.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		MaxAge:         time.Hour,
+	}))
+	defer server.Stop()
+
+	request := func(t *testing.T, method, origin string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL()+"/api/imagegraphs", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("answers preflights from allowed origins", func(t *testing.T) {
+		resp := request(t, http.MethodOptions, "https://app.example.com")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("expected the origin to be allowed, got %q", got)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Methods"); !strings.Contains(got, "PATCH") {
+			t.Errorf("expected PATCH in the allowed methods, got %q", got)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-API-Key") {
+			t.Errorf("expected X-API-Key in the allowed headers, got %q", got)
+		}
+		if got := resp.Header.Get("Access-Control-Max-Age"); got != "3600" {
+			t.Errorf("expected a max age of 3600, got %q", got)
+		}
+	})
+
+	t.Run("adds headers to requests from allowed origins", func(t *testing.T) {
+		resp := request(t, http.MethodGet, "https://app.example.com")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("expected credentials to be allowed, got %q", got)
+		}
+	})
+
+	t.Run("ignores other origins", func(t *testing.T) {
+		if resp := request(t, http.MethodOptions, "https://evil.example.com"); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status 403 for a preflight from another origin, got %d", resp.StatusCode)
+		}
+
+		resp := request(t, http.MethodGet, "https://evil.example.com")
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("expected no CORS headers for another origin, got %q", got)
+		}
+	})
+}
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := httpgateway.ParseTrustedProxies("127.0.0.1, ::1/128")
+	if err != nil {
+		t.Fatalf("failed to parse trusted proxies: %v", err)
+	}
+
+	// The gallery rate limits per client, which shows who the server
+	// thinks made each request
+	server := setupTestServer(t,
+		httpgateway.WithGallery(0, 1),
+		httpgateway.WithTrustedProxies(proxies),
+	)
+	defer server.Stop()
+
+	galleryStatus := func(t *testing.T, forwardedFor string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL()+"/api/gallery", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := galleryStatus(t, "203.0.113.1"); status != http.StatusOK {
+		t.Errorf("expected the first client's request to succeed, got %d", status)
+	}
+	if status := galleryStatus(t, "203.0.113.2, 127.0.0.1"); status != http.StatusOK {
+		t.Errorf("expected a second client's request to succeed, got %d", status)
+	}
+	if status := galleryStatus(t, "203.0.113.1"); status != http.StatusTooManyRequests {
+		t.Errorf("expected the first client to be rate limited, got %d", status)
+	}
+
+	if _, err := httpgateway.ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("expected an invalid network to be rejected")
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	server := setupAuthTestServer(
+		t,
+		httpgateway.WithGallery(600, 100),
+		httpgateway.WithWebhooks(inmem.NewWebhookStore()),
+		httpgateway.WithSnapshots(inmem.NewSnapshotStore()),
+		httpgateway.WithPipelineRuns(inmem.NewPipelineRunStore()),
+	)
+	defer server.Stop()
+
+	// The document and Swagger UI are public, like the gallery
+	resp, err := http.Get(server.URL() + "/api/openapi.json")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var document struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+
+	if !strings.HasPrefix(document.OpenAPI, "3.") {
+		t.Errorf("expected an OpenAPI 3 document, got version %q", document.OpenAPI)
+	}
+
+	t.Run("documents every route", func(t *testing.T) {
+		for _, route := range []string{
+			"get /api/imagegraphs",
+			"post /api/imagegraphs/{id}/nodes",
+			"put /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}",
+			"delete /api/keys/{key_id}",
+			"get /api/gallery/{id}",
+		} {
+			method, path, _ := strings.Cut(route, " ")
+			if document.Paths[path][method] == nil {
+				t.Errorf("expected %s to be documented", route)
+			}
+		}
+
+		// Routes without an operation entry are summarized by their pattern
+		for path, operations := range document.Paths {
+			for method, operation := range operations {
+				summary, _ := operation["summary"].(string)
+				if summary == "" || strings.Contains(summary, " /api/") {
+					t.Errorf("expected %s %s to have a summary, got %q", method, path, summary)
+				}
+			}
+		}
+	})
+
+	t.Run("references request and response schemas", func(t *testing.T) {
+		op := document.Paths["/api/imagegraphs/{id}/nodes"]["post"]
+		data, _ := json.Marshal(op)
+		for _, ref := range []string{"#/components/schemas/AddNodeRequest", "#/components/schemas/AddNodeResponse"} {
+			if !strings.Contains(string(data), ref) {
+				t.Errorf("expected add node to reference %s, got %s", ref, data)
+			}
+		}
+
+		request := document.Components.Schemas["AddNodeRequest"]
+		properties, _ := request["properties"].(map[string]interface{})
+		config, _ := properties["config"].(map[string]interface{})
+		if config["$ref"] != "#/components/schemas/NodeConfig" {
+			t.Errorf("expected the node config to reference NodeConfig, got %v", config)
+		}
+	})
+
+	t.Run("includes node config schemas", func(t *testing.T) {
+		nodeConfig := document.Components.Schemas["NodeConfig"]
+		oneOf, _ := nodeConfig["oneOf"].([]interface{})
+		if len(oneOf) == 0 {
+			t.Fatalf("expected NodeConfig to be one of the node type configs, got %v", nodeConfig)
+		}
+
+		blur := document.Components.Schemas["BlurNodeConfig"]
+		properties, _ := blur["properties"].(map[string]interface{})
+		radius, _ := properties["radius"].(map[string]interface{})
+		if radius["type"] != "integer" {
+			t.Errorf("expected an integer blur radius, got %v", blur)
+		}
+	})
+
+	t.Run("serves Swagger UI", func(t *testing.T) {
+		resp, err := http.Get(server.URL() + "/api/docs")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		if !strings.Contains(string(body), "/api/openapi.json") {
+			t.Errorf("expected the page to load the OpenAPI document")
+		}
+	})
+}
+
+func TestClient(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Client Graph", ExternalID: "client-graph"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "In", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add input node: %v", err)
+	}
+	outputID, err := c.AddNode(ctx, graphID, client.NewNode{
+		Name:   "Out",
+		Type:   "output",
+		Config: json.RawMessage(`{"export_name": "poster"}`),
+	})
+	if err != nil {
+		t.Fatalf("failed to add output node: %v", err)
+	}
+
+	err = c.ConnectNodes(ctx, graphID, client.Connection{
+		FromNodeID: inputID,
+		OutputName: "original",
+		ToNodeID:   outputID,
+		InputName:  "input",
+	})
+	if err != nil {
+		t.Fatalf("failed to connect nodes: %v", err)
+	}
+
+	t.Run("reads graphs and nodes", func(t *testing.T) {
+		graph, err := c.GetImageGraphByExternalID(ctx, "client-graph")
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if graph.ID != graphID || len(graph.Nodes) != 2 {
+			t.Fatalf("expected graph %s with 2 nodes, got %s with %d", graphID, graph.ID, len(graph.Nodes))
+		}
+
+		input, ok := graph.Node(inputID)
+		if !ok {
+			t.Fatalf("expected input node %s in graph", inputID)
+		}
+		original, ok := input.Output("original")
+		if !ok || len(original.Connections) != 1 || original.Connections[0].NodeID != outputID {
+			t.Errorf("expected original output to be connected to the output node, got %+v", original)
+		}
+
+		list, err := c.ListImageGraphs(ctx, client.ListImageGraphsOptions{Limit: 10})
+		if err != nil {
+			t.Fatalf("failed to list graphs: %v", err)
+		}
+		if list.Total != 1 || list.ImageGraphs[0].Name != "Client Graph" {
+			t.Errorf("expected the graph to be listed, got %+v", list)
+		}
+	})
+
+	t.Run("updates nodes", func(t *testing.T) {
+		name := "Renamed"
+		if err := c.UpdateNode(ctx, graphID, outputID, client.NodeUpdate{Name: &name}); err != nil {
+			t.Fatalf("failed to update node: %v", err)
+		}
+		if err := c.AddNodeTag(ctx, graphID, outputID, "final"); err != nil {
+			t.Fatalf("failed to tag node: %v", err)
+		}
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		output, _ := graph.Node(outputID)
+		if output.Name != "Renamed" || !slices.Equal(output.Tags, []string{"final"}) {
+			t.Errorf("expected renamed, tagged node, got %q %v", output.Name, output.Tags)
+		}
+
+		result, err := c.ValidateNodeConfig(ctx, graphID, outputID, json.RawMessage(`{"export_name": "x"}`))
+		if err != nil {
+			t.Fatalf("failed to validate config: %v", err)
+		}
+		if !result.Valid {
+			t.Errorf("expected valid config, got %+v", result)
+		}
+	})
+
+	t.Run("uploads and downloads images", func(t *testing.T) {
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		imageData := photo.Bytes()
+
+		imageID, err := c.UploadOutputImage(ctx, graphID, inputID, "original", "photo.png", imageData)
+		if err != nil {
+			t.Fatalf("failed to upload image: %v", err)
+		}
+
+		data, err := c.GetImage(ctx, imageID)
+		if err != nil {
+			t.Fatalf("failed to download image: %v", err)
+		}
+		if !bytes.Equal(data, imageData) {
+			t.Errorf("expected the uploaded image back, got %d bytes", len(data))
+		}
+	})
+
+	t.Run("rejects files that aren't images", func(t *testing.T) {
+		// A PNG signature alone passes content sniffing but can't be decoded
+		truncated := []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A, 0x00, 0x00, 0x00, 0x0D}
+
+		_, err := c.UploadOutputImage(ctx, graphID, inputID, "original", "photo.png", truncated)
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for an undecodable upload, got %v", err)
+		}
+
+		_, err = c.UploadInputs(ctx, graphID, []client.InputUpload{
+			{Filename: "photo.png", Data: truncated},
+		}, client.UploadInputsOptions{})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for an undecodable input, got %v", err)
+		}
+	})
+
+	t.Run("uploads inputs in bulk", func(t *testing.T) {
+		framesID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Frames"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+
+		var frame bytes.Buffer
+		if err := png.Encode(&frame, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		for _, name := range []string{"frames/b.png", "frames/a.png", "frames/notes.txt"} {
+			f, err := zw.Create(name)
+			if err != nil {
+				t.Fatalf("failed to create archive: %v", err)
+			}
+			if strings.HasSuffix(name, ".png") {
+				f.Write(frame.Bytes())
+			} else {
+				f.Write([]byte("not an image"))
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("failed to create archive: %v", err)
+		}
+
+		nodeIDs, err := c.UploadInputs(ctx, framesID, []client.InputUpload{
+			{Filename: "cover.png", Data: frame.Bytes()},
+			{Filename: "frames.zip", Data: archive.Bytes()},
+		}, client.UploadInputsOptions{})
+		if err != nil {
+			t.Fatalf("failed to upload inputs: %v", err)
+		}
+		if len(nodeIDs) != 3 {
+			t.Fatalf("expected 3 input nodes, got %v", nodeIDs)
+		}
+
+		graph, err := c.GetImageGraph(ctx, framesID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for i, name := range []string{"cover.png", "a.png", "b.png"} {
+			node, ok := graph.Node(nodeIDs[i])
+			if !ok || node.Type != "input" || node.Name != name {
+				t.Errorf("expected input node %q, got %+v", name, node)
+			}
+		}
+
+		matchID, err := c.AddNode(ctx, framesID, client.NewNode{
+			Name:   "Match",
+			Type:   "resize_match",
+			Config: json.RawMessage(`{"interpolation": "Bilinear"}`),
+		})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+
+		uploads := []client.InputUpload{{Filename: "one.png", Data: frame.Bytes()}, {Filename: "two.png", Data: frame.Bytes()}, {Filename: "three.png", Data: frame.Bytes()}}
+		_, err = c.UploadInputs(ctx, framesID, uploads, client.UploadInputsOptions{ConnectTo: matchID})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for more images than free inputs, got %v", err)
+		}
+
+		connected, err := c.UploadInputs(ctx, framesID, uploads[:2], client.UploadInputsOptions{ConnectTo: matchID})
+		if err != nil {
+			t.Fatalf("failed to upload connected inputs: %v", err)
+		}
+
+		graph, err = c.GetImageGraph(ctx, framesID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for i, inputName := range []string{"original", "size_match"} {
+			node, _ := graph.Node(connected[i])
+			output, ok := node.Output("original")
+			if !ok || len(output.Connections) != 1 || output.Connections[0].NodeID != matchID || output.Connections[0].InputName != inputName {
+				t.Errorf("expected %s to be connected to the %s input, got %+v", node.Name, inputName, output)
+			}
+		}
+	})
+
+	t.Run("diffs images", func(t *testing.T) {
+		diffGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Diff"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+
+		diffID, err := c.AddNode(ctx, diffGraphID, client.NewNode{
+			Name:   "Diff",
+			Type:   "diff",
+			Config: json.RawMessage(`{"amplify": 2}`),
+		})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+
+		if _, err := c.GetNodeDiff(ctx, diffGraphID, diffID); client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected a 409 error before the inputs have images, got %v", err)
+		}
+
+		solid := func(fill color.Color) []byte {
+			img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+			for y := range 16 {
+				for x := range 16 {
+					img.Set(x, y, fill)
+				}
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatalf("failed to encode image: %v", err)
+			}
+			return buf.Bytes()
+		}
+
+		inputIDs, err := c.UploadInputs(ctx, diffGraphID, []client.InputUpload{
+			{Filename: "black.png", Data: solid(color.Black)},
+			{Filename: "white.png", Data: solid(color.White)},
+		}, client.UploadInputsOptions{ConnectTo: diffID})
+		if err != nil {
+			t.Fatalf("failed to upload inputs: %v", err)
+		}
+
+		if _, err := c.GetNodeDiff(ctx, diffGraphID, inputIDs[0]); client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for a node that isn't a diff node, got %v", err)
+		}
+
+		// Wait for the inputs' images to propagate to the diff node
+		server.settle(t)
+
+		diff, err := c.GetNodeDiff(ctx, diffGraphID, diffID)
+		if err != nil {
+			t.Fatalf("failed to get diff: %v", err)
+		}
+		if diff.RMSE != 1 {
+			t.Errorf("expected RMSE 1 for black against white, got %v", diff.RMSE)
+		}
+		if diff.SSIM > 0.01 {
+			t.Errorf("expected SSIM near 0 for black against white, got %v", diff.SSIM)
+		}
+	})
+
+	t.Run("sweeps node config", func(t *testing.T) {
+		sweepGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Sweep"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+
+		sourceID, err := c.AddNode(ctx, sweepGraphID, client.NewNode{Name: "Source", Type: "input", Config: json.RawMessage(`{}`)})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		blurID, err := c.AddNode(ctx, sweepGraphID, client.NewNode{Name: "Blur", Type: "blur", Config: json.RawMessage(`{"radius": 2}`)})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		if err := c.ConnectNodes(ctx, sweepGraphID, client.Connection{
+			FromNodeID: sourceID, OutputName: "original", ToNodeID: blurID, InputName: "original",
+		}); err != nil {
+			t.Fatalf("failed to connect nodes: %v", err)
+		}
+
+		_, err = c.SweepNode(ctx, sweepGraphID, blurID, client.NodeSweep{Field: "sigma", From: 1, To: 3})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for an unknown field, got %v", err)
+		}
+		_, err = c.SweepNode(ctx, sweepGraphID, blurID, client.NodeSweep{Field: "radius", From: 0, To: 3})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for an invalid value, got %v", err)
+		}
+
+		branchIDs, err := c.SweepNode(ctx, sweepGraphID, blurID, client.NodeSweep{Field: "radius", From: 1, To: 5, Step: 2})
+		if err != nil {
+			t.Fatalf("failed to sweep node: %v", err)
+		}
+		if len(branchIDs) != 3 {
+			t.Fatalf("expected 3 branches, got %v", branchIDs)
+		}
+
+		graph, err := c.GetImageGraph(ctx, sweepGraphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for i, radius := range []int{1, 3, 5} {
+			node, _ := graph.Node(branchIDs[i])
+			var config struct {
+				Radius int `json:"radius"`
+			}
+			if err := json.Unmarshal(node.Config, &config); err != nil || config.Radius != radius {
+				t.Errorf("expected branch with radius %d, got %s", radius, node.Config)
+			}
+			if want := fmt.Sprintf("Blur radius=%d", radius); node.Name != want {
+				t.Errorf("expected branch named %q, got %q", want, node.Name)
+			}
+			if len(node.Inputs) != 1 || node.Inputs[0].Connection == nil || node.Inputs[0].Connection.NodeID != sourceID {
+				t.Errorf("expected branch to be connected to the source, got %+v", node.Inputs)
+			}
+		}
+
+		layout, err := c.GetLayout(ctx, sweepGraphID)
+		if err != nil {
+			t.Fatalf("failed to get layout: %v", err)
+		}
+		if len(layout.NodePositions) != 3 {
+			t.Fatalf("expected the branches to be laid out, got %+v", layout.NodePositions)
+		}
+		for i, position := range layout.NodePositions {
+			if position.NodeID != branchIDs[i] || position.Y != layout.NodePositions[0].Y {
+				t.Errorf("expected branches in a row, got %+v", layout.NodePositions)
+				break
+			}
+		}
+	})
+
+	t.Run("duplicates a node", func(t *testing.T) {
+		graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Copied"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+		sourceID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Source", Type: "input", Config: json.RawMessage(`{}`)})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		blurID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Blur", Type: "blur", Config: json.RawMessage(`{"radius": 4}`)})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		if err := c.ConnectNodes(ctx, graphID, client.Connection{
+			FromNodeID: sourceID, OutputName: "original", ToNodeID: blurID, InputName: "original",
+		}); err != nil {
+			t.Fatalf("failed to connect nodes: %v", err)
+		}
+		if err := c.UpdateLayout(ctx, graphID, []client.NodePosition{{NodeID: blurID, X: 100, Y: 50, Color: "green"}}); err != nil {
+			t.Fatalf("failed to update layout: %v", err)
+		}
+
+		copyID, err := c.DuplicateNode(ctx, graphID, blurID, client.NodeDuplicate{})
+		if err != nil {
+			t.Fatalf("failed to duplicate node: %v", err)
+		}
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		node, ok := graph.Node(copyID)
+		if !ok || node.Name != "Blur" || node.Type != "blur" || !strings.Contains(string(node.Config), `"radius":4`) {
+			t.Fatalf("expected a copy of the blur node, got %+v", node)
+		}
+		if len(node.Inputs) != 1 || node.Inputs[0].Connected {
+			t.Errorf("expected the copy to be unconnected, got %+v", node.Inputs)
+		}
+
+		layout, err := c.GetLayout(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get layout: %v", err)
+		}
+		want := client.NodePosition{NodeID: copyID, X: 140, Y: 90, Color: "green"}
+		if !slices.Contains(layout.NodePositions, want) {
+			t.Errorf("expected the copy offset from the node, got %+v", layout.NodePositions)
+		}
+
+		otherID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Pasted"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+		copyID, err = c.DuplicateNode(ctx, graphID, blurID, client.NodeDuplicate{
+			TargetGraphID: otherID,
+			Offset:        &client.PositionOffset{X: 10},
+		})
+		if err != nil {
+			t.Fatalf("failed to duplicate node into another graph: %v", err)
+		}
+		other, err := c.GetImageGraph(ctx, otherID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if _, ok := other.Node(copyID); !ok || len(other.Nodes) != 1 {
+			t.Errorf("expected the copy in the other graph, got %+v", other.Nodes)
+		}
+		layout, err = c.GetLayout(ctx, otherID)
+		if err != nil {
+			t.Fatalf("failed to get layout: %v", err)
+		}
+		want = client.NodePosition{NodeID: copyID, X: 110, Y: 50, Color: "green"}
+		if len(layout.NodePositions) != 1 || layout.NodePositions[0] != want {
+			t.Errorf("expected the copy at the node's position plus the offset, got %+v", layout.NodePositions)
+		}
+
+		_, err = c.DuplicateNode(ctx, graphID, blurID, client.NodeDuplicate{TargetGraphID: imagegraph.MustNewImageGraphID().String()})
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error for an unknown target graph, got %v", err)
+		}
+
+		sheetID, err := c.AddNode(ctx, otherID, client.NewNode{Name: "Sheet", Type: "contact_sheet"})
+		if err != nil {
+			t.Fatalf("failed to add contact sheet: %v", err)
+		}
+		if _, err := c.AddNodeInput(ctx, otherID, sheetID, "image_4"); err != nil {
+			t.Fatalf("failed to add input: %v", err)
+		}
+		sheetCopyID, err := c.DuplicateNode(ctx, otherID, sheetID, client.NodeDuplicate{})
+		if err != nil {
+			t.Fatalf("failed to duplicate contact sheet: %v", err)
+		}
+		other, err = c.GetImageGraph(ctx, otherID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		sheetCopy, _ := other.Node(sheetCopyID)
+		var inputs []string
+		for _, input := range sheetCopy.Inputs {
+			inputs = append(inputs, input.Name)
+		}
+		if !slices.Equal(inputs, []string{"image_1", "image_2", "image_4"}) {
+			t.Errorf("expected the copy to have the added input, got %v", inputs)
+		}
+	})
+
+	t.Run("adds no part of a copy whose inputs can't be added", func(t *testing.T) {
+		graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Partial"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+		parsedGraphID, err := imagegraph.ParseImageGraphID(graphID)
+		if err != nil {
+			t.Fatalf("failed to parse graph ID: %v", err)
+		}
+
+		// The copy's inputs are added in the command that adds it, so an
+		// input that can't be added takes the copy with it
+		command := application.NewAddImageGraphNodeCommand(
+			parsedGraphID,
+			imagegraph.MustNewNodeID(),
+			imagegraph.NodeTypeContactSheet,
+			"Sheet",
+			nil,
+			"",
+		)
+		command.Inputs = []imagegraph.InputName{"image_3", "layer_1"}
+		if err := server.queues.HandleCommand(ctx, command); err == nil {
+			t.Fatal("expected adding the node to fail")
+		}
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if len(graph.Nodes) != 0 {
+			t.Errorf("expected no node to be added, got %+v", graph.Nodes)
+		}
+	})
+
+	t.Run("sets color management", func(t *testing.T) {
+		colorGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Color"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+
+		graph, err := c.GetImageGraph(ctx, colorGraphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if graph.ColorManagement != "preserve" {
+			t.Errorf("expected new graphs to preserve profiles, got %q", graph.ColorManagement)
+		}
+
+		if err := c.SetColorManagement(ctx, colorGraphID, "cmyk"); client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for an unknown setting, got %v", err)
+		}
+
+		if err := c.SetColorManagement(ctx, colorGraphID, "convert_srgb"); err != nil {
+			t.Fatalf("failed to set color management: %v", err)
+		}
+
+		graph, err = c.GetImageGraph(ctx, colorGraphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if graph.ColorManagement != "convert_srgb" {
+			t.Errorf("expected color management convert_srgb, got %q", graph.ColorManagement)
+		}
+	})
+
+	t.Run("skips intermediate previews in performance mode", func(t *testing.T) {
+		perfGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Performance"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+
+		if err := c.SetPerformanceMode(ctx, perfGraphID, true); err != nil {
+			t.Fatalf("failed to set performance mode: %v", err)
+		}
+
+		graph, err := c.GetImageGraph(ctx, perfGraphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if !graph.PerformanceMode {
+			t.Error("expected the graph to be in performance mode")
+		}
+
+		blurID, err := c.AddNode(ctx, perfGraphID, client.NewNode{Name: "Blur", Type: "blur", Config: json.RawMessage(`{"radius":1}`)})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 12, 8))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+
+		if _, err := c.UploadInputs(ctx, perfGraphID, []client.InputUpload{
+			{Filename: "photo.png", Data: photo.Bytes()},
+		}, client.UploadInputsOptions{ConnectTo: blurID}); err != nil {
+			t.Fatalf("failed to upload inputs: %v", err)
+		}
+
+		blurNode := func() client.Node {
+			t.Helper()
+			graph, err := c.GetImageGraph(ctx, perfGraphID)
+			if err != nil {
+				t.Fatalf("failed to get graph: %v", err)
+			}
+			node, _ := graph.Node(blurID)
+			return node
+		}
+
+		server.settle(t)
+
+		node := blurNode()
+		if len(node.Outputs) == 0 || node.Outputs[0].ImageID == "" {
+			t.Fatal("expected the blur node to generate its output")
+		}
+		if node.Preview != "" {
+			t.Errorf("expected the blur node to skip its preview, got %q", node.Preview)
+		}
+
+		preview, err := c.GetNodePreview(ctx, perfGraphID, blurID)
+		if err != nil {
+			t.Fatalf("failed to get node preview: %v", err)
+		}
+		if _, err := png.Decode(bytes.NewReader(preview)); err != nil {
+			t.Errorf("expected a PNG preview: %v", err)
+		}
+
+		if node = blurNode(); node.Preview == "" {
+			t.Error("expected the generated preview to be set on the node")
+		}
+	})
+
+	t.Run("stores image metadata", func(t *testing.T) {
+		metadataGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Metadata"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+
+		finalID, err := c.AddNode(ctx, metadataGraphID, client.NewNode{Name: "Final", Type: "output", Config: json.RawMessage(`{}`)})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 12, 8))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+
+		inputIDs, err := c.UploadInputs(ctx, metadataGraphID, []client.InputUpload{
+			{Filename: "photo.png", Data: photo.Bytes()},
+		}, client.UploadInputsOptions{ConnectTo: finalID})
+		if err != nil {
+			t.Fatalf("failed to upload inputs: %v", err)
+		}
+
+		outputImageID := func(nodeID string) string {
+			t.Helper()
+			graph, err := c.GetImageGraph(ctx, metadataGraphID)
+			if err != nil {
+				t.Fatalf("failed to get graph: %v", err)
+			}
+			node, _ := graph.Node(nodeID)
+			if len(node.Outputs) == 0 {
+				return ""
+			}
+			return node.Outputs[0].ImageID
+		}
+
+		metadata, err := c.GetImageMetadata(ctx, outputImageID(inputIDs[0]))
+		if err != nil {
+			t.Fatalf("failed to get image metadata: %v", err)
+		}
+		if metadata.Filename != "photo.png" || metadata.Width != 12 || metadata.Height != 8 {
+			t.Errorf("expected metadata of the uploaded file, got %+v", metadata)
+		}
+
+		server.settle(t)
+
+		finalImageID := outputImageID(finalID)
+		if finalImageID == "" {
+			t.Fatal("expected the output node to generate its final image")
+		}
+
+		metadata, err = c.GetImageMetadata(ctx, finalImageID)
+		if err != nil {
+			t.Fatalf("failed to get image metadata: %v", err)
+		}
+		if metadata.Filename != "photo.png" {
+			t.Errorf("expected the final image to carry the source filename, got %+v", metadata)
+		}
+
+		if _, err := c.GetImageMetadata(ctx, imagegraph.MustNewImageID().String()); client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error for an unknown image, got %v", err)
+		}
+	})
+
+	t.Run("saves layout and viewport", func(t *testing.T) {
+		positions := []client.NodePosition{
+			{NodeID: inputID, X: 10, Y: 20},
+			{NodeID: outputID, X: 300, Y: 20, Width: 240, Height: 120, Collapsed: true, Color: "blue", ZOrder: 2},
+		}
+		if err := c.UpdateLayout(ctx, graphID, positions); err != nil {
+			t.Fatalf("failed to update layout: %v", err)
+		}
+		if err := c.UpdateViewport(ctx, graphID, 1.5, -40, 25); err != nil {
+			t.Fatalf("failed to update viewport: %v", err)
+		}
+
+		layout, err := c.GetLayout(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get layout: %v", err)
+		}
+		if len(layout.NodePositions) != 2 {
+			t.Errorf("expected 2 node positions, got %+v", layout.NodePositions)
+		}
+		for _, pos := range layout.NodePositions {
+			if pos.NodeID == outputID && pos != positions[1] {
+				t.Errorf("expected the output's size, color and state to be saved, got %+v", pos)
+			}
+		}
+
+		bad := []client.NodePosition{{NodeID: inputID, X: 10, Y: 20, Color: "teal"}}
+		if err := c.UpdateLayout(ctx, graphID, bad); client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for an unknown color, got %v", err)
+		}
+
+		var apiErr *client.Error
+		unknown := []client.NodePosition{
+			{NodeID: inputID, X: 10, Y: 20},
+			{NodeID: imagegraph.MustNewNodeID().String(), X: 10, Y: 20},
+			{NodeID: inputID, X: 10, Y: 20, Width: -5},
+		}
+		err = c.UpdateLayout(ctx, graphID, unknown)
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected a 400 error for an unknown node, got %v", err)
+		}
+		wantFields := []string{"node_positions[1].node_id", "node_positions[2].node_id", "node_positions[2].width"}
+		if len(apiErr.Fields) != len(wantFields) {
+			t.Fatalf("expected errors for %v, got %+v", wantFields, apiErr.Fields)
+		}
+		for i, field := range wantFields {
+			if apiErr.Fields[i].Field != field {
+				t.Errorf("expected an error for %s, got %+v", field, apiErr.Fields[i])
+			}
+		}
+
+		err = c.UpdateViewport(ctx, graphID, 0, -40, 25)
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected a 400 error for a zoom of 0, got %v", err)
+		}
+		if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "zoom" {
+			t.Errorf("expected an error for the zoom, got %+v", apiErr.Fields)
+		}
+
+		viewport, err := c.GetViewport(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get viewport: %v", err)
+		}
+		if viewport.Zoom != 1.5 || viewport.PanX != -40 || viewport.PanY != 25 {
+			t.Errorf("expected the saved viewport, got %+v", viewport)
+		}
+	})
+
+	t.Run("saves viewport bookmarks", func(t *testing.T) {
+		if _, err := c.SaveViewportBookmark(ctx, graphID, client.ViewportBookmark{Name: "inputs", Zoom: 0.8, Default: true}); err != nil {
+			t.Fatalf("failed to save bookmark: %v", err)
+		}
+		if _, err := c.SaveViewportBookmark(ctx, graphID, client.ViewportBookmark{Name: "exports", Zoom: 1.2, PanX: 400, Default: true}); err != nil {
+			t.Fatalf("failed to save bookmark: %v", err)
+		}
+
+		bookmarks, err := c.ListViewportBookmarks(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to list bookmarks: %v", err)
+		}
+		if len(bookmarks) != 2 || bookmarks[0].Name != "inputs" || bookmarks[0].Default || !bookmarks[1].Default {
+			t.Errorf("expected exports to become the only default, got %+v", bookmarks)
+		}
+
+		viewport, err := c.GetViewport(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get viewport: %v", err)
+		}
+		if viewport.Zoom != 1.5 || len(viewport.Bookmarks) != 2 {
+			t.Errorf("expected the saved viewport with its bookmarks, got %+v", viewport)
+		}
+
+		_, err = c.SaveViewportBookmark(ctx, graphID, client.ViewportBookmark{Name: "bad", Zoom: 0})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected a 400 error for a zero zoom, got %v", err)
+		}
+
+		if err := c.DeleteViewportBookmark(ctx, graphID, "inputs"); err != nil {
+			t.Fatalf("failed to delete bookmark: %v", err)
+		}
+		if err := c.DeleteViewportBookmark(ctx, graphID, "inputs"); client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error for a deleted bookmark, got %v", err)
+		}
+	})
+
+	t.Run("gets the graph with its layout and viewport", func(t *testing.T) {
+		full, err := c.GetFullImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get full graph: %v", err)
+		}
+		if full.ImageGraph.ID != graphID {
+			t.Errorf("expected graph %s, got %s", graphID, full.ImageGraph.ID)
+		}
+		if len(full.Layout.NodePositions) != 2 {
+			t.Errorf("expected 2 node positions, got %+v", full.Layout.NodePositions)
+		}
+		if full.Viewport.Zoom != 1.5 {
+			t.Errorf("expected the saved viewport, got %+v", full.Viewport)
+		}
+		if len(full.NodeTypes) == 0 {
+			t.Error("expected node type schemas")
+		}
+	})
+
+	t.Run("arranges the nodes automatically", func(t *testing.T) {
+		layout, err := c.AutoLayout(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to auto layout: %v", err)
+		}
+
+		x := map[string]float64{}
+		for _, pos := range layout.NodePositions {
+			x[pos.NodeID] = pos.X
+			if pos.NodeID == outputID && (pos.Color != "blue" || !pos.Collapsed) {
+				t.Errorf("expected the output to keep its color and collapsed state, got %+v", pos)
+			}
+		}
+		if len(x) != 2 || x[outputID] <= x[inputID] {
+			t.Errorf("expected the output to the right of the input, got %+v", layout.NodePositions)
+		}
+
+		saved, err := c.GetLayout(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get layout: %v", err)
+		}
+		for _, pos := range saved.NodePositions {
+			if x[pos.NodeID] != pos.X {
+				t.Errorf("expected the arranged positions to be saved, got %+v", saved.NodePositions)
+			}
+		}
+	})
+
+	t.Run("returns API errors", func(t *testing.T) {
+		_, err := c.GetImageGraph(ctx, imagegraph.MustNewImageGraphID().String())
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 error, got %v", err)
+		}
+
+		_, err = c.AddNode(ctx, graphID, client.NewNode{Name: "Bad", Type: "no-such-type"})
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message == "" {
+			t.Errorf("expected a 400 error with a message, got %v", err)
+		}
+
+		if err := c.DeleteNode(ctx, graphID, outputID); err != nil {
+			t.Fatalf("failed to delete node: %v", err)
+		}
+	})
+
+	t.Run("authenticates", func(t *testing.T) {
+		server := setupAuthTestServer(t)
+		defer server.Stop()
+
+		if _, err := client.New(server.URL()).ListImageGraphs(ctx, client.ListImageGraphsOptions{}); client.StatusCode(err) != http.StatusUnauthorized {
+			t.Errorf("expected a 401 error without a token, got %v", err)
+		}
+
+		alice := client.New(server.URL(), client.WithToken("alice-token"))
+		graphID, err := alice.CreateImageGraph(ctx, client.NewImageGraph{Name: "Alice's"})
+		if err != nil {
+			t.Fatalf("failed to create graph: %v", err)
+		}
+		if err := alice.ShareImageGraph(ctx, graphID, "bob", "viewer"); err != nil {
+			t.Fatalf("failed to share graph: %v", err)
+		}
+
+		graph, err := client.New(server.URL(), client.WithToken("bob-token")).GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get shared graph: %v", err)
+		}
+		if graph.Role != "viewer" {
+			t.Errorf("expected bob to be a viewer, got %q", graph.Role)
+		}
+	})
+
+	t.Run("manages webhooks", func(t *testing.T) {
+		server := setupTestServer(t, httpgateway.WithWebhooks(inmem.NewWebhookStore()))
+		defer server.Stop()
+
+		c := client.New(server.URL())
+		graphID := server.createImageGraph(t, "Notifying")
+
+		webhook, err := c.CreateWebhook(ctx, graphID, "https://hooks.example.com/artwork", "")
+		if err != nil {
+			t.Fatalf("failed to create webhook: %v", err)
+		}
+		if webhook.Secret == "" {
+			t.Error("expected the created webhook's secret")
+		}
+
+		webhooks, err := c.ListWebhooks(ctx, graphID)
+		if err != nil || len(webhooks) != 1 || webhooks[0].ID != webhook.ID {
+			t.Fatalf("expected the webhook to be listed, got %v, %v", webhooks, err)
+		}
+
+		if err := c.DeleteWebhook(ctx, graphID, webhook.ID); err != nil {
+			t.Fatalf("failed to delete webhook: %v", err)
+		}
+		if err := c.DeleteWebhook(ctx, graphID, webhook.ID); client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected a 404 deleting twice, got %v", err)
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	sunsetGraphID, nodeIDs := server.buildGraph(t, testsupport.NewGraphBuilder().
+		WithName("Sunset Edits").
+		WithInput().Named("Beach Photo").
+		WithBlur(3).Named("Sunset Glow"))
+	server.createImageGraph(t, "Portraits")
+
+	search := func(t *testing.T, query string) (int, []map[string]interface{}) {
+		t.Helper()
+
+		resp, err := http.Get(server.URL() + "/api/search?q=" + url.QueryEscape(query))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			Hits []map[string]interface{} `json:"hits"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result.Hits
+	}
+
+	t.Run("finds graphs and nodes by name", func(t *testing.T) {
+		status, hits := search(t, "SUNSET")
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", status)
+		}
+		if len(hits) != 2 {
+			t.Fatalf("expected 2 hits, got %v", hits)
+		}
+
+		if hits[0]["type"] != "graph" || hits[0]["graph_id"] != sunsetGraphID {
+			t.Errorf("expected the graph hit first, got %v", hits[0])
+		}
+		if hits[1]["type"] != "node" || hits[1]["node_id"] != nodeIDs["Sunset Glow"] || hits[1]["graph_name"] != "Sunset Edits" {
+			t.Errorf("expected the node hit second, got %v", hits[1])
+		}
+	})
+
+	t.Run("matches substrings after prefixes", func(t *testing.T) {
+		_, hits := search(t, "photo")
+		if len(hits) != 1 || hits[0]["node_name"] != "Beach Photo" || hits[0]["prefix"] == true {
+			t.Errorf("expected a substring hit on Beach Photo, got %v", hits)
+		}
+	})
+
+	t.Run("finds tags", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, server.URL()+"/api/imagegraphs/"+sunsetGraphID+"/nodes/"+nodeIDs["Beach Photo"]+"/tags/coast", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		_, hits := search(t, "coa")
+		if len(hits) != 1 || hits[0]["field"] != "tag" || hits[0]["node_id"] != nodeIDs["Beach Photo"] {
+			t.Errorf("expected a tag hit on Beach Photo, got %v", hits)
+		}
+	})
+
+	t.Run("rejects empty queries", func(t *testing.T) {
+		if status, _ := search(t, "  "); status != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", status)
+		}
+	})
+}
+
+func TestErrorScenarios(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	t.Run("404 for non-existent graph", func(t *testing.T) {
+		fakeID := imagegraph.MustNewImageGraphID().String()
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/%s", server.URL(), fakeID))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("400 for invalid UUID", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/imagegraphs/not-a-uuid", server.URL()))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("400 for invalid config JSON", func(t *testing.T) {
+		graphID := server.createImageGraph(t, "Test Graph")
+		nodeID := server.addNode(t, graphID, "input", "Input Node", `{}`)
+
+		reqBody := map[string]string{"config": "not valid json"}
+		body, _ := json.Marshal(reqBody)
+
+		req, _ := http.NewRequest(
+			http.MethodPatch,
+			fmt.Sprintf("%s/api/imagegraphs/%s/nodes/%s", server.URL(), graphID, nodeID),
+			bytes.NewReader(body),
+		)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := setupTestServer(t, httpgateway.WithRequestTimeout(time.Minute))
+	defer server.Stop()
+
+	graphID, _ := server.buildGraph(t, testsupport.NewGraphBuilder().
+		WithName("Timed Graph").
+		WithInput().
+		WithBlur(5).
+		ConnectAll())
+
+	graph := server.getImageGraph(t, graphID)
+
+	nodes, ok := graph["nodes"].([]interface{})
+	if !ok || len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes within the request deadline, got %v", graph["nodes"])
+	}
+}
+
+// busHoldingObserver holds up the message bus the first time more events
+// than its limit are queued once it's armed, until it's released
+type busHoldingObserver struct {
+	limit   int
+	armed   atomic.Bool
+	held    chan struct{}
+	release chan struct{}
+	hold    sync.Once
+	unhold  sync.Once
+}
+
+func (o *busHoldingObserver) ObserveGraphCommandsQueued(int) {}
+
+func (o *busHoldingObserver) ObserveGraphCommandRejected(string) {}
+
+func (o *busHoldingObserver) ObserveGraphEventsQueued(queued int) {
+	if queued <= o.limit || !o.armed.Load() {
+		return
+	}
+
+	o.hold.Do(func() {
+		close(o.held)
+		<-o.release
+	})
+}
+
+func (o *busHoldingObserver) releaseBus() {
+	o.unhold.Do(func() { close(o.release) })
+}
+
+func TestGraphQueueLimit(t *testing.T) {
+	const limit = 2
+	observer := &busHoldingObserver{
+		limit:   limit,
+		held:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	server := setupTestServerWithGraphQueues(t,
+		[]application.GraphQueuesOption{
+			application.WithGraphQueueLimit(limit),
+			application.WithGraphQueueObserver(observer),
+		},
+		httpgateway.WithBusyRejection(time.Second),
+	)
+	defer server.Stop()
+	defer observer.releaseBus()
+
+	// Regenerating the graph fans out to every blur node at once
+	builder := testsupport.NewGraphBuilder().WithName("Fan-out Graph").WithInput()
+	for range 6 {
+		builder.WithBlur(1)
+	}
+	blurs := []string{"blur", "blur2", "blur3", "blur4", "blur5", "blur6"}
+	for _, blur := range blurs {
+		builder.Connect("input", blur)
+	}
+	graphID, nodeIDs := server.buildGraph(t, builder)
+
+	server.setNodeOutputImage(t, graphID, nodeIDs["input"], "original", "")
+	server.settle(t)
+
+	parsedGraphID, err := imagegraph.ParseImageGraphID(graphID)
+	if err != nil {
+		t.Fatalf("failed to parse graph ID: %v", err)
+	}
+
+	observer.armed.Store(true)
+	regenerated := make(chan error, 1)
+	go func() {
+		regenerated <- server.queues.HandleCommand(context.Background(), application.NewRegenerateImageGraphCommand(parsedGraphID))
+	}()
+
+	select {
+	case <-observer.held:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected regenerating the graph to queue more events than the limit")
+	}
+
+	c := client.New(server.URL())
+	ctx := context.Background()
+
+	t.Run("rejects requests while the graph's events are queued", func(t *testing.T) {
+		// A request let through would wait on the held up bus
+		reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(
+			reqCtx,
+			http.MethodPut,
+			fmt.Sprintf("%s/api/imagegraphs/%s/public", server.URL(), graphID),
+			strings.NewReader(`{"public": true}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected status 503, got %d", resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") != "1" {
+			t.Errorf("expected Retry-After 1, got %q", resp.Header.Get("Retry-After"))
+		}
+	})
+
+	observer.releaseBus()
+	if err := <-regenerated; err != nil {
+		t.Fatalf("failed to regenerate graph: %v", err)
+	}
+	server.settle(t)
+
+	t.Run("regenerates every node once the events are dispatched", func(t *testing.T) {
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+
+		for _, node := range graph.Nodes {
+			if node.State != "generated" {
+				t.Errorf("expected node %s to be generated, got %s", node.Name, node.State)
+			}
+		}
+	})
+
+	t.Run("accepts requests once there is room", func(t *testing.T) {
+		if err := c.SetImageGraphPublic(ctx, graphID, true); err != nil {
+			t.Errorf("failed to set graph public: %v", err)
+		}
+	})
+}
+
+func TestConnectionTransforms(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Transforms"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Input", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add input: %v", err)
+	}
+	blurID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Blur", Type: "blur", Config: json.RawMessage(`{"radius": 2}`)})
+	if err != nil {
+		t.Fatalf("failed to add blur: %v", err)
+	}
+
+	connection := client.Connection{
+		FromNodeID: inputID,
+		OutputName: "original",
+		ToNodeID:   blurID,
+		InputName:  "original",
+	}
+
+	inputTransform := func(t *testing.T) string {
+		t.Helper()
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for _, node := range graph.Nodes {
+			if node.ID == blurID && node.Inputs[0].Connection != nil {
+				return node.Inputs[0].Connection.Transform
+			}
+		}
+		t.Fatal("expected the blur's input to be connected")
+		return ""
+	}
+
+	t.Run("connects with a transform", func(t *testing.T) {
+		connection.Transform = "alpha"
+		if err := c.ConnectNodes(ctx, graphID, connection); err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+
+		if got := inputTransform(t); got != "alpha" {
+			t.Errorf("expected the alpha transform, got %q", got)
+		}
+	})
+
+	t.Run("reconnecting changes the transform", func(t *testing.T) {
+		connection.Transform = "invert"
+		if err := c.ConnectNodes(ctx, graphID, connection); err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+
+		if got := inputTransform(t); got != "invert" {
+			t.Errorf("expected the invert transform, got %q", got)
+		}
+	})
+
+	t.Run("rejects unknown transforms", func(t *testing.T) {
+		connection.Transform = "sepia"
+		err := c.ConnectNodes(ctx, graphID, connection)
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %v", err)
+		}
+	})
+}
+
+func TestNodeInputs(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Inputs"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Input", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add input: %v", err)
+	}
+	sheetID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Sheet", Type: "contact_sheet"})
+	if err != nil {
+		t.Fatalf("failed to add contact sheet: %v", err)
+	}
+	blurID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Blur", Type: "blur", Config: json.RawMessage(`{"radius": 2}`)})
+	if err != nil {
+		t.Fatalf("failed to add blur: %v", err)
+	}
+
+	inputNames := func(t *testing.T) []string {
+		t.Helper()
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		var names []string
+		for _, node := range graph.Nodes {
+			if node.ID != sheetID {
+				continue
+			}
+			for _, input := range node.Inputs {
+				names = append(names, input.Name)
+			}
+		}
+		return names
+	}
+
+	t.Run("adds the next input", func(t *testing.T) {
+		name, err := c.AddNodeInput(ctx, graphID, sheetID, "")
+		if err != nil {
+			t.Fatalf("failed to add input: %v", err)
+		}
+		if name != "image_3" {
+			t.Errorf("expected image_3, got %q", name)
+		}
+	})
+
+	t.Run("adds a named input", func(t *testing.T) {
+		name, err := c.AddNodeInput(ctx, graphID, sheetID, "image_6")
+		if err != nil {
+			t.Fatalf("failed to add input: %v", err)
+		}
+		if name != "image_6" {
+			t.Errorf("expected image_6, got %q", name)
+		}
+
+		want := []string{"image_1", "image_2", "image_3", "image_6"}
+		if got := inputNames(t); !slices.Equal(got, want) {
+			t.Errorf("expected inputs %v, got %v", want, got)
+		}
+	})
+
+	t.Run("rejects badly named and existing inputs", func(t *testing.T) {
+		for _, name := range []string{"layer_1", "image_3"} {
+			_, err := c.AddNodeInput(ctx, graphID, sheetID, name)
+			if client.StatusCode(err) != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %q, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("rejects adding inputs to fixed node types", func(t *testing.T) {
+		_, err := c.AddNodeInput(ctx, graphID, blurID, "")
+		if client.StatusCode(err) != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %v", err)
+		}
+	})
+
+	t.Run("removes a connected input", func(t *testing.T) {
+		err := c.ConnectNodes(ctx, graphID, client.Connection{
+			FromNodeID: inputID,
+			OutputName: "original",
+			ToNodeID:   sheetID,
+			InputName:  "image_6",
+		})
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+
+		if err := c.RemoveNodeInput(ctx, graphID, sheetID, "image_6"); err != nil {
+			t.Fatalf("failed to remove input: %v", err)
+		}
+
+		want := []string{"image_1", "image_2", "image_3"}
+		if got := inputNames(t); !slices.Equal(got, want) {
+			t.Errorf("expected inputs %v, got %v", want, got)
+		}
+	})
+
+	t.Run("rejects removing declared and missing inputs", func(t *testing.T) {
+		err := c.RemoveNodeInput(ctx, graphID, sheetID, "image_1")
+		if client.StatusCode(err) != http.StatusUnprocessableEntity {
+			t.Errorf("expected status 422, got %v", err)
+		}
+
+		err = c.RemoveNodeInput(ctx, graphID, sheetID, "image_9")
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected status 404, got %v", err)
+		}
+	})
+}
+
+func TestNodeExpressions(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Expressions"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	resizeID, err := c.AddNode(ctx, graphID, client.NewNode{
+		Name:   "Resize",
+		Type:   "resize",
+		Config: json.RawMessage(`{"width": 100, "interpolation": "Bilinear"}`),
+	})
+	if err != nil {
+		t.Fatalf("failed to add resize: %v", err)
+	}
+
+	expressions := func(t *testing.T) map[string]string {
+		t.Helper()
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for _, node := range graph.Nodes {
+			if node.ID == resizeID {
+				return node.Expressions
+			}
+		}
+		t.Fatalf("resize node not found")
+		return nil
+	}
+
+	t.Run("sets expressions", func(t *testing.T) {
+		update := client.NodeUpdate{Expressions: map[string]string{"width": "input.width / 2"}}
+		if err := c.UpdateNode(ctx, graphID, resizeID, update); err != nil {
+			t.Fatalf("failed to set expressions: %v", err)
+		}
+		if got := expressions(t); got["width"] != "input.width / 2" {
+			t.Errorf("expected the width expression, got %v", got)
+		}
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		for _, exprs := range []map[string]string{
+			{"width": "input.width /"},
+			{"interpolation": "input.width"},
+			{"width": "mask.width"},
+		} {
+			err := c.UpdateNode(ctx, graphID, resizeID, client.NodeUpdate{Expressions: exprs})
+			if client.StatusCode(err) != http.StatusBadRequest {
+				t.Errorf("expected 400 for %v, got %v", exprs, err)
+			}
+		}
+	})
+
+	t.Run("removes expressions", func(t *testing.T) {
+		update := client.NodeUpdate{Expressions: map[string]string{}}
+		if err := c.UpdateNode(ctx, graphID, resizeID, update); err != nil {
+			t.Fatalf("failed to remove expressions: %v", err)
+		}
+		if got := expressions(t); len(got) != 0 {
+			t.Errorf("expected no expressions, got %v", got)
+		}
+	})
+}
+
+func TestGraphParameters(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Parameters"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	resizeID, err := c.AddNode(ctx, graphID, client.NewNode{
+		Name:   "Resize",
+		Type:   "resize",
+		Config: json.RawMessage(`{"width": 100, "interpolation": "Bilinear"}`),
+	})
+	if err != nil {
+		t.Fatalf("failed to add resize: %v", err)
+	}
+
+	t.Run("sets parameters", func(t *testing.T) {
+		if err := c.SetParameters(ctx, graphID, map[string]float64{"target_width": 800}); err != nil {
+			t.Fatalf("failed to set parameters: %v", err)
+		}
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if graph.Parameters["target_width"] != 800 {
+			t.Errorf("expected target_width 800, got %v", graph.Parameters)
+		}
+	})
+
+	t.Run("rejects bad parameter names", func(t *testing.T) {
+		err := c.SetParameters(ctx, graphID, map[string]float64{"Target Width": 800})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected 400, got %v", err)
+		}
+	})
+
+	t.Run("lets expressions use parameters", func(t *testing.T) {
+		update := client.NodeUpdate{Expressions: map[string]string{"width": "target_width / 2"}}
+		if err := c.UpdateNode(ctx, graphID, resizeID, update); err != nil {
+			t.Fatalf("failed to set expressions: %v", err)
+		}
+
+		update = client.NodeUpdate{Expressions: map[string]string{"width": "palette_size"}}
+		if err := c.UpdateNode(ctx, graphID, resizeID, update); client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown parameter, got %v", err)
+		}
+	})
+
+	t.Run("won't remove parameters in use", func(t *testing.T) {
+		err := c.SetParameters(ctx, graphID, map[string]float64{})
+		if client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected 409, got %v", err)
+		}
+	})
+}
+
+func TestGraphSeed(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Seed"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	paletteID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Palette", Type: "palette_extract"})
+	if err != nil {
+		t.Fatalf("failed to add palette extract: %v", err)
+	}
+
+	effectiveSeed := func(t *testing.T) *int {
+		t.Helper()
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for _, node := range graph.Nodes {
+			if node.ID == paletteID {
+				return node.EffectiveSeed
+			}
+		}
+		t.Fatalf("palette node not found")
+		return nil
+	}
+
+	if seed := effectiveSeed(t); seed == nil || *seed != imagegraph.DefaultPaletteExtractSeed {
+		t.Errorf("expected the default seed, got %v", seed)
+	}
+
+	seed := 1234
+	if err := c.SetSeed(ctx, graphID, &seed); err != nil {
+		t.Fatalf("failed to set seed: %v", err)
+	}
+	if got := effectiveSeed(t); got == nil || *got != seed {
+		t.Errorf("expected the graph seed %d, got %v", seed, got)
+	}
+
+	negative := -1
+	if err := c.SetSeed(ctx, graphID, &negative); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative seed, got %v", err)
+	}
+
+	if err := c.SetSeed(ctx, graphID, nil); err != nil {
+		t.Fatalf("failed to clear seed: %v", err)
+	}
+	graph, err := c.GetImageGraph(ctx, graphID)
+	if err != nil {
+		t.Fatalf("failed to get graph: %v", err)
+	}
+	if graph.Seed != nil {
+		t.Errorf("expected no graph seed, got %d", *graph.Seed)
+	}
+}
+
+func TestPaletteFiles(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Palettes"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	paletteID, err := c.ImportPalette(ctx, graphID, "sunset.hex", []byte("#ff6600\n#330066\n#ffffff\n"))
+	if err != nil {
+		t.Fatalf("failed to import palette: %v", err)
+	}
+
+	graph, err := c.GetImageGraph(ctx, graphID)
+	if err != nil {
+		t.Fatalf("failed to get graph: %v", err)
+	}
+	if len(graph.Nodes) != 1 || graph.Nodes[0].Type != "palette_create" || graph.Nodes[0].Name != "sunset" {
+		t.Fatalf("expected a palette_create node named sunset, got %+v", graph.Nodes)
+	}
+	var config struct {
+		Colors string `json:"colors"`
+	}
+	if err := json.Unmarshal(graph.Nodes[0].Config, &config); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if config.Colors != "#ff6600, #330066, #ffffff" {
+		t.Errorf("expected the imported colors, got %q", config.Colors)
+	}
+
+	// Wait for the palette image to generate
+	server.settle(t)
+
+	gpl, err := c.ExportPalette(ctx, graphID, paletteID, "")
+	if err != nil {
+		t.Fatalf("failed to export palette: %v", err)
+	}
+	if !strings.HasPrefix(string(gpl), "GIMP Palette\nName: sunset\n") {
+		t.Errorf("expected a GIMP palette named sunset, got %q", gpl)
+	}
+
+	hex, err := c.ExportPalette(ctx, graphID, paletteID, "hex")
+	if err != nil {
+		t.Fatalf("failed to export palette: %v", err)
+	}
+	if string(hex) != "#ff6600\n#330066\n#ffffff\n" {
+		t.Errorf("expected the colors in order, got %q", hex)
+	}
+
+	aco, err := c.ExportPalette(ctx, graphID, paletteID, "aco")
+	if err != nil {
+		t.Fatalf("failed to export palette: %v", err)
+	}
+
+	// Exported files import as the same palette
+	for name, data := range map[string][]byte{"sunset.gpl": gpl, "sunset.aco": aco} {
+		nodeID, err := c.ImportPalette(ctx, graphID, name, data)
+		if err != nil {
+			t.Fatalf("failed to import %s: %v", name, err)
+		}
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		for _, node := range graph.Nodes {
+			if node.ID != nodeID {
+				continue
+			}
+			if err := json.Unmarshal(node.Config, &config); err != nil {
+				t.Fatalf("failed to decode config: %v", err)
+			}
+			if config.Colors != "#ff6600, #330066, #ffffff" {
+				t.Errorf("expected %s to import the same colors, got %q", name, config.Colors)
+			}
+		}
+	}
+
+	if _, err := c.ExportPalette(ctx, graphID, paletteID, "pdf"); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for an unknown format, got %v", err)
+	}
+
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Photo", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add input: %v", err)
+	}
+	if _, err := c.ExportPalette(ctx, graphID, inputID, ""); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for a node without a palette output, got %v", err)
+	}
+
+	if _, err := c.ImportPalette(ctx, graphID, "broken.gpl", []byte("GIMP Palette\n300 0 0\n")); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for an invalid palette file, got %v", err)
+	}
+}
+
+func TestPaletteSwatches(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Swatches"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	editID, err := c.AddNode(ctx, graphID, client.NewNode{
+		Name:   "Edit",
+		Type:   "palette_edit",
+		Config: json.RawMessage(`{"colors": "#ff0000,!#00ff00"}`),
+	})
+	if err != nil {
+		t.Fatalf("failed to add palette edit: %v", err)
+	}
+
+	expectSwatches := func(t *testing.T, want ...client.PaletteSwatch) {
+		t.Helper()
+
+		swatches, err := c.ListPaletteSwatches(ctx, graphID, editID)
+		if err != nil {
+			t.Fatalf("failed to list swatches: %v", err)
+		}
+		if !slices.Equal(swatches, want) {
+			t.Errorf("expected swatches %v, got %v", want, swatches)
+		}
+	}
+
+	expectSwatches(t, client.PaletteSwatch{Color: "#ff0000", Enabled: true}, client.PaletteSwatch{Color: "#00ff00"})
+
+	if err := c.SetPaletteSwatchEnabled(ctx, graphID, editID, "#00FF00", true); err != nil {
+		t.Fatalf("failed to enable swatch: %v", err)
+	}
+	first := 0
+	if err := c.AddPaletteSwatch(ctx, graphID, editID, "0000ff", false, &first); err != nil {
+		t.Fatalf("failed to add swatch: %v", err)
+	}
+	expectSwatches(t,
+		client.PaletteSwatch{Color: "#0000ff"},
+		client.PaletteSwatch{Color: "#ff0000", Enabled: true},
+		client.PaletteSwatch{Color: "#00ff00", Enabled: true},
+	)
+
+	if err := c.ReorderPaletteSwatches(ctx, graphID, editID, []string{"#00ff00", "#ff0000", "#0000ff"}); err != nil {
+		t.Fatalf("failed to reorder swatches: %v", err)
+	}
+	if err := c.RemovePaletteSwatch(ctx, graphID, editID, "#ff0000"); err != nil {
+		t.Fatalf("failed to remove swatch: %v", err)
+	}
+	expectSwatches(t, client.PaletteSwatch{Color: "#00ff00", Enabled: true}, client.PaletteSwatch{Color: "#0000ff"})
+
+	if err := c.AddPaletteSwatch(ctx, graphID, editID, "#00ff00", true, nil); client.StatusCode(err) != http.StatusConflict {
+		t.Errorf("expected a 409 error for a color already in the palette, got %v", err)
+	}
+	if err := c.RemovePaletteSwatch(ctx, graphID, editID, "#ff0000"); client.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("expected a 404 error for a removed swatch, got %v", err)
+	}
+	if err := c.AddPaletteSwatch(ctx, graphID, editID, "red", true, nil); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for a color that isn't hex, got %v", err)
+	}
+	if err := c.ReorderPaletteSwatches(ctx, graphID, editID, []string{"#00ff00"}); client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for an order missing a color, got %v", err)
+	}
+
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Photo", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add input: %v", err)
+	}
+	if _, err := c.ListPaletteSwatches(ctx, graphID, inputID); client.StatusCode(err) != http.StatusUnprocessableEntity {
+		t.Errorf("expected a 422 error for a node that isn't a palette edit node, got %v", err)
+	}
+}
+
+func TestMultiGraphWebSocket(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := client.New(server.URL())
+
+	first, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "First"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	second, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Second"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL(), "http") + "/api/ws"
+
+	// Unknown graphs and connections to nothing are rejected before upgrading
+	missing, err := imagegraph.NewImageGraphID()
+	if err != nil {
+		t.Fatalf("failed to generate graph ID: %v", err)
+	}
+	for query, status := range map[string]int{
+		"":                                 http.StatusBadRequest,
+		"?graph_id=" + first + ",nope":     http.StatusBadRequest,
+		"?graph_id=" + missing.String():    http.StatusNotFound,
+		"?graph_list=maybe":                http.StatusBadRequest,
+		"?graph_list=true&last_event_id=3": http.StatusBadRequest,
+	} {
+		_, resp, err := websocket.Dial(ctx, wsURL+query, nil)
+		if err == nil || resp == nil || resp.StatusCode != status {
+			t.Errorf("expected %d for %q, got %v", status, query, err)
+		}
+	}
+
+	conn, _, err := websocket.Dial(ctx, wsURL+"?graph_id="+first+","+second+"&graph_list=true", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	// waitFor reads messages until one matches
+	waitFor := func(description string, match func(httpgateway.WebSocketMessage) bool) httpgateway.WebSocketMessage {
+		t.Helper()
+		for {
+			var msg httpgateway.WebSocketMessage
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				t.Fatalf("failed waiting for %s: %v", description, err)
+			}
+			if match(msg) {
+				return msg
+			}
+		}
+	}
+
+	// Each graph sends its own heartbeats
+	heartbeats := map[string]bool{}
+	waitFor("heartbeats", func(msg httpgateway.WebSocketMessage) bool {
+		if msg.Type == "heartbeat" {
+			heartbeats[msg.GraphID] = true
+		}
+		return heartbeats[first] && heartbeats[second]
+	})
+
+	if _, err := c.AddNode(ctx, second, client.NewNode{Name: "Source", Type: "input"}); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+	update := waitFor("a node update", func(msg httpgateway.WebSocketMessage) bool {
+		return msg.Type == httpgateway.MessageTypeNodeUpdate
+	})
+	if update.GraphID != second || update.ID == "" {
+		t.Errorf("expected a numbered update of the second graph, got %+v", update)
+	}
+
+	third, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Third"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	created := waitFor("a graph list update", func(msg httpgateway.WebSocketMessage) bool {
+		return msg.Type == httpgateway.MessageTypeGraphListUpdate
+	})
+	data, _ := created.Data.(map[string]any)
+	if created.GraphID != third || data["change"] != "created" || data["name"] != "Third" {
+		t.Errorf("expected the third graph to be reported created, got %+v", created)
+	}
+}
+
+func TestAdminStats(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Stats"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Source", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+	if _, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Result", Type: "output"}); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	if _, err := c.UploadOutputImage(ctx, graphID, inputID, "original", "photo.png", photo.Bytes()); err != nil {
+		t.Fatalf("failed to upload image: %v", err)
+	}
+
+	// Wait for the preview, which is orphaned until it is set on the node
+	server.settle(t)
+
+	stats, err := c.GetAdminStats(ctx)
+	if err != nil {
+		t.Fatalf("failed to get stats: %v", err)
+	}
+
+	if stats.ImageGraphs != 1 || stats.NodesByType["input"] != 1 || stats.NodesByType["output"] != 1 {
+		t.Errorf("expected one graph with an input and an output node, got %+v", stats)
+	}
+	if stats.StoredImages == nil || *stats.StoredImages < 2 || *stats.StoredImageBytes == 0 {
+		t.Fatalf("expected the upload and its preview to be stored, got %+v", stats)
+	}
+	if *stats.OrphanedImages != 0 || *stats.OrphanedImageBytes != 0 {
+		t.Errorf("expected no orphaned images, got %d", *stats.OrphanedImages)
+	}
+	if stats.QueueDepth != 0 {
+		t.Errorf("expected in-memory events to never queue, got %d", stats.QueueDepth)
+	}
+
+	t.Run("requires an admin when authentication is enabled", func(t *testing.T) {
+		authServer := setupAuthTestServer(t)
+		defer authServer.Stop()
+
+		if status, _ := sendAs(t, authServer, "alice-token", http.MethodGet, "/api/admin/stats", nil); status != http.StatusForbidden {
+			t.Errorf("expected status 403 for a user, got %d", status)
+		}
+		if status, _ := sendAs(t, authServer, "root-token", http.MethodGet, "/api/admin/stats", nil); status != http.StatusOK {
+			t.Errorf("expected the admin to get the stats, got %d", status)
+		}
+	})
+}
+
+func TestImportOutputImage(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	sourceGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "First pipeline"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	sourceID, err := c.AddNode(ctx, sourceGraphID, client.NewNode{Name: "Source", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	sourceImageID, err := c.UploadOutputImage(ctx, sourceGraphID, sourceID, "original", "photo.png", photo.Bytes())
+	if err != nil {
+		t.Fatalf("failed to upload image: %v", err)
+	}
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Second pipeline"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Fed", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	imageID, err := c.ImportOutputImage(ctx, graphID, inputID, "original", client.ImageSource{
+		ImageGraphID: sourceGraphID,
+		NodeID:       sourceID,
+		OutputName:   "original",
+	})
+	if err != nil {
+		t.Fatalf("failed to import image: %v", err)
+	}
+	if imageID == sourceImageID {
+		t.Error("expected the image to be copied")
+	}
+
+	graph, err := c.GetImageGraph(ctx, graphID)
+	if err != nil {
+		t.Fatalf("failed to get graph: %v", err)
+	}
+	node, _ := graph.Node(inputID)
+	if len(node.Outputs) != 1 || node.Outputs[0].ImageID != imageID {
+		t.Errorf("expected the output to hold the copy, got %+v", node.Outputs)
+	}
+
+	data, err := c.GetImage(ctx, imageID)
+	if err != nil {
+		t.Fatalf("failed to download the copy: %v", err)
+	}
+	if !bytes.Equal(data, photo.Bytes()) {
+		t.Error("expected the copy to match the source image")
+	}
+
+	// Images are referred to by ID only within the graph that has them
+	if _, err := c.ImportOutputImage(ctx, graphID, inputID, "original", client.ImageSource{ImageID: sourceImageID}); client.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("expected 404 for an image of another graph, got %v", err)
+	}
+	if _, err := c.ImportOutputImage(ctx, graphID, inputID, "original", client.ImageSource{ImageGraphID: sourceGraphID, ImageID: sourceImageID}); err != nil {
+		t.Errorf("failed to import by image ID: %v", err)
+	}
+
+	otherID, err := c.AddNode(ctx, sourceGraphID, client.NewNode{Name: "Empty", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+	_, err = c.ImportOutputImage(ctx, graphID, inputID, "original", client.ImageSource{ImageGraphID: sourceGraphID, NodeID: otherID, OutputName: "original"})
+	if client.StatusCode(err) != http.StatusConflict {
+		t.Errorf("expected 409 for an output without an image, got %v", err)
+	}
+	_, err = c.ImportOutputImage(ctx, graphID, inputID, "original", client.ImageSource{ImageGraphID: sourceGraphID})
+	if client.StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected 400 without a source, got %v", err)
+	}
+}
+
+func TestGraphLinks(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	sourceGraphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "First pipeline"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	sourceID, err := c.AddNode(ctx, sourceGraphID, client.NewNode{Name: "Source", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	upload := func(width int) []byte {
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, width, 3))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		if _, err := c.UploadOutputImage(ctx, sourceGraphID, sourceID, "original", "photo.png", photo.Bytes()); err != nil {
+			t.Fatalf("failed to upload image: %v", err)
+		}
+		return photo.Bytes()
+	}
+
+	first := upload(4)
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Second pipeline"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	inputID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Fed", Type: "input"})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	// linkedImage checks the linked input node holds a copy of want once the
+	// pipeline settles
+	linkedImage := func(want []byte) {
+		t.Helper()
+		server.settle(t)
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		node, _ := graph.Node(inputID)
+		if len(node.Outputs) == 1 && node.Outputs[0].ImageID != "" {
+			data, err := c.GetImage(ctx, node.Outputs[0].ImageID)
+			if err == nil && bytes.Equal(data, want) {
+				return
+			}
+		}
+		t.Fatal("expected the linked input node to hold a copy of the source image")
+	}
+
+	link, err := c.CreateGraphLink(ctx, graphID, client.GraphLink{
+		NodeID:             inputID,
+		SourceImageGraphID: sourceGraphID,
+		SourceNodeID:       sourceID,
+		SourceOutputName:   "original",
+	})
+	if err != nil {
+		t.Fatalf("failed to link node: %v", err)
+	}
+
+	t.Run("starts with the source's current image", func(t *testing.T) {
+		linkedImage(first)
+	})
+
+	t.Run("propagates new source images", func(t *testing.T) {
+		linkedImage(upload(6))
+	})
+
+	t.Run("lists the links feeding the graph", func(t *testing.T) {
+		links, err := c.ListGraphLinks(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to list links: %v", err)
+		}
+		if len(links) != 1 || links[0].ID != link.ID || links[0].SourceNodeID != sourceID {
+			t.Errorf("expected the link, got %+v", links)
+		}
+	})
+
+	t.Run("rejects invalid links", func(t *testing.T) {
+		_, err := c.CreateGraphLink(ctx, graphID, client.GraphLink{NodeID: inputID, SourceImageGraphID: sourceGraphID, SourceNodeID: sourceID, SourceOutputName: "original"})
+		if client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected 409 linking a linked node, got %v", err)
+		}
+
+		backID, err := c.AddNode(ctx, sourceGraphID, client.NewNode{Name: "Back", Type: "input"})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		_, err = c.CreateGraphLink(ctx, sourceGraphID, client.GraphLink{NodeID: backID, SourceImageGraphID: graphID, SourceNodeID: inputID, SourceOutputName: "original"})
+		if client.StatusCode(err) != http.StatusConflict {
+			t.Errorf("expected 409 for a cycle, got %v", err)
+		}
+
+		blurID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Blur", Type: "blur"})
+		if err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+		_, err = c.CreateGraphLink(ctx, graphID, client.GraphLink{NodeID: blurID, SourceImageGraphID: sourceGraphID, SourceNodeID: sourceID, SourceOutputName: "original"})
+		if client.StatusCode(err) != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422 linking a node that isn't an input, got %v", err)
+		}
+
+		_, err = c.CreateGraphLink(ctx, sourceGraphID, client.GraphLink{NodeID: backID, SourceImageGraphID: sourceGraphID, SourceNodeID: sourceID, SourceOutputName: "original"})
+		if client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected 400 linking nodes of the same graph, got %v", err)
+		}
+	})
+
+	t.Run("removes links", func(t *testing.T) {
+		if err := c.DeleteGraphLink(ctx, graphID, link.ID); err != nil {
+			t.Fatalf("failed to remove link: %v", err)
+		}
+		if err := c.DeleteGraphLink(ctx, graphID, link.ID); client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected 404 removing it again, got %v", err)
+		}
+		links, err := c.ListGraphLinks(ctx, graphID)
+		if err != nil || len(links) != 0 {
+			t.Errorf("expected no links, got %+v, %v", links, err)
+		}
+	})
+}
+
+func TestSchedules(t *testing.T) {
+	store := inmem.NewScheduleStore()
+	server := setupTestServer(t, httpgateway.WithSchedules(store))
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Nightly"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	if _, err := c.GetSchedule(ctx, graphID); client.StatusCode(err) != http.StatusNotFound {
+		t.Errorf("expected 404 before a schedule is set, got %v", err)
+	}
+
+	t.Run("sets an enabled schedule", func(t *testing.T) {
+		schedule, err := c.SetSchedule(ctx, graphID, " @daily ", nil)
+		if err != nil {
+			t.Fatalf("failed to set schedule: %v", err)
+		}
+		if schedule.Cron != "@daily" || !schedule.Enabled || schedule.NextRunAt == nil {
+			t.Fatalf("expected an enabled daily schedule, got %+v", schedule)
+		}
+		if schedule.NextRunAt.Hour() != 0 || schedule.NextRunAt.Minute() != 0 {
+			t.Errorf("expected the next run at midnight, got %v", schedule.NextRunAt)
+		}
+	})
+
+	t.Run("disables the schedule", func(t *testing.T) {
+		disabled := false
+		if _, err := c.SetSchedule(ctx, graphID, "0 2 * * *", &disabled); err != nil {
+			t.Fatalf("failed to set schedule: %v", err)
+		}
+
+		// Changing the expression keeps the schedule disabled
+		schedule, err := c.SetSchedule(ctx, graphID, "0 3 * * *", nil)
+		if err != nil {
+			t.Fatalf("failed to set schedule: %v", err)
+		}
+		if schedule.Enabled || schedule.NextRunAt != nil {
+			t.Errorf("expected a disabled schedule, got %+v", schedule)
+		}
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		if _, err := c.SetSchedule(ctx, graphID, "0 25 * * *", nil); client.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("expected 400, got %v", err)
+		}
+	})
+
+	t.Run("lists the run history", func(t *testing.T) {
+		id, _ := imagegraph.ParseImageGraphID(graphID)
+		for _, status := range []string{application.ScheduledRunStarted, application.ScheduledRunSkipped} {
+			if err := store.AddRun(ctx, application.ScheduledRun{ImageGraphID: id, Status: status}); err != nil {
+				t.Fatalf("failed to add run: %v", err)
+			}
+		}
+
+		runs, err := c.ListScheduledRuns(ctx, graphID, 0)
+		if err != nil {
+			t.Fatalf("failed to list runs: %v", err)
+		}
+		if len(runs) != 2 || runs[0].Status != application.ScheduledRunSkipped {
+			t.Errorf("expected the runs newest first, got %+v", runs)
+		}
+	})
+
+	t.Run("removes the schedule", func(t *testing.T) {
+		if err := c.DeleteSchedule(ctx, graphID); err != nil {
+			t.Fatalf("failed to remove schedule: %v", err)
+		}
+		if err := c.DeleteSchedule(ctx, graphID); client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected 404 removing it again, got %v", err)
+		}
+	})
+}
+
+func TestImageProvenance(t *testing.T) {
+	server := setupTestServer(t)
+	defer server.Stop()
+
+	ctx := context.Background()
+	c := client.New(server.URL())
+
+	graphID, err := c.CreateImageGraph(ctx, client.NewImageGraph{Name: "Provenance"})
+	if err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+	blurID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Blur", Type: "blur", Config: json.RawMessage(`{"radius": 1}`)})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+	finalID, err := c.AddNode(ctx, graphID, client.NewNode{Name: "Final", Type: "output", Config: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+	err = c.ConnectNodes(ctx, graphID, client.Connection{FromNodeID: blurID, OutputName: "blurred", ToNodeID: finalID, InputName: "input"})
+	if err != nil {
+		t.Fatalf("failed to connect nodes: %v", err)
+	}
+
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 6, 4))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	inputIDs, err := c.UploadInputs(ctx, graphID, []client.InputUpload{
+		{Filename: "photo.png", Data: photo.Bytes()},
+	}, client.UploadInputsOptions{ConnectTo: blurID})
+	if err != nil {
+		t.Fatalf("failed to upload inputs: %v", err)
+	}
+
+	// outputImageID returns the image a node generated once the pipeline
+	// settles
+	outputImageID := func(nodeID string) string {
+		t.Helper()
+		server.settle(t)
+
+		graph, err := c.GetImageGraph(ctx, graphID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		node, _ := graph.Node(nodeID)
+		if len(node.Outputs) == 0 || node.Outputs[0].ImageID == "" {
+			t.Fatalf("expected node %s to have an output image", nodeID)
+		}
+		return node.Outputs[0].ImageID
+	}
+
+	finalImageID := outputImageID(finalID)
+	blurImageID := outputImageID(blurID)
+	sourceImageID := outputImageID(inputIDs[0])
+
+	t.Run("walks back to the uploaded image", func(t *testing.T) {
+		provenance, err := c.GetImageProvenance(ctx, finalImageID)
+		if err != nil {
+			t.Fatalf("failed to get provenance: %v", err)
+		}
+		if provenance.ImageID != finalImageID || len(provenance.Steps) != 3 {
+			t.Fatalf("expected a chain of three images, got %+v", provenance)
+		}
+
+		final := provenance.Steps[0].ProducedBy
+		if final == nil || final.NodeID != finalID || final.NodeName != "Final" || final.ImageGraphID != graphID || final.NodeType != "output" {
+			t.Errorf("expected the final image to be produced by the output node, got %+v", final)
+		}
+		if len(final.Inputs) != 1 || final.Inputs[0].ImageID != blurImageID {
+			t.Errorf("expected the final image to be generated from the blurred image, got %+v", final.Inputs)
+		}
+
+		blur := provenance.Steps[1].ProducedBy
+		if provenance.Steps[1].ImageID != blurImageID || blur == nil || blur.NodeName != "Blur" || blur.OutputName != "blurred" {
+			t.Errorf("expected the blurred image to be produced by the blur node, got %+v", provenance.Steps[1])
+		}
+		if blur != nil && (string(blur.Config) != `{"radius":1}` || len(blur.Inputs) != 1 || blur.Inputs[0].ImageID != sourceImageID) {
+			t.Errorf("expected the blur's config and input, got %s, %+v", blur.Config, blur.Inputs)
+		}
+
+		source := provenance.Steps[2]
+		if source.ImageID != sourceImageID || source.ProducedBy != nil || source.Filename != "photo.png" {
+			t.Errorf("expected the uploaded image to end the chain, got %+v", source)
+		}
+	})
+
+	t.Run("rejects unknown images", func(t *testing.T) {
+		_, err := c.GetImageProvenance(ctx, imagegraph.MustNewImageID().String())
+		if client.StatusCode(err) != http.StatusNotFound {
+			t.Errorf("expected 404 for an unknown image, got %v", err)
 		}
 	})
 }
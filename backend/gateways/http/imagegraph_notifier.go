@@ -3,8 +3,12 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/coder/websocket"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
@@ -15,10 +19,24 @@ import (
 type ImageGraphNotifier struct {
 	logger *slog.Logger
 
-	// Map of graph ID to set of connections
-	graphConnections map[imagegraph.ImageGraphID]map[*websocket.Conn]bool
+	// Map of graph ID to connections and what each subscribed to
+	graphConnections map[imagegraph.ImageGraphID]map[*websocket.Conn]*graphSubscriber
 	mu               sync.RWMutex
 
+	// Connections subscribed to the graph list and the graphs each may be
+	// told about
+	listConnections map[*websocket.Conn]GraphFilter
+
+	// Recent messages of each graph, replayed to clients that reconnect.
+	// Graphs without connections lose theirs once it is NotifierHistoryTTL
+	// old.
+	history map[imagegraph.ImageGraphID]*graphHistory
+
+	// epoch is when the notifier started, and histories counts the
+	// histories it has started, which together make up each history's epoch
+	epoch     string
+	histories uint64
+
 	// Channel for broadcasting messages
 	broadcast chan *BroadcastMessage
 	done      chan struct{}
@@ -27,13 +45,151 @@ type ImageGraphNotifier struct {
 // BroadcastMessage represents a message to broadcast to clients
 type BroadcastMessage struct {
 	GraphID imagegraph.ImageGraphID
-	Data    any
+	Data    WebSocketMessage
+
+	// GraphList messages go to the graph list's subscribers rather than
+	// the graph's
+	GraphList bool
 }
 
-// WebSocketMessage is the structure sent to clients
+// WebSocketMessage is the structure sent to clients. Broadcast messages
+// carry an EventID; clients pass the last one they saw when they reconnect to
+// be sent what they missed. Heartbeats and graph list updates aren't
+// numbered. GraphID tells connections subscribed to several graphs which one
+// a message is about.
 type WebSocketMessage struct {
-	Type string `json:"type"`
-	Data any    `json:"data"`
+	ID      string `json:"id,omitempty"`
+	GraphID string `json:"graph_id,omitempty"`
+	Type    string `json:"type"`
+	Data    any    `json:"data"`
+
+	// nodeID is the node a node_update is about, used for filtering
+	nodeID string
+}
+
+// Types of the messages broadcast about graph changes, which clients can
+// subscribe to
+const (
+	MessageTypeNodeUpdate   = "node_update"
+	MessageTypeLayoutUpdate = "layout_update"
+
+	// MessageTypeGraphListUpdate is sent to graph list subscribers when a
+	// graph they can see is created
+	MessageTypeGraphListUpdate = "graph_list_update"
+)
+
+// GraphFilter reports whether a graph list subscriber may be told about a
+// graph. A nil filter allows every graph.
+type GraphFilter func(imagegraph.ImageGraphID) bool
+
+// Subscription filters the messages broadcast to a connection to those about
+// the given nodes and of the given types. An empty filter lets everything
+// through, and messages that aren't about a node pass the node filter.
+type Subscription struct {
+	NodeIDs map[string]bool
+	Types   map[string]bool
+}
+
+// wants reports whether the subscription lets the message through
+func (s Subscription) wants(msg WebSocketMessage) bool {
+	if len(s.Types) > 0 && !s.Types[msg.Type] {
+		return false
+	}
+	if len(s.NodeIDs) > 0 && msg.nodeID != "" && !s.NodeIDs[msg.nodeID] {
+		return false
+	}
+	return true
+}
+
+// graphSubscriber is a connection's subscription to a graph. While the
+// messages the connection missed are replayed, the messages broadcast to it
+// are held in pending so that they are written after the replay.
+type graphSubscriber struct {
+	subscription Subscription
+	replaying    bool
+	pending      []WebSocketMessage
+}
+
+// NotifierReplayBufferSize is how many of a graph's most recent messages are
+// kept for replay to reconnecting clients
+const NotifierReplayBufferSize = 256
+
+// NotifierHistoryTTL is how long after its last message the history of a
+// graph without connections is kept. Clients reconnecting after it's gone
+// are sent a "resync" message.
+const NotifierHistoryTTL = 15 * time.Minute
+
+// notifierPruneInterval is how often histories past NotifierHistoryTTL are
+// removed
+const notifierPruneInterval = time.Minute
+
+// EventID identifies a message broadcast about a graph by its number, which
+// increases with every message sent for the graph, prefixed with the epoch of
+// the graph's history. IDs from before the server restarted, or from a
+// history that has since expired, are from another epoch.
+type EventID struct {
+	Epoch string
+	Seq   uint64
+}
+
+// ParseEventID parses an EventID written as epoch-seq
+func ParseEventID(value string) (EventID, error) {
+	epoch, seq, ok := strings.Cut(value, "-")
+	if !ok || epoch == "" {
+		return EventID{}, fmt.Errorf("invalid event ID %q, expected epoch-seq", value)
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return EventID{}, fmt.Errorf("invalid event ID %q: %w", value, err)
+	}
+
+	return EventID{Epoch: epoch, Seq: n}, nil
+}
+
+// String writes the EventID as epoch-seq
+func (id EventID) String() string {
+	return id.Epoch + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// IsZero reports whether the EventID is unset, as it is for new connections
+func (id EventID) IsZero() bool {
+	return id == EventID{}
+}
+
+// graphHistory numbers a graph's messages and keeps the most recent ones in
+// a ring buffer
+type graphHistory struct {
+	epoch     string
+	lastID    uint64
+	updatedAt time.Time
+	messages  [NotifierReplayBufferSize]WebSocketMessage
+}
+
+// add numbers the message and stores it, overwriting the oldest once the
+// buffer is full
+func (h *graphHistory) add(msg WebSocketMessage, now time.Time) WebSocketMessage {
+	h.lastID++
+	h.updatedAt = now
+	msg.ID = EventID{Epoch: h.epoch, Seq: h.lastID}.String()
+	h.messages[h.lastID%NotifierReplayBufferSize] = msg
+	return msg
+}
+
+// since returns the messages after lastEventID, oldest first. It returns
+// false if some of them are no longer buffered, or lastEventID is from
+// another epoch, in which case the client must reload.
+func (h *graphHistory) since(lastEventID EventID) ([]WebSocketMessage, bool) {
+	seq := lastEventID.Seq
+	if lastEventID.Epoch != h.epoch || seq > h.lastID || h.lastID-seq > NotifierReplayBufferSize {
+		return nil, false
+	}
+
+	missed := make([]WebSocketMessage, 0, h.lastID-seq)
+	for id := seq + 1; id <= h.lastID; id++ {
+		missed = append(missed, h.messages[id%NotifierReplayBufferSize])
+	}
+	return missed, true
 }
 
 // NodeUpdateMessage contains node state changes
@@ -47,7 +203,10 @@ type NodeUpdateMessage struct {
 func NewImageGraphNotifier(logger *slog.Logger) *ImageGraphNotifier {
 	notifier := &ImageGraphNotifier{
 		logger:           logger,
-		graphConnections: make(map[imagegraph.ImageGraphID]map[*websocket.Conn]bool),
+		graphConnections: make(map[imagegraph.ImageGraphID]map[*websocket.Conn]*graphSubscriber),
+		listConnections:  make(map[*websocket.Conn]GraphFilter),
+		history:          make(map[imagegraph.ImageGraphID]*graphHistory),
+		epoch:            strconv.FormatInt(time.Now().UnixNano(), 36),
 		broadcast:        make(chan *BroadcastMessage, 256),
 		done:             make(chan struct{}),
 	}
@@ -60,27 +219,120 @@ func NewImageGraphNotifier(logger *slog.Logger) *ImageGraphNotifier {
 
 // run is the main loop that handles broadcasting messages
 func (n *ImageGraphNotifier) run() {
+	prune := time.NewTicker(notifierPruneInterval)
+	defer prune.Stop()
+
 	for {
 		select {
 		case msg := <-n.broadcast:
-			n.broadcastToGraph(msg.GraphID, msg.Data)
+			if msg.GraphList {
+				n.broadcastToGraphList(msg.GraphID, msg.Data)
+			} else {
+				n.broadcastToGraph(msg.GraphID, msg.Data)
+			}
+		case now := <-prune.C:
+			n.pruneHistory(now)
 		case <-n.done:
 			return
 		}
 	}
 }
 
-// Register adds a connection for a specific graph
-func (n *ImageGraphNotifier) Register(graphID imagegraph.ImageGraphID, conn *websocket.Conn) {
+// Register adds a connection for a specific graph, which is only sent the
+// messages its subscription wants. A client reconnecting with the ID of the
+// last message it saw is sent the wanted messages broadcast since then, or a
+// "resync" message if they are no longer buffered; messages broadcast during
+// the replay are sent after it. A connection can be registered for several
+// graphs.
+func (n *ImageGraphNotifier) Register(
+	graphID imagegraph.ImageGraphID,
+	conn *websocket.Conn,
+	subscription Subscription,
+	lastEventID EventID,
+) {
 	n.mu.Lock()
-	defer n.mu.Unlock()
+
+	// Collect the missed messages while holding the lock so that none are
+	// broadcast between them and the registration
+	var missed []WebSocketMessage
+	if !lastEventID.IsZero() {
+		missed = n.missedMessages(graphID, subscription, lastEventID)
+	}
 
 	if n.graphConnections[graphID] == nil {
-		n.graphConnections[graphID] = make(map[*websocket.Conn]bool)
+		n.graphConnections[graphID] = make(map[*websocket.Conn]*graphSubscriber)
+	}
+	subscriber := &graphSubscriber{subscription: subscription, replaying: len(missed) > 0}
+	n.graphConnections[graphID][conn] = subscriber
+
+	n.logger.Info("client connected", "graph_id", graphID.String(), "total_connections", len(n.graphConnections[graphID]), "replayed", len(missed))
+	n.mu.Unlock()
+
+	if len(missed) > 0 {
+		n.replay(conn, subscriber, missed)
+	}
+}
+
+// replay writes the missed messages to a connection, followed by the
+// messages broadcast to it meanwhile, before letting broadcasts write to it
+func (n *ImageGraphNotifier) replay(
+	conn *websocket.Conn,
+	subscriber *graphSubscriber,
+	missed []WebSocketMessage,
+) {
+	for len(missed) > 0 {
+		for _, msg := range missed {
+			messageBytes, err := json.Marshal(msg)
+			if err == nil {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				err = conn.Write(ctx, websocket.MessageText, messageBytes)
+				cancel()
+			}
+			if err != nil {
+				n.logger.Error("failed to replay to websocket", "error", err)
+
+				// Stop holding messages back; broadcasts find the
+				// connection broken and unregister it
+				n.mu.Lock()
+				subscriber.replaying = false
+				subscriber.pending = nil
+				n.mu.Unlock()
+				return
+			}
+		}
+
+		n.mu.Lock()
+		missed = subscriber.pending
+		subscriber.pending = nil
+		subscriber.replaying = len(missed) > 0
+		n.mu.Unlock()
+	}
+}
+
+// missedMessages returns the graph's messages after lastEventID that the
+// subscription wants, or a single unnumbered "resync" message if they can't
+// all be replayed
+func (n *ImageGraphNotifier) missedMessages(
+	graphID imagegraph.ImageGraphID,
+	subscription Subscription,
+	lastEventID EventID,
+) []WebSocketMessage {
+	var missed []WebSocketMessage
+	ok := false
+	if history := n.history[graphID]; history != nil {
+		missed, ok = history.since(lastEventID)
+	}
+	if !ok {
+		return []WebSocketMessage{{GraphID: graphID.String(), Type: "resync", Data: map[string]any{}}}
 	}
-	n.graphConnections[graphID][conn] = true
 
-	n.logger.Info("client connected", "graph_id", graphID.String(), "total_connections", len(n.graphConnections[graphID]))
+	wanted := missed[:0]
+	for _, msg := range missed {
+		if subscription.wants(msg) {
+			wanted = append(wanted, msg)
+		}
+	}
+	return wanted
 }
 
 // Unregister removes a connection
@@ -98,8 +350,30 @@ func (n *ImageGraphNotifier) Unregister(graphID imagegraph.ImageGraphID, conn *w
 	n.logger.Info("client disconnected", "graph_id", graphID.String())
 }
 
+// RegisterGraphList subscribes a connection to updates about the graph list,
+// limited to the graphs the filter allows. Graph list updates aren't
+// replayed, so reconnecting clients should list the graphs again.
+func (n *ImageGraphNotifier) RegisterGraphList(conn *websocket.Conn, filter GraphFilter) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.listConnections[conn] = filter
+
+	n.logger.Info("graph list client connected", "total_connections", len(n.listConnections))
+}
+
+// UnregisterGraphList removes a connection's graph list subscription
+func (n *ImageGraphNotifier) UnregisterGraphList(conn *websocket.Conn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.listConnections, conn)
+
+	n.logger.Info("graph list client disconnected")
+}
+
 // Broadcast sends a message to all clients connected to a specific graph
-func (n *ImageGraphNotifier) Broadcast(graphID imagegraph.ImageGraphID, data any) {
+func (n *ImageGraphNotifier) Broadcast(graphID imagegraph.ImageGraphID, data WebSocketMessage) {
 	select {
 	case n.broadcast <- &BroadcastMessage{GraphID: graphID, Data: data}:
 	default:
@@ -107,11 +381,31 @@ func (n *ImageGraphNotifier) Broadcast(graphID imagegraph.ImageGraphID, data any
 	}
 }
 
-// broadcastToGraph sends data to all connections for a graph
-func (n *ImageGraphNotifier) broadcastToGraph(graphID imagegraph.ImageGraphID, data any) {
-	n.mu.RLock()
-	connections := n.graphConnections[graphID]
-	n.mu.RUnlock()
+// broadcastToGraph numbers the message, buffers it for replay and sends it
+// to all connections for a graph
+func (n *ImageGraphNotifier) broadcastToGraph(graphID imagegraph.ImageGraphID, data WebSocketMessage) {
+	n.mu.Lock()
+	history := n.history[graphID]
+	if history == nil {
+		n.histories++
+		history = &graphHistory{epoch: n.epoch + "." + strconv.FormatUint(n.histories, 36)}
+		n.history[graphID] = history
+	}
+	data.GraphID = graphID.String()
+	data = history.add(data, time.Now())
+
+	connections := make([]*websocket.Conn, 0, len(n.graphConnections[graphID]))
+	for conn, subscriber := range n.graphConnections[graphID] {
+		if !subscriber.subscription.wants(data) {
+			continue
+		}
+		if subscriber.replaying {
+			subscriber.pending = append(subscriber.pending, data)
+			continue
+		}
+		connections = append(connections, conn)
+	}
+	n.mu.Unlock()
 
 	if len(connections) == 0 {
 		return
@@ -125,7 +419,7 @@ func (n *ImageGraphNotifier) broadcastToGraph(graphID imagegraph.ImageGraphID, d
 	}
 
 	// Send to all connections
-	for conn := range connections {
+	for _, conn := range connections {
 		go func(c *websocket.Conn) {
 			ctx := context.Background()
 			if err := c.Write(ctx, websocket.MessageText, messageBytes); err != nil {
@@ -137,24 +431,105 @@ func (n *ImageGraphNotifier) broadcastToGraph(graphID imagegraph.ImageGraphID, d
 	}
 }
 
+// pruneHistory removes the histories of graphs without connections that
+// have had no messages for NotifierHistoryTTL
+func (n *ImageGraphNotifier) pruneHistory(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for graphID, history := range n.history {
+		if len(n.graphConnections[graphID]) == 0 && now.Sub(history.updatedAt) > NotifierHistoryTTL {
+			delete(n.history, graphID)
+		}
+	}
+}
+
+// broadcastToGraphList sends a message about a graph to the graph list
+// subscribers whose filter allows the graph
+func (n *ImageGraphNotifier) broadcastToGraphList(graphID imagegraph.ImageGraphID, data WebSocketMessage) {
+	n.mu.RLock()
+	subscribers := make(map[*websocket.Conn]GraphFilter, len(n.listConnections))
+	for conn, filter := range n.listConnections {
+		subscribers[conn] = filter
+	}
+	n.mu.RUnlock()
+
+	if len(subscribers) == 0 {
+		return
+	}
+
+	data.GraphID = graphID.String()
+	messageBytes, err := json.Marshal(data)
+	if err != nil {
+		n.logger.Error("failed to marshal websocket message", "error", err)
+		return
+	}
+
+	// Filters may look the graph up, so they run with the writes
+	for conn, filter := range subscribers {
+		go func(c *websocket.Conn, filter GraphFilter) {
+			if filter != nil && !filter(graphID) {
+				return
+			}
+			if err := c.Write(context.Background(), websocket.MessageText, messageBytes); err != nil {
+				n.logger.Error("failed to write to websocket", "error", err)
+				n.UnregisterGraphList(c)
+			}
+		}(conn, filter)
+	}
+}
+
 // BroadcastNodeUpdate sends a node update to all clients viewing the graph
 func (n *ImageGraphNotifier) BroadcastNodeUpdate(graphID imagegraph.ImageGraphID, nodeUpdate any) {
 	msg := WebSocketMessage{
-		Type: "node_update",
-		Data: nodeUpdate,
+		Type:   MessageTypeNodeUpdate,
+		Data:   nodeUpdate,
+		nodeID: nodeUpdateNodeID(nodeUpdate),
 	}
 	n.Broadcast(graphID, msg)
 }
 
+// nodeUpdateNodeID returns the ID of the node a node update is about
+func nodeUpdateNodeID(nodeUpdate any) string {
+	switch update := nodeUpdate.(type) {
+	case NodeUpdateMessage:
+		return update.NodeID
+	case map[string]any:
+		nodeID, _ := update["node_id"].(string)
+		return nodeID
+	default:
+		return ""
+	}
+}
+
 // BroadcastLayoutUpdate sends a layout update notification to all clients viewing the graph
 func (n *ImageGraphNotifier) BroadcastLayoutUpdate(graphID imagegraph.ImageGraphID) {
 	msg := WebSocketMessage{
-		Type: "layout_update",
+		Type: MessageTypeLayoutUpdate,
 		Data: map[string]any{},
 	}
 	n.Broadcast(graphID, msg)
 }
 
+// BroadcastGraphListUpdate sends a change to the graph list, such as a
+// created graph, to the clients subscribed to the list
+func (n *ImageGraphNotifier) BroadcastGraphListUpdate(graphID imagegraph.ImageGraphID, listUpdate any) {
+	msg := &BroadcastMessage{
+		GraphID: graphID,
+		Data: WebSocketMessage{
+			Type: MessageTypeGraphListUpdate,
+			Data: listUpdate,
+		},
+		GraphList: true,
+	}
+
+	select {
+	case n.broadcast <- msg:
+	default:
+		n.logger.Warn("broadcast channel full, dropping message", "graph_id", graphID.String())
+	}
+}
+
 // Close shuts down the notifier
 func (n *ImageGraphNotifier) Close() {
 	close(n.done)
@@ -170,4 +545,9 @@ func (n *ImageGraphNotifier) Close() {
 		}
 		delete(n.graphConnections, graphID)
 	}
+
+	for conn := range n.listConnections {
+		conn.Close(websocket.StatusNormalClosure, "server shutting down")
+		delete(n.listConnections, conn)
+	}
 }
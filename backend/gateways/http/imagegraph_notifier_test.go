@@ -0,0 +1,156 @@
+package http
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+func TestGraphHistoryReplaysMissedMessages(t *testing.T) {
+	h := graphHistory{epoch: "e"}
+	for range 5 {
+		h.add(WebSocketMessage{Type: "node_update"}, time.Now())
+	}
+
+	missed, ok := h.since(EventID{Epoch: "e", Seq: 2})
+	if !ok {
+		t.Fatal("expected messages after 2 to be replayable")
+	}
+	if len(missed) != 3 || missed[0].ID != "e-3" || missed[2].ID != "e-5" {
+		t.Errorf("expected messages 3 to 5, got %+v", missed)
+	}
+
+	missed, ok = h.since(EventID{Epoch: "e", Seq: 5})
+	if !ok || len(missed) != 0 {
+		t.Errorf("expected nothing missed after the latest message, got %+v", missed)
+	}
+
+	if _, ok := h.since(EventID{Epoch: "e", Seq: 6}); ok {
+		t.Error("expected an unknown ID to need a resync")
+	}
+
+	// IDs from before a restart or an expired history are from another epoch
+	if _, ok := h.since(EventID{Epoch: "old", Seq: 2}); ok {
+		t.Error("expected an ID from another epoch to need a resync")
+	}
+}
+
+func TestGraphHistoryDropsOldestMessages(t *testing.T) {
+	h := graphHistory{epoch: "e"}
+	for range NotifierReplayBufferSize + 10 {
+		h.add(WebSocketMessage{Type: "node_update"}, time.Now())
+	}
+
+	if _, ok := h.since(EventID{Epoch: "e", Seq: 9}); ok {
+		t.Error("expected overwritten messages to need a resync")
+	}
+
+	missed, ok := h.since(EventID{Epoch: "e", Seq: 10})
+	if !ok {
+		t.Fatal("expected the buffered messages to be replayable")
+	}
+	if len(missed) != NotifierReplayBufferSize || missed[0].ID != "e-11" {
+		t.Errorf("expected %d messages from e-11, got %d from %s", NotifierReplayBufferSize, len(missed), missed[0].ID)
+	}
+}
+
+func TestParseEventID(t *testing.T) {
+	id, err := ParseEventID(EventID{Epoch: "abc.1", Seq: 42}.String())
+	if err != nil || id != (EventID{Epoch: "abc.1", Seq: 42}) {
+		t.Errorf("expected the ID to round trip, got %+v, %v", id, err)
+	}
+
+	for _, value := range []string{"42", "-42", "abc-", "abc-x"} {
+		if _, err := ParseEventID(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestNotifierPrunesIdleHistory(t *testing.T) {
+	n := NewImageGraphNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer n.Close()
+
+	graphID := imagegraph.MustNewImageGraphID()
+	n.broadcastToGraph(graphID, WebSocketMessage{Type: MessageTypeLayoutUpdate})
+
+	n.pruneHistory(time.Now())
+	if n.history[graphID] == nil {
+		t.Fatal("expected a recent history to be kept")
+	}
+
+	lastEventID, err := ParseEventID(n.history[graphID].messages[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.pruneHistory(time.Now().Add(NotifierHistoryTTL + time.Second))
+	if n.history[graphID] != nil {
+		t.Error("expected an idle history to be removed")
+	}
+
+	// A new history for the graph starts a new epoch, so the IDs of the
+	// removed one can't be mistaken for its own
+	n.broadcastToGraph(graphID, WebSocketMessage{Type: MessageTypeLayoutUpdate})
+	missed := n.missedMessages(graphID, Subscription{}, lastEventID)
+	if len(missed) != 1 || missed[0].Type != "resync" {
+		t.Errorf("expected a resync for an ID of the removed history, got %+v", missed)
+	}
+}
+
+func TestNotifierHoldsBroadcastsDuringReplay(t *testing.T) {
+	n := NewImageGraphNotifier(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer n.Close()
+
+	graphID := imagegraph.MustNewImageGraphID()
+	subscriber := &graphSubscriber{replaying: true}
+	n.graphConnections[graphID] = map[*websocket.Conn]*graphSubscriber{nil: subscriber}
+	defer n.Unregister(graphID, nil)
+
+	n.broadcastToGraph(graphID, WebSocketMessage{Type: MessageTypeLayoutUpdate})
+
+	if len(subscriber.pending) != 1 || subscriber.pending[0].ID == "" {
+		t.Errorf("expected the broadcast to wait for the replay, got %+v", subscriber.pending)
+	}
+}
+
+func TestSubscriptionFiltersMessages(t *testing.T) {
+	nodeUpdate := func(nodeID string) WebSocketMessage {
+		return WebSocketMessage{Type: MessageTypeNodeUpdate, nodeID: nodeID}
+	}
+	layoutUpdate := WebSocketMessage{Type: MessageTypeLayoutUpdate}
+
+	everything := Subscription{}
+	if !everything.wants(nodeUpdate("a")) || !everything.wants(layoutUpdate) {
+		t.Error("expected an empty subscription to want everything")
+	}
+
+	oneNode := Subscription{NodeIDs: map[string]bool{"a": true}}
+	if !oneNode.wants(nodeUpdate("a")) {
+		t.Error("expected the subscribed node's updates")
+	}
+	if oneNode.wants(nodeUpdate("b")) {
+		t.Error("expected other nodes' updates to be filtered")
+	}
+	if !oneNode.wants(layoutUpdate) {
+		t.Error("expected updates not about a node to pass the node filter")
+	}
+
+	nodesOnly := Subscription{Types: map[string]bool{MessageTypeNodeUpdate: true}}
+	if nodesOnly.wants(layoutUpdate) {
+		t.Error("expected other types to be filtered")
+	}
+}
+
+func TestNodeUpdateNodeID(t *testing.T) {
+	if got := nodeUpdateNodeID(map[string]any{"node_id": "a", "state": "completed"}); got != "a" {
+		t.Errorf("expected node ID from map, got %q", got)
+	}
+	if got := nodeUpdateNodeID(NodeUpdateMessage{NodeID: "b"}); got != "b" {
+		t.Errorf("expected node ID from NodeUpdateMessage, got %q", got)
+	}
+}
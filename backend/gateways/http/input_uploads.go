@@ -0,0 +1,377 @@
+package http
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+)
+
+const (
+	// maxInputUploadSize caps a whole bulk input upload
+	maxInputUploadSize = 200 * 1024 * 1024 // 200 MB
+
+	// maxInputImageSize caps each image of a bulk input upload, as uploads
+	// of a single image are
+	maxInputImageSize = 10 * 1024 * 1024 // 10 MB
+
+	// maxInputImages caps how many images a bulk input upload can create
+	// nodes for, counting those expanded from archives
+	maxInputImages = 200
+)
+
+var errTooManyInputs = fmt.Errorf("too many images (max %d)", maxInputImages)
+
+// errNotAnImage rejects uploads that can't be decoded as an image
+var errNotAnImage = errors.New("file is not a decodable image")
+
+// uploadedInput is an image of a bulk input upload
+type uploadedInput struct {
+	name   string
+	data   []byte
+	upload imagegraph.UploadedImage
+}
+
+// handleUploadInputs creates an Input node for each uploaded image, named
+// after its file. The "images" form field takes images and ZIP archives of
+// images, which are expanded in name order. If connect_to is given, the new
+// nodes are connected to that node's unconnected inputs in order, starting
+// at connect_input if given.
+func (s *HTTPServer) handleUploadInputs(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxInputUploadSize)
+	if err := r.ParseMultipartForm(maxInputImageSize); err != nil {
+		s.logger.Error("failed to parse multipart form", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid multipart form data"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	inputs, err := readUploadedInputs(r.MultipartForm.File["images"])
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if len(inputs) == 0 {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "at least one image is required"})
+		return
+	}
+	for i, input := range inputs {
+		upload, err := s.inspectUpload(input.data)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("%s: %s", input.name, err)})
+			return
+		}
+		inputs[i].upload = upload
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to upload inputs"})
+		return
+	}
+
+	var toNodeID imagegraph.NodeID
+	var toInputs []imagegraph.InputName
+	if connectTo := r.FormValue("connect_to"); connectTo != "" {
+		toNodeID, err = imagegraph.ParseNodeID(connectTo)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid connect_to"})
+			return
+		}
+
+		toInputs, err = freeInputs(ig, toNodeID, imagegraph.InputName(r.FormValue("connect_input")))
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		if len(inputs) > len(toInputs) {
+			respondJSON(w, http.StatusBadRequest, errorResponse{
+				Error: fmt.Sprintf("connect_to has %d unconnected inputs for %d images", len(toInputs), len(inputs)),
+			})
+			return
+		}
+	}
+
+	// Check the limits up front rather than stopping part way through
+	complexity := ig.Complexity()
+	complexity.Nodes += len(inputs)
+	if !toNodeID.IsNil() {
+		complexity.Connections += len(inputs)
+	}
+	if (s.graphLimits.MaxNodes > 0 && complexity.Nodes > s.graphLimits.MaxNodes) ||
+		(s.graphLimits.MaxConnections > 0 && complexity.Connections > s.graphLimits.MaxConnections) {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "image graph limit reached"})
+		return
+	}
+
+	nodeIDs := make([]string, 0, len(inputs))
+	for i, input := range inputs {
+		nodeID, err := s.addInputNode(r, imageGraphID, input)
+		if err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			s.logger.Error("failed to upload input", "error", err, "id", imageGraphID, "file", input.name)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("failed to upload %s", input.name)})
+			return
+		}
+		nodeIDs = append(nodeIDs, nodeID.String())
+
+		if toNodeID.IsNil() {
+			continue
+		}
+
+		command := application.NewConnectImageGraphNodesCommand(
+			imageGraphID,
+			nodeID,
+			imagegraph.NodeTypeDefs[imagegraph.NodeTypeInput].PrimaryOutput(),
+			toNodeID,
+			toInputs[i],
+		)
+
+		if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			s.logger.Error("failed to handle ConnectImageGraphNodesCommand", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("failed to connect %s", input.name)})
+			return
+		}
+	}
+
+	respondJSON(w, http.StatusCreated, uploadInputsResponse{NodeIDs: nodeIDs})
+}
+
+// addInputNode adds an Input node named after the uploaded file and sets
+// its output to the image
+func (s *HTTPServer) addInputNode(
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	input uploadedInput,
+) (
+	imagegraph.NodeID,
+	error,
+) {
+	nodeID, err := s.idGenerator.NewNodeID()
+	if err != nil {
+		return nodeID, fmt.Errorf("could not generate node ID: %w", err)
+	}
+
+	addCommand := application.NewAddImageGraphNodeCommand(
+		imageGraphID,
+		nodeID,
+		imagegraph.NodeTypeInput,
+		input.name,
+		imagegraph.NewNodeConfig(imagegraph.NodeTypeInput),
+		"",
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), addCommand); err != nil {
+		return nodeID, fmt.Errorf("could not add node: %w", err)
+	}
+
+	imageID := imagegraph.MustNewImageID()
+
+	if err := filestorage.SaveUpload(s.imageStorage, imageID, input.name, input.data); err != nil {
+		return nodeID, fmt.Errorf("could not save image: %w", err)
+	}
+
+	setImageCommand := application.NewSetImageGraphNodeOutputImageCommand(
+		imageGraphID,
+		nodeID,
+		imagegraph.NodeTypeDefs[imagegraph.NodeTypeInput].PrimaryOutput(),
+		imageID,
+		0, // allow command handler to resolve to current node version
+	)
+	setImageCommand.Upload = &input.upload
+
+	if err := s.messageBus.HandleCommand(r.Context(), setImageCommand); err != nil {
+		return nodeID, fmt.Errorf("could not set node output image: %w", err)
+	}
+
+	return nodeID, nil
+}
+
+// freeInputs returns the unconnected inputs of a node in the order its type
+// declares them, starting at from if it isn't empty
+func freeInputs(
+	ig *imagegraph.ImageGraph,
+	nodeID imagegraph.NodeID,
+	from imagegraph.InputName,
+) (
+	[]imagegraph.InputName,
+	error,
+) {
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		return nil, errors.New("connect_to node not found")
+	}
+
+	names := node.InputNames()
+	if from != "" {
+		start := slices.Index(names, from)
+		if start < 0 {
+			return nil, fmt.Errorf("connect_to node has no input %q", from)
+		}
+		names = names[start:]
+	}
+
+	var free []imagegraph.InputName
+	for _, name := range names {
+		if connected, err := node.IsInputConnected(name); err == nil && !connected {
+			free = append(free, name)
+		}
+	}
+
+	return free, nil
+}
+
+// inspectUpload checks that uploaded data is an image that can be decoded,
+// whatever its Content-Type claimed, and returns what was detected about
+// it. Dimensions are checked against the image limits from the header
+// before the pixels are decoded, across all of an animation's frames since
+// generation decodes each of them.
+func (s *HTTPServer) inspectUpload(data []byte) (imagegraph.UploadedImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return imagegraph.UploadedImage{}, errNotAnImage
+	}
+
+	size := imagegraph.ImageSize{Width: config.Width, Height: config.Height}
+	if err := s.imageLimits.CheckFrames(size, imagegen.CountFrames(data)); err != nil {
+		return imagegraph.UploadedImage{}, err
+	}
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return imagegraph.UploadedImage{}, errNotAnImage
+	}
+
+	return imagegraph.UploadedImage{
+		Format: format,
+		Width:  config.Width,
+		Height: config.Height,
+		Bytes:  len(data),
+	}, nil
+}
+
+// readUploadedInputs reads the images of a bulk input upload, expanding ZIP
+// archives
+func readUploadedInputs(files []*multipart.FileHeader) ([]uploadedInput, error) {
+	var inputs []uploadedInput
+
+	for _, header := range files {
+		data, err := readFormFile(header)
+		if err != nil {
+			return nil, err
+		}
+
+		if http.DetectContentType(data) == "application/zip" {
+			archived, err := readZippedInputs(header.Filename, data, maxInputImages-len(inputs))
+			if err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, archived...)
+			continue
+		}
+
+		if len(inputs) == maxInputImages {
+			return nil, errTooManyInputs
+		}
+
+		if len(data) > maxInputImageSize {
+			return nil, fmt.Errorf("%s is too large (max 10MB)", header.Filename)
+		}
+		if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+			return nil, fmt.Errorf("%s is not an image", header.Filename)
+		}
+		inputs = append(inputs, uploadedInput{name: header.Filename, data: data})
+	}
+
+	return inputs, nil
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s", header.Filename)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s", header.Filename)
+	}
+	return data, nil
+}
+
+// readZippedInputs reads up to limit images of a ZIP archive in name order,
+// skipping directories, hidden files and files that aren't images
+func readZippedInputs(archiveName string, data []byte, limit int) ([]uploadedInput, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid ZIP archive", archiveName)
+	}
+
+	files := slices.Clone(archive.File)
+	slices.SortFunc(files, func(a, b *zip.File) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var inputs []uploadedInput
+	for _, file := range files {
+		name := path.Base(file.Name)
+		if file.FileInfo().IsDir() || strings.HasPrefix(name, ".") || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+		if file.UncompressedSize64 > maxInputImageSize {
+			return nil, fmt.Errorf("%s in %s is too large (max 10MB)", file.Name, archiveName)
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s in %s", file.Name, archiveName)
+		}
+		// Don't trust the archive's sizes when reading
+		imageData, err := io.ReadAll(io.LimitReader(rc, maxInputImageSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s in %s", file.Name, archiveName)
+		}
+		if len(imageData) > maxInputImageSize {
+			return nil, fmt.Errorf("%s in %s is too large (max 10MB)", file.Name, archiveName)
+		}
+
+		if !strings.HasPrefix(http.DetectContentType(imageData), "image/") {
+			continue
+		}
+		if len(inputs) == limit {
+			return nil, errTooManyInputs
+		}
+		inputs = append(inputs, uploadedInput{name: name, data: imageData})
+	}
+
+	return inputs, nil
+}
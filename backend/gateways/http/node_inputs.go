@@ -0,0 +1,148 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// handleAddNodeInput adds an input to a node whose type can grow inputs.
+// The input is named after the node's highest numbered input unless the
+// request names it.
+func (s *HTTPServer) handleAddNodeInput(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	// The body is optional
+	var req addNodeInputRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to add input"})
+		return
+	}
+
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	inputName := imagegraph.InputName(req.InputName)
+	if inputName == "" {
+		inputName, err = node.NextInputName()
+		if err != nil {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "node type has a fixed set of inputs"})
+			return
+		}
+	}
+
+	command := application.NewAddImageGraphNodeInputCommand(imageGraphID, nodeID, inputName)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, imagegraph.ErrInvalidInputName) {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf(
+				"input %q already exists or isn't named like %q",
+				inputName, imagegraph.NodeTypeDefs[node.Type].DynamicInputName(1),
+			)})
+			return
+		}
+		s.respondNodeInputError(w, err, "failed to add input")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, addNodeInputResponse{InputName: string(inputName)})
+}
+
+// handleRemoveNodeInput removes an input that was added to a node,
+// disconnecting it first
+func (s *HTTPServer) handleRemoveNodeInput(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	inputName := imagegraph.InputName(r.PathValue("input_name"))
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to remove input"})
+		return
+	}
+
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+	if !node.HasInput(inputName) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "input not found"})
+		return
+	}
+
+	command := application.NewRemoveImageGraphNodeInputCommand(imageGraphID, nodeID, inputName)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		s.respondNodeInputError(w, err, "failed to remove input")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondNodeInputError responds to a failure to add or remove a node input
+func (s *HTTPServer) respondNodeInputError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, application.ErrImageGraphNotFound):
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+	case errors.Is(err, imagegraph.ErrFixedInput):
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "input is fixed by the node type"})
+	case errors.Is(err, imagegraph.ErrInputLimit):
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "node has the most inputs its type allows"})
+	default:
+		s.logger.Error("failed to update node inputs", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: message})
+	}
+}
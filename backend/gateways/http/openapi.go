@@ -0,0 +1,659 @@
+package http
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+//go:embed swagger/index.html
+var swaggerUI embed.FS
+
+// openAPIOperation documents a route. Request and Response are zero values
+// of the types the handler decodes and responds with; their schemas are
+// generated from the types' JSON tags.
+type openAPIOperation struct {
+	Summary  string
+	Tag      string
+	Query    []openAPIQueryParam
+	Request  any
+	Response any
+	Status   int
+
+	// ContentType overrides the JSON content type of successful responses,
+	// for routes that serve files
+	ContentType string
+
+	// Multipart requests upload a file in the named form field
+	Multipart string
+}
+
+type openAPIQueryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// openAPIOperations documents every API route, keyed by its mux pattern.
+// TestOpenAPI checks that no registered route is missing.
+var openAPIOperations = map[string]openAPIOperation{
+	"GET /api/openapi.json": {Summary: "Get this OpenAPI document", Tag: "meta", Response: map[string]any{}},
+	"GET /api/docs":         {Summary: "Browse the API with Swagger UI", Tag: "meta", ContentType: "text/html"},
+	"GET /api/me":           {Summary: "Get the authenticated user", Tag: "users", Response: currentUserResponse{}},
+
+	"GET /api/keys":             {Summary: "List your API keys", Tag: "api-keys", Response: listAPIKeysResponse{}},
+	"POST /api/keys":            {Summary: "Issue an API key", Tag: "api-keys", Request: createAPIKeyRequest{}, Response: createAPIKeyResponse{}, Status: http.StatusCreated},
+	"DELETE /api/keys/{key_id}": {Summary: "Revoke an API key", Tag: "api-keys"},
+	"GET /api/admin/stats":      {Summary: "Report stored graphs, nodes and images for capacity monitoring", Tag: "admin", Response: adminStatsResponse{}},
+	"GET /api/admin/dlq": {
+		Summary:  "List the events whose handlers kept failing, newest first",
+		Tag:      "admin",
+		Query:    []openAPIQueryParam{{Name: "limit", Type: "integer", Description: "How many dead letters to list, 1-500 (default 100)"}},
+		Response: listDeadLettersResponse{},
+	},
+	"GET /api/admin/dlq/{dead_letter_id}": {
+		Summary:  "Get a dead letter",
+		Tag:      "admin",
+		Response: deadLetterResponse{},
+	},
+	"POST /api/admin/dlq/{dead_letter_id}/replay": {
+		Summary: "Hand a dead letter's event to the handler that failed it again, removing it if the handler succeeds",
+		Tag:     "admin",
+	},
+	"DELETE /api/admin/dlq/{dead_letter_id}": {
+		Summary: "Discard a dead letter",
+		Tag:     "admin",
+	},
+	"GET /api/node-types":       {Summary: "List node types and their config schemas", Tag: "node-types", Response: nodeTypeSchemasResponse{}},
+	"GET /api/search":           {Summary: "Search graph and node names and tags", Tag: "imagegraphs", Query: []openAPIQueryParam{{Name: "q", Type: "string", Description: "Text to search for"}}, Response: searchResponse{}},
+	"GET /api/imagegraphs":      {Summary: "List image graphs", Tag: "imagegraphs", Query: listImageGraphsQuery, Response: listImageGraphsResponse{}},
+	"POST /api/imagegraphs":     {Summary: "Create an image graph", Tag: "imagegraphs", Request: createImageGraphRequest{}, Response: createImageGraphResponse{}, Status: http.StatusCreated},
+	"GET /api/imagegraphs/{id}": {Summary: "Get an image graph, or 304 if If-None-Match names its ETag", Tag: "imagegraphs", Query: imageGraphViewQuery, Response: imageGraphResponse{}},
+	"GET /api/imagegraphs/by-external-id": {
+		Summary:  "Get an image graph by external ID",
+		Tag:      "imagegraphs",
+		Query:    append([]openAPIQueryParam{{Name: "external_id", Type: "string"}}, imageGraphViewQuery...),
+		Response: imageGraphResponse{},
+	},
+	"PUT /api/imagegraphs/{id}/public":                        {Summary: "Publish or unpublish an image graph to the gallery", Tag: "imagegraphs", Request: setImageGraphPublicRequest{}},
+	"PUT /api/imagegraphs/{id}/color-management":              {Summary: "Set what happens to the color profiles of source images in final images", Tag: "imagegraphs", Request: setColorManagementRequest{}},
+	"PUT /api/imagegraphs/{id}/performance-mode":              {Summary: "Set whether intermediate nodes skip their previews during generation", Tag: "imagegraphs", Request: setPerformanceModeRequest{}},
+	"PUT /api/imagegraphs/{id}/seed":                          {Summary: "Set the seed stochastic nodes without a seed of their own generate with, or clear it with null", Tag: "imagegraphs", Request: setSeedRequest{}},
+	"PUT /api/imagegraphs/{id}/parameters":                    {Summary: "Replace the parameters node expressions can refer to, regenerating the nodes that use changed ones", Tag: "imagegraphs", Request: setParametersRequest{}},
+	"GET /api/imagegraphs/{id}/shares":                        {Summary: "List the users an image graph is shared with", Tag: "sharing", Response: listSharesResponse{}},
+	"PUT /api/imagegraphs/{id}/shares/{user_id}":              {Summary: "Share an image graph with a user", Tag: "sharing", Request: shareImageGraphRequest{}},
+	"DELETE /api/imagegraphs/{id}/shares/{user_id}":           {Summary: "Stop sharing an image graph with a user", Tag: "sharing"},
+	"PUT /api/imagegraphs/{id}/tags/{tag}":                    {Summary: "Tag an image graph", Tag: "tags"},
+	"DELETE /api/imagegraphs/{id}/tags/{tag}":                 {Summary: "Untag an image graph", Tag: "tags"},
+	"PUT /api/imagegraphs/{id}/nodes/{node_id}/tags/{tag}":    {Summary: "Tag a node", Tag: "tags"},
+	"DELETE /api/imagegraphs/{id}/nodes/{node_id}/tags/{tag}": {Summary: "Untag a node", Tag: "tags"},
+	"GET /api/imagegraphs/{id}/latency":                       {Summary: "Get propagation latency statistics", Tag: "imagegraphs", Response: propagationLatencyResponse{}},
+	"GET /api/imagegraphs/{id}/exports":                       {Summary: "List the images of output nodes", Tag: "exports", Response: listExportsResponse{}},
+	"GET /api/imagegraphs/{id}/exports/archive":               {Summary: "Download the images of output nodes as a ZIP", Tag: "exports", ContentType: "application/zip"},
+	"GET /api/imagegraphs/{id}/webhooks":                      {Summary: "List the webhooks notified when the pipeline completes", Tag: "webhooks", Response: listWebhooksResponse{}},
+	"POST /api/imagegraphs/{id}/webhooks":                     {Summary: "Register a webhook", Tag: "webhooks", Request: createWebhookRequest{}, Response: createWebhookResponse{}, Status: http.StatusCreated},
+	"DELETE /api/imagegraphs/{id}/webhooks/{webhook_id}":      {Summary: "Remove a webhook", Tag: "webhooks"},
+	"GET /api/imagegraphs/{id}/links":                         {Summary: "List the links feeding input nodes from other graphs", Tag: "links", Response: listGraphLinksResponse{}},
+	"POST /api/imagegraphs/{id}/links":                        {Summary: "Link an input node to a node output of another graph", Tag: "links", Request: createGraphLinkRequest{}, Response: graphLinkResponse{}, Status: http.StatusCreated},
+	"DELETE /api/imagegraphs/{id}/links/{link_id}":            {Summary: "Remove a graph link", Tag: "links"},
+	"GET /api/imagegraphs/{id}/schedule":                      {Summary: "Get the cron schedule a graph regenerates on", Tag: "schedules", Response: scheduleResponse{}},
+	"PUT /api/imagegraphs/{id}/schedule":                      {Summary: "Set, enable or disable the cron schedule a graph regenerates on", Tag: "schedules", Request: setScheduleRequest{}, Response: scheduleResponse{}},
+	"DELETE /api/imagegraphs/{id}/schedule":                   {Summary: "Remove a graph's schedule", Tag: "schedules"},
+	"POST /api/imagegraphs/{id}/nodes":                        {Summary: "Add a node", Tag: "nodes", Request: addNodeRequest{}, Response: addNodeResponse{}, Status: http.StatusCreated},
+	"GET /api/imagegraphs/{id}/snapshots":                     {Summary: "List the named snapshots of a graph's results", Tag: "snapshots", Response: listSnapshotsResponse{}},
+	"POST /api/imagegraphs/{id}/snapshots":                    {Summary: "Snapshot the configs and output images of every node under a name", Tag: "snapshots", Request: createSnapshotRequest{}, Response: snapshotResponse{}, Status: http.StatusCreated},
+	"GET /api/imagegraphs/{id}/snapshots/{snapshot_id}":       {Summary: "Get a snapshot", Tag: "snapshots", Response: snapshotResponse{}},
+	"GET /api/imagegraphs/{id}/snapshots/{snapshot_id}/compare": {
+		Summary:  "Compare a snapshot with another snapshot, or with the graph as it is now",
+		Tag:      "snapshots",
+		Query:    []openAPIQueryParam{{Name: "to", Type: "string", Description: "ID of the snapshot to compare with; the current graph when omitted"}},
+		Response: compareSnapshotsResponse{},
+	},
+	"GET /api/imagegraphs/{id}/runs": {
+		Summary:  "List the recent runs of a graph's pipeline, newest first",
+		Tag:      "imagegraphs",
+		Query:    []openAPIQueryParam{{Name: "limit", Type: "integer", Description: "How many of the most recent runs to list (default 20)"}},
+		Response: listPipelineRunsResponse{},
+	},
+	"GET /api/imagegraphs/{id}/schedule/runs": {
+		Summary:  "List the recent times a graph's schedule came due, newest first",
+		Tag:      "schedules",
+		Query:    []openAPIQueryParam{{Name: "limit", Type: "integer", Description: "How many runs to list, 1-200 (default 20)"}},
+		Response: listScheduledRunsResponse{},
+	},
+	"GET /api/imagegraphs/{id}/nodes/by-external-id": {
+		Summary:  "Get a node by external ID",
+		Tag:      "nodes",
+		Query:    []openAPIQueryParam{{Name: "external_id", Type: "string"}},
+		Response: nodeResponse{},
+	},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/import": {
+		Summary:  "Set a node output to a copy of an image from this or another graph",
+		Tag:      "nodes",
+		Request:  importOutputImageRequest{},
+		Response: uploadImageResponse{},
+		Status:   http.StatusCreated,
+	},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/image": {
+		Summary:     "Download the current image of a node output (409 while it has none)",
+		Tag:         "nodes",
+		ContentType: "image/png",
+	},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/history": {
+		Summary:  "List the current image of a node output and the images it replaced",
+		Tag:      "nodes",
+		Response: outputHistoryResponse{},
+	},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/history/{image_id}/promote": {
+		Summary: "Make a previous image of a node output its current image again",
+		Tag:     "nodes",
+	},
+	"DELETE /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}": {
+		Summary: "Clear a node output's image, which moves into its history, and the inputs connected to it downstream",
+		Tag:     "nodes",
+	},
+	"PATCH /api/imagegraphs/{id}/nodes/{node_id}": {
+		Summary: "Update a node",
+		Tag:     "nodes",
+		Query:   []openAPIQueryParam{{Name: "dry_run", Type: "boolean", Description: "Validate the config without applying anything; responds like validate"}},
+		Request: updateNodeRequest{},
+	},
+	"DELETE /api/imagegraphs/{id}/nodes/{node_id}":                     {Summary: "Remove a node", Tag: "nodes"},
+	"GET /api/imagegraphs/{id}/validate":                               {Summary: "Check the image kinds and sizes carried by every connection of a graph", Tag: "imagegraphs", Response: validateImageGraphResponse{}},
+	"GET /api/imagegraphs/{id}/diagnostics":                            {Summary: "Report unconnected inputs, dangling outputs, unreachable outputs, failed or stuck nodes and broken connections", Tag: "imagegraphs", Query: diagnosticsQuery, Response: diagnosticsResponse{}},
+	"GET /api/imagegraphs/{id}/estimate":                               {Summary: "Estimate the output sizes, memory and time of regenerating every node of a graph, from its input images and recorded timings", Tag: "imagegraphs", Response: estimateResponse{}},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/validate":              {Summary: "Validate a node config", Tag: "nodes", Request: validateNodeConfigRequest{}, Response: validateNodeConfigResponse{}},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/stats":                  {Summary: "Report how long the recent generations of a node took and the pixels they worked on", Tag: "nodes", Query: nodeStatsQuery, Response: nodeStatsResponse{}},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/upgrade":               {Summary: "Upgrade a node to its latest implementation", Tag: "nodes"},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/diff":                   {Summary: "Measure how much the inputs of a diff node differ", Tag: "nodes", Response: nodeDiffResponse{}},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/preview":                {Summary: "Download a node's preview, generating it if it was skipped", Tag: "nodes", ContentType: "image/png"},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/duplicate":             {Summary: "Copy a node's type, name, config and added inputs, without connections, into this graph or target_graph_id, offset from it on the canvas", Tag: "nodes", Request: duplicateNodeRequest{}, Response: duplicateNodeResponse{}, Status: http.StatusCreated},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/sweep":                 {Summary: "Add a copy of a node for each value of a config field, connected like the node and laid out in a row", Tag: "nodes", Request: sweepNodeRequest{}, Response: sweepNodeResponse{}, Status: http.StatusCreated},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/inputs":                {Summary: "Add an input to a node whose type can grow inputs, named after its highest numbered input unless input_name is given", Tag: "nodes", Request: addNodeInputRequest{}, Response: addNodeInputResponse{}, Status: http.StatusCreated},
+	"DELETE /api/imagegraphs/{id}/nodes/{node_id}/inputs/{input_name}": {Summary: "Remove an added input from a node, disconnecting it first", Tag: "nodes"},
+	"PUT /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}":  {Summary: "Upload a node output image", Tag: "nodes", Multipart: "image", Response: uploadImageResponse{}, Status: http.StatusCreated},
+	"POST /api/imagegraphs/{id}/inputs":                                {Summary: "Upload images or ZIPs of images as new Input nodes, optionally connected to connect_to's free inputs from connect_input", Tag: "nodes", Multipart: "images", Response: uploadInputsResponse{}, Status: http.StatusCreated},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/palette":                {Summary: "Download the palette a node outputs as a GIMP .gpl, Adobe .aco or hex text file", Tag: "nodes", Query: []openAPIQueryParam{{Name: "format", Type: "string", Description: "gpl (default), aco or hex"}}, ContentType: "application/octet-stream"},
+	"POST /api/imagegraphs/{id}/palettes":                              {Summary: "Create a PaletteCreate node from an uploaded .gpl, .aco or hex text file, with optional name and format fields", Tag: "nodes", Multipart: "file", Response: addNodeResponse{}, Status: http.StatusCreated},
+	"GET /api/imagegraphs/{id}/nodes/{node_id}/swatches":               {Summary: "List the colors of a palette edit node in order, with whether each is enabled", Tag: "nodes", Response: listPaletteSwatchesResponse{}},
+	"POST /api/imagegraphs/{id}/nodes/{node_id}/swatches":              {Summary: "Add a color to a palette edit node, at index or at the end", Tag: "nodes", Request: addPaletteSwatchRequest{}, Response: paletteSwatchResponse{}, Status: http.StatusCreated},
+	"PUT /api/imagegraphs/{id}/nodes/{node_id}/swatches/order":         {Summary: "Reorder the colors of a palette edit node, listing each once", Tag: "nodes", Request: reorderPaletteSwatchesRequest{}},
+	"PATCH /api/imagegraphs/{id}/nodes/{node_id}/swatches/{color}":     {Summary: "Enable or disable a color of a palette edit node, given as rrggbb", Tag: "nodes", Request: setPaletteSwatchRequest{}, Response: paletteSwatchResponse{}},
+	"DELETE /api/imagegraphs/{id}/nodes/{node_id}/swatches/{color}":    {Summary: "Remove a color, given as rrggbb, from a palette edit node", Tag: "nodes"},
+	"PUT /api/imagegraphs/{id}/connectNodes":                           {Summary: "Connect a node output to a node input", Tag: "nodes", Request: connectionRequest{}},
+	"PUT /api/imagegraphs/{id}/disconnectNodes":                        {Summary: "Disconnect a node output from a node input", Tag: "nodes", Request: connectionRequest{}},
+	"GET /api/images/{image_id}":                                       {Summary: "Download an image", Tag: "images", ContentType: "image/png"},
+	"GET /api/images/{image_id}/metadata":                              {Summary: "Get the metadata stored with an image", Tag: "images", Response: imageMetadataResponse{}},
+	"GET /api/images/{image_id}/provenance":                            {Summary: "Trace an image back through the nodes and images it was generated from", Tag: "images", Response: imageProvenanceResponse{}},
+	"GET /api/imagegraphs/{id}/full":                                   {Summary: "Get an image graph with its layout, viewport and the node type schemas", Tag: "imagegraphs", Response: fullImageGraphResponse{}},
+	"GET /api/imagegraphs/{id}/layout":                                 {Summary: "Get node positions", Tag: "layout", Response: layoutResponse{}},
+	"PUT /api/imagegraphs/{id}/layout":                                 {Summary: "Set node positions", Tag: "layout", Request: updateLayoutRequest{}},
+	"POST /api/imagegraphs/{id}/layout/auto":                           {Summary: "Arrange the nodes in layers following their connections and save the positions", Tag: "layout", Response: layoutResponse{}},
+	"GET /api/imagegraphs/{id}/viewport":                               {Summary: "Get the saved viewport", Tag: "layout", Response: viewportResponse{}},
+	"PUT /api/imagegraphs/{id}/viewport":                               {Summary: "Save the viewport", Tag: "layout", Request: updateViewportRequest{}},
+	"GET /api/imagegraphs/{id}/viewport/bookmarks":                     {Summary: "List the named viewport bookmarks", Tag: "layout", Response: listViewportBookmarksResponse{}},
+	"GET /api/imagegraphs/{id}/viewport/bookmarks/{name}":              {Summary: "Get a viewport bookmark", Tag: "layout", Response: viewportBookmarkResponse{}},
+	"PUT /api/imagegraphs/{id}/viewport/bookmarks/{name}":              {Summary: "Add or replace a viewport bookmark", Tag: "layout", Request: saveViewportBookmarkRequest{}, Response: viewportBookmarkResponse{}},
+	"DELETE /api/imagegraphs/{id}/viewport/bookmarks/{name}":           {Summary: "Remove a viewport bookmark", Tag: "layout"},
+	"GET /api/imagegraphs/{id}/ws":                                     {Summary: "Subscribe to graph updates over a WebSocket", Tag: "imagegraphs", Query: websocketQuery, Status: http.StatusSwitchingProtocols},
+	"GET /api/ws":                                                      {Summary: "Subscribe to the updates of several graphs and the graph list over a WebSocket", Tag: "imagegraphs", Query: multiWebsocketQuery, Status: http.StatusSwitchingProtocols},
+	"GET /api/gallery":                                                 {Summary: "List public graphs", Tag: "gallery", Response: galleryIndexResponse{}},
+	"GET /api/gallery/{id}":                                            {Summary: "Get a public graph", Tag: "gallery", Response: galleryGraphResponse{}},
+	"GET /api/gallery/{id}/images/{image_id}":                          {Summary: "Download an image of a public graph", Tag: "gallery", ContentType: "image/png"},
+}
+
+var diagnosticsQuery = []openAPIQueryParam{
+	{Name: "stuck_after", Type: "string", Description: "How long a node can be generating before it's reported as stuck, as a Go duration (default 10m)"},
+}
+
+var nodeStatsQuery = []openAPIQueryParam{
+	{Name: "limit", Type: "integer", Description: "How many of the most recent runs to summarise and list (default 100)"},
+}
+
+var websocketQuery = []openAPIQueryParam{
+	{Name: "node_id", Type: "string", Description: "Only send updates about these nodes; repeated or comma-separated"},
+	{Name: "type", Type: "string", Description: "Only send these update types (node_update, layout_update); repeated or comma-separated"},
+	{Name: "last_event_id", Type: "string", Description: "ID of the last update seen; later ones are replayed. The Last-Event-ID header works too"},
+}
+
+var multiWebsocketQuery = []openAPIQueryParam{
+	{Name: "graph_id", Type: "string", Description: "Send updates about these graphs; repeated or comma-separated"},
+	{Name: "graph_list", Type: "boolean", Description: "Send graph_list_update messages when graphs are created"},
+	{Name: "node_id", Type: "string", Description: "Only send updates about these nodes; repeated or comma-separated"},
+	{Name: "type", Type: "string", Description: "Only send these graph update types (node_update, layout_update); repeated or comma-separated"},
+	{Name: "last_event_id", Type: "string", Description: "graph_id:id of the last update seen of a graph; later ones are replayed. Repeated or comma-separated"},
+}
+
+var imageGraphViewQuery = []openAPIQueryParam{
+	{Name: "view", Type: "string", Description: "full (default), or summary for only the ID, name, type, state and preview of each node"},
+}
+
+var listImageGraphsQuery = []openAPIQueryParam{
+	{Name: "tag", Type: "string", Description: "Only list graphs with this tag"},
+	{Name: "sort", Type: "string", Description: "name, created or updated (default created)"},
+	{Name: "order", Type: "string", Description: "asc or desc (default desc, asc for name)"},
+	{Name: "limit", Type: "integer", Description: "Page size, 1 to 500 (default 100)"},
+	{Name: "offset", Type: "integer", Description: "Graphs to skip"},
+}
+
+// openAPIEnums lists the values of types that marshal to one of a fixed set
+// of strings
+var openAPIEnums = map[reflect.Type]func() []string{
+	reflect.TypeFor[imagegraph.Role](): func() []string {
+		return mapValues(imagegraph.RoleMapper.FromWithDefault, imagegraph.RoleViewer, imagegraph.RoleEditor, imagegraph.RoleOwner)
+	},
+	reflect.TypeFor[imagegraph.NodeState](): func() []string {
+		return mapValues(imagegraph.NodeStateMapper.FromWithDefault, imagegraph.AllNodeStates()...)
+	},
+	reflect.TypeFor[imagegraph.FieldType](): func() []string {
+		return []string{
+			string(imagegraph.FieldTypeInt),
+			string(imagegraph.FieldTypeString),
+			string(imagegraph.FieldTypeFloat),
+			string(imagegraph.FieldTypeBool),
+			string(imagegraph.FieldTypeOption),
+			string(imagegraph.FieldTypeColor),
+		}
+	},
+	reflect.TypeFor[application.APIKeyScope](): func() []string {
+		var scopes []string
+		for _, scope := range application.AllAPIKeyScopes() {
+			scopes = append(scopes, string(scope))
+		}
+		return scopes
+	},
+}
+
+func mapValues[T any](from func(T, string) string, values ...T) []string {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = from(value, "unknown")
+	}
+	return strs
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// routeRecorder is a ServeMux that records the API patterns registered on
+// it, so the OpenAPI document covers exactly the routes being served
+type routeRecorder struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeRecorder) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	if strings.Contains(pattern, " /api/") {
+		m.patterns = append(m.patterns, pattern)
+	}
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+// buildOpenAPIDocument generates the OpenAPI 3 document for the routes
+func (s *HTTPServer) buildOpenAPIDocument(patterns []string) map[string]any {
+	schemas := &openAPISchemas{components: map[string]any{}}
+	addNodeConfigSchemas(schemas)
+
+	paths := map[string]map[string]any{}
+	for _, pattern := range patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+
+		op, ok := openAPIOperations[pattern]
+		if !ok {
+			op = openAPIOperation{Summary: pattern}
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = schemas.operation(pattern, path, op)
+	}
+
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Artwork API",
+			"version":     "1.0.0",
+			"description": "Build image graphs whose nodes transform images, and fetch their outputs.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerToken": map[string]any{"type": "http", "scheme": "bearer"},
+				"tokenCookie": map[string]any{"type": "apiKey", "in": "cookie", "name": TokenCookieName},
+				"apiKey":      map[string]any{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+			},
+		},
+	}
+
+	if s.authenticator != nil {
+		document["security"] = []map[string][]string{
+			{"bearerToken": {}},
+			{"tokenCookie": {}},
+			{"apiKey": {}},
+		}
+	}
+
+	return document
+}
+
+func (g *openAPISchemas) operation(pattern, path string, op openAPIOperation) map[string]any {
+	operation := map[string]any{
+		"operationId": operationID(pattern),
+		"summary":     op.Summary,
+	}
+	if op.Tag != "" {
+		operation["tags"] = []string{op.Tag}
+	}
+
+	var parameters []map[string]any
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		parameters = append(parameters, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, param := range op.Query {
+		parameter := map[string]any{
+			"name":   param.Name,
+			"in":     "query",
+			"schema": map[string]any{"type": param.Type},
+		}
+		if param.Description != "" {
+			parameter["description"] = param.Description
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if op.Request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": g.schemaFor(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+	if op.Multipart != "" {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"multipart/form-data": map[string]any{
+					"schema": map[string]any{
+						"type":       "object",
+						"required":   []string{op.Multipart},
+						"properties": map[string]any{op.Multipart: map[string]any{"type": "string", "format": "binary"}},
+					},
+				},
+			},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+		if op.Response == nil && op.ContentType == "" {
+			status = http.StatusNoContent
+		}
+	}
+
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success["content"] = map[string]any{
+			op.ContentType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+		}
+	case op.Response != nil:
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": g.schemaFor(reflect.TypeOf(op.Response))},
+		}
+	}
+
+	operation["responses"] = map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": g.schemaFor(reflect.TypeFor[errorResponse]())},
+			},
+		},
+	}
+
+	return operation
+}
+
+// operationID derives a stable camelCase ID from a route pattern, such as
+// getImagegraphsIdNodesByExternalIdExternalId
+func operationID(pattern string) string {
+	method, path, _ := strings.Cut(pattern, " ")
+
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+
+	words := strings.FieldsFunc(strings.TrimPrefix(path, "/api"), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+
+	return id.String()
+}
+
+// openAPISchemas generates schemas from Go types, collecting named structs
+// as components
+type openAPISchemas struct {
+	components map[string]any
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	marshalerType  = reflect.TypeFor[json.Marshaler]()
+)
+
+func (g *openAPISchemas) schemaFor(t reflect.Type) map[string]any {
+	if enum, ok := openAPIEnums[t]; ok {
+		return map[string]any{"type": "string", "enum": enum()}
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Kind() != reflect.Struct && t.Implements(marshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structRef adds a struct's schema to the components, returning a reference
+// to it
+func (g *openAPISchemas) structRef(t reflect.Type) map[string]any {
+	name := t.Name()
+	if name == "" {
+		return g.structSchema(t)
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+
+	if _, ok := g.components[name]; !ok {
+		// Reserve the name first so recursive types terminate
+		g.components[name] = map[string]any{}
+		g.components[name] = g.structSchema(t)
+	}
+
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (g *openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	g.addStructFields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *openAPISchemas) addStructFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened, as
+		// encoding/json does
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			g.addStructFields(field.Type, properties, required)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		// Configs are validated against the node type's schema
+		if name == "config" && field.Type == rawMessageType {
+			properties[name] = map[string]any{"$ref": "#/components/schemas/NodeConfig"}
+		} else {
+			properties[name] = g.schemaFor(field.Type)
+		}
+
+		optional := strings.Contains(options, "omitempty") ||
+			strings.Contains(options, "omitzero") ||
+			field.Type.Kind() == reflect.Pointer
+		if !optional {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// addNodeConfigSchemas adds a config schema for every node type, generated
+// from its Schema(), and a NodeConfig schema that accepts any of them
+func addNodeConfigSchemas(g *openAPISchemas) {
+	var refs []map[string]any
+
+	for _, info := range nodeTypeMetadata {
+		config := imagegraph.NewNodeConfig(info.nodeType)
+		if config == nil {
+			continue
+		}
+
+		properties := map[string]any{}
+		var required []string
+
+		for _, field := range config.Schema() {
+			property := map[string]any{}
+			switch field.Type {
+			case imagegraph.FieldTypeInt:
+				property["type"] = "integer"
+			case imagegraph.FieldTypeFloat:
+				property["type"] = "number"
+			case imagegraph.FieldTypeBool:
+				property["type"] = "boolean"
+			case imagegraph.FieldTypeColor:
+				property["type"] = "string"
+				property["pattern"] = "^#[0-9A-Fa-f]{6}$"
+			case imagegraph.FieldTypeOption:
+				property["type"] = "string"
+				property["enum"] = field.Options
+			default:
+				property["type"] = "string"
+			}
+			if field.Default != nil {
+				property["default"] = field.Default
+			}
+
+			properties[field.Name] = property
+			if field.Required {
+				required = append(required, field.Name)
+			}
+		}
+
+		schema := map[string]any{
+			"type":        "object",
+			"description": "Config for " + info.displayName + " (" + info.name + ") nodes",
+			"properties":  properties,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+
+		name := nodeConfigSchemaName(info.name)
+		g.components[name] = schema
+		refs = append(refs, map[string]any{"$ref": "#/components/schemas/" + name})
+	}
+
+	g.components["NodeConfig"] = map[string]any{
+		"description": "Node config; its shape depends on the node type",
+		"oneOf":       refs,
+	}
+}
+
+// nodeConfigSchemaName names the config schema of a node type, such as
+// PaletteExtractNodeConfig for palette_extract
+func nodeConfigSchemaName(nodeType string) string {
+	var name strings.Builder
+	for _, word := range strings.Split(nodeType, "_") {
+		if word != "" {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	name.WriteString("NodeConfig")
+	return name.String()
+}
+
+func (s *HTTPServer) handleGetOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, s.openAPIDocument)
+}
+
+func (s *HTTPServer) handleGetAPIDocs(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, swaggerUI, "swagger/index.html")
+}
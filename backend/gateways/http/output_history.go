@@ -0,0 +1,240 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// handleGetOutputHistory lists the current image of a node output and the
+// images it replaced
+func (s *HTTPServer) handleGetOutputHistory(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get output history"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	output, exists := node.Outputs[imagegraph.OutputName(r.PathValue("output_name"))]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "output not found"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapOutputHistoryToResponse(output))
+}
+
+// handlePromoteOutputVariant makes an image from a node output's history its
+// current image again. Downstream nodes regenerate from it.
+func (s *HTTPServer) handlePromoteOutputVariant(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	imageID, err := imagegraph.ParseImageID(r.PathValue("image_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image ID"})
+		return
+	}
+
+	outputName := imagegraph.OutputName(r.PathValue("output_name"))
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to promote output image"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	if !node.HasOutput(outputName) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "output not found"})
+		return
+	}
+
+	if node.State.Get() != imagegraph.Generated || node.Pinned {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "only generated nodes that aren't pinned can promote an output image"})
+		return
+	}
+
+	command := application.NewPromoteImageGraphNodeOutputVariantCommand(
+		imageGraphID,
+		nodeID,
+		outputName,
+		imageID,
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, imagegraph.ErrOutputVariantNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image not found in output history"})
+			return
+		}
+		s.logger.Error("failed to handle PromoteImageGraphNodeOutputVariantCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to promote output image"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleClearNodeOutputImage unsets the current image of a node output. The
+// image moves into the output's history, and downstream nodes lose the inputs
+// connected to it.
+func (s *HTTPServer) handleClearNodeOutputImage(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	outputName := imagegraph.OutputName(r.PathValue("output_name"))
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to clear output image"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	if !node.HasOutput(outputName) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "output not found"})
+		return
+	}
+
+	command := application.NewUnsetImageGraphNodeOutputImageCommand(
+		imageGraphID,
+		nodeID,
+		outputName,
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, imagegraph.ErrNodePinned) {
+			respondJSON(w, http.StatusConflict, errorResponse{Error: "pinned nodes keep their output images"})
+			return
+		}
+		s.logger.Error("failed to handle UnsetImageGraphNodeOutputImageCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to clear output image"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetNodeOutputImage serves the current image of a node output, so
+// clients can keep showing an output without looking up its image ID after
+// every regeneration
+func (s *HTTPServer) handleGetNodeOutputImage(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get output image"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	output, exists := node.Outputs[imagegraph.OutputName(r.PathValue("output_name"))]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "output not found"})
+		return
+	}
+
+	if !output.HasImage() {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "output has no image yet"})
+		return
+	}
+
+	s.serveImage(w, output.ImageID)
+}
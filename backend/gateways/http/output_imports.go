@@ -0,0 +1,189 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+)
+
+// handleImportOutputImage sets a node output to a copy of an image of
+// another node, in this graph or another the caller can view, so one
+// pipeline's result can feed another without downloading and uploading it.
+// The source is a node output's current image, or any image the source graph
+// refers to. The image is copied so that either graph can drop it without
+// breaking the other.
+func (s *HTTPServer) handleImportOutputImage(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	outputName := imagegraph.OutputName(r.PathValue("output_name"))
+
+	var req importOutputImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	sourceGraphID := imageGraphID
+	if req.ImageGraphID != "" {
+		if sourceGraphID, err = imagegraph.ParseImageGraphID(req.ImageGraphID); err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid source image graph ID"})
+			return
+		}
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to import image"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	if !node.HasOutput(outputName) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "output not found"})
+		return
+	}
+
+	sourceImageID, ok := s.importSourceImage(w, r, sourceGraphID, req)
+	if !ok {
+		return
+	}
+
+	imageData, err := s.imageStorage.Get(sourceImageID)
+	if err != nil {
+		s.logger.Error("failed to get image from storage", "error", err, "image_id", sourceImageID)
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image not found"})
+		return
+	}
+
+	upload, err := s.inspectUpload(imageData)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	imageID := imagegraph.MustNewImageID()
+
+	if err := filestorage.CopyImage(s.imageStorage, sourceImageID, imageID); err != nil {
+		s.logger.Error("failed to copy image", "error", err, "image_id", sourceImageID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to import image"})
+		return
+	}
+
+	command := application.NewSetImageGraphNodeOutputImageCommand(
+		imageGraphID,
+		nodeID,
+		outputName,
+		imageID,
+		0, // allow command handler to resolve to current node version
+	)
+	command.Upload = &upload
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to handle SetImageGraphNodeOutputImageCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to set node output image"})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, uploadImageResponse{ImageID: imageID.String()})
+}
+
+// importSourceImage finds the image an import copies, responding with an
+// error if the source graph isn't visible to the caller or doesn't have it
+func (s *HTTPServer) importSourceImage(
+	w http.ResponseWriter,
+	r *http.Request,
+	sourceGraphID imagegraph.ImageGraphID,
+	req importOutputImageRequest,
+) (imagegraph.ImageID, bool) {
+	if (req.ImageID == "") == (req.NodeID == "") {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "either node_id and output_name or image_id is required"})
+		return imagegraph.ImageID{}, false
+	}
+
+	source, err := s.imageGraphViews.Get(r.Context(), sourceGraphID)
+	if err != nil && !errors.Is(err, application.ErrImageGraphNotFound) {
+		s.logger.Error("failed to get image graph", "error", err, "id", sourceGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to import image"})
+		return imagegraph.ImageID{}, false
+	}
+
+	// Graphs the caller can't view are reported as missing, as they are
+	// by the graph endpoints
+	user, authenticated := application.UserFromContext(r.Context())
+	if err != nil || (authenticated && !user.CanAccess(source)) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "source image graph not found"})
+		return imagegraph.ImageID{}, false
+	}
+
+	if req.ImageID != "" {
+		imageID, err := imagegraph.ParseImageID(req.ImageID)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image ID"})
+			return imagegraph.ImageID{}, false
+		}
+		if !slices.Contains(source.ImageIDs(), imageID) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "source image graph has no such image"})
+			return imagegraph.ImageID{}, false
+		}
+		return imageID, true
+	}
+
+	sourceNodeID, err := imagegraph.ParseNodeID(req.NodeID)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid source node ID"})
+		return imagegraph.ImageID{}, false
+	}
+
+	sourceNode, exists := source.Nodes[sourceNodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "source node not found"})
+		return imagegraph.ImageID{}, false
+	}
+
+	sourceOutput := imagegraph.OutputName(req.OutputName)
+	if !sourceNode.HasOutput(sourceOutput) {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "source output not found"})
+		return imagegraph.ImageID{}, false
+	}
+
+	imageID, err := sourceNode.Outputs.GetImage(sourceOutput)
+	if err != nil || imageID.IsNil() {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "source output has no image yet"})
+		return imagegraph.ImageID{}, false
+	}
+
+	return imageID, true
+}
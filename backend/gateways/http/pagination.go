@@ -0,0 +1,78 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+const (
+	// defaultListLimit is the page size of graph lists that don't ask for one
+	defaultListLimit = 100
+
+	// maxListLimit is the largest page size a graph list may ask for
+	maxListLimit = 500
+)
+
+// parseListImageGraphsOptions reads the tag, sort, order, limit and offset
+// query parameters of a graph list. Graphs are listed newest first unless
+// asked otherwise; name order defaults to ascending.
+func parseListImageGraphsOptions(r *http.Request) (application.ListImageGraphsOptions, error) {
+	query := r.URL.Query()
+
+	opts := application.ListImageGraphsOptions{
+		Sort:  application.SortImageGraphsByCreated,
+		Limit: defaultListLimit,
+	}
+
+	if tag := query.Get("tag"); tag != "" {
+		tag, err := imagegraph.NormalizeTag(tag)
+		if err != nil {
+			return opts, err
+		}
+		opts.Tag = tag
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		switch sort := application.ImageGraphSort(sort); sort {
+		case application.SortImageGraphsByName,
+			application.SortImageGraphsByCreated,
+			application.SortImageGraphsByUpdated:
+			opts.Sort = sort
+		default:
+			return opts, fmt.Errorf("sort must be one of name, created or updated")
+		}
+	}
+
+	switch order := query.Get("order"); order {
+	case "":
+		opts.Descending = opts.Sort != application.SortImageGraphsByName
+	case "asc":
+		opts.Descending = false
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxListLimit {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		opts.Limit = n
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("offset must be a non-negative integer")
+		}
+		opts.Offset = n
+	}
+
+	return opts, nil
+}
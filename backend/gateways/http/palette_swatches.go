@@ -0,0 +1,231 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/dorky/messages"
+)
+
+// handleListPaletteSwatches lists the colors of a PaletteEdit node's
+// palette in order, with whether each is enabled
+func (s *HTTPServer) handleListPaletteSwatches(w http.ResponseWriter, r *http.Request) {
+	_, _, node, ok := s.getPaletteEditNode(w, r, "failed to list swatches")
+	if !ok {
+		return
+	}
+
+	swatches, err := node.Config.(*imagegraph.NodeConfigPaletteEdit).Swatches()
+	if err != nil {
+		s.logger.Error("failed to parse palette edit colors", "error", err, "node_id", node.ID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list swatches"})
+		return
+	}
+
+	resp := listPaletteSwatchesResponse{Swatches: make([]paletteSwatchResponse, len(swatches))}
+	for i, swatch := range swatches {
+		resp.Swatches[i] = paletteSwatchResponse{Color: swatch.Color, Enabled: swatch.Enabled}
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// handleAddPaletteSwatch adds a color to a PaletteEdit node's palette
+func (s *HTTPServer) handleAddPaletteSwatch(w http.ResponseWriter, r *http.Request) {
+	var req addPaletteSwatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	color, err := imagegraph.ParseSwatchColor(req.Color)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	imageGraphID, nodeID, _, ok := s.getPaletteEditNode(w, r, "failed to add swatch")
+	if !ok {
+		return
+	}
+
+	enabled := req.Enabled == nil || *req.Enabled
+	index := -1
+	if req.Index != nil {
+		index = *req.Index
+	}
+
+	command := application.NewAddImageGraphPaletteSwatchCommand(imageGraphID, nodeID, color, enabled, index)
+	if !s.handleSwatchCommand(w, r, command, "failed to add swatch") {
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, paletteSwatchResponse{Color: color, Enabled: enabled})
+}
+
+// handleSetPaletteSwatch enables or disables a color of a PaletteEdit
+// node's palette
+func (s *HTTPServer) handleSetPaletteSwatch(w http.ResponseWriter, r *http.Request) {
+	var req setPaletteSwatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+	if req.Enabled == nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "enabled is required"})
+		return
+	}
+
+	color, err := imagegraph.ParseSwatchColor(r.PathValue("color"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	imageGraphID, nodeID, _, ok := s.getPaletteEditNode(w, r, "failed to set swatch")
+	if !ok {
+		return
+	}
+
+	command := application.NewSetImageGraphPaletteSwatchEnabledCommand(imageGraphID, nodeID, color, *req.Enabled)
+	if !s.handleSwatchCommand(w, r, command, "failed to set swatch") {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, paletteSwatchResponse{Color: color, Enabled: *req.Enabled})
+}
+
+// handleRemovePaletteSwatch removes a color from a PaletteEdit node's
+// palette
+func (s *HTTPServer) handleRemovePaletteSwatch(w http.ResponseWriter, r *http.Request) {
+	color, err := imagegraph.ParseSwatchColor(r.PathValue("color"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	imageGraphID, nodeID, _, ok := s.getPaletteEditNode(w, r, "failed to remove swatch")
+	if !ok {
+		return
+	}
+
+	command := application.NewRemoveImageGraphPaletteSwatchCommand(imageGraphID, nodeID, color)
+	if !s.handleSwatchCommand(w, r, command, "failed to remove swatch") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReorderPaletteSwatches puts a PaletteEdit node's palette in the
+// order of the request's colors
+func (s *HTTPServer) handleReorderPaletteSwatches(w http.ResponseWriter, r *http.Request) {
+	var req reorderPaletteSwatchesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	colors := make([]string, len(req.Colors))
+	for i, c := range req.Colors {
+		color, err := imagegraph.ParseSwatchColor(c)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		colors[i] = color
+	}
+
+	imageGraphID, nodeID, _, ok := s.getPaletteEditNode(w, r, "failed to reorder swatches")
+	if !ok {
+		return
+	}
+
+	command := application.NewReorderImageGraphPaletteSwatchesCommand(imageGraphID, nodeID, colors)
+	if !s.handleSwatchCommand(w, r, command, "failed to reorder swatches") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPaletteEditNode reads the node of the request's path from the graph,
+// responding with an error unless it is a PaletteEdit node
+func (s *HTTPServer) getPaletteEditNode(
+	w http.ResponseWriter,
+	r *http.Request,
+	message string,
+) (imagegraph.ImageGraphID, imagegraph.NodeID, *imagegraph.Node, bool) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return imageGraphID, imagegraph.NodeID{}, nil, false
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return imageGraphID, nodeID, nil, false
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return imageGraphID, nodeID, nil, false
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: message})
+		return imageGraphID, nodeID, nil, false
+	}
+
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return imageGraphID, nodeID, nil, false
+	}
+	if _, ok := node.Config.(*imagegraph.NodeConfigPaletteEdit); !ok {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "node is not a palette edit node"})
+		return imageGraphID, nodeID, nil, false
+	}
+
+	return imageGraphID, nodeID, node, true
+}
+
+// handleSwatchCommand runs a swatch command, responding with an error and
+// returning false if it fails
+func (s *HTTPServer) handleSwatchCommand(
+	w http.ResponseWriter,
+	r *http.Request,
+	command messages.Command,
+	message string,
+) bool {
+	err := s.messageBus.HandleCommand(r.Context(), command)
+
+	switch {
+	case err == nil:
+		return true
+	case s.respondGraphBusy(w, err):
+	case errors.Is(err, application.ErrImageGraphNotFound):
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+	case errors.Is(err, imagegraph.ErrSwatchNotFound):
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "swatch not found"})
+	case errors.Is(err, imagegraph.ErrSwatchExists):
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "palette already has the color"})
+	case errors.Is(err, imagegraph.ErrInvalidSwatchOrder):
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "colors must list each swatch color once"})
+	case errors.Is(err, imagegraph.ErrNotPaletteEdit):
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "node is not a palette edit node"})
+	default:
+		s.logger.Error("failed to edit palette swatches", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: message})
+	}
+
+	return false
+}
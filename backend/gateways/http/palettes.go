@@ -0,0 +1,207 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/palettefile"
+)
+
+// maxPaletteFileSize caps uploaded palette files, well above what
+// palettefile.MaxColors colors take in any format
+const maxPaletteFileSize = 1024 * 1024 // 1 MB
+
+// handleExportPalette downloads the palette a node outputs as a GIMP .gpl,
+// Adobe .aco or hex text file, picked by the format query parameter and gpl
+// by default
+func (s *HTTPServer) handleExportPalette(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	format := palettefile.FormatGPL
+	if name := r.URL.Query().Get("format"); name != "" {
+		if format, err = palettefile.ParseFormat(name); err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to export palette"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	outputName, ok := paletteOutput(node)
+	if !ok {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "node has no palette output"})
+		return
+	}
+
+	imageID, err := node.Outputs.GetImage(outputName)
+	if err != nil || imageID.IsNil() {
+		respondJSON(w, http.StatusConflict, errorResponse{Error: "node has no palette yet"})
+		return
+	}
+
+	imageData, err := s.imageStorage.Get(imageID)
+	if err != nil {
+		s.logger.Error("failed to get image from storage", "error", err, "image_id", imageID)
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image not found"})
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		s.logger.Error("failed to decode palette image", "error", err, "image_id", imageID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to export palette"})
+		return
+	}
+
+	palette := palettefile.Palette{Name: node.Name, Colors: palettefile.ColorsFromImage(img)}
+
+	var body bytes.Buffer
+	if err := palettefile.Encode(&body, format, palette); err != nil {
+		s.logger.Error("failed to encode palette", "error", err, "image_id", imageID, "format", format)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to export palette"})
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": archiveFileName(node.Name, "palette") + "." + string(format),
+	}))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// paletteOutput returns the node's first output that holds a palette
+func paletteOutput(node *imagegraph.Node) (imagegraph.OutputName, bool) {
+	def := imagegraph.NodeTypeDefs[node.Type]
+	for _, name := range def.Outputs {
+		if def.OutputKind(name) == imagegraph.ImageKindPalette {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// handleImportPalette creates a PaletteCreate node from an uploaded GIMP
+// .gpl, Adobe .aco or hex text file in the "file" form field. The format is
+// taken from the format field, or detected from the file. The node is named
+// after the name field, the palette's own name or the file name, in that
+// order.
+func (s *HTTPServer) handleImportPalette(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPaletteFileSize+64*1024)
+	if err := r.ParseMultipartForm(maxPaletteFileSize); err != nil {
+		s.logger.Error("failed to parse multipart form", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid multipart form data"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File["file"]
+	if len(headers) != 1 {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "a single palette file is required"})
+		return
+	}
+
+	data, err := readFormFile(headers[0])
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	format := palettefile.DetectFormat(headers[0].Filename, data)
+	if name := r.FormValue("format"); name != "" {
+		if format, err = palettefile.ParseFormat(name); err != nil {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	palette, err := palettefile.Decode(data, format)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = palette.Name
+	}
+	if name == "" {
+		name = strings.TrimSuffix(headers[0].Filename, path.Ext(headers[0].Filename))
+	}
+
+	config := imagegraph.NewNodeConfigPaletteCreate()
+	config.Colors = strings.Join(palette.Hex(), ", ")
+
+	nodeID, err := s.idGenerator.NewNodeID()
+	if err != nil {
+		s.logger.Error("failed to generate node ID", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to import palette"})
+		return
+	}
+
+	command := application.NewAddImageGraphNodeCommand(
+		imageGraphID,
+		nodeID,
+		imagegraph.NodeTypePaletteCreate,
+		name,
+		config,
+		"",
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, application.ErrGraphLimitExceeded) {
+			respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "image graph node limit reached"})
+			return
+		}
+		s.logger.Error("failed to handle AddImageGraphNodeCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to import palette"})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, addNodeResponse{ID: nodeID.String()})
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// How many runs the pipeline runs endpoint lists
+const (
+	defaultPipelineRuns = 20
+	maxPipelineRuns     = 200
+)
+
+// handleListPipelineRuns lists the recent runs of a graph's pipeline: the
+// input image that triggered each, the nodes it regenerated and the final
+// images it produced
+func (s *HTTPServer) handleListPipelineRuns(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	limit := defaultPipelineRuns
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPipelineRuns {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", maxPipelineRuns)})
+			return
+		}
+	}
+
+	if _, err := s.imageGraphViews.Get(r.Context(), imageGraphID); err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list runs"})
+		return
+	}
+
+	runs, err := s.pipelineRuns.ListByImageGraph(r.Context(), imageGraphID, limit)
+	if err != nil {
+		s.logger.Error("failed to list pipeline runs", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list runs"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapPipelineRunsToResponse(runs))
+}
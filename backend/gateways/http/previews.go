@@ -0,0 +1,67 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// handleGetNodePreview serves the preview of a node. Nodes generated in
+// performance mode have no preview, theirs is generated from the node's
+// primary output the first time it is asked for.
+func (s *HTTPServer) handleGetNodePreview(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get node preview"})
+		return
+	}
+
+	node, exists := ig.Nodes[nodeID]
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	previewID := node.Preview
+
+	if previewID.IsNil() {
+		if s.previews == nil {
+			respondJSON(w, http.StatusNotImplemented, errorResponse{Error: "preview generation is not enabled"})
+			return
+		}
+
+		outputImageID, err := node.Outputs.GetImage(imagegraph.NodeTypeDefs[node.Type].PrimaryOutput())
+		if err != nil || outputImageID.IsNil() {
+			respondJSON(w, http.StatusConflict, errorResponse{Error: "node has no output image yet"})
+			return
+		}
+
+		previewID, err = s.previews.GeneratePreview(r.Context(), imageGraphID, nodeID, node.ImageVersion, outputImageID)
+		if err != nil {
+			s.logger.Error("failed to generate node preview", "error", err, "id", imageGraphID, "node_id", nodeID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get node preview"})
+			return
+		}
+	}
+
+	s.serveImage(w, previewID)
+}
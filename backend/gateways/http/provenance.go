@@ -0,0 +1,141 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+)
+
+// How many images a provenance chain walks back through at most
+const maxProvenanceSteps = 500
+
+// handleGetImageProvenance walks back from an image through the recorded
+// provenance of the images it was generated from, listing each image once,
+// nearest first. Images without provenance were uploaded or generated
+// before provenance was recorded and end the chain.
+func (s *HTTPServer) handleGetImageProvenance(w http.ResponseWriter, r *http.Request) {
+	imageID, err := imagegraph.ParseImageID(r.PathValue("image_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image ID"})
+		return
+	}
+
+	provenanceStorage, ok := s.imageStorage.(filestorage.ImageProvenanceStorage)
+	if !ok {
+		respondJSON(w, http.StatusNotImplemented, errorResponse{Error: "image provenance is not recorded"})
+		return
+	}
+
+	exists, err := s.imageStorage.Exists(imageID)
+	if err != nil {
+		s.logger.Error("failed to check image in storage", "error", err, "image_id", imageID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image provenance"})
+		return
+	}
+	if !exists {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "image not found"})
+		return
+	}
+
+	response := imageProvenanceResponse{ImageID: imageID.String(), Steps: []provenanceStepResponse{}}
+	graphs := make(map[imagegraph.ImageGraphID]*imagegraph.ImageGraph)
+	visited := make(map[imagegraph.ImageID]bool)
+	pending := []imagegraph.ImageID{imageID}
+
+	for len(pending) > 0 {
+		stepImageID := pending[0]
+		pending = pending[1:]
+
+		if visited[stepImageID] {
+			continue
+		}
+		if len(response.Steps) == maxProvenanceSteps {
+			response.Truncated = true
+			break
+		}
+		visited[stepImageID] = true
+
+		provenance, err := provenanceStorage.GetProvenance(stepImageID)
+		if err != nil {
+			s.logger.Error("failed to get image provenance from storage", "error", err, "image_id", stepImageID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image provenance"})
+			return
+		}
+
+		if provenance == nil {
+			response.Steps = append(response.Steps, s.sourceProvenanceStep(stepImageID))
+			continue
+		}
+
+		ig, visible, err := s.provenanceGraph(r, graphs, provenance.ImageGraphID)
+		if err != nil {
+			s.logger.Error("failed to get image graph", "error", err, "id", provenance.ImageGraphID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get image provenance"})
+			return
+		}
+
+		// Graphs the caller can't view aren't revealed, nor are the images
+		// they were generated from
+		if !visible {
+			response.Steps = append(response.Steps, provenanceStepResponse{ImageID: stepImageID.String(), Hidden: true})
+			continue
+		}
+
+		response.Steps = append(response.Steps, mapProvenanceToResponse(stepImageID, *provenance, ig))
+		for _, input := range provenance.Inputs {
+			pending = append(pending, input.ImageID)
+		}
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// sourceProvenanceStep describes an image without provenance by the name of
+// the file it was uploaded from, if known
+func (s *HTTPServer) sourceProvenanceStep(imageID imagegraph.ImageID) provenanceStepResponse {
+	step := provenanceStepResponse{ImageID: imageID.String()}
+
+	if metadataStorage, ok := s.imageStorage.(filestorage.ImageMetadataStorage); ok {
+		if metadata, err := metadataStorage.GetMetadata(imageID); err == nil {
+			step.Filename = metadata.Filename
+		}
+	}
+
+	return step
+}
+
+// provenanceGraph looks up the graph an image was generated in, once per
+// request, reporting whether the caller may see how the image was generated.
+// The graph is nil if it has since been removed, in which case only
+// unauthenticated callers may.
+func (s *HTTPServer) provenanceGraph(
+	r *http.Request,
+	graphs map[imagegraph.ImageGraphID]*imagegraph.ImageGraph,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	*imagegraph.ImageGraph,
+	bool,
+	error,
+) {
+	ig, looked := graphs[imageGraphID]
+	if !looked {
+		var err error
+		ig, err = s.imageGraphViews.Get(r.Context(), imageGraphID)
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			ig = nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		graphs[imageGraphID] = ig
+	}
+
+	_, authenticated := application.UserFromContext(r.Context())
+	if ig == nil {
+		return nil, !authenticated, nil
+	}
+
+	return ig, canAccess(r, ig), nil
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/cron"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// How many runs the scheduled runs endpoint lists
+const (
+	defaultScheduledRuns = 20
+	maxScheduledRuns     = 200
+)
+
+func (s *HTTPServer) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	schedule, err := s.schedules.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrScheduleNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "schedule not found"})
+			return
+		}
+		s.logger.Error("failed to get schedule", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get schedule"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapScheduleToResponse(schedule, time.Now().UTC()))
+}
+
+// handleSetSchedule sets the cron expression a graph is regenerated on,
+// enabling or disabling the schedule. Omitting enabled keeps a schedule's
+// current setting, and enables a new one.
+func (s *HTTPServer) handleSetSchedule(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req setScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	parsed, err := cron.Parse(req.Cron)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if _, err := s.imageGraphViews.Get(r.Context(), imageGraphID); err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to set schedule"})
+		return
+	}
+
+	now := time.Now().UTC()
+
+	schedule, err := s.schedules.Get(r.Context(), imageGraphID)
+	if errors.Is(err, application.ErrScheduleNotFound) {
+		schedule = application.Schedule{ImageGraphID: imageGraphID, Enabled: true, CreatedAt: now}
+	} else if err != nil {
+		s.logger.Error("failed to get schedule", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to set schedule"})
+		return
+	}
+
+	schedule.Cron = parsed.String()
+	schedule.UpdatedAt = now
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+
+	if err := s.schedules.Set(r.Context(), schedule); err != nil {
+		s.logger.Error("failed to set schedule", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to set schedule"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapScheduleToResponse(schedule, now))
+}
+
+func (s *HTTPServer) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	if err := s.schedules.Delete(r.Context(), imageGraphID); err != nil {
+		if errors.Is(err, application.ErrScheduleNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "schedule not found"})
+			return
+		}
+		s.logger.Error("failed to delete schedule", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to remove schedule"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListScheduledRuns lists the recent times a graph's schedule came
+// due, newest first, with whether each regenerated the graph
+func (s *HTTPServer) handleListScheduledRuns(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	limit := defaultScheduledRuns
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxScheduledRuns {
+			respondJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("limit must be between 1 and %d", maxScheduledRuns)})
+			return
+		}
+	}
+
+	runs, err := s.schedules.ListRuns(r.Context(), imageGraphID, limit)
+	if err != nil {
+		s.logger.Error("failed to list scheduled runs", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list runs"})
+		return
+	}
+
+	response := listScheduledRunsResponse{Runs: make([]scheduledRunResponse, 0, len(runs))}
+	for _, run := range runs {
+		response.Runs = append(response.Runs, scheduledRunResponse{
+			ScheduledFor: run.ScheduledFor,
+			StartedAt:    run.StartedAt,
+			Status:       run.Status,
+			Error:        run.Error,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
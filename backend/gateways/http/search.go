@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// maxSearchHits caps the number of hits returned by a search
+const maxSearchHits = 50
+
+// handleSearch finds graphs and nodes whose names or tags contain the query,
+// ignoring case. Prefix matches are listed first, then graph hits before
+// node hits.
+func (s *HTTPServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query, err := imagegraph.NormalizeSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	imageGraphs, err := s.imageGraphViews.Search(r.Context(), query)
+	if err != nil {
+		s.logger.Error("failed to search image graphs", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to search image graphs"})
+		return
+	}
+
+	hits := []searchHit{}
+
+	for _, ig := range imageGraphs {
+		if !canAccess(r, ig) {
+			continue
+		}
+
+		for _, match := range ig.Search(query) {
+			hit := searchHit{
+				Type:      "graph",
+				Field:     string(match.Field),
+				Value:     match.Value,
+				Prefix:    match.Prefix,
+				GraphID:   ig.ID.String(),
+				GraphName: ig.Name,
+			}
+
+			if node, ok := ig.Nodes.Get(match.NodeID); ok {
+				hit.Type = "node"
+				hit.NodeID = node.ID.String()
+				hit.NodeName = node.Name
+			}
+
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Prefix != hits[j].Prefix {
+			return hits[i].Prefix
+		}
+		return hits[i].Type == "graph" && hits[j].Type != "graph"
+	})
+
+	if len(hits) > maxSearchHits {
+		hits = hits[:maxSearchHits]
+	}
+
+	respondJSON(w, http.StatusOK, searchResponse{Hits: hits})
+}
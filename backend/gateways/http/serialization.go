@@ -3,7 +3,12 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/cron"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/domain/ui"
 )
@@ -11,13 +16,69 @@ import (
 // Request types
 
 type createImageGraphRequest struct {
-	Name string `json:"name"`
+	Name       string `json:"name"`
+	ExternalID string `json:"external_id,omitempty"`
+}
+
+type createAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit,omitempty"`
+}
+
+type createWebhookRequest struct {
+	URL string `json:"url"`
+
+	// Secret signs deliveries; one is generated when omitted
+	Secret string `json:"secret,omitempty"`
+}
+
+// createGraphLinkRequest links an Input node to a node output of another
+// graph
+type createGraphLinkRequest struct {
+	NodeID             string `json:"node_id"`
+	SourceImageGraphID string `json:"source_image_graph_id"`
+	SourceNodeID       string `json:"source_node_id"`
+	SourceOutputName   string `json:"source_output_name"`
+}
+
+// setScheduleRequest sets the cron expression a graph is regenerated on
+type setScheduleRequest struct {
+	Cron    string `json:"cron"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+type shareImageGraphRequest struct {
+	Role string `json:"role"`
+}
+
+type setImageGraphPublicRequest struct {
+	Public *bool `json:"public"`
+}
+
+type setColorManagementRequest struct {
+	ColorManagement string `json:"color_management"`
+}
+
+// setParametersRequest replaces all of a graph's parameters
+type setParametersRequest struct {
+	Parameters map[string]float64 `json:"parameters"`
+}
+
+// setSeedRequest sets a graph's seed, or clears it when null
+type setSeedRequest struct {
+	Seed *int `json:"seed"`
+}
+
+type setPerformanceModeRequest struct {
+	PerformanceMode *bool `json:"performance_mode"`
 }
 
 type addNodeRequest struct {
-	Name   string          `json:"name"`
-	Type   string          `json:"type"`
-	Config json.RawMessage `json:"config"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Config     json.RawMessage `json:"config"`
+	ExternalID string          `json:"external_id,omitempty"`
 }
 
 type connectionRequest struct {
@@ -25,32 +86,58 @@ type connectionRequest struct {
 	OutputName string `json:"output_name"`
 	ToNodeID   string `json:"to_node_id"`
 	InputName  string `json:"input_name"`
+	Transform  string `json:"transform,omitempty"`
 }
 
+// updateNodeRequest updates the fields that are set. Expressions replaces
+// the node's config expressions, and an empty object removes them.
 type updateNodeRequest struct {
-	Name   *string         `json:"name,omitempty"`
+	Name           *string           `json:"name,omitempty"`
+	Description    *string           `json:"description,omitempty"`
+	Config         json.RawMessage   `json:"config,omitempty"`
+	Bypassed       *bool             `json:"bypassed,omitempty"`
+	Pinned         *bool             `json:"pinned,omitempty"`
+	Implementation *int              `json:"implementation,omitempty"`
+	Expressions    map[string]string `json:"expressions,omitempty"`
+}
+
+type validateNodeConfigRequest struct {
 	Config json.RawMessage `json:"config,omitempty"`
 }
 
 type updateLayoutRequest struct {
-	NodePositions []nodePosition `json:"node_positions"`
+	NodePositions []nodeLayout `json:"node_positions"`
 }
 
-// toDomain converts the request to domain types
-func (r *updateLayoutRequest) toDomain() ([]ui.NodePosition, error) {
-	nodePositions := make([]ui.NodePosition, 0, len(r.NodePositions))
-	for _, pos := range r.NodePositions {
-		nodeID, err := imagegraph.ParseNodeID(pos.NodeID)
+// toDomain converts the request to domain types, returning a
+// *ui.ValidationError listing the node IDs that can't be parsed
+func (r *updateLayoutRequest) toDomain() ([]ui.NodeLayout, error) {
+	nodeLayouts := make([]ui.NodeLayout, 0, len(r.NodePositions))
+	invalid := &ui.ValidationError{}
+	for i, nl := range r.NodePositions {
+		nodeID, err := imagegraph.ParseNodeID(nl.NodeID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid node ID: %s", pos.NodeID)
+			invalid.Fields = append(invalid.Fields, ui.FieldError{
+				Field:   fmt.Sprintf("node_positions[%d].node_id", i),
+				Message: fmt.Sprintf("invalid node ID %q", nl.NodeID),
+			})
+			continue
 		}
-		nodePositions = append(nodePositions, ui.NodePosition{
-			NodeID: nodeID,
-			X:      pos.X,
-			Y:      pos.Y,
+		nodeLayouts = append(nodeLayouts, ui.NodeLayout{
+			NodeID:    nodeID,
+			X:         nl.X,
+			Y:         nl.Y,
+			Width:     nl.Width,
+			Height:    nl.Height,
+			Collapsed: nl.Collapsed,
+			Color:     nl.Color,
+			ZOrder:    nl.ZOrder,
 		})
 	}
-	return nodePositions, nil
+	if len(invalid.Fields) > 0 {
+		return nil, invalid
+	}
+	return nodeLayouts, nil
 }
 
 type updateViewportRequest struct {
@@ -59,6 +146,13 @@ type updateViewportRequest struct {
 	PanY float64 `json:"pan_y"`
 }
 
+type saveViewportBookmarkRequest struct {
+	Zoom    float64 `json:"zoom"`
+	PanX    float64 `json:"pan_x"`
+	PanY    float64 `json:"pan_y"`
+	Default bool    `json:"default"`
+}
+
 // Response types
 
 type createImageGraphResponse struct {
@@ -73,74 +167,721 @@ type uploadImageResponse struct {
 	ImageID string `json:"image_id"`
 }
 
+// importOutputImageRequest names the image an output is set to a copy of:
+// the current image of a node output, or an image the graph refers to. The
+// graph is the one being imported into unless image_graph_id is set.
+type importOutputImageRequest struct {
+	ImageGraphID string `json:"image_graph_id,omitempty"`
+	NodeID       string `json:"node_id,omitempty"`
+	OutputName   string `json:"output_name,omitempty"`
+	ImageID      string `json:"image_id,omitempty"`
+}
+
+type uploadInputsResponse struct {
+	NodeIDs []string `json:"node_ids"`
+}
+
+type validateNodeConfigResponse struct {
+	Valid    bool     `json:"valid"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings"`
+}
+
+// validateImageGraphResponse lists the connections of a graph that static
+// validation found problems with. The graph is valid if none have errors.
+type validateImageGraphResponse struct {
+	Valid       bool                           `json:"valid"`
+	Connections []connectionValidationResponse `json:"connections"`
+}
+
+// diagnosticsResponse lists the problems that keep a graph from being
+// complete, errors first
+type diagnosticsResponse struct {
+	Errors      int                  `json:"errors"`
+	Warnings    int                  `json:"warnings"`
+	Diagnostics []diagnosticResponse `json:"diagnostics"`
+}
+
+// estimateResponse is what regenerating a graph is expected to take.
+// DurationMs totals the nodes whose duration could be estimated, and
+// PeakMemoryBytes is the most any one node needs.
+type estimateResponse struct {
+	DurationMs      float64                `json:"duration_ms"`
+	PeakMemoryBytes int64                  `json:"peak_memory_bytes"`
+	Nodes           []nodeEstimateResponse `json:"nodes"`
+}
+
+// nodeEstimateResponse is what regenerating a node is expected to take.
+// Output and DurationMs are null when they couldn't be estimated.
+type nodeEstimateResponse struct {
+	NodeID           string                       `json:"node_id"`
+	NodeName         string                       `json:"node_name"`
+	Type             string                       `json:"type"`
+	Inputs           map[string]imageSizeResponse `json:"inputs"`
+	Output           *imageSizeResponse           `json:"output"`
+	MemoryBytes      int64                        `json:"memory_bytes"`
+	DurationMs       *float64                     `json:"duration_ms"`
+	TimedGenerations int                          `json:"timed_generations"`
+}
+
+// nodeStatsResponse summarises the recent generation runs of a node, with
+// the runs newest first. Durations and pixels only count the runs that
+// succeeded.
+type nodeStatsResponse struct {
+	NodeID           string                  `json:"node_id"`
+	Runs             int                     `json:"runs"`
+	Failures         int                     `json:"failures"`
+	LastMs           float64                 `json:"last_ms"`
+	MeanMs           float64                 `json:"mean_ms"`
+	MinMs            float64                 `json:"min_ms"`
+	MaxMs            float64                 `json:"max_ms"`
+	MeanInputPixels  int64                   `json:"mean_input_pixels"`
+	MeanOutputPixels int64                   `json:"mean_output_pixels"`
+	MsPerMegapixel   float64                 `json:"ms_per_megapixel"`
+	History          []generationRunResponse `json:"history"`
+}
+
+type generationRunResponse struct {
+	NodeVersion  int64     `json:"node_version"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   float64   `json:"duration_ms"`
+	InputPixels  int64     `json:"input_pixels"`
+	OutputPixels int64     `json:"output_pixels"`
+	Failed       bool      `json:"failed"`
+}
+
+// pipelineRunResponse is a run of a graph's pipeline, from an image being
+// set on an input node until the graph settled
+type pipelineRunResponse struct {
+	ID             string                      `json:"id"`
+	TriggerNodeID  string                      `json:"trigger_node_id"`
+	TriggerImageID string                      `json:"trigger_image_id"`
+	StartedAt      time.Time                   `json:"started_at"`
+	EndedAt        time.Time                   `json:"ended_at"`
+	DurationMs     float64                     `json:"duration_ms"`
+	Status         string                      `json:"status"`
+	Nodes          []pipelineRunNodeResponse   `json:"nodes"`
+	Outputs        []pipelineRunOutputResponse `json:"outputs"`
+}
+
+type pipelineRunNodeResponse struct {
+	NodeID      string    `json:"node_id"`
+	Type        string    `json:"type"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  float64   `json:"duration_ms"`
+	Generations int       `json:"generations"`
+	Failed      bool      `json:"failed"`
+}
+
+type pipelineRunOutputResponse struct {
+	Name       string  `json:"name"`
+	NodeID     string  `json:"node_id"`
+	ImageID    string  `json:"image_id"`
+	URL        string  `json:"url"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type listPipelineRunsResponse struct {
+	Runs []pipelineRunResponse `json:"runs"`
+}
+
+type imageSizeResponse struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type diagnosticResponse struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	Port     string `json:"port,omitempty"`
+	Message  string `json:"message"`
+}
+
+type connectionValidationResponse struct {
+	FromNodeID string   `json:"from_node_id"`
+	OutputName string   `json:"output_name"`
+	ToNodeID   string   `json:"to_node_id"`
+	InputName  string   `json:"input_name"`
+	Errors     []string `json:"errors"`
+	Warnings   []string `json:"warnings"`
+}
+
+// sweepNodeRequest sweeps a config field either through Values or through
+// the numeric range From to To in steps of Step, which defaults to 1
+// duplicateNodeRequest optionally names the graph a node is copied into and
+// how far the copy is placed from the node on the canvas
+type duplicateNodeRequest struct {
+	TargetGraphID string          `json:"target_graph_id,omitempty"`
+	Offset        *positionOffset `json:"offset,omitempty"`
+}
+
+type positionOffset struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type duplicateNodeResponse struct {
+	ID      string `json:"id"`
+	GraphID string `json:"graph_id"`
+}
+
+type sweepNodeRequest struct {
+	Field  string            `json:"field"`
+	Values []json.RawMessage `json:"values,omitempty"`
+	From   float64           `json:"from,omitempty"`
+	To     float64           `json:"to,omitempty"`
+	Step   float64           `json:"step,omitempty"`
+}
+
+type sweepNodeResponse struct {
+	NodeIDs []string `json:"node_ids"`
+}
+
+// addNodeInputRequest names the input to add, which defaults to the one
+// after the node's highest numbered input
+type addNodeInputRequest struct {
+	InputName string `json:"input_name,omitempty"`
+}
+
+type addNodeInputResponse struct {
+	InputName string `json:"input_name"`
+}
+
+type paletteSwatchResponse struct {
+	Color   string `json:"color"`
+	Enabled bool   `json:"enabled"`
+}
+
+type listPaletteSwatchesResponse struct {
+	Swatches []paletteSwatchResponse `json:"swatches"`
+}
+
+// addPaletteSwatchRequest adds a color, enabled unless enabled is false, at
+// index or at the end
+type addPaletteSwatchRequest struct {
+	Color   string `json:"color"`
+	Enabled *bool  `json:"enabled,omitempty"`
+	Index   *int   `json:"index,omitempty"`
+}
+
+type setPaletteSwatchRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type reorderPaletteSwatchesRequest struct {
+	Colors []string `json:"colors"`
+}
+
+// nodeDiffResponse reports how much the base and compare images of a diff
+// node differ
+type nodeDiffResponse struct {
+	RMSE float64 `json:"rmse"`
+	SSIM float64 `json:"ssim"`
+}
+
+// imageMetadataResponse is the metadata stored with an image
+type imageMetadataResponse struct {
+	Filename    string    `json:"filename,omitempty"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	DPI         float64   `json:"dpi,omitempty"`
+	CapturedAt  time.Time `json:"captured_at,omitzero"`
+	CameraMake  string    `json:"camera_make,omitempty"`
+	CameraModel string    `json:"camera_model,omitempty"`
+}
+
+type imageProvenanceResponse struct {
+	ImageID string                   `json:"image_id"`
+	Steps   []provenanceStepResponse `json:"steps"`
+	// Whether the chain was cut short after the most steps it's walked for
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// provenanceStepResponse is an image in a provenance chain: generated by a
+// node, hidden because the caller can't view the graph it was generated
+// in, or a source image, uploaded from a file if Filename is set
+type provenanceStepResponse struct {
+	ImageID    string                 `json:"image_id"`
+	Filename   string                 `json:"filename,omitempty"`
+	Hidden     bool                   `json:"hidden,omitempty"`
+	ProducedBy *imageProducerResponse `json:"produced_by,omitempty"`
+}
+
+type imageProducerResponse struct {
+	ImageGraphID string `json:"image_graph_id"`
+	NodeID       string `json:"node_id"`
+	// The node's current name, if it hasn't been removed
+	NodeName       string                    `json:"node_name,omitempty"`
+	NodeType       string                    `json:"node_type"`
+	NodeVersion    int                       `json:"node_version"`
+	OutputName     string                    `json:"output_name"`
+	Implementation int                       `json:"implementation"`
+	Bypassed       bool                      `json:"bypassed,omitempty"`
+	Config         imagegraph.NodeConfig     `json:"config"`
+	Inputs         []provenanceInputResponse `json:"inputs"`
+	ProducedAt     time.Time                 `json:"produced_at"`
+}
+
+type provenanceInputResponse struct {
+	Name      string `json:"name"`
+	ImageID   string `json:"image_id"`
+	Transform string `json:"transform,omitempty"`
+}
+
+type listExportsResponse struct {
+	Exports []exportResponse `json:"exports"`
+}
+
+type exportResponse struct {
+	Name        string    `json:"name"`
+	NodeID      string    `json:"node_id"`
+	ImageID     string    `json:"image_id"`
+	URL         string    `json:"url"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	Format      string    `json:"format,omitempty"`
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+}
+
+type galleryIndexResponse struct {
+	Graphs []galleryGraphSummary `json:"graphs"`
+}
+
+type galleryGraphSummary struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ImageCount int    `json:"image_count"`
+	CoverURL   string `json:"cover_url,omitempty"`
+}
+
+type galleryGraphResponse struct {
+	ID     string                 `json:"id"`
+	Name   string                 `json:"name"`
+	Images []galleryImageResponse `json:"images"`
+}
+
+type galleryImageResponse struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type latencyStatsResponse struct {
+	Count  int     `json:"count"`
+	LastMs float64 `json:"last_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+type connectionLatencyResponse struct {
+	FromNodeID     string               `json:"from_node_id"`
+	FromOutputName string               `json:"from_output_name"`
+	ToNodeID       string               `json:"to_node_id"`
+	ToInputName    string               `json:"to_input_name"`
+	Input          latencyStatsResponse `json:"input"`
+	Regeneration   latencyStatsResponse `json:"regeneration"`
+}
+
+type propagationLatencyResponse struct {
+	ImageGraphID string                      `json:"image_graph_id"`
+	Input        latencyStatsResponse        `json:"input"`
+	Regeneration latencyStatsResponse        `json:"regeneration"`
+	Connections  []connectionLatencyResponse `json:"connections"`
+}
+
 type listImageGraphsResponse struct {
 	ImageGraphs []imageGraphSummary `json:"imagegraphs"`
+	Total       int                 `json:"total"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
 }
 
 type imageGraphSummary struct {
-	ID   string `json:"id"`
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	Owner            string          `json:"owner,omitempty"`
+	Role             imagegraph.Role `json:"role,omitempty"`
+	Public           bool            `json:"public,omitempty"`
+	Tags             []string        `json:"tags,omitempty"`
+	NodeCount        int             `json:"node_count"`
+	ThumbnailImageID string          `json:"thumbnail_image_id,omitempty"`
+	CreatedAt        time.Time       `json:"created_at,omitzero"`
+	UpdatedAt        time.Time       `json:"updated_at,omitzero"`
+}
+
+// currentUserResponse reports who a request is authenticated as. Only
+// auth_enabled is set when authentication is disabled.
+type currentUserResponse struct {
+	AuthEnabled bool   `json:"auth_enabled"`
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Admin       bool   `json:"admin,omitempty"`
+}
+
+// adminStatsResponse reports what is stored, for capacity monitoring. The
+// image counts are left out when the image storage can't list its images.
+type adminStatsResponse struct {
+	ImageGraphs        int            `json:"image_graphs"`
+	NodesByType        map[string]int `json:"nodes_by_type"`
+	StoredImages       *int           `json:"stored_images,omitempty"`
+	StoredImageBytes   *int64         `json:"stored_image_bytes,omitempty"`
+	OrphanedImages     *int           `json:"orphaned_images,omitempty"`
+	OrphanedImageBytes *int64         `json:"orphaned_image_bytes,omitempty"`
+	QueueDepth         int            `json:"queue_depth"`
+}
+
+// deadLetterResponse describes an event a handler kept failing, with the
+// event as it was dispatched
+type deadLetterResponse struct {
+	ID            int64           `json:"id"`
+	Handler       string          `json:"handler"`
+	EventType     string          `json:"event_type"`
+	EntityType    string          `json:"entity_type"`
+	EntityID      string          `json:"entity_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Error         string          `json:"error"`
+	Attempts      int             `json:"attempts"`
+	FirstFailedAt time.Time       `json:"first_failed_at"`
+	LastFailedAt  time.Time       `json:"last_failed_at"`
+}
+
+type listDeadLettersResponse struct {
+	DeadLetters []deadLetterResponse `json:"dead_letters"`
+}
+
+// apiKeyResponse describes an API key without its secret
+type apiKeyResponse struct {
+	ID        string                    `json:"id"`
+	Name      string                    `json:"name"`
+	Scopes    []application.APIKeyScope `json:"scopes"`
+	RateLimit int                       `json:"rate_limit"`
+	Prefix    string                    `json:"prefix"`
+	CreatedAt time.Time                 `json:"created_at"`
+}
+
+// createAPIKeyResponse is the only response that includes a key's secret
+type createAPIKeyResponse struct {
+	apiKeyResponse
+	Key string `json:"key"`
+}
+
+type listAPIKeysResponse struct {
+	Keys []apiKeyResponse `json:"keys"`
+}
+
+// webhookResponse describes a webhook without its secret
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createWebhookResponse is the only response that includes a webhook's
+// secret
+type createWebhookResponse struct {
+	webhookResponse
+	Secret string `json:"secret"`
+}
+
+type listWebhooksResponse struct {
+	Webhooks []webhookResponse `json:"webhooks"`
+}
+
+type graphLinkResponse struct {
+	ID                 string    `json:"id"`
+	NodeID             string    `json:"node_id"`
+	SourceImageGraphID string    `json:"source_image_graph_id"`
+	SourceNodeID       string    `json:"source_node_id"`
+	SourceOutputName   string    `json:"source_output_name"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+type listGraphLinksResponse struct {
+	Links []graphLinkResponse `json:"links"`
+}
+
+type scheduleResponse struct {
+	Cron    string `json:"cron"`
+	Enabled bool   `json:"enabled"`
+
+	// NextRunAt is when the schedule next comes due, while it's enabled
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type scheduledRunResponse struct {
+	ScheduledFor time.Time `json:"scheduled_for"`
+	StartedAt    time.Time `json:"started_at"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type listScheduledRunsResponse struct {
+	Runs []scheduledRunResponse `json:"runs"`
+}
+
+type createSnapshotRequest struct {
 	Name string `json:"name"`
 }
 
+// snapshotSummaryResponse describes a snapshot without its nodes
+type snapshotSummaryResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Nodes     int       `json:"nodes"`
+	Images    int       `json:"images"`
+}
+
+type listSnapshotsResponse struct {
+	Snapshots []snapshotSummaryResponse `json:"snapshots"`
+}
+
+type snapshotResponse struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at"`
+	Nodes     []snapshotNodeResponse `json:"nodes"`
+}
+
+type snapshotNodeResponse struct {
+	NodeID  string                   `json:"node_id"`
+	Name    string                   `json:"name"`
+	Type    string                   `json:"type"`
+	Config  json.RawMessage          `json:"config"`
+	Outputs []snapshotOutputResponse `json:"outputs"`
+}
+
+type snapshotOutputResponse struct {
+	Name    string `json:"name"`
+	ImageID string `json:"image_id,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// compareSnapshotsResponse lists how the nodes of a snapshot, or of the
+// graph as it is now when there's no to_snapshot_id, differ from another
+// snapshot
+type compareSnapshotsResponse struct {
+	FromSnapshotID string                       `json:"from_snapshot_id"`
+	ToSnapshotID   string                       `json:"to_snapshot_id,omitempty"`
+	Added          int                          `json:"added"`
+	Removed        int                          `json:"removed"`
+	Changed        int                          `json:"changed"`
+	Unchanged      int                          `json:"unchanged"`
+	Nodes          []snapshotNodeChangeResponse `json:"nodes"`
+}
+
+type snapshotNodeChangeResponse struct {
+	NodeID        string                         `json:"node_id"`
+	Name          string                         `json:"name"`
+	Type          string                         `json:"type"`
+	Change        string                         `json:"change"`
+	ConfigChanged bool                           `json:"config_changed"`
+	FromConfig    json.RawMessage                `json:"from_config,omitempty"`
+	ToConfig      json.RawMessage                `json:"to_config,omitempty"`
+	Outputs       []snapshotOutputChangeResponse `json:"outputs"`
+}
+
+type snapshotOutputChangeResponse struct {
+	Name        string            `json:"name"`
+	Changed     bool              `json:"changed"`
+	FromImageID string            `json:"from_image_id,omitempty"`
+	FromURL     string            `json:"from_url,omitempty"`
+	ToImageID   string            `json:"to_image_id,omitempty"`
+	ToURL       string            `json:"to_url,omitempty"`
+	Difference  *nodeDiffResponse `json:"difference,omitempty"`
+}
+
+// shareResponse is a user an image graph is shared with and the role they
+// were granted
+type shareResponse struct {
+	UserID string          `json:"user_id"`
+	Role   imagegraph.Role `json:"role"`
+}
+
+type listSharesResponse struct {
+	Shares []shareResponse `json:"shares"`
+}
+
+type searchResponse struct {
+	Hits []searchHit `json:"hits"`
+}
+
+// searchHit is a graph or node name or tag matching a search. Node hits
+// carry the node so the frontend can open it in its graph.
+type searchHit struct {
+	Type      string `json:"type"`
+	Field     string `json:"field"`
+	Value     string `json:"value"`
+	Prefix    bool   `json:"prefix"`
+	GraphID   string `json:"graph_id"`
+	GraphName string `json:"graph_name"`
+	NodeID    string `json:"node_id,omitempty"`
+	NodeName  string `json:"node_name,omitempty"`
+}
+
 type imageGraphResponse struct {
-	ID      string         `json:"id"`
-	Name    string         `json:"name"`
-	Version int            `json:"version"`
-	Nodes   []nodeResponse `json:"nodes"`
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	ExternalID      string             `json:"external_id,omitempty"`
+	Owner           string             `json:"owner,omitempty"`
+	Role            imagegraph.Role    `json:"role,omitempty"`
+	Shares          []shareResponse    `json:"shares,omitempty"`
+	Public          bool               `json:"public,omitempty"`
+	ColorManagement string             `json:"color_management"`
+	PerformanceMode bool               `json:"performance_mode,omitempty"`
+	Parameters      map[string]float64 `json:"parameters,omitempty"`
+	Seed            *int               `json:"seed,omitempty"`
+	Tags            []string           `json:"tags,omitempty"`
+	Version         int                `json:"version"`
+	CreatedAt       time.Time          `json:"created_at,omitzero"`
+	UpdatedAt       time.Time          `json:"updated_at,omitzero"`
+	Complexity      complexityResponse `json:"complexity"`
+	Nodes           []nodeResponse     `json:"nodes"`
+}
+
+// complexityResponse reports the live complexity counters of an image graph
+// along with the configured limits, which are omitted when unlimited
+type complexityResponse struct {
+	Nodes              int `json:"nodes"`
+	Connections        int `json:"connections"`
+	PendingGenerations int `json:"pending_generations"`
+	MaxNodes           int `json:"max_nodes,omitempty"`
+	MaxConnections     int `json:"max_connections,omitempty"`
+}
+
+// imageGraphSummaryViewResponse is an image graph with just enough about
+// each node for list-like UIs, returned for ?view=summary. Its nodes replace
+// the full ones of the embedded response.
+type imageGraphSummaryViewResponse struct {
+	imageGraphResponse
+	Nodes []nodeSummaryResponse `json:"nodes"`
+}
+
+type nodeSummaryResponse struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	State   string `json:"state"`
+	Preview string `json:"preview,omitempty"`
 }
 
 type nodeResponse struct {
-	ID           string                `json:"id"`
-	Name         string                `json:"name"`
-	Type         string                `json:"type"`
-	Version      int                   `json:"version"`
-	ImageVersion int                   `json:"image_version,omitempty"`
-	Config       imagegraph.NodeConfig `json:"config"`
-	State        string                `json:"state"`
-	Preview      string                `json:"preview,omitempty"`
-	Inputs       []inputResponse       `json:"inputs"`
-	Outputs      []outputResponse      `json:"outputs"`
+	ID                   string                `json:"id"`
+	Name                 string                `json:"name"`
+	Description          string                `json:"description,omitempty"`
+	Tags                 []string              `json:"tags,omitempty"`
+	ExternalID           string                `json:"external_id,omitempty"`
+	Type                 string                `json:"type"`
+	Version              int                   `json:"version"`
+	ImageVersion         int                   `json:"image_version,omitempty"`
+	Config               imagegraph.NodeConfig `json:"config"`
+	Expressions          map[string]string     `json:"expressions,omitempty"`
+	EffectiveSeed        *int                  `json:"effective_seed,omitempty"`
+	Implementation       int                   `json:"implementation"`
+	LatestImplementation int                   `json:"latest_implementation"`
+	Bypassed             bool                  `json:"bypassed,omitempty"`
+	Pinned               bool                  `json:"pinned,omitempty"`
+	Stale                bool                  `json:"stale,omitempty"`
+	State                string                `json:"state"`
+	Error                string                `json:"error,omitempty"`
+	Preview              string                `json:"preview,omitempty"`
+	CreatedAt            time.Time             `json:"created_at,omitzero"`
+	UpdatedAt            time.Time             `json:"updated_at,omitzero"`
+	Inputs               []inputResponse       `json:"inputs"`
+	Outputs              []outputResponse      `json:"outputs"`
 }
 
 type inputResponse struct {
 	Name       string                   `json:"name"`
 	ImageID    string                   `json:"image_id,omitempty"`
 	Connected  bool                     `json:"connected"`
+	Optional   bool                     `json:"optional,omitempty"`
 	Connection *inputConnectionResponse `json:"connection,omitempty"`
 }
 
 type inputConnectionResponse struct {
 	NodeID     string `json:"node_id"`
 	OutputName string `json:"output_name"`
+	Transform  string `json:"transform,omitempty"`
 }
 
 type outputResponse struct {
 	Name        string                     `json:"name"`
 	ImageID     string                     `json:"image_id,omitempty"`
+	History     []outputVariantResponse    `json:"history,omitempty"`
 	Connections []outputConnectionResponse `json:"connections"`
 }
 
+// outputVariantResponse is an image an output held before it was replaced
+type outputVariantResponse struct {
+	ImageID     string    `json:"image_id"`
+	URL         string    `json:"url"`
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+}
+
+// outputHistoryResponse is the current image of a node output followed by
+// the images it replaced, most recent first
+type outputHistoryResponse struct {
+	OutputName  string                  `json:"output_name"`
+	ImageID     string                  `json:"image_id,omitempty"`
+	GeneratedAt time.Time               `json:"generated_at,omitzero"`
+	History     []outputVariantResponse `json:"history"`
+}
+
 type outputConnectionResponse struct {
 	NodeID    string `json:"node_id"`
 	InputName string `json:"input_name"`
 }
 
 type layoutResponse struct {
-	GraphID       string         `json:"graph_id"`
-	NodePositions []nodePosition `json:"node_positions"`
+	GraphID       string       `json:"graph_id"`
+	NodePositions []nodeLayout `json:"node_positions"`
 }
 
-type nodePosition struct {
-	NodeID string  `json:"node_id"`
-	X      float64 `json:"x"`
-	Y      float64 `json:"y"`
+// nodeLayout is how a node is drawn on the canvas. Only the position is
+// required; the rest is left out for nodes that use the defaults.
+type nodeLayout struct {
+	NodeID    string  `json:"node_id"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Width     float64 `json:"width,omitempty"`
+	Height    float64 `json:"height,omitempty"`
+	Collapsed bool    `json:"collapsed,omitempty"`
+	Color     string  `json:"color,omitempty"`
+	ZOrder    int     `json:"z_order,omitempty"`
 }
 
 type viewportResponse struct {
-	GraphID string  `json:"graph_id"`
+	GraphID   string                     `json:"graph_id"`
+	Zoom      float64                    `json:"zoom"`
+	PanX      float64                    `json:"pan_x"`
+	PanY      float64                    `json:"pan_y"`
+	Bookmarks []viewportBookmarkResponse `json:"bookmarks"`
+}
+
+type viewportBookmarkResponse struct {
+	Name    string  `json:"name"`
 	Zoom    float64 `json:"zoom"`
 	PanX    float64 `json:"pan_x"`
 	PanY    float64 `json:"pan_y"`
+	Default bool    `json:"default"`
+}
+
+type listViewportBookmarksResponse struct {
+	Bookmarks []viewportBookmarkResponse `json:"bookmarks"`
+}
+
+// fullImageGraphResponse is everything the editor loads for a graph
+type fullImageGraphResponse struct {
+	ImageGraph imageGraphResponse       `json:"imagegraph"`
+	Layout     layoutResponse           `json:"layout"`
+	Viewport   viewportResponse         `json:"viewport"`
+	NodeTypes  []nodeTypeSchemaAPIEntry `json:"node_types"`
 }
 
 type nodeTypeSchemasResponse struct {
@@ -148,17 +889,26 @@ type nodeTypeSchemasResponse struct {
 }
 
 type nodeTypeSchemaAPIEntry struct {
-	Name        string         `json:"name"`
-	DisplayName string         `json:"display_name"`
-	Category    string         `json:"category"`
-	Schema      nodeTypeSchema `json:"schema"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Category    string `json:"category"`
+	// Deprecated, if set, says what to use instead of the node type
+	Deprecated string         `json:"deprecated,omitempty"`
+	Schema     nodeTypeSchema `json:"schema"`
 }
 
+// nodeTypeSchema describes a node type. Node types with DynamicInputs can
+// have inputs named like it with a number after, up to MaxInputs in all.
 type nodeTypeSchema struct {
-	Inputs       []string              `json:"inputs"`
-	Outputs      []string              `json:"outputs"`
-	NameRequired bool                  `json:"name_required"`
-	Fields       []nodeTypeSchemaField `json:"fields"`
+	Inputs               []string              `json:"inputs"`
+	OptionalInputs       []string              `json:"optional_inputs,omitempty"`
+	DynamicInputs        string                `json:"dynamic_inputs,omitempty"`
+	MaxInputs            int                   `json:"max_inputs,omitempty"`
+	Outputs              []string              `json:"outputs"`
+	NameRequired         bool                  `json:"name_required"`
+	LatestImplementation int                   `json:"latest_implementation"`
+	ConfigVersion        int                   `json:"config_version"`
+	Fields               []nodeTypeSchemaField `json:"fields"`
 }
 
 type nodeTypeSchemaField struct {
@@ -171,6 +921,13 @@ type nodeTypeSchemaField struct {
 
 type errorResponse struct {
 	Error string `json:"error"`
+	// Fields lists the invalid fields of a request that failed validation
+	Fields []fieldErrorResponse `json:"fields,omitempty"`
+}
+
+type fieldErrorResponse struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
 }
 
 // Mappers
@@ -191,100 +948,632 @@ var nodeTypeMetadata = []nodeTypeInfo{
 	{imagegraph.NodeTypeResize, "resize", "Resize", "Resize"},
 	{imagegraph.NodeTypeResizeMatch, "resize_match", "Match To Size", "Resize"},
 	{imagegraph.NodeTypePixelInflate, "pixel_inflate", "Inflate Pixels", "Resize"},
+	{imagegraph.NodeTypeUpscale, "upscale", "Upscale", "Resize"},
 	{imagegraph.NodeTypeBlur, "blur", "Blur", "Transform"},
+	{imagegraph.NodeTypeAutoContrast, "auto_contrast", "Auto Contrast", "Transform"},
+	{imagegraph.NodeTypeColorSpace, "color_space", "Color Space", "Transform"},
+	{imagegraph.NodeTypeDiff, "diff", "Diff", "Transform"},
+	{imagegraph.NodeTypeContactSheet, "contact_sheet", "Contact Sheet", "Transform"},
+	{imagegraph.NodeTypeSwitch, "switch", "Switch", "Transform"},
 	{imagegraph.NodeTypePaletteCreate, "palette_create", "Palette Create", "Palette"},
 	{imagegraph.NodeTypePaletteEdit, "palette_edit", "Palette Edit", "Palette"},
 	{imagegraph.NodeTypePaletteExtract, "palette_extract", "Palette Extract", "Palette"},
 	{imagegraph.NodeTypePaletteApply, "palette_apply", "Palette Apply", "Palette"},
+	{imagegraph.NodeTypeGenerate, "generate", "Generate", "Generate"},
+}
+
+// RegisterNodeType lists a node type registered at startup after the
+// built-in ones, for the UI to offer. It must be called before the server is
+// created.
+func RegisterNodeType(nodeType imagegraph.NodeType, name, displayName, category string) {
+	nodeTypeMetadata = append(nodeTypeMetadata, nodeTypeInfo{nodeType, name, displayName, category})
 }
 
 // Conversion functions
 
 // mapImageGraphToResponse converts a domain ImageGraph to an API response
-func mapImageGraphToResponse(ig *imagegraph.ImageGraph) imageGraphResponse {
+func mapImageGraphToResponse(
+	ig *imagegraph.ImageGraph,
+	limits application.GraphLimits,
+) imageGraphResponse {
 	nodes := make([]nodeResponse, 0, len(ig.Nodes))
 
 	for _, node := range ig.Nodes {
-		// Map inputs in the order defined by the node type configuration
-		inputNames := imagegraph.NodeTypeDefs[node.Type].Inputs
-		inputs := make([]inputResponse, 0, len(inputNames))
-		for _, inputName := range inputNames {
-			input, ok := node.Inputs[inputName]
-			if !ok {
-				continue
-			}
+		nodes = append(nodes, mapNodeToResponse(node, ig.Seed))
+	}
 
-			inputResp := inputResponse{
-				Name:      string(input.Name),
-				Connected: input.Connected,
-			}
+	response := mapImageGraphFieldsToResponse(ig, limits)
+	response.Nodes = nodes
 
-			if !input.ImageID.IsNil() {
-				inputResp.ImageID = input.ImageID.String()
-			}
+	return response
+}
 
-			if input.Connected {
-				inputResp.Connection = &inputConnectionResponse{
-					NodeID:     input.InputConnection.NodeID.String(),
-					OutputName: string(input.InputConnection.OutputName),
-				}
-			}
+// mapImageGraphToSummaryViewResponse converts a domain ImageGraph to an API
+// response listing only the ID, name, type, state and preview of its nodes
+func mapImageGraphToSummaryViewResponse(
+	ig *imagegraph.ImageGraph,
+	limits application.GraphLimits,
+) imageGraphSummaryViewResponse {
+	nodes := make([]nodeSummaryResponse, 0, len(ig.Nodes))
 
-			inputs = append(inputs, inputResp)
+	for _, node := range ig.Nodes {
+		nodeResp := nodeSummaryResponse{
+			ID:    node.ID.String(),
+			Name:  node.Name,
+			Type:  imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
+			State: imagegraph.NodeStateMapper.FromWithDefault(node.State.Get(), "unknown"),
+		}
+		if !node.Preview.IsNil() {
+			nodeResp.Preview = node.Preview.String()
 		}
+		nodes = append(nodes, nodeResp)
+	}
 
-		// Map outputs in the order defined by the node type configuration
-		outputNames := imagegraph.NodeTypeDefs[node.Type].Outputs
-		outputs := make([]outputResponse, 0, len(outputNames))
-		for _, outputName := range outputNames {
-			output, ok := node.Outputs[outputName]
-			if !ok {
-				continue
-			}
+	return imageGraphSummaryViewResponse{
+		imageGraphResponse: mapImageGraphFieldsToResponse(ig, limits),
+		Nodes:              nodes,
+	}
+}
 
-			outputResp := outputResponse{
-				Name:        string(output.Name),
-				Connections: make([]outputConnectionResponse, 0, len(output.Connections)),
-			}
+// mapImageGraphFieldsToResponse converts everything about a domain
+// ImageGraph but its nodes to an API response
+func mapImageGraphFieldsToResponse(
+	ig *imagegraph.ImageGraph,
+	limits application.GraphLimits,
+) imageGraphResponse {
+	return imageGraphResponse{
+		ID:              ig.ID.String(),
+		Name:            ig.Name,
+		ExternalID:      ig.ExternalID,
+		Owner:           ig.Owner,
+		Shares:          mapSharesToResponse(ig.Shares),
+		Public:          ig.Public,
+		ColorManagement: ig.ColorManagement,
+		PerformanceMode: ig.PerformanceMode,
+		Parameters:      ig.Parameters,
+		Seed:            ig.Seed,
+		Tags:            ig.Tags,
+		Version:         int(ig.Version),
+		CreatedAt:       ig.CreatedAt,
+		UpdatedAt:       ig.UpdatedAt,
+		Complexity:      mapComplexityToResponse(ig.Complexity(), limits),
+	}
+}
+
+// mapImageMetadataToResponse converts ImageMetadata to an API response
+func mapImageMetadataToResponse(metadata imagegraph.ImageMetadata) imageMetadataResponse {
+	return imageMetadataResponse{
+		Filename:    metadata.Filename,
+		Width:       metadata.Width,
+		Height:      metadata.Height,
+		DPI:         metadata.DPI,
+		CapturedAt:  metadata.CapturedAt,
+		CameraMake:  metadata.CameraMake,
+		CameraModel: metadata.CameraModel,
+	}
+}
+
+// mapProvenanceToResponse converts the provenance of a generated image to a
+// step of a provenance chain, naming the node from the graph it was
+// generated in if the graph is still around
+func mapProvenanceToResponse(
+	imageID imagegraph.ImageID,
+	provenance imagegraph.ImageProvenance,
+	ig *imagegraph.ImageGraph,
+) provenanceStepResponse {
+	producer := &imageProducerResponse{
+		ImageGraphID:   provenance.ImageGraphID.String(),
+		NodeID:         provenance.NodeID.String(),
+		NodeType:       imagegraph.NodeTypeMapper.FromWithDefault(provenance.NodeType, "unknown"),
+		NodeVersion:    int(provenance.NodeVersion),
+		OutputName:     string(provenance.OutputName),
+		Implementation: provenance.Implementation,
+		Bypassed:       provenance.Bypassed,
+		Config:         provenance.Config,
+		Inputs:         make([]provenanceInputResponse, 0, len(provenance.Inputs)),
+		ProducedAt:     provenance.ProducedAt,
+	}
+
+	if ig != nil {
+		if node, ok := ig.Nodes.Get(provenance.NodeID); ok {
+			producer.NodeName = node.Name
+		}
+	}
+
+	for _, input := range provenance.Inputs {
+		producer.Inputs = append(producer.Inputs, provenanceInputResponse{
+			Name:      string(input.Name),
+			ImageID:   input.ImageID.String(),
+			Transform: input.Transform,
+		})
+	}
+
+	return provenanceStepResponse{ImageID: imageID.String(), ProducedBy: producer}
+}
+
+// mapAPIKeyToResponse converts an APIKey to an API response
+func mapAPIKeyToResponse(key application.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		RateLimit: key.RateLimit,
+		Prefix:    key.Prefix,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+// mapWebhookToResponse converts a Webhook to an API response
+func mapWebhookToResponse(webhook application.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+// mapGraphLinkToResponse converts a GraphLink to an API response
+func mapGraphLinkToResponse(link application.GraphLink) graphLinkResponse {
+	return graphLinkResponse{
+		ID:                 link.ID,
+		NodeID:             link.TargetNodeID.String(),
+		SourceImageGraphID: link.SourceImageGraphID.String(),
+		SourceNodeID:       link.SourceNodeID.String(),
+		SourceOutputName:   string(link.SourceOutputName),
+		CreatedAt:          link.CreatedAt,
+	}
+}
+
+// mapScheduleToResponse converts a Schedule to an API response, with when it
+// next comes due after now
+func mapScheduleToResponse(schedule application.Schedule, now time.Time) scheduleResponse {
+	response := scheduleResponse{
+		Cron:      schedule.Cron,
+		Enabled:   schedule.Enabled,
+		CreatedAt: schedule.CreatedAt,
+		UpdatedAt: schedule.UpdatedAt,
+	}
+
+	if parsed, err := cron.Parse(schedule.Cron); err == nil && schedule.Enabled {
+		if next := parsed.Next(now); !next.IsZero() {
+			response.NextRunAt = &next
+		}
+	}
+
+	return response
+}
+
+// mapSnapshotToSummaryResponse converts a Snapshot to an API response
+// without its nodes
+func mapSnapshotToSummaryResponse(snapshot application.Snapshot) snapshotSummaryResponse {
+	return snapshotSummaryResponse{
+		ID:        snapshot.ID,
+		Name:      snapshot.Name,
+		CreatedAt: snapshot.CreatedAt,
+		Nodes:     len(snapshot.Nodes),
+		Images:    snapshot.ImageCount(),
+	}
+}
 
+// mapSnapshotToResponse converts a Snapshot to an API response
+func mapSnapshotToResponse(snapshot application.Snapshot) snapshotResponse {
+	response := snapshotResponse{
+		ID:        snapshot.ID,
+		Name:      snapshot.Name,
+		CreatedAt: snapshot.CreatedAt,
+		Nodes:     make([]snapshotNodeResponse, 0, len(snapshot.Nodes)),
+	}
+
+	for _, node := range snapshot.Nodes {
+		nodeResp := snapshotNodeResponse{
+			NodeID:  node.NodeID.String(),
+			Name:    node.Name,
+			Type:    imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
+			Config:  node.Config,
+			Outputs: make([]snapshotOutputResponse, 0, len(node.Outputs)),
+		}
+
+		for _, output := range node.Outputs {
+			outputResp := snapshotOutputResponse{Name: string(output.Name)}
 			if !output.ImageID.IsNil() {
 				outputResp.ImageID = output.ImageID.String()
+				outputResp.URL = "/api/images/" + output.ImageID.String()
 			}
+			nodeResp.Outputs = append(nodeResp.Outputs, outputResp)
+		}
+
+		response.Nodes = append(response.Nodes, nodeResp)
+	}
+
+	return response
+}
 
-			for conn := range output.Connections {
-				outputResp.Connections = append(outputResp.Connections, outputConnectionResponse{
-					NodeID:    conn.NodeID.String(),
-					InputName: string(conn.InputName),
-				})
+// mapSnapshotNodeChangeToResponse converts the comparison of a node between
+// two snapshots to an API response, named and typed as it is in the later
+// one
+func mapSnapshotNodeChangeToResponse(change application.SnapshotNodeChange) snapshotNodeChangeResponse {
+	node := change.To
+	if node == nil {
+		node = change.From
+	}
+
+	response := snapshotNodeChangeResponse{
+		NodeID:        change.NodeID.String(),
+		Name:          node.Name,
+		Type:          imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
+		Change:        change.Change,
+		ConfigChanged: change.ConfigChanged,
+		Outputs:       make([]snapshotOutputChangeResponse, 0, len(change.Outputs)),
+	}
+
+	if change.ConfigChanged {
+		response.FromConfig = change.From.Config
+		response.ToConfig = change.To.Config
+	}
+
+	for _, output := range change.Outputs {
+		outputResp := snapshotOutputChangeResponse{
+			Name:    string(output.Name),
+			Changed: output.Changed,
+		}
+		if !output.FromImageID.IsNil() {
+			outputResp.FromImageID = output.FromImageID.String()
+			outputResp.FromURL = "/api/images/" + output.FromImageID.String()
+		}
+		if !output.ToImageID.IsNil() {
+			outputResp.ToImageID = output.ToImageID.String()
+			outputResp.ToURL = "/api/images/" + output.ToImageID.String()
+		}
+		response.Outputs = append(response.Outputs, outputResp)
+	}
+
+	return response
+}
+
+// mapSharesToResponse converts an ImageGraph's shares to an API response,
+// ordered by user ID
+func mapSharesToResponse(shares imagegraph.Shares) []shareResponse {
+	response := make([]shareResponse, 0, len(shares))
+
+	for _, userID := range slices.Sorted(maps.Keys(shares)) {
+		response = append(response, shareResponse{UserID: userID, Role: shares[userID]})
+	}
+
+	return response
+}
+
+// mapComplexityToResponse converts an ImageGraph's complexity and the limits
+// it is held to into an API response
+func mapComplexityToResponse(
+	complexity imagegraph.Complexity,
+	limits application.GraphLimits,
+) complexityResponse {
+	return complexityResponse{
+		Nodes:              complexity.Nodes,
+		Connections:        complexity.Connections,
+		PendingGenerations: complexity.PendingGenerations,
+		MaxNodes:           limits.MaxNodes,
+		MaxConnections:     limits.MaxConnections,
+	}
+}
+
+// mapNodeToResponse converts a domain Node of a graph with the given seed to
+// an API response
+func mapNodeToResponse(node *imagegraph.Node, graphSeed *int) nodeResponse {
+	// Map inputs in the order defined by the node type configuration, then
+	// any the node added
+	inputNames := node.InputNames()
+	inputs := make([]inputResponse, 0, len(inputNames))
+	for _, inputName := range inputNames {
+		input, ok := node.Inputs[inputName]
+		if !ok {
+			continue
+		}
+
+		inputResp := inputResponse{
+			Name:      string(input.Name),
+			Connected: input.Connected,
+			Optional:  input.Optional,
+		}
+
+		if !input.ImageID.IsNil() {
+			inputResp.ImageID = input.ImageID.String()
+		}
+
+		if input.Connected {
+			inputResp.Connection = &inputConnectionResponse{
+				NodeID:     input.InputConnection.NodeID.String(),
+				OutputName: string(input.InputConnection.OutputName),
+				Transform:  input.Transform,
 			}
+		}
 
-			outputs = append(outputs, outputResp)
+		inputs = append(inputs, inputResp)
+	}
+
+	// Map outputs in the order defined by the node type configuration
+	outputNames := imagegraph.NodeTypeDefs[node.Type].Outputs
+	outputs := make([]outputResponse, 0, len(outputNames))
+	for _, outputName := range outputNames {
+		output, ok := node.Outputs[outputName]
+		if !ok {
+			continue
 		}
 
-		nodeResp := nodeResponse{
-			ID:           node.ID.String(),
-			Name:         node.Name,
-			Type:         imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
-			Version:      int(node.Version),
-			ImageVersion: int(node.ImageVersion),
-			Config:       node.Config,
-			State:        imagegraph.NodeStateMapper.FromWithDefault(node.State.Get(), "unknown"),
-			Inputs:       inputs,
-			Outputs:      outputs,
+		outputResp := outputResponse{
+			Name:        string(output.Name),
+			Connections: make([]outputConnectionResponse, 0, len(output.Connections)),
 		}
 
-		if !node.Preview.IsNil() {
-			nodeResp.Preview = node.Preview.String()
+		if !output.ImageID.IsNil() {
+			outputResp.ImageID = output.ImageID.String()
 		}
 
-		nodes = append(nodes, nodeResp)
+		if len(output.History) > 0 {
+			outputResp.History = mapOutputVariantsToResponse(output.History)
+		}
+
+		for conn := range output.Connections {
+			outputResp.Connections = append(outputResp.Connections, outputConnectionResponse{
+				NodeID:    conn.NodeID.String(),
+				InputName: string(conn.InputName),
+			})
+		}
+
+		outputs = append(outputs, outputResp)
 	}
 
-	return imageGraphResponse{
-		ID:      ig.ID.String(),
-		Name:    ig.Name,
-		Version: int(ig.Version),
-		Nodes:   nodes,
+	nodeResp := nodeResponse{
+		ID:                   node.ID.String(),
+		Name:                 node.Name,
+		Description:          node.Description,
+		Tags:                 node.Tags,
+		ExternalID:           node.ExternalID,
+		Type:                 imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
+		Version:              int(node.Version),
+		ImageVersion:         int(node.ImageVersion),
+		Config:               node.Config,
+		Expressions:          node.Expressions,
+		Implementation:       node.Implementation,
+		LatestImplementation: imagegraph.NodeTypeDefs[node.Type].LatestImplementation(),
+		Bypassed:             node.Bypassed,
+		Pinned:               node.Pinned,
+		Stale:                node.Stale,
+		State:                imagegraph.NodeStateMapper.FromWithDefault(node.State.Get(), "unknown"),
+		Error:                node.Error,
+		CreatedAt:            node.CreatedAt,
+		UpdatedAt:            node.UpdatedAt,
+		Inputs:               inputs,
+		Outputs:              outputs,
+	}
+
+	if !node.Preview.IsNil() {
+		nodeResp.Preview = node.Preview.String()
+	}
+
+	if seed, ok := imagegraph.EffectiveSeed(node.Config, graphSeed); ok {
+		nodeResp.EffectiveSeed = &seed
+	}
+
+	return nodeResp
+}
+
+// galleryImage is an exported image published by a public image graph
+type galleryImage struct {
+	name    string
+	imageID imagegraph.ImageID
+}
+
+// galleryImages returns the exported images of an image graph, ordered by
+// export name
+func galleryImages(ig *imagegraph.ImageGraph) []galleryImage {
+	exports := ig.Exports()
+
+	images := make([]galleryImage, 0, len(exports))
+
+	for _, export := range exports {
+		images = append(images, galleryImage{name: export.Name, imageID: export.ImageID})
+	}
+
+	return images
+}
+
+func galleryImageURL(imageGraphID imagegraph.ImageGraphID, imageID imagegraph.ImageID) string {
+	return fmt.Sprintf("/api/gallery/%s/images/%s", imageGraphID, imageID)
+}
+
+func mapGalleryIndexToResponse(imageGraphs []*imagegraph.ImageGraph) galleryIndexResponse {
+	graphs := make([]galleryGraphSummary, 0, len(imageGraphs))
+
+	for _, ig := range imageGraphs {
+		images := galleryImages(ig)
+
+		summary := galleryGraphSummary{
+			ID:         ig.ID.String(),
+			Name:       ig.Name,
+			ImageCount: len(images),
+		}
+
+		if len(images) > 0 {
+			summary.CoverURL = galleryImageURL(ig.ID, images[0].imageID)
+		}
+
+		graphs = append(graphs, summary)
+	}
+
+	return galleryIndexResponse{Graphs: graphs}
+}
+
+func mapGalleryGraphToResponse(ig *imagegraph.ImageGraph) galleryGraphResponse {
+	images := galleryImages(ig)
+
+	response := galleryGraphResponse{
+		ID:     ig.ID.String(),
+		Name:   ig.Name,
+		Images: make([]galleryImageResponse, 0, len(images)),
+	}
+
+	for _, image := range images {
+		response.Images = append(response.Images, galleryImageResponse{
+			Name: image.name,
+			URL:  galleryImageURL(ig.ID, image.imageID),
+		})
+	}
+
+	return response
+}
+
+func mapNodeLayoutsToResponse(nodeLayouts []ui.NodeLayout) []nodeLayout {
+	response := make([]nodeLayout, 0, len(nodeLayouts))
+	for _, nl := range nodeLayouts {
+		response = append(response, nodeLayout{
+			NodeID:    nl.NodeID.String(),
+			X:         nl.X,
+			Y:         nl.Y,
+			Width:     nl.Width,
+			Height:    nl.Height,
+			Collapsed: nl.Collapsed,
+			Color:     nl.Color,
+			ZOrder:    nl.ZOrder,
+		})
+	}
+	return response
+}
+
+func mapViewportBookmarkToResponse(bookmark ui.ViewportBookmark) viewportBookmarkResponse {
+	return viewportBookmarkResponse{
+		Name:    bookmark.Name,
+		Zoom:    bookmark.Zoom,
+		PanX:    bookmark.PanX,
+		PanY:    bookmark.PanY,
+		Default: bookmark.Default,
+	}
+}
+
+func mapViewportBookmarksToResponse(bookmarks []ui.ViewportBookmark) []viewportBookmarkResponse {
+	response := make([]viewportBookmarkResponse, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		response = append(response, mapViewportBookmarkToResponse(bookmark))
+	}
+	return response
+}
+
+func mapGenerationRunsToResponse(nodeID imagegraph.NodeID, runs []application.GenerationRun) nodeStatsResponse {
+	toMs := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	stats := application.SummarizeGenerationRuns(runs)
+
+	response := nodeStatsResponse{
+		NodeID:           nodeID.String(),
+		Runs:             stats.Runs,
+		Failures:         stats.Failures,
+		LastMs:           toMs(stats.Last),
+		MeanMs:           toMs(stats.Mean),
+		MinMs:            toMs(stats.Min),
+		MaxMs:            toMs(stats.Max),
+		MeanInputPixels:  stats.MeanInputPixels,
+		MeanOutputPixels: stats.MeanOutputPixels,
+		MsPerMegapixel:   toMs(stats.PerMegapixel),
+		History:          make([]generationRunResponse, 0, len(runs)),
+	}
+
+	for _, run := range runs {
+		response.History = append(response.History, generationRunResponse{
+			NodeVersion:  int64(run.NodeVersion),
+			StartedAt:    run.StartedAt,
+			DurationMs:   toMs(run.Duration),
+			InputPixels:  run.InputPixels,
+			OutputPixels: run.OutputPixels,
+			Failed:       run.Failed,
+		})
+	}
+
+	return response
+}
+
+func mapPipelineRunsToResponse(runs []application.PipelineRun) listPipelineRunsResponse {
+	toMs := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	response := listPipelineRunsResponse{Runs: make([]pipelineRunResponse, 0, len(runs))}
+
+	for _, run := range runs {
+		runResp := pipelineRunResponse{
+			ID:             run.ID,
+			TriggerNodeID:  run.TriggerNodeID.String(),
+			TriggerImageID: run.TriggerImageID.String(),
+			StartedAt:      run.StartedAt,
+			EndedAt:        run.EndedAt,
+			DurationMs:     toMs(run.Duration()),
+			Status:         run.Status,
+			Nodes:          make([]pipelineRunNodeResponse, 0, len(run.Nodes)),
+			Outputs:        make([]pipelineRunOutputResponse, 0, len(run.Outputs)),
+		}
+
+		for _, node := range run.Nodes {
+			runResp.Nodes = append(runResp.Nodes, pipelineRunNodeResponse{
+				NodeID:      node.NodeID.String(),
+				Type:        imagegraph.NodeTypeMapper.FromWithDefault(node.NodeType, "unknown"),
+				StartedAt:   node.StartedAt,
+				DurationMs:  toMs(node.Duration()),
+				Generations: node.Generations,
+				Failed:      node.Failed,
+			})
+		}
+
+		for _, output := range run.Outputs {
+			runResp.Outputs = append(runResp.Outputs, pipelineRunOutputResponse{
+				Name:       output.Name,
+				NodeID:     output.NodeID.String(),
+				ImageID:    output.ImageID.String(),
+				URL:        "/api/images/" + output.ImageID.String(),
+				DurationMs: toMs(output.Duration),
+			})
+		}
+
+		response.Runs = append(response.Runs, runResp)
+	}
+
+	return response
+}
+
+func mapLatencyStatsToResponse(stats application.LatencyStats) latencyStatsResponse {
+	toMs := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	return latencyStatsResponse{
+		Count:  stats.Count,
+		LastMs: toMs(stats.Last),
+		MeanMs: toMs(stats.Mean),
+		MaxMs:  toMs(stats.Max),
+	}
+}
+
+// mapPropagationLatencyToResponse converts a propagation latency report to
+// an API response
+func mapPropagationLatencyToResponse(report application.PropagationLatencyReport) propagationLatencyResponse {
+	connections := make([]connectionLatencyResponse, 0, len(report.Connections))
+
+	for _, c := range report.Connections {
+		connections = append(connections, connectionLatencyResponse{
+			FromNodeID:     c.FromNodeID.String(),
+			FromOutputName: string(c.FromOutputName),
+			ToNodeID:       c.ToNodeID.String(),
+			ToInputName:    string(c.ToInputName),
+			Input:          mapLatencyStatsToResponse(c.Input),
+			Regeneration:   mapLatencyStatsToResponse(c.Regeneration),
+		})
+	}
+
+	return propagationLatencyResponse{
+		ImageGraphID: report.ImageGraphID.String(),
+		Input:        mapLatencyStatsToResponse(report.Input),
+		Regeneration: mapLatencyStatsToResponse(report.Regeneration),
+		Connections:  connections,
 	}
 }
 
@@ -304,6 +1593,11 @@ func buildNodeTypeSchemas() []nodeTypeSchemaAPIEntry {
 			inputs[i] = string(input)
 		}
 
+		optionalInputs := make([]string, len(cfg.OptionalInputs))
+		for i, input := range cfg.OptionalInputs {
+			optionalInputs[i] = string(input)
+		}
+
 		// Convert outputs
 		outputs := make([]string, len(cfg.Outputs))
 		for i, output := range cfg.Outputs {
@@ -328,14 +1622,49 @@ func buildNodeTypeSchemas() []nodeTypeSchemaAPIEntry {
 			Name:        info.name,
 			DisplayName: info.displayName,
 			Category:    info.category,
+			Deprecated:  cfg.Deprecated,
 			Schema: nodeTypeSchema{
-				Inputs:       inputs,
-				Outputs:      outputs,
-				NameRequired: cfg.NameRequired,
-				Fields:       fields,
+				Inputs:               inputs,
+				OptionalInputs:       optionalInputs,
+				DynamicInputs:        string(cfg.DynamicInputs),
+				MaxInputs:            cfg.MaxInputs,
+				Outputs:              outputs,
+				NameRequired:         cfg.NameRequired,
+				LatestImplementation: cfg.LatestImplementation(),
+				ConfigVersion:        cfg.ConfigVersion(),
+				Fields:               fields,
 			},
 		})
 	}
 
 	return apiSchemas
 }
+
+func mapOutputVariantsToResponse(variants []imagegraph.OutputVariant) []outputVariantResponse {
+	response := make([]outputVariantResponse, 0, len(variants))
+
+	for _, variant := range variants {
+		response = append(response, outputVariantResponse{
+			ImageID:     variant.ImageID.String(),
+			URL:         "/api/images/" + variant.ImageID.String(),
+			GeneratedAt: variant.GeneratedAt,
+			ReplacedAt:  variant.ReplacedAt,
+		})
+	}
+
+	return response
+}
+
+func mapOutputHistoryToResponse(output *imagegraph.Output) outputHistoryResponse {
+	response := outputHistoryResponse{
+		OutputName:  string(output.Name),
+		GeneratedAt: output.GeneratedAt,
+		History:     mapOutputVariantsToResponse(output.History),
+	}
+
+	if output.HasImage() {
+		response.ImageID = output.ImageID.String()
+	}
+
+	return response
+}
@@ -4,22 +4,27 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/dmpettyp/dorky/messagebus"
 	"github.com/google/uuid"
 
 	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+	"github.com/dmpettyp/artwork/logging"
 	"github.com/dmpettyp/artwork/metrics"
 )
 
 type HTTPServer struct {
 	logger          *slog.Logger
-	messageBus      *messagebus.MessageBus
+	messageBus      application.CommandHandler
 	imageGraphViews application.ImageGraphViews
 	layoutViews     application.LayoutViews
 	viewportViews   application.ViewportViews
@@ -28,6 +33,84 @@ type HTTPServer struct {
 	server          *http.Server
 	port            string
 	metrics         *metrics.HTTPMetrics
+	idGenerator     IDGenerator
+	latencyReporter PropagationLatencyReporter
+	imageComparer   ImageComparer
+	timingReporter  GenerationTimingReporter
+	generationRuns  application.GenerationRunStore
+	previews        PreviewGenerator
+	galleryLimiter  *rateLimiter
+	graphLimits     application.GraphLimits
+	imageLimits     imagegraph.ImageLimits
+	requestTimeout  time.Duration
+	busyRetryAfter  time.Duration
+	authenticator   Authenticator
+	apiKeys         application.APIKeyStore
+	apiKeyUsers     UserDirectory
+	apiKeyLimiters  *apiKeyLimiters
+	webhooks        application.WebhookStore
+	graphLinks      application.GraphLinkStore
+	schedules       application.ScheduleStore
+	deadLetters     *application.DeadLetterQueue
+	snapshots       application.SnapshotStore
+	pipelineRuns    application.PipelineRunStore
+	stats           application.StatsCollector
+	cors            *CORSConfig
+	trustedProxies  []netip.Prefix
+	frontend        fs.FS
+	openAPIDocument map[string]any
+}
+
+// PropagationLatencyReporter reports how long images take to propagate
+// through the connections of an image graph
+type PropagationLatencyReporter interface {
+	Report(imageGraphID imagegraph.ImageGraphID) application.PropagationLatencyReport
+}
+
+// GenerationTimingReporter reports how long generating each node type has
+// taken
+type GenerationTimingReporter interface {
+	GenerationTimings() map[imagegraph.NodeType]imagegraph.GenerationTiming
+}
+
+// ImageComparer measures how much two images differ
+type ImageComparer interface {
+	CompareImages(
+		ctx context.Context,
+		baseImageID imagegraph.ImageID,
+		compareImageID imagegraph.ImageID,
+	) (imagegraph.ImageDifference, error)
+}
+
+// PreviewGenerator generates the preview of a node from one of its output
+// images, for nodes whose preview was skipped by performance mode
+type PreviewGenerator interface {
+	GeneratePreview(
+		ctx context.Context,
+		imageGraphID imagegraph.ImageGraphID,
+		nodeID imagegraph.NodeID,
+		nodeVersion imagegraph.NodeVersion,
+		outputImageID imagegraph.ImageID,
+	) (imagegraph.ImageID, error)
+}
+
+// IDGenerator creates the IDs assigned to image graphs and nodes created
+// through the API
+type IDGenerator interface {
+	NewImageGraphID() (imagegraph.ImageGraphID, error)
+	NewNodeID() (imagegraph.NodeID, error)
+}
+
+// randomIDGenerator is the default IDGenerator, using the domain's ID
+// constructors
+type randomIDGenerator struct{}
+
+func (randomIDGenerator) NewImageGraphID() (imagegraph.ImageGraphID, error) {
+	return imagegraph.NewImageGraphID()
+}
+
+func (randomIDGenerator) NewNodeID() (imagegraph.NodeID, error) {
+	return imagegraph.NewNodeID()
 }
 
 // ServerOption is a functional option for configuring the HTTPServer
@@ -40,11 +123,207 @@ func WithPort(port string) ServerOption {
 	}
 }
 
+// WithIDGenerator sets the generator used to create IDs for new image graphs
+// and nodes
+func WithIDGenerator(idGenerator IDGenerator) ServerOption {
+	return func(s *HTTPServer) {
+		s.idGenerator = idGenerator
+	}
+}
+
+// WithPropagationLatencyReporter enables the propagation latency report
+// endpoint
+func WithPropagationLatencyReporter(reporter PropagationLatencyReporter) ServerOption {
+	return func(s *HTTPServer) {
+		s.latencyReporter = reporter
+	}
+}
+
+// WithGenerationTimingReporter sets where estimates of how long regenerating
+// a graph takes get their timings from. Without one, estimates leave out
+// durations.
+func WithGenerationTimingReporter(reporter GenerationTimingReporter) ServerOption {
+	return func(s *HTTPServer) {
+		s.timingReporter = reporter
+	}
+}
+
+// WithGenerationRuns enables the node stats endpoint reporting how long
+// each generation of a node took, and has estimates prefer the timings of
+// the node itself over those of its type
+func WithGenerationRuns(runs application.GenerationRunStore) ServerOption {
+	return func(s *HTTPServer) {
+		s.generationRuns = runs
+	}
+}
+
+// WithImageComparer enables the endpoint reporting how much the inputs of a
+// diff node differ
+func WithImageComparer(comparer ImageComparer) ServerOption {
+	return func(s *HTTPServer) {
+		s.imageComparer = comparer
+	}
+}
+
+// WithPreviewGenerator enables generating the previews of nodes on demand
+// through the node preview endpoint
+func WithPreviewGenerator(previews PreviewGenerator) ServerOption {
+	return func(s *HTTPServer) {
+		s.previews = previews
+	}
+}
+
+// WithFrontend serves the frontend from the given files, such as the ones
+// embedded in the binary, instead of the ../frontend directory
+func WithFrontend(frontend fs.FS) ServerOption {
+	return func(s *HTTPServer) {
+		s.frontend = frontend
+	}
+}
+
+// WithGallery enables the public gallery endpoints. Each client may make
+// requestsPerMinute gallery requests on average, in bursts of up to burst
+// requests.
+func WithGallery(requestsPerMinute int, burst int) ServerOption {
+	return func(s *HTTPServer) {
+		s.galleryLimiter = newRateLimiter(requestsPerMinute, burst)
+	}
+}
+
+// WithGraphLimits reports the complexity limits image graphs are held to
+// alongside their live complexity counters. The limits themselves are
+// enforced by the command handlers.
+func WithGraphLimits(limits application.GraphLimits) ServerOption {
+	return func(s *HTTPServer) {
+		s.graphLimits = limits
+	}
+}
+
+// WithImageLimits rejects uploaded images larger than the limits, checked
+// from their headers before anything decodes their pixels
+func WithImageLimits(limits imagegraph.ImageLimits) ServerOption {
+	return func(s *HTTPServer) {
+		s.imageLimits = limits
+	}
+}
+
+// WithRequestTimeout sets a deadline on the context of every API request.
+// The deadline is carried into command handling and the output generation
+// the request triggers. WebSocket connections are exempt.
+func WithRequestTimeout(timeout time.Duration) ServerOption {
+	return func(s *HTTPServer) {
+		s.requestTimeout = timeout
+	}
+}
+
+// WithBusyRejection answers API requests whose commands find their image
+// graph's command queue full with 503 Service Unavailable, asking the client
+// to retry after the provided duration, rather than holding them until there
+// is room. The queues are bounded with application.WithGraphQueueLimit.
+func WithBusyRejection(retryAfter time.Duration) ServerOption {
+	return func(s *HTTPServer) {
+		s.busyRetryAfter = retryAfter
+	}
+}
+
+// WithAuthenticator requires API requests to be authenticated, scoping the
+// image graphs each user can list and access to the ones they own. Admins
+// can access every graph. The gallery stays public.
+func WithAuthenticator(authenticator Authenticator) ServerOption {
+	return func(s *HTTPServer) {
+		s.authenticator = authenticator
+	}
+}
+
+// WithAPIKeys lets users issue API keys from /api/keys for scripts to
+// authenticate with instead of their access token. Keys are stored in store
+// and act as the users' current versions in users. Only takes effect along
+// with WithAuthenticator.
+func WithAPIKeys(store application.APIKeyStore, users UserDirectory) ServerOption {
+	return func(s *HTTPServer) {
+		s.apiKeys = store
+		s.apiKeyUsers = users
+	}
+}
+
+// WithWebhooks lets graph editors register webhooks, stored in store, that
+// are notified when the graph's pipeline completes
+func WithWebhooks(store application.WebhookStore) ServerOption {
+	return func(s *HTTPServer) {
+		s.webhooks = store
+	}
+}
+
+// WithGraphLinks lets graph editors link Input nodes to node outputs of
+// other graphs, with the links stored in store. The application's
+// GraphLinkEventHandlers must propagate images along the links.
+func WithGraphLinks(store application.GraphLinkStore) ServerOption {
+	return func(s *HTTPServer) {
+		s.graphLinks = store
+	}
+}
+
+// WithSchedules lets graph editors schedule their graphs to regenerate on a
+// cron expression, with the schedules and their run history stored in
+// store. An application.Scheduler must run the schedules.
+func WithSchedules(store application.ScheduleStore) ServerOption {
+	return func(s *HTTPServer) {
+		s.schedules = store
+	}
+}
+
+// WithDeadLetters enables the admin endpoints inspecting, replaying and
+// discarding the events whose handlers kept failing
+func WithDeadLetters(queue *application.DeadLetterQueue) ServerOption {
+	return func(s *HTTPServer) {
+		s.deadLetters = queue
+	}
+}
+
+// WithSnapshots enables taking named snapshots of the results of graphs,
+// stored in store, and comparing them
+func WithSnapshots(store application.SnapshotStore) ServerOption {
+	return func(s *HTTPServer) {
+		s.snapshots = store
+	}
+}
+
+// WithStats enables the admin endpoint reporting how many graphs, nodes and
+// images are stored, with GraphStats from collector
+func WithStats(collector application.StatsCollector) ServerOption {
+	return func(s *HTTPServer) {
+		s.stats = collector
+	}
+}
+
+// WithPipelineRuns enables the endpoint listing the recent runs of a graph's
+// pipeline, stored in store
+func WithPipelineRuns(store application.PipelineRunStore) ServerOption {
+	return func(s *HTTPServer) {
+		s.pipelineRuns = store
+	}
+}
+
+// WithCORS lets browser frontends served from other origins call the API
+func WithCORS(config CORSConfig) ServerOption {
+	return func(s *HTTPServer) {
+		s.cors = &config
+	}
+}
+
+// WithTrustedProxies honors the X-Forwarded-* headers of requests from the
+// given proxy networks, for running behind a reverse proxy
+func WithTrustedProxies(proxies []netip.Prefix) ServerOption {
+	return func(s *HTTPServer) {
+		s.trustedProxies = proxies
+	}
+}
+
 // NewHTTPServer creates a new HTTP server that handles requests by sending
 // commands to the provided message bus
 func NewHTTPServer(
 	logger *slog.Logger,
-	messageBus *messagebus.MessageBus,
+	messageBus application.CommandHandler,
 	imageGraphViews application.ImageGraphViews,
 	layoutViews application.LayoutViews,
 	viewportViews application.ViewportViews,
@@ -66,6 +345,8 @@ func NewHTTPServer(
 		imageStorage:    imageStorage,
 		notifier:        notifier,
 		port:            "8080", // default port
+		idGenerator:     randomIDGenerator{},
+		frontend:        os.DirFS("../frontend"),
 	}
 
 	// Apply options
@@ -73,44 +354,180 @@ func NewHTTPServer(
 		opt(s)
 	}
 
+	if s.authenticator == nil {
+		s.apiKeys = nil
+	}
+	if s.apiKeys != nil {
+		s.authenticator = &apiKeyAuthenticator{keys: s.apiKeys, users: s.apiKeyUsers, next: s.authenticator}
+		s.apiKeyLimiters = newAPIKeyLimiters()
+	}
+
 	s.metrics = appMetrics.HTTP
 
 	// Set up routes
-	mux := http.NewServeMux()
+	mux := &routeRecorder{ServeMux: http.NewServeMux()}
+
+	// API description
+	mux.HandleFunc("GET /api/openapi.json", s.handleGetOpenAPIDocument)
+	mux.HandleFunc("GET /api/docs", s.handleGetAPIDocs)
 
 	// API routes
+	mux.HandleFunc("GET /api/me", s.handleGetCurrentUser)
+	if s.apiKeys != nil {
+		mux.HandleFunc("GET /api/keys", s.handleListAPIKeys)
+		mux.HandleFunc("POST /api/keys", s.handleCreateAPIKey)
+		mux.HandleFunc("DELETE /api/keys/{key_id}", s.handleDeleteAPIKey)
+	}
+	if s.stats != nil {
+		mux.HandleFunc("GET /api/admin/stats", authorizeAdmin(s.handleGetAdminStats))
+	}
+	if s.deadLetters != nil {
+		mux.HandleFunc("GET /api/admin/dlq", authorizeAdmin(s.handleListDeadLetters))
+		mux.HandleFunc("GET /api/admin/dlq/{dead_letter_id}", authorizeAdmin(s.handleGetDeadLetter))
+		mux.HandleFunc("POST /api/admin/dlq/{dead_letter_id}/replay", authorizeAdmin(s.handleReplayDeadLetter))
+		mux.HandleFunc("DELETE /api/admin/dlq/{dead_letter_id}", authorizeAdmin(s.handleDiscardDeadLetter))
+	}
 	mux.HandleFunc("GET /api/node-types", s.handleGetNodeTypeSchemas)
+	mux.HandleFunc("GET /api/search", s.handleSearch)
 	mux.HandleFunc("GET /api/imagegraphs", s.handleListImageGraphs)
 	mux.HandleFunc("POST /api/imagegraphs", s.handleCreateImageGraph)
-	mux.HandleFunc("GET /api/imagegraphs/{id}", s.handleGetImageGraph)
-	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes", s.handleAddNode)
-	mux.HandleFunc("DELETE /api/imagegraphs/{id}/nodes/{node_id}", s.handleDeleteNode)
-	mux.HandleFunc("PUT /api/imagegraphs/{id}/connectNodes", s.handleConnectNodes)
-	mux.HandleFunc("PUT /api/imagegraphs/{id}/disconnectNodes", s.handleDisconnectNodes)
-	mux.HandleFunc("PATCH /api/imagegraphs/{id}/nodes/{node_id}", s.handleUpdateNode)
-	mux.HandleFunc("PUT /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}", s.handleUploadNodeOutputImage)
+	mux.HandleFunc("GET /api/imagegraphs/{id}", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetImageGraph))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/full", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetFullImageGraph))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/public", s.authorizeGraph(imagegraph.RoleOwner, s.handleSetImageGraphPublic))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/color-management", s.authorizeGraph(imagegraph.RoleEditor, s.handleSetColorManagement))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/performance-mode", s.authorizeGraph(imagegraph.RoleEditor, s.handleSetPerformanceMode))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/parameters", s.authorizeGraph(imagegraph.RoleEditor, s.handleSetParameters))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/seed", s.authorizeGraph(imagegraph.RoleEditor, s.handleSetSeed))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/shares", s.authorizeGraph(imagegraph.RoleViewer, s.handleListShares))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/shares/{user_id}", s.authorizeGraph(imagegraph.RoleOwner, s.handleShareImageGraph))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/shares/{user_id}", s.authorizeGraph(imagegraph.RoleOwner, s.handleUnshareImageGraph))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/tags/{tag}", s.authorizeGraph(imagegraph.RoleEditor, s.handleAddImageGraphTag))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/tags/{tag}", s.authorizeGraph(imagegraph.RoleEditor, s.handleRemoveImageGraphTag))
+	// The external ID is a query parameter because a path wildcard would
+	// overlap the /api/imagegraphs/{id}/... routes
+	mux.HandleFunc("GET /api/imagegraphs/by-external-id", s.handleGetImageGraphByExternalID)
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/by-external-id", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetNodeByExternalID))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/latency", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetPropagationLatency))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/exports", s.authorizeGraph(imagegraph.RoleViewer, s.handleListExports))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/exports/archive", s.authorizeGraph(imagegraph.RoleViewer, s.handleDownloadExportsArchive))
+	if s.webhooks != nil {
+		mux.HandleFunc("GET /api/imagegraphs/{id}/webhooks", s.authorizeGraph(imagegraph.RoleEditor, s.handleListWebhooks))
+		mux.HandleFunc("POST /api/imagegraphs/{id}/webhooks", s.authorizeGraph(imagegraph.RoleEditor, s.handleCreateWebhook))
+		mux.HandleFunc("DELETE /api/imagegraphs/{id}/webhooks/{webhook_id}", s.authorizeGraph(imagegraph.RoleEditor, s.handleDeleteWebhook))
+	}
+	if s.graphLinks != nil {
+		mux.HandleFunc("GET /api/imagegraphs/{id}/links", s.authorizeGraph(imagegraph.RoleViewer, s.handleListGraphLinks))
+		mux.HandleFunc("POST /api/imagegraphs/{id}/links", s.authorizeGraph(imagegraph.RoleEditor, s.handleCreateGraphLink))
+		mux.HandleFunc("DELETE /api/imagegraphs/{id}/links/{link_id}", s.authorizeGraph(imagegraph.RoleEditor, s.handleDeleteGraphLink))
+	}
+	if s.schedules != nil {
+		mux.HandleFunc("GET /api/imagegraphs/{id}/schedule", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetSchedule))
+		mux.HandleFunc("PUT /api/imagegraphs/{id}/schedule", s.authorizeGraph(imagegraph.RoleEditor, s.handleSetSchedule))
+		mux.HandleFunc("DELETE /api/imagegraphs/{id}/schedule", s.authorizeGraph(imagegraph.RoleEditor, s.handleDeleteSchedule))
+		mux.HandleFunc("GET /api/imagegraphs/{id}/schedule/runs", s.authorizeGraph(imagegraph.RoleViewer, s.handleListScheduledRuns))
+	}
+	if s.pipelineRuns != nil {
+		mux.HandleFunc("GET /api/imagegraphs/{id}/runs", s.authorizeGraph(imagegraph.RoleViewer, s.handleListPipelineRuns))
+	}
+	if s.snapshots != nil {
+		mux.HandleFunc("GET /api/imagegraphs/{id}/snapshots", s.authorizeGraph(imagegraph.RoleViewer, s.handleListSnapshots))
+		mux.HandleFunc("POST /api/imagegraphs/{id}/snapshots", s.authorizeGraph(imagegraph.RoleEditor, s.handleCreateSnapshot))
+		mux.HandleFunc("GET /api/imagegraphs/{id}/snapshots/{snapshot_id}", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetSnapshot))
+		mux.HandleFunc("GET /api/imagegraphs/{id}/snapshots/{snapshot_id}/compare", s.authorizeGraph(imagegraph.RoleViewer, s.handleCompareSnapshots))
+	}
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes", s.authorizeGraph(imagegraph.RoleEditor, s.handleAddNode))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/nodes/{node_id}", s.authorizeGraph(imagegraph.RoleEditor, s.handleDeleteNode))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/connectNodes", s.authorizeGraph(imagegraph.RoleEditor, s.handleConnectNodes))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/disconnectNodes", s.authorizeGraph(imagegraph.RoleEditor, s.handleDisconnectNodes))
+	mux.HandleFunc("PATCH /api/imagegraphs/{id}/nodes/{node_id}", s.authorizeGraph(imagegraph.RoleEditor, s.handleUpdateNode))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/validate", s.authorizeGraph(imagegraph.RoleViewer, s.handleValidateImageGraph))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/diagnostics", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetDiagnostics))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/estimate", s.authorizeGraph(imagegraph.RoleViewer, s.handleEstimateImageGraph))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/validate", s.authorizeGraph(imagegraph.RoleViewer, s.handleValidateNodeConfig))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/stats", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetNodeStats))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/upgrade", s.authorizeGraph(imagegraph.RoleEditor, s.handleUpgradeNode))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/diff", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetNodeDiff))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/preview", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetNodePreview))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/duplicate", s.authorizeGraph(imagegraph.RoleViewer, s.handleDuplicateNode))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/sweep", s.authorizeGraph(imagegraph.RoleEditor, s.handleSweepNode))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/inputs", s.authorizeGraph(imagegraph.RoleEditor, s.handleAddNodeInput))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/nodes/{node_id}/inputs/{input_name}", s.authorizeGraph(imagegraph.RoleEditor, s.handleRemoveNodeInput))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/nodes/{node_id}/tags/{tag}", s.authorizeGraph(imagegraph.RoleEditor, s.handleAddNodeTag))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/nodes/{node_id}/tags/{tag}", s.authorizeGraph(imagegraph.RoleEditor, s.handleRemoveNodeTag))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}", s.authorizeGraph(imagegraph.RoleEditor, s.handleUploadNodeOutputImage))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}", s.authorizeGraph(imagegraph.RoleEditor, s.handleClearNodeOutputImage))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/import", s.authorizeGraph(imagegraph.RoleEditor, s.handleImportOutputImage))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/image", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetNodeOutputImage))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/history", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetOutputHistory))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/outputs/{output_name}/history/{image_id}/promote", s.authorizeGraph(imagegraph.RoleEditor, s.handlePromoteOutputVariant))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/inputs", s.authorizeGraph(imagegraph.RoleEditor, s.handleUploadInputs))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/palette", s.authorizeGraph(imagegraph.RoleViewer, s.handleExportPalette))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/nodes/{node_id}/swatches", s.authorizeGraph(imagegraph.RoleViewer, s.handleListPaletteSwatches))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/nodes/{node_id}/swatches", s.authorizeGraph(imagegraph.RoleEditor, s.handleAddPaletteSwatch))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/nodes/{node_id}/swatches/order", s.authorizeGraph(imagegraph.RoleEditor, s.handleReorderPaletteSwatches))
+	mux.HandleFunc("PATCH /api/imagegraphs/{id}/nodes/{node_id}/swatches/{color}", s.authorizeGraph(imagegraph.RoleEditor, s.handleSetPaletteSwatch))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/nodes/{node_id}/swatches/{color}", s.authorizeGraph(imagegraph.RoleEditor, s.handleRemovePaletteSwatch))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/palettes", s.authorizeGraph(imagegraph.RoleEditor, s.handleImportPalette))
 
 	// Image retrieval
 	mux.HandleFunc("GET /api/images/{image_id}", s.handleGetImage)
+	mux.HandleFunc("GET /api/images/{image_id}/metadata", s.handleGetImageMetadata)
+	mux.HandleFunc("GET /api/images/{image_id}/provenance", s.handleGetImageProvenance)
 
 	// Layout routes
-	mux.HandleFunc("GET /api/imagegraphs/{id}/layout", s.handleGetLayout)
-	mux.HandleFunc("PUT /api/imagegraphs/{id}/layout", s.handleUpdateLayout)
+	mux.HandleFunc("GET /api/imagegraphs/{id}/layout", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetLayout))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/layout", s.authorizeGraph(imagegraph.RoleEditor, s.handleUpdateLayout))
+	mux.HandleFunc("POST /api/imagegraphs/{id}/layout/auto", s.authorizeGraph(imagegraph.RoleEditor, s.handleAutoLayout))
 
 	// Viewport routes
-	mux.HandleFunc("GET /api/imagegraphs/{id}/viewport", s.handleGetViewport)
-	mux.HandleFunc("PUT /api/imagegraphs/{id}/viewport", s.handleUpdateViewport)
+	mux.HandleFunc("GET /api/imagegraphs/{id}/viewport", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetViewport))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/viewport", s.authorizeGraph(imagegraph.RoleEditor, s.handleUpdateViewport))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/viewport/bookmarks", s.authorizeGraph(imagegraph.RoleViewer, s.handleListViewportBookmarks))
+	mux.HandleFunc("GET /api/imagegraphs/{id}/viewport/bookmarks/{name}", s.authorizeGraph(imagegraph.RoleViewer, s.handleGetViewportBookmark))
+	mux.HandleFunc("PUT /api/imagegraphs/{id}/viewport/bookmarks/{name}", s.authorizeGraph(imagegraph.RoleEditor, s.handleSaveViewportBookmark))
+	mux.HandleFunc("DELETE /api/imagegraphs/{id}/viewport/bookmarks/{name}", s.authorizeGraph(imagegraph.RoleEditor, s.handleDeleteViewportBookmark))
+
+	// WebSocket routes
+	mux.HandleFunc("GET /api/imagegraphs/{id}/ws", s.authorizeGraph(imagegraph.RoleViewer, s.handleWebSocket))
+	mux.HandleFunc("GET /api/ws", s.handleMultiWebSocket)
+
+	// Public gallery routes, rate limited per client
+	if s.galleryLimiter != nil {
+		mux.HandleFunc("GET /api/gallery", s.galleryLimiter.limit(s.handleGalleryIndex))
+		mux.HandleFunc("GET /api/gallery/{id}", s.galleryLimiter.limit(s.handleGetGalleryGraph))
+		mux.HandleFunc("GET /api/gallery/{id}/images/{image_id}", s.galleryLimiter.limit(s.handleGetGalleryImage))
+	}
 
-	// WebSocket route
-	mux.HandleFunc("GET /api/imagegraphs/{id}/ws", s.handleWebSocket)
+	s.openAPIDocument = s.buildOpenAPIDocument(mux.patterns)
 
 	// Serve static frontend files
-	fs := http.FileServer(http.Dir("../frontend"))
-	mux.Handle("/", fs)
+	mux.Handle("/", frontendHandler(s.frontend))
+
+	var handler http.Handler = mux
+	if s.requestTimeout > 0 {
+		handler = timeoutMiddleware(s.requestTimeout, handler)
+	}
+	if s.busyRetryAfter > 0 {
+		handler = busyRejectionMiddleware(handler)
+	}
+	if s.apiKeys != nil {
+		handler = s.apiKeyMiddleware(handler)
+	}
+	if s.authenticator != nil {
+		handler = authMiddleware(s.authenticator, handler)
+	}
+	if s.cors != nil {
+		handler = corsMiddleware(*s.cors, handler)
+	}
+
+	handler = loggingMiddleware(logger, tracingMiddleware(appMetrics.HTTP.Middleware(handler)))
+	if len(s.trustedProxies) > 0 {
+		handler = forwardedMiddleware(s.trustedProxies, handler)
+	}
 
 	s.server = &http.Server{
 		Addr:    ":" + s.port,
-		Handler: loggingMiddleware(logger, appMetrics.HTTP.Middleware(mux)),
+		Handler: handler,
 	}
 
 	return s
@@ -148,12 +565,10 @@ func (s *HTTPServer) Metrics() *metrics.HTTPMetrics {
 	return s.metrics
 }
 
-type ctxKey string
-
-const requestIDKey ctxKey = "request_id"
-
 // loggingMiddleware wraps handlers with basic structured request logging and
-// request ID propagation.
+// request ID propagation. The request ID, taken from the X-Request-ID header
+// when the client sent one, is echoed in the response and carried in the
+// request context into the commands, events and generation it causes.
 func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -162,8 +577,8 @@ func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
 			reqID = uuid.NewString()
 		}
 
-		ctx := context.WithValue(r.Context(), requestIDKey, reqID)
-		r = r.WithContext(ctx)
+		r = r.WithContext(logging.WithRequestID(r.Context(), reqID))
+		w.Header().Set("X-Request-ID", reqID)
 
 		logger.Info("http_request_start",
 			"method", r.Method,
@@ -193,6 +608,23 @@ func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
 	})
 }
 
+// timeoutMiddleware bounds API requests with a context deadline. Handlers
+// pass the request context on, so work for requests that time out or whose
+// client disconnects is abandoned rather than run to completion.
+func timeoutMiddleware(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || strings.HasSuffix(r.URL.Path, "/ws") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	status       int
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+func (s *HTTPServer) handleListShares(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve image graph"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, listSharesResponse{Shares: mapSharesToResponse(ig.Shares)})
+}
+
+func (s *HTTPServer) handleShareImageGraph(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req shareImageGraphRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	role, err := imagegraph.RoleMapper.To(req.Role)
+	if err != nil || role == imagegraph.RoleOwner {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "role must be viewer or editor"})
+		return
+	}
+
+	userID := r.PathValue("user_id")
+
+	// Ownership can't be granted, and an owner's role can't be replaced
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err == nil && ig.Owner == userID {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "image graph is owned by that user"})
+		return
+	}
+
+	s.handleShareCommand(w, r, application.NewShareImageGraphCommand(imageGraphID, userID, role))
+}
+
+func (s *HTTPServer) handleUnshareImageGraph(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	s.handleShareCommand(w, r, application.NewUnshareImageGraphCommand(imageGraphID, r.PathValue("user_id")))
+}
+
+// handleShareCommand sends a share command and responds with 204 on success.
+// Granting and revoking roles is idempotent.
+func (s *HTTPServer) handleShareCommand(w http.ResponseWriter, r *http.Request, command messages.Command) {
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		if errors.Is(err, application.ErrPermissionDenied) {
+			respondJSON(w, http.StatusForbidden, errorResponse{Error: "permission denied"})
+			return
+		}
+		s.logger.Error("failed to handle share command", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update shares"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,288 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+)
+
+const (
+	maxSnapshotsPerImageGraph = 50
+	maxSnapshotNameLength     = 100
+)
+
+func (s *HTTPServer) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	snapshots, err := s.snapshots.ListByImageGraph(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to list snapshots", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list snapshots"})
+		return
+	}
+
+	response := listSnapshotsResponse{Snapshots: make([]snapshotSummaryResponse, 0, len(snapshots))}
+	for _, snapshot := range snapshots {
+		response.Snapshots = append(response.Snapshots, mapSnapshotToSummaryResponse(snapshot))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleCreateSnapshot records the configs and output images of a graph's
+// nodes under a name. The output images are copied so that the snapshot
+// keeps them after they fall out of their outputs' histories.
+func (s *HTTPServer) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req createSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxSnapshotNameLength {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "name must be between 1 and 100 characters"})
+		return
+	}
+
+	existing, err := s.snapshots.ListByImageGraph(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to list snapshots", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to take snapshot"})
+		return
+	}
+	if len(existing) >= maxSnapshotsPerImageGraph {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "too many snapshots"})
+		return
+	}
+	for _, snapshot := range existing {
+		if snapshot.Name == req.Name {
+			respondJSON(w, http.StatusConflict, errorResponse{Error: "a snapshot with this name already exists"})
+			return
+		}
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to take snapshot"})
+		return
+	}
+
+	snapshot, err := application.NewSnapshot(ig, uuid.NewString(), req.Name, time.Now().UTC())
+	if err != nil {
+		s.logger.Error("failed to take snapshot", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to take snapshot"})
+		return
+	}
+
+	copies, err := s.copySnapshotImages(&snapshot)
+	if err != nil {
+		s.removeImages(copies)
+		s.logger.Error("failed to copy snapshot images", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to take snapshot"})
+		return
+	}
+
+	if err := s.snapshots.Add(r.Context(), snapshot); err != nil {
+		s.removeImages(copies)
+		if errors.Is(err, application.ErrSnapshotNameTaken) {
+			respondJSON(w, http.StatusConflict, errorResponse{Error: "a snapshot with this name already exists"})
+			return
+		}
+		s.logger.Error("failed to add snapshot", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to take snapshot"})
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, mapSnapshotToResponse(snapshot))
+}
+
+func (s *HTTPServer) handleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	snapshot, ok := s.getSnapshot(w, r, imageGraphID, r.PathValue("snapshot_id"))
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapSnapshotToResponse(snapshot))
+}
+
+// handleCompareSnapshots lists how the nodes of the snapshot named by the
+// to query parameter, or of the graph as it is now without one, differ from
+// those of a snapshot
+func (s *HTTPServer) handleCompareSnapshots(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	from, ok := s.getSnapshot(w, r, imageGraphID, r.PathValue("snapshot_id"))
+	if !ok {
+		return
+	}
+
+	var to application.Snapshot
+	if toID := r.URL.Query().Get("to"); toID != "" {
+		to, ok = s.getSnapshot(w, r, imageGraphID, toID)
+		if !ok {
+			return
+		}
+	} else {
+		ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+		if err != nil {
+			if errors.Is(err, application.ErrImageGraphNotFound) {
+				respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+				return
+			}
+			s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to compare snapshots"})
+			return
+		}
+
+		to, err = application.NewSnapshot(ig, "", "", time.Now().UTC())
+		if err != nil {
+			s.logger.Error("failed to capture image graph", "error", err, "id", imageGraphID)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to compare snapshots"})
+			return
+		}
+	}
+
+	response := compareSnapshotsResponse{
+		FromSnapshotID: from.ID,
+		ToSnapshotID:   to.ID,
+		Nodes:          []snapshotNodeChangeResponse{},
+	}
+
+	for _, change := range application.CompareSnapshots(from, to) {
+		switch change.Change {
+		case application.SnapshotNodeAdded:
+			response.Added++
+		case application.SnapshotNodeRemoved:
+			response.Removed++
+		case application.SnapshotNodeChanged:
+			response.Changed++
+		default:
+			response.Unchanged++
+		}
+
+		nodeResp := mapSnapshotNodeChangeToResponse(change)
+
+		// Measure how much changed images differ when both are there
+		for i, output := range change.Outputs {
+			if s.imageComparer == nil || !output.Changed || output.FromImageID.IsNil() || output.ToImageID.IsNil() {
+				continue
+			}
+
+			diff, err := s.imageComparer.CompareImages(r.Context(), output.FromImageID, output.ToImageID)
+			if err != nil {
+				s.logger.Warn("failed to compare snapshot images", "error", err, "id", imageGraphID, "node_id", change.NodeID)
+				continue
+			}
+			nodeResp.Outputs[i].Difference = &nodeDiffResponse{RMSE: diff.RMSE, SSIM: diff.SSIM}
+		}
+
+		response.Nodes = append(response.Nodes, nodeResp)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// getSnapshot retrieves a snapshot of an image graph, responding with an
+// error if it can't
+func (s *HTTPServer) getSnapshot(
+	w http.ResponseWriter,
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	snapshotID string,
+) (
+	application.Snapshot,
+	bool,
+) {
+	// Snapshot IDs are UUIDs, so anything else can't be one
+	if _, err := uuid.Parse(snapshotID); err != nil {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "snapshot not found"})
+		return application.Snapshot{}, false
+	}
+
+	snapshot, err := s.snapshots.Get(r.Context(), imageGraphID, snapshotID)
+	if err != nil {
+		if errors.Is(err, application.ErrSnapshotNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "snapshot not found"})
+			return application.Snapshot{}, false
+		}
+		s.logger.Error("failed to get snapshot", "error", err, "id", imageGraphID, "snapshot_id", snapshotID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to get snapshot"})
+		return application.Snapshot{}, false
+	}
+
+	return snapshot, true
+}
+
+// copySnapshotImages replaces the output images of a snapshot with copies,
+// returning the copies made
+func (s *HTTPServer) copySnapshotImages(snapshot *application.Snapshot) ([]imagegraph.ImageID, error) {
+	var copies []imagegraph.ImageID
+
+	for i := range snapshot.Nodes {
+		for j := range snapshot.Nodes[i].Outputs {
+			output := &snapshot.Nodes[i].Outputs[j]
+			if output.ImageID.IsNil() {
+				continue
+			}
+
+			copyID, err := imagegraph.NewImageID()
+			if err != nil {
+				return copies, err
+			}
+
+			if err := filestorage.CopyImage(s.imageStorage, output.ImageID, copyID); err != nil {
+				return copies, err
+			}
+
+			copies = append(copies, copyID)
+			output.ImageID = copyID
+		}
+	}
+
+	return copies, nil
+}
+
+// removeImages removes images that were stored for a request that failed
+func (s *HTTPServer) removeImages(imageIDs []imagegraph.ImageID) {
+	for _, imageID := range imageIDs {
+		if err := s.imageStorage.Remove(imageID); err != nil {
+			s.logger.Warn("failed to remove image", "error", err, "image_id", imageID)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/domain/ui"
+)
+
+// maxSweepBranches caps how many branches a parameter sweep can create
+const maxSweepBranches = 32
+
+// sweepBranch is a node to create for one value of a parameter sweep
+type sweepBranch struct {
+	name   string
+	config imagegraph.NodeConfig
+}
+
+// handleSweepNode creates a branch for each value of a parameter sweep over
+// a node's config field. Each branch is a copy of the node with the field
+// set to the value, connected to the same upstream outputs as the node, and
+// placed in a row below the node in the requesting user's layout.
+func (s *HTTPServer) handleSweepNode(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	var req sweepNodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	ig, err := s.imageGraphViews.Get(r.Context(), imageGraphID)
+	if err != nil {
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to get image graph", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to sweep node"})
+		return
+	}
+
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "node not found"})
+		return
+	}
+
+	branches, err := sweepBranches(node, req)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	// Branches are connected to the same upstream outputs as the node
+	var connected []*imagegraph.Input
+	for _, name := range node.InputNames() {
+		if input, err := node.Inputs.Get(name); err == nil && input.Connected {
+			connected = append(connected, input)
+		}
+	}
+
+	// Check the limits up front rather than stopping part way through
+	complexity := ig.Complexity()
+	complexity.Nodes += len(branches)
+	complexity.Connections += len(branches) * len(connected)
+	if (s.graphLimits.MaxNodes > 0 && complexity.Nodes > s.graphLimits.MaxNodes) ||
+		(s.graphLimits.MaxConnections > 0 && complexity.Connections > s.graphLimits.MaxConnections) {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "image graph limit reached"})
+		return
+	}
+
+	branchIDs := make([]imagegraph.NodeID, 0, len(branches))
+	for _, branch := range branches {
+		branchID, err := s.addSweepBranch(r, imageGraphID, node.Type, branch, connected)
+		if err != nil {
+			if s.respondGraphBusy(w, err) {
+				return
+			}
+			s.logger.Error("failed to add sweep branch", "error", err, "id", imageGraphID, "name", branch.name)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: fmt.Sprintf("failed to add %s", branch.name)})
+			return
+		}
+		branchIDs = append(branchIDs, branchID)
+	}
+
+	if err := s.layOutSweep(r, imageGraphID, nodeID, branchIDs); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		s.logger.Error("failed to lay out sweep", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update layout"})
+		return
+	}
+
+	nodeIDs := make([]string, 0, len(branchIDs))
+	for _, branchID := range branchIDs {
+		nodeIDs = append(nodeIDs, branchID.String())
+	}
+
+	respondJSON(w, http.StatusCreated, sweepNodeResponse{NodeIDs: nodeIDs})
+}
+
+// sweepBranches builds a config for each value of the sweep, failing if the
+// field isn't in the node's config schema or a value isn't valid for it
+func sweepBranches(node *imagegraph.Node, req sweepNodeRequest) ([]sweepBranch, error) {
+	fieldIndex := slices.IndexFunc(node.Config.Schema(), func(f imagegraph.FieldSchema) bool {
+		return f.Name == req.Field
+	})
+	if fieldIndex < 0 {
+		return nil, fmt.Errorf("%s nodes have no config field %q", imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"), req.Field)
+	}
+	field := node.Config.Schema()[fieldIndex]
+
+	values, err := sweepValues(field, req)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := json.Marshal(node.Config)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config of node %q", node.ID)
+	}
+
+	branches := make([]sweepBranch, 0, len(values))
+	for _, value := range values {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(original, &fields); err != nil {
+			return nil, fmt.Errorf("could not read config of node %q", node.ID)
+		}
+		fields[req.Field] = value
+
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		label := req.Field + "=" + sweepValueLabel(value)
+
+		config := imagegraph.NewNodeConfig(node.Type)
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("%s: invalid value", label)
+		}
+		if err := config.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+
+		name := label
+		if node.Name != "" {
+			name = node.Name + " " + label
+		}
+
+		branches = append(branches, sweepBranch{name: name, config: config})
+	}
+
+	return branches, nil
+}
+
+// sweepValues lists the values of a sweep, either given explicitly or
+// stepping through a numeric range
+func sweepValues(field imagegraph.FieldSchema, req sweepNodeRequest) ([]json.RawMessage, error) {
+	if len(req.Values) > 0 {
+		if len(req.Values) > maxSweepBranches {
+			return nil, fmt.Errorf("too many values (max %d)", maxSweepBranches)
+		}
+		return req.Values, nil
+	}
+
+	if field.Type != imagegraph.FieldTypeInt && field.Type != imagegraph.FieldTypeFloat {
+		return nil, fmt.Errorf("%s is not numeric, values are required", field.Name)
+	}
+
+	step := req.Step
+	if step == 0 {
+		step = 1
+	}
+	if step < 0 || req.To < req.From {
+		return nil, errors.New("from must not be greater than to, and step must be positive")
+	}
+
+	// Allow for rounding errors in the range reaching To
+	count := math.Floor((req.To-req.From)/step+1e-9) + 1
+	if count > maxSweepBranches {
+		return nil, fmt.Errorf("too many values (max %d)", maxSweepBranches)
+	}
+
+	values := make([]json.RawMessage, 0, int(count))
+	for i := range int(count) {
+		value := req.From + float64(i)*step
+		if field.Type == imagegraph.FieldTypeInt {
+			if value != math.Trunc(value) {
+				return nil, fmt.Errorf("%s is an integer, got %g", field.Name, value)
+			}
+			values = append(values, json.RawMessage(strconv.Itoa(int(value))))
+			continue
+		}
+		// Drop the noise of stepping by fractions, 0.30000000000000004 is 0.3
+		value = math.Round(value*1e9) / 1e9
+		values = append(values, json.RawMessage(strconv.FormatFloat(value, 'g', -1, 64)))
+	}
+
+	return values, nil
+}
+
+// sweepValueLabel formats a sweep value for branch names, without the quotes
+// of strings
+func sweepValueLabel(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+	return string(value)
+}
+
+// addSweepBranch adds a branch node and connects its inputs to the same
+// outputs as the swept node's
+func (s *HTTPServer) addSweepBranch(
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeType imagegraph.NodeType,
+	branch sweepBranch,
+	connected []*imagegraph.Input,
+) (
+	imagegraph.NodeID,
+	error,
+) {
+	branchID, err := s.idGenerator.NewNodeID()
+	if err != nil {
+		return branchID, fmt.Errorf("could not generate node ID: %w", err)
+	}
+
+	addCommand := application.NewAddImageGraphNodeCommand(
+		imageGraphID,
+		branchID,
+		nodeType,
+		branch.name,
+		branch.config,
+		"",
+	)
+
+	if err := s.messageBus.HandleCommand(r.Context(), addCommand); err != nil {
+		return branchID, fmt.Errorf("could not add node: %w", err)
+	}
+
+	for _, input := range connected {
+		// Inputs added to the swept node are added to the branch too
+		if !slices.Contains(imagegraph.NodeTypeDefs[nodeType].Inputs, input.Name) {
+			inputCommand := application.NewAddImageGraphNodeInputCommand(imageGraphID, branchID, input.Name)
+
+			if err := s.messageBus.HandleCommand(r.Context(), inputCommand); err != nil {
+				return branchID, fmt.Errorf("could not add input %q: %w", input.Name, err)
+			}
+		}
+
+		connectCommand := application.NewConnectImageGraphNodesCommand(
+			imageGraphID,
+			input.InputConnection.NodeID,
+			input.InputConnection.OutputName,
+			branchID,
+			input.Name,
+		)
+		connectCommand.Transform = input.Transform
+
+		if err := s.messageBus.HandleCommand(r.Context(), connectCommand); err != nil {
+			return branchID, fmt.Errorf("could not connect input %q: %w", input.Name, err)
+		}
+	}
+
+	return branchID, nil
+}
+
+// layOutSweep places the branches of a sweep side by side in a row below the
+// swept node, in the requesting user's layout
+func (s *HTTPServer) layOutSweep(
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	branchIDs []imagegraph.NodeID,
+) error {
+	current, err := s.getUserNodeLayouts(r.Context(), imageGraphID)
+	if err != nil {
+		return err
+	}
+
+	var origin ui.NodeLayout
+	if i := slices.IndexFunc(current, func(nl ui.NodeLayout) bool { return nl.NodeID == nodeID }); i >= 0 {
+		origin = current[i]
+	}
+
+	nodeLayouts := slices.Clone(current)
+	for i, branchID := range branchIDs {
+		nodeLayouts = append(nodeLayouts, ui.NodeLayout{
+			NodeID: branchID,
+			X:      origin.X + float64(i)*ui.AutoLayoutLayerSpacing,
+			Y:      origin.Y + ui.AutoLayoutNodeSpacing,
+		})
+	}
+
+	user, _ := application.UserFromContext(r.Context())
+	command := application.NewUpdateLayoutCommand(imageGraphID, user.ID, nodeLayouts)
+
+	return s.messageBus.HandleCommand(r.Context(), command)
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// parseTagPath parses the image graph ID and tag of a tag route, responding
+// with an error if either is invalid
+func parseTagPath(w http.ResponseWriter, r *http.Request) (imagegraph.ImageGraphID, string, bool) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return imagegraph.ImageGraphID{}, "", false
+	}
+
+	tag, err := imagegraph.NormalizeTag(r.PathValue("tag"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return imagegraph.ImageGraphID{}, "", false
+	}
+
+	return imageGraphID, tag, true
+}
+
+// handleTagCommand sends a tag command and responds with 204 on success.
+// Adding and removing tags is idempotent.
+func (s *HTTPServer) handleTagCommand(w http.ResponseWriter, r *http.Request, command messages.Command) {
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrImageGraphNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "image graph not found"})
+			return
+		}
+		s.logger.Error("failed to handle tag command", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to update tags"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *HTTPServer) handleAddImageGraphTag(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, tag, ok := parseTagPath(w, r)
+	if !ok {
+		return
+	}
+
+	s.handleTagCommand(w, r, application.NewAddImageGraphTagCommand(imageGraphID, tag))
+}
+
+func (s *HTTPServer) handleRemoveImageGraphTag(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, tag, ok := parseTagPath(w, r)
+	if !ok {
+		return
+	}
+
+	s.handleTagCommand(w, r, application.NewRemoveImageGraphTagCommand(imageGraphID, tag))
+}
+
+func (s *HTTPServer) handleAddNodeTag(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, tag, ok := parseTagPath(w, r)
+	if !ok {
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	s.handleTagCommand(w, r, application.NewAddImageGraphNodeTagCommand(imageGraphID, nodeID, tag))
+}
+
+func (s *HTTPServer) handleRemoveNodeTag(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, tag, ok := parseTagPath(w, r)
+	if !ok {
+		return
+	}
+
+	nodeID, err := imagegraph.ParseNodeID(r.PathValue("node_id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid node ID"})
+		return
+	}
+
+	s.handleTagCommand(w, r, application.NewRemoveImageGraphNodeTagCommand(imageGraphID, nodeID, tag))
+}
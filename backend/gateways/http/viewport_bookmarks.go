@@ -0,0 +1,161 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/domain/ui"
+)
+
+func (s *HTTPServer) handleListViewportBookmarks(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	viewport, ok := s.getViewportForBookmarks(w, r, imageGraphID, func() (*ui.Viewport, error) {
+		return s.getUserViewport(r.Context(), imageGraphID)
+	})
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, listViewportBookmarksResponse{
+		Bookmarks: mapViewportBookmarksToResponse(viewport.Bookmarks),
+	})
+}
+
+func (s *HTTPServer) handleGetViewportBookmark(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	viewport, ok := s.getViewportForBookmarks(w, r, imageGraphID, func() (*ui.Viewport, error) {
+		return s.getUserViewport(r.Context(), imageGraphID)
+	})
+	if !ok {
+		return
+	}
+
+	bookmark, ok := viewport.Bookmark(r.PathValue("name"))
+	if !ok {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "bookmark not found"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapViewportBookmarkToResponse(bookmark))
+}
+
+// handleSaveViewportBookmark adds the named bookmark, or replaces it if it
+// already exists
+func (s *HTTPServer) handleSaveViewportBookmark(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req saveViewportBookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	bookmark := ui.ViewportBookmark{
+		Name:    r.PathValue("name"),
+		Zoom:    req.Zoom,
+		PanX:    req.PanX,
+		PanY:    req.PanY,
+		Default: req.Default,
+	}
+
+	if err := bookmark.Validate(); err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	// Bookmarks are saved to the user's own viewport
+	user, _ := application.UserFromContext(r.Context())
+	viewport, ok := s.getViewportForBookmarks(w, r, imageGraphID, func() (*ui.Viewport, error) {
+		return s.viewportViews.Get(r.Context(), imageGraphID, user.ID)
+	})
+	if !ok {
+		return
+	}
+
+	if _, exists := viewport.Bookmark(bookmark.Name); !exists && len(viewport.Bookmarks) >= ui.MaxViewportBookmarks {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "too many bookmarks; remove one first"})
+		return
+	}
+
+	command := application.NewSaveViewportBookmarkCommand(imageGraphID, user.ID, bookmark)
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		s.logger.Error("failed to handle SaveViewportBookmarkCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to save bookmark"})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapViewportBookmarkToResponse(bookmark))
+}
+
+func (s *HTTPServer) handleDeleteViewportBookmark(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	user, _ := application.UserFromContext(r.Context())
+	command := application.NewDeleteViewportBookmarkCommand(imageGraphID, user.ID, r.PathValue("name"))
+
+	if err := s.messageBus.HandleCommand(r.Context(), command); err != nil {
+		if s.respondGraphBusy(w, err) {
+			return
+		}
+		if errors.Is(err, application.ErrViewportBookmarkNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "bookmark not found"})
+			return
+		}
+		s.logger.Error("failed to handle DeleteViewportBookmarkCommand", "error", err)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to remove bookmark"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getViewportForBookmarks gets a viewport with get, or an empty one if it
+// was never saved. It responds with an error and returns false if the
+// viewport couldn't be read.
+func (s *HTTPServer) getViewportForBookmarks(
+	w http.ResponseWriter,
+	r *http.Request,
+	imageGraphID imagegraph.ImageGraphID,
+	get func() (*ui.Viewport, error),
+) (
+	*ui.Viewport,
+	bool,
+) {
+	viewport, err := get()
+	if errors.Is(err, application.ErrViewportNotFound) {
+		return &ui.Viewport{GraphID: imageGraphID}, true
+	}
+	if err != nil {
+		s.logger.Error("failed to get viewport", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to retrieve viewport"})
+		return nil, false
+	}
+
+	return viewport, true
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+const (
+	maxWebhooksPerImageGraph = 10
+	maxWebhookURLLength      = 2048
+	maxWebhookSecretLength   = 256
+	webhookSecretRandomBytes = 32
+)
+
+func (s *HTTPServer) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	webhooks, err := s.webhooks.ListByImageGraph(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to list webhooks", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to list webhooks"})
+		return
+	}
+
+	response := listWebhooksResponse{Webhooks: make([]webhookResponse, 0, len(webhooks))}
+	for _, webhook := range webhooks {
+		response.Webhooks = append(response.Webhooks, mapWebhookToResponse(webhook))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (s *HTTPServer) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Error("failed to parse request body", "error", err)
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body"})
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if !validWebhookURL(req.URL) {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "url must be an absolute http or https URL"})
+		return
+	}
+
+	if len(req.Secret) > maxWebhookSecretLength {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "secret may be at most 256 bytes"})
+		return
+	}
+
+	existing, err := s.webhooks.ListByImageGraph(r.Context(), imageGraphID)
+	if err != nil {
+		s.logger.Error("failed to list webhooks", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to register webhook"})
+		return
+	}
+	if len(existing) >= maxWebhooksPerImageGraph {
+		respondJSON(w, http.StatusUnprocessableEntity, errorResponse{Error: "too many webhooks; remove one first"})
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		random := make([]byte, webhookSecretRandomBytes)
+		if _, err := rand.Read(random); err != nil {
+			s.logger.Error("failed to generate webhook secret", "error", err)
+			respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to register webhook"})
+			return
+		}
+		secret = base64.RawURLEncoding.EncodeToString(random)
+	}
+
+	webhook := application.Webhook{
+		ID:           uuid.NewString(),
+		ImageGraphID: imageGraphID,
+		URL:          req.URL,
+		Secret:       secret,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	if err := s.webhooks.Add(r.Context(), webhook); err != nil {
+		s.logger.Error("failed to add webhook", "error", err, "id", imageGraphID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to register webhook"})
+		return
+	}
+
+	// The secret is only ever returned here
+	respondJSON(w, http.StatusCreated, createWebhookResponse{
+		webhookResponse: mapWebhookToResponse(webhook),
+		Secret:          secret,
+	})
+}
+
+func (s *HTTPServer) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	imageGraphID, err := imagegraph.ParseImageGraphID(r.PathValue("id"))
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid image graph ID"})
+		return
+	}
+
+	webhookID := r.PathValue("webhook_id")
+
+	// Webhook IDs are UUIDs, so anything else can't be one
+	if _, err := uuid.Parse(webhookID); err != nil {
+		respondJSON(w, http.StatusNotFound, errorResponse{Error: "webhook not found"})
+		return
+	}
+
+	if err := s.webhooks.Delete(r.Context(), imageGraphID, webhookID); err != nil {
+		if errors.Is(err, application.ErrWebhookNotFound) {
+			respondJSON(w, http.StatusNotFound, errorResponse{Error: "webhook not found"})
+			return
+		}
+		s.logger.Error("failed to delete webhook", "error", err, "id", imageGraphID, "webhook_id", webhookID)
+		respondJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to remove webhook"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validWebhookURL reports whether a URL can be delivered to
+func validWebhookURL(raw string) bool {
+	if raw == "" || len(raw) > maxWebhookURLLength {
+		return false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
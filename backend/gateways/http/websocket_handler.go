@@ -2,13 +2,24 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 )
 
+// maxWebSocketGraphs caps how many graphs one connection can subscribe to,
+// since each is sent its own heartbeats
+const maxWebSocketGraphs = 50
+
 // handleWebSocket upgrades HTTP connections to WebSocket for real-time updates
 func (s *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	graphIDStr := r.PathValue("id")
@@ -23,17 +34,27 @@ func (s *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Accept the WebSocket connection
-	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		CompressionMode: websocket.CompressionDisabled, // Disable compression for lower latency
-	})
+	subscription, err := parseSubscription(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lastEventID, err := parseLastEventID(r)
+	if err != nil {
+		http.Error(w, "invalid last event ID", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := s.acceptWebSocket(w, r)
 	if err != nil {
 		s.logger.Error("failed to accept websocket", "error", err)
 		return
 	}
 
-	// Register the connection with the notifier
-	s.notifier.Register(graphID, conn)
+	// Register the connection with the notifier, replaying anything missed
+	// since the client's last connection
+	s.notifier.Register(graphID, conn, subscription, lastEventID)
 
 	// Ensure cleanup on exit
 	defer func() {
@@ -47,11 +68,220 @@ func (s *HTTPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Keep the connection alive with ping/pong
 	go s.keepAlive(ctx, conn)
 
+	// Report the graph's complexity so clients can warn before limits are hit
+	go s.sendHeartbeats(ctx, conn, graphID)
+
 	// Wait for the connection to close
 	// We don't expect clients to send messages, so we just wait for disconnect
 	s.waitForClose(ctx, conn)
 }
 
+// handleMultiWebSocket upgrades HTTP connections to a WebSocket that is
+// sent the updates of every graph in the graph_id query parameter, and
+// changes to the graph list when graph_list is true, so a page showing many
+// graphs needs one connection. Messages carry the graph_id they are about.
+func (s *HTTPServer) handleMultiWebSocket(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var graphIDs []imagegraph.ImageGraphID
+	for _, graphIDStr := range splitQueryValues(query["graph_id"]) {
+		graphID, err := imagegraph.ParseImageGraphID(graphIDStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid graph ID %q", graphIDStr), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(graphIDs, graphID) {
+			graphIDs = append(graphIDs, graphID)
+		}
+	}
+	if len(graphIDs) > maxWebSocketGraphs {
+		http.Error(w, fmt.Sprintf("at most %d graphs can be subscribed to", maxWebSocketGraphs), http.StatusBadRequest)
+		return
+	}
+
+	graphList := false
+	if value := query.Get("graph_list"); value != "" {
+		var err error
+		if graphList, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "invalid graph_list", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if len(graphIDs) == 0 && !graphList {
+		http.Error(w, "graph_id or graph_list is required", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := parseSubscription(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lastEventIDs, err := parseGraphLastEventIDs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Each graph is authorized as the single graph endpoint authorizes its
+	// graph, except that graphs that don't exist are rejected up front
+	user, authenticated := application.UserFromContext(r.Context())
+	for _, graphID := range graphIDs {
+		ig, err := s.imageGraphViews.Get(r.Context(), graphID)
+		if err != nil && !errors.Is(err, application.ErrImageGraphNotFound) {
+			s.logger.Error("failed to get image graph", "error", err, "image_graph_id", graphID)
+			http.Error(w, "failed to get image graph", http.StatusInternalServerError)
+			return
+		}
+		if err != nil || (authenticated && !user.CanAccess(ig)) {
+			http.Error(w, fmt.Sprintf("image graph %s not found", graphID), http.StatusNotFound)
+			return
+		}
+	}
+
+	conn, err := s.acceptWebSocket(w, r)
+	if err != nil {
+		s.logger.Error("failed to accept websocket", "error", err)
+		return
+	}
+
+	ctx := r.Context()
+
+	for _, graphID := range graphIDs {
+		s.notifier.Register(graphID, conn, subscription, lastEventIDs[graphID])
+	}
+
+	if graphList {
+		var filter GraphFilter
+		if authenticated && !user.Admin {
+			// Only tell users about graphs they can view, as listing does
+			filter = func(graphID imagegraph.ImageGraphID) bool {
+				ig, err := s.imageGraphViews.Get(ctx, graphID)
+				return err == nil && user.CanAccess(ig)
+			}
+		}
+		s.notifier.RegisterGraphList(conn, filter)
+	}
+
+	defer func() {
+		for _, graphID := range graphIDs {
+			s.notifier.Unregister(graphID, conn)
+		}
+		if graphList {
+			s.notifier.UnregisterGraphList(conn)
+		}
+		conn.Close(websocket.StatusNormalClosure, "")
+	}()
+
+	go s.keepAlive(ctx, conn)
+
+	for _, graphID := range graphIDs {
+		go s.sendHeartbeats(ctx, conn, graphID)
+	}
+
+	s.waitForClose(ctx, conn)
+}
+
+// acceptWebSocket upgrades the request to a WebSocket. Cross-origin
+// upgrades are only accepted from the origins CORS allows.
+func (s *HTTPServer) acceptWebSocket(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	acceptOptions := &websocket.AcceptOptions{
+		CompressionMode: websocket.CompressionDisabled, // Disable compression for lower latency
+	}
+	if s.cors != nil {
+		acceptOptions.OriginPatterns = s.cors.websocketOriginPatterns()
+	}
+
+	return websocket.Accept(w, r, acceptOptions)
+}
+
+// parseSubscription reads the node IDs and message types a client wants to
+// be sent from the node_id and type query parameters, each repeated or
+// comma-separated. Leaving either out subscribes to everything.
+func parseSubscription(r *http.Request) (Subscription, error) {
+	query := r.URL.Query()
+	subscription := Subscription{}
+
+	for _, nodeIDStr := range splitQueryValues(query["node_id"]) {
+		nodeID, err := imagegraph.ParseNodeID(nodeIDStr)
+		if err != nil {
+			return Subscription{}, fmt.Errorf("invalid node ID %q", nodeIDStr)
+		}
+		if subscription.NodeIDs == nil {
+			subscription.NodeIDs = make(map[string]bool)
+		}
+		subscription.NodeIDs[nodeID.String()] = true
+	}
+
+	for _, messageType := range splitQueryValues(query["type"]) {
+		if messageType != MessageTypeNodeUpdate && messageType != MessageTypeLayoutUpdate {
+			return Subscription{}, fmt.Errorf("invalid message type %q", messageType)
+		}
+		if subscription.Types == nil {
+			subscription.Types = make(map[string]bool)
+		}
+		subscription.Types[messageType] = true
+	}
+
+	return subscription, nil
+}
+
+// splitQueryValues splits comma-separated query values, dropping empty ones
+func splitQueryValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				split = append(split, part)
+			}
+		}
+	}
+	return split
+}
+
+// parseLastEventID reads the ID of the last message a reconnecting client
+// saw from the Last-Event-ID header, or the last_event_id query parameter
+// since browsers can't set headers on WebSocket requests. It is zero for new
+// connections.
+func parseLastEventID(r *http.Request) (EventID, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return EventID{}, nil
+	}
+
+	return ParseEventID(value)
+}
+
+// parseGraphLastEventIDs reads the ID of the last message a reconnecting
+// client saw of each graph from last_event_id values written as
+// graph_id:id, repeated or comma-separated
+func parseGraphLastEventIDs(r *http.Request) (map[imagegraph.ImageGraphID]EventID, error) {
+	lastEventIDs := make(map[imagegraph.ImageGraphID]EventID)
+
+	for _, value := range splitQueryValues(r.URL.Query()["last_event_id"]) {
+		graphIDStr, idStr, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid last event ID %q, expected graph_id:id", value)
+		}
+		graphID, err := imagegraph.ParseImageGraphID(graphIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid graph ID %q", graphIDStr)
+		}
+		id, err := ParseEventID(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last event ID %q", idStr)
+		}
+		lastEventIDs[graphID] = id
+	}
+
+	return lastEventIDs, nil
+}
+
 // keepAlive sends periodic pings to keep the connection alive
 func (s *HTTPServer) keepAlive(ctx context.Context, conn *websocket.Conn) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -70,6 +300,56 @@ func (s *HTTPServer) keepAlive(ctx context.Context, conn *websocket.Conn) {
 	}
 }
 
+// heartbeatInterval is how often clients are sent the complexity counters
+// of the graph they are viewing
+const heartbeatInterval = 5 * time.Second
+
+// sendHeartbeats sends the graph's complexity counters and limits to the
+// client when it connects and then periodically until it disconnects
+func (s *HTTPServer) sendHeartbeats(
+	ctx context.Context,
+	conn *websocket.Conn,
+	graphID imagegraph.ImageGraphID,
+) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sendHeartbeat(ctx, conn, graphID); err != nil {
+			s.logger.Debug("heartbeat failed, connection likely closed", "error", err)
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *HTTPServer) sendHeartbeat(
+	ctx context.Context,
+	conn *websocket.Conn,
+	graphID imagegraph.ImageGraphID,
+) error {
+	ig, err := s.imageGraphViews.Get(ctx, graphID)
+	if err != nil {
+		return err
+	}
+
+	messageBytes, err := json.Marshal(WebSocketMessage{
+		GraphID: graphID.String(),
+		Type:    "heartbeat",
+		Data:    mapComplexityToResponse(ig.Complexity(), s.graphLimits),
+	})
+	if err != nil {
+		return err
+	}
+
+	return conn.Write(ctx, websocket.MessageText, messageBytes)
+}
+
 // waitForClose waits for the WebSocket connection to close
 func (s *HTTPServer) waitForClose(ctx context.Context, conn *websocket.Conn) {
 	for {
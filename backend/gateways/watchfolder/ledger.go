@@ -0,0 +1,86 @@
+package watchfolder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ledgerFileName is the file in the watched directory that records what has
+// been ingested
+const ledgerFileName = ".artwork-ingested.json"
+
+// ledgerEntry records the version of a file that was ingested, and the graph
+// and image it became or why it couldn't be ingested
+type ledgerEntry struct {
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	IngestedAt time.Time `json:"ingested_at"`
+	GraphID    string    `json:"graph_id,omitempty"`
+	ImageID    string    `json:"image_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ledger tracks the files of the watched directory that have been ingested,
+// by file name
+type ledger struct {
+	path    string
+	entries map[string]ledgerEntry
+}
+
+func loadLedger(path string) (*ledger, error) {
+	l := &ledger{path: path, entries: make(map[string]ledgerEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read ingestion ledger: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &l.entries); err != nil {
+		return nil, fmt.Errorf("could not parse ingestion ledger %s: %w", path, err)
+	}
+
+	return l, nil
+}
+
+// ingested reports whether this version of the file has been ingested
+func (l *ledger) ingested(name string, info os.FileInfo) bool {
+	entry, ok := l.entries[name]
+	return ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime())
+}
+
+// record saves an ingested file to the ledger. The ledger is replaced
+// atomically so a crash can't leave it truncated.
+func (l *ledger) record(name string, entry ledgerEntry) error {
+	l.entries[name] = entry
+
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode ingestion ledger: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ledgerFileName+".*")
+	if err != nil {
+		return fmt.Errorf("could not write ingestion ledger: %w", err)
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write ingestion ledger: %w", err)
+	}
+
+	return nil
+}
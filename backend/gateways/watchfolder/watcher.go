@@ -0,0 +1,379 @@
+// Package watchfolder ingests images dropped into a directory, setting each
+// on a designated input node or on a new graph instantiated from a pipeline
+// spec.
+package watchfolder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+	"github.com/dmpettyp/artwork/pipeline"
+)
+
+// imageExtensions are the files in the watched directory that are ingested
+var imageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+type imageSaver interface {
+	Save(imageID imagegraph.ImageID, imageData []byte) error
+}
+
+// Config configures a Watcher. Either NodeID and GraphID or Template must be
+// set.
+type Config struct {
+	// Dir is the directory watched for new images
+	Dir string
+
+	// GraphID and NodeID designate the input node that each new image is
+	// set on, replacing the previous one
+	GraphID imagegraph.ImageGraphID
+	NodeID  imagegraph.NodeID
+
+	// Output is the output of the designated node that images are set on,
+	// "original" by default
+	Output imagegraph.OutputName
+
+	// Template instantiates a new graph for each image, named after the
+	// template and the image file. The image is set on TemplateNode, or the
+	// template's only input node without an image.
+	Template     *pipeline.Spec
+	TemplateNode string
+
+	// Owner owns the graphs instantiated from the template
+	Owner string
+
+	// PollInterval is how often the directory is scanned, 2s by default
+	PollInterval time.Duration
+
+	// SettleTime is how long a file's size and modification time must stay
+	// unchanged before it's ingested, so that files still being written or
+	// copied aren't picked up part way through. Files are always seen
+	// unchanged by two scans before they're ingested.
+	SettleTime time.Duration
+}
+
+// Watcher polls a directory for new and changed images and ingests them once
+// they've stopped changing. Ingested files are recorded in a ledger file in
+// the directory, so they aren't ingested again after a restart unless they
+// change.
+type Watcher struct {
+	logger       *slog.Logger
+	messageBus   application.CommandHandler
+	imageStorage imageSaver
+	config       Config
+	templateNode string
+
+	ledger  *ledger
+	pending map[string]pendingFile
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// pendingFile is a file waiting to stop changing before it's ingested
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// NewWatcher creates a Watcher for the configured directory, loading the
+// ledger of files it has already ingested
+func NewWatcher(
+	logger *slog.Logger,
+	messageBus application.CommandHandler,
+	imageStorage imageSaver,
+	config Config,
+) (
+	*Watcher,
+	error,
+) {
+	if config.Dir == "" {
+		return nil, errors.New("a watch directory is required")
+	}
+
+	designated := !config.GraphID.IsNil() || !config.NodeID.IsNil()
+
+	switch {
+	case designated && config.Template != nil:
+		return nil, errors.New("images can be set on a designated node or a template graph, not both")
+	case designated && (config.GraphID.IsNil() || config.NodeID.IsNil()):
+		return nil, errors.New("a designated node needs both a graph ID and a node ID")
+	case !designated && config.Template == nil:
+		return nil, errors.New("a designated node or a template is required")
+	}
+
+	var templateNode string
+	if config.Template != nil {
+		if err := config.Template.CheckNodes(); err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+
+		var err error
+		if templateNode, err = config.Template.InputNode(config.TemplateNode); err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+
+	if config.Output == "" {
+		config.Output = "original"
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 2 * time.Second
+	}
+	if config.SettleTime < 0 {
+		config.SettleTime = 0
+	}
+
+	info, err := os.Stat(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not watch %s: %w", config.Dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("could not watch %s: not a directory", config.Dir)
+	}
+
+	ledger, err := loadLedger(filepath.Join(config.Dir, ledgerFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		logger:       logger,
+		messageBus:   messageBus,
+		imageStorage: imageStorage,
+		config:       config,
+		templateNode: templateNode,
+		ledger:       ledger,
+		pending:      make(map[string]pendingFile),
+	}, nil
+}
+
+// Start scans the directory in the background until Stop is called
+func (w *Watcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	w.logger.Info("watching for images", "dir", w.config.Dir)
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			w.poll(ctx, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops scanning, waiting for an ingestion in progress to finish
+func (w *Watcher) Stop(ctx context.Context) error {
+	w.logger.Info("stopping folder watcher")
+
+	w.cancel()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop folder watcher: %w", ctx.Err())
+	}
+}
+
+// poll scans the directory once, ingesting the files that have been
+// unchanged for the settle time
+func (w *Watcher) poll(ctx context.Context, now time.Time) {
+	entries, err := os.ReadDir(w.config.Dir)
+	if err != nil {
+		w.logger.Error("failed to scan watch directory", "error", err, "dir", w.config.Dir)
+		return
+	}
+
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Dotfiles include the ledger and the temporary files many tools
+		// write before renaming into place
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !imageExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+
+		seen[name] = true
+
+		if w.ledger.ingested(name, info) {
+			delete(w.pending, name)
+			continue
+		}
+
+		pending, ok := w.pending[name]
+		if !ok || pending.size != info.Size() || !pending.modTime.Equal(info.ModTime()) {
+			w.pending[name] = pendingFile{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+
+		if now.Sub(pending.since) < w.config.SettleTime {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		delete(w.pending, name)
+		w.ingest(ctx, name, info)
+	}
+
+	for name := range w.pending {
+		if !seen[name] {
+			delete(w.pending, name)
+		}
+	}
+}
+
+// ingest sets a settled file's image and records the outcome in the ledger.
+// Failures are recorded too, so a file that can't be ingested is only tried
+// again once it changes.
+func (w *Watcher) ingest(ctx context.Context, name string, info os.FileInfo) {
+	entry := ledgerEntry{
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+		IngestedAt: time.Now().UTC(),
+	}
+
+	graphID, imageID, err := w.setImage(ctx, name)
+	if err != nil {
+		entry.Error = err.Error()
+		w.logger.Error("failed to ingest image", "error", err, "file", name)
+	} else {
+		entry.GraphID = graphID.String()
+		entry.ImageID = imageID.String()
+		w.logger.Info("ingested image", "file", name, "graph_id", graphID, "image_id", imageID)
+	}
+
+	if err := w.ledger.record(name, entry); err != nil {
+		w.logger.Error("failed to record ingested image", "error", err, "file", name)
+	}
+}
+
+func (w *Watcher) setImage(ctx context.Context, name string) (imagegraph.ImageGraphID, imagegraph.ImageID, error) {
+	imageData, err := os.ReadFile(filepath.Join(w.config.Dir, name))
+	if err != nil {
+		return imagegraph.ImageGraphID{}, imagegraph.ImageID{}, fmt.Errorf("could not read image: %w", err)
+	}
+
+	if w.config.Template == nil {
+		imageID, err := w.setNodeImage(ctx, w.config.GraphID, w.config.NodeID, w.config.Output, name, imageData)
+		return w.config.GraphID, imageID, err
+	}
+
+	return w.instantiateTemplate(ctx, name, imageData)
+}
+
+// instantiateTemplate builds a new graph from the template and sets the image
+// on its input node, after any images the template names
+func (w *Watcher) instantiateTemplate(
+	ctx context.Context,
+	name string,
+	imageData []byte,
+) (
+	imagegraph.ImageGraphID,
+	imagegraph.ImageID,
+	error,
+) {
+	spec := w.config.Template
+	graphID := imagegraph.MustNewImageGraphID()
+	graphName := spec.Name + " " + strings.TrimSuffix(name, filepath.Ext(name))
+
+	nodeIDs, err := pipeline.Build(ctx, w.messageBus, spec, graphID, graphName, w.config.Owner)
+	if err != nil {
+		return graphID, imagegraph.ImageID{}, err
+	}
+
+	var imageID imagegraph.ImageID
+
+	for _, node := range spec.Nodes {
+		switch {
+		case node.Name == w.templateNode:
+			imageID, err = w.setNodeImage(ctx, graphID, nodeIDs[node.Name], imagegraph.OutputName(node.ImageOutput()), name, imageData)
+		case node.Image != "":
+			var templateImage []byte
+			if templateImage, err = os.ReadFile(node.Image); err == nil {
+				_, err = w.setNodeImage(ctx, graphID, nodeIDs[node.Name], imagegraph.OutputName(node.ImageOutput()), filepath.Base(node.Image), templateImage)
+			}
+		}
+		if err != nil {
+			return graphID, imagegraph.ImageID{}, fmt.Errorf("could not set image of node %q: %w", node.Name, err)
+		}
+	}
+
+	return graphID, imageID, nil
+}
+
+// setNodeImage stores an image and sets it on a node output, naming the
+// node after the file as uploads through the API do
+func (w *Watcher) setNodeImage(
+	ctx context.Context,
+	graphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName imagegraph.OutputName,
+	fileName string,
+	imageData []byte,
+) (
+	imagegraph.ImageID,
+	error,
+) {
+	imageID := imagegraph.MustNewImageID()
+
+	if err := filestorage.SaveUpload(w.imageStorage, imageID, fileName, imageData); err != nil {
+		return imageID, fmt.Errorf("could not save image: %w", err)
+	}
+
+	command := application.NewSetImageGraphNodeOutputImageCommand(
+		graphID,
+		nodeID,
+		outputName,
+		imageID,
+		0, // allow command handler to resolve to current node version
+	)
+
+	if err := w.messageBus.HandleCommand(ctx, command); err != nil {
+		return imageID, fmt.Errorf("could not set node output image: %w", err)
+	}
+
+	setNameCommand := application.NewSetImageGraphNodeNameCommand(graphID, nodeID, fileName)
+
+	if err := w.messageBus.HandleCommand(ctx, setNameCommand); err != nil {
+		return imageID, fmt.Errorf("could not name node: %w", err)
+	}
+
+	return imageID, nil
+}
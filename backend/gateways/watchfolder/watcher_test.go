@@ -0,0 +1,301 @@
+package watchfolder
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/inmem"
+	"github.com/dmpettyp/artwork/pipeline"
+)
+
+type mockImageStorage struct {
+	mu   sync.Mutex
+	data map[imagegraph.ImageID][]byte
+}
+
+func (m *mockImageStorage) Save(imageID imagegraph.ImageID, imageData []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[imageID] = imageData
+	return nil
+}
+
+type testApp struct {
+	uow     *inmem.UnitOfWork
+	mb      *messagebus.MessageBus
+	storage *mockImageStorage
+}
+
+func setupTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	uow, err := inmem.NewUnitOfWork()
+	if err != nil {
+		t.Fatalf("failed to create unit of work: %v", err)
+	}
+
+	mb := messagebus.New()
+
+	if _, err := application.NewImageGraphCommandHandlers(mb, nil, uow); err != nil {
+		t.Fatalf("failed to create command handlers: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go mb.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		mb.Stop()
+	})
+
+	return &testApp{
+		uow:     uow,
+		mb:      mb,
+		storage: &mockImageStorage{data: make(map[imagegraph.ImageID][]byte)},
+	}
+}
+
+// addInputNode creates a graph with an input node, returning their IDs
+func (app *testApp) addInputNode(t *testing.T) (imagegraph.ImageGraphID, imagegraph.NodeID) {
+	t.Helper()
+
+	ctx := context.Background()
+	graphID := imagegraph.MustNewImageGraphID()
+	nodeID := imagegraph.MustNewNodeID()
+
+	if err := app.mb.HandleCommand(ctx, application.NewCreateImageGraphCommand(graphID, "watched", "", "")); err != nil {
+		t.Fatalf("failed to create graph: %v", err)
+	}
+
+	command := application.NewAddImageGraphNodeCommand(graphID, nodeID, imagegraph.NodeTypeInput, "input", imagegraph.NewNodeConfigInput(), "")
+	if err := app.mb.HandleCommand(ctx, command); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	return graphID, nodeID
+}
+
+func (app *testApp) newWatcher(t *testing.T, config Config) *Watcher {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	w, err := NewWatcher(logger, app.mb, app.storage, config)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	return w
+}
+
+func (app *testApp) nodeOutputImage(t *testing.T, graphID imagegraph.ImageGraphID, nodeID imagegraph.NodeID) (imagegraph.ImageID, string) {
+	t.Helper()
+
+	ig, err := app.uow.ImageGraphViews.Get(context.Background(), graphID)
+	if err != nil {
+		t.Fatalf("failed to get graph: %v", err)
+	}
+
+	node, ok := ig.Nodes.Get(nodeID)
+	if !ok {
+		t.Fatalf("node %s not found", nodeID)
+	}
+
+	imageID, err := node.Outputs.GetImage("original")
+	if err != nil {
+		t.Fatalf("failed to get output image: %v", err)
+	}
+	return imageID, node.Name
+}
+
+func writeImage(t *testing.T, path string, width int) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, 1))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write image: %v", err)
+	}
+}
+
+func TestWatcherDesignatedNode(t *testing.T) {
+	app := setupTestApp(t)
+	graphID, nodeID := app.addInputNode(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	config := Config{Dir: dir, GraphID: graphID, NodeID: nodeID, SettleTime: time.Second}
+	w := app.newWatcher(t, config)
+
+	start := time.Now()
+	writeImage(t, filepath.Join(dir, "photo.png"), 4)
+	writeImage(t, filepath.Join(dir, ".partial.png"), 4)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	t.Run("waits for files to settle", func(t *testing.T) {
+		w.poll(ctx, start)
+		w.poll(ctx, start.Add(500*time.Millisecond))
+
+		if imageID, _ := app.nodeOutputImage(t, graphID, nodeID); !imageID.IsNil() {
+			t.Fatal("expected no image before the file settled")
+		}
+	})
+
+	t.Run("ingests settled files", func(t *testing.T) {
+		w.poll(ctx, start.Add(time.Second))
+
+		imageID, name := app.nodeOutputImage(t, graphID, nodeID)
+		if imageID.IsNil() {
+			t.Fatal("expected the image to be set")
+		}
+		if name != "photo.png" {
+			t.Errorf("expected the node to be named after the file, got %q", name)
+		}
+		if len(app.storage.data) != 1 {
+			t.Errorf("expected only photo.png to be stored, got %d images", len(app.storage.data))
+		}
+		if _, err := os.Stat(filepath.Join(dir, ledgerFileName)); err != nil {
+			t.Errorf("expected a ledger to be written: %v", err)
+		}
+	})
+
+	t.Run("doesn't ingest files again", func(t *testing.T) {
+		w.poll(ctx, start.Add(5*time.Second))
+		w.poll(ctx, start.Add(10*time.Second))
+
+		restarted := app.newWatcher(t, config)
+		restarted.poll(ctx, start.Add(15*time.Second))
+		restarted.poll(ctx, start.Add(20*time.Second))
+
+		if len(app.storage.data) != 1 {
+			t.Errorf("expected photo.png to be ingested once, got %d images", len(app.storage.data))
+		}
+	})
+
+	t.Run("restarts settling when files change", func(t *testing.T) {
+		before, _ := app.nodeOutputImage(t, graphID, nodeID)
+
+		writeImage(t, filepath.Join(dir, "photo.png"), 8)
+		w.poll(ctx, start.Add(30*time.Second))
+
+		writeImage(t, filepath.Join(dir, "photo.png"), 16)
+		w.poll(ctx, start.Add(31*time.Second))
+		w.poll(ctx, start.Add(31*time.Second+500*time.Millisecond))
+
+		if after, _ := app.nodeOutputImage(t, graphID, nodeID); after != before {
+			t.Fatal("expected no image while the file was changing")
+		}
+
+		w.poll(ctx, start.Add(32*time.Second))
+
+		if after, _ := app.nodeOutputImage(t, graphID, nodeID); after == before {
+			t.Error("expected the changed file to be ingested")
+		}
+	})
+}
+
+func TestWatcherTemplate(t *testing.T) {
+	app := setupTestApp(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	spec := &pipeline.Spec{
+		Name: "poster",
+		Tags: []string{"ingested"},
+		Nodes: []pipeline.Node{
+			{Name: "photo", Type: "input"},
+			{Name: "small", Type: "resize", Config: []byte(`{"width": 2, "interpolation": "Bilinear"}`)},
+		},
+		Connections: []pipeline.Connection{{From: "photo.original", To: "small.original"}},
+	}
+
+	w := app.newWatcher(t, Config{Dir: dir, Template: spec})
+
+	start := time.Now()
+	writeImage(t, filepath.Join(dir, "one.png"), 4)
+	writeImage(t, filepath.Join(dir, "two.jpg"), 4)
+	w.poll(ctx, start)
+	w.poll(ctx, start)
+
+	page, err := app.uow.ImageGraphViews.List(ctx, application.ListImageGraphsOptions{Sort: application.SortImageGraphsByName})
+	if err != nil {
+		t.Fatalf("failed to list graphs: %v", err)
+	}
+	if len(page.ImageGraphs) != 2 {
+		t.Fatalf("expected a graph per image, got %d", len(page.ImageGraphs))
+	}
+
+	for i, want := range []string{"poster one", "poster two"} {
+		ig, err := app.uow.ImageGraphViews.Get(ctx, page.ImageGraphs[i].ID)
+		if err != nil {
+			t.Fatalf("failed to get graph: %v", err)
+		}
+		if ig.Name != want {
+			t.Errorf("expected graph %q, got %q", want, ig.Name)
+		}
+		if len(ig.Nodes) != 2 {
+			t.Errorf("expected graph %q to have the template's nodes, got %d", ig.Name, len(ig.Nodes))
+		}
+		if !ig.Tags.Has("ingested") {
+			t.Errorf("expected graph %q to have the template's tags", ig.Name)
+		}
+
+		var set bool
+		for _, node := range ig.Nodes {
+			if node.Type == imagegraph.NodeTypeInput {
+				imageID, _ := node.Outputs.GetImage("original")
+				set = !imageID.IsNil()
+			}
+		}
+		if !set {
+			t.Errorf("expected graph %q to have its input image set", ig.Name)
+		}
+	}
+}
+
+func TestNewWatcherErrors(t *testing.T) {
+	app := setupTestApp(t)
+	graphID, nodeID := app.addInputNode(t)
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	template := &pipeline.Spec{Name: "t", Nodes: []pipeline.Node{{Name: "in", Type: "input"}}}
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"no directory", Config{GraphID: graphID, NodeID: nodeID}},
+		{"missing directory", Config{Dir: filepath.Join(dir, "missing"), GraphID: graphID, NodeID: nodeID}},
+		{"no target", Config{Dir: dir}},
+		{"graph without node", Config{Dir: dir, GraphID: graphID}},
+		{"node and template", Config{Dir: dir, GraphID: graphID, NodeID: nodeID, Template: template}},
+		{"unknown template node", Config{Dir: dir, Template: template, TemplateNode: "other"}},
+		{"invalid template config", Config{Dir: dir, Template: &pipeline.Spec{
+			Name:  "t",
+			Nodes: []pipeline.Node{{Name: "in", Type: "input"}, {Name: "r", Type: "resize", Config: []byte(`{"width": "wide"}`)}},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWatcher(logger, app.mb, app.storage, tt.config); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
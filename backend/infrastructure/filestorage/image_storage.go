@@ -1,9 +1,13 @@
 package filestorage
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 )
@@ -16,7 +20,103 @@ type ImageStorage interface {
 	Exists(imageID imagegraph.ImageID) (bool, error)
 }
 
-// FilesystemImageStorage implements ImageStorage using the local filesystem
+// ImageMetadataStorage is implemented by image storages that keep a metadata
+// sidecar alongside each image
+type ImageMetadataStorage interface {
+	SaveWithMetadata(imageID imagegraph.ImageID, imageData []byte, metadata imagegraph.ImageMetadata) error
+	GetMetadata(imageID imagegraph.ImageID) (imagegraph.ImageMetadata, error)
+}
+
+// ImageProvenanceStorage is implemented by image storages that record how
+// generated images were produced
+type ImageProvenanceStorage interface {
+	SaveProvenance(imageID imagegraph.ImageID, provenance imagegraph.ImageProvenance) error
+	// GetProvenance returns nil for images with no provenance recorded
+	GetProvenance(imageID imagegraph.ImageID) (*imagegraph.ImageProvenance, error)
+}
+
+// ImageInventory is implemented by image storages that can list the images
+// they hold
+type ImageInventory interface {
+	// Inventory returns the size in bytes of every stored image
+	Inventory() (map[imagegraph.ImageID]int64, error)
+}
+
+// imageSaver is the part of an ImageStorage that saves images
+type imageSaver interface {
+	Save(imageID imagegraph.ImageID, imageData []byte) error
+}
+
+// SaveUpload saves an image read from a file, recording the file's name in
+// its metadata if the storage keeps metadata
+func SaveUpload(storage imageSaver, imageID imagegraph.ImageID, fileName string, imageData []byte) error {
+	metadataStorage, ok := storage.(ImageMetadataStorage)
+	if !ok {
+		return storage.Save(imageID, imageData)
+	}
+
+	metadata := ReadImageMetadata(imageData)
+	metadata.Filename = fileName
+
+	return metadataStorage.SaveWithMetadata(imageID, imageData, metadata)
+}
+
+// CopyImage stores a copy of an image under another ID, along with its
+// metadata and provenance if the storage keeps them
+func CopyImage(storage ImageStorage, from imagegraph.ImageID, to imagegraph.ImageID) error {
+	imageData, err := storage.Get(from)
+	if err != nil {
+		return fmt.Errorf("failed to copy image %s: %w", from, err)
+	}
+
+	if err := copyProvenance(storage, from, to); err != nil {
+		return fmt.Errorf("failed to copy image %s: %w", from, err)
+	}
+
+	metadataStorage, ok := storage.(ImageMetadataStorage)
+	if !ok {
+		return storage.Save(to, imageData)
+	}
+
+	metadata, err := metadataStorage.GetMetadata(from)
+	if err != nil {
+		return fmt.Errorf("failed to copy image %s: %w", from, err)
+	}
+
+	return metadataStorage.SaveWithMetadata(to, imageData, metadata)
+}
+
+// copyProvenance records the provenance of an image, if it has any, as the
+// provenance of its copy, so the copy traces back to the node that
+// generated it
+func copyProvenance(storage ImageStorage, from imagegraph.ImageID, to imagegraph.ImageID) error {
+	provenanceStorage, ok := storage.(ImageProvenanceStorage)
+	if !ok {
+		return nil
+	}
+
+	provenance, err := provenanceStorage.GetProvenance(from)
+	if err != nil || provenance == nil {
+		return err
+	}
+
+	return provenanceStorage.SaveProvenance(to, *provenance)
+}
+
+// imageMetadataDTO is the JSON form of an image's metadata sidecar
+type imageMetadataDTO struct {
+	Filename    string    `json:"filename,omitempty"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	DPI         float64   `json:"dpi,omitempty"`
+	CapturedAt  time.Time `json:"captured_at,omitzero"`
+	CameraMake  string    `json:"camera_make,omitempty"`
+	CameraModel string    `json:"camera_model,omitempty"`
+}
+
+// FilesystemImageStorage implements ImageStorage using the local filesystem.
+// Each image has a JSON metadata sidecar next to it, and generated images a
+// JSON provenance sidecar.
 type FilesystemImageStorage struct {
 	baseDir string
 }
@@ -33,8 +133,29 @@ func NewFilesystemImageStorage(baseDir string) (*FilesystemImageStorage, error)
 	}, nil
 }
 
-// Save stores an image to the filesystem
+// Save stores an image to the filesystem, with the metadata read from its
+// data
 func (s *FilesystemImageStorage) Save(imageID imagegraph.ImageID, imageData []byte) error {
+	return s.SaveWithMetadata(imageID, imageData, ReadImageMetadata(imageData))
+}
+
+// SaveWithMetadata stores an image and its metadata sidecar to the
+// filesystem
+func (s *FilesystemImageStorage) SaveWithMetadata(
+	imageID imagegraph.ImageID,
+	imageData []byte,
+	metadata imagegraph.ImageMetadata,
+) error {
+	metadataJSON, err := json.Marshal(imageMetadataDTO(metadata))
+	if err != nil {
+		return fmt.Errorf("failed to marshal image metadata: %w", err)
+	}
+
+	// Write the sidecar first so that an image is never without one
+	if err := os.WriteFile(s.getMetadataPath(imageID), metadataJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write image metadata file: %w", err)
+	}
+
 	filePath := s.getFilePath(imageID)
 
 	// Write the file
@@ -45,6 +166,66 @@ func (s *FilesystemImageStorage) Save(imageID imagegraph.ImageID, imageData []by
 	return nil
 }
 
+// GetMetadata retrieves the metadata of an image from its sidecar. Images
+// stored before sidecars existed get the metadata read from their data.
+func (s *FilesystemImageStorage) GetMetadata(imageID imagegraph.ImageID) (imagegraph.ImageMetadata, error) {
+	data, err := os.ReadFile(s.getMetadataPath(imageID))
+	if os.IsNotExist(err) {
+		imageData, err := s.Get(imageID)
+		if err != nil {
+			return imagegraph.ImageMetadata{}, err
+		}
+		return ReadImageMetadata(imageData), nil
+	}
+	if err != nil {
+		return imagegraph.ImageMetadata{}, fmt.Errorf("failed to read image metadata file: %w", err)
+	}
+
+	var dto imageMetadataDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return imagegraph.ImageMetadata{}, fmt.Errorf("failed to unmarshal image metadata: %w", err)
+	}
+
+	return imagegraph.ImageMetadata(dto), nil
+}
+
+// SaveProvenance stores how an image was produced in its provenance
+// sidecar
+func (s *FilesystemImageStorage) SaveProvenance(
+	imageID imagegraph.ImageID,
+	provenance imagegraph.ImageProvenance,
+) error {
+	data, err := json.Marshal(provenance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal image provenance: %w", err)
+	}
+
+	if err := os.WriteFile(s.getProvenancePath(imageID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write image provenance file: %w", err)
+	}
+
+	return nil
+}
+
+// GetProvenance retrieves how an image was produced from its provenance
+// sidecar, or nil if it has none
+func (s *FilesystemImageStorage) GetProvenance(imageID imagegraph.ImageID) (*imagegraph.ImageProvenance, error) {
+	data, err := os.ReadFile(s.getProvenancePath(imageID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image provenance file: %w", err)
+	}
+
+	var provenance imagegraph.ImageProvenance
+	if err := json.Unmarshal(data, &provenance); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal image provenance: %w", err)
+	}
+
+	return &provenance, nil
+}
+
 // Get retrieves an image from the filesystem
 func (s *FilesystemImageStorage) Get(imageID imagegraph.ImageID) ([]byte, error) {
 	filePath := s.getFilePath(imageID)
@@ -61,6 +242,20 @@ func (s *FilesystemImageStorage) Get(imageID imagegraph.ImageID) ([]byte, error)
 	return data, nil
 }
 
+// Open returns a reader for an image on the filesystem so that it can be
+// streamed without loading it into memory. The caller must close the reader.
+func (s *FilesystemImageStorage) Open(imageID imagegraph.ImageID) (io.ReadCloser, error) {
+	file, err := os.Open(s.getFilePath(imageID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("image not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to open image file: %w", err)
+	}
+
+	return file, nil
+}
+
 // Exists checks if an image exists in storage
 func (s *FilesystemImageStorage) Exists(imageID imagegraph.ImageID) (bool, error) {
 	filePath := s.getFilePath(imageID)
@@ -86,12 +281,72 @@ func (s *FilesystemImageStorage) Remove(imageID imagegraph.ImageID) error {
 		return fmt.Errorf("failed to remove image %q: %w", imageID, err)
 	}
 
+	if err := os.Remove(s.getMetadataPath(imageID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove metadata of image %q: %w", imageID, err)
+	}
+
+	if err := os.Remove(s.getProvenancePath(imageID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove provenance of image %q: %w", imageID, err)
+	}
+
 	return nil
 }
 
+// Copy saves a copy of an image, with its metadata and provenance, under
+// another ID
+func (s *FilesystemImageStorage) Copy(from imagegraph.ImageID, to imagegraph.ImageID) error {
+	return CopyImage(s, from, to)
+}
+
+// Inventory returns the size in bytes of every image in the storage
+// directory, leaving out their metadata sidecars
+func (s *FilesystemImageStorage) Inventory() (map[imagegraph.ImageID]int64, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	inventory := make(map[imagegraph.ImageID]int64)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".png")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		imageID, err := imagegraph.ParseImageID(name)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to stat image %q: %w", imageID, err)
+		}
+
+		inventory[imageID] = info.Size()
+	}
+
+	return inventory, nil
+}
+
 // getFilePath returns the filesystem path for a given image ID
 func (s *FilesystemImageStorage) getFilePath(imageID imagegraph.ImageID) string {
 	// Store images as {baseDir}/{imageID}.png
 	// In the future, we could store the extension in metadata or detect it from content
 	return filepath.Join(s.baseDir, imageID.String()+".png")
 }
+
+// getMetadataPath returns the filesystem path of an image's metadata sidecar
+func (s *FilesystemImageStorage) getMetadataPath(imageID imagegraph.ImageID) string {
+	return filepath.Join(s.baseDir, imageID.String()+".json")
+}
+
+// getProvenancePath returns the filesystem path of an image's provenance
+// sidecar
+func (s *FilesystemImageStorage) getProvenancePath(imageID imagegraph.ImageID) string {
+	return filepath.Join(s.baseDir, imageID.String()+".provenance.json")
+}
@@ -0,0 +1,268 @@
+package filestorage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math"
+	"strings"
+	"time"
+
+	// Register the formats images can be uploaded in
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// exifTimeLayout is how EXIF records dates and times
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// EXIF tags read into image metadata
+const (
+	exifTagMake             = 0x010f
+	exifTagModel            = 0x0110
+	exifTagXResolution      = 0x011a
+	exifTagResolutionUnit   = 0x0128
+	exifTagDateTime         = 0x0132
+	exifTagExifIFD          = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+	jfifHeader   = []byte("JFIF\x00")
+)
+
+// ReadImageMetadata reads what the data of an image says about it: its
+// size, its DPI from PNG pHYs chunks or JPEG JFIF headers, and the DPI,
+// camera and capture time of EXIF data in PNGs and JPEGs. Anything that
+// can't be read is left empty.
+func ReadImageMetadata(data []byte) imagegraph.ImageMetadata {
+	var metadata imagegraph.ImageMetadata
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		metadata.Width = config.Width
+		metadata.Height = config.Height
+	}
+
+	var exif []byte
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		metadata.DPI, exif = readPNGMetadata(data)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		metadata.DPI, exif = readJPEGMetadata(data)
+	}
+
+	if exif != nil {
+		readEXIF(exif, &metadata)
+	}
+
+	return metadata
+}
+
+// readPNGMetadata reads the DPI of a PNG's pHYs chunk and the EXIF data of
+// its eXIf chunk, both of which come before the image data
+func readPNGMetadata(data []byte) (float64, []byte) {
+	var dpi float64
+	var exif []byte
+
+	for i := len(pngSignature); i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		typ := string(data[i+4 : i+8])
+		if length < 0 || i+12+length > len(data) || typ == "IDAT" {
+			break
+		}
+		chunk := data[i+8 : i+8+length]
+		i += 12 + length
+
+		switch typ {
+		case "pHYs":
+			// Pixels per unit on each axis, and a unit that is either
+			// unknown or the meter
+			if len(chunk) == 9 && chunk[8] == 1 {
+				dpi = roundDPI(float64(binary.BigEndian.Uint32(chunk[:4])) * 0.0254)
+			}
+		case "eXIf":
+			exif = chunk
+		}
+	}
+
+	return dpi, exif
+}
+
+// readJPEGMetadata reads the DPI of a JPEG's JFIF header and the EXIF data
+// of its APP1 segment, which come before the image data
+func readJPEGMetadata(data []byte) (float64, []byte) {
+	var dpi float64
+	var exif []byte
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			break
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			i++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		i += 2 + length
+
+		switch {
+		case marker == 0xe0 && bytes.HasPrefix(segment, jfifHeader) && len(segment) >= 12:
+			// A version, a unit of inches or centimeters, and the density
+			// on each axis
+			density := float64(binary.BigEndian.Uint16(segment[8:10]))
+			switch segment[7] {
+			case 1:
+				dpi = density
+			case 2:
+				dpi = roundDPI(density * 2.54)
+			}
+		case marker == 0xe1 && bytes.HasPrefix(segment, exifHeader):
+			exif = segment[len(exifHeader):]
+		}
+	}
+
+	return dpi, exif
+}
+
+// readEXIF reads the camera, capture time and DPI from EXIF data, a TIFF
+// structure whose first directory describes the image and points to a
+// directory of photo details
+func readEXIF(tiff []byte, metadata *imagegraph.ImageMetadata) {
+	if len(tiff) < 8 {
+		return
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd0 := readTIFFDirectory(tiff, order, order.Uint32(tiff[4:8]))
+
+	metadata.CameraMake = ifd0.ascii(exifTagMake)
+	metadata.CameraModel = ifd0.ascii(exifTagModel)
+
+	if resolution := ifd0.rational(exifTagXResolution); resolution > 0 {
+		switch ifd0.short(exifTagResolutionUnit) {
+		case 3: // centimeters
+			metadata.DPI = roundDPI(resolution * 2.54)
+		default: // inches
+			metadata.DPI = roundDPI(resolution)
+		}
+	}
+
+	captured := ifd0.ascii(exifTagDateTime)
+	if offset := ifd0.long(exifTagExifIFD); offset > 0 {
+		exifIFD := readTIFFDirectory(tiff, order, offset)
+		if original := exifIFD.ascii(exifTagDateTimeOriginal); original != "" {
+			captured = original
+		}
+	}
+	if capturedAt, err := time.Parse(exifTimeLayout, captured); err == nil {
+		metadata.CapturedAt = capturedAt
+	}
+}
+
+// tiffDirectory holds the entries of a TIFF image file directory by tag
+type tiffDirectory struct {
+	tiff    []byte
+	order   binary.ByteOrder
+	entries map[uint16][]byte
+}
+
+// readTIFFDirectory reads the 12 byte entries of the directory at offset:
+// a tag, a type, a count and either the value or the offset of the value
+func readTIFFDirectory(tiff []byte, order binary.ByteOrder, offset uint32) tiffDirectory {
+	dir := tiffDirectory{tiff: tiff, order: order, entries: map[uint16][]byte{}}
+
+	start := int(offset)
+	if start < 0 || start+2 > len(tiff) {
+		return dir
+	}
+
+	count := int(order.Uint16(tiff[start : start+2]))
+	for i := range count {
+		entry := start + 2 + 12*i
+		if entry+12 > len(tiff) {
+			break
+		}
+		dir.entries[order.Uint16(tiff[entry:entry+2])] = tiff[entry : entry+12]
+	}
+
+	return dir
+}
+
+// value returns the bytes of an entry's value, which are stored in the
+// entry itself if they fit in 4 bytes
+func (d tiffDirectory) value(tag uint16, size int) []byte {
+	entry, ok := d.entries[tag]
+	if !ok {
+		return nil
+	}
+
+	length := int(d.order.Uint32(entry[4:8])) * size
+	if length <= 4 {
+		return entry[8 : 8+length]
+	}
+
+	offset := int(d.order.Uint32(entry[8:12]))
+	if offset < 0 || length < 0 || offset+length > len(d.tiff) {
+		return nil
+	}
+	return d.tiff[offset : offset+length]
+}
+
+func (d tiffDirectory) ascii(tag uint16) string {
+	return strings.TrimSpace(strings.TrimRight(string(d.value(tag, 1)), "\x00"))
+}
+
+func (d tiffDirectory) short(tag uint16) int {
+	if value := d.value(tag, 2); len(value) >= 2 {
+		return int(d.order.Uint16(value))
+	}
+	return 0
+}
+
+func (d tiffDirectory) long(tag uint16) uint32 {
+	if value := d.value(tag, 4); len(value) >= 4 {
+		return d.order.Uint32(value)
+	}
+	return 0
+}
+
+func (d tiffDirectory) rational(tag uint16) float64 {
+	value := d.value(tag, 8)
+	if len(value) < 8 {
+		return 0
+	}
+	numerator, denominator := d.order.Uint32(value[:4]), d.order.Uint32(value[4:8])
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// roundDPI drops the noise of converting between units, 2835 pixels per
+// meter is 72.01 DPI
+func roundDPI(dpi float64) float64 {
+	return math.Round(dpi*100) / 100
+}
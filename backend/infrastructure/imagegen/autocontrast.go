@@ -0,0 +1,173 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+func (ig *ImageGen) GenerateOutputsForAutoContrastNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	inputImageID imagegraph.ImageID,
+	mode string,
+	clipPercent float64,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeAutoContrast)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeAutoContrast, imageGraphID, nodeID, nodeVersion,
+		"mode", mode,
+		"clip_percent", clipPercent,
+	)
+
+	frames, err := ig.loadFrames(ctx, inputImageID)
+	if err != nil {
+		return err
+	}
+
+	adjusted, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		switch mode {
+		case "equalize":
+			return equalizeHistogram(img), nil
+		case "stretch":
+			return stretchContrast(img, clipPercent), nil
+		default:
+			return nil, fmt.Errorf("unsupported auto contrast mode %q", mode)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, adjusted.first())
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for auto contrast node: %w", err)
+	}
+
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "adjusted", nodeVersion, adjusted, "")
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for auto contrast node: %w", err)
+	}
+
+	return nil
+}
+
+// channelHistograms counts the values of the red, green and blue channels of
+// the visible pixels of an image
+func channelHistograms(img *image.NRGBA) [3][256]int {
+	var histograms [3][256]int
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] == 0 {
+			continue
+		}
+		for c := range 3 {
+			histograms[c][img.Pix[i+c]]++
+		}
+	}
+	return histograms
+}
+
+// applyChannelLUTs maps the red, green and blue channels of an image through
+// a lookup table each, keeping alpha
+func applyChannelLUTs(img *image.NRGBA, luts [3][256]uint8) *image.NRGBA {
+	out := image.NewNRGBA(img.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := range 3 {
+			out.Pix[i+c] = luts[c][img.Pix[i+c]]
+		}
+		out.Pix[i+3] = img.Pix[i+3]
+	}
+	return out
+}
+
+// stretchContrast rescales each channel so that its values span the full
+// range, after clipping clipPercent of the darkest and brightest values
+func stretchContrast(img image.Image, clipPercent float64) image.Image {
+	nrgba := toNRGBA(img)
+	histograms := channelHistograms(nrgba)
+
+	var luts [3][256]uint8
+	for c, histogram := range histograms {
+		total := 0
+		for _, count := range histogram {
+			total += count
+		}
+		clip := int(float64(total) * clipPercent / 100)
+
+		low, high := 0, 255
+		for seen := 0; low < 255; low++ {
+			seen += histogram[low]
+			if seen > clip {
+				break
+			}
+		}
+		for seen := 0; high > 0; high-- {
+			seen += histogram[high]
+			if seen > clip {
+				break
+			}
+		}
+
+		for v := range 256 {
+			if high <= low {
+				luts[c][v] = uint8(v)
+				continue
+			}
+			scaled := float64(v-low) * 255 / float64(high-low)
+			luts[c][v] = uint8(math.Round(math.Max(0, math.Min(255, scaled))))
+		}
+	}
+
+	return applyChannelLUTs(nrgba, luts)
+}
+
+// equalizeHistogram spreads the values of each channel so that its
+// histogram is as flat as possible
+func equalizeHistogram(img image.Image) image.Image {
+	nrgba := toNRGBA(img)
+	histograms := channelHistograms(nrgba)
+
+	var luts [3][256]uint8
+	for c, histogram := range histograms {
+		// The cumulative counts start from the first value present, so the
+		// darkest value maps to 0
+		var cdf [256]int
+		running, cdfMin := 0, 0
+		for v, count := range histogram {
+			running += count
+			cdf[v] = running
+			if cdfMin == 0 {
+				cdfMin = running
+			}
+		}
+
+		for v := range 256 {
+			if running == cdfMin {
+				luts[c][v] = uint8(v)
+				continue
+			}
+			scaled := float64(cdf[v]-cdfMin) * 255 / float64(running-cdfMin)
+			luts[c][v] = uint8(math.Round(math.Max(0, scaled)))
+		}
+	}
+
+	return applyChannelLUTs(nrgba, luts)
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	return nrgba
+}
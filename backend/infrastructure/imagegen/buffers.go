@@ -0,0 +1,42 @@
+package imagegen
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// pixelBuffers reuses the pixel buffers of intermediate images, which are
+// as large as the images being generated. Buffers are only pooled while a
+// generator reads them; images handed on to be saved always own their
+// pixels.
+var pixelBuffers = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// borrowRGBA returns an image's pixels as an *image.RGBA to read directly,
+// drawing them into a pooled buffer unless the image already is one.
+// release returns the buffer to the pool; the RGBA must not be used after.
+func borrowRGBA(img image.Image) (rgba *image.RGBA, release func()) {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, func() {}
+	}
+
+	bounds := img.Bounds()
+	size := 4 * bounds.Dx() * bounds.Dy()
+
+	buf := pixelBuffers.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+
+	rgba = &image.RGBA{
+		Pix:    (*buf)[:size],
+		Stride: 4 * bounds.Dx(),
+		Rect:   bounds,
+	}
+	// Src overwrites every pixel, so what the buffer held before is gone
+	draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+
+	return rgba, func() { pixelBuffers.Put(buf) }
+}
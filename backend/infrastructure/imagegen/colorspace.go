@@ -0,0 +1,161 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Rec. 709 luminance weights of linear RGB, as used by sRGB
+const (
+	lumaR = 0.2126
+	lumaG = 0.7152
+	lumaB = 0.0722
+)
+
+func (ig *ImageGen) GenerateOutputsForColorSpaceNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	inputImageID imagegraph.ImageID,
+	from string,
+	to string,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeColorSpace)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeColorSpace, imageGraphID, nodeID, nodeVersion,
+		"from", from,
+		"to", to,
+	)
+
+	frames, err := ig.loadFrames(ctx, inputImageID)
+	if err != nil {
+		return err
+	}
+
+	converted, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return convertColorSpace(img, from, to)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, converted.first())
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for color space node: %w", err)
+	}
+
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "converted", nodeVersion, converted, "")
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for color space node: %w", err)
+	}
+
+	return nil
+}
+
+// convertColorSpace decodes the colors of an image encoded in the from color
+// space to linear RGB and encodes them in the to color space. The result
+// has 16 bits per channel so that linear values keep their precision in the
+// shadows.
+func convertColorSpace(img image.Image, from, to string) (image.Image, error) {
+	decode, ok := colorSpaceDecoders[from]
+	if !ok {
+		return nil, fmt.Errorf("unsupported color space %q", from)
+	}
+	encode, ok := colorSpaceEncoders[to]
+	if !ok {
+		return nil, fmt.Errorf("unsupported color space %q", to)
+	}
+
+	bounds := img.Bounds()
+	src := image.NewNRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	out := image.NewNRGBA64(src.Bounds())
+	for i := 0; i < len(src.Pix); i += 8 {
+		var rgb [3]float64
+		for c := range 3 {
+			rgb[c] = float64(uint16(src.Pix[i+2*c])<<8|uint16(src.Pix[i+2*c+1])) / 0xffff
+		}
+
+		rgb = encode(decode(rgb))
+
+		for c := range 3 {
+			v := uint16(math.Round(math.Max(0, math.Min(1, rgb[c])) * 0xffff))
+			out.Pix[i+2*c] = uint8(v >> 8)
+			out.Pix[i+2*c+1] = uint8(v)
+		}
+		out.Pix[i+6], out.Pix[i+7] = src.Pix[i+6], src.Pix[i+7]
+	}
+
+	return out, nil
+}
+
+// inLightSpace applies an operation to an image in linear light if linear is
+// set, converting the image from sRGB beforehand and back afterwards, or to
+// the image as it is otherwise. Filters that average colors darken fine
+// detail and edges when applied to gamma-encoded values.
+func inLightSpace(img image.Image, linear bool, op func(image.Image) image.Image) image.Image {
+	if !linear {
+		return op(img)
+	}
+
+	// The conversions can't fail with known color spaces
+	linearImg, _ := convertColorSpace(img, "srgb", "linear_rgb")
+	result, _ := convertColorSpace(op(linearImg), "linear_rgb", "srgb")
+
+	// Back in sRGB, 8 bits per channel are enough again
+	return toNRGBA(result)
+}
+
+// colorSpaceDecoders convert colors in each color space to linear RGB
+var colorSpaceDecoders = map[string]func([3]float64) [3]float64{
+	"srgb": func(c [3]float64) [3]float64 {
+		return [3]float64{srgbToLinear(c[0]), srgbToLinear(c[1]), srgbToLinear(c[2])}
+	},
+	"linear_rgb": func(c [3]float64) [3]float64 {
+		return c
+	},
+	"grayscale": func(c [3]float64) [3]float64 {
+		y := srgbToLinear(grayValue(c))
+		return [3]float64{y, y, y}
+	},
+	"grayscale_linear": func(c [3]float64) [3]float64 {
+		y := grayValue(c)
+		return [3]float64{y, y, y}
+	},
+}
+
+// colorSpaceEncoders convert linear RGB colors to each color space
+var colorSpaceEncoders = map[string]func([3]float64) [3]float64{
+	"srgb": func(c [3]float64) [3]float64 {
+		return [3]float64{linearToSRGB(c[0]), linearToSRGB(c[1]), linearToSRGB(c[2])}
+	},
+	"linear_rgb": func(c [3]float64) [3]float64 {
+		return c
+	},
+	"grayscale": func(c [3]float64) [3]float64 {
+		y := linearToSRGB(lumaR*c[0] + lumaG*c[1] + lumaB*c[2])
+		return [3]float64{y, y, y}
+	},
+	"grayscale_linear": func(c [3]float64) [3]float64 {
+		y := lumaR*c[0] + lumaG*c[1] + lumaB*c[2]
+		return [3]float64{y, y, y}
+	},
+}
+
+// grayValue reads the value of a grayscale color, averaging the channels in
+// case the image isn't quite gray
+func grayValue(c [3]float64) float64 {
+	return (c[0] + c[1] + c[2]) / 3
+}
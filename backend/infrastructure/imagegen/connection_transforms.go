@@ -0,0 +1,136 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Connections can transform the images they pass on. Rather than storing
+// transformed images, generators are handed a placeholder image ID for each
+// transformed input, which loads as the input's image with the transform
+// applied. Transformed images are decoded once and cached like any other.
+
+type transformedInputsKey struct{}
+
+// TransformedInput is the image a placeholder image ID loads as: ImageID
+// with Transform applied
+type TransformedInput struct {
+	ImageID   imagegraph.ImageID
+	Transform string
+}
+
+// WithTransformedInputs returns a context that makes generation on it load
+// each placeholder image ID in inputs as its TransformedInput
+func WithTransformedInputs(
+	ctx context.Context,
+	inputs map[imagegraph.ImageID]TransformedInput,
+) context.Context {
+	return context.WithValue(ctx, transformedInputsKey{}, inputs)
+}
+
+func transformedInput(ctx context.Context, imageID imagegraph.ImageID) (TransformedInput, bool) {
+	inputs, _ := ctx.Value(transformedInputsKey{}).(map[imagegraph.ImageID]TransformedInput)
+	input, ok := inputs[imageID]
+	return input, ok
+}
+
+// loadTransformedFrames loads the image of a transformed input as a frame
+// sequence, transforming each frame
+func (ig *ImageGen) loadTransformedFrames(ctx context.Context, input TransformedInput) (*frameSequence, error) {
+	key := decodeCacheKey{imageID: input.ImageID, allFrames: true, transform: input.Transform}
+	if cached, ok := ig.decodeCache.get(key); ok {
+		ig.observeDecodeCache(true)
+		countLoadedPixels(ctx, cached.first().Bounds())
+		return cached, nil
+	}
+
+	frames, err := ig.loadFrames(ctx, input.ImageID)
+	if err != nil {
+		return nil, err
+	}
+
+	transformed, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return transformImage(img, input.Transform)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ig.decodeCache.put(key, transformed)
+
+	return transformed, nil
+}
+
+// loadTransformedImage loads the image of a transformed input as a still
+// image
+func (ig *ImageGen) loadTransformedImage(ctx context.Context, input TransformedInput) (image.Image, error) {
+	key := decodeCacheKey{imageID: input.ImageID, transform: input.Transform}
+	if cached, ok := ig.decodeCache.get(key); ok {
+		ig.observeDecodeCache(true)
+		countLoadedPixels(ctx, cached.first().Bounds())
+		return cached.first(), nil
+	}
+
+	img, err := ig.loadImage(ctx, input.ImageID)
+	if err != nil {
+		return nil, err
+	}
+
+	transformed, err := transformImage(img, input.Transform)
+	if err != nil {
+		return nil, err
+	}
+
+	ig.decodeCache.put(key, stillFrame(transformed))
+
+	return transformed, nil
+}
+
+// transformImage applies a connection transform to an image
+func transformImage(img image.Image, transform string) (image.Image, error) {
+	var channel func(r, g, b, a uint8) (uint8, uint8, uint8, uint8)
+
+	switch transform {
+	case imagegraph.ConnectionTransformNone:
+		return img, nil
+	case imagegraph.ConnectionTransformInvert:
+		channel = func(r, g, b, a uint8) (uint8, uint8, uint8, uint8) {
+			return 255 - r, 255 - g, 255 - b, a
+		}
+	case imagegraph.ConnectionTransformAlpha:
+		channel = func(_, _, _, a uint8) (uint8, uint8, uint8, uint8) {
+			return a, a, a, 255
+		}
+	case imagegraph.ConnectionTransformLuminance:
+		channel = func(r, g, b, a uint8) (uint8, uint8, uint8, uint8) {
+			l := uint8(math.Round(lumaR*float64(r) + lumaG*float64(g) + lumaB*float64(b)))
+			return l, l, l, a
+		}
+	case imagegraph.ConnectionTransformRed:
+		channel = func(r, _, _, a uint8) (uint8, uint8, uint8, uint8) {
+			return r, r, r, a
+		}
+	case imagegraph.ConnectionTransformGreen:
+		channel = func(_, g, _, a uint8) (uint8, uint8, uint8, uint8) {
+			return g, g, g, a
+		}
+	case imagegraph.ConnectionTransformBlue:
+		channel = func(_, _, b, a uint8) (uint8, uint8, uint8, uint8) {
+			return b, b, b, a
+		}
+	default:
+		return nil, fmt.Errorf("unsupported connection transform %q", transform)
+	}
+
+	out := toNRGBA(img)
+	for i := 0; i < len(out.Pix); i += 4 {
+		p := out.Pix[i : i+4 : i+4]
+		p[0], p[1], p[2], p[3] = channel(p[0], p[1], p[2], p[3])
+	}
+
+	return out, nil
+}
@@ -0,0 +1,172 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/nfnt/resize"
+)
+
+// ContactSheetImage is an image placed on a contact sheet and the label
+// drawn under it
+type ContactSheetImage struct {
+	ImageID imagegraph.ImageID
+	Label   string
+}
+
+// contactSheetLayout places the cells of a contact sheet
+type contactSheetLayout struct {
+	columns     int
+	rows        int
+	cellSize    int
+	spacing     int
+	labelHeight int
+	labelScale  int
+}
+
+func newContactSheetLayout(images, columns, cellSize, spacing int, labels bool) contactSheetLayout {
+	layout := contactSheetLayout{
+		columns:  min(columns, images),
+		rows:     (images + columns - 1) / columns,
+		cellSize: cellSize,
+		spacing:  spacing,
+	}
+
+	if labels {
+		layout.labelScale = max(1, cellSize/128)
+		layout.labelHeight = (glyphHeight + 4) * layout.labelScale
+	}
+
+	return layout
+}
+
+// size is the size of the whole sheet
+func (l contactSheetLayout) size() image.Point {
+	return image.Pt(
+		l.columns*l.cellSize+(l.columns+1)*l.spacing,
+		l.rows*(l.cellSize+l.labelHeight)+(l.rows+1)*l.spacing,
+	)
+}
+
+// cell is the square the i-th image is fitted into
+func (l contactSheetLayout) cell(i int) image.Rectangle {
+	column, row := i%l.columns, i/l.columns
+	min := image.Pt(
+		l.spacing+column*(l.cellSize+l.spacing),
+		l.spacing+row*(l.cellSize+l.labelHeight+l.spacing),
+	)
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(l.cellSize, l.cellSize))}
+}
+
+func (ig *ImageGen) GenerateOutputsForContactSheetNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	images []ContactSheetImage,
+	columns int,
+	cellSize int,
+	spacing int,
+	labels bool,
+	background string,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeContactSheet)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeContactSheet, imageGraphID, nodeID, nodeVersion,
+		"images", len(images),
+		"columns", columns,
+		"cell_size", cellSize,
+		"spacing", spacing,
+		"labels", labels,
+		"background", background,
+	)
+
+	if len(images) == 0 {
+		return fmt.Errorf("could not generate outputs for contact sheet node: no input images")
+	}
+
+	bg, err := parseHexColor(background)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for contact sheet node: %w", err)
+	}
+
+	layout := newContactSheetLayout(len(images), columns, cellSize, spacing, labels)
+
+	size := layout.size()
+	if err := ig.imageLimits.Check(imagegraph.ImageSize{Width: size.X, Height: size.Y}); err != nil {
+		return fmt.Errorf("could not generate outputs for contact sheet node: %w", err)
+	}
+
+	sheet := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	for i, sheetImage := range images {
+		img, err := ig.loadImage(ctx, sheetImage.ImageID)
+		if err != nil {
+			return err
+		}
+
+		cell := layout.cell(i)
+		fitted := fitToCell(img, layout.cellSize)
+		offset := cell.Size().Sub(fitted.Bounds().Size()).Div(2)
+		draw.Draw(sheet, fitted.Bounds().Add(cell.Min.Add(offset)), fitted, fitted.Bounds().Min, draw.Over)
+
+		if labels {
+			drawCellLabel(sheet, layout, cell, sheetImage.Label, labelColor(bg))
+		}
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, sheet)
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for contact sheet node: %w", err)
+	}
+
+	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "sheet", nodeVersion, sheet)
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for contact sheet node: %w", err)
+	}
+
+	return nil
+}
+
+// fitToCell scales an image to fit a square of cellSize, keeping its aspect
+// ratio
+func fitToCell(img image.Image, cellSize int) image.Image {
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return img
+	}
+
+	if size.X >= size.Y {
+		return resize.Resize(uint(cellSize), 0, img, resize.Bilinear)
+	}
+	return resize.Resize(0, uint(cellSize), img, resize.Bilinear)
+}
+
+// drawCellLabel centers a label in the space under a cell
+func drawCellLabel(sheet *image.RGBA, layout contactSheetLayout, cell image.Rectangle, label string, col color.RGBA) {
+	label = fitText(label, layout.cellSize, layout.labelScale)
+	x := cell.Min.X + (layout.cellSize-textWidth(label, layout.labelScale))/2
+	y := cell.Max.Y + 2*layout.labelScale
+
+	drawText(sheet, image.Pt(x, y), label, col, layout.labelScale)
+}
+
+// labelColor is black on light backgrounds and white on dark ones
+func labelColor(bg color.Color) color.RGBA {
+	r, g, b, _ := bg.RGBA()
+	luminance := lumaR*float64(r>>8) + lumaG*float64(g>>8) + lumaB*float64(b>>8)
+	if luminance > 128 {
+		return color.RGBA{A: 255}
+	}
+	return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+}
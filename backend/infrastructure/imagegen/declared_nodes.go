@@ -0,0 +1,304 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/anthonynsimon/bild/blur"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+const processorTimeout = 2 * time.Minute
+
+// NodeOperation is how the nodes of a registered node type generate their
+// outputs: with one of the built-in operations, applied to the primary input
+// and saved as the primary output, or by posting their inputs to an external
+// processor, once for each output
+type NodeOperation struct {
+	// Builtin names a built-in operation, see BuiltinOperations
+	Builtin string
+	// Params are the parameters of the built-in operation. Config fields
+	// named like a parameter override it.
+	Params map[string]any
+
+	// ProcessorURL is the URL of an external processor, which is posted a
+	// multipart form with a file for each connected input, named after the
+	// input, a config field holding the node's config as JSON and an output
+	// field naming the output to produce, and responds with the image
+	ProcessorURL string
+}
+
+// builtinOperation transforms an image with parameters taken from the
+// node's config and the operation's declared params
+type builtinOperation func(img image.Image, params operationParams) (image.Image, error)
+
+// builtinOperations are the operations registered node types can name
+var builtinOperations = map[string]builtinOperation{
+	// radius (default 2), linear
+	"blur": func(img image.Image, params operationParams) (image.Image, error) {
+		radius := params.float("radius", 2)
+		return inLightSpace(img, params.bool("linear"), func(img image.Image) image.Image {
+			return blur.Gaussian(img, radius)
+		}), nil
+	},
+	// mode ("stretch" or "equalize", default "stretch"), clip_percent
+	"auto_contrast": func(img image.Image, params operationParams) (image.Image, error) {
+		switch mode := params.string("mode", "stretch"); mode {
+		case "stretch":
+			return stretchContrast(img, params.float("clip_percent", 0)), nil
+		case "equalize":
+			return equalizeHistogram(img), nil
+		default:
+			return nil, fmt.Errorf("unsupported auto contrast mode %q", mode)
+		}
+	},
+	// from and to, color spaces of the Color Space node (default "srgb")
+	"color_space": func(img image.Image, params operationParams) (image.Image, error) {
+		return convertColorSpace(img, params.string("from", "srgb"), params.string("to", "srgb"))
+	},
+	"grayscale": func(img image.Image, params operationParams) (image.Image, error) {
+		return convertColorSpace(img, "srgb", "grayscale")
+	},
+	"invert": func(img image.Image, params operationParams) (image.Image, error) {
+		inverted := toNRGBA(img)
+		for i := 0; i < len(inverted.Pix); i += 4 {
+			inverted.Pix[i] = 255 - inverted.Pix[i]
+			inverted.Pix[i+1] = 255 - inverted.Pix[i+1]
+			inverted.Pix[i+2] = 255 - inverted.Pix[i+2]
+		}
+		return inverted, nil
+	},
+}
+
+// BuiltinOperations lists the names of the built-in operations
+func BuiltinOperations() []string {
+	names := make([]string, 0, len(builtinOperations))
+	for name := range builtinOperations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// operationParams are the parameters of a built-in operation, as decoded
+// from JSON
+type operationParams map[string]any
+
+func (p operationParams) float(name string, def float64) float64 {
+	if value, ok := p[name].(float64); ok {
+		return value
+	}
+	return def
+}
+
+func (p operationParams) string(name string, def string) string {
+	if value, ok := p[name].(string); ok {
+		return value
+	}
+	return def
+}
+
+func (p operationParams) bool(name string) bool {
+	value, _ := p[name].(bool)
+	return value
+}
+
+// DeclaredNode is a node of a registered node type to generate the outputs
+// of
+type DeclaredNode struct {
+	// NodeType names the node's type
+	NodeType     string
+	Operation    NodeOperation
+	Inputs       map[imagegraph.InputName]imagegraph.ImageID
+	PrimaryInput imagegraph.InputName
+	Outputs      []imagegraph.OutputName
+	Config       map[string]any
+}
+
+func (ig *ImageGen) GenerateOutputsForDeclaredNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	node DeclaredNode,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, node.NodeType)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, node.NodeType, imageGraphID, nodeID, nodeVersion,
+		"operation", node.Operation.Builtin,
+		"processor", node.Operation.ProcessorURL,
+	)
+
+	if node.Operation.Builtin != "" {
+		return ig.generateBuiltinOperation(ctx, imageGraphID, nodeID, nodeVersion, node, rec)
+	}
+
+	return ig.generateWithProcessor(ctx, imageGraphID, nodeID, nodeVersion, node, rec)
+}
+
+func (ig *ImageGen) generateBuiltinOperation(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	node DeclaredNode,
+	rec *imageGenMetricsRecorder,
+) error {
+	operation, ok := builtinOperations[node.Operation.Builtin]
+	if !ok {
+		return fmt.Errorf("unknown operation %q", node.Operation.Builtin)
+	}
+
+	params := make(operationParams, len(node.Operation.Params)+len(node.Config))
+	for name, value := range node.Operation.Params {
+		params[name] = value
+	}
+	for name, value := range node.Config {
+		params[name] = value
+	}
+
+	frames, err := ig.loadFrames(ctx, node.Inputs[node.PrimaryInput])
+	if err != nil {
+		return err
+	}
+
+	result, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return operation(img, params)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, result.first())
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for %s node: %w", node.NodeType, err)
+	}
+
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, node.Outputs[0], nodeVersion, result, "")
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for %s node: %w", node.NodeType, err)
+	}
+
+	return nil
+}
+
+func (ig *ImageGen) generateWithProcessor(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	node DeclaredNode,
+	rec *imageGenMetricsRecorder,
+) error {
+	// Inputs are encoded once and sent for every output
+	inputs := make(map[imagegraph.InputName][]byte, len(node.Inputs))
+	for name, imageID := range node.Inputs {
+		img, err := ig.loadImage(ctx, imageID)
+		if err != nil {
+			return err
+		}
+
+		inputs[name], err = ig.encodeImage(img)
+		if err != nil {
+			return err
+		}
+	}
+
+	config, err := json.Marshal(node.Config)
+	if err != nil {
+		return fmt.Errorf("could not encode config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, processorTimeout)
+	defer cancel()
+
+	for i, output := range node.Outputs {
+		data, err := postToProcessor(ctx, node.Operation.ProcessorURL, inputs, config, output)
+		if err != nil {
+			return fmt.Errorf("could not generate %s with processor: %w", output, err)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("could not decode %s from processor: %w", output, err)
+		}
+
+		if i == 0 {
+			err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, img)
+			rec.preview(err)
+			if err != nil {
+				return fmt.Errorf("could not generate outputs for %s node: %w", node.NodeType, err)
+			}
+		}
+
+		err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, output, nodeVersion, img)
+		rec.output(err)
+		if err != nil {
+			return fmt.Errorf("could not generate outputs for %s node: %w", node.NodeType, err)
+		}
+	}
+
+	return nil
+}
+
+func postToProcessor(
+	ctx context.Context,
+	url string,
+	inputs map[imagegraph.InputName][]byte,
+	config []byte,
+	output imagegraph.OutputName,
+) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	_ = writer.WriteField("config", string(config))
+	_ = writer.WriteField("output", string(output))
+
+	for _, name := range slices.Sorted(maps.Keys(inputs)) {
+		part, err := writer.CreateFormFile(string(name), string(name)+".png")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(inputs[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	httpReq.Header.Set("Accept", "image/*")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,134 @@
+package imagegen
+
+import (
+	"container/list"
+	"image"
+	"sync"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// defaultDecodeCacheBudget is how many bytes of decoded pixels the decode
+// cache holds unless configured with WithDecodeCache
+const defaultDecodeCacheBudget = 256 << 20
+
+// WithDecodeCache sets how many bytes of decoded pixels are kept to reuse
+// across the nodes an image feeds, 0 to disable the cache
+func WithDecodeCache(budget int64) Option {
+	return func(ig *ImageGen) {
+		ig.decodeCache = newDecodeCache(budget)
+	}
+}
+
+// decodeCacheKey identifies a cached decoding. Images loaded as a still
+// image and as a frame sequence are decoded differently, so they are cached
+// separately, as are the images connection transforms make of them.
+type decodeCacheKey struct {
+	imageID   imagegraph.ImageID
+	allFrames bool
+	transform string
+}
+
+type decodeCacheEntry struct {
+	key    decodeCacheKey
+	frames *frameSequence
+	size   int64
+}
+
+// decodeCache keeps recently decoded images so that an output feeding
+// several nodes is read from storage and decoded once per propagation
+// rather than once per node. Stored images never change, so entries never
+// go stale; the least recently used are evicted once the decoded pixels
+// exceed the budget. Generators must treat the frames they load as read
+// only, as other generations may be using them.
+type decodeCache struct {
+	mu      sync.Mutex
+	budget  int64
+	size    int64
+	order   *list.List
+	entries map[decodeCacheKey]*list.Element
+}
+
+func newDecodeCache(budget int64) *decodeCache {
+	return &decodeCache{
+		budget:  budget,
+		order:   list.New(),
+		entries: make(map[decodeCacheKey]*list.Element),
+	}
+}
+
+// get returns a copy of a cached sequence, so callers can change its
+// profile and metadata without affecting the cache. A nil cache never hits.
+func (c *decodeCache) get(key decodeCacheKey) (*frameSequence, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+
+	frames := *elem.Value.(*decodeCacheEntry).frames
+	return &frames, true
+}
+
+// put caches a copy of a decoded sequence, evicting the least recently used
+// entries to stay within budget. Sequences larger than the whole budget
+// aren't cached.
+func (c *decodeCache) put(key decodeCacheKey, frames *frameSequence) {
+	if c == nil {
+		return
+	}
+
+	size := decodedSize(frames)
+	if size > c.budget {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	cached := *frames
+	c.entries[key] = c.order.PushFront(&decodeCacheEntry{key: key, frames: &cached, size: size})
+	c.size += size
+
+	for c.size > c.budget {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*decodeCacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.size -= entry.size
+	}
+}
+
+// decodedSize estimates the memory held by the pixels of a sequence's frames
+func decodedSize(frames *frameSequence) int64 {
+	var size int64
+	for _, frame := range frames.frames {
+		bounds := frame.Bounds()
+		size += int64(bounds.Dx()) * int64(bounds.Dy()) * bytesPerPixel(frame)
+	}
+	return size
+}
+
+func bytesPerPixel(img image.Image) int64 {
+	switch img.(type) {
+	case *image.Gray, *image.Alpha, *image.Paletted:
+		return 1
+	case *image.Gray16, *image.Alpha16, *image.YCbCr:
+		return 2
+	case *image.RGBA64, *image.NRGBA64:
+		return 8
+	default:
+		return 4
+	}
+}
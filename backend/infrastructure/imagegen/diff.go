@@ -0,0 +1,236 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/nfnt/resize"
+)
+
+// ssimWindow is the size of the square windows SSIM is computed over, and
+// ssimStride the distance between them
+const (
+	ssimWindow = 8
+	ssimStride = 4
+)
+
+// heatMapStops are the colors differences are mapped through, from none to
+// the largest
+var heatMapStops = []color.RGBA{
+	{0, 0, 0, 255},
+	{0, 0, 160, 255},
+	{200, 0, 0, 255},
+	{255, 200, 0, 255},
+	{255, 255, 255, 255},
+}
+
+func (ig *ImageGen) GenerateOutputsForDiffNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	baseImageID imagegraph.ImageID,
+	compareImageID imagegraph.ImageID,
+	amplify int,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeDiff)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeDiff, imageGraphID, nodeID, nodeVersion,
+		"amplify", amplify,
+	)
+
+	base, compare, err := ig.loadComparedImages(ctx, baseImageID, compareImageID)
+	if err != nil {
+		return err
+	}
+
+	heatMap := diffHeatMap(base, compare, amplify)
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, heatMap)
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for diff node: %w", err)
+	}
+
+	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "diff", nodeVersion, heatMap)
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for diff node: %w", err)
+	}
+
+	return nil
+}
+
+// CompareImages measures how much the compare image differs from the base
+// image, resizing compare to the size of base if they differ. Animated images
+// are compared by their first frames.
+func (ig *ImageGen) CompareImages(
+	ctx context.Context,
+	baseImageID imagegraph.ImageID,
+	compareImageID imagegraph.ImageID,
+) (imagegraph.ImageDifference, error) {
+	base, compare, err := ig.loadComparedImages(ctx, baseImageID, compareImageID)
+	if err != nil {
+		return imagegraph.ImageDifference{}, err
+	}
+
+	return imagegraph.ImageDifference{
+		RMSE: rootMeanSquareError(base, compare),
+		SSIM: structuralSimilarity(base, compare),
+	}, nil
+}
+
+// loadComparedImages loads two images as RGBA images of the base image's size
+func (ig *ImageGen) loadComparedImages(
+	ctx context.Context,
+	baseImageID imagegraph.ImageID,
+	compareImageID imagegraph.ImageID,
+) (*image.RGBA, *image.RGBA, error) {
+	baseImg, err := ig.loadImage(ctx, baseImageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	compareImg, err := ig.loadImage(ctx, compareImageID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := baseImg.Bounds().Size()
+	if compareImg.Bounds().Size() != size {
+		compareImg = resize.Resize(uint(size.X), uint(size.Y), compareImg, resize.Bilinear)
+	}
+
+	return toRGBA(baseImg), toRGBA(compareImg), nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// diffHeatMap renders the largest channel difference of each pixel, scaled
+// by amplify, through the heat map colors
+func diffHeatMap(base, compare *image.RGBA, amplify int) image.Image {
+	heatMap := image.NewRGBA(base.Bounds())
+
+	for i := 0; i < len(base.Pix); i += 4 {
+		var delta float64
+		for c := range 3 {
+			delta = math.Max(delta, math.Abs(float64(base.Pix[i+c])-float64(compare.Pix[i+c]))/255)
+		}
+
+		heat := heatColor(math.Min(delta*float64(amplify), 1))
+		heatMap.Pix[i] = heat.R
+		heatMap.Pix[i+1] = heat.G
+		heatMap.Pix[i+2] = heat.B
+		heatMap.Pix[i+3] = heat.A
+	}
+
+	return heatMap
+}
+
+// heatColor interpolates the heat map color of a difference from 0 to 1
+func heatColor(t float64) color.RGBA {
+	pos := t * float64(len(heatMapStops)-1)
+	i := int(pos)
+	if i >= len(heatMapStops)-1 {
+		return heatMapStops[len(heatMapStops)-1]
+	}
+
+	frac := pos - float64(i)
+	from, to := heatMapStops[i], heatMapStops[i+1]
+	lerp := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*frac))
+	}
+
+	return color.RGBA{lerp(from.R, to.R), lerp(from.G, to.G), lerp(from.B, to.B), 255}
+}
+
+// rootMeanSquareError is the RMSE of the color channels of two images of the
+// same size, scaled to 0-1
+func rootMeanSquareError(base, compare *image.RGBA) float64 {
+	if len(base.Pix) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := 0; i < len(base.Pix); i += 4 {
+		for c := range 3 {
+			d := (float64(base.Pix[i+c]) - float64(compare.Pix[i+c])) / 255
+			sum += d * d
+		}
+	}
+
+	return math.Sqrt(sum / float64(len(base.Pix)/4*3))
+}
+
+// structuralSimilarity is the mean SSIM of the luma of two images of the
+// same size, over overlapping windows. Images smaller than a window are
+// compared as a single window.
+func structuralSimilarity(base, compare *image.RGBA) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	bounds := base.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return 1
+	}
+
+	baseLuma, compareLuma := luma(base), luma(compare)
+
+	windowW, windowH := min(ssimWindow, width), min(ssimWindow, height)
+
+	var total float64
+	var windows int
+	for y := 0; y+windowH <= height; y += ssimStride {
+		for x := 0; x+windowW <= width; x += ssimStride {
+			var sumA, sumB, sumAA, sumBB, sumAB float64
+			for wy := y; wy < y+windowH; wy++ {
+				for wx := x; wx < x+windowW; wx++ {
+					a, b := baseLuma[wy*width+wx], compareLuma[wy*width+wx]
+					sumA += a
+					sumB += b
+					sumAA += a * a
+					sumBB += b * b
+					sumAB += a * b
+				}
+			}
+
+			n := float64(windowW * windowH)
+			meanA, meanB := sumA/n, sumB/n
+			varA := sumAA/n - meanA*meanA
+			varB := sumBB/n - meanB*meanB
+			covar := sumAB/n - meanA*meanB
+
+			total += ((2*meanA*meanB + c1) * (2*covar + c2)) /
+				((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+			windows++
+		}
+	}
+
+	return total / float64(windows)
+}
+
+// luma returns the Rec. 601 luma of each pixel of an image, row by row
+func luma(img *image.RGBA) []float64 {
+	values := make([]float64, 0, len(img.Pix)/4)
+	for i := 0; i < len(img.Pix); i += 4 {
+		values = append(values,
+			0.299*float64(img.Pix[i])+0.587*float64(img.Pix[i+1])+0.114*float64(img.Pix[i+2]))
+	}
+	return values
+}
@@ -0,0 +1,745 @@
+package imagegen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Animated images move through the pipeline as frame sequences. Each frame
+// is transformed on its own and the sequence is stored as a single APNG, so
+// image storage doesn't need to know about frames, and decoders that don't
+// support APNG, including image.Decode, see the first frame as a still PNG.
+
+// Output formats of animated images
+const (
+	AnimationFormatAPNG = "apng"
+	AnimationFormatGIF  = "gif"
+)
+
+// defaultFrameDelay is how long frames without a delay are shown, as
+// browsers do
+const defaultFrameDelay = 100 * time.Millisecond
+
+// frameSequence is an image made of frames shown one after another. Every
+// frame is the whole picture rather than the change from the frame before.
+// A still image is a sequence of one frame.
+type frameSequence struct {
+	frames []image.Image
+	delays []time.Duration
+
+	// plays is how many times the sequence plays, 0 for forever
+	plays int
+
+	// iccProfile is the ICC profile of the image the sequence was read
+	// from, nil for sRGB
+	iccProfile []byte
+
+	// metadata is the stored metadata of the image the sequence was read
+	// from, nil if there is none
+	metadata *imagegraph.ImageMetadata
+}
+
+func stillFrame(img image.Image) *frameSequence {
+	return &frameSequence{frames: []image.Image{img}, delays: []time.Duration{0}}
+}
+
+// first returns the first frame, which stands for the sequence in previews
+// and in nodes that work on still images
+func (s *frameSequence) first() image.Image {
+	return s.frames[0]
+}
+
+func (s *frameSequence) animated() bool {
+	return len(s.frames) > 1
+}
+
+// mapFrames transforms each frame, keeping the sequence's timing. It stops
+// if generation is cancelled between frames.
+func (s *frameSequence) mapFrames(
+	ctx context.Context,
+	transform func(image.Image) (image.Image, error),
+) (
+	*frameSequence,
+	error,
+) {
+	mapped := &frameSequence{
+		frames:     make([]image.Image, 0, len(s.frames)),
+		delays:     s.delays,
+		plays:      s.plays,
+		iccProfile: s.iccProfile,
+		metadata:   s.metadata,
+	}
+
+	for i, frame := range s.frames {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("generation stopped at frame %d: %w", i, err)
+		}
+
+		img, err := transform(frame)
+		if err != nil {
+			return nil, fmt.Errorf("could not transform frame %d: %w", i, err)
+		}
+		mapped.frames = append(mapped.frames, img)
+	}
+
+	return mapped, nil
+}
+
+// loadFrames reads and decodes an image as a frame sequence, with every
+// frame of animated GIFs and APNGs and a single frame for other images.
+// Recently loaded images come from the decode cache.
+func (ig *ImageGen) loadFrames(ctx context.Context, imageID imagegraph.ImageID) (*frameSequence, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("generation stopped before loading image: %w", err)
+	}
+
+	if input, ok := transformedInput(ctx, imageID); ok {
+		return ig.loadTransformedFrames(ctx, input)
+	}
+
+	key := decodeCacheKey{imageID: imageID, allFrames: true}
+	if cached, ok := ig.decodeCache.get(key); ok {
+		ig.observeDecodeCache(true)
+		countLoadedPixels(ctx, cached.first().Bounds())
+		return cached, nil
+	}
+	ig.observeDecodeCache(false)
+
+	imageData, err := ig.getImage(ctx, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get image: %w", err)
+	}
+
+	if err := ig.checkImageSize(imageData, true); err != nil {
+		return nil, err
+	}
+
+	frames, err := decodeFrames(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode image: %w", err)
+	}
+	frames.iccProfile = extractICCProfile(imageData)
+	frames.metadata = ig.getImageMetadata(ctx, imageID)
+
+	ig.decodeCache.put(key, frames)
+	countLoadedPixels(ctx, frames.first().Bounds())
+
+	return frames, nil
+}
+
+// saveAndSetOutputFrames saves a frame sequence as a node output. Animated
+// sequences are encoded in format, APNG if it's empty; single frames are
+// saved as PNGs like any other output. The sequence's ICC profile is
+// embedded in PNGs and APNGs, and its metadata is saved with the output,
+// sized to it.
+func (ig *ImageGen) saveAndSetOutputFrames(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName imagegraph.OutputName,
+	nodeVersion imagegraph.NodeVersion,
+	frames *frameSequence,
+	format string,
+) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("generation stopped before saving output: %w", err)
+	}
+
+	var imageData []byte
+	var err error
+	switch {
+	case !frames.animated():
+		imageData, err = ig.encodeImage(frames.first())
+	case format == AnimationFormatGIF:
+		imageData, err = encodeGIF(frames)
+	case format == AnimationFormatAPNG || format == "":
+		imageData, err = encodeAPNG(frames)
+	default:
+		err = fmt.Errorf("unsupported animation format %q", format)
+	}
+	if err != nil {
+		return fmt.Errorf("could not encode frames: %w", err)
+	}
+
+	if bytes.HasPrefix(imageData, pngSignature) {
+		imageData, err = embedICCProfile(imageData, frames.iccProfile)
+		if err != nil {
+			return fmt.Errorf("could not embed color profile: %w", err)
+		}
+	}
+
+	var metadata *imagegraph.ImageMetadata
+	if frames.metadata != nil {
+		sized := *frames.metadata
+		sized.Width, sized.Height = frames.first().Bounds().Dx(), frames.first().Bounds().Dy()
+		metadata = &sized
+	}
+
+	err = ig.saveAndSetOutputData(ctx, imageGraphID, nodeID, outputName, nodeVersion, imageData, metadata)
+	if err != nil {
+		return err
+	}
+
+	countSavedPixels(ctx, frames.first().Bounds())
+
+	return nil
+}
+
+// decodeFrames decodes animated GIFs and APNGs into their frames, and any
+// other image into a single frame
+func decodeFrames(data []byte) (*frameSequence, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return decodeGIF(data)
+	case bytes.HasPrefix(data, pngSignature) && isAPNG(data):
+		return decodeAPNG(data)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return stillFrame(img), nil
+}
+
+// decodeGIF composes each frame of a GIF onto the frames before it as GIF
+// disposal methods describe
+func decodeGIF(data []byte) (*frameSequence, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("gif has no frames")
+	}
+
+	// GIFs count repeats after the first play, with -1 for none
+	seq := &frameSequence{plays: 0}
+	switch {
+	case g.LoopCount < 0:
+		seq.plays = 1
+	case g.LoopCount > 0:
+		seq.plays = g.LoopCount + 1
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	if canvas.Rect.Empty() {
+		canvas = image.NewRGBA(g.Image[0].Bounds())
+	}
+
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		seq.frames = append(seq.frames, cloneRGBA(canvas))
+		seq.delays = append(seq.delays, frameDelay(time.Duration(g.Delay[i])*10*time.Millisecond))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return seq, nil
+}
+
+// countGIFFrames counts the image descriptors of a GIF by skipping over its
+// blocks, without decompressing their data. A truncated GIF's frames are
+// counted up to where it ends.
+func countGIFFrames(data []byte) int {
+	// The header and logical screen descriptor, followed by the global color
+	// table if there is one
+	pos := 13
+	if len(data) < pos {
+		return 0
+	}
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+
+	// skipSubBlocks returns the position after a sequence of data sub-blocks
+	skipSubBlocks := func(pos int) int {
+		for pos < len(data) && data[pos] != 0 {
+			pos += int(data[pos]) + 1
+		}
+		return pos + 1
+	}
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension: introducer, label and sub-blocks
+			pos = skipSubBlocks(pos + 2)
+		case 0x2C: // image descriptor, local color table and image data
+			if pos+10 > len(data) {
+				return frames
+			}
+			frames++
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+			// The LZW minimum code size precedes the image data
+			pos = skipSubBlocks(pos + 1)
+		default: // trailer, or data decoding would reject
+			return frames
+		}
+	}
+
+	return frames
+}
+
+// countAPNGFrames counts the frames of an APNG, the larger of the number its
+// acTL chunk declares and the number of frame control chunks it has. PNGs
+// that aren't animated have none.
+func countAPNGFrames(data []byte) int {
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return 0
+	}
+
+	animated := false
+	declared, controls := 0, 0
+	for _, chunk := range chunks {
+		switch chunk.typ {
+		case "acTL":
+			animated = true
+			if len(chunk.data) == 8 {
+				declared = int(binary.BigEndian.Uint32(chunk.data[0:4]))
+			}
+		case "IDAT":
+			if !animated {
+				return 0
+			}
+		case "fcTL":
+			controls++
+		}
+	}
+
+	return max(declared, controls)
+}
+
+// encodeGIF encodes the frames as an animated GIF. GIF frames have at most
+// 256 colors, so each frame is dithered to its own palette of its most
+// common colors.
+func encodeGIF(seq *frameSequence) ([]byte, error) {
+	bounds := seq.first().Bounds()
+
+	g := &gif.GIF{
+		Config: image.Config{Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+
+	switch {
+	case seq.plays == 0:
+		g.LoopCount = 0
+	case seq.plays == 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = seq.plays - 1
+	}
+
+	for i, frame := range seq.frames {
+		frameBounds := frame.Bounds()
+		origin := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+
+		var framePalette color.Palette
+		if hasTransparency(frame) {
+			framePalette = append(framePalette, color.Transparent)
+		}
+		framePalette = append(framePalette, mostCommonColors(frame, 256-len(framePalette))...)
+		if len(framePalette) == 0 {
+			framePalette = color.Palette{color.Transparent}
+		}
+
+		paletted := image.NewPaletted(origin, framePalette)
+		draw.FloydSteinberg.Draw(paletted, origin, frame, frameBounds.Min)
+
+		g.Image = append(g.Image, paletted)
+		g.Delay = append(g.Delay, int(frameDelay(seq.delays[i])/(10*time.Millisecond)))
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return nil, fmt.Errorf("could not encode gif: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngChunk is a chunk of a PNG file
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// APNG frame control values
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+
+	apngBlendSource = 0
+	apngBlendOver   = 1
+)
+
+// readPNGChunks splits a PNG file into its chunks
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a png")
+	}
+
+	var chunks []pngChunk
+	r := bytes.NewReader(data[len(pngSignature):])
+	for r.Len() > 0 {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("could not read png chunk: %w", err)
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		if int64(length) > int64(r.Len())-4 {
+			return nil, errors.New("png chunk is truncated")
+		}
+
+		chunkData := make([]byte, length)
+		if _, err := io.ReadFull(r, chunkData); err != nil {
+			return nil, fmt.Errorf("could not read png chunk: %w", err)
+		}
+		if _, err := r.Seek(4, io.SeekCurrent); err != nil { // CRC
+			return nil, fmt.Errorf("could not read png chunk: %w", err)
+		}
+
+		chunk := pngChunk{typ: string(header[4:8]), data: chunkData}
+		chunks = append(chunks, chunk)
+		if chunk.typ == "IEND" {
+			break
+		}
+	}
+
+	return chunks, nil
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	w.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+
+	w.WriteString(typ)
+	w.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
+
+// isAPNG reports whether a PNG is animated, which APNGs declare with an
+// acTL chunk before their image data
+func isAPNG(data []byte) bool {
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return false
+	}
+
+	for _, chunk := range chunks {
+		switch chunk.typ {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+	}
+	return false
+}
+
+// apngFrame is a frame of an APNG as it's stored: a region of the canvas
+// with how to compose and then dispose of it
+type apngFrame struct {
+	width, height    uint32
+	xOffset, yOffset uint32
+	delay            time.Duration
+	dispose, blend   byte
+	data             [][]byte
+}
+
+// decodeAPNG composes each frame of an APNG onto the frames before it. Each
+// frame's data is decoded by rewriting it as a PNG of its own.
+func decodeAPNG(data []byte) (*frameSequence, error) {
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, errors.New("png has no header")
+	}
+	header := chunks[0].data
+
+	seq := &frameSequence{}
+
+	// Chunks other than the frames' that every frame's PNG needs, such as
+	// the palette
+	var shared []pngChunk
+	var frames []*apngFrame
+	var current *apngFrame
+
+	for _, chunk := range chunks[1:] {
+		switch chunk.typ {
+		case "acTL":
+			if len(chunk.data) != 8 {
+				return nil, errors.New("invalid apng animation control")
+			}
+			seq.plays = int(binary.BigEndian.Uint32(chunk.data[4:8]))
+		case "fcTL":
+			if len(chunk.data) != 26 {
+				return nil, errors.New("invalid apng frame control")
+			}
+			d := chunk.data
+			delayNum := binary.BigEndian.Uint16(d[20:22])
+			delayDen := binary.BigEndian.Uint16(d[22:24])
+			if delayDen == 0 {
+				delayDen = 100
+			}
+			current = &apngFrame{
+				width:   binary.BigEndian.Uint32(d[4:8]),
+				height:  binary.BigEndian.Uint32(d[8:12]),
+				xOffset: binary.BigEndian.Uint32(d[12:16]),
+				yOffset: binary.BigEndian.Uint32(d[16:20]),
+				delay:   time.Duration(delayNum) * time.Second / time.Duration(delayDen),
+				dispose: d[24],
+				blend:   d[25],
+			}
+			frames = append(frames, current)
+		case "IDAT":
+			// Image data before the first frame control is a default image
+			// that isn't part of the animation
+			if current != nil {
+				current.data = append(current.data, chunk.data)
+			}
+		case "fdAT":
+			if current == nil || len(chunk.data) < 4 {
+				return nil, errors.New("apng frame data without frame control")
+			}
+			current.data = append(current.data, chunk.data[4:])
+		case "IEND":
+		default:
+			if current == nil {
+				shared = append(shared, chunk)
+			}
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil, errors.New("apng has no frames")
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, int(binary.BigEndian.Uint32(header[0:4])), int(binary.BigEndian.Uint32(header[4:8]))))
+
+	for i, frame := range frames {
+		img, err := decodeAPNGFrame(header, shared, frame)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode apng frame %d: %w", i, err)
+		}
+
+		region := image.Rect(0, 0, int(frame.width), int(frame.height)).
+			Add(image.Pt(int(frame.xOffset), int(frame.yOffset)))
+
+		dispose := frame.dispose
+		if i == 0 && dispose == apngDisposePrevious {
+			dispose = apngDisposeBackground
+		}
+
+		var previous *image.RGBA
+		if dispose == apngDisposePrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		op := draw.Over
+		if frame.blend == apngBlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, region, img, img.Bounds().Min, op)
+
+		seq.frames = append(seq.frames, cloneRGBA(canvas))
+		seq.delays = append(seq.delays, frameDelay(frame.delay))
+
+		switch dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, region, image.Transparent, image.Point{}, draw.Src)
+		case apngDisposePrevious:
+			canvas = previous
+		}
+	}
+
+	return seq, nil
+}
+
+func decodeAPNGFrame(header []byte, shared []pngChunk, frame *apngFrame) (image.Image, error) {
+	frameHeader := bytes.Clone(header)
+	binary.BigEndian.PutUint32(frameHeader[0:4], frame.width)
+	binary.BigEndian.PutUint32(frameHeader[4:8], frame.height)
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	writePNGChunk(&buf, "IHDR", frameHeader)
+	for _, chunk := range shared {
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+	}
+	for _, data := range frame.data {
+		writePNGChunk(&buf, "IDAT", data)
+	}
+	writePNGChunk(&buf, "IEND", nil)
+
+	return png.Decode(&buf)
+}
+
+// encodeAPNG encodes the frames as an APNG whose first frame is also its
+// default image. Frames are stored whole as 8-bit RGBA, the size of the
+// first frame.
+func encodeAPNG(seq *frameSequence) ([]byte, error) {
+	bounds := seq.first().Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, errors.New("frames are empty")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:4], uint32(width))
+	binary.BigEndian.PutUint32(header[4:8], uint32(height))
+	header[8] = 8 // bit depth
+	header[9] = 6 // truecolor with alpha
+	writePNGChunk(&buf, "IHDR", header)
+
+	animationControl := make([]byte, 8)
+	binary.BigEndian.PutUint32(animationControl[0:4], uint32(len(seq.frames)))
+	binary.BigEndian.PutUint32(animationControl[4:8], uint32(seq.plays))
+	writePNGChunk(&buf, "acTL", animationControl)
+
+	var sequence uint32
+	for i, frame := range seq.frames {
+		delay := frameDelay(seq.delays[i])
+
+		frameControl := make([]byte, 26)
+		binary.BigEndian.PutUint32(frameControl[0:4], sequence)
+		binary.BigEndian.PutUint32(frameControl[4:8], uint32(width))
+		binary.BigEndian.PutUint32(frameControl[8:12], uint32(height))
+		binary.BigEndian.PutUint16(frameControl[20:22], uint16(min(delay.Milliseconds(), 65535)))
+		binary.BigEndian.PutUint16(frameControl[22:24], 1000)
+		frameControl[24] = apngDisposeNone
+		frameControl[25] = apngBlendSource
+		writePNGChunk(&buf, "fcTL", frameControl)
+		sequence++
+
+		data, err := compressRGBA(frame, width, height)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			writePNGChunk(&buf, "IDAT", data)
+			continue
+		}
+
+		frameData := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(frameData, sequence)
+		writePNGChunk(&buf, "fdAT", append(frameData, data...))
+		sequence++
+	}
+
+	writePNGChunk(&buf, "IEND", nil)
+
+	return buf.Bytes(), nil
+}
+
+// compressRGBA compresses a frame as PNG image data in 8-bit RGBA, with
+// each row filtered by the difference from the pixel to its left
+func compressRGBA(frame image.Image, width, height int) ([]byte, error) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), frame, frame.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	const subFilter = 1
+	row := make([]byte, 1+width*4)
+	for y := range height {
+		pixels := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		row[0] = subFilter
+		for x := range pixels {
+			left := byte(0)
+			if x >= 4 {
+				left = pixels[x-4]
+			}
+			row[1+x] = pixels[x] - left
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, fmt.Errorf("could not compress frame: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("could not compress frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func frameDelay(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return defaultFrameDelay
+	}
+	return delay
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
+
+func hasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,696 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/nfnt/resize"
+)
+
+const (
+	generateTimeout       = 5 * time.Minute
+	comfyUIPollInterval   = time.Second
+	openAIBaseURL         = "https://api.openai.com/v1"
+	openAIDefaultModel    = "gpt-image-1"
+	stabilityBaseURL      = "https://api.stability.ai/v2beta"
+	stabilityDefaultModel = "sd3.5-large"
+	comfyUIDefaultModel   = "sd_xl_base_1.0.safetensors"
+)
+
+// GenerateRequest describes an image to be produced by an external image
+// generation provider
+type GenerateRequest struct {
+	Provider       string
+	Prompt         string
+	NegativePrompt string
+	Model          string
+	Width          int
+	Height         int
+	Seed           *int
+	Strength       float64
+}
+
+// imageGenerator is implemented by each external image generation provider.
+// reference is the encoded reference image, or nil when the node's reference
+// input isn't connected.
+type imageGenerator interface {
+	generate(
+		ctx context.Context,
+		req GenerateRequest,
+		reference []byte,
+	) ([]byte, error)
+}
+
+// Option configures optional ImageGen behaviour
+type Option func(*ImageGen)
+
+// WithOpenAI enables the "openai" generate provider
+func WithOpenAI(apiKey string) Option {
+	return func(ig *ImageGen) {
+		ig.generators["openai"] = &openAIGenerator{
+			apiKey:  apiKey,
+			baseURL: openAIBaseURL,
+			client:  &http.Client{},
+		}
+	}
+}
+
+// WithStability enables the "stability" generate provider
+func WithStability(apiKey string) Option {
+	return func(ig *ImageGen) {
+		ig.generators["stability"] = &stabilityGenerator{
+			apiKey:  apiKey,
+			baseURL: stabilityBaseURL,
+			client:  &http.Client{},
+		}
+	}
+}
+
+// WithComfyUI enables the "comfyui" generate provider using the ComfyUI
+// server at baseURL
+func WithComfyUI(baseURL string) Option {
+	return func(ig *ImageGen) {
+		ig.generators["comfyui"] = &comfyUIGenerator{
+			baseURL: strings.TrimRight(baseURL, "/"),
+			client:  &http.Client{},
+		}
+	}
+}
+
+func (ig *ImageGen) GenerateOutputsForGenerateNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	referenceImageID imagegraph.ImageID,
+	req GenerateRequest,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeGenerate)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeGenerate, imageGraphID, nodeID, nodeVersion,
+		"provider", req.Provider,
+		"model", req.Model,
+		"width", req.Width,
+		"height", req.Height,
+		"has_reference", !referenceImageID.IsNil(),
+	)
+
+	generator, ok := ig.generators[req.Provider]
+	if !ok {
+		return fmt.Errorf("generate provider %q is not configured", req.Provider)
+	}
+
+	var reference []byte
+
+	if !referenceImageID.IsNil() {
+		img, err := ig.loadImage(ctx, referenceImageID)
+		if err != nil {
+			return err
+		}
+
+		reference, err = ig.encodeImage(img)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, generateTimeout)
+	defer cancel()
+
+	imageData, err := generator.generate(ctx, req, reference)
+	if err != nil {
+		return fmt.Errorf("could not generate image with %s: %w", req.Provider, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return fmt.Errorf("could not decode image returned by %s: %w", req.Provider, err)
+	}
+
+	// Providers only support a fixed set of sizes, so scale the result to
+	// the dimensions the node asked for
+	bounds := img.Bounds()
+	if bounds.Dx() != req.Width || bounds.Dy() != req.Height {
+		img = resize.Resize(uint(req.Width), uint(req.Height), img, resize.Lanczos3)
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, img)
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for generate node: %w", err)
+	}
+
+	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "generated", nodeVersion, img)
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for generate node: %w", err)
+	}
+
+	return nil
+}
+
+// ReportGenerationFailure marks the node as having failed to generate its
+// outputs for nodeVersion
+func (ig *ImageGen) ReportGenerationFailure(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	cause error,
+) error {
+	err := ig.nodeUpdater.SetNodeGenerationFailed(
+		ctx, imageGraphID, nodeID, cause.Error(), nodeVersion,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not report generation failure: %w", err)
+	}
+
+	return nil
+}
+
+// checkResponse returns an error containing the response body if the
+// provider didn't respond with a 2xx status
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	return fmt.Errorf(
+		"unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)),
+	)
+}
+
+type openAIGenerator struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// openAISize picks the supported output size closest to the requested
+// aspect ratio
+func openAISize(width, height int) string {
+	ratio := float64(width) / float64(height)
+
+	switch {
+	case ratio > 1.2:
+		return "1536x1024"
+	case ratio < 1/1.2:
+		return "1024x1536"
+	default:
+		return "1024x1024"
+	}
+}
+
+func (g *openAIGenerator) generate(
+	ctx context.Context,
+	req GenerateRequest,
+	reference []byte,
+) ([]byte, error) {
+	model := req.Model
+	if model == "" {
+		model = openAIDefaultModel
+	}
+
+	// The images API has no negative prompt, so fold it into the prompt
+	prompt := req.Prompt
+	if req.NegativePrompt != "" {
+		prompt += "\n\nAvoid: " + req.NegativePrompt
+	}
+
+	var httpReq *http.Request
+
+	if reference == nil {
+		body, err := json.Marshal(map[string]any{
+			"model":  model,
+			"prompt": prompt,
+			"size":   openAISize(req.Width, req.Height),
+			"n":      1,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		httpReq, err = http.NewRequestWithContext(
+			ctx, http.MethodPost, g.baseURL+"/images/generations", bytes.NewReader(body),
+		)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+	} else {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+
+		_ = writer.WriteField("model", model)
+		_ = writer.WriteField("prompt", prompt)
+		_ = writer.WriteField("size", openAISize(req.Width, req.Height))
+
+		part, err := writer.CreateFormFile("image", "reference.png")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(reference); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		httpReq, err = http.NewRequestWithContext(
+			ctx, http.MethodPost, g.baseURL+"/images/edits", &body,
+		)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+g.apiKey)
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("could not decode response: %w", err)
+	}
+
+	if len(result.Data) == 0 || result.Data[0].B64JSON == "" {
+		return nil, fmt.Errorf("response contained no image")
+	}
+
+	return base64.StdEncoding.DecodeString(result.Data[0].B64JSON)
+}
+
+type stabilityGenerator struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+var stabilityAspectRatios = []string{
+	"21:9", "16:9", "3:2", "5:4", "1:1", "4:5", "2:3", "9:16", "9:21",
+}
+
+// stabilityAspectRatio picks the supported aspect ratio closest to the
+// requested dimensions
+func stabilityAspectRatio(width, height int) string {
+	target := float64(width) / float64(height)
+	best := "1:1"
+	bestDiff := math.MaxFloat64
+
+	for _, ar := range stabilityAspectRatios {
+		var w, h float64
+		fmt.Sscanf(ar, "%f:%f", &w, &h)
+
+		diff := math.Abs(math.Log(w/h) - math.Log(target))
+		if diff < bestDiff {
+			best = ar
+			bestDiff = diff
+		}
+	}
+
+	return best
+}
+
+func (g *stabilityGenerator) generate(
+	ctx context.Context,
+	req GenerateRequest,
+	reference []byte,
+) ([]byte, error) {
+	model := req.Model
+	if model == "" {
+		model = stabilityDefaultModel
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	_ = writer.WriteField("model", model)
+	_ = writer.WriteField("prompt", req.Prompt)
+	_ = writer.WriteField("output_format", "png")
+
+	if req.NegativePrompt != "" {
+		_ = writer.WriteField("negative_prompt", req.NegativePrompt)
+	}
+
+	if req.Seed != nil {
+		_ = writer.WriteField("seed", fmt.Sprint(*req.Seed))
+	}
+
+	if reference == nil {
+		_ = writer.WriteField("mode", "text-to-image")
+		_ = writer.WriteField("aspect_ratio", stabilityAspectRatio(req.Width, req.Height))
+	} else {
+		_ = writer.WriteField("mode", "image-to-image")
+		_ = writer.WriteField("strength", fmt.Sprint(req.Strength))
+
+		part, err := writer.CreateFormFile("image", "reference.png")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(reference); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, g.baseURL+"/stable-image/generate/sd3", &body,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	httpReq.Header.Set("Authorization", "Bearer "+g.apiKey)
+	httpReq.Header.Set("Accept", "image/*")
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// comfyUIGenerator queues a workflow on a ComfyUI server and polls its
+// history until the job completes
+type comfyUIGenerator struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (g *comfyUIGenerator) generate(
+	ctx context.Context,
+	req GenerateRequest,
+	reference []byte,
+) ([]byte, error) {
+	var referenceName string
+
+	if reference != nil {
+		name, err := g.uploadImage(ctx, reference)
+		if err != nil {
+			return nil, fmt.Errorf("could not upload reference image: %w", err)
+		}
+		referenceName = name
+	}
+
+	promptID, err := g.queuePrompt(ctx, comfyUIWorkflow(req, referenceName))
+	if err != nil {
+		return nil, fmt.Errorf("could not queue prompt: %w", err)
+	}
+
+	ticker := time.NewTicker(comfyUIPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("prompt %s did not complete: %w", promptID, ctx.Err())
+		case <-ticker.C:
+		}
+
+		img, done, err := g.fetchResult(ctx, promptID)
+		if err != nil {
+			return nil, err
+		}
+
+		if done {
+			return img, nil
+		}
+	}
+}
+
+// comfyUIWorkflow builds an API-format workflow that samples a checkpoint
+// from an empty latent, or from the encoded reference image when one is
+// provided
+func comfyUIWorkflow(req GenerateRequest, referenceName string) map[string]any {
+	model := req.Model
+	if model == "" {
+		model = comfyUIDefaultModel
+	}
+
+	seed := int(time.Now().UnixNano() % math.MaxInt32)
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+
+	latent := []any{"latent", 0}
+	denoise := 1.0
+
+	workflow := map[string]any{
+		"checkpoint": map[string]any{
+			"class_type": "CheckpointLoaderSimple",
+			"inputs":     map[string]any{"ckpt_name": model},
+		},
+		"positive": map[string]any{
+			"class_type": "CLIPTextEncode",
+			"inputs":     map[string]any{"text": req.Prompt, "clip": []any{"checkpoint", 1}},
+		},
+		"negative": map[string]any{
+			"class_type": "CLIPTextEncode",
+			"inputs":     map[string]any{"text": req.NegativePrompt, "clip": []any{"checkpoint", 1}},
+		},
+		"decode": map[string]any{
+			"class_type": "VAEDecode",
+			"inputs":     map[string]any{"samples": []any{"sampler", 0}, "vae": []any{"checkpoint", 2}},
+		},
+		"save": map[string]any{
+			"class_type": "SaveImage",
+			"inputs":     map[string]any{"images": []any{"decode", 0}, "filename_prefix": "artwork"},
+		},
+	}
+
+	if referenceName == "" {
+		workflow["latent"] = map[string]any{
+			"class_type": "EmptyLatentImage",
+			"inputs":     map[string]any{"width": req.Width, "height": req.Height, "batch_size": 1},
+		}
+	} else {
+		workflow["reference"] = map[string]any{
+			"class_type": "LoadImage",
+			"inputs":     map[string]any{"image": referenceName},
+		}
+		workflow["latent"] = map[string]any{
+			"class_type": "VAEEncode",
+			"inputs":     map[string]any{"pixels": []any{"reference", 0}, "vae": []any{"checkpoint", 2}},
+		}
+		denoise = req.Strength
+	}
+
+	workflow["sampler"] = map[string]any{
+		"class_type": "KSampler",
+		"inputs": map[string]any{
+			"model":        []any{"checkpoint", 0},
+			"positive":     []any{"positive", 0},
+			"negative":     []any{"negative", 0},
+			"latent_image": latent,
+			"seed":         seed,
+			"steps":        25,
+			"cfg":          7.0,
+			"sampler_name": "euler",
+			"scheduler":    "normal",
+			"denoise":      denoise,
+		},
+	}
+
+	return workflow
+}
+
+func (g *comfyUIGenerator) uploadImage(ctx context.Context, imageData []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("image", fmt.Sprintf("artwork-%d.png", time.Now().UnixNano()))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(imageData); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/upload/image", &body)
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("could not decode upload response: %w", err)
+	}
+
+	return result.Name, nil
+}
+
+func (g *comfyUIGenerator) queuePrompt(ctx context.Context, workflow map[string]any) (string, error) {
+	body, err := json.Marshal(map[string]any{"prompt": workflow})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/prompt", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		PromptID string `json:"prompt_id"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("could not decode prompt response: %w", err)
+	}
+
+	return result.PromptID, nil
+}
+
+// fetchResult checks the history of a queued prompt, returning the first
+// saved image once the prompt has finished executing
+func (g *comfyUIGenerator) fetchResult(ctx context.Context, promptID string) ([]byte, bool, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/history/"+promptID, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, false, err
+	}
+
+	var history map[string]struct {
+		Status struct {
+			StatusStr string `json:"status_str"`
+			Completed bool   `json:"completed"`
+		} `json:"status"`
+		Outputs map[string]struct {
+			Images []struct {
+				Filename  string `json:"filename"`
+				Subfolder string `json:"subfolder"`
+				Type      string `json:"type"`
+			} `json:"images"`
+		} `json:"outputs"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		return nil, false, fmt.Errorf("could not decode history response: %w", err)
+	}
+
+	entry, ok := history[promptID]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if entry.Status.StatusStr == "error" {
+		return nil, false, fmt.Errorf("prompt %s failed", promptID)
+	}
+
+	if !entry.Status.Completed {
+		return nil, false, nil
+	}
+
+	for _, output := range entry.Outputs {
+		for _, img := range output.Images {
+			data, err := g.viewImage(ctx, img.Filename, img.Subfolder, img.Type)
+			return data, true, err
+		}
+	}
+
+	return nil, false, fmt.Errorf("prompt %s completed without an image", promptID)
+}
+
+func (g *comfyUIGenerator) viewImage(ctx context.Context, filename, subfolder, folderType string) ([]byte, error) {
+	query := url.Values{
+		"filename":  {filename},
+		"subfolder": {subfolder},
+		"type":      {folderType},
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/view?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,114 @@
+package imagegen_test
+
+import (
+	"context"
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen/imagegentest"
+)
+
+// goldenTolerance leaves room for rounding differences, so generators can be
+// reworked without regenerating every golden image
+var goldenTolerance = imagegentest.Tolerance{MaxDelta: 2}
+
+// TestGolden runs each generator against the checked-in inputs and compares
+// its output with testdata/golden/<name>.png. Run with -update-golden after
+// a change meant to alter an output, and review the new images.
+func TestGolden(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name   string
+		output imagegraph.OutputName
+		run    func(ctx context.Context, h *imagegentest.Harness, input, compare imagegraph.ImageID) error
+	}{
+		{"blur", "blurred", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForBlurNode(ctx, h.GraphID, h.NodeID, h.Version, input, 2, false)
+		}},
+		{"blur_linear", "blurred", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForBlurNode(ctx, h.GraphID, h.NodeID, h.Version, input, 2, true)
+		}},
+		{"resize_nearest", "resized", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForResizeNode(ctx, h.GraphID, h.NodeID, h.Version, input, intPtr(48), nil, "NearestNeighbor", false)
+		}},
+		{"resize_bicubic", "resized", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForResizeNode(ctx, h.GraphID, h.NodeID, h.Version, input, intPtr(10), nil, "Bicubic", false)
+		}},
+		{"resize_match", "resized", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			sizeMatch := h.AddImage(image.NewNRGBA(image.Rect(0, 0, 15, 10)))
+			return h.ImageGen.GenerateOutputsForResizeMatchNode(ctx, h.GraphID, h.NodeID, h.Version, input, sizeMatch, "Bilinear", true)
+		}},
+		{"crop", "cropped", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForCropNode(ctx, h.GraphID, h.NodeID, h.Version, input, intPtr(3), intPtr(20), intPtr(2), intPtr(12))
+		}},
+		{"pixel_inflate", "inflated", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForPixelInflateNode(ctx, h.GraphID, h.NodeID, h.Version, input, 72, 1, "#202020")
+		}},
+		{"auto_contrast", "adjusted", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForAutoContrastNode(ctx, h.GraphID, h.NodeID, h.Version, input, "stretch", 0.5)
+		}},
+		{"auto_contrast_equalize", "adjusted", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForAutoContrastNode(ctx, h.GraphID, h.NodeID, h.Version, input, "equalize", 0)
+		}},
+		{"color_space_grayscale", "converted", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForColorSpaceNode(ctx, h.GraphID, h.NodeID, h.Version, input, "srgb", "grayscale")
+		}},
+		{"color_space_linear", "converted", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForColorSpaceNode(ctx, h.GraphID, h.NodeID, h.Version, input, "srgb", "linear_rgb")
+		}},
+		{"diff", "diff", func(ctx context.Context, h *imagegentest.Harness, input, compare imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForDiffNode(ctx, h.GraphID, h.NodeID, h.Version, input, compare, 4)
+		}},
+		{"palette_extract_median_cut", "palette", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForPaletteExtractNode(ctx, h.GraphID, h.NodeID, h.Version, input, 6, imagegraph.PaletteExtractMedianCut, imagegraph.DefaultPaletteMaxSamples, imagegraph.DefaultPaletteExtractSeed, 2)
+		}},
+		{"palette_apply", "mapped", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			palette := h.LoadImage(filepath.Join("testdata", "palette.png"))
+			config := imagegraph.NewNodeConfigPaletteApply()
+			return h.ImageGen.GenerateOutputsForPaletteApplyNode(ctx, h.GraphID, h.NodeID, h.Version, input, palette, config, 2)
+		}},
+		{"output", "final", func(ctx context.Context, h *imagegentest.Harness, input, _ imagegraph.ImageID) error {
+			return h.ImageGen.GenerateOutputsForOutputNode(ctx, h.GraphID, h.NodeID, h.Version, input, "", "", false)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := imagegentest.New(t)
+			input := h.LoadImage(filepath.Join("testdata", "input.png"))
+			compare := h.LoadImage(filepath.Join("testdata", "compare.png"))
+
+			ctx := imagegen.WithoutPreview(context.Background())
+			if err := tt.run(ctx, h, input, compare); err != nil {
+				t.Fatalf("failed to generate: %v", err)
+			}
+
+			got := h.Output(tt.output)
+			imagegentest.AssertGolden(t, filepath.Join("testdata", "golden", tt.name+".png"), got, goldenTolerance)
+		})
+	}
+}
+
+// TestGoldenDeterministic checks generators produce the same pixels every
+// run, which golden images rely on
+func TestGoldenDeterministic(t *testing.T) {
+	var hashes []string
+	for range 2 {
+		h := imagegentest.New(t)
+		input := h.LoadImage(filepath.Join("testdata", "input.png"))
+
+		err := h.ImageGen.GenerateOutputsForPaletteExtractNode(context.Background(), h.GraphID, h.NodeID, h.Version, input, 6, imagegraph.PaletteExtractOKLabClusters, imagegraph.DefaultPaletteMaxSamples, imagegraph.DefaultPaletteExtractSeed, 2)
+		if err != nil {
+			t.Fatalf("failed to generate: %v", err)
+		}
+		hashes = append(hashes, imagegentest.Hash(h.Output("palette")))
+	}
+
+	if hashes[0] != hashes[1] {
+		t.Errorf("expected seeded palette extraction to be deterministic, got %v", hashes)
+	}
+}
@@ -0,0 +1,412 @@
+package imagegen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ICC profiles describe the colors of an image. They ride along with frame
+// sequences from the image they were read from and are embedded again in
+// every PNG and APNG the sequence is saved as, so image storage carries them
+// without knowing about them. GIFs have no place for a profile and are read
+// as sRGB.
+
+// iccProfileName is the name iCCP chunks of generated PNGs give profiles
+const iccProfileName = "ICC Profile"
+
+// jpegICCMarker starts the APP2 segments of JPEGs that hold an ICC profile
+var jpegICCMarker = []byte("ICC_PROFILE\x00")
+
+// xyzD50ToLinearSRGB converts PCS colors, XYZ relative to D50, to linear
+// sRGB, with the Bradford adaptation of sRGB's D65 white point to D50
+var xyzD50ToLinearSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// extractICCProfile returns the ICC profile embedded in a PNG or JPEG, or
+// nil if it has none. Profiles that can't be read are ignored like missing
+// ones, so the image is treated as sRGB.
+func extractICCProfile(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return extractPNGICCProfile(data)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return extractJPEGICCProfile(data)
+	default:
+		return nil
+	}
+}
+
+// extractPNGICCProfile reads the profile of a PNG's iCCP chunk: a name, a
+// compression method that is always zlib, and the compressed profile
+func extractPNGICCProfile(data []byte) []byte {
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return nil
+	}
+
+	for _, chunk := range chunks {
+		if chunk.typ == "IDAT" {
+			break
+		}
+		if chunk.typ != "iCCP" {
+			continue
+		}
+
+		nameEnd := bytes.IndexByte(chunk.data, 0)
+		if nameEnd < 0 || nameEnd+2 > len(chunk.data) || chunk.data[nameEnd+1] != 0 {
+			return nil
+		}
+
+		r, err := zlib.NewReader(bytes.NewReader(chunk.data[nameEnd+2:]))
+		if err != nil {
+			return nil
+		}
+		profile, err := io.ReadAll(r)
+		if err != nil {
+			return nil
+		}
+		return profile
+	}
+
+	return nil
+}
+
+// extractJPEGICCProfile reassembles the profile of a JPEG, which is split
+// across numbered APP2 segments as it may not fit in one
+func extractJPEGICCProfile(data []byte) []byte {
+	var parts [][]byte
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+
+		// Markers without a segment, and padding
+		if marker == 0xff {
+			i++
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			i += 2
+			continue
+		}
+
+		// The profile comes before the image data
+		if marker == 0xda || marker == 0xd9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+length]
+		i += 2 + length
+
+		if marker != 0xe2 || !bytes.HasPrefix(segment, jpegICCMarker) || len(segment) < len(jpegICCMarker)+2 {
+			continue
+		}
+
+		seq, count := int(segment[len(jpegICCMarker)]), int(segment[len(jpegICCMarker)+1])
+		if count == 0 || seq == 0 || seq > count {
+			return nil
+		}
+		if parts == nil {
+			parts = make([][]byte, count)
+		}
+		if len(parts) != count {
+			return nil
+		}
+		parts[seq-1] = segment[len(jpegICCMarker)+2:]
+	}
+
+	var profile []byte
+	for _, part := range parts {
+		if part == nil {
+			return nil
+		}
+		profile = append(profile, part...)
+	}
+
+	return profile
+}
+
+// embedICCProfile adds an iCCP chunk holding a profile to a PNG, right after
+// its header as profiles must come before the image data. PNGs are returned
+// as they are if there is no profile.
+func embedICCProfile(data []byte, profile []byte) ([]byte, error) {
+	if len(profile) == 0 {
+		return data, nil
+	}
+
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(profile); err != nil {
+		return nil, fmt.Errorf("could not compress profile: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("could not compress profile: %w", err)
+	}
+
+	iccp := append([]byte(iccProfileName), 0, 0)
+	iccp = append(iccp, compressed.Bytes()...)
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	for _, chunk := range chunks {
+		// The profile replaces any other statement of the color space
+		switch chunk.typ {
+		case "iCCP", "sRGB", "gAMA", "cHRM":
+			continue
+		}
+
+		writePNGChunk(&buf, chunk.typ, chunk.data)
+		if chunk.typ == "IHDR" {
+			writePNGChunk(&buf, "iCCP", iccp)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// iccMatrixProfile is an RGB profile defined by a tone curve per channel and
+// the XYZ colors of its primaries, the kind cameras and editors embed
+type iccMatrixProfile struct {
+	curves [3]func(float64) float64
+	toXYZ  [3][3]float64
+}
+
+// parseICCMatrixProfile reads the curves and primaries of a matrix profile.
+// Profiles that define their colors with lookup tables instead, such as
+// CMYK and many printer profiles, aren't supported.
+func parseICCMatrixProfile(data []byte) (*iccMatrixProfile, error) {
+	if len(data) < 132 {
+		return nil, errors.New("profile is truncated")
+	}
+	if string(data[16:20]) != "RGB " {
+		return nil, fmt.Errorf("unsupported profile color space %q", data[16:20])
+	}
+	if string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("unsupported profile connection space %q", data[20:24])
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := range count {
+		entry := 132 + 12*i
+		if entry+12 > len(data) {
+			return nil, errors.New("profile tag table is truncated")
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4 : entry+8]))
+		size := int(binary.BigEndian.Uint32(data[entry+8 : entry+12]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, errors.New("profile tag is out of bounds")
+		}
+		tags[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+
+	profile := &iccMatrixProfile{}
+	for c, names := range [3][2]string{{"rXYZ", "rTRC"}, {"gXYZ", "gTRC"}, {"bXYZ", "bTRC"}} {
+		primary, err := parseICCXYZ(tags[names[0]])
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", names[0], err)
+		}
+		for i := range 3 {
+			profile.toXYZ[i][c] = primary[i]
+		}
+
+		profile.curves[c], err = parseICCCurve(tags[names[1]])
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", names[1], err)
+		}
+	}
+
+	return profile, nil
+}
+
+// parseICCXYZ reads the single color of an XYZ tag
+func parseICCXYZ(tag []byte) ([3]float64, error) {
+	var xyz [3]float64
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return xyz, errors.New("missing or invalid XYZ tag")
+	}
+	for i := range 3 {
+		xyz[i] = s15Fixed16(tag[8+4*i:])
+	}
+	return xyz, nil
+}
+
+// parseICCCurve reads a tone curve, which decodes channel values to linear
+// light, from a curve tag holding a gamma or a table, or a parametric curve
+// tag
+func parseICCCurve(tag []byte) (func(float64) float64, error) {
+	if len(tag) < 12 {
+		return nil, errors.New("missing or invalid curve tag")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:12]))
+		if len(tag) < 12+2*count {
+			return nil, errors.New("curve is truncated")
+		}
+		switch count {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:14])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, nil
+		}
+		table := make([]float64, count)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 0xffff
+		}
+		return func(x float64) float64 {
+			pos := x * float64(count-1)
+			i := min(int(pos), count-2)
+			return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+		}, nil
+
+	case "para":
+		function := binary.BigEndian.Uint16(tag[8:10])
+		paramCounts := map[uint16]int{0: 1, 1: 3, 2: 4, 3: 5, 4: 7}
+		paramCount, ok := paramCounts[function]
+		if !ok {
+			return nil, fmt.Errorf("unsupported parametric curve function %d", function)
+		}
+		if len(tag) < 12+4*paramCount {
+			return nil, errors.New("parametric curve is truncated")
+		}
+
+		// Parameters the function doesn't use keep values that make them
+		// drop out: g, a, b, c, d, e, f
+		p := [7]float64{1, 1, 0, 0, math.Inf(-1), 0, 0}
+		for i := range paramCount {
+			p[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+
+		switch function {
+		case 0:
+			return func(x float64) float64 { return math.Pow(x, g) }, nil
+		case 1, 2:
+			// Below -b/a the curve is flat, at c
+			return func(x float64) float64 {
+				if v := a*x + b; v >= 0 {
+					return math.Pow(v, g) + c
+				}
+				return c
+			}, nil
+		default:
+			return func(x float64) float64 {
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported curve type %q", tag[:4])
+	}
+}
+
+// s15Fixed16 reads a signed 15.16 fixed point number
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b[:4]))) / 65536
+}
+
+// toSRGB converts the colors of an image described by the profile to sRGB
+func (p *iccMatrixProfile) toSRGB(img image.Image) image.Image {
+	// Decode each channel value once rather than once per pixel
+	var luts [3][256]float64
+	for c := range 3 {
+		for v := range 256 {
+			luts[c][v] = p.curves[c](float64(v) / 255)
+		}
+	}
+
+	var m [3][3]float64
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				m[i][j] += xyzD50ToLinearSRGB[i][k] * p.toXYZ[k][j]
+			}
+		}
+	}
+
+	src := toNRGBA(img)
+	out := image.NewNRGBA(src.Bounds())
+	for i := 0; i < len(src.Pix); i += 4 {
+		rgb := [3]float64{luts[0][src.Pix[i]], luts[1][src.Pix[i+1]], luts[2][src.Pix[i+2]]}
+		for c := range 3 {
+			linear := m[c][0]*rgb[0] + m[c][1]*rgb[1] + m[c][2]*rgb[2]
+			out.Pix[i+c] = uint8(math.Round(linearToSRGB(math.Max(0, math.Min(1, linear))) * 255))
+		}
+		out.Pix[i+3] = src.Pix[i+3]
+	}
+
+	return out
+}
+
+// applyColorManagement prepares a frame sequence for an Output node under
+// an ImageGraph's color management setting. Sequences whose profile can't be
+// converted to sRGB keep it, so their colors are still displayed right.
+func (ig *ImageGen) applyColorManagement(
+	ctx context.Context,
+	frames *frameSequence,
+	colorManagement string,
+) (
+	*frameSequence,
+	error,
+) {
+	if len(frames.iccProfile) == 0 {
+		return frames, nil
+	}
+
+	switch colorManagement {
+	case imagegraph.ColorManagementStrip:
+		stripped := *frames
+		stripped.iccProfile = nil
+		return &stripped, nil
+
+	case imagegraph.ColorManagementConvertSRGB:
+		profile, err := parseICCMatrixProfile(frames.iccProfile)
+		if err != nil {
+			if ig.logger != nil {
+				ig.logger.WarnContext(ctx, "could not convert color profile to sRGB, keeping it", "error", err)
+			}
+			return frames, nil
+		}
+
+		converted, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+			return profile.toSRGB(img), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		converted.iccProfile = nil
+		return converted, nil
+
+	default:
+		return frames, nil
+	}
+}
@@ -0,0 +1,52 @@
+package imagegen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// WithImageLimits rejects images larger than the limits before decoding
+// them, rather than allocating their pixels
+func WithImageLimits(limits imagegraph.ImageLimits) Option {
+	return func(ig *ImageGen) {
+		ig.imageLimits = limits
+	}
+}
+
+// checkImageSize reads the dimensions in an image's header and returns an
+// error if they exceed the limits. When all of an animation's frames are to
+// be decoded, the frames counted from its headers must fit the limits
+// together. Images whose header can't be read are left for decoding to
+// reject.
+func (ig *ImageGen) checkImageSize(data []byte, allFrames bool) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	frames := 1
+	if allFrames {
+		frames = CountFrames(data)
+	}
+
+	if err := ig.imageLimits.CheckFrames(imagegraph.ImageSize{Width: config.Width, Height: config.Height}, frames); err != nil {
+		return fmt.Errorf("image too large to decode: %w", err)
+	}
+
+	return nil
+}
+
+// CountFrames counts the frames of an animated GIF or APNG from their
+// headers, without decoding them. Other images have a single frame.
+func CountFrames(data []byte) int {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return max(countGIFFrames(data), 1)
+	case bytes.HasPrefix(data, pngSignature):
+		return max(countAPNGFrames(data), 1)
+	}
+	return 1
+}
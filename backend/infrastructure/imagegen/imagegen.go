@@ -12,6 +12,7 @@ import (
 	"log/slog"
 	"math"
 	"math/rand"
+	"slices"
 	"sort"
 	"strings"
 
@@ -26,6 +27,13 @@ type imageStorage interface {
 	Get(imageID imagegraph.ImageID) ([]byte, error)
 }
 
+// imageMetadataStorage is implemented by image storages that keep metadata
+// alongside each image, which generated images inherit from their sources
+type imageMetadataStorage interface {
+	SaveWithMetadata(imageID imagegraph.ImageID, imageData []byte, metadata imagegraph.ImageMetadata) error
+	GetMetadata(imageID imagegraph.ImageID) (imagegraph.ImageMetadata, error)
+}
+
 type nodeUpdater interface {
 	SetNodeOutputImage(
 		ctx context.Context,
@@ -50,6 +58,14 @@ type nodeUpdater interface {
 		nodeID imagegraph.NodeID,
 		config imagegraph.NodeConfig,
 	) error
+
+	SetNodeGenerationFailed(
+		ctx context.Context,
+		imageGraphID imagegraph.ImageGraphID,
+		nodeID imagegraph.NodeID,
+		message string,
+		nodeVersion imagegraph.NodeVersion,
+	) error
 }
 
 type ImageGen struct {
@@ -57,6 +73,12 @@ type ImageGen struct {
 	nodeUpdater  nodeUpdater
 	logger       *slog.Logger
 	metrics      *metrics.ImageGenMetrics
+	generators   map[string]imageGenerator
+	upscaler     upscaler
+	decodeCache  *decodeCache
+	pixelWorkers int
+	imageLimits  imagegraph.ImageLimits
+	timings      *generationTimings
 }
 
 func NewImageGen(
@@ -64,22 +86,36 @@ func NewImageGen(
 	nodeUpdater nodeUpdater,
 	logger *slog.Logger,
 	metrics *metrics.ImageGenMetrics,
+	opts ...Option,
 ) *ImageGen {
 	if logger == nil {
 		logger = slog.Default()
 	}
 
-	return &ImageGen{
+	ig := &ImageGen{
 		imageStorage: imageStorage,
 		nodeUpdater:  nodeUpdater,
 		logger:       logger,
 		metrics:      metrics,
+		generators:   make(map[string]imageGenerator),
+		decodeCache:  newDecodeCache(defaultDecodeCacheBudget),
+		timings:      newGenerationTimings(),
 	}
+
+	for _, opt := range opts {
+		opt(ig)
+	}
+
+	return ig
 }
 
-// Metrics helpers live in metrics_helpers.go.
+// Metrics helpers live in metrics_helpers.go, tracing helpers in
+// tracing_helpers.go.
 
+// logGeneration logs the start of generation with the context it runs on,
+// which carries the ID of the request that caused it
 func (ig *ImageGen) logGeneration(
+	ctx context.Context,
 	nodeType string,
 	imageGraphID imagegraph.ImageGraphID,
 	nodeID imagegraph.NodeID,
@@ -97,7 +133,7 @@ func (ig *ImageGen) logGeneration(
 		"node_version", int64(nodeVersion),
 	}
 	args = append(args, attrs...)
-	ig.logger.Info("generate_node", args...)
+	ig.logger.InfoContext(ctx, "generate_node", args...)
 }
 
 func (ig *ImageGen) encodeImage(img image.Image) ([]byte, error) {
@@ -112,22 +148,73 @@ func (ig *ImageGen) encodeImage(img image.Image) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (ig *ImageGen) loadImage(imageID imagegraph.ImageID) (image.Image, error) {
-	imageData, err := ig.imageStorage.Get(imageID)
+// loadImage reads and decodes an image. Loading is the first step of every
+// generation, so it also stops generation that has been cancelled.
+func (ig *ImageGen) loadImage(ctx context.Context, imageID imagegraph.ImageID) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("generation stopped before loading image: %w", err)
+	}
+
+	if input, ok := transformedInput(ctx, imageID); ok {
+		return ig.loadTransformedImage(ctx, input)
+	}
+
+	key := decodeCacheKey{imageID: imageID}
+	if cached, ok := ig.decodeCache.get(key); ok {
+		ig.observeDecodeCache(true)
+		countLoadedPixels(ctx, cached.first().Bounds())
+		return cached.first(), nil
+	}
+	ig.observeDecodeCache(false)
+
+	imageData, err := ig.getImage(ctx, imageID)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not get image: %w", err)
 	}
 
+	if err := ig.checkImageSize(imageData, false); err != nil {
+		return nil, err
+	}
+
 	img, _, err := image.Decode(bytes.NewReader(imageData))
 
 	if err != nil {
 		return nil, fmt.Errorf("could not decode image: %w", err)
 	}
 
+	ig.decodeCache.put(key, stillFrame(img))
+	countLoadedPixels(ctx, img.Bounds())
+
 	return img, nil
 }
 
+// ImageSize reads the dimensions of an image from its header, without
+// decoding its pixels. Connection transforms keep the size of the image they
+// transform, so a transformed input's size is its image's.
+func (ig *ImageGen) ImageSize(ctx context.Context, imageID imagegraph.ImageID) (imagegraph.ImageSize, error) {
+	if input, ok := transformedInput(ctx, imageID); ok {
+		imageID = input.ImageID
+	}
+
+	if cached, ok := ig.decodeCache.get(decodeCacheKey{imageID: imageID}); ok {
+		size := cached.first().Bounds().Size()
+		return imagegraph.ImageSize{Width: size.X, Height: size.Y}, nil
+	}
+
+	imageData, err := ig.getImage(ctx, imageID)
+	if err != nil {
+		return imagegraph.ImageSize{}, fmt.Errorf("could not get image: %w", err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return imagegraph.ImageSize{}, fmt.Errorf("could not read image size: %w", err)
+	}
+
+	return imagegraph.ImageSize{Width: config.Width, Height: config.Height}, nil
+}
+
 // saveAndSetOutput encodes an image, saves it to storage, and sets it as a node output
 func (ig *ImageGen) saveAndSetOutput(
 	ctx context.Context,
@@ -137,12 +224,39 @@ func (ig *ImageGen) saveAndSetOutput(
 	nodeVersion imagegraph.NodeVersion,
 	img image.Image,
 ) error {
+	// Skip encoding and saving an image nobody will see
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("generation stopped before saving output: %w", err)
+	}
+
 	// Encode the image
 	imageData, err := ig.encodeImage(img)
 	if err != nil {
 		return err
 	}
 
+	err = ig.saveAndSetOutputData(ctx, imageGraphID, nodeID, outputName, nodeVersion, imageData, nil)
+	if err != nil {
+		return err
+	}
+
+	countSavedPixels(ctx, img.Bounds())
+
+	return nil
+}
+
+// saveAndSetOutputData saves an encoded image to storage and sets it as a
+// node output. Metadata inherited from the source image is saved with it if
+// it isn't nil.
+func (ig *ImageGen) saveAndSetOutputData(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName imagegraph.OutputName,
+	nodeVersion imagegraph.NodeVersion,
+	imageData []byte,
+	metadata *imagegraph.ImageMetadata,
+) error {
 	// Generate new image ID
 	outputImageID, err := imagegraph.NewImageID()
 	if err != nil {
@@ -150,11 +264,16 @@ func (ig *ImageGen) saveAndSetOutput(
 	}
 
 	// Save to storage
-	err = ig.imageStorage.Save(outputImageID, imageData)
+	err = ig.saveImage(ctx, outputImageID, imageData, metadata)
 	if err != nil {
 		return fmt.Errorf("could not save image: %w", err)
 	}
 
+	err = ig.saveProvenance(ctx, outputImageID, outputName)
+	if err != nil {
+		return err
+	}
+
 	// Set the output image on the node
 	err = ig.nodeUpdater.SetNodeOutputImage(ctx, imageGraphID, nodeID, outputName, outputImageID, nodeVersion)
 	if err != nil {
@@ -171,6 +290,30 @@ func (ig *ImageGen) saveAndSetPreview(
 	nodeVersion imagegraph.NodeVersion,
 	img image.Image,
 ) error {
+	if previewSkipped(ctx) {
+		return nil
+	}
+
+	previewImageID, err := ig.savePreview(ctx, img)
+	if err != nil {
+		return err
+	}
+
+	err = ig.nodeUpdater.SetNodePreviewImage(ctx, imageGraphID, nodeID, previewImageID, nodeVersion)
+
+	if err != nil {
+		return fmt.Errorf("could not set node preview image: %w", err)
+	}
+
+	return nil
+}
+
+// savePreview scales an image down to a preview and saves it to storage
+func (ig *ImageGen) savePreview(ctx context.Context, img image.Image) (imagegraph.ImageID, error) {
+	if err := ctx.Err(); err != nil {
+		return imagegraph.ImageID{}, fmt.Errorf("generation stopped before saving preview: %w", err)
+	}
+
 	bounds := img.Bounds()
 	width := uint(bounds.Dx())
 	height := uint(bounds.Dy())
@@ -194,28 +337,22 @@ func (ig *ImageGen) saveAndSetPreview(
 	imageData, err := ig.encodeImage(previewImg)
 
 	if err != nil {
-		return err
+		return imagegraph.ImageID{}, err
 	}
 
 	previewImageID, err := imagegraph.NewImageID()
 
 	if err != nil {
-		return fmt.Errorf("could not generate preview image ID: %w", err)
-	}
-
-	err = ig.imageStorage.Save(previewImageID, imageData)
-
-	if err != nil {
-		return fmt.Errorf("could not save preview image: %w", err)
+		return imagegraph.ImageID{}, fmt.Errorf("could not generate preview image ID: %w", err)
 	}
 
-	err = ig.nodeUpdater.SetNodePreviewImage(ctx, imageGraphID, nodeID, previewImageID, nodeVersion)
+	err = ig.saveImage(ctx, previewImageID, imageData, nil)
 
 	if err != nil {
-		return fmt.Errorf("could not set node preview image: %w", err)
+		return imagegraph.ImageID{}, fmt.Errorf("could not save preview image: %w", err)
 	}
 
-	return nil
+	return previewImageID, nil
 }
 
 func (ig *ImageGen) GeneratePreviewForInputNode(
@@ -225,12 +362,12 @@ func (ig *ImageGen) GeneratePreviewForInputNode(
 	nodeVersion imagegraph.NodeVersion,
 	outputImageID imagegraph.ImageID,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypeInput)
+	ctx, rec := ig.newRecorder(ctx, nodeTypeInput)
 	defer func() {
 		rec.total(err)
 	}()
 
-	outputImage, err := ig.loadImage(outputImageID)
+	outputImage, err := ig.loadImage(ctx, outputImageID)
 	if err != nil {
 		return err
 	}
@@ -251,29 +388,40 @@ func (ig *ImageGen) GenerateOutputsForBlurNode(
 	nodeVersion imagegraph.NodeVersion,
 	inputImageID imagegraph.ImageID,
 	radius int,
+	linear bool,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypeBlur)
+	ctx, rec := ig.newRecorder(ctx, nodeTypeBlur)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypeBlur, imageGraphID, nodeID, nodeVersion, "radius", radius)
+	ig.logGeneration(ctx, nodeTypeBlur, imageGraphID, nodeID, nodeVersion,
+		"radius", radius,
+		"linear", linear,
+	)
 
 	// Load the input image
-	img, err := ig.loadImage(inputImageID)
+	frames, err := ig.loadFrames(ctx, inputImageID)
 	if err != nil {
 		return err
 	}
 
-	blurredImg := blur.Gaussian(img, float64(radius))
+	blurred, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return inLightSpace(img, linear, func(img image.Image) image.Image {
+			return blur.Gaussian(img, float64(radius))
+		}), nil
+	})
+	if err != nil {
+		return err
+	}
 
-	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, blurredImg)
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, blurred.first())
 	rec.preview(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for blur node: %w", err)
 	}
 
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "blurred", nodeVersion, blurredImg)
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "blurred", nodeVersion, blurred, "")
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for blur node: %w", err)
@@ -291,20 +439,22 @@ func (ig *ImageGen) GenerateOutputsForResizeNode(
 	width *int,
 	height *int,
 	interpolation string,
+	linear bool,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypeResize)
+	ctx, rec := ig.newRecorder(ctx, nodeTypeResize)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypeResize, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypeResize, imageGraphID, nodeID, nodeVersion,
 		"width", width,
 		"height", height,
 		"interpolation", interpolation,
+		"linear", linear,
 	)
 
 	// Load the input image
-	img, err := ig.loadImage(inputImageID)
+	frames, err := ig.loadFrames(ctx, inputImageID)
 	if err != nil {
 		return err
 	}
@@ -334,15 +484,22 @@ func (ig *ImageGen) GenerateOutputsForResizeNode(
 		return fmt.Errorf("at least one of width or height must be set")
 	}
 
-	resizedImg := resize.Resize(targetWidth, targetHeight, img, interpolationFunction)
+	resized, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return inLightSpace(img, linear, func(img image.Image) image.Image {
+			return resize.Resize(targetWidth, targetHeight, img, interpolationFunction)
+		}), nil
+	})
+	if err != nil {
+		return err
+	}
 
-	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, resizedImg)
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, resized.first())
 	rec.preview(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for resize node: %w", err)
 	}
 
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "resized", nodeVersion, resizedImg)
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "resized", nodeVersion, resized, "")
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for resize node: %w", err)
@@ -368,24 +525,26 @@ func (ig *ImageGen) GenerateOutputsForResizeMatchNode(
 	originalImageID imagegraph.ImageID,
 	sizeMatchImageID imagegraph.ImageID,
 	interpolation string,
+	linear bool,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypeResizeMatch)
+	ctx, rec := ig.newRecorder(ctx, nodeTypeResizeMatch)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypeResizeMatch, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypeResizeMatch, imageGraphID, nodeID, nodeVersion,
 		"interpolation", interpolation,
+		"linear", linear,
 	)
 
 	// Load the original image
-	originalImg, err := ig.loadImage(originalImageID)
+	originalFrames, err := ig.loadFrames(ctx, originalImageID)
 	if err != nil {
 		return err
 	}
 
 	// Load the size_match image to get dimensions
-	sizeMatchImg, err := ig.loadImage(sizeMatchImageID)
+	sizeMatchImg, err := ig.loadImage(ctx, sizeMatchImageID)
 	if err != nil {
 		return err
 	}
@@ -400,20 +559,27 @@ func (ig *ImageGen) GenerateOutputsForResizeMatchNode(
 		return fmt.Errorf("unsupported interpolation function %q", interpolation)
 	}
 
-	resizedImg := resize.Resize(
-		targetWidth,
-		targetHeight,
-		originalImg,
-		interpolationFunction,
-	)
+	resized, err := originalFrames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return inLightSpace(img, linear, func(img image.Image) image.Image {
+			return resize.Resize(
+				targetWidth,
+				targetHeight,
+				img,
+				interpolationFunction,
+			)
+		}), nil
+	})
+	if err != nil {
+		return err
+	}
 
-	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, resizedImg)
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, resized.first())
 	rec.preview(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for resize match node: %w", err)
 	}
 
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "resized", nodeVersion, resizedImg)
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "resized", nodeVersion, resized, "")
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for resize match node: %w", err)
@@ -510,23 +676,24 @@ func (ig *ImageGen) GenerateOutputsForCropNode(
 	imageID imagegraph.ImageID,
 	left, right, top, bottom *int,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypeCrop)
+	ctx, rec := ig.newRecorder(ctx, nodeTypeCrop)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypeCrop, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypeCrop, imageGraphID, nodeID, nodeVersion,
 		"left", left,
 		"right", right,
 		"top", top,
 		"bottom", bottom,
 	)
 
-	originalImage, err := ig.loadImage(imageID)
+	frames, err := ig.loadFrames(ctx, imageID)
 	if err != nil {
 		return err
 	}
 
+	originalImage := frames.first()
 	bounds := originalImage.Bounds()
 
 	// If no crop bounds are provided, pass through the original image
@@ -537,7 +704,7 @@ func (ig *ImageGen) GenerateOutputsForCropNode(
 			return fmt.Errorf("could not generate outputs for crop node: %w", err)
 		}
 
-		err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "cropped", nodeVersion, originalImage)
+		err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "cropped", nodeVersion, frames, "")
 		rec.output(err)
 		if err != nil {
 			return fmt.Errorf("could not generate outputs for crop node: %w", err)
@@ -587,14 +754,18 @@ func (ig *ImageGen) GenerateOutputsForCropNode(
 	// Create the crop rectangle
 	cropRect := image.Rect(actualLeft, actualTop, actualRight, actualBottom)
 
-	// Create a sub-image (this is a view, not a copy)
-	var croppedImg image.Image
-	if subImager, ok := originalImage.(interface {
-		SubImage(r image.Rectangle) image.Image
-	}); ok {
-		croppedImg = subImager.SubImage(cropRect)
-	} else {
-		return fmt.Errorf("image type does not support cropping")
+	// Create a sub-image of each frame (this is a view, not a copy)
+	cropped, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		subImager, ok := img.(interface {
+			SubImage(r image.Rectangle) image.Image
+		})
+		if !ok {
+			return nil, fmt.Errorf("image type does not support cropping")
+		}
+		return subImager.SubImage(cropRect), nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Generate preview with crop overlay visualization
@@ -606,7 +777,7 @@ func (ig *ImageGen) GenerateOutputsForCropNode(
 		return fmt.Errorf("could not generate outputs for crop node: %w", err)
 	}
 
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "cropped", nodeVersion, croppedImg)
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "cropped", nodeVersion, cropped, "")
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for crop node: %w", err)
@@ -621,26 +792,45 @@ func (ig *ImageGen) GenerateOutputsForOutputNode(
 	nodeID imagegraph.NodeID,
 	nodeVersion imagegraph.NodeVersion,
 	imageID imagegraph.ImageID,
+	animationFormat string,
+	colorManagement string,
+	stripMetadata bool,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypeOutput)
+	ctx, rec := ig.newRecorder(ctx, nodeTypeOutput)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypeOutput, imageGraphID, nodeID, nodeVersion)
+	ig.logGeneration(ctx, nodeTypeOutput, imageGraphID, nodeID, nodeVersion,
+		"animation_format", animationFormat,
+		"color_management", colorManagement,
+		"strip_metadata", stripMetadata,
+	)
 
-	originalImage, err := ig.loadImage(imageID)
+	frames, err := ig.loadFrames(ctx, imageID)
 	if err != nil {
 		return err
 	}
 
-	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, originalImage)
+	// Final images keep, drop or convert the profile of their source
+	frames, err = ig.applyColorManagement(ctx, frames, colorManagement)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for output node: %w", err)
+	}
+
+	if stripMetadata {
+		frames.metadata = nil
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, frames.first())
 	rec.preview(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for output node: %w", err)
 	}
 
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "final", nodeVersion, originalImage)
+	// Animations are reassembled in the format the node asks for, with the
+	// timing of the frames they were made from
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "final", nodeVersion, frames, animationFormat)
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for output node: %w", err)
@@ -649,6 +839,45 @@ func (ig *ImageGen) GenerateOutputsForOutputNode(
 	return nil
 }
 
+// GenerateOutputsForBypassedNode forwards the input image of a bypassed node
+// to its output unchanged
+func (ig *ImageGen) GenerateOutputsForBypassedNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	inputImageID imagegraph.ImageID,
+	outputName imagegraph.OutputName,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeBypass)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeBypass, imageGraphID, nodeID, nodeVersion,
+		"output", outputName,
+	)
+
+	frames, err := ig.loadFrames(ctx, inputImageID)
+	if err != nil {
+		return err
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, frames.first())
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for bypassed node: %w", err)
+	}
+
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, outputName, nodeVersion, frames, "")
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for bypassed node: %w", err)
+	}
+
+	return nil
+}
+
 func (ig *ImageGen) GenerateOutputsForPixelInflateNode(
 	ctx context.Context,
 	imageGraphID imagegraph.ImageGraphID,
@@ -659,86 +888,42 @@ func (ig *ImageGen) GenerateOutputsForPixelInflateNode(
 	lineWidth int,
 	lineColor string,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypePixelInflate)
+	ctx, rec := ig.newRecorder(ctx, nodeTypePixelInflate)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypePixelInflate, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypePixelInflate, imageGraphID, nodeID, nodeVersion,
 		"width", width,
 		"line_width", lineWidth,
 		"line_color", lineColor,
 	)
 
 	// Load the input image
-	img, err := ig.loadImage(inputImageID)
+	frames, err := ig.loadFrames(ctx, inputImageID)
 	if err != nil {
 		return err
 	}
 
-		// Get original dimensions
-		bounds := img.Bounds()
-		originalWidth := bounds.Dx()
-		originalHeight := bounds.Dy()
-
-		// Calculate new height maintaining aspect ratio
-		targetWidth := uint(width)
-		targetHeight := uint(float64(width) * float64(originalHeight) / float64(originalWidth))
-
-		// Scale the image using NearestNeighbor to preserve pixel appearance
-		scaledImg := resize.Resize(targetWidth, targetHeight, img, resize.NearestNeighbor)
-
-		// Create a mutable RGBA image from the scaled image
-		scaledBounds := scaledImg.Bounds()
-		outputImg := image.NewRGBA(scaledBounds)
-		for y := scaledBounds.Min.Y; y < scaledBounds.Max.Y; y++ {
-			for x := scaledBounds.Min.X; x < scaledBounds.Max.X; x++ {
-				outputImg.Set(x, y, scaledImg.At(x, y))
-			}
-		}
-
-		// Parse hex color #RRGGBB
-		var r, g, b uint8
-		fmt.Sscanf(lineColor, "#%02x%02x%02x", &r, &g, &b)
-		lineCol := color.RGBA{R: r, G: g, B: b, A: 255}
-
-		// Calculate scale factor
-		scaleX := float64(targetWidth) / float64(originalWidth)
-		scaleY := float64(targetHeight) / float64(originalHeight)
-
-		// Draw vertical lines (delineating original pixel columns)
-		for i := range originalWidth - 1 {
-			x := int(float64(i+1) * scaleX)
-			for lineOffset := range lineWidth {
-				xPos := x + lineOffset - lineWidth/2
-				if xPos >= 0 && xPos < int(targetWidth) {
-					for y := range int(targetHeight) {
-						outputImg.Set(xPos, y, lineCol)
-					}
-				}
-			}
-		}
+	// Parse hex color #RRGGBB
+	var r, g, b uint8
+	fmt.Sscanf(lineColor, "#%02x%02x%02x", &r, &g, &b)
+	lineCol := color.RGBA{R: r, G: g, B: b, A: 255}
 
-		// Draw horizontal lines (delineating original pixel rows)
-		for i := range originalHeight - 1 {
-			y := int(float64(i+1) * scaleY)
-			for lineOffset := range lineWidth {
-				yPos := y + lineOffset - lineWidth/2
-				if yPos >= 0 && yPos < int(targetHeight) {
-					for x := range int(targetWidth) {
-						outputImg.Set(x, yPos, lineCol)
-					}
-				}
-			}
-		}
+	inflated, err := frames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return inflatePixels(img, width, lineWidth, lineCol, ig.workers()), nil
+	})
+	if err != nil {
+		return err
+	}
 
-	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, outputImg)
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, inflated.first())
 	rec.preview(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for pixel inflate node: %w", err)
 	}
 
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "inflated", nodeVersion, outputImg)
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "inflated", nodeVersion, inflated, "")
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for pixel inflate node: %w", err)
@@ -747,6 +932,89 @@ func (ig *ImageGen) GenerateOutputsForPixelInflateNode(
 	return nil
 }
 
+// inflatePixels scales an image up to width with nearest neighbor sampling
+// and draws lines of lineCol between the original pixels. Lines are written
+// straight into the output's pixels, as setting them one at a time through
+// image.Set dominates the time taken on large images, with the output's rows
+// split across workers.
+func inflatePixels(img image.Image, width int, lineWidth int, lineCol color.RGBA, workers int) *image.RGBA {
+	// Get original dimensions
+	bounds := img.Bounds()
+	originalWidth := bounds.Dx()
+	originalHeight := bounds.Dy()
+
+	// Calculate new height maintaining aspect ratio
+	targetWidth := uint(width)
+	targetHeight := uint(float64(width) * float64(originalHeight) / float64(originalWidth))
+
+	// Scale the image using NearestNeighbor to preserve pixel appearance
+	scaledImg := resize.Resize(targetWidth, targetHeight, img, resize.NearestNeighbor)
+
+	// The scaled image is new unless no scaling was needed, so it becomes the
+	// output if it's already RGBA. The input may be shared and is never
+	// drawn on.
+	outputImg, ok := scaledImg.(*image.RGBA)
+	if !ok || scaledImg == img {
+		outputImg = image.NewRGBA(scaledImg.Bounds())
+		draw.Draw(outputImg, outputImg.Bounds(), scaledImg, scaledImg.Bounds().Min, draw.Src)
+	}
+
+	outputWidth := min(int(targetWidth), outputImg.Rect.Dx())
+	outputHeight := min(int(targetHeight), outputImg.Rect.Dy())
+	pixel := []byte{lineCol.R, lineCol.G, lineCol.B, lineCol.A}
+
+	// Calculate scale factor
+	scaleX := float64(targetWidth) / float64(originalWidth)
+	scaleY := float64(targetHeight) / float64(originalHeight)
+
+	// Find the columns of vertical lines (delineating original pixel
+	// columns) and the rows of horizontal lines (delineating original pixel
+	// rows)
+	var lineColumns []int
+	for i := range originalWidth - 1 {
+		x := int(float64(i+1) * scaleX)
+		for lineOffset := range lineWidth {
+			xPos := x + lineOffset - lineWidth/2
+			if xPos >= 0 && xPos < outputWidth {
+				lineColumns = append(lineColumns, xPos)
+			}
+		}
+	}
+
+	lineRows := make([]bool, outputHeight)
+	for i := range originalHeight - 1 {
+		y := int(float64(i+1) * scaleY)
+		for lineOffset := range lineWidth {
+			yPos := y + lineOffset - lineWidth/2
+			if yPos >= 0 && yPos < outputHeight {
+				lineRows[yPos] = true
+			}
+		}
+	}
+
+	// Horizontal lines copy a row of the line color over the whole row,
+	// other rows have the line color set in each line column
+	lineRow := make([]byte, 4*outputWidth)
+	for x := 0; x < len(lineRow); x += 4 {
+		copy(lineRow[x:], pixel)
+	}
+
+	parallelRows(outputHeight, workers, func(_, start, end int) {
+		for y := start; y < end; y++ {
+			row := outputImg.Pix[y*outputImg.Stride:]
+			if lineRows[y] {
+				copy(row, lineRow)
+				continue
+			}
+			for _, x := range lineColumns {
+				copy(row[4*x:], pixel)
+			}
+		}
+	})
+
+	return outputImg
+}
+
 func (ig *ImageGen) GenerateOutputsForPaletteExtractNode(
 	ctx context.Context,
 	imageGraphID imagegraph.ImageGraphID,
@@ -754,32 +1022,48 @@ func (ig *ImageGen) GenerateOutputsForPaletteExtractNode(
 	nodeVersion imagegraph.NodeVersion,
 	sourceImageID imagegraph.ImageID,
 	numColors int,
-	method string,
+	method imagegraph.PaletteExtractMethod,
+	maxSamples int,
+	seed int,
+	implementation int,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypePaletteExtract)
+	ctx, rec := ig.newRecorder(ctx, nodeTypePaletteExtract)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypePaletteExtract, imageGraphID, nodeID, nodeVersion,
-		"method", method,
+	ig.logGeneration(ctx, nodeTypePaletteExtract, imageGraphID, nodeID, nodeVersion,
+		"method", imagegraph.PaletteExtractMethodMapper.FromWithDefault(method, "unknown"),
 		"num_colors", numColors,
+		"max_samples", maxSamples,
+		"seed", seed,
+		"implementation", implementation,
 	)
 
 	// Load source image
-	sourceImg, err := ig.loadImage(sourceImageID)
+	sourceImg, err := ig.loadImage(ctx, sourceImageID)
 	if err != nil {
 		return err
 	}
 
+	// Gather colors from a bounded number of pixels, which is what keeps
+	// clustering the colors of photographs fast
+	sourceImg = samplePixels(sourceImg, maxSamples)
+
 		var palette []color.Color
 		switch method {
-		case "dominant_frequency":
+		case imagegraph.PaletteExtractDominantFrequency:
 			palette = mostCommonColors(sourceImg, numColors)
-		default: // "oklab_clusters" and fallback
+		case imagegraph.PaletteExtractMedianCut:
+			palette = medianCutQuantize(extractWeightedColorsFromImage(sourceImg), numColors)
+		default: // PaletteExtractOKLabClusters
+			if implementation >= 2 {
+				palette = kmeansClusteringOKLabWeighted(extractWeightedColorsFromImage(sourceImg), numColors, seed)
+				break
+			}
 			// Extract colors from the image (ignoring alpha)
-			colors := extractColorsFromImage(sourceImg)
-			palette = kmeansClusteringOKLab(colors, numColors)
+			colors := extractColorsFromImage(sourceImg, ig.workers())
+			palette = kmeansClusteringOKLab(colors, numColors, seed)
 		}
 
 		// No sorting - use colors as returned by clustering
@@ -809,28 +1093,41 @@ func (ig *ImageGen) GenerateOutputsForPaletteApplyNode(
 	sourceImageID imagegraph.ImageID,
 	paletteImageID imagegraph.ImageID,
 	config *imagegraph.NodeConfigPaletteApply,
+	implementation int,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypePaletteApply)
+	ctx, rec := ig.newRecorder(ctx, nodeTypePaletteApply)
 	defer func() {
 		rec.total(err)
 	}()
 
-	normalizeMode := ""
+	normalizeMode, distance := "", paletteDistanceRGB
+	alpha := paletteAlpha{keep: implementation >= 2}
 	if config != nil {
 		normalizeMode = config.Normalize
+		if config.Distance != "" {
+			distance = config.Distance
+		}
+		if alpha.keep {
+			alpha.keepTransparent = config.KeepTransparent
+			alpha.threshold = uint8(config.AlphaThreshold)
+		}
 	}
-	ig.logGeneration(nodeTypePaletteApply, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypePaletteApply, imageGraphID, nodeID, nodeVersion,
 		"normalize", normalizeMode,
+		"distance", distance,
+		"implementation", implementation,
+		"keep_transparent", alpha.keepTransparent,
+		"alpha_threshold", alpha.threshold,
 	)
 
 	// Load source image
-	sourceImg, err := ig.loadImage(sourceImageID)
+	sourceFrames, err := ig.loadFrames(ctx, sourceImageID)
 	if err != nil {
 		return err
 	}
 
 	// Load palette image
-	paletteImg, err := ig.loadImage(paletteImageID)
+	paletteImg, err := ig.loadImage(ctx, paletteImageID)
 	if err != nil {
 		return err
 	}
@@ -847,18 +1144,23 @@ func (ig *ImageGen) GenerateOutputsForPaletteApplyNode(
 		paletteColors = normalizePaletteLightness(paletteColors)
 	}
 
-	// Map source image to palette
-	outputImg := mapImageToPalette(sourceImg, paletteColors)
+	// Map each frame of the source image to palette
+	mapped, err := sourceFrames.mapFrames(ctx, func(img image.Image) (image.Image, error) {
+		return mapImageToPalette(img, paletteColors, distance, alpha, ig.workers()), nil
+	})
+	if err != nil {
+		return err
+	}
 
 	// Save preview
-	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, outputImg)
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, mapped.first())
 	rec.preview(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for palette apply node: %w", err)
 	}
 
 	// Save output
-	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "mapped", nodeVersion, outputImg)
+	err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "mapped", nodeVersion, mapped, "")
 	rec.output(err)
 	if err != nil {
 		return fmt.Errorf("could not generate outputs for palette apply node: %w", err)
@@ -874,12 +1176,12 @@ func (ig *ImageGen) GenerateOutputsForPaletteCreateNode(
 	nodeVersion imagegraph.NodeVersion,
 	colorStrings []string,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypePaletteCreate)
+	ctx, rec := ig.newRecorder(ctx, nodeTypePaletteCreate)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypePaletteCreate, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypePaletteCreate, imageGraphID, nodeID, nodeVersion,
 		"colors_count", len(colorStrings),
 	)
 
@@ -918,62 +1220,56 @@ func (ig *ImageGen) GenerateOutputsForPaletteEditNode(
 	existingColors []string,
 	currentConfig string,
 ) (err error) {
-	rec := ig.newRecorder(nodeTypePaletteEdit)
+	ctx, rec := ig.newRecorder(ctx, nodeTypePaletteEdit)
 	defer func() {
 		rec.total(err)
 	}()
 
-	ig.logGeneration(nodeTypePaletteEdit, imageGraphID, nodeID, nodeVersion,
+	ig.logGeneration(ctx, nodeTypePaletteEdit, imageGraphID, nodeID, nodeVersion,
 		"existing_colors", len(existingColors),
 	)
 
 	// Load source image
-	sourceImg, err := ig.loadImage(sourceImageID)
+	sourceImg, err := ig.loadImage(ctx, sourceImageID)
 	if err != nil {
 		return err
 	}
 
-	extracted := extractColorsFromImage(sourceImg)
+	extracted := extractColorsFromImage(sourceImg, ig.workers())
 	if len(extracted) > 100 {
 		return fmt.Errorf("palette edit: source image contains more than 100 unique colors")
 	}
 
-	// Map existing colors (with disabled flag)
+	// Existing colors keep their order and disabled flags, so swatches can
+	// be arranged by hand
 	existingMap := make(map[string]bool)
-	disabledMap := make(map[string]bool)
+	combined := make([]string, 0, len(existingColors)+len(extracted))
 	for _, raw := range existingColors {
-		base := strings.TrimPrefix(raw, "!")
-		existingMap[base] = true
-		if strings.HasPrefix(raw, "!") {
-			disabledMap[base] = true
+		base := strings.ToLower(strings.TrimPrefix(raw, "!"))
+		if existingMap[base] {
+			continue
 		}
+		existingMap[base] = true
+		combined = append(combined, raw)
 	}
 
-	// Add extracted colors if not present
+	// Extracted colors not present yet are added after them, sorted
+	// deterministically
+	added := make([]color.Color, 0, len(extracted))
 	for _, c := range extracted {
 		hex := colorToHex(c)
-		if _, ok := existingMap[hex]; ok {
+		if existingMap[hex] {
 			continue
 		}
 		existingMap[hex] = true
+		added = append(added, c)
 	}
-
-	// Build combined list with disabled flags
-	combined := make([]string, 0, len(existingMap))
-	for colorHex := range existingMap {
-		if disabledMap[colorHex] {
-			combined = append(combined, "!"+colorHex)
-		} else {
-			combined = append(combined, colorHex)
-		}
-	}
-
-	// Sort deterministically
-	sort.SliceStable(combined, func(i, j int) bool {
-		ci, _ := parseHexColor(strings.TrimPrefix(combined[i], "!"))
-		cj, _ := parseHexColor(strings.TrimPrefix(combined[j], "!"))
-		return lessByLuminanceHue(ci, cj)
+	sort.SliceStable(added, func(i, j int) bool {
+		return lessByLuminanceHue(added[i], added[j])
 	})
+	for _, c := range added {
+		combined = append(combined, colorToHex(c))
+	}
 
 	// Build enabled palette image
 	enabledColors := make([]color.Color, 0, len(combined))
@@ -1014,51 +1310,134 @@ func (ig *ImageGen) GenerateOutputsForPaletteEditNode(
 
 // extractPaletteColors extracts all non-transparent unique colors from a palette image
 func extractPaletteColors(img image.Image) []color.Color {
-	bounds := img.Bounds()
-	colorMap := make(map[uint32]color.Color)
+	rgba, release := borrowRGBA(img)
+	defer release()
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			c := img.At(x, y)
-			r, g, b, a := c.RGBA()
+	bounds := rgba.Bounds()
+	seen := make(map[uint32]bool)
+
+	// Colors are kept in the order they're first seen, so that colors
+	// equally near a pixel resolve the same way every time
+	var colors []color.Color
 
+	for y := range bounds.Dy() {
+		row := rgba.Pix[y*rgba.Stride : y*rgba.Stride+4*bounds.Dx()]
+		for i := 0; i < len(row); i += 4 {
 			// Skip transparent pixels
-			if a>>8 == 0 {
+			if row[i+3] == 0 {
 				continue
 			}
 
-			// Convert to 8-bit
-			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(b>>8)
+			r8, g8, b8 := row[i], row[i+1], row[i+2]
 			key := uint32(r8)<<16 | uint32(g8)<<8 | uint32(b8)
-			colorMap[key] = color.RGBA{R: r8, G: g8, B: b8, A: 255}
+			if !seen[key] {
+				seen[key] = true
+				colors = append(colors, color.RGBA{R: r8, G: g8, B: b8, A: 255})
+			}
 		}
 	}
 
-	// Convert map to slice
-	colors := make([]color.Color, 0, len(colorMap))
-	for _, c := range colorMap {
-		colors = append(colors, c)
-	}
-
 	return colors
 }
 
-// mapImageToPalette maps each pixel in the source image to the nearest color in the palette
-func mapImageToPalette(sourceImg image.Image, palette []color.Color) image.Image {
-	bounds := sourceImg.Bounds()
+// paletteAlpha is how palette mapping treats the source alpha. The zero
+// value is how implementation 1 maps: pixels are matched by their
+// premultiplied colors and come out opaque.
+type paletteAlpha struct {
+	// keep keeps the source alpha, matching pixels by their colors before
+	// premultiplying
+	keep bool
+	// keepTransparent copies pixels that aren't fully opaque unmapped
+	keepTransparent bool
+	// threshold, when above 0, makes pixels fully opaque where their alpha
+	// reaches it and fully transparent elsewhere
+	threshold uint8
+}
+
+// mapImageToPalette maps each pixel in the source image to the nearest color
+// in the palette, by distance in RGB or OKLab. The nearest color is looked
+// up in a k-d tree of the palette, once per source color as images have far
+// fewer colors than pixels, and pixels are read and written directly, with
+// the rows split across workers.
+func mapImageToPalette(sourceImg image.Image, palette []color.Color, distance string, alpha paletteAlpha, workers int) image.Image {
+	src, release := borrowRGBA(sourceImg)
+	defer release()
+
+	paletteRGBA := make([]color.RGBA, len(palette))
+	for i, pc := range palette {
+		paletteRGBA[i] = color.RGBAModel.Convert(pc).(color.RGBA)
+	}
+	tree := newColorTree(paletteRGBA, distance)
+
+	bounds := src.Bounds()
 	outputImg := image.NewRGBA(bounds)
+	rowWidth := 4 * bounds.Dx()
+
+	parallelRows(bounds.Dy(), workers, func(_, start, end int) {
+		// Each shard remembers its own nearest colors, so none are shared
+		nearest := make(map[uint32]color.RGBA)
+
+		for y := start; y < end; y++ {
+			srcRow := src.Pix[y*src.Stride : y*src.Stride+rowWidth]
+			dstRow := outputImg.Pix[y*outputImg.Stride : y*outputImg.Stride+rowWidth]
+			for i := 0; i < rowWidth; i += 4 {
+				r, g, b, a := srcRow[i], srcRow[i+1], srcRow[i+2], srcRow[i+3]
+
+				outA := uint8(255)
+				if alpha.keep {
+					outA = a
+					if alpha.threshold > 0 {
+						outA = 0
+						if a >= alpha.threshold {
+							outA = 255
+						}
+					}
+					if outA == 0 {
+						dstRow[i], dstRow[i+1], dstRow[i+2], dstRow[i+3] = 0, 0, 0, 0
+						continue
+					}
+					if alpha.keepTransparent && outA < 255 {
+						copy(dstRow[i:i+4], srcRow[i:i+4])
+						continue
+					}
+					r, g, b = unpremultiply(r, a), unpremultiply(g, a), unpremultiply(b, a)
+				}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			sourceColor := sourceImg.At(x, y)
-			nearestColor := findNearestColor(sourceColor, palette)
-			outputImg.Set(x, y, nearestColor)
+				key := uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+
+				c, ok := nearest[key]
+				if !ok {
+					c = tree.nearest(r, g, b)
+					nearest[key] = c
+				}
+
+				if outA == 255 {
+					dstRow[i], dstRow[i+1], dstRow[i+2], dstRow[i+3] = c.R, c.G, c.B, c.A
+					continue
+				}
+				dstRow[i], dstRow[i+1], dstRow[i+2], dstRow[i+3] =
+					premultiply(c.R, outA), premultiply(c.G, outA), premultiply(c.B, outA), outA
+			}
 		}
-	}
+	})
 
 	return outputImg
 }
 
+// unpremultiply recovers a channel's value from its value premultiplied by
+// alpha, rounding to nearest
+func unpremultiply(v, a uint8) uint8 {
+	if a == 0 || a == 255 {
+		return v
+	}
+	return uint8(min((uint32(v)*255+uint32(a)/2)/uint32(a), 255))
+}
+
+// premultiply scales a channel's value by alpha, rounding to nearest
+func premultiply(v, a uint8) uint8 {
+	return uint8((uint32(v)*uint32(a) + 127) / 255)
+}
+
 // normalizePaletteLightness scales palette colors in OKLab so the lightness range spans [0,1].
 func normalizePaletteLightness(palette []color.Color) []color.Color {
 	if len(palette) == 0 {
@@ -1092,53 +1471,44 @@ func normalizePaletteLightness(palette []color.Color) []color.Color {
 	return scaled
 }
 
-// findNearestColor finds the nearest color in the palette using Euclidean distance in RGB space
-func findNearestColor(c color.Color, palette []color.Color) color.Color {
-	r1, g1, b1, _ := c.RGBA()
-	r1_8, g1_8, b1_8 := float64(r1>>8), float64(g1>>8), float64(b1>>8)
-
-	minDist := float64(1000000)
-	var nearestColor color.Color = palette[0]
-
-	for _, pc := range palette {
-		r2, g2, b2, _ := pc.RGBA()
-		r2_8, g2_8, b2_8 := float64(r2>>8), float64(g2>>8), float64(b2>>8)
-
-		// Euclidean distance in RGB space
-		dr := r1_8 - r2_8
-		dg := g1_8 - g2_8
-		db := b1_8 - b2_8
-		dist := dr*dr + dg*dg + db*db
+// extractColorsFromImage extracts all unique RGB colors from an image,
+// ordered by their RGB value so clustering them is deterministic. The rows
+// are split across workers, each collecting the colors of its own rows.
+func extractColorsFromImage(img image.Image, workers int) []color.Color {
+	rgba, release := borrowRGBA(img)
+	defer release()
+
+	bounds := rgba.Bounds()
+	shardColors := make([]map[uint32]struct{}, max(workers, 1))
+
+	parallelRows(bounds.Dy(), workers, func(shard, start, end int) {
+		colorSet := make(map[uint32]struct{})
+		for y := start; y < end; y++ {
+			row := rgba.Pix[y*rgba.Stride : y*rgba.Stride+4*bounds.Dx()]
+			for i := 0; i < len(row); i += 4 {
+				// Ignore alpha
+				colorSet[uint32(row[i])<<16|uint32(row[i+1])<<8|uint32(row[i+2])] = struct{}{}
+			}
+		}
+		shardColors[shard] = colorSet
+	})
 
-		if dist < minDist {
-			minDist = dist
-			nearestColor = pc
+	colorSet := make(map[uint32]struct{})
+	for _, shard := range shardColors {
+		for key := range shard {
+			colorSet[key] = struct{}{}
 		}
 	}
 
-	return nearestColor
-}
-
-// extractColorsFromImage extracts all unique RGB colors from an image
-func extractColorsFromImage(img image.Image) []color.Color {
-	bounds := img.Bounds()
-	colorMap := make(map[uint32]color.Color)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			c := img.At(x, y)
-			r, g, b, _ := c.RGBA()
-			// Convert to 8-bit and ignore alpha
-			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(b>>8)
-			key := uint32(r8)<<16 | uint32(g8)<<8 | uint32(b8)
-			colorMap[key] = color.RGBA{R: r8, G: g8, B: b8, A: 255}
-		}
+	keys := make([]uint32, 0, len(colorSet))
+	for key := range colorSet {
+		keys = append(keys, key)
 	}
+	slices.Sort(keys)
 
-	// Convert map to slice
-	colors := make([]color.Color, 0, len(colorMap))
-	for _, c := range colorMap {
-		colors = append(colors, c)
+	colors := make([]color.Color, len(keys))
+	for i, key := range keys {
+		colors[i] = color.RGBA{R: uint8(key >> 16), G: uint8(key >> 8), B: uint8(key), A: 255}
 	}
 
 	return colors
@@ -1301,7 +1671,8 @@ func lessByLuminanceHue(a, b color.Color) bool {
 }
 
 // kmeansClusteringOKLab performs k-means clustering in OKLab space for better perceptual grouping.
-func kmeansClusteringOKLab(colors []color.Color, k int) []color.Color {
+// The seed picks the initial centroids, so the same seed gives the same palette.
+func kmeansClusteringOKLab(colors []color.Color, k int, seed int) []color.Color {
 	if len(colors) == 0 {
 		return []color.Color{}
 	}
@@ -1316,7 +1687,7 @@ func kmeansClusteringOKLab(colors []color.Color, k int) []color.Color {
 		labColors[i] = labColor{l: l, a: a, b: b, src: c}
 	}
 
-	rng := rand.New(rand.NewSource(42))
+	rng := rand.New(rand.NewSource(int64(seed)))
 
 	bestPalette := make([]color.Color, k)
 	bestInertia := math.MaxFloat64
@@ -0,0 +1,364 @@
+// Package imagegentest runs image generators against small, checked-in
+// input images and compares what they produce with golden images, within a
+// tolerance, so that refactors of the algorithms (parallelizing them,
+// working in linear light, ...) can be shown to keep their output.
+//
+// A Harness runs ImageGen's generators in memory:
+//
+//	h := imagegentest.New(t)
+//	input := h.LoadImage("testdata/input.png")
+//	err := h.ImageGen.GenerateOutputsForBlurNode(ctx, h.GraphID, h.NodeID, h.Version, input, 2, false)
+//	imagegentest.AssertGolden(t, "testdata/golden/blur.png", h.Output("blurred"), imagegentest.Tolerance{MaxDelta: 1})
+//
+// The comparison helpers take any image.Image, so processors outside this
+// repository can check their output the same way. Run the tests with
+// -update-golden to write the images produced as the new golden images.
+package imagegentest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	// Inputs may be checked in as JPEGs and GIFs too
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "write the images produced as the new golden images")
+
+// Storage is an in-memory image storage
+type Storage struct {
+	mu     sync.Mutex
+	images map[imagegraph.ImageID][]byte
+}
+
+// NewStorage creates an empty in-memory image storage
+func NewStorage() *Storage {
+	return &Storage{images: make(map[imagegraph.ImageID][]byte)}
+}
+
+func (s *Storage) Save(imageID imagegraph.ImageID, imageData []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images[imageID] = imageData
+	return nil
+}
+
+func (s *Storage) Get(imageID imagegraph.ImageID) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imageData, ok := s.images[imageID]
+	if !ok {
+		return nil, fmt.Errorf("image not found: %s", imageID)
+	}
+	return imageData, nil
+}
+
+// NodeUpdater records the outputs generators set, and the failures they
+// report, in place of the commands that would set them on a graph's node
+type NodeUpdater struct {
+	mu       sync.Mutex
+	outputs  map[imagegraph.OutputName]imagegraph.ImageID
+	failures []string
+}
+
+// NewNodeUpdater creates a NodeUpdater with nothing recorded
+func NewNodeUpdater() *NodeUpdater {
+	return &NodeUpdater{outputs: make(map[imagegraph.OutputName]imagegraph.ImageID)}
+}
+
+func (u *NodeUpdater) SetNodeOutputImage(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	outputName imagegraph.OutputName,
+	imageID imagegraph.ImageID,
+	nodeVersion imagegraph.NodeVersion,
+) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.outputs[outputName] = imageID
+	return nil
+}
+
+func (u *NodeUpdater) SetNodePreviewImage(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	imageID imagegraph.ImageID,
+	nodeVersion imagegraph.NodeVersion,
+) error {
+	return nil
+}
+
+func (u *NodeUpdater) SetNodeConfig(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	config imagegraph.NodeConfig,
+) error {
+	return nil
+}
+
+func (u *NodeUpdater) SetNodeGenerationFailed(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	message string,
+	nodeVersion imagegraph.NodeVersion,
+) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.failures = append(u.failures, message)
+	return nil
+}
+
+// Output returns the image set on an output, if one was
+func (u *NodeUpdater) Output(name imagegraph.OutputName) (imagegraph.ImageID, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	imageID, ok := u.outputs[name]
+	return imageID, ok
+}
+
+// Failures returns the messages of the generation failures reported
+func (u *NodeUpdater) Failures() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return append([]string(nil), u.failures...)
+}
+
+// Harness runs an ImageGen on in-memory storage, as the node NodeID of the
+// graph GraphID at Version
+type Harness struct {
+	t testing.TB
+
+	Storage  *Storage
+	Updater  *NodeUpdater
+	ImageGen *imagegen.ImageGen
+
+	GraphID imagegraph.ImageGraphID
+	NodeID  imagegraph.NodeID
+	Version imagegraph.NodeVersion
+}
+
+// New creates a Harness running an ImageGen configured with opts
+func New(t testing.TB, opts ...imagegen.Option) *Harness {
+	t.Helper()
+
+	storage := NewStorage()
+	updater := NewNodeUpdater()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return &Harness{
+		t:        t,
+		Storage:  storage,
+		Updater:  updater,
+		ImageGen: imagegen.NewImageGen(storage, updater, logger, nil, opts...),
+		GraphID:  imagegraph.MustNewImageGraphID(),
+		NodeID:   imagegraph.MustNewNodeID(),
+		Version:  1,
+	}
+}
+
+// LoadImage stores an image file, e.g. a checked-in input under testdata,
+// returning its ID to generate from
+func (h *Harness) LoadImage(path string) imagegraph.ImageID {
+	h.t.Helper()
+
+	imageData, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("failed to read image: %v", err)
+	}
+
+	return h.save(imageData)
+}
+
+// AddImage stores an image, returning its ID to generate from
+func (h *Harness) AddImage(img image.Image) imagegraph.ImageID {
+	h.t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		h.t.Fatalf("failed to encode image: %v", err)
+	}
+
+	return h.save(buf.Bytes())
+}
+
+func (h *Harness) save(imageData []byte) imagegraph.ImageID {
+	h.t.Helper()
+
+	imageID := imagegraph.MustNewImageID()
+	if err := h.Storage.Save(imageID, imageData); err != nil {
+		h.t.Fatalf("failed to save image: %v", err)
+	}
+
+	return imageID
+}
+
+// Output returns the image the generator set on an output, failing the test
+// if it didn't set one
+func (h *Harness) Output(name imagegraph.OutputName) image.Image {
+	h.t.Helper()
+
+	imageID, ok := h.Updater.Output(name)
+	if !ok {
+		h.t.Fatalf("expected output %q to be set, failures: %v", name, h.Updater.Failures())
+	}
+
+	imageData, err := h.Storage.Get(imageID)
+	if err != nil {
+		h.t.Fatalf("failed to get output %q: %v", name, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		h.t.Fatalf("failed to decode output %q: %v", name, err)
+	}
+
+	return img
+}
+
+// Tolerance is how far an image may stray from its golden image. Rounding
+// differences between equivalent algorithms call for a MaxDelta of a step
+// or two.
+type Tolerance struct {
+	// MaxDelta is how much each channel of a pixel may differ, out of 255
+	MaxDelta uint8
+
+	// MaxPixels is the fraction of pixels, from 0 to 1, that may differ by
+	// more than MaxDelta
+	MaxPixels float64
+}
+
+// Compare returns an error describing how got differs from want beyond the
+// tolerance, or nil if it doesn't. Images of different sizes always differ.
+func Compare(want image.Image, got image.Image, tolerance Tolerance) error {
+	if want.Bounds().Size() != got.Bounds().Size() {
+		return fmt.Errorf("expected a %v image, got %v", want.Bounds().Size(), got.Bounds().Size())
+	}
+
+	w, g := toNRGBA(want), toNRGBA(got)
+
+	var differing, maxDelta int
+	for i := 0; i < len(w.Pix); i += 4 {
+		pixelDelta := 0
+		for c := range 4 {
+			delta := int(w.Pix[i+c]) - int(g.Pix[i+c])
+			pixelDelta = max(pixelDelta, delta, -delta)
+		}
+		maxDelta = max(maxDelta, pixelDelta)
+		if pixelDelta > int(tolerance.MaxDelta) {
+			differing++
+		}
+	}
+
+	pixels := len(w.Pix) / 4
+	if differing > 0 && float64(differing) > tolerance.MaxPixels*float64(pixels) {
+		return fmt.Errorf(
+			"%d of %d pixels differ by more than %d (by up to %d)",
+			differing, pixels, tolerance.MaxDelta, maxDelta,
+		)
+	}
+
+	return nil
+}
+
+// AssertGolden fails the test if an image differs from the golden image at
+// path beyond the tolerance. With -update-golden the image is written to
+// path instead.
+func AssertGolden(t testing.TB, path string, got image.Image, tolerance Tolerance) {
+	t.Helper()
+
+	if *updateGolden {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, got); err != nil {
+			t.Fatalf("failed to encode golden image: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create golden image directory: %v", err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("failed to write golden image: %v", err)
+		}
+		return
+	}
+
+	want, err := readImage(path)
+	if err != nil {
+		t.Fatalf("failed to read golden image (run with -update-golden to create it): %v", err)
+	}
+
+	if err := Compare(want, got, tolerance); err != nil {
+		t.Errorf("image differs from %s: %v", path, err)
+	}
+}
+
+// Hash returns a digest of an image's size and non-premultiplied pixels,
+// which is the same for every encoding of the same pixels
+func Hash(img image.Image) string {
+	nrgba := toNRGBA(img)
+
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, [2]int32{int32(nrgba.Rect.Dx()), int32(nrgba.Rect.Dy())})
+	h.Write(nrgba.Pix)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AssertHash fails the test unless an image's Hash is want, for outputs
+// that must not change by a single step
+func AssertHash(t testing.TB, want string, got image.Image) {
+	t.Helper()
+
+	if hash := Hash(got); hash != want {
+		t.Errorf("expected image hash %s, got %s", want, hash)
+	}
+}
+
+func readImage(path string) (image.Image, error) {
+	imageData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", path, err)
+	}
+
+	return img, nil
+}
+
+// toNRGBA copies an image into a tightly packed NRGBA image whose bounds
+// start at the origin
+func toNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, bounds.Min, draw.Src)
+	return nrgba
+}
@@ -0,0 +1,63 @@
+package imagegentest
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	base := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := range base.Pix {
+		base.Pix[i] = 100
+	}
+
+	shifted := func(delta uint8, pixels int) *image.NRGBA {
+		img := image.NewNRGBA(base.Rect)
+		copy(img.Pix, base.Pix)
+		for i := range pixels {
+			img.SetNRGBA(i%4, i/4, color.NRGBA{100 + delta, 100, 100, 100})
+		}
+		return img
+	}
+
+	tests := []struct {
+		name      string
+		got       image.Image
+		tolerance Tolerance
+		wantErr   bool
+	}{
+		{"identical", shifted(0, 0), Tolerance{}, false},
+		{"within delta", shifted(2, 16), Tolerance{MaxDelta: 2}, false},
+		{"beyond delta", shifted(3, 1), Tolerance{MaxDelta: 2}, true},
+		{"within pixel fraction", shifted(50, 4), Tolerance{MaxPixels: 0.25}, false},
+		{"beyond pixel fraction", shifted(50, 5), Tolerance{MaxPixels: 0.25}, true},
+		{"different size", image.NewNRGBA(image.Rect(0, 0, 4, 3)), Tolerance{MaxDelta: 255, MaxPixels: 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Compare(base, tt.got, tt.tolerance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	nrgba.SetNRGBA(1, 1, color.NRGBA{10, 20, 30, 255})
+
+	// The same pixels in another image type and offset bounds hash the same
+	rgba := image.NewRGBA(image.Rect(5, 5, 8, 7))
+	rgba.SetRGBA(6, 6, color.RGBA{10, 20, 30, 255})
+
+	if Hash(nrgba) != Hash(rgba) {
+		t.Error("expected the same pixels to hash the same")
+	}
+
+	if Hash(nrgba) == Hash(image.NewNRGBA(image.Rect(0, 0, 2, 3))) {
+		t.Error("expected images of different sizes to hash differently")
+	}
+}
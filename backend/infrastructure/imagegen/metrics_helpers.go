@@ -1,6 +1,9 @@
 package imagegen
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 func (ig *ImageGen) observeTotal(nodeType string, start time.Time, err error) {
 	if ig.metrics == nil {
@@ -17,13 +20,19 @@ type imageGenMetricsRecorder struct {
 	ig       *ImageGen
 	nodeType string
 	start    time.Time
+	pixels   *pixelCounts
 }
 
-func (ig *ImageGen) newRecorder(nodeType string) *imageGenMetricsRecorder {
-	return &imageGenMetricsRecorder{
+// newRecorder starts timing a generation. The context it returns counts the
+// pixels of the images the generation loads, which are recorded with its
+// timing.
+func (ig *ImageGen) newRecorder(ctx context.Context, nodeType string) (context.Context, *imageGenMetricsRecorder) {
+	ctx, pixels := withPixelCounts(ctx)
+	return ctx, &imageGenMetricsRecorder{
 		ig:       ig,
 		nodeType: nodeType,
 		start:    time.Now(),
+		pixels:   pixels,
 	}
 }
 
@@ -51,4 +60,18 @@ func (r *imageGenMetricsRecorder) output(err error) {
 
 func (r *imageGenMetricsRecorder) total(err error) {
 	r.ig.observeTotal(r.nodeType, r.start, err)
+	if err == nil {
+		r.ig.timings.record(r.nodeType, time.Since(r.start), r.pixels.loaded.Load())
+	}
+}
+
+func (ig *ImageGen) observeDecodeCache(hit bool) {
+	if ig.metrics == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	ig.metrics.ObserveDecodeCache(result)
 }
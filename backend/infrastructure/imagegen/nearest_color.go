@@ -0,0 +1,138 @@
+package imagegen
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Color spaces palette colors can be matched in
+const (
+	paletteDistanceRGB   = "rgb"
+	paletteDistanceOKLab = "oklab"
+)
+
+// colorTree is a k-d tree over the colors of a palette. It finds the palette
+// color nearest to a color by Euclidean distance in a handful of
+// comparisons rather than one per palette color, and breaks ties towards the
+// color that comes first in the palette as a linear scan would.
+type colorTree struct {
+	palette []color.RGBA
+	toPoint func(r, g, b uint8) [3]float64
+	nodes   []colorTreeNode
+	root    int
+}
+
+type colorTreeNode struct {
+	point       [3]float64
+	index       int
+	axis        int
+	left, right int
+}
+
+// newColorTree builds a tree over a palette, measuring distance in RGB or
+// OKLab
+func newColorTree(palette []color.RGBA, distance string) *colorTree {
+	t := &colorTree{
+		palette: palette,
+		toPoint: rgbPoint,
+		nodes:   make([]colorTreeNode, 0, len(palette)),
+	}
+	if distance == paletteDistanceOKLab {
+		t.toPoint = okLabPoint
+	}
+
+	indexes := make([]int, len(palette))
+	points := make([][3]float64, len(palette))
+	for i, c := range palette {
+		indexes[i] = i
+		points[i] = t.toPoint(c.R, c.G, c.B)
+	}
+
+	t.root = t.build(indexes, points)
+
+	return t
+}
+
+// build adds the median of indexes along their widest axis as a node, with
+// the colors either side of it as its subtrees, and returns the node
+func (t *colorTree) build(indexes []int, points [][3]float64) int {
+	if len(indexes) == 0 {
+		return -1
+	}
+
+	axis, widest := 0, -1.0
+	for a := range 3 {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, i := range indexes {
+			low, high = math.Min(low, points[i][a]), math.Max(high, points[i][a])
+		}
+		if high-low > widest {
+			axis, widest = a, high-low
+		}
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return points[indexes[i]][axis] < points[indexes[j]][axis]
+	})
+	median := len(indexes) / 2
+
+	node := len(t.nodes)
+	t.nodes = append(t.nodes, colorTreeNode{
+		point: points[indexes[median]],
+		index: indexes[median],
+		axis:  axis,
+	})
+
+	left := t.build(indexes[:median], points)
+	right := t.build(indexes[median+1:], points)
+	t.nodes[node].left, t.nodes[node].right = left, right
+
+	return node
+}
+
+// nearest returns the palette color nearest to a color
+func (t *colorTree) nearest(r, g, b uint8) color.RGBA {
+	point := t.toPoint(r, g, b)
+	best, bestDist := -1, math.Inf(1)
+	t.search(t.root, point, &best, &bestDist)
+	return t.palette[best]
+}
+
+func (t *colorTree) search(node int, point [3]float64, best *int, bestDist *float64) {
+	if node < 0 {
+		return
+	}
+	n := &t.nodes[node]
+
+	var dist float64
+	for a := range 3 {
+		d := point[a] - n.point[a]
+		dist += d * d
+	}
+	if dist < *bestDist || (dist == *bestDist && n.index < *best) {
+		*best, *bestDist = n.index, dist
+	}
+
+	diff := point[n.axis] - n.point[n.axis]
+	near, far := n.left, n.right
+	if diff >= 0 {
+		near, far = n.right, n.left
+	}
+
+	t.search(near, point, best, bestDist)
+	// The far side can only hold a color as near, or an equally near one
+	// earlier in the palette, if the splitting plane is no further away
+	if diff*diff <= *bestDist {
+		t.search(far, point, best, bestDist)
+	}
+}
+
+func rgbPoint(r, g, b uint8) [3]float64 {
+	return [3]float64{float64(r), float64(g), float64(b)}
+}
+
+func okLabPoint(r, g, b uint8) [3]float64 {
+	l, a, bb := rgbToOKLab(color.RGBA{R: r, G: g, B: b, A: 255})
+	return [3]float64{l, a, bb}
+}
@@ -12,4 +12,12 @@ const (
 	nodeTypePaletteApply   = "palette_apply"
 	nodeTypePaletteCreate  = "palette_create"
 	nodeTypePaletteEdit    = "palette_edit"
+	nodeTypeGenerate       = "generate"
+	nodeTypeUpscale        = "upscale"
+	nodeTypeDiff           = "diff"
+	nodeTypeAutoContrast   = "auto_contrast"
+	nodeTypeColorSpace     = "color_space"
+	nodeTypeContactSheet   = "contact_sheet"
+	nodeTypeSwitch         = "switch"
+	nodeTypeBypass         = "bypass"
 )
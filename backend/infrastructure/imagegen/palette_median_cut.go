@@ -0,0 +1,130 @@
+package imagegen
+
+import (
+	"image/color"
+	"sort"
+)
+
+// medianCutBox is a group of colors bounded along each RGB channel
+type medianCutBox struct {
+	colors []weightedLabColor
+}
+
+// medianCutChannel returns a color's value on an RGB channel: 0 red, 1 green, 2 blue
+func medianCutChannel(c weightedLabColor, channel int) uint8 {
+	rgba := c.src.(color.RGBA)
+	switch channel {
+	case 0:
+		return rgba.R
+	case 1:
+		return rgba.G
+	default:
+		return rgba.B
+	}
+}
+
+// widestChannel returns the RGB channel the box's colors spread furthest
+// along, and how far
+func (box medianCutBox) widestChannel() (int, int) {
+	widest, widestRange := 0, -1
+	for channel := range 3 {
+		lo, hi := uint8(255), uint8(0)
+		for _, c := range box.colors {
+			v := medianCutChannel(c, channel)
+			lo = min(lo, v)
+			hi = max(hi, v)
+		}
+		if r := int(hi) - int(lo); r > widestRange {
+			widest, widestRange = channel, r
+		}
+	}
+	return widest, widestRange
+}
+
+// split cuts the box in two along its widest channel at the weighted median
+// of its colors, so each half covers about as many pixels
+func (box medianCutBox) split() (medianCutBox, medianCutBox) {
+	channel, _ := box.widestChannel()
+
+	colors := append([]weightedLabColor(nil), box.colors...)
+	sort.SliceStable(colors, func(i, j int) bool {
+		return medianCutChannel(colors[i], channel) < medianCutChannel(colors[j], channel)
+	})
+
+	var total float64
+	for _, c := range colors {
+		total += c.weight
+	}
+
+	// Cut after the color that brings the running weight past half, keeping
+	// at least one color on each side
+	cut, running := 1, 0.0
+	for i, c := range colors[:len(colors)-1] {
+		running += c.weight
+		cut = i + 1
+		if running >= total/2 {
+			break
+		}
+	}
+
+	return medianCutBox{colors: colors[:cut]}, medianCutBox{colors: colors[cut:]}
+}
+
+// average returns the mean of the box's colors weighted by their pixels
+func (box medianCutBox) average() color.Color {
+	var r, g, b, total float64
+	for _, c := range box.colors {
+		rgba := c.src.(color.RGBA)
+		r += float64(rgba.R) * c.weight
+		g += float64(rgba.G) * c.weight
+		b += float64(rgba.B) * c.weight
+		total += c.weight
+	}
+	return color.RGBA{
+		R: uint8(r/total + 0.5),
+		G: uint8(g/total + 0.5),
+		B: uint8(b/total + 0.5),
+		A: 255,
+	}
+}
+
+// medianCutQuantize reduces the colors to a palette of k by repeatedly
+// splitting the box of colors that spreads furthest along an RGB channel at
+// its weighted median, then averaging each box. Unlike k-means it makes a
+// single pass per split, so it stays fast for big palettes. The palette is
+// sorted by luminance and hue.
+func medianCutQuantize(colors []weightedLabColor, k int) []color.Color {
+	if len(colors) == 0 || k <= 0 {
+		return []color.Color{}
+	}
+
+	boxes := []medianCutBox{{colors: colors}}
+	for len(boxes) < k {
+		widest, widestRange := -1, 0
+		for i, box := range boxes {
+			if len(box.colors) < 2 {
+				continue
+			}
+			if _, r := box.widestChannel(); r > widestRange {
+				widest, widestRange = i, r
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		first, second := boxes[widest].split()
+		boxes[widest] = first
+		boxes = append(boxes, second)
+	}
+
+	palette := make([]color.Color, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.average()
+	}
+	sort.SliceStable(palette, func(i, j int) bool {
+		return lessByLuminanceHue(palette[i], palette[j])
+	})
+
+	return palette
+}
@@ -0,0 +1,54 @@
+package imagegen
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMedianCutQuantize(t *testing.T) {
+	t.Run("keeps every color when there are no more than k", func(t *testing.T) {
+		source := testImage(40, 40, 8)
+		colors := extractWeightedColorsFromImage(source)
+
+		palette := medianCutQuantize(colors, 16)
+		if len(palette) != len(colors) {
+			t.Fatalf("expected %d colors, got %d", len(colors), len(palette))
+		}
+
+		distinct := make(map[color.RGBA]bool)
+		for _, c := range colors {
+			distinct[c.src.(color.RGBA)] = true
+		}
+		for _, c := range palette {
+			if !distinct[c.(color.RGBA)] {
+				t.Errorf("expected %v to be a color of the image", c)
+			}
+		}
+	})
+
+	t.Run("reduces to k colors sorted by luminance", func(t *testing.T) {
+		palette := medianCutQuantize(extractWeightedColorsFromImage(testImage(100, 80, 64)), 8)
+		if len(palette) != 8 {
+			t.Fatalf("expected 8 colors, got %d", len(palette))
+		}
+		for i := 1; i < len(palette); i++ {
+			if lessByLuminanceHue(palette[i], palette[i-1]) {
+				t.Errorf("expected color %d to sort after color %d", i, i-1)
+			}
+		}
+	})
+
+	t.Run("averages colors by the pixels they cover", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+		for x := range 3 {
+			img.Set(x, 0, color.RGBA{R: 200, A: 255})
+		}
+		img.Set(3, 0, color.RGBA{R: 40, A: 255})
+
+		palette := medianCutQuantize(extractWeightedColorsFromImage(img), 1)
+		if want := (color.RGBA{R: 160, A: 255}); len(palette) != 1 || palette[0] != want {
+			t.Errorf("expected %v, got %v", want, palette)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package imagegen
+
+import (
+	"image"
+	"math"
+)
+
+// samplePixels picks at most maxSamples pixels of an image on an even grid,
+// returned as an image of their own, so palettes are extracted from a
+// bounded number of pixels however large the image. Each sample is the pixel
+// at the center of its cell rather than a blend of the cell, so sampling
+// never makes up colors the image doesn't have. Images within the limit, or
+// any image with a limit of 0, are returned as they are.
+func samplePixels(img image.Image, maxSamples int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxSamples <= 0 || width*height <= maxSamples {
+		return img
+	}
+
+	// Scale both sides alike to keep the grid even
+	scale := math.Sqrt(float64(maxSamples) / float64(width*height))
+	sampledWidth := max(1, min(width, maxSamples, int(float64(width)*scale)))
+	sampledHeight := max(1, min(height, maxSamples/sampledWidth, int(float64(height)*scale)))
+
+	src, release := borrowRGBA(img)
+	defer release()
+
+	sampled := image.NewRGBA(image.Rect(0, 0, sampledWidth, sampledHeight))
+	for y := range sampledHeight {
+		srcRow := src.Pix[(2*y+1)*height/(2*sampledHeight)*src.Stride:]
+		dstRow := sampled.Pix[y*sampled.Stride:]
+		for x := range sampledWidth {
+			sx := (2*x + 1) * width / (2 * sampledWidth)
+			copy(dstRow[4*x:4*x+4], srcRow[4*sx:4*sx+4])
+		}
+	}
+
+	return sampled
+}
@@ -0,0 +1,94 @@
+package imagegen
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+func TestSamplePixels(t *testing.T) {
+	t.Run("keeps images within the limit", func(t *testing.T) {
+		source := testImage(100, 80, 16)
+
+		if sampled := samplePixels(source, 8000); sampled != image.Image(source) {
+			t.Error("expected an image within the limit to be returned as it is")
+		}
+		if sampled := samplePixels(source, 0); sampled != image.Image(source) {
+			t.Error("expected a limit of 0 to sample every pixel")
+		}
+	})
+
+	t.Run("samples only colors of the image", func(t *testing.T) {
+		source := testImage(1000, 800, 64)
+		colors := make(map[color.RGBA]bool)
+		for y := range source.Rect.Dy() {
+			for x := range source.Rect.Dx() {
+				colors[source.RGBAAt(x, y)] = true
+			}
+		}
+
+		sampled := samplePixels(source, 10000).(*image.RGBA)
+
+		if n := sampled.Rect.Dx() * sampled.Rect.Dy(); n > 10000 || n < 9000 {
+			t.Errorf("expected close to 10000 samples, got %d", n)
+		}
+		for y := range sampled.Rect.Dy() {
+			for x := range sampled.Rect.Dx() {
+				if c := sampled.RGBAAt(x, y); !colors[c] {
+					t.Fatalf("expected only colors of the image, got %v", c)
+				}
+			}
+		}
+	})
+
+	t.Run("stays within the limit for any shape", func(t *testing.T) {
+		for _, size := range []image.Point{{100000, 1}, {1, 100000}, {3000, 7}} {
+			sampled := samplePixels(testImage(size.X, size.Y, 4), 100)
+			if bounds := sampled.Bounds(); bounds.Dx()*bounds.Dy() > 100 {
+				t.Errorf("expected at most 100 samples of a %v image, got %v", size, bounds.Size())
+			}
+		}
+	})
+
+	t.Run("finds the same palette as every pixel", func(t *testing.T) {
+		// Four flat quarters, as in a simple illustration
+		quarters := []color.RGBA{{R: 200, A: 255}, {G: 200, A: 255}, {B: 200, A: 255}, {R: 200, G: 200, A: 255}}
+		source := image.NewRGBA(image.Rect(0, 0, 400, 400))
+		for y := range 400 {
+			for x := range 400 {
+				source.SetRGBA(x, y, quarters[2*(y/200)+x/200])
+			}
+		}
+
+		want := kmeansClusteringOKLabWeighted(extractWeightedColorsFromImage(source), 4, imagegraph.DefaultPaletteExtractSeed)
+		got := kmeansClusteringOKLabWeighted(extractWeightedColorsFromImage(samplePixels(source, 1000)), 4, imagegraph.DefaultPaletteExtractSeed)
+
+		if len(got) != len(want) {
+			t.Fatalf("expected %d colors, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("expected %v at %d, got %v", want[i], i, got[i])
+			}
+		}
+	})
+}
+
+func BenchmarkPaletteExtract(b *testing.B) {
+	// A photograph's worth of distinct colors at 4K
+	source := testImage(width4K, height4K, 1<<18)
+
+	for _, maxSamples := range []int{0, 100000} {
+		name := "every pixel"
+		if maxSamples > 0 {
+			name = "sampled"
+		}
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				kmeansClusteringOKLabWeighted(extractWeightedColorsFromImage(samplePixels(source, maxSamples)), 16, imagegraph.DefaultPaletteExtractSeed)
+			}
+		})
+	}
+}
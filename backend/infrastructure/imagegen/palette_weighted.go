@@ -0,0 +1,217 @@
+package imagegen
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// weightedLabColor is a distinct image color in OKLab space along with the
+// number of pixels it covers
+type weightedLabColor struct {
+	labColor
+	weight float64
+}
+
+// extractWeightedColorsFromImage returns the distinct colors in the image
+// (ignoring alpha) weighted by their pixel counts. Colors are ordered by
+// their RGB value so that clustering is deterministic.
+func extractWeightedColorsFromImage(img image.Image) []weightedLabColor {
+	bounds := img.Bounds()
+	counts := make(map[uint32]int)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			key := uint32(r>>8)<<16 | uint32(g>>8)<<8 | uint32(b>>8)
+			counts[key]++
+		}
+	}
+
+	keys := make([]uint32, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	colors := make([]weightedLabColor, len(keys))
+	for i, key := range keys {
+		c := color.RGBA{R: uint8(key >> 16), G: uint8(key >> 8), B: uint8(key), A: 255}
+		l, a, b := rgbToOKLab(c)
+		colors[i] = weightedLabColor{
+			labColor: labColor{l: l, a: a, b: b, src: c},
+			weight:   float64(counts[key]),
+		}
+	}
+
+	return colors
+}
+
+// kmeansClusteringOKLabWeighted performs k-means clustering in OKLab space
+// where every color pulls its centroid in proportion to the number of pixels
+// it covers. Unlike kmeansClusteringOKLab, large flat areas dominate the
+// palette rather than being outvoted by many rare noise colors.
+func kmeansClusteringOKLabWeighted(colors []weightedLabColor, k int, seed int) []color.Color {
+	if len(colors) == 0 || k <= 0 {
+		return []color.Color{}
+	}
+
+	if len(colors) <= k {
+		palette := make([]color.Color, len(colors))
+		for i, c := range colors {
+			palette[i] = c.src
+		}
+		sort.SliceStable(palette, func(i, j int) bool {
+			return lessByLuminanceHue(palette[i], palette[j])
+		})
+		return palette
+	}
+
+	rng := rand.New(rand.NewSource(int64(seed)))
+
+	bestPalette := make([]color.Color, k)
+	bestInertia := math.MaxFloat64
+
+	const maxIterations = 50
+	const restarts = 5
+
+	for range restarts {
+		centroids := initCentroidsKMeansPPWeighted(colors, k, rng)
+		assignments := make([]int, len(colors))
+
+		for range maxIterations {
+			changed := false
+
+			for i, c := range colors {
+				best := nearestCentroid(c.labColor, centroids)
+				if assignments[i] != best {
+					assignments[i] = best
+					changed = true
+				}
+			}
+
+			sums := make([][3]float64, k)
+			weights := make([]float64, k)
+			for i, c := range colors {
+				cluster := assignments[i]
+				sums[cluster][0] += c.l * c.weight
+				sums[cluster][1] += c.a * c.weight
+				sums[cluster][2] += c.b * c.weight
+				weights[cluster] += c.weight
+			}
+
+			for i := range centroids {
+				if weights[i] == 0 {
+					// Keep empty clusters where they are; the next restart
+					// may place them better
+					continue
+				}
+				centroids[i] = [3]float64{
+					sums[i][0] / weights[i],
+					sums[i][1] / weights[i],
+					sums[i][2] / weights[i],
+				}
+			}
+
+			if !changed {
+				break
+			}
+		}
+
+		inertia := 0.0
+		for i, c := range colors {
+			inertia += c.weight * labDistance(c.labColor, centroids[assignments[i]])
+		}
+
+		if inertia < bestInertia {
+			bestInertia = inertia
+			for i, c := range centroids {
+				bestPalette[i] = okLabToRGBA(c[0], c[1], c[2])
+			}
+		}
+	}
+
+	sort.SliceStable(bestPalette, func(i, j int) bool {
+		return lessByLuminanceHue(bestPalette[i], bestPalette[j])
+	})
+
+	return bestPalette
+}
+
+// initCentroidsKMeansPPWeighted initializes centroids using k-means++ where
+// the chance of picking a color also scales with its pixel count
+func initCentroidsKMeansPPWeighted(
+	colors []weightedLabColor,
+	k int,
+	rng *rand.Rand,
+) [][3]float64 {
+	centroids := make([][3]float64, 0, k)
+
+	pick := func(scores []float64, sum float64) weightedLabColor {
+		target := rng.Float64() * sum
+		acc := 0.0
+		for i, score := range scores {
+			acc += score
+			if acc >= target {
+				return colors[i]
+			}
+		}
+		return colors[len(colors)-1]
+	}
+
+	scores := make([]float64, len(colors))
+	sum := 0.0
+	for i, c := range colors {
+		scores[i] = c.weight
+		sum += c.weight
+	}
+
+	first := pick(scores, sum)
+	centroids = append(centroids, [3]float64{first.l, first.a, first.b})
+
+	for len(centroids) < k {
+		sum = 0.0
+		for i, c := range colors {
+			scores[i] = c.weight * labDistance(c.labColor, centroids[nearestCentroid(c.labColor, centroids)])
+			sum += scores[i]
+		}
+
+		// Every color already coincides with a centroid
+		if sum == 0 {
+			break
+		}
+
+		next := pick(scores, sum)
+		centroids = append(centroids, [3]float64{next.l, next.a, next.b})
+	}
+
+	// Fewer distinct positions than clusters; duplicate the first centroid
+	// so that the palette still has k entries
+	for len(centroids) < k {
+		centroids = append(centroids, centroids[0])
+	}
+
+	return centroids
+}
+
+func nearestCentroid(c labColor, centroids [][3]float64) int {
+	best := 0
+	minDist := math.MaxFloat64
+	for j, centroid := range centroids {
+		if dist := labDistance(c, centroid); dist < minDist {
+			minDist = dist
+			best = j
+		}
+	}
+	return best
+}
+
+// labDistance is the squared OKLab distance between a color and a centroid
+func labDistance(c labColor, centroid [3]float64) float64 {
+	dl := c.l - centroid[0]
+	da := c.a - centroid[1]
+	db := c.b - centroid[2]
+	return dl*dl + da*da + db*db
+}
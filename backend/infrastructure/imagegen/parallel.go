@@ -0,0 +1,52 @@
+package imagegen
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minShardRows keeps shards large enough that starting a goroutine costs
+// less than the rows it works on
+const minShardRows = 16
+
+// WithPixelWorkers sets how many goroutines per-pixel operations are split
+// across, 0 for one per GOMAXPROCS
+func WithPixelWorkers(workers int) Option {
+	return func(ig *ImageGen) {
+		ig.pixelWorkers = workers
+	}
+}
+
+// workers is how many goroutines a per-pixel operation may use
+func (ig *ImageGen) workers() int {
+	if ig.pixelWorkers > 0 {
+		return ig.pixelWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parallelRows splits the rows [0, height) into at most workers contiguous
+// shards and calls fn for each on its own goroutine, returning once all are
+// done. Shards never share rows, so fn can write the rows of an image it's
+// given without locking, and shard indexes are below workers so results can
+// be gathered per shard and merged in order, keeping output deterministic.
+func parallelRows(height, workers int, fn func(shard, start, end int)) {
+	shards := min(max(workers, 1), (height+minShardRows-1)/minShardRows)
+	if shards <= 1 {
+		fn(0, 0, height)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for shard := range shards {
+		start := height * shard / shards
+		end := height * (shard + 1) / shards
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(shard, start, end)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,519 @@
+package imagegen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nfnt/resize"
+)
+
+// testImage makes an image of random colors drawn from a limited set, like
+// the pixel art and photos palette nodes are used on
+func testImage(width, height, colors int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+
+	palette := make([]color.RGBA, colors)
+	for i := range palette {
+		palette[i] = color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		c := palette[rng.Intn(colors)]
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func testPalette(colors int) []color.Color {
+	img := testImage(colors, 1, colors)
+	palette := make([]color.Color, colors)
+	for i := range palette {
+		palette[i] = img.RGBAAt(i, 0)
+	}
+	return palette
+}
+
+// mapImageToPalettePerPixel is how palette mapping was done before it
+// worked on pixels directly, to compare against
+func mapImageToPalettePerPixel(sourceImg image.Image, palette []color.Color) image.Image {
+	bounds := sourceImg.Bounds()
+	outputImg := image.NewRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r1, g1, b1, _ := sourceImg.At(x, y).RGBA()
+
+			minDist := float64(1000000)
+			var nearestColor color.Color = palette[0]
+			for _, pc := range palette {
+				r2, g2, b2, _ := pc.RGBA()
+				dr := float64(r1>>8) - float64(r2>>8)
+				dg := float64(g1>>8) - float64(g2>>8)
+				db := float64(b1>>8) - float64(b2>>8)
+				if dist := dr*dr + dg*dg + db*db; dist < minDist {
+					minDist = dist
+					nearestColor = pc
+				}
+			}
+
+			outputImg.Set(x, y, nearestColor)
+		}
+	}
+
+	return outputImg
+}
+
+// inflatePixelsPerPixel is how pixel inflate was done before it worked on
+// pixels directly, to compare against
+func inflatePixelsPerPixel(img image.Image, width int, lineWidth int, lineCol color.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	originalWidth := bounds.Dx()
+	originalHeight := bounds.Dy()
+
+	targetWidth := uint(width)
+	targetHeight := uint(float64(width) * float64(originalHeight) / float64(originalWidth))
+
+	scaledImg := resize.Resize(targetWidth, targetHeight, img, resize.NearestNeighbor)
+
+	scaledBounds := scaledImg.Bounds()
+	outputImg := image.NewRGBA(scaledBounds)
+	for y := scaledBounds.Min.Y; y < scaledBounds.Max.Y; y++ {
+		for x := scaledBounds.Min.X; x < scaledBounds.Max.X; x++ {
+			outputImg.Set(x, y, scaledImg.At(x, y))
+		}
+	}
+
+	scaleX := float64(targetWidth) / float64(originalWidth)
+	scaleY := float64(targetHeight) / float64(originalHeight)
+
+	for i := range originalWidth - 1 {
+		x := int(float64(i+1) * scaleX)
+		for lineOffset := range lineWidth {
+			xPos := x + lineOffset - lineWidth/2
+			if xPos >= 0 && xPos < int(targetWidth) {
+				for y := range int(targetHeight) {
+					outputImg.Set(xPos, y, lineCol)
+				}
+			}
+		}
+	}
+
+	for i := range originalHeight - 1 {
+		y := int(float64(i+1) * scaleY)
+		for lineOffset := range lineWidth {
+			yPos := y + lineOffset - lineWidth/2
+			if yPos >= 0 && yPos < int(targetHeight) {
+				for x := range int(targetWidth) {
+					outputImg.Set(x, yPos, lineCol)
+				}
+			}
+		}
+	}
+
+	return outputImg
+}
+
+func TestMapImageToPalette(t *testing.T) {
+	source := testImage(64, 48, 200)
+	palette := testPalette(16)
+
+	want := mapImageToPalettePerPixel(source, palette).(*image.RGBA)
+
+	for _, workers := range []int{1, 4} {
+		got := mapImageToPalette(source, palette, paletteDistanceRGB, paletteAlpha{}, workers).(*image.RGBA)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("expected the same colors as mapping pixel by pixel with %d workers", workers)
+		}
+	}
+
+	// Sources that aren't RGBA are read through a pooled buffer, which must
+	// not carry pixels over between images
+	gray := image.NewGray(source.Bounds())
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i)
+	}
+	want = mapImageToPalettePerPixel(gray, palette).(*image.RGBA)
+	for range 2 {
+		got := mapImageToPalette(gray, palette, paletteDistanceRGB, paletteAlpha{}, 4).(*image.RGBA)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Error("expected the same colors as mapping a gray image pixel by pixel")
+		}
+	}
+}
+
+func TestMapImageToPaletteAlpha(t *testing.T) {
+	palette := []color.Color{
+		color.RGBA{A: 255},
+		color.RGBA{R: 255, A: 255},
+		color.RGBA{R: 255, G: 255, B: 255, A: 255},
+	}
+
+	// Half transparent red, which premultiplied is nearer black than red,
+	// fully transparent white and opaque white
+	source := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	source.SetNRGBA(0, 0, color.NRGBA{R: 250, A: 128})
+	source.SetNRGBA(1, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 0})
+	source.SetNRGBA(2, 0, color.NRGBA{R: 250, G: 250, B: 250, A: 255})
+
+	pixels := func(img image.Image) []color.NRGBA {
+		var got []color.NRGBA
+		for x := range 3 {
+			got = append(got, color.NRGBAModel.Convert(img.At(x, 0)).(color.NRGBA))
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		alpha paletteAlpha
+		want  []color.NRGBA
+	}{
+		{
+			name:  "opaque",
+			alpha: paletteAlpha{},
+			want:  []color.NRGBA{{A: 255}, {A: 255}, {R: 255, G: 255, B: 255, A: 255}},
+		},
+		{
+			name:  "keeps alpha",
+			alpha: paletteAlpha{keep: true},
+			want:  []color.NRGBA{{R: 255, A: 128}, {}, {R: 255, G: 255, B: 255, A: 255}},
+		},
+		{
+			name:  "keeps transparent pixels",
+			alpha: paletteAlpha{keep: true, keepTransparent: true},
+			// Copied premultiplied, so red loses a little to rounding
+			want: []color.NRGBA{{R: 249, A: 128}, {}, {R: 255, G: 255, B: 255, A: 255}},
+		},
+		{
+			name:  "threshold below alpha",
+			alpha: paletteAlpha{keep: true, threshold: 100},
+			want:  []color.NRGBA{{R: 255, A: 255}, {}, {R: 255, G: 255, B: 255, A: 255}},
+		},
+		{
+			name:  "threshold above alpha",
+			alpha: paletteAlpha{keep: true, threshold: 200},
+			want:  []color.NRGBA{{}, {}, {R: 255, G: 255, B: 255, A: 255}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pixels(mapImageToPalette(source, palette, paletteDistanceRGB, tt.alpha, 1))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInflatePixels(t *testing.T) {
+	source := testImage(16, 12, 8)
+	lineCol := color.RGBA{R: 255, A: 255}
+
+	for _, lineWidth := range []int{0, 1, 3} {
+		want := inflatePixelsPerPixel(source, 160, lineWidth, lineCol)
+
+		for _, workers := range []int{1, 4} {
+			got := inflatePixels(source, 160, lineWidth, lineCol, workers)
+			if got.Rect != want.Rect || !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("expected the same pixels as inflating pixel by pixel with line width %d and %d workers", lineWidth, workers)
+			}
+		}
+	}
+
+	t.Run("never draws on the input", func(t *testing.T) {
+		before := bytes.Clone(source.Pix)
+
+		// Inflating to the same width doesn't scale, so the input isn't copied
+		// by resizing
+		inflatePixels(source, 16, 1, lineCol, 1)
+
+		if !bytes.Equal(source.Pix, before) {
+			t.Error("expected the input image to be unchanged")
+		}
+	})
+}
+
+func TestColorTree(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// Duplicates check ties go to the first color, as a linear scan's would
+	palette := make([]color.RGBA, 0, 66)
+	for range 64 {
+		palette = append(palette, color.RGBA{R: uint8(rng.Intn(256)), G: uint8(rng.Intn(256)), B: uint8(rng.Intn(256)), A: 255})
+	}
+	palette = append(palette, palette[3], palette[10])
+
+	for _, distance := range []string{paletteDistanceRGB, paletteDistanceOKLab} {
+		tree := newColorTree(palette, distance)
+
+		for range 2000 {
+			r, g, b := uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256))
+
+			point := tree.toPoint(r, g, b)
+			want, wantDist := 0, math.Inf(1)
+			for i, c := range palette {
+				p := tree.toPoint(c.R, c.G, c.B)
+				if dist := (point[0]-p[0])*(point[0]-p[0]) + (point[1]-p[1])*(point[1]-p[1]) + (point[2]-p[2])*(point[2]-p[2]); dist < wantDist {
+					want, wantDist = i, dist
+				}
+			}
+
+			if got := tree.nearest(r, g, b); got != palette[want] {
+				t.Fatalf("expected %v nearest to %v in %s, got %v", palette[want], color.RGBA{R: r, G: g, B: b, A: 255}, distance, got)
+			}
+		}
+	}
+}
+
+func TestExtractColorsFromImage(t *testing.T) {
+	source := testImage(64, 48, 200)
+
+	distinct := make(map[color.RGBA]bool)
+	for y := range source.Rect.Dy() {
+		for x := range source.Rect.Dx() {
+			distinct[source.RGBAAt(x, y)] = true
+		}
+	}
+
+	want := extractColorsFromImage(source, 1)
+	if len(want) != len(distinct) {
+		t.Fatalf("expected %d colors, got %d", len(distinct), len(want))
+	}
+
+	got := extractColorsFromImage(source, 4)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d colors with 4 workers, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected the same colors in the same order with 4 workers, got %v at %d, want %v", got[i], i, want[i])
+		}
+	}
+}
+
+func TestParallelRows(t *testing.T) {
+	for _, height := range []int{0, 1, 15, 16, 100, 2160} {
+		covered := make([]int, height)
+		shards := make([]bool, 8)
+
+		parallelRows(height, 8, func(shard, start, end int) {
+			shards[shard] = true
+			for y := start; y < end; y++ {
+				covered[y]++
+			}
+		})
+
+		for y, count := range covered {
+			if count != 1 {
+				t.Fatalf("expected row %d of %d to be covered once, got %d", y, height, count)
+			}
+		}
+	}
+}
+
+// 4K is the size large pipelines are usually run at
+const width4K, height4K = 3840, 2160
+
+// benchmarkWorkers runs a benchmark on one goroutine and on one per
+// GOMAXPROCS
+func benchmarkWorkers(b *testing.B, fn func(workers int)) {
+	for _, workers := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				fn(workers)
+			}
+		})
+	}
+}
+
+func BenchmarkMapImageToPalette(b *testing.B) {
+	source := testImage(1024, 768, 4096)
+	palette := testPalette(32)
+
+	b.Run("per pixel", func(b *testing.B) {
+		for b.Loop() {
+			mapImageToPalettePerPixel(source, palette)
+		}
+	})
+
+	b.Run("pix", func(b *testing.B) {
+		for b.Loop() {
+			mapImageToPalette(source, palette, paletteDistanceRGB, paletteAlpha{}, 1)
+		}
+	})
+
+	b.Run("4K", func(b *testing.B) {
+		source := testImage(width4K, height4K, 4096)
+		benchmarkWorkers(b, func(workers int) {
+			mapImageToPalette(source, palette, paletteDistanceRGB, paletteAlpha{}, workers)
+		})
+	})
+
+	// A photo's worth of colors mapped to a full palette, where looking up
+	// each color by scanning the palette dominates
+	b.Run("4000x4000 to 256 colors", func(b *testing.B) {
+		source := testImage(4000, 4000, 1<<18)
+		palette := testPalette(256)
+
+		b.Run("per pixel", func(b *testing.B) {
+			for b.Loop() {
+				mapImageToPalettePerPixel(source, palette)
+			}
+		})
+
+		for _, distance := range []string{paletteDistanceRGB, paletteDistanceOKLab} {
+			b.Run(distance, func(b *testing.B) {
+				for b.Loop() {
+					mapImageToPalette(source, palette, distance, paletteAlpha{}, runtime.GOMAXPROCS(0))
+				}
+			})
+		}
+	})
+}
+
+func BenchmarkExtractColorsFromImage(b *testing.B) {
+	source := testImage(width4K, height4K, 4096)
+	benchmarkWorkers(b, func(workers int) {
+		extractColorsFromImage(source, workers)
+	})
+}
+
+func BenchmarkInflatePixels(b *testing.B) {
+	source := testImage(128, 96, 16)
+	lineCol := color.RGBA{A: 255}
+
+	b.Run("per pixel", func(b *testing.B) {
+		for b.Loop() {
+			inflatePixelsPerPixel(source, 2048, 2, lineCol)
+		}
+	})
+
+	b.Run("pix", func(b *testing.B) {
+		for b.Loop() {
+			inflatePixels(source, 2048, 2, lineCol, 1)
+		}
+	})
+
+	b.Run("4K", func(b *testing.B) {
+		source := testImage(width4K/10, height4K/10, 16)
+		benchmarkWorkers(b, func(workers int) {
+			inflatePixels(source, width4K, 2, lineCol, workers)
+		})
+	})
+}
+
+func TestTransformImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.Pix = []uint8{200, 100, 50, 128}
+
+	tests := []struct {
+		transform string
+		want      []uint8
+	}{
+		{"invert", []uint8{55, 155, 205, 128}},
+		{"alpha", []uint8{128, 128, 128, 255}},
+		{"luminance", []uint8{118, 118, 118, 128}},
+		{"red", []uint8{200, 200, 200, 128}},
+		{"green", []uint8{100, 100, 100, 128}},
+		{"blue", []uint8{50, 50, 50, 128}},
+	}
+
+	for _, tt := range tests {
+		got, err := transformImage(img, tt.transform)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.transform, err)
+		}
+		if pix := toNRGBA(got).Pix; !bytes.Equal(pix, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.transform, tt.want, pix)
+		}
+	}
+
+	if !bytes.Equal(img.Pix, []uint8{200, 100, 50, 128}) {
+		t.Error("expected the source image to be left unchanged")
+	}
+
+	if _, err := transformImage(img, "sepia"); err == nil {
+		t.Error("expected an unknown transform to be rejected")
+	}
+}
+
+func TestContactSheetLayout(t *testing.T) {
+	layout := newContactSheetLayout(5, 4, 100, 10, true)
+
+	if layout.columns != 4 || layout.rows != 2 {
+		t.Fatalf("expected 4 columns and 2 rows, got %d and %d", layout.columns, layout.rows)
+	}
+
+	labelHeight := (glyphHeight + 4) * layout.labelScale
+	if got, want := layout.size(), image.Pt(4*100+5*10, 2*(100+labelHeight)+3*10); got != want {
+		t.Errorf("expected a %v sheet, got %v", want, got)
+	}
+
+	if got, want := layout.cell(5-1), image.Rect(10, 20+100+labelHeight, 110, 20+200+labelHeight); got != want {
+		t.Errorf("expected the last image in %v, got %v", want, got)
+	}
+
+	if got := newContactSheetLayout(2, 4, 100, 0, false).size(); got != image.Pt(200, 100) {
+		t.Errorf("expected a sheet as wide as its images, got %v", got)
+	}
+}
+
+func TestFitText(t *testing.T) {
+	if got := fitText("blur", 100, 1); got != "blur" {
+		t.Errorf("expected text that fits to be kept, got %q", got)
+	}
+
+	got := fitText("a very long node name", 60, 1)
+	if textWidth(got, 1) > 60 || got[len(got)-2:] != ".." {
+		t.Errorf("expected text cut to fit, got %q", got)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	drawText(img, image.Pt(0, 0), "i", color.RGBA{A: 255}, 1)
+	if img.RGBAAt(2, 1).A != 255 || img.RGBAAt(0, 1).A != 0 {
+		t.Error("expected the glyph's pixels to be drawn")
+	}
+}
+
+func TestCountFrames(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	var animation bytes.Buffer
+	if err := gif.EncodeAll(&animation, &gif.GIF{Image: []*image.Paletted{frame, frame, frame}, Delay: []int{1, 1, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := CountFrames(animation.Bytes()); got != 3 {
+		t.Errorf("expected 3 GIF frames, got %d", got)
+	}
+
+	seq := &frameSequence{
+		frames: []image.Image{testImage(4, 4, 2), testImage(4, 4, 2)},
+		delays: []time.Duration{time.Second, time.Second},
+	}
+	apng, err := encodeAPNG(seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := CountFrames(apng); got != 2 {
+		t.Errorf("expected 2 APNG frames, got %d", got)
+	}
+
+	var still bytes.Buffer
+	if err := png.Encode(&still, testImage(4, 4, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if got := CountFrames(still.Bytes()); got != 1 {
+		t.Errorf("expected a PNG to have 1 frame, got %d", got)
+	}
+}
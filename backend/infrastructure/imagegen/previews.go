@@ -0,0 +1,57 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Previews of intermediate nodes can be skipped during generation to save
+// time and storage in large pipelines. Their previews are generated later,
+// when somebody asks for them.
+
+type skipPreviewKey struct{}
+
+// WithoutPreview returns a context that makes generation on it skip saving
+// the node's preview
+func WithoutPreview(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipPreviewKey{}, true)
+}
+
+func previewSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipPreviewKey{}).(bool)
+	return skip
+}
+
+// GeneratePreview generates the preview of a node from one of its output
+// images on demand, for nodes whose preview was skipped during generation,
+// and returns the ID of the preview image. Animated images are previewed by
+// their first frame.
+func (ig *ImageGen) GeneratePreview(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	outputImageID imagegraph.ImageID,
+) (
+	imagegraph.ImageID,
+	error,
+) {
+	outputImage, err := ig.loadImage(ctx, outputImageID)
+	if err != nil {
+		return imagegraph.ImageID{}, err
+	}
+
+	previewImageID, err := ig.savePreview(ctx, outputImage)
+	if err != nil {
+		return imagegraph.ImageID{}, err
+	}
+
+	err = ig.nodeUpdater.SetNodePreviewImage(ctx, imageGraphID, nodeID, previewImageID, nodeVersion)
+	if err != nil {
+		return imagegraph.ImageID{}, fmt.Errorf("could not set node preview image: %w", err)
+	}
+
+	return previewImageID, nil
+}
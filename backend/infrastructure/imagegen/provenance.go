@@ -0,0 +1,53 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// The provenance of a node's outputs is recorded as each is saved, when the
+// storage keeps provenance and generation runs on a context carrying it
+
+type provenanceKey struct{}
+
+// imageProvenanceStorage is implemented by image storages that record how
+// generated images were produced
+type imageProvenanceStorage interface {
+	SaveProvenance(imageID imagegraph.ImageID, provenance imagegraph.ImageProvenance) error
+}
+
+// WithProvenance returns a context that makes generation on it record the
+// provenance of each output it saves
+func WithProvenance(ctx context.Context, provenance imagegraph.ImageProvenance) context.Context {
+	return context.WithValue(ctx, provenanceKey{}, provenance)
+}
+
+// saveProvenance records the provenance of a saved output image, completed
+// with the output's name and the time it was saved
+func (ig *ImageGen) saveProvenance(
+	ctx context.Context,
+	imageID imagegraph.ImageID,
+	outputName imagegraph.OutputName,
+) error {
+	provenanceStorage, ok := ig.imageStorage.(imageProvenanceStorage)
+	if !ok {
+		return nil
+	}
+
+	provenance, ok := ctx.Value(provenanceKey{}).(imagegraph.ImageProvenance)
+	if !ok {
+		return nil
+	}
+
+	provenance.OutputName = outputName
+	provenance.ProducedAt = time.Now().UTC()
+
+	if err := provenanceStorage.SaveProvenance(imageID, provenance); err != nil {
+		return fmt.Errorf("could not save provenance of image %q: %w", imageID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GenerateOutputsForSwitchNode forwards the image on a switch node's
+// selected input to its output. The stored image is copied as it is, without
+// decoding and encoding it again, unless its connection transforms it.
+func (ig *ImageGen) GenerateOutputsForSwitchNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	inputImageID imagegraph.ImageID,
+	selected imagegraph.InputName,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeSwitch)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeSwitch, imageGraphID, nodeID, nodeVersion,
+		"selected", selected,
+	)
+
+	if input, ok := transformedInput(ctx, inputImageID); ok {
+		frames, err := ig.loadTransformedFrames(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, frames.first())
+		rec.preview(err)
+		if err != nil {
+			return fmt.Errorf("could not generate outputs for switch node: %w", err)
+		}
+
+		err = ig.saveAndSetOutputFrames(ctx, imageGraphID, nodeID, "output", nodeVersion, frames, "")
+		rec.output(err)
+		if err != nil {
+			return fmt.Errorf("could not generate outputs for switch node: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("generation stopped before loading image: %w", err)
+	}
+
+	imageData, err := ig.getImage(ctx, inputImageID)
+	if err != nil {
+		return fmt.Errorf("could not get image: %w", err)
+	}
+
+	// Only the preview needs the image decoded
+	if !previewSkipped(ctx) {
+		img, err := ig.loadImage(ctx, inputImageID)
+		if err != nil {
+			return err
+		}
+
+		err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, img)
+		rec.preview(err)
+		if err != nil {
+			return fmt.Errorf("could not generate outputs for switch node: %w", err)
+		}
+	}
+
+	metadata := ig.getImageMetadata(ctx, inputImageID)
+
+	err = ig.saveAndSetOutputData(ctx, imageGraphID, nodeID, "output", nodeVersion, imageData, metadata)
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for switch node: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package imagegen
+
+import (
+	"context"
+	"image"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// Successful generations are timed per node type, along with the pixels of
+// the images they load, so the time regenerating a node takes can be
+// estimated before it's started. Timings are kept in memory and start over
+// when the server restarts.
+
+type generationTimings struct {
+	mu     sync.Mutex
+	totals map[string]*generationTotals
+}
+
+type generationTotals struct {
+	count int
+	total time.Duration
+	// The time taken and pixels loaded by the generations that loaded images
+	pixelTime time.Duration
+	pixels    int64
+}
+
+func newGenerationTimings() *generationTimings {
+	return &generationTimings{totals: make(map[string]*generationTotals)}
+}
+
+func (t *generationTimings) record(nodeType string, elapsed time.Duration, pixels int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	totals, ok := t.totals[nodeType]
+	if !ok {
+		totals = &generationTotals{}
+		t.totals[nodeType] = totals
+	}
+
+	totals.count++
+	totals.total += elapsed
+	if pixels > 0 {
+		totals.pixelTime += elapsed
+		totals.pixels += pixels
+	}
+}
+
+// GenerationTimings returns the timings recorded for each node type that
+// has generated successfully since the server started
+func (ig *ImageGen) GenerationTimings() map[imagegraph.NodeType]imagegraph.GenerationTiming {
+	timings := make(map[imagegraph.NodeType]imagegraph.GenerationTiming)
+	if ig.timings == nil {
+		return timings
+	}
+
+	ig.timings.mu.Lock()
+	defer ig.timings.mu.Unlock()
+
+	for name, totals := range ig.timings.totals {
+		// Bypassed nodes are timed under their own name, which isn't a
+		// node type
+		nodeType, err := imagegraph.NodeTypeMapper.To(name)
+		if err != nil {
+			continue
+		}
+
+		timing := imagegraph.GenerationTiming{
+			Count: totals.count,
+			Mean:  totals.total / time.Duration(totals.count),
+		}
+		if totals.pixels > 0 {
+			timing.PerMegapixel = time.Duration(float64(totals.pixelTime) * 1e6 / float64(totals.pixels))
+		}
+		timings[nodeType] = timing
+	}
+
+	return timings
+}
+
+type pixelCountsKey struct{}
+
+// pixelCounts counts the pixels of the images a generation loads and saves
+type pixelCounts struct {
+	loaded atomic.Int64
+	saved  atomic.Int64
+}
+
+// withPixelCounts returns a context that counts the pixels of the images
+// loaded and saved on it, keeping the counts ctx already has
+func withPixelCounts(ctx context.Context) (context.Context, *pixelCounts) {
+	if counts, ok := ctx.Value(pixelCountsKey{}).(*pixelCounts); ok {
+		return ctx, counts
+	}
+	counts := &pixelCounts{}
+	return context.WithValue(ctx, pixelCountsKey{}, counts), counts
+}
+
+// countLoadedPixels adds the pixels of an image loaded on ctx to its
+// counts, if it has them
+func countLoadedPixels(ctx context.Context, bounds image.Rectangle) {
+	if counts, ok := ctx.Value(pixelCountsKey{}).(*pixelCounts); ok {
+		counts.loaded.Add(int64(bounds.Dx()) * int64(bounds.Dy()))
+	}
+}
+
+// countSavedPixels adds the pixels of an output saved on ctx to its counts,
+// if it has them
+func countSavedPixels(ctx context.Context, bounds image.Rectangle) {
+	if counts, ok := ctx.Value(pixelCountsKey{}).(*pixelCounts); ok {
+		counts.saved.Add(int64(bounds.Dx()) * int64(bounds.Dy()))
+	}
+}
+
+// GenerationSize is the pixels of the images a generation loaded as input
+// and saved as output. Animations count their first frame.
+type GenerationSize struct {
+	InputPixels  int64
+	OutputPixels int64
+}
+
+// WithGenerationSize returns a context that counts the pixels generation on
+// it loads and saves, and a function returning the counts so far
+func WithGenerationSize(ctx context.Context) (context.Context, func() GenerationSize) {
+	ctx, counts := withPixelCounts(ctx)
+	return ctx, func() GenerationSize {
+		return GenerationSize{
+			InputPixels:  counts.loaded.Load(),
+			OutputPixels: counts.saved.Load(),
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package imagegen
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/tracing"
+)
+
+var tracer = otel.Tracer("github.com/dmpettyp/artwork/infrastructure/imagegen")
+
+// getImage reads an image from storage in a span of the generation's trace
+func (ig *ImageGen) getImage(ctx context.Context, imageID imagegraph.ImageID) ([]byte, error) {
+	_, span := tracer.Start(ctx, "ImageStorage.Get", trace.WithAttributes(
+		attribute.String("artwork.image_id", imageID.String()),
+	))
+
+	imageData, err := ig.imageStorage.Get(imageID)
+	span.SetAttributes(attribute.Int("artwork.image_bytes", len(imageData)))
+	tracing.End(span, err)
+
+	return imageData, err
+}
+
+// getImageMetadata reads the metadata of an image from storage in a span of
+// the generation's trace. It returns nil if the storage doesn't keep
+// metadata or the image's can't be read, as metadata is never required.
+func (ig *ImageGen) getImageMetadata(ctx context.Context, imageID imagegraph.ImageID) *imagegraph.ImageMetadata {
+	metadataStorage, ok := ig.imageStorage.(imageMetadataStorage)
+	if !ok {
+		return nil
+	}
+
+	_, span := tracer.Start(ctx, "ImageStorage.GetMetadata", trace.WithAttributes(
+		attribute.String("artwork.image_id", imageID.String()),
+	))
+
+	metadata, err := metadataStorage.GetMetadata(imageID)
+	tracing.End(span, err)
+
+	if err != nil {
+		return nil
+	}
+	return &metadata
+}
+
+// saveImage writes an image to storage in a span of the generation's trace,
+// with its metadata if it has any and the storage keeps metadata
+func (ig *ImageGen) saveImage(
+	ctx context.Context,
+	imageID imagegraph.ImageID,
+	imageData []byte,
+	metadata *imagegraph.ImageMetadata,
+) error {
+	_, span := tracer.Start(ctx, "ImageStorage.Save", trace.WithAttributes(
+		attribute.String("artwork.image_id", imageID.String()),
+		attribute.Int("artwork.image_bytes", len(imageData)),
+	))
+
+	var err error
+	if metadataStorage, ok := ig.imageStorage.(imageMetadataStorage); ok && metadata != nil {
+		err = metadataStorage.SaveWithMetadata(imageID, imageData, *metadata)
+	} else {
+		err = ig.imageStorage.Save(imageID, imageData)
+	}
+	tracing.End(span, err)
+
+	return err
+}
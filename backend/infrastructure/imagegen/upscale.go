@@ -0,0 +1,162 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/nfnt/resize"
+)
+
+const upscaleTimeout = 2 * time.Minute
+
+// upscaler is implemented by super-resolution backends. It returns the
+// encoded upscaled image.
+type upscaler interface {
+	upscale(
+		ctx context.Context,
+		imageData []byte,
+		scale int,
+		model string,
+	) ([]byte, error)
+}
+
+// WithUpscaler enables upscale nodes using the ESRGAN-style upscaling
+// service at baseURL
+func WithUpscaler(baseURL string) Option {
+	return func(ig *ImageGen) {
+		ig.upscaler = &httpUpscaler{
+			baseURL: strings.TrimRight(baseURL, "/"),
+			client:  &http.Client{},
+		}
+	}
+}
+
+func (ig *ImageGen) GenerateOutputsForUpscaleNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	nodeVersion imagegraph.NodeVersion,
+	inputImageID imagegraph.ImageID,
+	scale int,
+	model string,
+) (err error) {
+	ctx, rec := ig.newRecorder(ctx, nodeTypeUpscale)
+	defer func() {
+		rec.total(err)
+	}()
+
+	ig.logGeneration(ctx, nodeTypeUpscale, imageGraphID, nodeID, nodeVersion,
+		"scale", scale,
+		"model", model,
+	)
+
+	if ig.upscaler == nil {
+		return fmt.Errorf("upscaler is not configured")
+	}
+
+	originalImage, err := ig.loadImage(ctx, inputImageID)
+	if err != nil {
+		return err
+	}
+
+	imageData, err := ig.encodeImage(originalImage)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, upscaleTimeout)
+	defer cancel()
+
+	upscaledData, err := ig.upscaler.upscale(ctx, imageData, scale, model)
+	if err != nil {
+		return fmt.Errorf("could not upscale image with %s: %w", model, err)
+	}
+
+	upscaledImage, _, err := image.Decode(bytes.NewReader(upscaledData))
+	if err != nil {
+		return fmt.Errorf("could not decode upscaled image: %w", err)
+	}
+
+	// Models have a fixed native scale, so bring the result to exactly
+	// scale times the original size
+	bounds := originalImage.Bounds()
+	width := bounds.Dx() * scale
+	height := bounds.Dy() * scale
+
+	if upscaledImage.Bounds().Dx() != width || upscaledImage.Bounds().Dy() != height {
+		upscaledImage = resize.Resize(uint(width), uint(height), upscaledImage, resize.Lanczos3)
+	}
+
+	err = ig.saveAndSetPreview(ctx, imageGraphID, nodeID, nodeVersion, upscaledImage)
+	rec.preview(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for upscale node: %w", err)
+	}
+
+	err = ig.saveAndSetOutput(ctx, imageGraphID, nodeID, "upscaled", nodeVersion, upscaledImage)
+	rec.output(err)
+	if err != nil {
+		return fmt.Errorf("could not generate outputs for upscale node: %w", err)
+	}
+
+	return nil
+}
+
+// httpUpscaler posts images to an upscaling service that accepts a
+// multipart form with image, scale and model fields and responds with the
+// upscaled image
+type httpUpscaler struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (u *httpUpscaler) upscale(
+	ctx context.Context,
+	imageData []byte,
+	scale int,
+	model string,
+) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	_ = writer.WriteField("scale", fmt.Sprint(scale))
+	_ = writer.WriteField("model", model)
+
+	part, err := writer.CreateFormFile("image", "original.png")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(imageData); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.baseURL+"/upscale", &body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	httpReq.Header.Set("Accept", "image/*")
+
+	resp, err := u.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(resp.Body)
+}
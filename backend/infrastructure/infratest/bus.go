@@ -0,0 +1,147 @@
+package infratest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+)
+
+// flushCommand is handled once the message bus has dispatched every event
+// queued before it, since the bus handles one message at a time
+type flushCommand struct {
+	messages.BaseCommand
+}
+
+func newFlushCommand() *flushCommand {
+	command := &flushCommand{}
+	command.Init("TestsupportFlushCommand")
+	return command
+}
+
+// settleTimeout bounds how long MustSettle waits for a pipeline
+const settleTimeout = 10 * time.Second
+
+// Idler does work in the background of the message bus that sends commands
+// when it finishes, as application.ImageGraphEventHandlers does generating
+// node outputs
+type Idler interface {
+	// WaitIdle waits until no background work is running and returns how
+	// much has finished so far
+	WaitIdle(ctx context.Context) (uint64, error)
+}
+
+// Bus runs a message bus for the length of a test, handling commands
+// synchronously: a command returns once the events it led to have been
+// handled too. Output generation the event handlers start in the background
+// is waited for by Settle.
+type Bus struct {
+	t  testing.TB
+	mb *messagebus.MessageBus
+}
+
+// StartBus starts a message bus, which is stopped when the test ends. Every
+// handler must be registered with the bus before it is started.
+func StartBus(t testing.TB, mb *messagebus.MessageBus) *Bus {
+	t.Helper()
+
+	err := messagebus.RegisterCommandHandler(mb, func(context.Context, *flushCommand) ([]messages.Event, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register flush handler: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go mb.Start(ctx)
+	t.Cleanup(cancel)
+
+	return &Bus{t: t, mb: mb}
+}
+
+// MessageBus returns the running message bus, for components that send
+// their own commands
+func (b *Bus) MessageBus() *messagebus.MessageBus {
+	return b.mb
+}
+
+// Handle handles a command and every event that follows from it
+func (b *Bus) Handle(ctx context.Context, command messages.Command) error {
+	if err := b.mb.HandleCommand(ctx, command); err != nil {
+		return err
+	}
+
+	return b.flush(ctx)
+}
+
+// MustHandle handles a command and every event that follows from it,
+// failing the test if the command fails
+func (b *Bus) MustHandle(command messages.Command) {
+	b.t.Helper()
+
+	if err := b.Handle(context.Background(), command); err != nil {
+		b.t.Fatalf("failed to handle %s: %v", command.GetType(), err)
+	}
+}
+
+// Flush waits until the events of every command handled so far, including
+// commands sent by other goroutines, have been handled
+func (b *Bus) Flush() {
+	b.t.Helper()
+
+	if err := b.flush(context.Background()); err != nil {
+		b.t.Fatalf("%v", err)
+	}
+}
+
+// Settle waits until the pipeline has nothing left to do: every event is
+// handled and none of the idlers' background work is running, nor has any
+// finished since the bus was last flushed, so its commands have been
+// handled too
+func (b *Bus) Settle(ctx context.Context, idlers ...Idler) error {
+	var last uint64
+	checked := false
+
+	for {
+		if err := b.flush(ctx); err != nil {
+			return err
+		}
+
+		var finished uint64
+		for _, idler := range idlers {
+			n, err := idler.WaitIdle(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to settle: %w", err)
+			}
+			finished += n
+		}
+
+		if checked && finished == last {
+			return nil
+		}
+		last, checked = finished, true
+	}
+}
+
+// MustSettle waits for the pipeline to settle, failing the test if it
+// doesn't within 10 seconds
+func (b *Bus) MustSettle(idlers ...Idler) {
+	b.t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), settleTimeout)
+	defer cancel()
+
+	if err := b.Settle(ctx, idlers...); err != nil {
+		b.t.Fatalf("%v", err)
+	}
+}
+
+func (b *Bus) flush(ctx context.Context) error {
+	if err := b.mb.HandleCommand(ctx, newFlushCommand()); err != nil {
+		return fmt.Errorf("failed to flush message bus: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package infratest provides in-memory stand-ins for the infrastructure the
+// application runs on, so that code embedding the application package can
+// test it quickly and deterministically:
+//
+//	storage := infratest.NewImageStorage()
+//	clock := infratest.NewClock(time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC))
+//	mb := messagebus.New()
+//	// ... register handlers with storage and clock ...
+//	bus := infratest.StartBus(t, mb)
+//	bus.MustHandle(application.NewCreateImageGraphCommand(id, "test", "", ""))
+//	bus.MustSettle(eventHandlers)
+//
+// ImageStorage implements every optional capability of the filesystem image
+// storage, Clock only moves when told to, and Bus handles a command along
+// with every event it leads to before returning, and can wait for the
+// output generation those events start.
+package infratest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/filestorage"
+)
+
+// ImageStorage is an in-memory image storage keeping the metadata and
+// provenance of its images, as the filesystem image storage does
+type ImageStorage struct {
+	mu         sync.Mutex
+	images     map[imagegraph.ImageID][]byte
+	metadata   map[imagegraph.ImageID]imagegraph.ImageMetadata
+	provenance map[imagegraph.ImageID]imagegraph.ImageProvenance
+}
+
+// NewImageStorage creates an empty in-memory image storage
+func NewImageStorage() *ImageStorage {
+	return &ImageStorage{
+		images:     make(map[imagegraph.ImageID][]byte),
+		metadata:   make(map[imagegraph.ImageID]imagegraph.ImageMetadata),
+		provenance: make(map[imagegraph.ImageID]imagegraph.ImageProvenance),
+	}
+}
+
+// Save stores an image along with the metadata read from it
+func (s *ImageStorage) Save(imageID imagegraph.ImageID, imageData []byte) error {
+	return s.SaveWithMetadata(imageID, imageData, filestorage.ReadImageMetadata(imageData))
+}
+
+func (s *ImageStorage) SaveWithMetadata(
+	imageID imagegraph.ImageID,
+	imageData []byte,
+	metadata imagegraph.ImageMetadata,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images[imageID] = imageData
+	s.metadata[imageID] = metadata
+	return nil
+}
+
+func (s *ImageStorage) Get(imageID imagegraph.ImageID) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imageData, ok := s.images[imageID]
+	if !ok {
+		return nil, fmt.Errorf("image not found: %s", imageID)
+	}
+	return imageData, nil
+}
+
+func (s *ImageStorage) GetMetadata(imageID imagegraph.ImageID) (imagegraph.ImageMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metadata, ok := s.metadata[imageID]
+	if !ok {
+		return metadata, fmt.Errorf("image not found: %s", imageID)
+	}
+	return metadata, nil
+}
+
+func (s *ImageStorage) SaveProvenance(imageID imagegraph.ImageID, provenance imagegraph.ImageProvenance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.provenance[imageID] = provenance
+	return nil
+}
+
+func (s *ImageStorage) GetProvenance(imageID imagegraph.ImageID) (*imagegraph.ImageProvenance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	provenance, ok := s.provenance[imageID]
+	if !ok {
+		return nil, nil
+	}
+	return &provenance, nil
+}
+
+func (s *ImageStorage) Exists(imageID imagegraph.ImageID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.images[imageID]
+	return ok, nil
+}
+
+// Remove deletes an image with its metadata and provenance. Removing an
+// image that isn't stored is not an error.
+func (s *ImageStorage) Remove(imageID imagegraph.ImageID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.images, imageID)
+	delete(s.metadata, imageID)
+	delete(s.provenance, imageID)
+	return nil
+}
+
+// Copy saves a copy of an image, with its metadata and provenance, under
+// another ID
+func (s *ImageStorage) Copy(from imagegraph.ImageID, to imagegraph.ImageID) error {
+	return filestorage.CopyImage(s, from, to)
+}
+
+// Inventory returns the size in bytes of every stored image
+func (s *ImageStorage) Inventory() (map[imagegraph.ImageID]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inventory := make(map[imagegraph.ImageID]int64, len(s.images))
+	for imageID, imageData := range s.images {
+		inventory[imageID] = int64(len(imageData))
+	}
+	return inventory, nil
+}
+
+// Len returns the number of stored images
+func (s *ImageStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.images)
+}
@@ -0,0 +1,202 @@
+package infratest_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/dorky/messagebus"
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/infratest"
+	"github.com/dmpettyp/artwork/infrastructure/inmem"
+)
+
+func TestImageStorage(t *testing.T) {
+	storage := infratest.NewImageStorage()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+
+	original := imagegraph.MustNewImageID()
+	if err := storage.Save(original, buf.Bytes()); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+
+	metadata, err := storage.GetMetadata(original)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if metadata.Width != 3 || metadata.Height != 2 {
+		t.Errorf("expected metadata read from the image, got %+v", metadata)
+	}
+
+	provenance := imagegraph.ImageProvenance{OutputName: "final"}
+	if err := storage.SaveProvenance(original, provenance); err != nil {
+		t.Fatalf("failed to save provenance: %v", err)
+	}
+
+	copied := imagegraph.MustNewImageID()
+	if err := storage.Copy(original, copied); err != nil {
+		t.Fatalf("failed to copy image: %v", err)
+	}
+
+	got, err := storage.GetProvenance(copied)
+	if err != nil || got == nil || got.OutputName != "final" {
+		t.Errorf("expected the copy to keep the provenance, got %+v, %v", got, err)
+	}
+
+	if err := storage.Remove(original); err != nil {
+		t.Fatalf("failed to remove image: %v", err)
+	}
+	if exists, _ := storage.Exists(original); exists {
+		t.Error("expected the image to be removed")
+	}
+	if got, _ := storage.GetProvenance(original); got != nil {
+		t.Error("expected the provenance to be removed with the image")
+	}
+
+	inventory, err := storage.Inventory()
+	if err != nil {
+		t.Fatalf("failed to get inventory: %v", err)
+	}
+	if len(inventory) != 1 || inventory[copied] != int64(buf.Len()) {
+		t.Errorf("expected only the copy in the inventory, got %v", inventory)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC)
+	clock := infratest.NewClock(start)
+
+	var _ application.Clock = clock
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("expected %v, got %v", start, clock.Now())
+	}
+
+	if now := clock.Advance(time.Minute); !now.Equal(start.Add(time.Minute)) || !clock.Now().Equal(now) {
+		t.Errorf("expected the clock to advance a minute, got %v", clock.Now())
+	}
+
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("expected the clock to be set back to %v, got %v", start, clock.Now())
+	}
+}
+
+func TestBus(t *testing.T) {
+	uow, err := inmem.NewUnitOfWork()
+	if err != nil {
+		t.Fatalf("failed to create unit of work: %v", err)
+	}
+
+	mb := messagebus.New()
+	if _, err := application.NewImageGraphCommandHandlers(mb, nil, uow); err != nil {
+		t.Fatalf("failed to create command handlers: %v", err)
+	}
+
+	var created []imagegraph.ImageGraphID
+	err = messagebus.RegisterEventHandler(mb, func(_ context.Context, event *imagegraph.CreatedEvent) ([]messages.Event, error) {
+		created = append(created, event.ImageGraphID)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register event handler: %v", err)
+	}
+
+	bus := infratest.StartBus(t, mb)
+
+	id := imagegraph.MustNewImageGraphID()
+	bus.MustHandle(application.NewCreateImageGraphCommand(id, "test", "", ""))
+
+	// The event was handled before the command returned
+	if len(created) != 1 || created[0] != id {
+		t.Errorf("expected the created event to be handled, got %v", created)
+	}
+
+	if _, err := uow.ImageGraphViews.Get(context.Background(), id); err != nil {
+		t.Errorf("expected the graph to be created: %v", err)
+	}
+
+	err = bus.Handle(context.Background(), application.NewSetImageGraphPublicCommand(imagegraph.MustNewImageGraphID(), true))
+	if err == nil {
+		t.Error("expected a command on a missing graph to fail")
+	}
+}
+
+// background runs work in goroutines that finish by sending commands, as
+// output generation does
+type background struct {
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	completed uint64
+}
+
+func (b *background) start(work func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		work()
+
+		b.mu.Lock()
+		b.completed++
+		b.mu.Unlock()
+	}()
+}
+
+func (b *background) WaitIdle(context.Context) (uint64, error) {
+	b.wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.completed, nil
+}
+
+func TestBusSettle(t *testing.T) {
+	uow, err := inmem.NewUnitOfWork()
+	if err != nil {
+		t.Fatalf("failed to create unit of work: %v", err)
+	}
+
+	mb := messagebus.New()
+	if _, err := application.NewImageGraphCommandHandlers(mb, nil, uow); err != nil {
+		t.Fatalf("failed to create command handlers: %v", err)
+	}
+
+	// Publishing a graph in the background once it's created leaves the bus
+	// with a command to handle after the creation has been flushed
+	work := &background{}
+	err = messagebus.RegisterEventHandler(mb, func(_ context.Context, event *imagegraph.CreatedEvent) ([]messages.Event, error) {
+		work.start(func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = mb.HandleCommand(context.Background(), application.NewSetImageGraphPublicCommand(event.ImageGraphID, true))
+		})
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register event handler: %v", err)
+	}
+
+	bus := infratest.StartBus(t, mb)
+
+	id := imagegraph.MustNewImageGraphID()
+	bus.MustHandle(application.NewCreateImageGraphCommand(id, "test", "", ""))
+	bus.MustSettle(work)
+
+	ig, err := uow.ImageGraphViews.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get graph: %v", err)
+	}
+	if !ig.Public {
+		t.Error("expected the background work to have published the graph")
+	}
+}
@@ -0,0 +1,82 @@
+package inmem
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// APIKeyStore implements application.APIKeyStore in memory
+type APIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]application.APIKey
+}
+
+// NewAPIKeyStore creates an empty API key store
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{
+		keys: make(map[string]application.APIKey),
+	}
+}
+
+// Add stores a newly issued APIKey
+func (s *APIKeyStore) Add(ctx context.Context, key application.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.Scopes = slices.Clone(key.Scopes)
+	s.keys[key.ID] = key
+
+	return nil
+}
+
+// GetBySecretSHA256 retrieves the APIKey whose secret has the given hash
+func (s *APIKeyStore) GetBySecretSHA256(ctx context.Context, secretSHA256 string) (application.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.SecretSHA256 == secretSHA256 {
+			return key, nil
+		}
+	}
+
+	return application.APIKey{}, application.ErrAPIKeyNotFound
+}
+
+// ListByUser retrieves the APIKeys a user has issued, oldest first
+func (s *APIKeyStore) ListByUser(ctx context.Context, userID string) ([]application.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []application.APIKey
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.SortFunc(keys, func(a, b application.APIKey) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return keys, nil
+}
+
+// Delete revokes an APIKey issued by the user
+func (s *APIKeyStore) Delete(ctx context.Context, userID string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || key.UserID != userID {
+		return application.ErrAPIKeyNotFound
+	}
+
+	delete(s.keys, id)
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package inmem
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// DeadLetterStore implements application.DeadLetterStore in memory
+type DeadLetterStore struct {
+	mu      sync.RWMutex
+	letters []application.DeadLetter
+	nextID  int64
+}
+
+// NewDeadLetterStore creates an empty dead letter store
+func NewDeadLetterStore() *DeadLetterStore {
+	return &DeadLetterStore{nextID: 1}
+}
+
+// Add stores a DeadLetter, returning the ID it was given
+func (s *DeadLetterStore) Add(ctx context.Context, letter application.DeadLetter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter.ID = s.nextID
+	s.nextID++
+	s.letters = append(s.letters, letter)
+
+	return letter.ID, nil
+}
+
+// Get retrieves a DeadLetter
+func (s *DeadLetterStore) Get(ctx context.Context, id int64) (application.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return application.DeadLetter{}, application.ErrDeadLetterNotFound
+	}
+
+	return s.letters[i], nil
+}
+
+// List retrieves the most recent DeadLetters, newest first
+func (s *DeadLetterStore) List(ctx context.Context, limit int) ([]application.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var letters []application.DeadLetter
+	for _, letter := range slices.Backward(s.letters) {
+		if len(letters) == limit {
+			break
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// Update records another failure of a DeadLetter's handler
+func (s *DeadLetterStore) Update(ctx context.Context, letter application.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(letter.ID)
+	if i < 0 {
+		return application.ErrDeadLetterNotFound
+	}
+
+	s.letters[i] = letter
+
+	return nil
+}
+
+// Delete removes a DeadLetter
+func (s *DeadLetterStore) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return application.ErrDeadLetterNotFound
+	}
+
+	s.letters = slices.Delete(s.letters, i, i+1)
+
+	return nil
+}
+
+func (s *DeadLetterStore) index(id int64) int {
+	return slices.IndexFunc(s.letters, func(letter application.DeadLetter) bool {
+		return letter.ID == id
+	})
+}
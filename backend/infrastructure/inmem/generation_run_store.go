@@ -0,0 +1,58 @@
+package inmem
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GenerationRunStore implements application.GenerationRunStore in memory
+type GenerationRunStore struct {
+	mu   sync.RWMutex
+	runs []application.GenerationRun
+}
+
+// NewGenerationRunStore creates an empty generation run store
+func NewGenerationRunStore() *GenerationRunStore {
+	return &GenerationRunStore{}
+}
+
+// Add stores a GenerationRun
+func (s *GenerationRunStore) Add(ctx context.Context, run application.GenerationRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = append(s.runs, run)
+
+	return nil
+}
+
+// ListByNode retrieves the most recent GenerationRuns of a node, newest
+// first
+func (s *GenerationRunStore) ListByNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	limit int,
+) (
+	[]application.GenerationRun,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var runs []application.GenerationRun
+	for _, run := range slices.Backward(s.runs) {
+		if len(runs) == limit {
+			break
+		}
+		if run.ImageGraphID == imageGraphID && run.NodeID == nodeID {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,94 @@
+package inmem
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GraphLinkStore implements application.GraphLinkStore in memory
+type GraphLinkStore struct {
+	mu    sync.RWMutex
+	links map[string]application.GraphLink
+}
+
+// NewGraphLinkStore creates an empty graph link store
+func NewGraphLinkStore() *GraphLinkStore {
+	return &GraphLinkStore{
+		links: make(map[string]application.GraphLink),
+	}
+}
+
+// Add stores a new GraphLink
+func (s *GraphLinkStore) Add(ctx context.Context, link application.GraphLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.links[link.ID] = link
+
+	return nil
+}
+
+// ListByImageGraph retrieves the GraphLinks feeding an ImageGraph, oldest
+// first
+func (s *GraphLinkStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.GraphLink,
+	error,
+) {
+	return s.list(func(link application.GraphLink) bool {
+		return link.TargetImageGraphID == imageGraphID
+	}), nil
+}
+
+// ListBySource retrieves the GraphLinks fed by an ImageGraph, oldest first
+func (s *GraphLinkStore) ListBySource(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.GraphLink,
+	error,
+) {
+	return s.list(func(link application.GraphLink) bool {
+		return link.SourceImageGraphID == imageGraphID
+	}), nil
+}
+
+func (s *GraphLinkStore) list(match func(application.GraphLink) bool) []application.GraphLink {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var links []application.GraphLink
+	for _, link := range s.links {
+		if match(link) {
+			links = append(links, link)
+		}
+	}
+
+	slices.SortFunc(links, func(a, b application.GraphLink) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return links
+}
+
+// Delete removes a GraphLink feeding an ImageGraph
+func (s *GraphLinkStore) Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok || link.TargetImageGraphID != imageGraphID {
+		return application.ErrGraphLinkNotFound
+	}
+
+	delete(s.links, id)
+
+	return nil
+}
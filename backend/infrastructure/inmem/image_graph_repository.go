@@ -49,3 +49,36 @@ func (repo *ImageGraphRepository) Get(
 	}
 	return result, nil
 }
+
+// Add adds a new ImageGraph, rejecting external IDs that are already in use
+func (repo *ImageGraphRepository) Add(ig *imagegraph.ImageGraph) error {
+	if ig.ExternalID != "" {
+		_, err := repo.GetByExternalID(ig.ExternalID)
+		if err == nil {
+			return application.ErrDuplicateExternalID
+		}
+		if !errors.Is(err, application.ErrImageGraphNotFound) {
+			return err
+		}
+	}
+
+	return repo.Repository.Add(ig)
+}
+
+func (repo *ImageGraphRepository) GetByExternalID(
+	externalID string,
+) (
+	*imagegraph.ImageGraph,
+	error,
+) {
+	result, err := repo.FindOne(
+		func(a *imagegraph.ImageGraph) bool { return a.ExternalID == externalID },
+	)
+	if err != nil {
+		if errors.Is(err, inmem.ErrNotFound) {
+			return nil, application.ErrImageGraphNotFound
+		}
+		return nil, err
+	}
+	return result, nil
+}
@@ -2,7 +2,10 @@ package inmem
 
 import (
 	"context"
+	"slices"
+	"strings"
 
+	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 )
 
@@ -28,12 +31,81 @@ func (view *ImageGraphViews) Get(
 	return result.Clone(), nil
 }
 
-func (view *ImageGraphViews) List(_ context.Context) (
+func (view *ImageGraphViews) GetByExternalID(
+	_ context.Context,
+	externalID string,
+) (
+	*imagegraph.ImageGraph,
+	error,
+) {
+	result, err := view.repo.GetByExternalID(externalID)
+	if err != nil {
+		return nil, err
+	}
+	return result.Clone(), nil
+}
+
+func (view *ImageGraphViews) List(
+	_ context.Context,
+	opts application.ListImageGraphsOptions,
+) (
+	*application.ImageGraphPage,
+	error,
+) {
+	all, err := view.repo.FindAll(func(ig *imagegraph.ImageGraph) bool {
+		return (opts.Tag == "" || ig.Tags.Has(opts.Tag)) &&
+			(opts.AccessibleTo == "" || ig.RoleOf(opts.AccessibleTo) != imagegraph.RoleNone)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	compare := func(a, b *imagegraph.ImageGraph) int {
+		switch opts.Sort {
+		case application.SortImageGraphsByName:
+			return strings.Compare(a.Name, b.Name)
+		case application.SortImageGraphsByUpdated:
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+
+	slices.SortFunc(all, func(a, b *imagegraph.ImageGraph) int {
+		c := compare(a, b)
+		if opts.Descending {
+			c = -c
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID.String(), b.ID.String())
+		}
+		return c
+	})
+
+	page := &application.ImageGraphPage{Total: len(all)}
+
+	all = all[min(opts.Offset, len(all)):]
+	if opts.Limit > 0 {
+		all = all[:min(opts.Limit, len(all))]
+	}
+
+	for _, ig := range all {
+		page.ImageGraphs = append(page.ImageGraphs, application.NewImageGraphSummary(ig))
+	}
+
+	return page, nil
+}
+
+func (view *ImageGraphViews) Search(
+	_ context.Context,
+	query string,
+) (
 	[]*imagegraph.ImageGraph,
 	error,
 ) {
-	all, err := view.repo.FindAll(func(*imagegraph.ImageGraph) bool {
-		return true
+	found, err := view.repo.FindAll(func(ig *imagegraph.ImageGraph) bool {
+		return len(ig.Search(query)) > 0
 	})
 
 	if err != nil {
@@ -42,7 +114,28 @@ func (view *ImageGraphViews) List(_ context.Context) (
 
 	var result []*imagegraph.ImageGraph
 
-	for _, ig := range all {
+	for _, ig := range found {
+		result = append(result, ig.Clone())
+	}
+
+	return result, nil
+}
+
+func (view *ImageGraphViews) ListPublic(_ context.Context) (
+	[]*imagegraph.ImageGraph,
+	error,
+) {
+	public, err := view.repo.FindAll(func(ig *imagegraph.ImageGraph) bool {
+		return ig.Public
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*imagegraph.ImageGraph
+
+	for _, ig := range public {
 		result = append(result, ig.Clone())
 	}
 
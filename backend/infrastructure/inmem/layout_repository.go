@@ -16,7 +16,7 @@ type LayoutRepository struct {
 
 func NewLayoutRepository() (*LayoutRepository, error) {
 	identityEqualFn := func(a *ui.Layout, b *ui.Layout) bool {
-		return a.GraphID == b.GraphID
+		return a.GraphID == b.GraphID && a.UserID == b.UserID
 	}
 
 	inmemRepository, err := inmem.CreateRepository(
@@ -35,12 +35,13 @@ func NewLayoutRepository() (*LayoutRepository, error) {
 
 func (repo *LayoutRepository) Get(
 	graphID imagegraph.ImageGraphID,
+	userID string,
 ) (
 	*ui.Layout,
 	error,
 ) {
 	result, err := repo.FindOne(
-		func(a *ui.Layout) bool { return a.GraphID == graphID },
+		func(a *ui.Layout) bool { return a.GraphID == graphID && a.UserID == userID },
 	)
 	if err != nil {
 		if errors.Is(err, inmem.ErrNotFound) {
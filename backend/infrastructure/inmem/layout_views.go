@@ -19,7 +19,7 @@ func NewLayoutViews(repo *LayoutRepository) *LayoutViews {
 	}
 }
 
-// Get retrieves a layout by graph ID
-func (v *LayoutViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID) (*ui.Layout, error) {
-	return v.repo.Get(graphID)
+// Get retrieves a user's layout by graph ID
+func (v *LayoutViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID, userID string) (*ui.Layout, error) {
+	return v.repo.Get(graphID, userID)
 }
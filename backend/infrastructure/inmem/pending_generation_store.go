@@ -0,0 +1,47 @@
+package inmem
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// PendingGenerationStore implements application.PendingGenerationStore in
+// memory. Its contents don't survive a restart, and neither do the graphs of
+// the in-memory backend.
+type PendingGenerationStore struct {
+	mu      sync.Mutex
+	pending []application.PendingGeneration
+}
+
+// NewPendingGenerationStore creates an empty pending generation store
+func NewPendingGenerationStore() *PendingGenerationStore {
+	return &PendingGenerationStore{}
+}
+
+// Add stores PendingGenerations, ignoring nodes that are already pending
+func (s *PendingGenerationStore) Add(ctx context.Context, pending []application.PendingGeneration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range pending {
+		if !slices.Contains(s.pending, p) {
+			s.pending = append(s.pending, p)
+		}
+	}
+
+	return nil
+}
+
+// Take removes and returns every stored PendingGeneration
+func (s *PendingGenerationStore) Take(ctx context.Context) ([]application.PendingGeneration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+
+	return pending, nil
+}
@@ -0,0 +1,57 @@
+package inmem
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// PipelineRunStore implements application.PipelineRunStore in memory
+type PipelineRunStore struct {
+	mu   sync.RWMutex
+	runs []application.PipelineRun
+}
+
+// NewPipelineRunStore creates an empty pipeline run store
+func NewPipelineRunStore() *PipelineRunStore {
+	return &PipelineRunStore{}
+}
+
+// Add stores a PipelineRun
+func (s *PipelineRunStore) Add(ctx context.Context, run application.PipelineRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = append(s.runs, run)
+
+	return nil
+}
+
+// ListByImageGraph retrieves the most recent PipelineRuns of an ImageGraph,
+// newest first
+func (s *PipelineRunStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	limit int,
+) (
+	[]application.PipelineRun,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var runs []application.PipelineRun
+	for _, run := range slices.Backward(s.runs) {
+		if len(runs) == limit {
+			break
+		}
+		if run.ImageGraphID == imageGraphID {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
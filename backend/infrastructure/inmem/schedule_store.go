@@ -0,0 +1,123 @@
+package inmem
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ScheduleStore implements application.ScheduleStore in memory
+type ScheduleStore struct {
+	mu        sync.RWMutex
+	schedules map[imagegraph.ImageGraphID]application.Schedule
+	runs      []application.ScheduledRun
+}
+
+// NewScheduleStore creates an empty schedule store
+func NewScheduleStore() *ScheduleStore {
+	return &ScheduleStore{
+		schedules: make(map[imagegraph.ImageGraphID]application.Schedule),
+	}
+}
+
+// Set adds or replaces an ImageGraph's Schedule
+func (s *ScheduleStore) Set(ctx context.Context, schedule application.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schedules[schedule.ImageGraphID] = schedule
+
+	return nil
+}
+
+// Get retrieves an ImageGraph's Schedule
+func (s *ScheduleStore) Get(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	application.Schedule,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedule, ok := s.schedules[imageGraphID]
+	if !ok {
+		return application.Schedule{}, application.ErrScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+// Delete removes an ImageGraph's Schedule, keeping the history of its runs
+func (s *ScheduleStore) Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[imageGraphID]; !ok {
+		return application.ErrScheduleNotFound
+	}
+
+	delete(s.schedules, imageGraphID)
+
+	return nil
+}
+
+// ListEnabled retrieves every enabled Schedule, oldest first
+func (s *ScheduleStore) ListEnabled(ctx context.Context) ([]application.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var schedules []application.Schedule
+	for _, schedule := range s.schedules {
+		if schedule.Enabled {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	slices.SortFunc(schedules, func(a, b application.Schedule) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ImageGraphID.String(), b.ImageGraphID.String()))
+	})
+
+	return schedules, nil
+}
+
+// AddRun stores a ScheduledRun
+func (s *ScheduleStore) AddRun(ctx context.Context, run application.ScheduledRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = append(s.runs, run)
+
+	return nil
+}
+
+// ListRuns retrieves the most recent runs of an ImageGraph's Schedule,
+// newest first
+func (s *ScheduleStore) ListRuns(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	limit int,
+) (
+	[]application.ScheduledRun,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var runs []application.ScheduledRun
+	for _, run := range slices.Backward(s.runs) {
+		if len(runs) == limit {
+			break
+		}
+		if run.ImageGraphID == imageGraphID {
+			runs = append(runs, run)
+		}
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,78 @@
+package inmem
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// SnapshotStore implements application.SnapshotStore in memory
+type SnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots []application.Snapshot
+}
+
+// NewSnapshotStore creates an empty snapshot store
+func NewSnapshotStore() *SnapshotStore {
+	return &SnapshotStore{}
+}
+
+// Add stores a Snapshot
+func (s *SnapshotStore) Add(ctx context.Context, snapshot application.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.snapshots {
+		if existing.ImageGraphID == snapshot.ImageGraphID && existing.Name == snapshot.Name {
+			return application.ErrSnapshotNameTaken
+		}
+	}
+
+	s.snapshots = append(s.snapshots, snapshot)
+
+	return nil
+}
+
+// Get retrieves a Snapshot of an ImageGraph
+func (s *SnapshotStore) Get(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	id string,
+) (
+	application.Snapshot,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, snapshot := range s.snapshots {
+		if snapshot.ImageGraphID == imageGraphID && snapshot.ID == id {
+			return snapshot, nil
+		}
+	}
+
+	return application.Snapshot{}, application.ErrSnapshotNotFound
+}
+
+// ListByImageGraph retrieves the Snapshots of an ImageGraph, oldest first
+func (s *SnapshotStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.Snapshot,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snapshots []application.Snapshot
+	for _, snapshot := range s.snapshots {
+		if snapshot.ImageGraphID == imageGraphID {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
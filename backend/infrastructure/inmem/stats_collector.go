@@ -0,0 +1,45 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// StatsCollector implements application.StatsCollector over the in-memory
+// ImageGraphs and Snapshots
+type StatsCollector struct {
+	graphs    *ImageGraphRepository
+	snapshots *SnapshotStore
+}
+
+// NewStatsCollector creates a StatsCollector over the ImageGraphs of the
+// views and the Snapshots of the store
+func NewStatsCollector(views *ImageGraphViews, snapshots *SnapshotStore) *StatsCollector {
+	return &StatsCollector{graphs: views.repo, snapshots: snapshots}
+}
+
+// CollectGraphStats counts the stored ImageGraphs. Events are published as
+// they are committed, so the queue is always empty.
+func (c *StatsCollector) CollectGraphStats(_ context.Context) (application.GraphStats, error) {
+	stats := application.NewGraphStats()
+
+	graphs, err := c.graphs.FindAll(func(*imagegraph.ImageGraph) bool { return true })
+	if err != nil {
+		return application.GraphStats{}, err
+	}
+
+	for _, ig := range graphs {
+		stats.AddImageGraph(ig)
+	}
+
+	c.snapshots.mu.RLock()
+	defer c.snapshots.mu.RUnlock()
+
+	for _, snapshot := range c.snapshots.snapshots {
+		stats.AddSnapshot(snapshot)
+	}
+
+	return stats, nil
+}
@@ -16,7 +16,7 @@ type ViewportRepository struct {
 
 func NewViewportRepository() (*ViewportRepository, error) {
 	identityEqualFn := func(a *ui.Viewport, b *ui.Viewport) bool {
-		return a.GraphID == b.GraphID
+		return a.GraphID == b.GraphID && a.UserID == b.UserID
 	}
 
 	inmemRepository, err := inmem.CreateRepository(
@@ -35,12 +35,13 @@ func NewViewportRepository() (*ViewportRepository, error) {
 
 func (repo *ViewportRepository) Get(
 	graphID imagegraph.ImageGraphID,
+	userID string,
 ) (
 	*ui.Viewport,
 	error,
 ) {
 	result, err := repo.FindOne(
-		func(a *ui.Viewport) bool { return a.GraphID == graphID },
+		func(a *ui.Viewport) bool { return a.GraphID == graphID && a.UserID == userID },
 	)
 	if err != nil {
 		if errors.Is(err, inmem.ErrNotFound) {
@@ -19,7 +19,12 @@ func NewViewportViews(repo *ViewportRepository) *ViewportViews {
 	}
 }
 
-// Get retrieves a viewport by graph ID
-func (v *ViewportViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID) (*ui.Viewport, error) {
-	return v.repo.Get(graphID)
+// Get retrieves a copy of a user's viewport by graph ID, so that readers
+// don't see its bookmarks change under them
+func (v *ViewportViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID, userID string) (*ui.Viewport, error) {
+	viewport, err := v.repo.Get(graphID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return viewport.Clone(), nil
 }
@@ -0,0 +1,75 @@
+package inmem
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// WebhookStore implements application.WebhookStore in memory
+type WebhookStore struct {
+	mu       sync.RWMutex
+	webhooks map[string]application.Webhook
+}
+
+// NewWebhookStore creates an empty webhook store
+func NewWebhookStore() *WebhookStore {
+	return &WebhookStore{
+		webhooks: make(map[string]application.Webhook),
+	}
+}
+
+// Add stores a newly registered Webhook
+func (s *WebhookStore) Add(ctx context.Context, webhook application.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks[webhook.ID] = webhook
+
+	return nil
+}
+
+// ListByImageGraph retrieves the Webhooks registered on an ImageGraph,
+// oldest first
+func (s *WebhookStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.Webhook,
+	error,
+) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhooks []application.Webhook
+	for _, webhook := range s.webhooks {
+		if webhook.ImageGraphID == imageGraphID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	slices.SortFunc(webhooks, func(a, b application.Webhook) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	return webhooks, nil
+}
+
+// Delete removes a Webhook registered on an ImageGraph
+func (s *WebhookStore) Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok || webhook.ImageGraphID != imageGraphID {
+		return application.ErrWebhookNotFound
+	}
+
+	delete(s.webhooks, id)
+
+	return nil
+}
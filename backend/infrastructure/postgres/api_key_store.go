@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// APIKeyStore implements application.APIKeyStore
+type APIKeyStore struct {
+	db *sql.DB
+}
+
+func NewAPIKeyStore(db *sql.DB) *APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+// Add stores a newly issued APIKey
+func (s *APIKeyStore) Add(ctx context.Context, key application.APIKey) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, user_id, name, scopes, rate_limit, secret_sha256, prefix, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, key.ID, key.UserID, key.Name, joinScopes(key.Scopes), key.RateLimit, key.SecretSHA256, key.Prefix, key.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	return nil
+}
+
+// GetBySecretSHA256 retrieves the APIKey whose secret has the given hash
+func (s *APIKeyStore) GetBySecretSHA256(ctx context.Context, secretSHA256 string) (application.APIKey, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, name, scopes, rate_limit, secret_sha256, prefix, created_at
+		FROM api_keys
+		WHERE secret_sha256 = $1
+	`, secretSHA256)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return application.APIKey{}, application.ErrAPIKeyNotFound
+	}
+
+	return key, err
+}
+
+// ListByUser retrieves the APIKeys a user has issued, oldest first
+func (s *APIKeyStore) ListByUser(ctx context.Context, userID string) ([]application.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, name, scopes, rate_limit, secret_sha256, prefix, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []application.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Delete revokes an APIKey issued by the user
+func (s *APIKeyStore) Delete(ctx context.Context, userID string, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM api_keys WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if deleted == 0 {
+		return application.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (application.APIKey, error) {
+	var key application.APIKey
+	var scopes string
+
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&scopes,
+		&key.RateLimit,
+		&key.SecretSHA256,
+		&key.Prefix,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return application.APIKey{}, err
+		}
+		return application.APIKey{}, fmt.Errorf("failed to scan API key: %w", err)
+	}
+
+	key.Scopes = splitScopes(scopes)
+
+	return key, nil
+}
+
+// joinScopes stores scopes space-separated, the way OAuth does
+func joinScopes(scopes []application.APIKeyScope) string {
+	strs := make([]string, len(scopes))
+	for i, scope := range scopes {
+		strs[i] = string(scope)
+	}
+	return strings.Join(strs, " ")
+}
+
+func splitScopes(scopes string) []application.APIKeyScope {
+	fields := strings.Fields(scopes)
+	result := make([]application.APIKeyScope, len(fields))
+	for i, field := range fields {
+		result[i] = application.APIKeyScope(field)
+	}
+	return result
+}
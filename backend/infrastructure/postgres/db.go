@@ -7,6 +7,8 @@ import (
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
 )
 
 // Config holds database configuration
@@ -76,6 +78,19 @@ func NewDB(config Config) (*sql.DB, error) {
 }
 
 // withTx is a helper for executing a function within a transaction
+// lockImageGraph takes a transaction-scoped advisory lock on an ImageGraph,
+// so transactions changing the graph, its layout or its viewport run one at a
+// time across every instance. Unlike row locks it also covers rows that don't
+// exist yet.
+func lockImageGraph(ctx context.Context, tx *sql.Tx, id imagegraph.ImageGraphID) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to lock image graph %q: %w", id, err)
+	}
+
+	return nil
+}
+
 func withTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// DeadLetterStore implements application.DeadLetterStore. Events are stored
+// as in the events table, so only the event types there can be dead-lettered.
+type DeadLetterStore struct {
+	db *sql.DB
+}
+
+func NewDeadLetterStore(db *sql.DB) *DeadLetterStore {
+	return &DeadLetterStore{db: db}
+}
+
+// Add stores a DeadLetter, returning the ID it was given
+func (s *DeadLetterStore) Add(ctx context.Context, letter application.DeadLetter) (int64, error) {
+	eventData, err := json.Marshal(letter.Event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	var id int64
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO dead_letters (handler, event_type, event_data, error, attempts, first_failed_at, last_failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`,
+		letter.Handler,
+		letter.Event.GetType(),
+		eventData,
+		letter.Error,
+		letter.Attempts,
+		letter.FirstFailedAt,
+		letter.LastFailedAt,
+	).Scan(&id)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert dead letter: %w", err)
+	}
+
+	return id, nil
+}
+
+// Get retrieves a DeadLetter
+func (s *DeadLetterStore) Get(ctx context.Context, id int64) (application.DeadLetter, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, handler, event_type, event_data, error, attempts, first_failed_at, last_failed_at
+		FROM dead_letters
+		WHERE id = $1
+	`, id)
+
+	letter, err := scanDeadLetter(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return application.DeadLetter{}, application.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return application.DeadLetter{}, err
+	}
+
+	return letter, nil
+}
+
+// List retrieves the most recent DeadLetters, newest first
+func (s *DeadLetterStore) List(ctx context.Context, limit int) ([]application.DeadLetter, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, handler, event_type, event_data, error, attempts, first_failed_at, last_failed_at
+		FROM dead_letters
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []application.DeadLetter
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// Update records another failure of a DeadLetter's handler
+func (s *DeadLetterStore) Update(ctx context.Context, letter application.DeadLetter) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE dead_letters
+		SET error = $2, attempts = $3, last_failed_at = $4
+		WHERE id = $1
+	`, letter.ID, letter.Error, letter.Attempts, letter.LastFailedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update dead letter: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update dead letter: %w", err)
+	}
+	if updated == 0 {
+		return application.ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a DeadLetter
+func (s *DeadLetterStore) Delete(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	if deleted == 0 {
+		return application.ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+func scanDeadLetter(row rowScanner) (application.DeadLetter, error) {
+	var (
+		letter    application.DeadLetter
+		eventType string
+		eventData []byte
+	)
+
+	err := row.Scan(
+		&letter.ID,
+		&letter.Handler,
+		&eventType,
+		&eventData,
+		&letter.Error,
+		&letter.Attempts,
+		&letter.FirstFailedAt,
+		&letter.LastFailedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return letter, err
+	}
+	if err != nil {
+		return letter, fmt.Errorf("failed to scan dead letter: %w", err)
+	}
+
+	letter.Event, err = deserializeEvent(eventType, eventData)
+	if err != nil {
+		return letter, fmt.Errorf("failed to deserialize dead letter %d: %w", letter.ID, err)
+	}
+
+	return letter, nil
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GenerationRunStore implements application.GenerationRunStore
+type GenerationRunStore struct {
+	db *sql.DB
+}
+
+func NewGenerationRunStore(db *sql.DB) *GenerationRunStore {
+	return &GenerationRunStore{db: db}
+}
+
+// Add stores a GenerationRun, ignoring ImageGraphs that no longer exist
+func (s *GenerationRunStore) Add(ctx context.Context, run application.GenerationRun) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO generation_runs (
+			image_graph_id, node_id, node_type, node_version, started_at,
+			duration_ms, input_pixels, output_pixels, failed
+		)
+		SELECT id, $2, $3, $4, $5, $6, $7, $8, $9 FROM image_graphs WHERE id = $1
+	`,
+		run.ImageGraphID.ID,
+		run.NodeID.ID,
+		imagegraph.NodeTypeMapper.FromWithDefault(run.NodeType, "unknown"),
+		int64(run.NodeVersion),
+		run.StartedAt,
+		float64(run.Duration)/float64(time.Millisecond),
+		run.InputPixels,
+		run.OutputPixels,
+		run.Failed,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert generation run: %w", err)
+	}
+
+	return nil
+}
+
+// ListByNode retrieves the most recent GenerationRuns of a node, newest
+// first
+func (s *GenerationRunStore) ListByNode(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	nodeID imagegraph.NodeID,
+	limit int,
+) (
+	[]application.GenerationRun,
+	error,
+) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT node_type, node_version, started_at, duration_ms, input_pixels, output_pixels, failed
+		FROM generation_runs
+		WHERE image_graph_id = $1 AND node_id = $2
+		ORDER BY started_at DESC, id DESC
+		LIMIT $3
+	`, imageGraphID.ID, nodeID.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query generation runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []application.GenerationRun
+	for rows.Next() {
+		run := application.GenerationRun{ImageGraphID: imageGraphID, NodeID: nodeID}
+
+		var nodeType string
+		var nodeVersion int64
+		var durationMs float64
+
+		err := rows.Scan(
+			&nodeType,
+			&nodeVersion,
+			&run.StartedAt,
+			&durationMs,
+			&run.InputPixels,
+			&run.OutputPixels,
+			&run.Failed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan generation run: %w", err)
+		}
+
+		run.NodeType, err = imagegraph.NodeTypeMapper.To(nodeType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse generation run node type %q: %w", nodeType, err)
+		}
+		run.NodeVersion = imagegraph.NodeVersion(nodeVersion)
+		run.Duration = time.Duration(durationMs * float64(time.Millisecond))
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate generation runs: %w", err)
+	}
+
+	return runs, nil
+}
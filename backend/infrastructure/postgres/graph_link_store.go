@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// GraphLinkStore implements application.GraphLinkStore
+type GraphLinkStore struct {
+	db *sql.DB
+}
+
+func NewGraphLinkStore(db *sql.DB) *GraphLinkStore {
+	return &GraphLinkStore{db: db}
+}
+
+// Add stores a new GraphLink
+func (s *GraphLinkStore) Add(ctx context.Context, link application.GraphLink) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO graph_links (
+			id, source_image_graph_id, source_node_id, source_output_name,
+			target_image_graph_id, target_node_id, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`,
+		link.ID,
+		link.SourceImageGraphID.ID,
+		link.SourceNodeID.ID,
+		string(link.SourceOutputName),
+		link.TargetImageGraphID.ID,
+		link.TargetNodeID.ID,
+		link.CreatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert graph link: %w", err)
+	}
+
+	return nil
+}
+
+// ListByImageGraph retrieves the GraphLinks feeding an ImageGraph, oldest
+// first
+func (s *GraphLinkStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.GraphLink,
+	error,
+) {
+	return s.list(ctx, "target_image_graph_id", imageGraphID)
+}
+
+// ListBySource retrieves the GraphLinks fed by an ImageGraph, oldest first
+func (s *GraphLinkStore) ListBySource(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.GraphLink,
+	error,
+) {
+	return s.list(ctx, "source_image_graph_id", imageGraphID)
+}
+
+func (s *GraphLinkStore) list(
+	ctx context.Context,
+	column string,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.GraphLink,
+	error,
+) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, source_image_graph_id, source_node_id, source_output_name,
+			target_image_graph_id, target_node_id, created_at
+		FROM graph_links
+		WHERE `+column+` = $1
+		ORDER BY created_at, id
+	`, imageGraphID.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query graph links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []application.GraphLink
+	for rows.Next() {
+		var link application.GraphLink
+		var outputName string
+
+		err := rows.Scan(
+			&link.ID,
+			&link.SourceImageGraphID.ID,
+			&link.SourceNodeID.ID,
+			&outputName,
+			&link.TargetImageGraphID.ID,
+			&link.TargetNodeID.ID,
+			&link.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan graph link: %w", err)
+		}
+		link.SourceOutputName = imagegraph.OutputName(outputName)
+
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate graph links: %w", err)
+	}
+
+	return links, nil
+}
+
+// Delete removes a GraphLink feeding an ImageGraph
+func (s *GraphLinkStore) Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM graph_links WHERE id = $1 AND target_image_graph_id = $2
+	`, id, imageGraphID.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete graph link: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete graph link: %w", err)
+	}
+	if deleted == 0 {
+		return application.ErrGraphLinkNotFound
+	}
+
+	return nil
+}
@@ -3,10 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/dmpettyp/dorky/messages"
 
+	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 )
 
@@ -33,15 +35,22 @@ func (r *ImageGraphRepository) Get(id imagegraph.ImageGraphID) (*imagegraph.Imag
 
 	ctx := context.Background()
 
+	if err := lockImageGraph(ctx, r.tx, id); err != nil {
+		return nil, err
+	}
+
 	var row imageGraphRow
 	err := r.tx.QueryRowContext(ctx, `
-		SELECT id, name, version, data, created_at, updated_at
+		SELECT id, name, external_id, owner, public, version, data, created_at, updated_at
 		FROM image_graphs
 		WHERE id = $1
 		FOR UPDATE
 	`, id.ID).Scan(
 		&row.ID,
 		&row.Name,
+		&row.ExternalID,
+		&row.Owner,
+		&row.Public,
 		&row.Version,
 		&row.Data,
 		&row.CreatedAt,
@@ -67,15 +76,33 @@ func (r *ImageGraphRepository) Get(id imagegraph.ImageGraphID) (*imagegraph.Imag
 func (r *ImageGraphRepository) Add(ig *imagegraph.ImageGraph) error {
 	ctx := context.Background()
 
+	if err := lockImageGraph(ctx, r.tx, ig.ID); err != nil {
+		return err
+	}
+
 	row, err := serializeImageGraph(ig)
 	if err != nil {
 		return fmt.Errorf("failed to serialize image graph: %w", err)
 	}
 
+	if row.ExternalID.Valid {
+		var existingID string
+		err = r.tx.QueryRowContext(ctx, `
+			SELECT id FROM image_graphs WHERE external_id = $1
+		`, row.ExternalID).Scan(&existingID)
+
+		if err == nil {
+			return application.ErrDuplicateExternalID
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to check external ID: %w", err)
+		}
+	}
+
 	_, err = r.tx.ExecContext(ctx, `
-		INSERT INTO image_graphs (id, name, version, data)
-		VALUES ($1, $2, $3, $4)
-	`, row.ID, row.Name, row.Version, row.Data)
+		INSERT INTO image_graphs (id, name, external_id, owner, public, version, data, search_text, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, row.ID, row.Name, row.ExternalID, row.Owner, row.Public, row.Version, row.Data, row.SearchText, row.CreatedAt, row.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to insert image graph: %w", err)
@@ -98,9 +125,9 @@ func (r *ImageGraphRepository) SaveAll() error {
 
 		result, err := r.tx.ExecContext(ctx, `
 			UPDATE image_graphs
-			SET name = $2, version = $3, data = $4, updated_at = NOW()
+			SET name = $2, public = $3, version = $4, data = $5, search_text = $6, updated_at = $7
 			WHERE id = $1
-		`, row.ID, row.Name, row.Version, row.Data)
+		`, row.ID, row.Name, row.Public, row.Version, row.Data, row.SearchText, row.UpdatedAt)
 
 		if err != nil {
 			return fmt.Errorf("failed to update image graph: %w", err)
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// ImageGraphSummaryStore implements application.ImageGraphSummaryStore
+type ImageGraphSummaryStore struct {
+	db *sql.DB
+}
+
+func NewImageGraphSummaryStore(db *sql.DB) *ImageGraphSummaryStore {
+	return &ImageGraphSummaryStore{db: db}
+}
+
+// Put stores a summary unless a summary of a later version is already stored.
+// Summaries of ImageGraphs that no longer exist are ignored.
+func (s *ImageGraphSummaryStore) Put(ctx context.Context, summary application.ImageGraphSummary) error {
+	row, err := serializeImageGraphSummary(summary)
+	if err != nil {
+		return fmt.Errorf("failed to serialize image graph summary: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO image_graph_summaries (
+			image_graph_id, version, name, owner, public, tags, shares,
+			node_count, thumbnail_image_id, created_at, updated_at
+		)
+		SELECT id, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		FROM image_graphs WHERE id = $1
+		ON CONFLICT (image_graph_id) DO UPDATE SET
+			version = EXCLUDED.version,
+			name = EXCLUDED.name,
+			owner = EXCLUDED.owner,
+			public = EXCLUDED.public,
+			tags = EXCLUDED.tags,
+			shares = EXCLUDED.shares,
+			node_count = EXCLUDED.node_count,
+			thumbnail_image_id = EXCLUDED.thumbnail_image_id,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at
+		WHERE image_graph_summaries.version < EXCLUDED.version
+	`,
+		row.ImageGraphID,
+		row.Version,
+		row.Name,
+		row.Owner,
+		row.Public,
+		row.Tags,
+		row.Shares,
+		row.NodeCount,
+		row.ThumbnailImageID,
+		row.CreatedAt,
+		row.UpdatedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert image graph summary: %w", err)
+	}
+
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 )
 
@@ -21,12 +24,15 @@ func NewImageGraphViews(db *sql.DB) *ImageGraphViews {
 func (v *ImageGraphViews) Get(ctx context.Context, id imagegraph.ImageGraphID) (*imagegraph.ImageGraph, error) {
 	var row imageGraphRow
 	err := v.db.QueryRowContext(ctx, `
-		SELECT id, name, version, data, created_at, updated_at
+		SELECT id, name, external_id, owner, public, version, data, created_at, updated_at
 		FROM image_graphs
 		WHERE id = $1
 	`, id.ID).Scan(
 		&row.ID,
 		&row.Name,
+		&row.ExternalID,
+		&row.Owner,
+		&row.Public,
 		&row.Version,
 		&row.Data,
 		&row.CreatedAt,
@@ -45,13 +51,146 @@ func (v *ImageGraphViews) Get(ctx context.Context, id imagegraph.ImageGraphID) (
 	return ig, nil
 }
 
-// List retrieves all ImageGraphs (read-only)
-func (v *ImageGraphViews) List(ctx context.Context) ([]*imagegraph.ImageGraph, error) {
-	rows, err := v.db.QueryContext(ctx, `
-		SELECT id, name, version, data, created_at, updated_at
+// GetByExternalID retrieves an ImageGraph by its external ID (read-only, no
+// locking)
+func (v *ImageGraphViews) GetByExternalID(ctx context.Context, externalID string) (*imagegraph.ImageGraph, error) {
+	var row imageGraphRow
+	err := v.db.QueryRowContext(ctx, `
+		SELECT id, name, external_id, owner, public, version, data, created_at, updated_at
 		FROM image_graphs
+		WHERE external_id = $1
+	`, externalID).Scan(
+		&row.ID,
+		&row.Name,
+		&row.ExternalID,
+		&row.Owner,
+		&row.Public,
+		&row.Version,
+		&row.Data,
+		&row.CreatedAt,
+		&row.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, wrapImageGraphNotFound(err)
+	}
+
+	ig, err := deserializeImageGraph(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize image graph: %w", err)
+	}
+
+	return ig, nil
+}
+
+// imageGraphSortColumns maps list sort orders to the columns they order by
+var imageGraphSortColumns = map[application.ImageGraphSort]string{
+	application.SortImageGraphsByName:    "name",
+	application.SortImageGraphsByCreated: "created_at",
+	application.SortImageGraphsByUpdated: "updated_at",
+}
+
+// List retrieves a page of ImageGraph summaries (read-only)
+func (v *ImageGraphViews) List(
+	ctx context.Context,
+	opts application.ListImageGraphsOptions,
+) (*application.ImageGraphPage, error) {
+	var conditions []string
+	var args []any
+	if opts.Tag != "" {
+		args = append(args, opts.Tag)
+		conditions = append(conditions, fmt.Sprintf("tags ? $%d", len(args)))
+	}
+	if opts.AccessibleTo != "" {
+		args = append(args, opts.AccessibleTo)
+		conditions = append(conditions, fmt.Sprintf("(owner = $%[1]d OR shares ? $%[1]d)", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &application.ImageGraphPage{}
+
+	err := v.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM image_graph_summaries `+where, args...,
+	).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count image graphs: %w", err)
+	}
+
+	column, ok := imageGraphSortColumns[opts.Sort]
+	if !ok {
+		column = imageGraphSortColumns[application.SortImageGraphsByCreated]
+	}
+
+	direction := "ASC"
+	if opts.Descending {
+		direction = "DESC"
+	}
+
+	limit := "ALL"
+	if opts.Limit > 0 {
+		limit = strconv.Itoa(opts.Limit)
+	}
+
+	page.ImageGraphs, err = v.querySummaries(ctx, fmt.Sprintf(`
+		SELECT image_graph_id, version, name, owner, public, tags, shares,
+			node_count, thumbnail_image_id, created_at, updated_at
+		FROM image_graph_summaries
+		%s
+		ORDER BY %s %s, image_graph_id
+		LIMIT %s OFFSET %d
+	`, where, column, direction, limit, opts.Offset), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list image graphs: %w", err)
+	}
+
+	return page, nil
+}
+
+// ListPublic retrieves the ImageGraphs published to the gallery (read-only)
+func (v *ImageGraphViews) ListPublic(ctx context.Context) ([]*imagegraph.ImageGraph, error) {
+	graphs, err := v.query(ctx, `
+		SELECT id, name, external_id, owner, public, version, data, created_at, updated_at
+		FROM image_graphs
+		WHERE public
 		ORDER BY created_at DESC
 	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list public image graphs: %w", err)
+	}
+
+	return graphs, nil
+}
+
+// Search retrieves the ImageGraphs whose search text contains the query
+// (read-only). The search text joins the graph's and its nodes' names and
+// tags, so a query spanning two of them can match; callers filter the
+// results with ImageGraph.Search.
+func (v *ImageGraphViews) Search(ctx context.Context, query string) ([]*imagegraph.ImageGraph, error) {
+	graphs, err := v.query(ctx, `
+		SELECT id, name, external_id, owner, public, version, data, created_at, updated_at
+		FROM image_graphs
+		WHERE search_text LIKE '%' || $1 || '%'
+		ORDER BY created_at DESC
+	`, escapeLike(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search image graphs for %q: %w", query, err)
+	}
+
+	return graphs, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a string so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// query runs a query selecting image graph rows and deserializes the results
+func (v *ImageGraphViews) query(ctx context.Context, query string, args ...any) ([]*imagegraph.ImageGraph, error) {
+	rows, err := v.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query image graphs: %w", err)
 	}
@@ -63,6 +202,9 @@ func (v *ImageGraphViews) List(ctx context.Context) ([]*imagegraph.ImageGraph, e
 		if err := rows.Scan(
 			&row.ID,
 			&row.Name,
+			&row.ExternalID,
+			&row.Owner,
+			&row.Public,
 			&row.Version,
 			&row.Data,
 			&row.CreatedAt,
@@ -85,3 +227,46 @@ func (v *ImageGraphViews) List(ctx context.Context) ([]*imagegraph.ImageGraph, e
 
 	return graphs, nil
 }
+
+// querySummaries runs a query selecting image graph summary rows and
+// deserializes the results
+func (v *ImageGraphViews) querySummaries(ctx context.Context, query string, args ...any) ([]application.ImageGraphSummary, error) {
+	rows, err := v.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query image graph summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []application.ImageGraphSummary
+	for rows.Next() {
+		var row imageGraphSummaryRow
+		if err := rows.Scan(
+			&row.ImageGraphID,
+			&row.Version,
+			&row.Name,
+			&row.Owner,
+			&row.Public,
+			&row.Tags,
+			&row.Shares,
+			&row.NodeCount,
+			&row.ThumbnailImageID,
+			&row.CreatedAt,
+			&row.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan image graph summary row: %w", err)
+		}
+
+		summary, err := deserializeImageGraphSummary(row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize image graph summary: %w", err)
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating image graph summary rows: %w", err)
+	}
+
+	return summaries, nil
+}
@@ -11,37 +11,48 @@ import (
 	"github.com/dmpettyp/artwork/domain/ui"
 )
 
+// uiStateKey identifies a user's Layout or Viewport of an ImageGraph
+type uiStateKey struct {
+	graphID imagegraph.ImageGraphID
+	userID  string
+}
+
 // LayoutRepository implements application.LayoutRepository using PostgreSQL
 type LayoutRepository struct {
 	tx       *sql.Tx
-	modified map[imagegraph.ImageGraphID]*ui.Layout // Track modified aggregates for event collection
+	modified map[uiStateKey]*ui.Layout // Track modified aggregates for event collection
 }
 
 // newLayoutRepository creates a new repository with initialized maps
 func newLayoutRepository(tx *sql.Tx) *LayoutRepository {
 	return &LayoutRepository{
 		tx:       tx,
-		modified: make(map[imagegraph.ImageGraphID]*ui.Layout),
+		modified: make(map[uiStateKey]*ui.Layout),
 	}
 }
 
-// Get retrieves a Layout by graph ID with SELECT FOR UPDATE row locking
-func (r *LayoutRepository) Get(graphID imagegraph.ImageGraphID) (*ui.Layout, error) {
+// Get retrieves a user's Layout by graph ID with SELECT FOR UPDATE row locking
+func (r *LayoutRepository) Get(graphID imagegraph.ImageGraphID, userID string) (*ui.Layout, error) {
 	// Check if already loaded in this transaction (identity map pattern)
-	if layout, ok := r.modified[graphID]; ok {
+	if layout, ok := r.modified[uiStateKey{graphID, userID}]; ok {
 		return layout, nil
 	}
 
 	ctx := context.Background()
 
+	if err := lockImageGraph(ctx, r.tx, graphID); err != nil {
+		return nil, err
+	}
+
 	var row layoutRow
 	err := r.tx.QueryRowContext(ctx, `
-		SELECT graph_id, data, updated_at
+		SELECT graph_id, user_id, data, updated_at
 		FROM layouts
-		WHERE graph_id = $1
+		WHERE graph_id = $1 AND user_id = $2
 		FOR UPDATE
-	`, graphID.ID).Scan(
+	`, graphID.ID, userID).Scan(
 		&row.GraphID,
+		&row.UserID,
 		&row.Data,
 		&row.UpdatedAt,
 	)
@@ -56,7 +67,7 @@ func (r *LayoutRepository) Get(graphID imagegraph.ImageGraphID) (*ui.Layout, err
 	}
 
 	// Track for event collection
-	r.modified[layout.GraphID] = layout
+	r.modified[uiStateKey{layout.GraphID, layout.UserID}] = layout
 
 	return layout, nil
 }
@@ -65,24 +76,28 @@ func (r *LayoutRepository) Get(graphID imagegraph.ImageGraphID) (*ui.Layout, err
 func (r *LayoutRepository) Add(layout *ui.Layout) error {
 	ctx := context.Background()
 
+	if err := lockImageGraph(ctx, r.tx, layout.GraphID); err != nil {
+		return err
+	}
+
 	row, err := serializeLayout(layout)
 	if err != nil {
 		return fmt.Errorf("failed to serialize layout: %w", err)
 	}
 
 	_, err = r.tx.ExecContext(ctx, `
-		INSERT INTO layouts (graph_id, data)
-		VALUES ($1, $2)
-		ON CONFLICT (graph_id) DO UPDATE
+		INSERT INTO layouts (graph_id, user_id, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (graph_id, user_id) DO UPDATE
 		SET data = EXCLUDED.data, updated_at = NOW()
-	`, row.GraphID, row.Data)
+	`, row.GraphID, row.UserID, row.Data)
 
 	if err != nil {
 		return fmt.Errorf("failed to insert/update layout: %w", err)
 	}
 
 	// Track for event collection
-	r.modified[layout.GraphID] = layout
+	r.modified[uiStateKey{layout.GraphID, layout.UserID}] = layout
 
 	return nil
 }
@@ -98,11 +113,11 @@ func (r *LayoutRepository) SaveAll() error {
 		}
 
 		_, err = r.tx.ExecContext(ctx, `
-			INSERT INTO layouts (graph_id, data)
-			VALUES ($1, $2)
-			ON CONFLICT (graph_id) DO UPDATE
+			INSERT INTO layouts (graph_id, user_id, data)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (graph_id, user_id) DO UPDATE
 			SET data = EXCLUDED.data, updated_at = NOW()
-		`, row.GraphID, row.Data)
+		`, row.GraphID, row.UserID, row.Data)
 
 		if err != nil {
 			return fmt.Errorf("failed to save layout: %w", err)
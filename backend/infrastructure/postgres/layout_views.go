@@ -18,15 +18,16 @@ func NewLayoutViews(db *sql.DB) *LayoutViews {
 	return &LayoutViews{db: db}
 }
 
-// Get retrieves a Layout by graph ID (read-only, no locking)
-func (v *LayoutViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID) (*ui.Layout, error) {
+// Get retrieves a user's Layout by graph ID (read-only, no locking)
+func (v *LayoutViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID, userID string) (*ui.Layout, error) {
 	var row layoutRow
 	err := v.db.QueryRowContext(ctx, `
-		SELECT graph_id, data, updated_at
+		SELECT graph_id, user_id, data, updated_at
 		FROM layouts
-		WHERE graph_id = $1
-	`, graphID.ID).Scan(
+		WHERE graph_id = $1 AND user_id = $2
+	`, graphID.ID, userID).Scan(
 		&row.GraphID,
+		&row.UserID,
 		&row.Data,
 		&row.UpdatedAt,
 	)
@@ -1,38 +1,57 @@
 package postgres
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/dmpettyp/dorky/messages"
 	"github.com/dmpettyp/dorky/state"
 
+	"github.com/dmpettyp/artwork/application"
 	"github.com/dmpettyp/artwork/domain/imagegraph"
 	"github.com/dmpettyp/artwork/domain/ui"
 )
 
 type imageGraphRow struct {
-	ID        string
-	Name      string
-	Version   int64
-	Data      []byte
-	CreatedAt string
-	UpdatedAt string
+	ID         string
+	Name       string
+	ExternalID sql.NullString
+	Owner      string
+	Public     bool
+	Version    int64
+	Data       []byte
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	// SearchText is written for the search index and never read back
+	SearchText string
 }
 
 type layoutRow struct {
 	GraphID   string
+	UserID    string
 	Data      []byte
 	UpdatedAt string
 }
 
 type viewportRow struct {
 	GraphID   string
+	UserID    string
 	Data      []byte
 	UpdatedAt string
 }
 
 type imageGraphDTO struct {
-	Nodes map[string]nodeDTO `json:"nodes"`
+	ColorManagement string             `json:"color_management,omitempty"`
+	PerformanceMode bool               `json:"performance_mode,omitempty"`
+	Parameters      map[string]float64 `json:"parameters,omitempty"`
+	Seed            *int               `json:"seed,omitempty"`
+	Tags            []string           `json:"tags,omitempty"`
+	Shares          map[string]string  `json:"shares,omitempty"`
+	Nodes           map[string]nodeDTO `json:"nodes"`
 }
 
 type nodeDTO struct {
@@ -40,10 +59,23 @@ type nodeDTO struct {
 	Version        int64                `json:"version"`
 	Type           string               `json:"type"`
 	Name           string               `json:"name"`
+	Description    string               `json:"description,omitempty"`
+	Tags           []string             `json:"tags,omitempty"`
+	ExternalID     string               `json:"external_id,omitempty"`
 	State          string               `json:"state"`
+	Error          string               `json:"error,omitempty"`
 	Config         json.RawMessage      `json:"config"`
+	ConfigVersion  int                  `json:"config_version,omitempty"`
+	ConfigWarnings []string             `json:"config_warnings,omitempty"`
+	Expressions    map[string]string    `json:"expressions,omitempty"`
+	Implementation int                  `json:"implementation,omitempty"`
+	Bypassed       bool                 `json:"bypassed,omitempty"`
+	Pinned         bool                 `json:"pinned,omitempty"`
+	Stale          bool                 `json:"stale,omitempty"`
 	PreviewImageID string               `json:"preview_image_id,omitempty"`
 	ImageVersion   int64                `json:"image_version,omitempty"`
+	CreatedAt      time.Time            `json:"created_at,omitzero"`
+	UpdatedAt      time.Time            `json:"updated_at,omitzero"`
 	Inputs         map[string]inputDTO  `json:"inputs"`
 	Outputs        map[string]outputDTO `json:"outputs"`
 }
@@ -52,39 +84,63 @@ type inputDTO struct {
 	Name       string              `json:"name"`
 	ImageID    string              `json:"image_id,omitempty"`
 	Connected  bool                `json:"connected"`
+	Optional   bool                `json:"optional,omitempty"`
 	Connection *inputConnectionDTO `json:"connection,omitempty"`
 }
 
 type inputConnectionDTO struct {
 	NodeID     string `json:"node_id"`
 	OutputName string `json:"output_name"`
+	Transform  string `json:"transform,omitempty"`
 }
 
 type outputDTO struct {
 	Name        string                `json:"name"`
 	ImageID     string                `json:"image_id,omitempty"`
+	GeneratedAt time.Time             `json:"generated_at,omitzero"`
+	History     []outputVariantDTO    `json:"history,omitempty"`
 	Connections []outputConnectionDTO `json:"connections"`
 }
 
+type outputVariantDTO struct {
+	ImageID     string    `json:"image_id"`
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+}
+
 type outputConnectionDTO struct {
 	NodeID    string `json:"node_id"`
 	InputName string `json:"input_name"`
 }
 
 type layoutDTO struct {
-	NodePositions []nodePositionDTO `json:"node_positions"`
+	NodePositions []nodeLayoutDTO `json:"node_positions"`
 }
 
-type nodePositionDTO struct {
-	NodeID string  `json:"node_id"`
-	X      float64 `json:"x"`
-	Y      float64 `json:"y"`
+type nodeLayoutDTO struct {
+	NodeID    string  `json:"node_id"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Width     float64 `json:"width,omitempty"`
+	Height    float64 `json:"height,omitempty"`
+	Collapsed bool    `json:"collapsed,omitempty"`
+	Color     string  `json:"color,omitempty"`
+	ZOrder    int     `json:"z_order,omitempty"`
 }
 
 type viewportDTO struct {
-	Zoom float64 `json:"zoom"`
-	PanX float64 `json:"pan_x"`
-	PanY float64 `json:"pan_y"`
+	Zoom      float64               `json:"zoom"`
+	PanX      float64               `json:"pan_x"`
+	PanY      float64               `json:"pan_y"`
+	Bookmarks []viewportBookmarkDTO `json:"bookmarks,omitempty"`
+}
+
+type viewportBookmarkDTO struct {
+	Name    string  `json:"name"`
+	Zoom    float64 `json:"zoom"`
+	PanX    float64 `json:"pan_x"`
+	PanY    float64 `json:"pan_y"`
+	Default bool    `json:"default,omitempty"`
 }
 
 func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
@@ -96,6 +152,7 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 			inputDTO := inputDTO{
 				Name:      string(input.Name),
 				Connected: input.Connected,
+				Optional:  input.Optional,
 			}
 
 			if !input.ImageID.IsNil() {
@@ -106,6 +163,7 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 				inputDTO.Connection = &inputConnectionDTO{
 					NodeID:     input.InputConnection.NodeID.String(),
 					OutputName: string(input.InputConnection.OutputName),
+					Transform:  input.Transform,
 				}
 			}
 
@@ -116,6 +174,7 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 		for outputName, output := range node.Outputs {
 			outputDTO := outputDTO{
 				Name:        string(output.Name),
+				GeneratedAt: output.GeneratedAt,
 				Connections: make([]outputConnectionDTO, 0, len(output.Connections)),
 			}
 
@@ -123,6 +182,14 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 				outputDTO.ImageID = output.ImageID.String()
 			}
 
+			for _, variant := range output.History {
+				outputDTO.History = append(outputDTO.History, outputVariantDTO{
+					ImageID:     variant.ImageID.String(),
+					GeneratedAt: variant.GeneratedAt,
+					ReplacedAt:  variant.ReplacedAt,
+				})
+			}
+
 			for conn := range output.Connections {
 				outputDTO.Connections = append(outputDTO.Connections, outputConnectionDTO{
 					NodeID:    conn.NodeID.String(),
@@ -139,15 +206,28 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 		}
 
 		nodeDTO := nodeDTO{
-			ID:           node.ID.String(),
-			Version:      int64(node.Version),
-			Type:         imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
-			Name:         node.Name,
-			State:        imagegraph.NodeStateMapper.FromWithDefault(node.State.Get(), "unknown"),
-			Config:       configJSON,
-			ImageVersion: int64(node.ImageVersion),
-			Inputs:       inputsDTO,
-			Outputs:      outputsDTO,
+			ID:             node.ID.String(),
+			Version:        int64(node.Version),
+			Type:           imagegraph.NodeTypeMapper.FromWithDefault(node.Type, "unknown"),
+			Name:           node.Name,
+			Description:    node.Description,
+			Tags:           node.Tags,
+			ExternalID:     node.ExternalID,
+			State:          imagegraph.NodeStateMapper.FromWithDefault(node.State.Get(), "unknown"),
+			Error:          node.Error,
+			Config:         configJSON,
+			ConfigVersion:  imagegraph.NodeTypeDefs[node.Type].ConfigVersion(),
+			ConfigWarnings: node.ConfigWarnings,
+			Expressions:    node.Expressions,
+			Implementation: node.Implementation,
+			Bypassed:       node.Bypassed,
+			Pinned:         node.Pinned,
+			Stale:          node.Stale,
+			ImageVersion:   int64(node.ImageVersion),
+			CreatedAt:      node.CreatedAt,
+			UpdatedAt:      node.UpdatedAt,
+			Inputs:         inputsDTO,
+			Outputs:        outputsDTO,
 		}
 
 		if !node.Preview.IsNil() {
@@ -157,8 +237,26 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 		nodesDTO[nodeID.String()] = nodeDTO
 	}
 
+	var sharesDTO map[string]string
+	if len(ig.Shares) > 0 {
+		sharesDTO = make(map[string]string, len(ig.Shares))
+		for userID, role := range ig.Shares {
+			roleStr, err := imagegraph.RoleMapper.From(role)
+			if err != nil {
+				return imageGraphRow{}, fmt.Errorf("failed to map role for user %q: %w", userID, err)
+			}
+			sharesDTO[userID] = roleStr
+		}
+	}
+
 	dto := imageGraphDTO{
-		Nodes: nodesDTO,
+		ColorManagement: ig.ColorManagement,
+		PerformanceMode: ig.PerformanceMode,
+		Parameters:      ig.Parameters,
+		Seed:            ig.Seed,
+		Tags:            ig.Tags,
+		Shares:          sharesDTO,
+		Nodes:           nodesDTO,
 	}
 
 	dataJSON, err := json.Marshal(dto)
@@ -167,10 +265,16 @@ func serializeImageGraph(ig *imagegraph.ImageGraph) (imageGraphRow, error) {
 	}
 
 	return imageGraphRow{
-		ID:      ig.ID.String(),
-		Name:    ig.Name,
-		Version: int64(ig.Version),
-		Data:    dataJSON,
+		ID:         ig.ID.String(),
+		Name:       ig.Name,
+		ExternalID: sql.NullString{String: ig.ExternalID, Valid: ig.ExternalID != ""},
+		Owner:      ig.Owner,
+		Public:     ig.Public,
+		Version:    int64(ig.Version),
+		Data:       dataJSON,
+		CreatedAt:  ig.CreatedAt,
+		UpdatedAt:  ig.UpdatedAt,
+		SearchText: strings.Join(ig.SearchTerms(), "\n"),
 	}, nil
 }
 
@@ -210,6 +314,7 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 			input := &imagegraph.Input{
 				Name:      inputName,
 				Connected: inputDTO.Connected,
+				Optional:  inputDTO.Optional,
 			}
 
 			if inputDTO.ImageID != "" {
@@ -229,6 +334,7 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 					NodeID:     connNodeID,
 					OutputName: imagegraph.OutputName(inputDTO.Connection.OutputName),
 				}
+				input.Transform = inputDTO.Connection.Transform
 			}
 
 			inputs[inputName] = input
@@ -240,6 +346,7 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 
 			output := &imagegraph.Output{
 				Name:        outputName,
+				GeneratedAt: outputDTO.GeneratedAt,
 				Connections: make(map[imagegraph.OutputConnection]struct{}),
 			}
 
@@ -251,6 +358,18 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 				output.ImageID = imageID
 			}
 
+			for _, variantDTO := range outputDTO.History {
+				imageID, err := imagegraph.ParseImageID(variantDTO.ImageID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse output history image ID %s: %w", variantDTO.ImageID, err)
+				}
+				output.History = append(output.History, imagegraph.OutputVariant{
+					ImageID:     imageID,
+					GeneratedAt: variantDTO.GeneratedAt,
+					ReplacedAt:  variantDTO.ReplacedAt,
+				})
+			}
+
 			for _, connDTO := range outputDTO.Connections {
 				connNodeID, err := imagegraph.ParseNodeID(connDTO.NodeID)
 				if err != nil {
@@ -271,23 +390,51 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 			return nil, fmt.Errorf("failed to create node state: %w", err)
 		}
 
-		config := imagegraph.NewNodeConfig(nodeType)
-		if len(nodeDTO.Config) > 0 {
-			if err := json.Unmarshal(nodeDTO.Config, config); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal config for node %s: %w", nodeID, err)
-			}
+		// Configs stored at an older version of their node type's config
+		// schema are migrated, and what that changed is reported until the
+		// config is next set
+		config, migrated, err := imagegraph.DecodeNodeConfig(nodeType, nodeDTO.ConfigVersion, nodeDTO.Config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config for node %s: %w", nodeID, err)
 		}
 
 		node := &imagegraph.Node{
-			ID:           nodeID,
-			Version:      imagegraph.NodeVersion(nodeDTO.Version),
-			Type:         nodeType,
-			Name:         nodeDTO.Name,
-			State:        nodeStateObj,
-			Config:       config,
-			Inputs:       inputs,
-			Outputs:      outputs,
-			ImageVersion: imagegraph.NodeVersion(nodeDTO.ImageVersion),
+			ID:             nodeID,
+			Version:        imagegraph.NodeVersion(nodeDTO.Version),
+			Type:           nodeType,
+			Name:           nodeDTO.Name,
+			Description:    nodeDTO.Description,
+			Tags:           nodeDTO.Tags,
+			ExternalID:     nodeDTO.ExternalID,
+			State:          nodeStateObj,
+			Error:          nodeDTO.Error,
+			Config:         config,
+			ConfigWarnings: append(nodeDTO.ConfigWarnings, migrated...),
+			Expressions:    nodeDTO.Expressions,
+			Implementation: nodeDTO.Implementation,
+			Bypassed:       nodeDTO.Bypassed,
+			Pinned:         nodeDTO.Pinned,
+			Stale:          nodeDTO.Stale,
+			Inputs:         inputs,
+			Outputs:        outputs,
+			ImageVersion:   imagegraph.NodeVersion(nodeDTO.ImageVersion),
+			CreatedAt:      nodeDTO.CreatedAt,
+			UpdatedAt:      nodeDTO.UpdatedAt,
+		}
+
+		// Nodes stored before they were timestamped take their graph's
+		// timestamps
+		if node.CreatedAt.IsZero() {
+			node.CreatedAt = row.CreatedAt
+		}
+		if node.UpdatedAt.IsZero() {
+			node.UpdatedAt = row.UpdatedAt
+		}
+
+		// Nodes stored before implementations were versioned were generated
+		// by the first implementation of their type
+		if node.Implementation == 0 {
+			node.Implementation = 1
 		}
 
 		if nodeDTO.PreviewImageID != "" {
@@ -301,11 +448,40 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 		nodes[nodeID] = node
 	}
 
+	var shares imagegraph.Shares
+	if len(dto.Shares) > 0 {
+		shares = make(imagegraph.Shares, len(dto.Shares))
+		for userID, roleStr := range dto.Shares {
+			role, err := imagegraph.RoleMapper.To(roleStr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to map role for user %q: %w", userID, err)
+			}
+			shares[userID] = role
+		}
+	}
+
+	// Graphs saved before color management existed preserve profiles
+	colorManagement := dto.ColorManagement
+	if colorManagement == "" {
+		colorManagement = imagegraph.ColorManagementPreserve
+	}
+
 	ig := &imagegraph.ImageGraph{
-		ID:      id,
-		Name:    row.Name,
-		Version: imagegraph.ImageGraphVersion(row.Version),
-		Nodes:   nodes,
+		ID:              id,
+		Name:            row.Name,
+		ExternalID:      row.ExternalID.String,
+		Owner:           row.Owner,
+		Public:          row.Public,
+		ColorManagement: colorManagement,
+		PerformanceMode: dto.PerformanceMode,
+		Parameters:      dto.Parameters,
+		Seed:            dto.Seed,
+		Tags:            dto.Tags,
+		Shares:          shares,
+		Version:         imagegraph.ImageGraphVersion(row.Version),
+		CreatedAt:       row.CreatedAt,
+		UpdatedAt:       row.UpdatedAt,
+		Nodes:           nodes,
 	}
 
 	for _, node := range ig.Nodes {
@@ -316,17 +492,22 @@ func deserializeImageGraph(row imageGraphRow) (*imagegraph.ImageGraph, error) {
 }
 
 func serializeLayout(layout *ui.Layout) (layoutRow, error) {
-	positions := make([]nodePositionDTO, len(layout.NodePositions))
-	for i, pos := range layout.NodePositions {
-		positions[i] = nodePositionDTO{
-			NodeID: pos.NodeID.String(),
-			X:      pos.X,
-			Y:      pos.Y,
+	nodeLayouts := make([]nodeLayoutDTO, len(layout.NodeLayouts))
+	for i, nl := range layout.NodeLayouts {
+		nodeLayouts[i] = nodeLayoutDTO{
+			NodeID:    nl.NodeID.String(),
+			X:         nl.X,
+			Y:         nl.Y,
+			Width:     nl.Width,
+			Height:    nl.Height,
+			Collapsed: nl.Collapsed,
+			Color:     nl.Color,
+			ZOrder:    nl.ZOrder,
 		}
 	}
 
 	dto := layoutDTO{
-		NodePositions: positions,
+		NodePositions: nodeLayouts,
 	}
 
 	dataJSON, err := json.Marshal(dto)
@@ -336,6 +517,7 @@ func serializeLayout(layout *ui.Layout) (layoutRow, error) {
 
 	return layoutRow{
 		GraphID: layout.GraphID.String(),
+		UserID:  layout.UserID,
 		Data:    dataJSON,
 	}, nil
 }
@@ -351,22 +533,28 @@ func deserializeLayout(row layoutRow) (*ui.Layout, error) {
 		return nil, fmt.Errorf("failed to unmarshal layout data: %w", err)
 	}
 
-	positions := make([]ui.NodePosition, len(dto.NodePositions))
-	for i, posDTO := range dto.NodePositions {
-		nodeID, err := imagegraph.ParseNodeID(posDTO.NodeID)
+	nodeLayouts := make([]ui.NodeLayout, len(dto.NodePositions))
+	for i, nlDTO := range dto.NodePositions {
+		nodeID, err := imagegraph.ParseNodeID(nlDTO.NodeID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse node ID %s: %w", posDTO.NodeID, err)
+			return nil, fmt.Errorf("failed to parse node ID %s: %w", nlDTO.NodeID, err)
 		}
-		positions[i] = ui.NodePosition{
-			NodeID: nodeID,
-			X:      posDTO.X,
-			Y:      posDTO.Y,
+		nodeLayouts[i] = ui.NodeLayout{
+			NodeID:    nodeID,
+			X:         nlDTO.X,
+			Y:         nlDTO.Y,
+			Width:     nlDTO.Width,
+			Height:    nlDTO.Height,
+			Collapsed: nlDTO.Collapsed,
+			Color:     nlDTO.Color,
+			ZOrder:    nlDTO.ZOrder,
 		}
 	}
 
 	layout := &ui.Layout{
-		GraphID:       graphID,
-		NodePositions: positions,
+		GraphID:     graphID,
+		UserID:      row.UserID,
+		NodeLayouts: nodeLayouts,
 	}
 
 	return layout, nil
@@ -379,6 +567,16 @@ func serializeViewport(viewport *ui.Viewport) (viewportRow, error) {
 		PanY: viewport.PanY,
 	}
 
+	for _, b := range viewport.Bookmarks {
+		dto.Bookmarks = append(dto.Bookmarks, viewportBookmarkDTO{
+			Name:    b.Name,
+			Zoom:    b.Zoom,
+			PanX:    b.PanX,
+			PanY:    b.PanY,
+			Default: b.Default,
+		})
+	}
+
 	dataJSON, err := json.Marshal(dto)
 	if err != nil {
 		return viewportRow{}, fmt.Errorf("failed to marshal viewport data: %w", err)
@@ -386,6 +584,7 @@ func serializeViewport(viewport *ui.Viewport) (viewportRow, error) {
 
 	return viewportRow{
 		GraphID: viewport.GraphID.String(),
+		UserID:  viewport.UserID,
 		Data:    dataJSON,
 	}, nil
 }
@@ -403,10 +602,191 @@ func deserializeViewport(row viewportRow) (*ui.Viewport, error) {
 
 	viewport := &ui.Viewport{
 		GraphID: graphID,
+		UserID:  row.UserID,
 		Zoom:    dto.Zoom,
 		PanX:    dto.PanX,
 		PanY:    dto.PanY,
 	}
 
+	for _, b := range dto.Bookmarks {
+		viewport.Bookmarks = append(viewport.Bookmarks, ui.ViewportBookmark{
+			Name:    b.Name,
+			Zoom:    b.Zoom,
+			PanX:    b.PanX,
+			PanY:    b.PanY,
+			Default: b.Default,
+		})
+	}
+
 	return viewport, nil
 }
+
+type imageGraphSummaryRow struct {
+	ImageGraphID     string
+	Version          int64
+	Name             string
+	Owner            string
+	Public           bool
+	Tags             []byte
+	Shares           []byte
+	NodeCount        int
+	ThumbnailImageID sql.NullString
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func serializeImageGraphSummary(summary application.ImageGraphSummary) (imageGraphSummaryRow, error) {
+	tags := summary.Tags
+	if tags == nil {
+		tags = imagegraph.Tags{}
+	}
+
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return imageGraphSummaryRow{}, fmt.Errorf("failed to marshal summary tags: %w", err)
+	}
+
+	shares := make(map[string]string, len(summary.Shares))
+	for userID, role := range summary.Shares {
+		roleStr, err := imagegraph.RoleMapper.From(role)
+		if err != nil {
+			return imageGraphSummaryRow{}, fmt.Errorf("failed to map role for user %q: %w", userID, err)
+		}
+		shares[userID] = roleStr
+	}
+
+	sharesJSON, err := json.Marshal(shares)
+	if err != nil {
+		return imageGraphSummaryRow{}, fmt.Errorf("failed to marshal summary shares: %w", err)
+	}
+
+	row := imageGraphSummaryRow{
+		ImageGraphID: summary.ID.String(),
+		Version:      int64(summary.Version),
+		Name:         summary.Name,
+		Owner:        summary.Owner,
+		Public:       summary.Public,
+		Tags:         tagsJSON,
+		Shares:       sharesJSON,
+		NodeCount:    summary.NodeCount,
+		CreatedAt:    summary.CreatedAt,
+		UpdatedAt:    summary.UpdatedAt,
+	}
+
+	if !summary.ThumbnailImageID.IsNil() {
+		row.ThumbnailImageID = sql.NullString{String: summary.ThumbnailImageID.String(), Valid: true}
+	}
+
+	return row, nil
+}
+
+func deserializeImageGraphSummary(row imageGraphSummaryRow) (application.ImageGraphSummary, error) {
+	id, err := imagegraph.ParseImageGraphID(row.ImageGraphID)
+	if err != nil {
+		return application.ImageGraphSummary{}, fmt.Errorf("failed to parse image graph ID: %w", err)
+	}
+
+	summary := application.ImageGraphSummary{
+		ID:        id,
+		Version:   imagegraph.ImageGraphVersion(row.Version),
+		Name:      row.Name,
+		Owner:     row.Owner,
+		Public:    row.Public,
+		NodeCount: row.NodeCount,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+
+	if err := json.Unmarshal(row.Tags, &summary.Tags); err != nil {
+		return application.ImageGraphSummary{}, fmt.Errorf("failed to unmarshal summary tags: %w", err)
+	}
+	if len(summary.Tags) == 0 {
+		summary.Tags = nil
+	}
+
+	var shares map[string]string
+	if err := json.Unmarshal(row.Shares, &shares); err != nil {
+		return application.ImageGraphSummary{}, fmt.Errorf("failed to unmarshal summary shares: %w", err)
+	}
+	if len(shares) > 0 {
+		summary.Shares = make(imagegraph.Shares, len(shares))
+		for userID, roleStr := range shares {
+			role, err := imagegraph.RoleMapper.To(roleStr)
+			if err != nil {
+				return application.ImageGraphSummary{}, fmt.Errorf("failed to map role for user %q: %w", userID, err)
+			}
+			summary.Shares[userID] = role
+		}
+	}
+
+	if row.ThumbnailImageID.Valid {
+		summary.ThumbnailImageID, err = imagegraph.ParseImageID(row.ThumbnailImageID.String)
+		if err != nil {
+			return application.ImageGraphSummary{}, fmt.Errorf("failed to parse thumbnail image ID: %w", err)
+		}
+	}
+
+	return summary, nil
+}
+
+// eventTypes creates an empty event for each stored event type, so that
+// events can be decoded from the outbox
+var eventTypes = map[string]func() messages.Event{
+	"Created":                    func() messages.Event { return &imagegraph.CreatedEvent{} },
+	"PublicSet":                  func() messages.Event { return &imagegraph.PublicSetEvent{} },
+	"ColorManagementSet":         func() messages.Event { return &imagegraph.ColorManagementSetEvent{} },
+	"ParametersSet":              func() messages.Event { return &imagegraph.ParametersSetEvent{} },
+	"SeedSet":                    func() messages.Event { return &imagegraph.SeedSetEvent{} },
+	"PerformanceModeSet":         func() messages.Event { return &imagegraph.PerformanceModeSetEvent{} },
+	"TagAdded":                   func() messages.Event { return &imagegraph.TagAddedEvent{} },
+	"TagRemoved":                 func() messages.Event { return &imagegraph.TagRemovedEvent{} },
+	"Shared":                     func() messages.Event { return &imagegraph.SharedEvent{} },
+	"Unshared":                   func() messages.Event { return &imagegraph.UnsharedEvent{} },
+	"NodeAdded":                  func() messages.Event { return &imagegraph.NodeAddedEvent{} },
+	"NodeRemoved":                func() messages.Event { return &imagegraph.NodeRemovedEvent{} },
+	"NodeCreated":                func() messages.Event { return &imagegraph.NodeCreatedEvent{} },
+	"NodeInputConnected":         func() messages.Event { return &imagegraph.NodeInputConnectedEvent{} },
+	"NodeInputTransformSet":      func() messages.Event { return &imagegraph.NodeInputTransformSetEvent{} },
+	"NodeInputAdded":             func() messages.Event { return &imagegraph.NodeInputAddedEvent{} },
+	"NodeInputRemoved":           func() messages.Event { return &imagegraph.NodeInputRemovedEvent{} },
+	"NodeInputDisconnected":      func() messages.Event { return &imagegraph.NodeInputDisconnectedEvent{} },
+	"NodeOutputConnected":        func() messages.Event { return &imagegraph.NodeOutputConnectedEvent{} },
+	"NodeOutputDisconnected":     func() messages.Event { return &imagegraph.NodeOutputDisconnectedEvent{} },
+	"NodeOutputImageSet":         func() messages.Event { return &imagegraph.NodeOutputImageSetEvent{} },
+	"NodeOutputImageUnset":       func() messages.Event { return &imagegraph.NodeOutputImageUnsetEvent{} },
+	"NodeOutputVariantsDropped":  func() messages.Event { return &imagegraph.NodeOutputVariantsDroppedEvent{} },
+	"NodeInputImageSet":          func() messages.Event { return &imagegraph.NodeInputImageSetEvent{} },
+	"NodeInputImageUnset":        func() messages.Event { return &imagegraph.NodeInputImageUnsetEvent{} },
+	"NodeConfigSet":              func() messages.Event { return &imagegraph.NodeConfigSetEvent{} },
+	"NodeNameSet":                func() messages.Event { return &imagegraph.NodeNameSetEvent{} },
+	"NodeDescriptionSet":         func() messages.Event { return &imagegraph.NodeDescriptionSetEvent{} },
+	"NodeTagAdded":               func() messages.Event { return &imagegraph.NodeTagAddedEvent{} },
+	"NodeTagRemoved":             func() messages.Event { return &imagegraph.NodeTagRemovedEvent{} },
+	"NodeBypassSet":              func() messages.Event { return &imagegraph.NodeBypassSetEvent{} },
+	"NodeImplementationSet":      func() messages.Event { return &imagegraph.NodeImplementationSetEvent{} },
+	"NodeExpressionsSet":         func() messages.Event { return &imagegraph.NodeExpressionsSetEvent{} },
+	"NodePinnedSet":              func() messages.Event { return &imagegraph.NodePinnedSetEvent{} },
+	"NodeRegenerationSuppressed": func() messages.Event { return &imagegraph.NodeRegenerationSuppressedEvent{} },
+	"NodePreviewSet":             func() messages.Event { return &imagegraph.NodePreviewSetEvent{} },
+	"NodePreviewUnset":           func() messages.Event { return &imagegraph.NodePreviewUnsetEvent{} },
+	"NodeGenerationFailed":       func() messages.Event { return &imagegraph.NodeGenerationFailedEvent{} },
+	"NodeNeedsOutputs":           func() messages.Event { return &imagegraph.NodeNeedsOutputsEvent{} },
+	"LayoutUpdated":              func() messages.Event { return &ui.LayoutUpdatedEvent{} },
+	"ViewportUpdated":            func() messages.Event { return &ui.ViewportUpdatedEvent{} },
+	"ViewportBookmarkSaved":      func() messages.Event { return &ui.ViewportBookmarkSavedEvent{} },
+	"ViewportBookmarkDeleted":    func() messages.Event { return &ui.ViewportBookmarkDeletedEvent{} },
+}
+
+func deserializeEvent(eventType string, data []byte) (messages.Event, error) {
+	newEvent, ok := eventTypes[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+
+	event := newEvent()
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s event: %w", eventType, err)
+	}
+
+	return event, nil
+}
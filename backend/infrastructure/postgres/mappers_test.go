@@ -1,7 +1,12 @@
 package postgres
 
 import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dmpettyp/dorky/state"
 
@@ -27,19 +32,41 @@ func TestImageGraphRoundTrip(t *testing.T) {
 		t.Fatalf("failed to create node2 state: %v", err)
 	}
 
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	seed := 7
+
 	original := &imagegraph.ImageGraph{
-		ID:      imageGraphID,
-		Name:    "Test Graph",
-		Version: 5,
+		ID:              imageGraphID,
+		Name:            "Test Graph",
+		ExternalID:      "asset-42",
+		Owner:           "alice",
+		Shares:          imagegraph.Shares{"bob": imagegraph.RoleEditor, "carol": imagegraph.RoleViewer},
+		Public:          true,
+		ColorManagement: imagegraph.ColorManagementConvertSRGB,
+		PerformanceMode: true,
+		Parameters:      imagegraph.GraphParameters{"target_width": 800},
+		Seed:            &seed,
+		Tags:            imagegraph.Tags{"landscape", "pixelart"},
+		Version:         5,
+		CreatedAt:       created,
+		UpdatedAt:       updated,
 		Nodes: imagegraph.Nodes{
 			node1ID: {
-				ID:      node1ID,
-				Version: 2,
-				Type:    imagegraph.NodeTypeBlur,
-				Name:    "Blur Node",
-				State:   node1State,
-				Config:  &imagegraph.NodeConfigBlur{Radius: 5},
-				Preview: previewID,
+				ID:          node1ID,
+				Version:     2,
+				Type:        imagegraph.NodeTypeBlur,
+				Name:        "Blur Node",
+				Description: "Softens the background",
+				CreatedAt:   created,
+				UpdatedAt:   updated,
+				Tags:        imagegraph.Tags{"soften"},
+				ExternalID:  "asset-42-blur",
+				State:       node1State,
+				Config:      &imagegraph.NodeConfigBlur{Radius: 5},
+				Expressions: imagegraph.ConfigExpressions{"radius": "input.width / 100"},
+				Bypassed:    true,
+				Preview:     previewID,
 				Inputs: imagegraph.Inputs{
 					"input": {
 						Name:      "input",
@@ -51,6 +78,9 @@ func TestImageGraphRoundTrip(t *testing.T) {
 					"output": {
 						Name:    "output",
 						ImageID: imageID2,
+						History: []imagegraph.OutputVariant{
+							{ImageID: imagegraph.MustNewImageID(), GeneratedAt: created, ReplacedAt: updated},
+						},
 						Connections: map[imagegraph.OutputConnection]struct{}{
 							{NodeID: node2ID, InputName: "input"}: {},
 						},
@@ -73,18 +103,26 @@ func TestImageGraphRoundTrip(t *testing.T) {
 							NodeID:     node1ID,
 							OutputName: "output",
 						},
+						Transform: imagegraph.ConnectionTransformLuminance,
 					},
 				},
 				Outputs: imagegraph.Outputs{},
 			},
 		},
 	}
+	original.Nodes[node1ID].ConfigWarnings = []string{"radius was rounded"}
 
 	row, err := serializeImageGraph(original)
 	if err != nil {
 		t.Fatalf("serializeImageGraph failed: %v", err)
 	}
 
+	for _, term := range []string{"test graph", "pixelart", "blur node", "soften", "output node"} {
+		if !strings.Contains(row.SearchText, term) {
+			t.Errorf("search text %q is missing %q", row.SearchText, term)
+		}
+	}
+
 	deserialized, err := deserializeImageGraph(row)
 	if err != nil {
 		t.Fatalf("deserializeImageGraph failed: %v", err)
@@ -98,6 +136,42 @@ func TestImageGraphRoundTrip(t *testing.T) {
 		t.Errorf("Name mismatch: got %v, want %v", deserialized.Name, original.Name)
 	}
 
+	if deserialized.ExternalID != original.ExternalID {
+		t.Errorf("ExternalID mismatch: got %v, want %v", deserialized.ExternalID, original.ExternalID)
+	}
+
+	if deserialized.Owner != original.Owner {
+		t.Errorf("Owner mismatch: got %v, want %v", deserialized.Owner, original.Owner)
+	}
+
+	if !maps.Equal(deserialized.Shares, original.Shares) {
+		t.Errorf("Shares mismatch: got %v, want %v", deserialized.Shares, original.Shares)
+	}
+
+	if deserialized.Public != original.Public {
+		t.Errorf("Public mismatch: got %v, want %v", deserialized.Public, original.Public)
+	}
+
+	if deserialized.ColorManagement != original.ColorManagement {
+		t.Errorf("ColorManagement mismatch: got %v, want %v", deserialized.ColorManagement, original.ColorManagement)
+	}
+
+	if deserialized.PerformanceMode != original.PerformanceMode {
+		t.Errorf("PerformanceMode mismatch: got %v, want %v", deserialized.PerformanceMode, original.PerformanceMode)
+	}
+
+	if !maps.Equal(deserialized.Parameters, original.Parameters) {
+		t.Errorf("Parameters mismatch: got %v, want %v", deserialized.Parameters, original.Parameters)
+	}
+
+	if deserialized.Seed == nil || *deserialized.Seed != seed {
+		t.Errorf("Seed mismatch: got %v, want %d", deserialized.Seed, seed)
+	}
+
+	if len(deserialized.Tags) != 2 || deserialized.Tags[0] != "landscape" || deserialized.Tags[1] != "pixelart" {
+		t.Errorf("Tags mismatch: got %v, want %v", deserialized.Tags, original.Tags)
+	}
+
 	if deserialized.Version != original.Version {
 		t.Errorf("Version mismatch: got %v, want %v", deserialized.Version, original.Version)
 	}
@@ -115,6 +189,41 @@ func TestImageGraphRoundTrip(t *testing.T) {
 		t.Errorf("node1 type mismatch: got %v, want %v", node1.Type, imagegraph.NodeTypeBlur)
 	}
 
+	if node1.ExternalID != "asset-42-blur" {
+		t.Errorf("node1 external ID mismatch: got %v, want asset-42-blur", node1.ExternalID)
+	}
+
+	if len(node1.Tags) != 1 || node1.Tags[0] != "soften" {
+		t.Errorf("node1 tags mismatch: got %v, want [soften]", node1.Tags)
+	}
+
+	if !deserialized.CreatedAt.Equal(created) || !deserialized.UpdatedAt.Equal(updated) {
+		t.Errorf("graph timestamps mismatch: got %v/%v, want %v/%v", deserialized.CreatedAt, deserialized.UpdatedAt, created, updated)
+	}
+
+	if !node1.CreatedAt.Equal(created) || !node1.UpdatedAt.Equal(updated) {
+		t.Errorf("node1 timestamps mismatch: got %v/%v, want %v/%v", node1.CreatedAt, node1.UpdatedAt, created, updated)
+	}
+
+	if node1.Description != "Softens the background" {
+		t.Errorf("node1 description mismatch: got %q, want %q", node1.Description, "Softens the background")
+	}
+
+	if node1.Expressions["radius"] != "input.width / 100" {
+		t.Errorf("node1 expressions mismatch: got %v", node1.Expressions)
+	}
+	if !node1.Bypassed {
+		t.Error("node1 bypass was not preserved")
+	}
+
+	if node1.Implementation != 1 {
+		t.Errorf("node1 implementation mismatch: got %v, want 1", node1.Implementation)
+	}
+
+	if len(node1.ConfigWarnings) != 1 || node1.ConfigWarnings[0] != "radius was rounded" {
+		t.Errorf("node1 config warnings mismatch: got %v", node1.ConfigWarnings)
+	}
+
 	if node1.State.Get() != imagegraph.Generating {
 		t.Errorf("node1 state mismatch: got %v, want %v", node1.State.Get(), imagegraph.Generating)
 	}
@@ -175,6 +284,10 @@ func TestImageGraphRoundTrip(t *testing.T) {
 	if node2Input.InputConnection.OutputName != "output" {
 		t.Errorf("node2 input connection OutputName mismatch: got %v, want output", node2Input.InputConnection.OutputName)
 	}
+
+	if node2Input.Transform != imagegraph.ConnectionTransformLuminance {
+		t.Errorf("node2 input Transform mismatch: got %q, want luminance", node2Input.Transform)
+	}
 }
 
 func TestImageGraphEmptyNodes(t *testing.T) {
@@ -209,9 +322,10 @@ func TestLayoutRoundTrip(t *testing.T) {
 
 	original := &ui.Layout{
 		GraphID: graphID,
-		NodePositions: []ui.NodePosition{
+		UserID:  "user-1",
+		NodeLayouts: []ui.NodeLayout{
 			{NodeID: node1ID, X: 100.5, Y: 200.75},
-			{NodeID: node2ID, X: 300.25, Y: 400.5},
+			{NodeID: node2ID, X: 300.25, Y: 400.5, Width: 260, Height: 90, Collapsed: true, Color: "blue", ZOrder: 3},
 		},
 	}
 
@@ -229,32 +343,53 @@ func TestLayoutRoundTrip(t *testing.T) {
 		t.Errorf("GraphID mismatch: got %v, want %v", deserialized.GraphID, original.GraphID)
 	}
 
-	if len(deserialized.NodePositions) != len(original.NodePositions) {
-		t.Fatalf("NodePositions count mismatch: got %d, want %d", len(deserialized.NodePositions), len(original.NodePositions))
+	if deserialized.UserID != original.UserID {
+		t.Errorf("UserID mismatch: got %v, want %v", deserialized.UserID, original.UserID)
 	}
 
-	for i, pos := range deserialized.NodePositions {
-		origPos := original.NodePositions[i]
-		if pos.NodeID != origPos.NodeID {
-			t.Errorf("Position %d NodeID mismatch: got %v, want %v", i, pos.NodeID, origPos.NodeID)
-		}
-		if pos.X != origPos.X {
-			t.Errorf("Position %d X mismatch: got %v, want %v", i, pos.X, origPos.X)
-		}
-		if pos.Y != origPos.Y {
-			t.Errorf("Position %d Y mismatch: got %v, want %v", i, pos.Y, origPos.Y)
+	if len(deserialized.NodeLayouts) != len(original.NodeLayouts) {
+		t.Fatalf("NodeLayouts count mismatch: got %d, want %d", len(deserialized.NodeLayouts), len(original.NodeLayouts))
+	}
+
+	for i, nl := range deserialized.NodeLayouts {
+		if nl != original.NodeLayouts[i] {
+			t.Errorf("NodeLayout %d mismatch: got %+v, want %+v", i, nl, original.NodeLayouts[i])
 		}
 	}
 }
 
+func TestLayoutDeserializesPositionOnlyData(t *testing.T) {
+	nodeID := imagegraph.MustNewNodeID()
+
+	row := layoutRow{
+		GraphID: imagegraph.MustNewImageGraphID().String(),
+		Data:    []byte(`{"node_positions":[{"node_id":"` + nodeID.String() + `","x":10,"y":20}]}`),
+	}
+
+	layout, err := deserializeLayout(row)
+	if err != nil {
+		t.Fatalf("deserializeLayout failed: %v", err)
+	}
+
+	want := ui.NodeLayout{NodeID: nodeID, X: 10, Y: 20}
+	if len(layout.NodeLayouts) != 1 || layout.NodeLayouts[0] != want {
+		t.Errorf("expected %+v, got %+v", want, layout.NodeLayouts)
+	}
+}
+
 func TestViewportRoundTrip(t *testing.T) {
 	graphID := imagegraph.MustNewImageGraphID()
 
 	original := &ui.Viewport{
 		GraphID: graphID,
+		UserID:  "user-1",
 		Zoom:    1.5,
 		PanX:    100.25,
 		PanY:    200.75,
+		Bookmarks: []ui.ViewportBookmark{
+			{Name: "inputs", Zoom: 0.8, PanX: -50, PanY: 10},
+			{Name: "exports", Zoom: 1.2, PanX: 400, PanY: 30, Default: true},
+		},
 	}
 
 	row, err := serializeViewport(original)
@@ -271,6 +406,10 @@ func TestViewportRoundTrip(t *testing.T) {
 		t.Errorf("GraphID mismatch: got %v, want %v", deserialized.GraphID, original.GraphID)
 	}
 
+	if deserialized.UserID != original.UserID {
+		t.Errorf("UserID mismatch: got %v, want %v", deserialized.UserID, original.UserID)
+	}
+
 	if deserialized.Zoom != original.Zoom {
 		t.Errorf("Zoom mismatch: got %v, want %v", deserialized.Zoom, original.Zoom)
 	}
@@ -282,6 +421,10 @@ func TestViewportRoundTrip(t *testing.T) {
 	if deserialized.PanY != original.PanY {
 		t.Errorf("PanY mismatch: got %v, want %v", deserialized.PanY, original.PanY)
 	}
+
+	if !reflect.DeepEqual(deserialized.Bookmarks, original.Bookmarks) {
+		t.Errorf("Bookmarks mismatch: got %+v, want %+v", deserialized.Bookmarks, original.Bookmarks)
+	}
 }
 
 func TestNodeTypeMapping(t *testing.T) {
@@ -381,3 +524,65 @@ func TestNodeStateMapperIsComplete(t *testing.T) {
 		}
 	}
 }
+
+func TestEventRoundTrip(t *testing.T) {
+	ig, err := imagegraph.NewImageGraph(imagegraph.MustNewImageGraphID(), "Test Graph")
+	if err != nil {
+		t.Fatalf("failed to create image graph: %v", err)
+	}
+
+	inputID := imagegraph.MustNewNodeID()
+	blurID := imagegraph.MustNewNodeID()
+
+	ig.AddNode(inputID, imagegraph.NodeTypeInput, "input")
+	ig.AddNode(blurID, imagegraph.NodeTypeBlur, "blur")
+	ig.ConnectNodes(inputID, "original", blurID, "original")
+	ig.SetNodeConfig(blurID, &imagegraph.NodeConfigBlur{Radius: 3})
+	ig.Share("bob", imagegraph.RoleEditor)
+
+	inputNode, _ := ig.Nodes.Get(inputID)
+	ig.SetNodeOutputImage(inputID, "original", imagegraph.MustNewImageID(), inputNode.Version)
+
+	events := ig.GetEvents()
+	if len(events) == 0 {
+		t.Fatal("expected events")
+	}
+
+	for _, original := range events {
+		t.Run(original.GetType(), func(t *testing.T) {
+			data, err := json.Marshal(original)
+			if err != nil {
+				t.Fatalf("failed to marshal event: %v", err)
+			}
+
+			decoded, err := deserializeEvent(original.GetType(), data)
+			if err != nil {
+				t.Fatalf("failed to deserialize event: %v", err)
+			}
+
+			if reflect.TypeOf(decoded) != reflect.TypeOf(original) {
+				t.Fatalf("expected %T, got %T", original, decoded)
+			}
+
+			roundTripped, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("failed to marshal decoded event: %v", err)
+			}
+			if string(roundTripped) != string(data) {
+				t.Errorf("round-trip mismatch:\n got  %s\n want %s", roundTripped, data)
+			}
+		})
+	}
+}
+
+func TestEventTypesAreComplete(t *testing.T) {
+	for eventType, newEvent := range eventTypes {
+		if newEvent() == nil {
+			t.Errorf("event type %q creates nil", eventType)
+		}
+	}
+
+	if _, err := deserializeEvent("Unknown", []byte("{}")); err == nil {
+		t.Error("expected error for unknown event type")
+	}
+}
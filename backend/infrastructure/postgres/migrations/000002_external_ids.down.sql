@@ -0,0 +1,4 @@
+-- Rollback external IDs
+
+DROP INDEX IF EXISTS idx_image_graphs_external_id;
+ALTER TABLE image_graphs DROP COLUMN IF EXISTS external_id;
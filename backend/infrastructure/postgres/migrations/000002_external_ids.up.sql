@@ -0,0 +1,6 @@
+-- Client-supplied external IDs for image graphs. Node external IDs are stored
+-- in the graph's JSONB data and are unique within their graph.
+
+ALTER TABLE image_graphs ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX idx_image_graphs_external_id ON image_graphs(external_id);
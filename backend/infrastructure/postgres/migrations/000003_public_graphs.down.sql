@@ -0,0 +1,4 @@
+-- Rollback public image graphs
+
+DROP INDEX IF EXISTS idx_image_graphs_public;
+ALTER TABLE image_graphs DROP COLUMN IF EXISTS public;
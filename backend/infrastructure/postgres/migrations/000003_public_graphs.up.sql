@@ -0,0 +1,5 @@
+-- Public image graphs expose their Output node images through the gallery.
+
+ALTER TABLE image_graphs ADD COLUMN public BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_image_graphs_public ON image_graphs(created_at DESC) WHERE public;
@@ -0,0 +1,3 @@
+-- Rollback image graph tag index
+
+DROP INDEX IF EXISTS idx_image_graphs_tags;
@@ -0,0 +1,4 @@
+-- Image graph tags are stored in the aggregate data; index them so graphs
+-- can be listed by tag.
+
+CREATE INDEX idx_image_graphs_tags ON image_graphs USING GIN ((data->'tags'));
@@ -0,0 +1,4 @@
+-- Rollback graph search
+
+DROP INDEX IF EXISTS idx_image_graphs_search_text;
+ALTER TABLE image_graphs DROP COLUMN IF EXISTS search_text;
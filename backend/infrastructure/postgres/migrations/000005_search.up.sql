@@ -0,0 +1,32 @@
+-- Graph search matches names and tags of graphs and their nodes. The
+-- repository keeps them, lowercased and newline separated, in search_text,
+-- and a trigram index serves the substring queries.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE image_graphs ADD COLUMN search_text TEXT NOT NULL DEFAULT '';
+
+UPDATE image_graphs g
+SET search_text = lower(concat_ws(E'\n',
+    g.name,
+    (
+        SELECT string_agg(tag, E'\n')
+        FROM jsonb_array_elements_text(
+            CASE WHEN jsonb_typeof(g.data->'tags') = 'array' THEN g.data->'tags' ELSE '[]' END
+        ) AS tag
+    ),
+    (
+        SELECT string_agg(concat_ws(E'\n',
+            node->>'name',
+            (
+                SELECT string_agg(tag, E'\n')
+                FROM jsonb_array_elements_text(
+                    CASE WHEN jsonb_typeof(node->'tags') = 'array' THEN node->'tags' ELSE '[]' END
+                ) AS tag
+            )
+        ), E'\n')
+        FROM jsonb_each(g.data->'nodes') AS nodes(node_id, node)
+    )
+));
+
+CREATE INDEX idx_image_graphs_search_text ON image_graphs USING GIN (search_text gin_trgm_ops);
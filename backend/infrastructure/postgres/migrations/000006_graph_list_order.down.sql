@@ -0,0 +1,4 @@
+-- Rollback graph list order indexes
+
+DROP INDEX IF EXISTS idx_image_graphs_updated_at;
+DROP INDEX IF EXISTS idx_image_graphs_created_at;
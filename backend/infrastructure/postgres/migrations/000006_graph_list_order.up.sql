@@ -0,0 +1,5 @@
+-- Graph lists are paged in name, created or updated order, with the ID
+-- breaking ties. Names are already indexed.
+
+CREATE INDEX idx_image_graphs_created_at ON image_graphs(created_at, id);
+CREATE INDEX idx_image_graphs_updated_at ON image_graphs(updated_at, id);
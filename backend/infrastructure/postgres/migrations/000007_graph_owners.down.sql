@@ -0,0 +1,4 @@
+-- Rollback image graph owners
+
+DROP INDEX IF EXISTS idx_image_graphs_owner;
+ALTER TABLE image_graphs DROP COLUMN IF EXISTS owner;
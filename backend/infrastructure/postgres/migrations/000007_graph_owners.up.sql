@@ -0,0 +1,6 @@
+-- Image graphs are owned by the user that created them. Graphs created
+-- without authentication have no owner and are only listed for admins.
+
+ALTER TABLE image_graphs ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_image_graphs_owner ON image_graphs(owner, created_at);
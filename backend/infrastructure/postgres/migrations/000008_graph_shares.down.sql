@@ -0,0 +1,3 @@
+-- Rollback image graph share index
+
+DROP INDEX IF EXISTS idx_image_graphs_shares;
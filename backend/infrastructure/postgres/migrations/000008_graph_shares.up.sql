@@ -0,0 +1,4 @@
+-- Image graph shares are stored in the aggregate data as a map of user ID to
+-- role; index them so users can list the graphs shared with them.
+
+CREATE INDEX idx_image_graphs_shares ON image_graphs USING GIN ((data->'shares'));
@@ -0,0 +1,15 @@
+-- API keys let scripts act as a user without an interactive session. Only
+-- the SHA-256 hash of each key's secret is stored.
+
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    rate_limit INTEGER NOT NULL,
+    secret_sha256 TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id, created_at);
@@ -0,0 +1,3 @@
+-- Rollback webhooks
+
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,11 @@
+-- Webhooks are notified each time a graph's pipeline completes
+
+CREATE TABLE webhooks (
+    id UUID PRIMARY KEY,
+    image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhooks_image_graph ON webhooks(image_graph_id, created_at);
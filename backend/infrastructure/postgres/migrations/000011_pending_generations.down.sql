@@ -0,0 +1,3 @@
+-- Rollback pending generations
+
+DROP TABLE IF EXISTS pending_generations;
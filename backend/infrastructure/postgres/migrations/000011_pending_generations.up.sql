@@ -0,0 +1,8 @@
+-- Nodes whose generation was interrupted by shutdown, resumed on startup
+
+CREATE TABLE pending_generations (
+    image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    node_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (image_graph_id, node_id)
+);
@@ -0,0 +1,3 @@
+-- Rollback image graph summaries
+
+DROP TABLE IF EXISTS image_graph_summaries;
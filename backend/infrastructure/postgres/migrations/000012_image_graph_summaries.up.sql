@@ -0,0 +1,37 @@
+-- Summaries that ImageGraphs are listed with, maintained by event handlers so
+-- that listing doesn't load every graph's full data
+
+CREATE TABLE image_graph_summaries (
+    image_graph_id UUID PRIMARY KEY REFERENCES image_graphs(id) ON DELETE CASCADE,
+    version BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    owner TEXT NOT NULL DEFAULT '',
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    tags JSONB NOT NULL DEFAULT '[]',
+    shares JSONB NOT NULL DEFAULT '{}',
+    node_count INTEGER NOT NULL DEFAULT 0,
+    thumbnail_image_id UUID,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_image_graph_summaries_tags ON image_graph_summaries USING GIN (tags);
+CREATE INDEX idx_image_graph_summaries_shares ON image_graph_summaries USING GIN (shares);
+
+-- Thumbnails of existing graphs are filled in when their outputs next change
+INSERT INTO image_graph_summaries (
+    image_graph_id, version, name, owner, public, tags, shares,
+    node_count, created_at, updated_at
+)
+SELECT
+    id,
+    version,
+    name,
+    owner,
+    public,
+    COALESCE(data->'tags', '[]'),
+    COALESCE(data->'shares', '{}'),
+    (SELECT COUNT(*) FROM jsonb_object_keys(COALESCE(data->'nodes', '{}'))),
+    created_at,
+    updated_at
+FROM image_graphs;
@@ -0,0 +1,7 @@
+-- Rollback event outbox
+
+DROP INDEX IF EXISTS idx_events_unpublished;
+
+ALTER TABLE events DROP COLUMN IF EXISTS publish_context;
+ALTER TABLE events DROP COLUMN IF EXISTS claimed_until;
+ALTER TABLE events DROP COLUMN IF EXISTS published_at;
@@ -0,0 +1,11 @@
+-- Events are published from the events table by an outbox relay, so events
+-- committed with their aggregate can't be lost before they're published
+
+ALTER TABLE events ADD COLUMN published_at TIMESTAMP;
+ALTER TABLE events ADD COLUMN claimed_until TIMESTAMP;
+ALTER TABLE events ADD COLUMN publish_context JSONB;
+
+-- Events stored before the outbox were published directly
+UPDATE events SET published_at = timestamp;
+
+CREATE INDEX idx_events_unpublished ON events(id) WHERE published_at IS NULL;
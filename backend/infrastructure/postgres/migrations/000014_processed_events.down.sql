@@ -0,0 +1,3 @@
+-- Rollback processed events
+
+DROP TABLE IF EXISTS processed_events;
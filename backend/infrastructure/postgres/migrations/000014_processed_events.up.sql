@@ -0,0 +1,10 @@
+-- Events each handler has processed, so redelivered events are skipped
+
+CREATE TABLE processed_events (
+    handler TEXT NOT NULL,
+    event_id UUID NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (handler, event_id)
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
@@ -0,0 +1,11 @@
+-- Rollback per-user layouts and viewports, keeping only the shared ones
+
+DELETE FROM layouts WHERE user_id <> '';
+ALTER TABLE layouts DROP CONSTRAINT layouts_pkey;
+ALTER TABLE layouts DROP COLUMN user_id;
+ALTER TABLE layouts ADD PRIMARY KEY (graph_id);
+
+DELETE FROM viewports WHERE user_id <> '';
+ALTER TABLE viewports DROP CONSTRAINT viewports_pkey;
+ALTER TABLE viewports DROP COLUMN user_id;
+ALTER TABLE viewports ADD PRIMARY KEY (graph_id);
@@ -0,0 +1,11 @@
+-- Layouts and viewports are saved per user, so collaborators don't move each
+-- other's canvas. Rows saved before have an empty user ID and are shared by
+-- everyone.
+
+ALTER TABLE layouts ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE layouts DROP CONSTRAINT layouts_pkey;
+ALTER TABLE layouts ADD PRIMARY KEY (graph_id, user_id);
+
+ALTER TABLE viewports ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE viewports DROP CONSTRAINT viewports_pkey;
+ALTER TABLE viewports ADD PRIMARY KEY (graph_id, user_id);
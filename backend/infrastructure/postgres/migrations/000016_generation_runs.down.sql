@@ -0,0 +1,3 @@
+-- Rollback generation runs
+
+DROP TABLE IF EXISTS generation_runs;
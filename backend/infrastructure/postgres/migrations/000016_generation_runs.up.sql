@@ -0,0 +1,16 @@
+-- How long each generation of a node took and the pixels it worked on
+
+CREATE TABLE generation_runs (
+    id BIGSERIAL PRIMARY KEY,
+    image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    node_id UUID NOT NULL,
+    node_type TEXT NOT NULL,
+    node_version BIGINT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    duration_ms DOUBLE PRECISION NOT NULL,
+    input_pixels BIGINT NOT NULL,
+    output_pixels BIGINT NOT NULL,
+    failed BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_generation_runs_node ON generation_runs(image_graph_id, node_id, started_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback snapshots
+
+DROP TABLE IF EXISTS snapshots;
@@ -0,0 +1,12 @@
+-- Named snapshots of the configs and output images of a graph's nodes
+
+CREATE TABLE snapshots (
+    id UUID PRIMARY KEY,
+    image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    nodes JSONB NOT NULL,
+    UNIQUE (image_graph_id, name)
+);
+
+CREATE INDEX idx_snapshots_image_graph ON snapshots(image_graph_id, created_at);
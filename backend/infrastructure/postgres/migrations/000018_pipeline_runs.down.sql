@@ -0,0 +1,3 @@
+-- Rollback pipeline runs
+
+DROP TABLE IF EXISTS pipeline_runs;
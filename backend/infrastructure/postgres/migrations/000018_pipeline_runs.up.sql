@@ -0,0 +1,16 @@
+-- Runs of a graph's pipeline, from an input image being set until the graph
+-- settles
+
+CREATE TABLE pipeline_runs (
+    id UUID PRIMARY KEY,
+    image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    trigger_node_id UUID NOT NULL,
+    trigger_image_id UUID NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    ended_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    nodes JSONB NOT NULL,
+    outputs JSONB NOT NULL
+);
+
+CREATE INDEX idx_pipeline_runs_image_graph ON pipeline_runs(image_graph_id, started_at DESC);
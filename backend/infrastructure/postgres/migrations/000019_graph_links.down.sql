@@ -0,0 +1,3 @@
+-- Rollback graph links
+
+DROP TABLE IF EXISTS graph_links;
@@ -0,0 +1,15 @@
+-- Graph links copy each image set on a node output to an Input node of
+-- another graph
+
+CREATE TABLE graph_links (
+    id UUID PRIMARY KEY,
+    source_image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    source_node_id UUID NOT NULL,
+    source_output_name TEXT NOT NULL,
+    target_image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    target_node_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_graph_links_source ON graph_links(source_image_graph_id);
+CREATE INDEX idx_graph_links_target ON graph_links(target_image_graph_id, created_at);
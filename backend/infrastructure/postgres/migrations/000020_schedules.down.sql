@@ -0,0 +1,4 @@
+-- Rollback schedules
+
+DROP TABLE IF EXISTS scheduled_runs;
+DROP TABLE IF EXISTS schedules;
@@ -0,0 +1,23 @@
+-- Schedules re-run a graph's pipeline on a cron expression, and scheduled
+-- runs record each time one came due
+
+CREATE TABLE schedules (
+    image_graph_id UUID PRIMARY KEY REFERENCES image_graphs(id) ON DELETE CASCADE,
+    cron TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_schedules_enabled ON schedules(created_at) WHERE enabled;
+
+CREATE TABLE scheduled_runs (
+    id BIGSERIAL PRIMARY KEY,
+    image_graph_id UUID NOT NULL REFERENCES image_graphs(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_scheduled_runs_image_graph ON scheduled_runs(image_graph_id, started_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback dead letters
+
+DROP TABLE IF EXISTS dead_letters;
@@ -0,0 +1,13 @@
+-- Dead letters keep the events a handler kept failing, with the handler's
+-- last error, until they are replayed or discarded
+
+CREATE TABLE dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    handler TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    event_data JSONB NOT NULL,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    first_failed_at TIMESTAMP NOT NULL,
+    last_failed_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// Outbox implements application.Outbox over the events table. Events a
+// UnitOfWork commits with WithOutbox are left unpublished for a relay.
+type Outbox struct {
+	db        *sql.DB
+	committed chan struct{}
+}
+
+func NewOutbox(db *sql.DB) *Outbox {
+	return &Outbox{
+		db:        db,
+		committed: make(chan struct{}, 1),
+	}
+}
+
+// Committed receives a value after a UnitOfWork commits events to the outbox.
+// Signals are coalesced, so one value may stand for many commits.
+func (o *Outbox) Committed() <-chan struct{} {
+	return o.committed
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.committed <- struct{}{}:
+	default:
+	}
+}
+
+// Claim leases up to limit unpublished events that aren't claimed by another
+// relay, oldest first
+func (o *Outbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]application.OutboxEvent, error) {
+	rows, err := o.db.QueryContext(ctx, `
+		UPDATE events
+		SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM events
+			WHERE published_at IS NULL
+			AND (claimed_until IS NULL OR claimed_until < NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, event_data, publish_context
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim events: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []application.OutboxEvent
+	for rows.Next() {
+		var (
+			id             int64
+			eventType      string
+			eventData      []byte
+			publishContext []byte
+		)
+
+		if err := rows.Scan(&id, &eventType, &eventData, &publishContext); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+
+		event, err := deserializeEvent(eventType, eventData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize event %d: %w", id, err)
+		}
+
+		var carrier map[string]string
+		if len(publishContext) > 0 {
+			if err := json.Unmarshal(publishContext, &carrier); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal publish context of event %d: %w", id, err)
+			}
+		}
+
+		claimed = append(claimed, application.OutboxEvent{ID: id, Event: event, Carrier: carrier})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate events: %w", err)
+	}
+
+	// UPDATE ... RETURNING doesn't keep the order of the subquery
+	slices.SortFunc(claimed, func(a, b application.OutboxEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return claimed, nil
+}
+
+// MarkPublished records that events were published so they aren't claimed
+// again
+func (o *Outbox) MarkPublished(ctx context.Context, ids []int64) error {
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE events SET published_at = NOW(), claimed_until = NULL
+		WHERE id = ANY($1)
+	`, ids)
+
+	if err != nil {
+		return fmt.Errorf("failed to mark events published: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// PendingGenerationStore implements application.PendingGenerationStore
+type PendingGenerationStore struct {
+	db *sql.DB
+}
+
+func NewPendingGenerationStore(db *sql.DB) *PendingGenerationStore {
+	return &PendingGenerationStore{db: db}
+}
+
+// Add stores PendingGenerations, ignoring nodes that are already pending and
+// ImageGraphs that no longer exist
+func (s *PendingGenerationStore) Add(ctx context.Context, pending []application.PendingGeneration) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, p := range pending {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO pending_generations (image_graph_id, node_id)
+				SELECT id, $2 FROM image_graphs WHERE id = $1
+				ON CONFLICT DO NOTHING
+			`, p.ImageGraphID.ID, p.NodeID.ID)
+
+			if err != nil {
+				return fmt.Errorf("failed to insert pending generation: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Take removes and returns every stored PendingGeneration
+func (s *PendingGenerationStore) Take(ctx context.Context) ([]application.PendingGeneration, error) {
+	var pending []application.PendingGeneration
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			DELETE FROM pending_generations
+			RETURNING image_graph_id, node_id
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to delete pending generations: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var imageGraphID, nodeID string
+
+			if err := rows.Scan(&imageGraphID, &nodeID); err != nil {
+				return fmt.Errorf("failed to scan pending generation: %w", err)
+			}
+
+			p, err := parsePendingGeneration(imageGraphID, nodeID)
+			if err != nil {
+				return err
+			}
+
+			pending = append(pending, p)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to iterate pending generations: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+func parsePendingGeneration(imageGraphID, nodeID string) (application.PendingGeneration, error) {
+	graphID, err := imagegraph.ParseImageGraphID(imageGraphID)
+	if err != nil {
+		return application.PendingGeneration{}, fmt.Errorf("failed to parse pending generation graph ID %s: %w", imageGraphID, err)
+	}
+
+	parsedNodeID, err := imagegraph.ParseNodeID(nodeID)
+	if err != nil {
+		return application.PendingGeneration{}, fmt.Errorf("failed to parse pending generation node ID %s: %w", nodeID, err)
+	}
+
+	return application.PendingGeneration{ImageGraphID: graphID, NodeID: parsedNodeID}, nil
+}
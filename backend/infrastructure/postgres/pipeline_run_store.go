@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// pipelineRunNodeDTO is the JSON form of a node in a pipeline run's nodes
+// column
+type pipelineRunNodeDTO struct {
+	NodeID      string    `json:"node_id"`
+	NodeType    string    `json:"node_type"`
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at,omitzero"`
+	Generations int       `json:"generations"`
+	Failed      bool      `json:"failed,omitempty"`
+}
+
+// pipelineRunOutputDTO is the JSON form of an output in a pipeline run's
+// outputs column
+type pipelineRunOutputDTO struct {
+	Name       string  `json:"name"`
+	NodeID     string  `json:"node_id"`
+	ImageID    string  `json:"image_id"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// PipelineRunStore implements application.PipelineRunStore
+type PipelineRunStore struct {
+	db *sql.DB
+}
+
+func NewPipelineRunStore(db *sql.DB) *PipelineRunStore {
+	return &PipelineRunStore{db: db}
+}
+
+// Add stores a PipelineRun, ignoring ImageGraphs that no longer exist and
+// runs that were already stored
+func (s *PipelineRunStore) Add(ctx context.Context, run application.PipelineRun) error {
+	nodes, outputs, err := serializePipelineRun(run)
+	if err != nil {
+		return fmt.Errorf("failed to serialize pipeline run: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO pipeline_runs (
+			id, image_graph_id, trigger_node_id, trigger_image_id,
+			started_at, ended_at, status, nodes, outputs
+		)
+		SELECT $1, id, $3, $4, $5, $6, $7, $8, $9 FROM image_graphs WHERE id = $2
+		ON CONFLICT (id) DO NOTHING
+	`,
+		run.ID,
+		run.ImageGraphID.ID,
+		run.TriggerNodeID.ID,
+		run.TriggerImageID.ID,
+		run.StartedAt,
+		run.EndedAt,
+		run.Status,
+		nodes,
+		outputs,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert pipeline run: %w", err)
+	}
+
+	return nil
+}
+
+// ListByImageGraph retrieves the most recent PipelineRuns of an ImageGraph,
+// newest first
+func (s *PipelineRunStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	limit int,
+) (
+	[]application.PipelineRun,
+	error,
+) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trigger_node_id, trigger_image_id, started_at, ended_at, status, nodes, outputs
+		FROM pipeline_runs
+		WHERE image_graph_id = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2
+	`, imageGraphID.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pipeline runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []application.PipelineRun
+	for rows.Next() {
+		run := application.PipelineRun{ImageGraphID: imageGraphID}
+
+		var triggerNodeID, triggerImageID string
+		var nodes, outputs []byte
+
+		err := rows.Scan(
+			&run.ID,
+			&triggerNodeID,
+			&triggerImageID,
+			&run.StartedAt,
+			&run.EndedAt,
+			&run.Status,
+			&nodes,
+			&outputs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan pipeline run: %w", err)
+		}
+
+		run.TriggerNodeID, err = imagegraph.ParseNodeID(triggerNodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trigger node ID %s: %w", triggerNodeID, err)
+		}
+
+		run.TriggerImageID, err = imagegraph.ParseImageID(triggerImageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trigger image ID %s: %w", triggerImageID, err)
+		}
+
+		if err := deserializePipelineRun(&run, nodes, outputs); err != nil {
+			return nil, fmt.Errorf("failed to deserialize pipeline run %s: %w", run.ID, err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate pipeline runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+func serializePipelineRun(run application.PipelineRun) ([]byte, []byte, error) {
+	nodeDTOs := make([]pipelineRunNodeDTO, 0, len(run.Nodes))
+	for _, node := range run.Nodes {
+		nodeDTOs = append(nodeDTOs, pipelineRunNodeDTO{
+			NodeID:      node.NodeID.String(),
+			NodeType:    imagegraph.NodeTypeMapper.FromWithDefault(node.NodeType, "unknown"),
+			StartedAt:   node.StartedAt,
+			EndedAt:     node.EndedAt,
+			Generations: node.Generations,
+			Failed:      node.Failed,
+		})
+	}
+
+	outputDTOs := make([]pipelineRunOutputDTO, 0, len(run.Outputs))
+	for _, output := range run.Outputs {
+		outputDTOs = append(outputDTOs, pipelineRunOutputDTO{
+			Name:       output.Name,
+			NodeID:     output.NodeID.String(),
+			ImageID:    output.ImageID.String(),
+			DurationMs: float64(output.Duration) / float64(time.Millisecond),
+		})
+	}
+
+	nodes, err := json.Marshal(nodeDTOs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs, err := json.Marshal(outputDTOs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nodes, outputs, nil
+}
+
+func deserializePipelineRun(run *application.PipelineRun, nodes []byte, outputs []byte) error {
+	var nodeDTOs []pipelineRunNodeDTO
+	if err := json.Unmarshal(nodes, &nodeDTOs); err != nil {
+		return fmt.Errorf("failed to unmarshal pipeline run nodes: %w", err)
+	}
+
+	var outputDTOs []pipelineRunOutputDTO
+	if err := json.Unmarshal(outputs, &outputDTOs); err != nil {
+		return fmt.Errorf("failed to unmarshal pipeline run outputs: %w", err)
+	}
+
+	for _, dto := range nodeDTOs {
+		nodeID, err := imagegraph.ParseNodeID(dto.NodeID)
+		if err != nil {
+			return fmt.Errorf("failed to parse node ID %s: %w", dto.NodeID, err)
+		}
+
+		nodeType, err := imagegraph.NodeTypeMapper.To(dto.NodeType)
+		if err != nil {
+			return fmt.Errorf("failed to parse node type %q: %w", dto.NodeType, err)
+		}
+
+		run.Nodes = append(run.Nodes, application.PipelineRunNode{
+			NodeID:      nodeID,
+			NodeType:    nodeType,
+			StartedAt:   dto.StartedAt,
+			EndedAt:     dto.EndedAt,
+			Generations: dto.Generations,
+			Failed:      dto.Failed,
+		})
+	}
+
+	for _, dto := range outputDTOs {
+		nodeID, err := imagegraph.ParseNodeID(dto.NodeID)
+		if err != nil {
+			return fmt.Errorf("failed to parse node ID %s: %w", dto.NodeID, err)
+		}
+
+		imageID, err := imagegraph.ParseImageID(dto.ImageID)
+		if err != nil {
+			return fmt.Errorf("failed to parse image ID %s: %w", dto.ImageID, err)
+		}
+
+		run.Outputs = append(run.Outputs, application.CompletedOutput{
+			Name:     dto.Name,
+			NodeID:   nodeID,
+			ImageID:  imageID,
+			Duration: time.Duration(dto.DurationMs * float64(time.Millisecond)),
+		})
+	}
+
+	return nil
+}
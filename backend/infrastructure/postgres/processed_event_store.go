@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ProcessedEventStore implements application.ProcessedEventStore
+type ProcessedEventStore struct {
+	db *sql.DB
+}
+
+func NewProcessedEventStore(db *sql.DB) *ProcessedEventStore {
+	return &ProcessedEventStore{db: db}
+}
+
+// Processed reports whether the handler already processed the event
+func (s *ProcessedEventStore) Processed(ctx context.Context, handler string, eventID imagegraph.EventID) (bool, error) {
+	var processed bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM processed_events WHERE handler = $1 AND event_id = $2
+		)
+	`, handler, eventID.ID).Scan(&processed)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to query processed event: %w", err)
+	}
+
+	return processed, nil
+}
+
+// MarkProcessed records that the handler processed the event
+func (s *ProcessedEventStore) MarkProcessed(ctx context.Context, handler string, eventID imagegraph.EventID) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO processed_events (handler, event_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, handler, eventID.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert processed event: %w", err)
+	}
+
+	return nil
+}
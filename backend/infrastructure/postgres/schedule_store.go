@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// ScheduleStore implements application.ScheduleStore
+type ScheduleStore struct {
+	db *sql.DB
+}
+
+func NewScheduleStore(db *sql.DB) *ScheduleStore {
+	return &ScheduleStore{db: db}
+}
+
+// Set adds or replaces an ImageGraph's Schedule
+func (s *ScheduleStore) Set(ctx context.Context, schedule application.Schedule) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedules (image_graph_id, cron, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (image_graph_id) DO UPDATE
+		SET cron = EXCLUDED.cron, enabled = EXCLUDED.enabled, updated_at = EXCLUDED.updated_at
+	`, schedule.ImageGraphID.ID, schedule.Cron, schedule.Enabled, schedule.CreatedAt, schedule.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert schedule: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves an ImageGraph's Schedule
+func (s *ScheduleStore) Get(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	application.Schedule,
+	error,
+) {
+	schedule := application.Schedule{ImageGraphID: imageGraphID}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT cron, enabled, created_at, updated_at
+		FROM schedules
+		WHERE image_graph_id = $1
+	`, imageGraphID.ID).Scan(&schedule.Cron, &schedule.Enabled, &schedule.CreatedAt, &schedule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return application.Schedule{}, application.ErrScheduleNotFound
+	}
+	if err != nil {
+		return application.Schedule{}, fmt.Errorf("failed to query schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// Delete removes an ImageGraph's Schedule, keeping the history of its runs
+func (s *ScheduleStore) Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM schedules WHERE image_graph_id = $1
+	`, imageGraphID.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if deleted == 0 {
+		return application.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+// ListEnabled retrieves every enabled Schedule, oldest first
+func (s *ScheduleStore) ListEnabled(ctx context.Context) ([]application.Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT image_graph_id, cron, created_at, updated_at
+		FROM schedules
+		WHERE enabled
+		ORDER BY created_at, image_graph_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []application.Schedule
+	for rows.Next() {
+		schedule := application.Schedule{Enabled: true}
+
+		err := rows.Scan(&schedule.ImageGraphID.ID, &schedule.Cron, &schedule.CreatedAt, &schedule.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// AddRun stores a ScheduledRun, ignoring ImageGraphs that no longer exist
+func (s *ScheduleStore) AddRun(ctx context.Context, run application.ScheduledRun) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO scheduled_runs (image_graph_id, scheduled_for, started_at, status, error)
+		SELECT id, $2, $3, $4, $5 FROM image_graphs WHERE id = $1
+	`, run.ImageGraphID.ID, run.ScheduledFor, run.StartedAt, run.Status, run.Error)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert scheduled run: %w", err)
+	}
+
+	return nil
+}
+
+// ListRuns retrieves the most recent runs of an ImageGraph's Schedule,
+// newest first
+func (s *ScheduleStore) ListRuns(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	limit int,
+) (
+	[]application.ScheduledRun,
+	error,
+) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT scheduled_for, started_at, status, error
+		FROM scheduled_runs
+		WHERE image_graph_id = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2
+	`, imageGraphID.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []application.ScheduledRun
+	for rows.Next() {
+		run := application.ScheduledRun{ImageGraphID: imageGraphID}
+
+		err := rows.Scan(&run.ScheduledFor, &run.StartedAt, &run.Status, &run.Error)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled run: %w", err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate scheduled runs: %w", err)
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// snapshotNodeDTO is the JSON form of a node in a snapshot's nodes column
+type snapshotNodeDTO struct {
+	NodeID  string              `json:"node_id"`
+	Name    string              `json:"name"`
+	Type    string              `json:"type"`
+	Config  json.RawMessage     `json:"config"`
+	Outputs []snapshotOutputDTO `json:"outputs"`
+}
+
+type snapshotOutputDTO struct {
+	Name          string `json:"name"`
+	ImageID       string `json:"image_id,omitempty"`
+	SourceImageID string `json:"source_image_id,omitempty"`
+}
+
+// SnapshotStore implements application.SnapshotStore
+type SnapshotStore struct {
+	db *sql.DB
+}
+
+func NewSnapshotStore(db *sql.DB) *SnapshotStore {
+	return &SnapshotStore{db: db}
+}
+
+// Add stores a Snapshot
+func (s *SnapshotStore) Add(ctx context.Context, snapshot application.Snapshot) error {
+	nodes, err := serializeSnapshotNodes(snapshot.Nodes)
+	if err != nil {
+		return fmt.Errorf("failed to serialize snapshot: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO snapshots (id, image_graph_id, name, created_at, nodes)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (image_graph_id, name) DO NOTHING
+	`, snapshot.ID, snapshot.ImageGraphID.ID, snapshot.Name, snapshot.CreatedAt, nodes)
+	if err != nil {
+		return fmt.Errorf("failed to insert snapshot: %w", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to insert snapshot: %w", err)
+	}
+	if inserted == 0 {
+		return application.ErrSnapshotNameTaken
+	}
+
+	return nil
+}
+
+// Get retrieves a Snapshot of an ImageGraph
+func (s *SnapshotStore) Get(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+	id string,
+) (
+	application.Snapshot,
+	error,
+) {
+	snapshot := application.Snapshot{ID: id, ImageGraphID: imageGraphID}
+
+	var nodes []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT name, created_at, nodes
+		FROM snapshots
+		WHERE id = $1 AND image_graph_id = $2
+	`, id, imageGraphID.ID).Scan(&snapshot.Name, &snapshot.CreatedAt, &nodes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return application.Snapshot{}, application.ErrSnapshotNotFound
+	}
+	if err != nil {
+		return application.Snapshot{}, fmt.Errorf("failed to query snapshot: %w", err)
+	}
+
+	snapshot.Nodes, err = deserializeSnapshotNodes(nodes)
+	if err != nil {
+		return application.Snapshot{}, fmt.Errorf("failed to deserialize snapshot %s: %w", id, err)
+	}
+
+	return snapshot, nil
+}
+
+// ListByImageGraph retrieves the Snapshots of an ImageGraph, oldest first
+func (s *SnapshotStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.Snapshot,
+	error,
+) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, created_at, nodes
+		FROM snapshots
+		WHERE image_graph_id = $1
+		ORDER BY created_at, id
+	`, imageGraphID.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []application.Snapshot
+	for rows.Next() {
+		snapshot := application.Snapshot{ImageGraphID: imageGraphID}
+
+		var nodes []byte
+		if err := rows.Scan(&snapshot.ID, &snapshot.Name, &snapshot.CreatedAt, &nodes); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+
+		snapshot.Nodes, err = deserializeSnapshotNodes(nodes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize snapshot %s: %w", snapshot.ID, err)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+func serializeSnapshotNodes(nodes []application.SnapshotNode) ([]byte, error) {
+	dtos := make([]snapshotNodeDTO, 0, len(nodes))
+
+	for _, node := range nodes {
+		nodeType, err := imagegraph.NodeTypeMapper.From(node.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map node type: %w", err)
+		}
+
+		dto := snapshotNodeDTO{
+			NodeID:  node.NodeID.String(),
+			Name:    node.Name,
+			Type:    nodeType,
+			Config:  node.Config,
+			Outputs: make([]snapshotOutputDTO, 0, len(node.Outputs)),
+		}
+
+		for _, output := range node.Outputs {
+			outputDTO := snapshotOutputDTO{Name: string(output.Name)}
+			if !output.ImageID.IsNil() {
+				outputDTO.ImageID = output.ImageID.String()
+				outputDTO.SourceImageID = output.SourceImageID.String()
+			}
+			dto.Outputs = append(dto.Outputs, outputDTO)
+		}
+
+		dtos = append(dtos, dto)
+	}
+
+	return json.Marshal(dtos)
+}
+
+func deserializeSnapshotNodes(data []byte) ([]application.SnapshotNode, error) {
+	var dtos []snapshotNodeDTO
+	if err := json.Unmarshal(data, &dtos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot nodes: %w", err)
+	}
+
+	nodes := make([]application.SnapshotNode, 0, len(dtos))
+
+	for _, dto := range dtos {
+		nodeID, err := imagegraph.ParseNodeID(dto.NodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node ID %s: %w", dto.NodeID, err)
+		}
+
+		nodeType, err := imagegraph.NodeTypeMapper.To(dto.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node type %q: %w", dto.Type, err)
+		}
+
+		node := application.SnapshotNode{
+			NodeID: nodeID,
+			Name:   dto.Name,
+			Type:   nodeType,
+			Config: dto.Config,
+		}
+
+		for _, outputDTO := range dto.Outputs {
+			output := application.SnapshotOutput{Name: imagegraph.OutputName(outputDTO.Name)}
+
+			if outputDTO.ImageID != "" {
+				output.ImageID, err = imagegraph.ParseImageID(outputDTO.ImageID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse image ID %s: %w", outputDTO.ImageID, err)
+				}
+				output.SourceImageID, err = imagegraph.ParseImageID(outputDTO.SourceImageID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse image ID %s: %w", outputDTO.SourceImageID, err)
+				}
+			}
+
+			node.Outputs = append(node.Outputs, output)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
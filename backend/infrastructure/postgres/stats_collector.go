@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+// StatsCollector implements application.StatsCollector with queries over
+// every ImageGraph, Snapshot and unpublished event
+type StatsCollector struct {
+	db    *sql.DB
+	views *ImageGraphViews
+}
+
+func NewStatsCollector(db *sql.DB) *StatsCollector {
+	return &StatsCollector{db: db, views: NewImageGraphViews(db)}
+}
+
+// CollectGraphStats counts the stored ImageGraphs. Every graph is loaded, so
+// it is meant for occasional monitoring rather than every request.
+func (c *StatsCollector) CollectGraphStats(ctx context.Context) (application.GraphStats, error) {
+	stats := application.NewGraphStats()
+
+	graphs, err := c.views.query(ctx, `
+		SELECT id, name, external_id, owner, public, version, data, created_at, updated_at
+		FROM image_graphs
+	`)
+	if err != nil {
+		return application.GraphStats{}, err
+	}
+
+	for _, ig := range graphs {
+		stats.AddImageGraph(ig)
+	}
+
+	rows, err := c.db.QueryContext(ctx, `SELECT id, nodes FROM snapshots`)
+	if err != nil {
+		return application.GraphStats{}, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snapshot application.Snapshot
+		var nodes []byte
+		if err := rows.Scan(&snapshot.ID, &nodes); err != nil {
+			return application.GraphStats{}, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+
+		snapshot.Nodes, err = deserializeSnapshotNodes(nodes)
+		if err != nil {
+			return application.GraphStats{}, fmt.Errorf("failed to deserialize snapshot %s: %w", snapshot.ID, err)
+		}
+
+		stats.AddSnapshot(snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return application.GraphStats{}, fmt.Errorf("failed to iterate snapshots: %w", err)
+	}
+
+	err = c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM events WHERE published_at IS NULL
+	`).Scan(&stats.QueueDepth)
+	if err != nil {
+		return application.GraphStats{}, fmt.Errorf("failed to count unpublished events: %w", err)
+	}
+
+	return stats, nil
+}
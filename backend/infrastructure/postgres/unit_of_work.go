@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/dmpettyp/dorky/messages"
 
@@ -18,12 +19,31 @@ type repository interface {
 
 // UnitOfWork implements application.UnitOfWork using PostgreSQL
 type UnitOfWork struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox *Outbox
+}
+
+// UnitOfWorkOption configures optional UnitOfWork behavior
+type UnitOfWorkOption func(*UnitOfWork)
+
+// WithOutbox leaves the events the unit of work commits in the outbox for a
+// relay to publish rather than returning them, so they are published even if
+// the process dies right after committing them
+func WithOutbox(outbox *Outbox) UnitOfWorkOption {
+	return func(uow *UnitOfWork) {
+		uow.outbox = outbox
+	}
 }
 
 // NewUnitOfWork creates a new PostgreSQL-based unit of work
-func NewUnitOfWork(db *sql.DB) *UnitOfWork {
-	return &UnitOfWork{db: db}
+func NewUnitOfWork(db *sql.DB, opts ...UnitOfWorkOption) *UnitOfWork {
+	uow := &UnitOfWork{db: db}
+
+	for _, opt := range opts {
+		opt(uow)
+	}
+
+	return uow
 }
 
 // Run executes a function within a transaction boundary
@@ -63,7 +83,7 @@ func (uow *UnitOfWork) Run(
 			events = append(events, repo.CollectEvents()...)
 		}
 
-		if err := saveEvents(ctx, tx, events); err != nil {
+		if err := saveEvents(ctx, tx, events, uow.outbox != nil); err != nil {
 			return fmt.Errorf("failed to save events: %w", err)
 		}
 
@@ -74,17 +94,36 @@ func (uow *UnitOfWork) Run(
 		return nil, err
 	}
 
+	if uow.outbox != nil {
+		if len(events) > 0 {
+			uow.outbox.notify()
+		}
+		return nil, nil
+	}
+
 	return events, nil
 }
 
-func saveEvents(ctx context.Context, tx *sql.Tx, events []messages.Event) error {
+// saveEvents stores events with the aggregates they came from. Events for the
+// outbox are stored unpublished with the context they were committed in;
+// other events are published by the caller and stored as published.
+func saveEvents(ctx context.Context, tx *sql.Tx, events []messages.Event, outbox bool) error {
 	if len(events) == 0 {
 		return nil
 	}
 
+	var publishContext []byte
+	if outbox {
+		var err error
+		publishContext, err = json.Marshal(application.NewOutboxCarrier(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to marshal publish context: %w", err)
+		}
+	}
+
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO events (aggregate_id, aggregate_type, event_type, event_data, aggregate_version, timestamp)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO events (aggregate_id, aggregate_type, event_type, event_data, aggregate_version, timestamp, published_at, publish_context)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare event insert statement: %w", err)
@@ -108,6 +147,11 @@ func saveEvents(ctx context.Context, tx *sql.Tx, events []messages.Event) error
 			aggregateVersion = &version
 		}
 
+		var publishedAt *time.Time
+		if !outbox {
+			publishedAt = &timestamp
+		}
+
 		_, err = stmt.ExecContext(ctx,
 			aggregateID,
 			aggregateType,
@@ -115,6 +159,8 @@ func saveEvents(ctx context.Context, tx *sql.Tx, events []messages.Event) error
 			eventData,
 			aggregateVersion,
 			timestamp,
+			publishedAt,
+			publishContext,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert event: %w", err)
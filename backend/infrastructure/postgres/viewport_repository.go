@@ -14,34 +14,39 @@ import (
 // ViewportRepository implements application.ViewportRepository using PostgreSQL
 type ViewportRepository struct {
 	tx       *sql.Tx
-	modified map[imagegraph.ImageGraphID]*ui.Viewport // Track modified aggregates for event collection
+	modified map[uiStateKey]*ui.Viewport // Track modified aggregates for event collection
 }
 
 // newViewportRepository creates a new repository with initialized maps
 func newViewportRepository(tx *sql.Tx) *ViewportRepository {
 	return &ViewportRepository{
 		tx:       tx,
-		modified: make(map[imagegraph.ImageGraphID]*ui.Viewport),
+		modified: make(map[uiStateKey]*ui.Viewport),
 	}
 }
 
-// Get retrieves a Viewport by graph ID with SELECT FOR UPDATE row locking
-func (r *ViewportRepository) Get(graphID imagegraph.ImageGraphID) (*ui.Viewport, error) {
+// Get retrieves a user's Viewport by graph ID with SELECT FOR UPDATE row locking
+func (r *ViewportRepository) Get(graphID imagegraph.ImageGraphID, userID string) (*ui.Viewport, error) {
 	// Check if already loaded in this transaction (identity map pattern)
-	if viewport, ok := r.modified[graphID]; ok {
+	if viewport, ok := r.modified[uiStateKey{graphID, userID}]; ok {
 		return viewport, nil
 	}
 
 	ctx := context.Background()
 
+	if err := lockImageGraph(ctx, r.tx, graphID); err != nil {
+		return nil, err
+	}
+
 	var row viewportRow
 	err := r.tx.QueryRowContext(ctx, `
-		SELECT graph_id, data, updated_at
+		SELECT graph_id, user_id, data, updated_at
 		FROM viewports
-		WHERE graph_id = $1
+		WHERE graph_id = $1 AND user_id = $2
 		FOR UPDATE
-	`, graphID.ID).Scan(
+	`, graphID.ID, userID).Scan(
 		&row.GraphID,
+		&row.UserID,
 		&row.Data,
 		&row.UpdatedAt,
 	)
@@ -56,7 +61,7 @@ func (r *ViewportRepository) Get(graphID imagegraph.ImageGraphID) (*ui.Viewport,
 	}
 
 	// Track for event collection
-	r.modified[viewport.GraphID] = viewport
+	r.modified[uiStateKey{viewport.GraphID, viewport.UserID}] = viewport
 
 	return viewport, nil
 }
@@ -65,24 +70,28 @@ func (r *ViewportRepository) Get(graphID imagegraph.ImageGraphID) (*ui.Viewport,
 func (r *ViewportRepository) Add(viewport *ui.Viewport) error {
 	ctx := context.Background()
 
+	if err := lockImageGraph(ctx, r.tx, viewport.GraphID); err != nil {
+		return err
+	}
+
 	row, err := serializeViewport(viewport)
 	if err != nil {
 		return fmt.Errorf("failed to serialize viewport: %w", err)
 	}
 
 	_, err = r.tx.ExecContext(ctx, `
-		INSERT INTO viewports (graph_id, data)
-		VALUES ($1, $2)
-		ON CONFLICT (graph_id) DO UPDATE
+		INSERT INTO viewports (graph_id, user_id, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (graph_id, user_id) DO UPDATE
 		SET data = EXCLUDED.data, updated_at = NOW()
-	`, row.GraphID, row.Data)
+	`, row.GraphID, row.UserID, row.Data)
 
 	if err != nil {
 		return fmt.Errorf("failed to insert/update viewport: %w", err)
 	}
 
 	// Track for event collection
-	r.modified[viewport.GraphID] = viewport
+	r.modified[uiStateKey{viewport.GraphID, viewport.UserID}] = viewport
 
 	return nil
 }
@@ -99,9 +108,9 @@ func (r *ViewportRepository) SaveAll() error {
 
 		_, err = r.tx.ExecContext(ctx, `
 			UPDATE viewports
-			SET data = $2, updated_at = NOW()
-			WHERE graph_id = $1
-		`, row.GraphID, row.Data)
+			SET data = $3, updated_at = NOW()
+			WHERE graph_id = $1 AND user_id = $2
+		`, row.GraphID, row.UserID, row.Data)
 
 		if err != nil {
 			return fmt.Errorf("failed to save viewport: %w", err)
@@ -18,15 +18,16 @@ func NewViewportViews(db *sql.DB) *ViewportViews {
 	return &ViewportViews{db: db}
 }
 
-// Get retrieves a Viewport by graph ID (read-only, no locking)
-func (v *ViewportViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID) (*ui.Viewport, error) {
+// Get retrieves a user's Viewport by graph ID (read-only, no locking)
+func (v *ViewportViews) Get(ctx context.Context, graphID imagegraph.ImageGraphID, userID string) (*ui.Viewport, error) {
 	var row viewportRow
 	err := v.db.QueryRowContext(ctx, `
-		SELECT graph_id, data, updated_at
+		SELECT graph_id, user_id, data, updated_at
 		FROM viewports
-		WHERE graph_id = $1
-	`, graphID.ID).Scan(
+		WHERE graph_id = $1 AND user_id = $2
+	`, graphID.ID, userID).Scan(
 		&row.GraphID,
+		&row.UserID,
 		&row.Data,
 		&row.UpdatedAt,
 	)
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// WebhookStore implements application.WebhookStore
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func NewWebhookStore(db *sql.DB) *WebhookStore {
+	return &WebhookStore{db: db}
+}
+
+// Add stores a newly registered Webhook
+func (s *WebhookStore) Add(ctx context.Context, webhook application.Webhook) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhooks (id, image_graph_id, url, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, webhook.ID, webhook.ImageGraphID.ID, webhook.URL, webhook.Secret, webhook.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+
+	return nil
+}
+
+// ListByImageGraph retrieves the Webhooks registered on an ImageGraph,
+// oldest first
+func (s *WebhookStore) ListByImageGraph(
+	ctx context.Context,
+	imageGraphID imagegraph.ImageGraphID,
+) (
+	[]application.Webhook,
+	error,
+) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, url, secret, created_at
+		FROM webhooks
+		WHERE image_graph_id = $1
+		ORDER BY created_at, id
+	`, imageGraphID.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []application.Webhook
+	for rows.Next() {
+		webhook := application.Webhook{ImageGraphID: imageGraphID}
+
+		err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes a Webhook registered on an ImageGraph
+func (s *WebhookStore) Delete(ctx context.Context, imageGraphID imagegraph.ImageGraphID, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM webhooks WHERE id = $1 AND image_graph_id = $2
+	`, id, imageGraphID.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if deleted == 0 {
+		return application.ErrWebhookNotFound
+	}
+
+	return nil
+}
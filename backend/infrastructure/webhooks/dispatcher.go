@@ -0,0 +1,294 @@
+// Package webhooks delivers pipeline completions to the webhooks registered
+// on graphs, signing each delivery and retrying failed ones.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/dmpettyp/artwork/application"
+)
+
+const (
+	// EventHeader names the event a delivery notifies of
+	EventHeader = "X-Artwork-Event"
+
+	// DeliveryHeader identifies a delivery, and is the same on each retry of
+	// it so endpoints can ignore duplicates
+	DeliveryHeader = "X-Artwork-Delivery"
+
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// request body, keyed with the webhook's secret
+	SignatureHeader = "X-Artwork-Signature"
+
+	// PipelineCompletedEvent is sent when every Output node of a graph has
+	// generated its final image
+	PipelineCompletedEvent = "pipeline.completed"
+)
+
+// Payload is the JSON body of a pipeline completion delivery
+type Payload struct {
+	Event        string          `json:"event"`
+	ImageGraphID string          `json:"image_graph_id"`
+	CompletedAt  time.Time       `json:"completed_at"`
+	DurationMS   int64           `json:"duration_ms"`
+	Outputs      []PayloadOutput `json:"outputs"`
+}
+
+// PayloadOutput is an Output node's final image in a Payload
+type PayloadOutput struct {
+	Name       string `json:"name"`
+	NodeID     string `json:"node_id"`
+	ImageID    string `json:"image_id"`
+	ImageURL   string `json:"image_url"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Sign returns the signature of a body, as sent in the SignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// delivery is a payload waiting to be sent to a webhook
+type delivery struct {
+	id      string
+	webhook application.Webhook
+	body    []byte
+}
+
+// Dispatcher implements application.WebhookDispatcher, delivering
+// completions from a queue with a pool of workers
+type Dispatcher struct {
+	logger       *slog.Logger
+	imageBaseURL string
+	client       *http.Client
+	attempts     int
+	backoff      time.Duration
+	workers      int
+
+	queue  chan delivery
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Option configures optional Dispatcher behavior
+type Option func(*Dispatcher)
+
+// WithHTTPClient sends deliveries with the provided client
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithRetries makes up to attempts attempts at each delivery, waiting
+// backoff after the first failure and doubling the wait after each one
+func WithRetries(attempts int, backoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.attempts = max(attempts, 1)
+		d.backoff = backoff
+	}
+}
+
+// WithWorkers sends up to the provided number of deliveries at once
+func WithWorkers(workers int) Option {
+	return func(d *Dispatcher) {
+		d.workers = max(workers, 1)
+	}
+}
+
+// NewDispatcher creates a Dispatcher whose payloads link to images under
+// imageBaseURL, the public URL of the HTTP server
+func NewDispatcher(logger *slog.Logger, imageBaseURL string, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		logger:       logger,
+		imageBaseURL: strings.TrimSuffix(imageBaseURL, "/"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		attempts:     5,
+		backoff:      time.Second,
+		workers:      4,
+		queue:        make(chan delivery, 256),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Start delivers queued completions in the background until Stop is called
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	for range d.workers {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-d.queue:
+					d.deliver(ctx, delivery)
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops delivering, abandoning queued deliveries and retries, and waits
+// for the workers to finish
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.logger.Info("stopping webhook dispatcher")
+
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to stop webhook dispatcher: %w", ctx.Err())
+	}
+}
+
+// Dispatch queues a completion for delivery to each webhook. Deliveries are
+// dropped when the queue is full, so a slow endpoint can't hold up the
+// message bus.
+func (d *Dispatcher) Dispatch(webhooks []application.Webhook, completion application.PipelineCompletion) {
+	body, err := json.Marshal(d.payload(completion))
+	if err != nil {
+		d.logger.Error("failed to encode webhook payload", "error", err, "graph_id", completion.ImageGraphID)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		delivery := delivery{id: uuid.NewString(), webhook: webhook, body: body}
+
+		select {
+		case d.queue <- delivery:
+		default:
+			d.logger.Error(
+				"dropped webhook delivery, queue is full",
+				"graph_id", webhook.ImageGraphID,
+				"webhook_id", webhook.ID,
+			)
+		}
+	}
+}
+
+func (d *Dispatcher) payload(completion application.PipelineCompletion) Payload {
+	payload := Payload{
+		Event:        PipelineCompletedEvent,
+		ImageGraphID: completion.ImageGraphID.String(),
+		CompletedAt:  completion.CompletedAt,
+		DurationMS:   completion.Duration.Milliseconds(),
+		Outputs:      make([]PayloadOutput, 0, len(completion.Outputs)),
+	}
+
+	for _, output := range completion.Outputs {
+		payload.Outputs = append(payload.Outputs, PayloadOutput{
+			Name:       output.Name,
+			NodeID:     output.NodeID.String(),
+			ImageID:    output.ImageID.String(),
+			ImageURL:   d.imageBaseURL + "/api/images/" + output.ImageID.String(),
+			DurationMS: output.Duration.Milliseconds(),
+		})
+	}
+
+	return payload
+}
+
+// deliver sends a delivery, retrying with exponential backoff while it fails
+// in a way that may succeed later
+func (d *Dispatcher) deliver(ctx context.Context, delivery delivery) {
+	wait := d.backoff
+
+	for attempt := 1; ; attempt++ {
+		retry, err := d.send(ctx, delivery)
+		if err == nil {
+			d.logger.Info(
+				"delivered webhook",
+				"graph_id", delivery.webhook.ImageGraphID,
+				"webhook_id", delivery.webhook.ID,
+				"delivery_id", delivery.id,
+				"attempt", attempt,
+			)
+			return
+		}
+
+		if !retry || attempt >= d.attempts {
+			d.logger.Error(
+				"failed to deliver webhook",
+				"error", err,
+				"graph_id", delivery.webhook.ImageGraphID,
+				"webhook_id", delivery.webhook.ID,
+				"delivery_id", delivery.id,
+				"attempts", attempt,
+			)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// send makes one attempt at a delivery, reporting whether a failure is worth
+// retrying
+func (d *Dispatcher) send(ctx context.Context, delivery delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.webhook.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "artwork-webhooks")
+	req.Header.Set(EventHeader, PipelineCompletedEvent)
+	req.Header.Set(DeliveryHeader, delivery.id)
+	req.Header.Set(SignatureHeader, Sign(delivery.webhook.Secret, delivery.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("could not send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests
+
+	return retry, fmt.Errorf("endpoint responded %s", resp.Status)
+}
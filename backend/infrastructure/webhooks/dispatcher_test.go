@@ -0,0 +1,183 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// endpoint records the requests it receives, responding with the statuses
+// in order and then 204
+type endpoint struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []recordedRequest
+	received chan struct{}
+}
+
+func newEndpoint(t *testing.T, statuses ...int) (*endpoint, *httptest.Server) {
+	e := &endpoint{statuses: statuses, received: make(chan struct{}, 16)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		e.mu.Lock()
+		e.requests = append(e.requests, recordedRequest{header: r.Header.Clone(), body: body})
+		status := http.StatusNoContent
+		if len(e.statuses) > 0 {
+			status, e.statuses = e.statuses[0], e.statuses[1:]
+		}
+		e.mu.Unlock()
+
+		w.WriteHeader(status)
+		e.received <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+
+	return e, server
+}
+
+func (e *endpoint) wait(t *testing.T, count int) []recordedRequest {
+	t.Helper()
+
+	for range count {
+		select {
+		case <-e.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %d deliveries", count)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.requests
+}
+
+func newTestDispatcher(t *testing.T, opts ...Option) *Dispatcher {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts = append([]Option{WithRetries(3, time.Millisecond)}, opts...)
+
+	d := NewDispatcher(logger, "https://artwork.example.com/", opts...)
+	d.Start()
+	t.Cleanup(func() {
+		_ = d.Stop(context.Background())
+	})
+
+	return d
+}
+
+func testCompletion() application.PipelineCompletion {
+	return application.PipelineCompletion{
+		ImageGraphID: imagegraph.MustNewImageGraphID(),
+		CompletedAt:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:     1500 * time.Millisecond,
+		Outputs: []application.CompletedOutput{{
+			Name:     "poster",
+			NodeID:   imagegraph.MustNewNodeID(),
+			ImageID:  imagegraph.MustNewImageID(),
+			Duration: 1200 * time.Millisecond,
+		}},
+	}
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	e, server := newEndpoint(t)
+	d := newTestDispatcher(t)
+
+	completion := testCompletion()
+	webhook := application.Webhook{ID: "hook", ImageGraphID: completion.ImageGraphID, URL: server.URL, Secret: "s3cret"}
+
+	d.Dispatch([]application.Webhook{webhook}, completion)
+
+	requests := e.wait(t, 1)
+	req := requests[0]
+
+	if got := req.header.Get(EventHeader); got != PipelineCompletedEvent {
+		t.Errorf("expected event %q, got %q", PipelineCompletedEvent, got)
+	}
+	if req.header.Get(DeliveryHeader) == "" {
+		t.Error("expected a delivery ID")
+	}
+	if got, want := req.header.Get(SignatureHeader), Sign("s3cret", req.body); got != want {
+		t.Errorf("expected signature %q, got %q", want, got)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+
+	if payload.ImageGraphID != completion.ImageGraphID.String() || payload.DurationMS != 1500 {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+	if len(payload.Outputs) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(payload.Outputs))
+	}
+
+	output := payload.Outputs[0]
+	wantURL := "https://artwork.example.com/api/images/" + completion.Outputs[0].ImageID.String()
+	if output.Name != "poster" || output.ImageURL != wantURL || output.DurationMS != 1200 {
+		t.Errorf("unexpected output %+v", output)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	t.Run("retries server errors with the same delivery ID", func(t *testing.T) {
+		e, server := newEndpoint(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		d := newTestDispatcher(t)
+
+		d.Dispatch([]application.Webhook{{ID: "hook", URL: server.URL}}, testCompletion())
+
+		requests := e.wait(t, 3)
+		id := requests[0].header.Get(DeliveryHeader)
+		for _, req := range requests[1:] {
+			if got := req.header.Get(DeliveryHeader); got != id {
+				t.Errorf("expected retries to reuse delivery ID %q, got %q", id, got)
+			}
+		}
+	})
+
+	t.Run("gives up after the configured attempts", func(t *testing.T) {
+		e, server := newEndpoint(t, 500, 500, 500, 500)
+		d := newTestDispatcher(t)
+
+		d.Dispatch([]application.Webhook{{ID: "hook", URL: server.URL}}, testCompletion())
+
+		e.wait(t, 3)
+		select {
+		case <-e.received:
+			t.Error("expected no more than 3 attempts")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("doesn't retry client errors", func(t *testing.T) {
+		e, server := newEndpoint(t, http.StatusGone)
+		d := newTestDispatcher(t)
+
+		d.Dispatch([]application.Webhook{{ID: "hook", URL: server.URL}}, testCompletion())
+
+		e.wait(t, 1)
+		select {
+		case <-e.received:
+			t.Error("expected a 410 not to be retried")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/dmpettyp/artwork/logging"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("adds request ID from context to JSON records", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, err := logging.NewLogger(&buf, "json", slog.LevelInfo)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		ctx := logging.WithRequestID(context.Background(), "req-123")
+		logger.With("node_type", "blur").InfoContext(ctx, "generate_node")
+
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("expected JSON record, got %q: %v", buf.String(), err)
+		}
+		if record["request_id"] != "req-123" {
+			t.Errorf("expected request_id %q, got %v", "req-123", record["request_id"])
+		}
+		if record["node_type"] != "blur" {
+			t.Errorf("expected node_type %q, got %v", "blur", record["node_type"])
+		}
+	})
+
+	t.Run("omits request ID without one in context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger, _ := logging.NewLogger(&buf, "json", slog.LevelInfo)
+
+		logger.InfoContext(context.Background(), "startup")
+
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatalf("expected JSON record, got %q: %v", buf.String(), err)
+		}
+		if _, ok := record["request_id"]; ok {
+			t.Errorf("expected no request_id, got %v", record["request_id"])
+		}
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		if _, err := logging.NewLogger(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+			t.Fatal("expected error for unknown format, got nil")
+		}
+	})
+}
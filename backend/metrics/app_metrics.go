@@ -12,6 +12,7 @@ type AppMetrics struct {
 	HTTP     *HTTPMetrics
 	ImageGen *ImageGenMetrics
 	MessageBus *MessageBusMetrics
+	Propagation *PropagationMetrics
 }
 
 func NewAppMetrics() *AppMetrics {
@@ -20,12 +21,14 @@ func NewAppMetrics() *AppMetrics {
 	httpMetrics := newHTTPMetrics(registry)
 	imageGenMetrics := newImageGenMetrics(registry)
 	messageBusMetrics := newMessageBusMetrics(registry)
+	propagationMetrics := newPropagationMetrics(registry)
 
 	return &AppMetrics{
 		registry: registry,
 		HTTP:     httpMetrics,
 		ImageGen: imageGenMetrics,
 		MessageBus: messageBusMetrics,
+		Propagation: propagationMetrics,
 	}
 }
 
@@ -10,6 +10,7 @@ type ImageGenMetrics struct {
 	previewRequests *prometheus.CounterVec
 	outputRequests  *prometheus.CounterVec
 	duration        *prometheus.HistogramVec
+	decodeCache     *prometheus.CounterVec
 }
 
 func newImageGenMetrics(registry *prometheus.Registry) *ImageGenMetrics {
@@ -35,12 +36,20 @@ func newImageGenMetrics(registry *prometheus.Registry) *ImageGenMetrics {
 		Buckets:   prometheus.DefBuckets,
 	}, []string{"node_type", "status"})
 
-	registry.MustRegister(previewRequests, outputRequests, duration)
+	decodeCache := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "artwork",
+		Subsystem: "imagegen",
+		Name:      "decode_cache_total",
+		Help:      "Total number of image loads, by whether the decoded image was cached.",
+	}, []string{"result"})
+
+	registry.MustRegister(previewRequests, outputRequests, duration, decodeCache)
 
 	return &ImageGenMetrics{
 		previewRequests: previewRequests,
 		outputRequests:  outputRequests,
 		duration:        duration,
+		decodeCache:     decodeCache,
 	}
 }
 
@@ -55,3 +64,7 @@ func (m *ImageGenMetrics) ObserveOutput(nodeType, status string) {
 func (m *ImageGenMetrics) ObserveTotal(nodeType, status string, duration time.Duration) {
 	m.duration.WithLabelValues(nodeType, status).Observe(duration.Seconds())
 }
+
+func (m *ImageGenMetrics) ObserveDecodeCache(result string) {
+	m.decodeCache.WithLabelValues(result).Inc()
+}
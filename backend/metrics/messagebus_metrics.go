@@ -11,6 +11,9 @@ type MessageBusMetrics struct {
 	commandDuration *prometheus.HistogramVec
 	events          *prometheus.CounterVec
 	eventDuration   *prometheus.HistogramVec
+	graphQueued     prometheus.Gauge
+	graphEvents     prometheus.Gauge
+	graphRejected   *prometheus.CounterVec
 }
 
 func newMessageBusMetrics(registry *prometheus.Registry) *MessageBusMetrics {
@@ -44,13 +47,37 @@ func newMessageBusMetrics(registry *prometheus.Registry) *MessageBusMetrics {
 		Buckets:   prometheus.DefBuckets,
 	}, []string{"event", "status"})
 
-	registry.MustRegister(commands, commandDuration, events, eventDuration)
+	graphQueued := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "artwork",
+		Subsystem: "messagebus",
+		Name:      "graph_commands_queued",
+		Help:      "Commands running or waiting in the per-graph command queues.",
+	})
+
+	graphEvents := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "artwork",
+		Subsystem: "messagebus",
+		Name:      "graph_events_queued",
+		Help:      "Events of graphs the message bus has queued to dispatch to handlers.",
+	})
+
+	graphRejected := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "artwork",
+		Subsystem: "messagebus",
+		Name:      "graph_commands_rejected_total",
+		Help:      "Total number of commands rejected because their graph's queue was full.",
+	}, []string{"command"})
+
+	registry.MustRegister(commands, commandDuration, events, eventDuration, graphQueued, graphEvents, graphRejected)
 
 	return &MessageBusMetrics{
 		commands:        commands,
 		commandDuration: commandDuration,
 		events:          events,
 		eventDuration:   eventDuration,
+		graphQueued:     graphQueued,
+		graphEvents:     graphEvents,
+		graphRejected:   graphRejected,
 	}
 }
 
@@ -63,3 +90,15 @@ func (m *MessageBusMetrics) ObserveEvent(eventType string, status string, durati
 	m.events.WithLabelValues(eventType, status).Inc()
 	m.eventDuration.WithLabelValues(eventType, status).Observe(duration.Seconds())
 }
+
+func (m *MessageBusMetrics) ObserveGraphCommandsQueued(queued int) {
+	m.graphQueued.Set(float64(queued))
+}
+
+func (m *MessageBusMetrics) ObserveGraphEventsQueued(queued int) {
+	m.graphEvents.Set(float64(queued))
+}
+
+func (m *MessageBusMetrics) ObserveGraphCommandRejected(commandType string) {
+	m.graphRejected.WithLabelValues(commandType).Inc()
+}
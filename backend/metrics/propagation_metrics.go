@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type PropagationMetrics struct {
+	latency *prometheus.HistogramVec
+}
+
+func newPropagationMetrics(registry *prometheus.Registry) *PropagationMetrics {
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "artwork",
+		Subsystem: "propagation",
+		Name:      "latency_seconds",
+		Help:      "Time from an upstream output being set to a downstream input receiving it (stage=input) or the downstream node regenerating (stage=regeneration).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"stage"})
+
+	registry.MustRegister(latency)
+
+	return &PropagationMetrics{
+		latency: latency,
+	}
+}
+
+func (m *PropagationMetrics) ObserveInputLatency(duration time.Duration) {
+	m.latency.WithLabelValues("input").Observe(duration.Seconds())
+}
+
+func (m *PropagationMetrics) ObserveRegenerationLatency(duration time.Duration) {
+	m.latency.WithLabelValues("regeneration").Observe(duration.Seconds())
+}
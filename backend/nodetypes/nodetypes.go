@@ -0,0 +1,162 @@
+// Package nodetypes reads registries of simple node types, declared with
+// their inputs, outputs and config fields and generating their outputs with
+// a built-in operation or an external processor, and registers them at
+// startup so that adding one doesn't require any code.
+package nodetypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/infrastructure/imagegen"
+)
+
+// DefaultCategory is the UI category of node types that don't name one
+const DefaultCategory = "Custom"
+
+// Definition declares a node type in a registry file
+type Definition struct {
+	// Name is the node type's API name, such as "invert"
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Category    string `json:"category"`
+	// Deprecated, if set, says what to use instead of the node type
+	Deprecated string `json:"deprecated"`
+
+	// Inputs are listed primary input first, and OptionalInputs are the
+	// ones a node generates without
+	Inputs         []imagegraph.InputName  `json:"inputs"`
+	OptionalInputs []imagegraph.InputName  `json:"optional_inputs"`
+	Outputs        []imagegraph.OutputName `json:"outputs"`
+	PreservesSize  bool                    `json:"preserves_size"`
+
+	Config []imagegraph.FieldSchema `json:"config"`
+
+	// Exactly one of Operation and Processor is set
+	Operation *Operation `json:"operation"`
+	Processor *Processor `json:"processor"`
+}
+
+// Operation names a built-in operation and its fixed parameters
+type Operation struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params"`
+}
+
+// Processor is an external service that generates a node's outputs
+type Processor struct {
+	URL string `json:"url"`
+}
+
+// Registered is a node type that was registered from its Definition
+type Registered struct {
+	NodeType   imagegraph.NodeType
+	Definition Definition
+}
+
+// Load reads the node type definitions of a JSON registry file of the form
+// {"node_types": [Definition, ...]}
+func Load(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node types file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse reads the node type definitions of a JSON registry, rejecting
+// fields it doesn't know so that typos aren't silently ignored
+func Parse(data []byte) ([]Definition, error) {
+	var file struct {
+		NodeTypes []Definition `json:"node_types"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse node types file: %w", err)
+	}
+
+	for _, def := range file.NodeTypes {
+		if err := def.validate(); err != nil {
+			return nil, fmt.Errorf("node type %q: %w", def.Name, err)
+		}
+	}
+
+	return file.NodeTypes, nil
+}
+
+func (d Definition) validate() error {
+	if (d.Operation == nil) == (d.Processor == nil) {
+		return fmt.Errorf("exactly one of operation and processor is required")
+	}
+
+	if d.Operation != nil {
+		if !slices.Contains(imagegen.BuiltinOperations(), d.Operation.Name) {
+			return fmt.Errorf("operation must be one of %v, got %q", imagegen.BuiltinOperations(), d.Operation.Name)
+		}
+		// Built-in operations transform the primary input
+		if len(d.Inputs) == 0 || slices.Contains(d.OptionalInputs, d.Inputs[0]) {
+			return fmt.Errorf("operation %s needs a primary input that isn't optional", d.Operation.Name)
+		}
+	}
+
+	if d.Processor != nil {
+		u, err := url.Parse(d.Processor.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("processor url must be an http or https URL, got %q", d.Processor.URL)
+		}
+	}
+
+	return nil
+}
+
+// Register registers the node types of a registry with the application, in
+// order. It must be called at startup, before any graph is loaded.
+func Register(defs []Definition) ([]Registered, error) {
+	registered := make([]Registered, 0, len(defs))
+
+	for _, def := range defs {
+		if def.DisplayName == "" {
+			def.DisplayName = def.Name
+		}
+		if def.Category == "" {
+			def.Category = DefaultCategory
+		}
+
+		var operation imagegen.NodeOperation
+		if def.Operation != nil {
+			operation.Builtin = def.Operation.Name
+			operation.Params = def.Operation.Params
+		} else {
+			operation.ProcessorURL = def.Processor.URL
+		}
+
+		nodeType, err := application.RegisterNodeType(
+			def.Name,
+			imagegraph.NodeTypeDef{
+				Inputs:         def.Inputs,
+				OptionalInputs: def.OptionalInputs,
+				Outputs:        def.Outputs,
+				PreservesSize:  def.PreservesSize,
+				Deprecated:     def.Deprecated,
+			},
+			def.Config,
+			operation,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		registered = append(registered, Registered{NodeType: nodeType, Definition: def})
+	}
+
+	return registered, nil
+}
@@ -0,0 +1,137 @@
+package nodetypes_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+	"github.com/dmpettyp/artwork/nodetypes"
+)
+
+const registry = `{
+	"node_types": [
+		{
+			"name": "soften",
+			"display_name": "Soften",
+			"inputs": ["original"],
+			"outputs": ["softened"],
+			"preserves_size": true,
+			"config": [
+				{"name": "radius", "type": "float", "default": 3},
+				{"name": "linear", "type": "bool"}
+			],
+			"operation": {"name": "blur"}
+		},
+		{
+			"name": "stylize",
+			"category": "Generate",
+			"inputs": ["content", "style"],
+			"optional_inputs": ["style"],
+			"outputs": ["stylized"],
+			"config": [
+				{"name": "strength", "type": "option", "options": ["low", "high"], "required": true}
+			],
+			"processor": {"url": "http://localhost:9000/stylize"}
+		}
+	]
+}`
+
+func TestRegister(t *testing.T) {
+	defs, err := nodetypes.Parse([]byte(registry))
+	if err != nil {
+		t.Fatalf("failed to parse registry: %v", err)
+	}
+
+	registered, err := nodetypes.Register(defs)
+	if err != nil {
+		t.Fatalf("failed to register node types: %v", err)
+	}
+	if len(registered) != 2 {
+		t.Fatalf("expected 2 registered node types, got %d", len(registered))
+	}
+
+	if got := registered[1].Definition; got.DisplayName != "stylize" || got.Category != "Generate" {
+		t.Errorf("expected the name as display name and the given category, got %q and %q", got.DisplayName, got.Category)
+	}
+
+	t.Run("maps names to registered node types", func(t *testing.T) {
+		for _, r := range registered {
+			nodeType, err := imagegraph.NodeTypeMapper.To(r.Definition.Name)
+			if err != nil || nodeType != r.NodeType {
+				t.Errorf("expected %s to map to %d, got %d, %v", r.Definition.Name, r.NodeType, nodeType, err)
+			}
+		}
+	})
+
+	t.Run("configures nodes with declared fields", func(t *testing.T) {
+		config := imagegraph.NewNodeConfig(registered[0].NodeType)
+		if config == nil {
+			t.Fatalf("expected a config for the registered node type")
+		}
+
+		data, err := json.Marshal(config)
+		if err != nil {
+			t.Fatalf("failed to marshal config: %v", err)
+		}
+		if string(data) != `{"radius":3}` {
+			t.Errorf("expected the defaults, got %s", data)
+		}
+
+		if err := json.Unmarshal([]byte(`{"linear": true}`), config); err != nil {
+			t.Fatalf("failed to unmarshal config: %v", err)
+		}
+		if err := config.Validate(); err != nil {
+			t.Errorf("expected a valid config, got %v", err)
+		}
+
+		values := config.(*imagegraph.NodeConfigDeclared).Values
+		if values["radius"] != 3.0 || values["linear"] != true {
+			t.Errorf("expected the default radius and the set linear, got %v", values)
+		}
+	})
+
+	t.Run("validates configs against declared fields", func(t *testing.T) {
+		tests := map[string]string{
+			"missing required field": `{}`,
+			"unlisted option":        `{"strength": "medium"}`,
+			"unknown field":          `{"strength": "low", "radius": 2}`,
+		}
+
+		for name, raw := range tests {
+			config := imagegraph.NewNodeConfig(registered[1].NodeType)
+			if err := json.Unmarshal([]byte(raw), config); err != nil {
+				t.Fatalf("%s: failed to unmarshal config: %v", name, err)
+			}
+			if err := config.Validate(); err == nil {
+				t.Errorf("%s: expected %s to be invalid", name, raw)
+			}
+		}
+	})
+
+	t.Run("rejects node types that already exist", func(t *testing.T) {
+		_, err := nodetypes.Register(defs[:1])
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("expected the node type to already exist, got %v", err)
+		}
+	})
+}
+
+func TestParseRejectsInvalidDefinitions(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":           `{"node_types": [{"name": "a", "inputs": ["x"], "outputs": ["y"], "operation": {"name": "blur"}, "colour": "red"}]}`,
+		"no operation":            `{"node_types": [{"name": "a", "inputs": ["x"], "outputs": ["y"]}]}`,
+		"operation and processor": `{"node_types": [{"name": "a", "inputs": ["x"], "outputs": ["y"], "operation": {"name": "blur"}, "processor": {"url": "http://p"}}]}`,
+		"unknown operation":       `{"node_types": [{"name": "a", "inputs": ["x"], "outputs": ["y"], "operation": {"name": "melt"}}]}`,
+		"operation without input": `{"node_types": [{"name": "a", "outputs": ["y"], "operation": {"name": "invert"}}]}`,
+		"processor without url":   `{"node_types": [{"name": "a", "outputs": ["y"], "processor": {}}]}`,
+	}
+
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := nodetypes.Parse([]byte(raw)); err == nil {
+				t.Errorf("expected %s to be rejected", raw)
+			}
+		})
+	}
+}
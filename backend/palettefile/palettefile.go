@@ -0,0 +1,338 @@
+// Package palettefile reads and writes palettes in the file formats of
+// other paint programs: GIMP .gpl, Adobe .aco and plain hex lists.
+package palettefile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// MaxColors is the most colors a palette file can hold
+const MaxColors = 1024
+
+// Format is a palette file format
+type Format string
+
+const (
+	// FormatGPL is a GIMP palette: a text header followed by a line of
+	// decimal red, green and blue values per color
+	FormatGPL Format = "gpl"
+	// FormatACO is an Adobe color swatch file: big-endian RGB values, in a
+	// version 1 section followed by a version 2 section with names
+	FormatACO Format = "aco"
+	// FormatHex is a line of #rrggbb per color
+	FormatHex Format = "hex"
+)
+
+// Formats lists the supported formats
+var Formats = []Format{FormatGPL, FormatACO, FormatHex}
+
+// ErrInvalidPalette is returned when a palette file can't be read
+var ErrInvalidPalette = errors.New("invalid palette file")
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == strings.ToLower(name) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("palette format must be one of %v, got %q", Formats, name)
+}
+
+// DetectFormat guesses the format of a palette file from its file name,
+// and failing that from its contents
+func DetectFormat(filename string, data []byte) Format {
+	if format, err := ParseFormat(strings.TrimPrefix(path.Ext(filename), ".")); err == nil {
+		return format
+	}
+	if bytes.HasPrefix(data, []byte("GIMP Palette")) {
+		return FormatGPL
+	}
+	if len(data) >= 2 && data[0] == 0 && (data[1] == 1 || data[1] == 2) {
+		return FormatACO
+	}
+	return FormatHex
+}
+
+// ContentType is the media type palette files of the format are served as
+func (f Format) ContentType() string {
+	if f == FormatACO {
+		return "application/octet-stream"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Palette is a named, ordered list of colors
+type Palette struct {
+	Name   string
+	Colors []color.RGBA
+}
+
+// ColorsFromImage reads the colors stored in a palette image: its opaque
+// pixels in row order, each color once
+func ColorsFromImage(img image.Image) []color.RGBA {
+	var colors []color.RGBA
+	seen := make(map[color.RGBA]bool)
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			c := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255}
+			if !seen[c] {
+				seen[c] = true
+				colors = append(colors, c)
+			}
+		}
+	}
+
+	return colors
+}
+
+// Hex returns the palette's colors as #rrggbb
+func (p Palette) Hex() []string {
+	hex := make([]string, len(p.Colors))
+	for i, c := range p.Colors {
+		hex[i] = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return hex
+}
+
+// Encode writes the palette in the given format
+func Encode(w io.Writer, format Format, p Palette) error {
+	switch format {
+	case FormatGPL:
+		return encodeGPL(w, p)
+	case FormatACO:
+		return encodeACO(w, p)
+	case FormatHex:
+		return encodeHex(w, p)
+	}
+	return fmt.Errorf("unknown palette format %q", format)
+}
+
+// Decode reads a palette in the given format. Palettes must have between 1
+// and MaxColors colors.
+func Decode(data []byte, format Format) (Palette, error) {
+	var p Palette
+	var err error
+
+	switch format {
+	case FormatGPL:
+		p, err = decodeGPL(data)
+	case FormatACO:
+		p, err = decodeACO(data)
+	case FormatHex:
+		p, err = decodeHex(data)
+	default:
+		return Palette{}, fmt.Errorf("unknown palette format %q", format)
+	}
+	if err != nil {
+		return Palette{}, fmt.Errorf("%w: %s: %v", ErrInvalidPalette, format, err)
+	}
+
+	if len(p.Colors) == 0 {
+		return Palette{}, fmt.Errorf("%w: %s: no colors", ErrInvalidPalette, format)
+	}
+	if len(p.Colors) > MaxColors {
+		return Palette{}, fmt.Errorf("%w: %s: more than %d colors", ErrInvalidPalette, format, MaxColors)
+	}
+
+	return p, nil
+}
+
+func encodeGPL(w io.Writer, p Palette) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "GIMP Palette")
+	if name := strings.TrimSpace(p.Name); name != "" {
+		fmt.Fprintf(bw, "Name: %s\n", strings.ReplaceAll(name, "\n", " "))
+	}
+	fmt.Fprintln(bw, "Columns: 0")
+	fmt.Fprintln(bw, "#")
+	hex := p.Hex()
+	for i, c := range p.Colors {
+		fmt.Fprintf(bw, "%3d %3d %3d\t%s\n", c.R, c.G, c.B, hex[i])
+	}
+
+	return bw.Flush()
+}
+
+func decodeGPL(data []byte) (Palette, error) {
+	var p Palette
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		return Palette{}, errors.New(`missing "GIMP Palette" header`)
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case text == "" || strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "Name:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(text, "Name:"))
+			continue
+		case strings.HasPrefix(text, "Columns:"):
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return Palette{}, fmt.Errorf("line %d: expected red, green and blue values", line)
+		}
+
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return Palette{}, fmt.Errorf("line %d: %q is not a value from 0 to 255", line, fields[i])
+			}
+			rgb[i] = uint8(v)
+		}
+		p.Colors = append(p.Colors, color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255})
+	}
+
+	return p, scanner.Err()
+}
+
+// acoColorSpaceRGB is the color space of RGB colors in ACO files
+const acoColorSpaceRGB = 0
+
+func encodeACO(w io.Writer, p Palette) error {
+	var buf bytes.Buffer
+	put := func(v any) { binary.Write(&buf, binary.BigEndian, v) }
+
+	hex := p.Hex()
+
+	for _, version := range []uint16{1, 2} {
+		put(version)
+		put(uint16(len(p.Colors)))
+
+		for i, c := range p.Colors {
+			// Channels are 16 bit, 257 maps 255 to 65535
+			put([5]uint16{acoColorSpaceRGB, uint16(c.R) * 257, uint16(c.G) * 257, uint16(c.B) * 257, 0})
+
+			if version == 2 {
+				name := utf16.Encode([]rune(hex[i]))
+				put(uint32(len(name) + 1))
+				put(name)
+				put(uint16(0))
+			}
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func decodeACO(data []byte) (Palette, error) {
+	r := bytes.NewReader(data)
+	read := func(v any) error { return binary.Read(r, binary.BigEndian, v) }
+
+	var p Palette
+
+	for section := 0; section < 2; section++ {
+		var version, count uint16
+		if err := read(&version); err != nil {
+			if section > 0 && errors.Is(err, io.EOF) {
+				break
+			}
+			return Palette{}, errors.New("missing header")
+		}
+		if version != 1 && version != 2 {
+			return Palette{}, fmt.Errorf("unknown version %d", version)
+		}
+		if err := read(&count); err != nil {
+			return Palette{}, errors.New("missing color count")
+		}
+		if int(count) > MaxColors {
+			return Palette{}, fmt.Errorf("more than %d colors", MaxColors)
+		}
+
+		// The version 2 section repeats the colors of version 1 with names,
+		// so the last section read wins
+		colors := make([]color.RGBA, 0, count)
+		for i := range int(count) {
+			var values [5]uint16
+			if err := read(&values); err != nil {
+				return Palette{}, fmt.Errorf("color %d is truncated", i+1)
+			}
+			if values[0] != acoColorSpaceRGB {
+				return Palette{}, fmt.Errorf("color %d is not RGB", i+1)
+			}
+			colors = append(colors, color.RGBA{
+				R: uint8(values[1] >> 8),
+				G: uint8(values[2] >> 8),
+				B: uint8(values[3] >> 8),
+				A: 255,
+			})
+
+			if version == 2 {
+				var length uint32
+				if err := read(&length); err != nil || int64(length)*2 > int64(r.Len()) {
+					return Palette{}, fmt.Errorf("name of color %d is truncated", i+1)
+				}
+				if _, err := r.Seek(int64(length)*2, io.SeekCurrent); err != nil {
+					return Palette{}, fmt.Errorf("name of color %d is truncated", i+1)
+				}
+			}
+		}
+		p.Colors = colors
+	}
+
+	return p, nil
+}
+
+func encodeHex(w io.Writer, p Palette) error {
+	bw := bufio.NewWriter(w)
+	for _, hex := range p.Hex() {
+		fmt.Fprintln(bw, hex)
+	}
+	return bw.Flush()
+}
+
+// decodeHex reads colors written as rrggbb, with or without a leading #,
+// separated by whitespace or commas. Lines starting with ; or // are
+// comments.
+func decodeHex(data []byte) (Palette, error) {
+	var p Palette
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, ";") || strings.HasPrefix(text, "//") {
+			continue
+		}
+
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		for _, field := range fields {
+			hex := strings.TrimPrefix(field, "#")
+			v, err := strconv.ParseUint(hex, 16, 32)
+			if len(hex) != 6 || err != nil {
+				return Palette{}, fmt.Errorf("line %d: %q is not a color like #rrggbb", line, field)
+			}
+			p.Colors = append(p.Colors, color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255})
+		}
+	}
+
+	return p, scanner.Err()
+}
@@ -0,0 +1,146 @@
+package palettefile_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dmpettyp/artwork/palettefile"
+)
+
+func testPalette() palettefile.Palette {
+	return palettefile.Palette{
+		Name: "Sunset",
+		Colors: []color.RGBA{
+			{R: 0xff, G: 0x66, B: 0x00, A: 255},
+			{R: 0x33, G: 0x00, B: 0x66, A: 255},
+			{R: 0x00, G: 0x00, B: 0x00, A: 255},
+			{R: 0xff, G: 0xff, B: 0xff, A: 255},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range palettefile.Formats {
+		t.Run(string(format), func(t *testing.T) {
+			want := testPalette()
+
+			var buf bytes.Buffer
+			if err := palettefile.Encode(&buf, format, want); err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+
+			if detected := palettefile.DetectFormat("", buf.Bytes()); detected != format {
+				t.Errorf("expected the contents to be detected as %s, got %s", format, detected)
+			}
+
+			got, err := palettefile.Decode(buf.Bytes(), format)
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if !slices.Equal(got.Colors, want.Colors) {
+				t.Errorf("expected colors %v, got %v", want.Hex(), got.Hex())
+			}
+			if format == palettefile.FormatGPL && got.Name != want.Name {
+				t.Errorf("expected name %q, got %q", want.Name, got.Name)
+			}
+		})
+	}
+}
+
+func TestDecodeGPL(t *testing.T) {
+	data := "GIMP Palette\nName: Pastels\nColumns: 4\n#\n# a comment\n255 192 203\tPink\n  0 128   0 Green\n\n"
+
+	p, err := palettefile.Decode([]byte(data), palettefile.FormatGPL)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if p.Name != "Pastels" {
+		t.Errorf("expected name Pastels, got %q", p.Name)
+	}
+	if want := []string{"#ffc0cb", "#008000"}; !slices.Equal(p.Hex(), want) {
+		t.Errorf("expected %v, got %v", want, p.Hex())
+	}
+}
+
+func TestDecodeHex(t *testing.T) {
+	data := "; exported from somewhere\nFF0000, #00ff00\n// blue\n0000ff\n"
+
+	p, err := palettefile.Decode([]byte(data), palettefile.FormatHex)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if want := []string{"#ff0000", "#00ff00", "#0000ff"}; !slices.Equal(p.Hex(), want) {
+		t.Errorf("expected %v, got %v", want, p.Hex())
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	var aco bytes.Buffer
+	if err := palettefile.Encode(&aco, palettefile.FormatACO, testPalette()); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		format palettefile.Format
+		data   []byte
+	}{
+		{"gpl without header", palettefile.FormatGPL, []byte("255 0 0\n")},
+		{"gpl value out of range", palettefile.FormatGPL, []byte("GIMP Palette\n256 0 0\n")},
+		{"gpl missing channel", palettefile.FormatGPL, []byte("GIMP Palette\n255 0\n")},
+		{"gpl without colors", palettefile.FormatGPL, []byte("GIMP Palette\nName: Empty\n")},
+		{"hex short color", palettefile.FormatHex, []byte("#fff\n")},
+		{"hex not a color", palettefile.FormatHex, []byte("#gg0000\n")},
+		{"hex empty", palettefile.FormatHex, []byte("\n")},
+		{"hex too many colors", palettefile.FormatHex, []byte(strings.Repeat("#000000\n", palettefile.MaxColors+1))},
+		{"aco truncated", palettefile.FormatACO, aco.Bytes()[:12]},
+		{"aco unknown version", palettefile.FormatACO, []byte{0, 9, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := palettefile.Decode(tt.data, tt.format); !errors.Is(err, palettefile.ErrInvalidPalette) {
+				t.Errorf("expected ErrInvalidPalette, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     palettefile.Format
+	}{
+		{"colors.GPL", "", palettefile.FormatGPL},
+		{"swatches.aco", "", palettefile.FormatACO},
+		{"list.hex", "", palettefile.FormatHex},
+		{"palette.txt", "GIMP Palette\n", palettefile.FormatGPL},
+		{"palette.txt", "#ff0000\n", palettefile.FormatHex},
+	}
+
+	for _, tt := range tests {
+		if got := palettefile.DetectFormat(tt.filename, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectFormat(%q) = %s, want %s", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestColorsFromImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{G: 255, A: 255})
+	img.Set(0, 1, color.RGBA{R: 255, A: 255})
+	// The last pixel is transparent padding
+
+	got := palettefile.ColorsFromImage(img)
+	want := []color.RGBA{{R: 255, A: 255}, {G: 255, A: 255}}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dmpettyp/dorky/messages"
+
+	"github.com/dmpettyp/artwork/application"
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// CommandHandler handles application commands, as the message bus does
+type CommandHandler interface {
+	HandleCommand(ctx context.Context, command messages.Command) error
+}
+
+// Build creates the spec's graph in the application, returning the IDs of
+// its nodes by name. The spec's images aren't set; callers store them and
+// set them once the graph is built.
+func Build(
+	ctx context.Context,
+	commands CommandHandler,
+	spec *Spec,
+	graphID imagegraph.ImageGraphID,
+	name string,
+	owner string,
+) (
+	map[string]imagegraph.NodeID,
+	error,
+) {
+	err := commands.HandleCommand(ctx, application.NewCreateImageGraphCommand(graphID, name, "", owner))
+	if err != nil {
+		return nil, fmt.Errorf("could not create graph %q: %w", name, err)
+	}
+
+	for _, tag := range spec.Tags {
+		if err := commands.HandleCommand(ctx, application.NewAddImageGraphTagCommand(graphID, tag)); err != nil {
+			return nil, fmt.Errorf("could not tag graph with %q: %w", tag, err)
+		}
+	}
+
+	if len(spec.Parameters) > 0 {
+		command := application.NewSetImageGraphParametersCommand(graphID, spec.Parameters)
+		if err := commands.HandleCommand(ctx, command); err != nil {
+			return nil, fmt.Errorf("could not set graph parameters: %w", err)
+		}
+	}
+
+	if spec.Seed != nil {
+		command := application.NewSetImageGraphSeedCommand(graphID, spec.Seed)
+		if err := commands.HandleCommand(ctx, command); err != nil {
+			return nil, fmt.Errorf("could not set graph seed: %w", err)
+		}
+	}
+
+	nodeIDs := make(map[string]imagegraph.NodeID)
+
+	for _, node := range spec.Nodes {
+		nodeType, config, err := node.Parse()
+		if err != nil {
+			return nil, err
+		}
+
+		nodeID := imagegraph.MustNewNodeID()
+		nodeIDs[node.Name] = nodeID
+
+		command := application.NewAddImageGraphNodeCommand(graphID, nodeID, nodeType, node.Name, config, node.ExternalID)
+		if err := commands.HandleCommand(ctx, command); err != nil {
+			return nil, fmt.Errorf("could not add node %q: %w", node.Name, err)
+		}
+
+		if node.Description != "" {
+			command := application.NewSetImageGraphNodeDescriptionCommand(graphID, nodeID, node.Description)
+			if err := commands.HandleCommand(ctx, command); err != nil {
+				return nil, fmt.Errorf("could not describe node %q: %w", node.Name, err)
+			}
+		}
+
+		if len(node.Expressions) > 0 {
+			command := application.NewSetImageGraphNodeExpressionsCommand(graphID, nodeID, node.Expressions)
+			if err := commands.HandleCommand(ctx, command); err != nil {
+				return nil, fmt.Errorf("could not set expressions of node %q: %w", node.Name, err)
+			}
+		}
+	}
+
+	for _, connection := range spec.Connections {
+		fromNode, output, _ := SplitPort(connection.From)
+		toNode, input, _ := SplitPort(connection.To)
+
+		command := application.NewConnectImageGraphNodesCommand(
+			graphID,
+			nodeIDs[fromNode],
+			imagegraph.OutputName(output),
+			nodeIDs[toNode],
+			imagegraph.InputName(input),
+		)
+		command.Transform = connection.Transform
+		if err := commands.HandleCommand(ctx, command); err != nil {
+			return nil, fmt.Errorf("could not connect %s to %s: %w", connection.From, connection.To, err)
+		}
+	}
+
+	return nodeIDs, nil
+}
+
+// Parse resolves the node's type and decodes and validates its config
+func (n Node) Parse() (imagegraph.NodeType, imagegraph.NodeConfig, error) {
+	nodeType, err := imagegraph.NodeTypeMapper.To(n.Type)
+	if err != nil {
+		return nodeType, nil, fmt.Errorf("node %q: unknown type %q", n.Name, n.Type)
+	}
+
+	config := imagegraph.NewNodeConfig(nodeType)
+	if config == nil {
+		return nodeType, nil, fmt.Errorf("node %q: type %q can't be added", n.Name, n.Type)
+	}
+
+	if len(n.Config) > 0 {
+		if err := json.Unmarshal(n.Config, config); err != nil {
+			return nodeType, nil, fmt.Errorf("node %q: invalid config: %w", n.Name, err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nodeType, nil, fmt.Errorf("node %q: invalid config: %w", n.Name, err)
+	}
+
+	return nodeType, config, nil
+}
+
+// InputNode picks the input node that receives images fed to the spec's
+// graph: the named one or, when name is empty, the spec's only input node
+// without an image of its own
+func (spec *Spec) InputNode(name string) (string, error) {
+	var candidates []string
+
+	for _, node := range spec.Nodes {
+		nodeType, _ := imagegraph.NodeTypeMapper.To(node.Type)
+		if nodeType != imagegraph.NodeTypeInput {
+			continue
+		}
+
+		if name != "" && node.Name == name {
+			return name, nil
+		}
+		if node.Image == "" {
+			candidates = append(candidates, node.Name)
+		}
+	}
+
+	if name != "" {
+		return "", fmt.Errorf("pipeline spec has no input node %q", name)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", errors.New("pipeline spec has no input node without an image")
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", fmt.Errorf("pipeline spec has %d input nodes without images; name the one to use", len(candidates))
+	}
+}
+
+// CheckNodes parses every node of the spec, so that a spec with unknown
+// types or invalid configs is rejected before any graph is built
+func (spec *Spec) CheckNodes() error {
+	for _, node := range spec.Nodes {
+		if _, _, err := node.Parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
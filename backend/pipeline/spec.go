@@ -0,0 +1,171 @@
+// Package pipeline loads pipeline specs: image graph definitions written in
+// YAML or JSON that artworkctl builds on a server and artwork's batch mode
+// runs locally.
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Spec describes an image graph to build: its nodes, the images to upload
+// to them and how they're connected
+//
+//	name: poster
+//	nodes:
+//	  - name: photo
+//	    type: input
+//	    image: photo.png
+//	  - name: small
+//	    type: resize
+//	    config:
+//	      width: 800
+//	  - name: poster
+//	    type: output
+//	connections:
+//	  - from: photo.original
+//	    to: small.original
+//	  - from: small.resized
+//	    to: poster.input
+type Spec struct {
+	Name        string       `json:"name"`
+	ExternalID  string       `json:"external_id"`
+	Tags        []string     `json:"tags"`
+	Nodes       []Node       `json:"nodes"`
+	Connections []Connection `json:"connections"`
+
+	// Parameters are set on the graph for node expressions to refer to
+	Parameters map[string]float64 `json:"parameters"`
+
+	// Seed is set on the graph so its stochastic nodes generate the same
+	// outputs on every run
+	Seed *int `json:"seed"`
+}
+
+type Node struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Description string          `json:"description"`
+	ExternalID  string          `json:"external_id"`
+	Config      json.RawMessage `json:"config"`
+
+	// Expressions compute config fields from the sizes of the node's input
+	// images and the graph's parameters, e.g. width: "input.width / 2"
+	Expressions map[string]string `json:"expressions"`
+
+	// Image is uploaded to the node's output, "original" unless Output
+	// names another. Relative paths are resolved against the spec's
+	// directory.
+	Image  string `json:"image"`
+	Output string `json:"output"`
+}
+
+// ImageOutput is the output the node's image is uploaded to
+func (n Node) ImageOutput() string {
+	if n.Output == "" {
+		return "original"
+	}
+	return n.Output
+}
+
+// Connection connects "node.output" to "node.input", by node name,
+// optionally transforming the images passed along it, e.g. "invert"
+type Connection struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Transform string `json:"transform"`
+}
+
+// Load reads a spec from a YAML or JSON file
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pipeline spec: %w", err)
+	}
+
+	// JSON specs are decoded directly; YAML specs are converted to JSON so
+	// both share the spec's JSON tags
+	if filepath.Ext(path) != ".json" {
+		doc, err := parseYAML(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse pipeline spec %s: %w", path, err)
+		}
+		data, err = json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse pipeline spec %s: %w", path, err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var spec Spec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid pipeline spec %s: %w", path, err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pipeline spec %s: %w", path, err)
+	}
+
+	// Resolve image paths now so applying doesn't depend on the working
+	// directory
+	dir := filepath.Dir(path)
+	for i, node := range spec.Nodes {
+		if node.Image != "" && !filepath.IsAbs(node.Image) {
+			spec.Nodes[i].Image = filepath.Join(dir, node.Image)
+		}
+	}
+
+	return &spec, nil
+}
+
+// Validate checks that the spec is named, its nodes are named, typed and
+// uniquely named, and its connections are between its nodes
+func (spec *Spec) Validate() error {
+	if spec.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	names := map[string]bool{}
+	for i, node := range spec.Nodes {
+		if node.Name == "" {
+			return fmt.Errorf("node %d: name is required", i+1)
+		}
+		if node.Type == "" {
+			return fmt.Errorf("node %q: type is required", node.Name)
+		}
+		if names[node.Name] {
+			return fmt.Errorf("node %q: names must be unique", node.Name)
+		}
+		names[node.Name] = true
+	}
+
+	for _, connection := range spec.Connections {
+		for _, end := range []string{connection.From, connection.To} {
+			node, _, ok := SplitPort(end)
+			if !ok {
+				return fmt.Errorf("connection %q -> %q: ends must be \"node.port\"", connection.From, connection.To)
+			}
+			if !names[node] {
+				return fmt.Errorf("connection %q -> %q: unknown node %q", connection.From, connection.To, node)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SplitPort splits "node.port" at its last dot, so node names may contain
+// dots
+func SplitPort(end string) (string, string, bool) {
+	i := strings.LastIndex(end, ".")
+	if i <= 0 || i == len(end)-1 {
+		return "", "", false
+	}
+	return end[:i], end[i+1:], true
+}
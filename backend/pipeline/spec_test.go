@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(t *testing.T, name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write spec: %v", err)
+		}
+		return path
+	}
+
+	t.Run("loads YAML specs", func(t *testing.T) {
+		spec, err := Load(write(t, "pipeline.yaml", `
+name: poster
+tags: [print]
+nodes:
+  - name: photo
+    type: input
+    image: photo.png
+  - name: small
+    type: resize
+    config:
+      width: 800
+      interpolation: Bilinear
+  - name: poster
+    type: output
+connections:
+  - from: photo.original
+    to: small.original
+  - from: small.resized
+    to: poster.input
+`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if spec.Name != "poster" || len(spec.Nodes) != 3 || len(spec.Connections) != 2 {
+			t.Fatalf("unexpected spec: %+v", spec)
+		}
+		if got := spec.Nodes[0].Image; got != filepath.Join(dir, "photo.png") {
+			t.Errorf("expected image path relative to the spec, got %q", got)
+		}
+
+		var config map[string]any
+		if err := json.Unmarshal(spec.Nodes[1].Config, &config); err != nil {
+			t.Fatalf("failed to decode config: %v", err)
+		}
+		if config["width"] != float64(800) || config["interpolation"] != "Bilinear" {
+			t.Errorf("unexpected config: %v", config)
+		}
+	})
+
+	t.Run("loads JSON specs", func(t *testing.T) {
+		spec, err := Load(write(t, "pipeline.json", `{"name": "json", "nodes": [{"name": "in", "type": "input"}]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if spec.Name != "json" || len(spec.Nodes) != 1 {
+			t.Errorf("unexpected spec: %+v", spec)
+		}
+	})
+
+	for _, tt := range []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{"missing name", "nodes: []", "name is required"},
+		{"unknown field", "name: x\nnodez: []", `unknown field "nodez"`},
+		{"duplicate node", "name: x\nnodes:\n  - {name: a, type: input}\n  - {name: a, type: input}", `node "a": names must be unique`},
+		{"bad connection", "name: x\nnodes:\n  - {name: a, type: input}\nconnections:\n  - {from: a, to: a.in}", `ends must be "node.port"`},
+		{"unknown node", "name: x\nnodes:\n  - {name: a, type: input}\nconnections:\n  - {from: a.original, to: b.in}", `unknown node "b"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(write(t, "invalid.yaml", tt.spec))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
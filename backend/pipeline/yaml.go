@@ -0,0 +1,368 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML decodes the subset of YAML that specs are written in:
+// block mappings and sequences, single-line flow collections of scalars
+// ([a, b] and {k: v}), plain and quoted scalars, and # comments. Anchors,
+// tags, multi-line scalars and multiple documents aren't supported.
+//
+// Mappings decode to map[string]any and sequences to []any, as
+// encoding/json decodes objects and arrays.
+func parseYAML(data string) (any, error) {
+	p := &yamlParser{}
+
+	for i, raw := range strings.Split(data, "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+
+		content := strings.TrimLeft(text, " ")
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: indentation must use spaces, not tabs", i+1)
+		}
+
+		p.lines = append(p.lines, yamlLine{
+			number:  i + 1,
+			indent:  len(text) - len(content),
+			content: content,
+		})
+	}
+
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	value, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+	}
+
+	return value, nil
+}
+
+type yamlLine struct {
+	number  int
+	indent  int
+	content string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].content) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]any, error) {
+	items := []any{}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		if !isYAMLSequenceItem(line.content) {
+			return nil, fmt.Errorf("line %d: expected a sequence item", line.number)
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.content, "-"), " ")
+
+		switch {
+		case rest == "":
+			// The item is the block on the following lines
+			p.pos++
+			item, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+		case isYAMLSequenceItem(rest) || isYAMLMappingEntry(rest):
+			// A block starting on the item's line continues on the following
+			// lines at the indentation of its first entry
+			itemIndent := line.indent + len(line.content) - len(rest)
+			p.lines[p.pos] = yamlLine{number: line.number, indent: itemIndent, content: rest}
+			item, err := p.parseBlock(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+		default:
+			p.pos++
+			item, err := parseYAMLValue(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (map[string]any, error) {
+	mapping := map[string]any{}
+
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		if isYAMLSequenceItem(line.content) {
+			return nil, fmt.Errorf("line %d: expected a mapping key", line.number)
+		}
+
+		key, rest, ok := splitYAMLMappingEntry(line.content)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		key, err := parseYAMLKey(key, line.number)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLValue(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			mapping[key] = value
+			continue
+		}
+
+		// Sequences may be indented at the same level as their key
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].content) {
+			value, err := p.parseSequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			mapping[key] = value
+			continue
+		}
+
+		value, err := p.parseNested(indent)
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = value
+	}
+
+	return mapping, nil
+}
+
+// parseNested parses the block indented under a line, which is null if
+// nothing is indented under it
+func (p *yamlParser) parseNested(indent int) (any, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return nil, nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+func isYAMLSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+func isYAMLMappingEntry(content string) bool {
+	if strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") {
+		return false
+	}
+	_, _, ok := splitYAMLMappingEntry(content)
+	return ok
+}
+
+// splitYAMLMappingEntry splits "key: value" at the first colon outside
+// quotes that ends the line or is followed by a space
+func splitYAMLMappingEntry(content string) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ // skip the escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ':' && (i == len(content)-1 || content[i+1] == ' '):
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(content[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a # comment that starts the line or follows a
+// space, outside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ // skip the escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseYAMLKey(key string, lineNumber int) (string, error) {
+	value, err := parseYAMLScalar(key, lineNumber)
+	if err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", fmt.Errorf("line %d: empty key", lineNumber)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// parseYAMLValue parses the value on a line: a scalar or a flow collection
+// of scalars
+func parseYAMLValue(text string, lineNumber int) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence", lineNumber)
+		}
+		items := []any{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLScalar(part, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+
+	case strings.HasPrefix(text, "{"):
+		if !strings.HasSuffix(text, "}") {
+			return nil, fmt.Errorf("line %d: unterminated flow mapping", lineNumber)
+		}
+		mapping := map[string]any{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			key, rest, ok := splitYAMLMappingEntry(part)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\" in flow mapping", lineNumber)
+			}
+			key, err := parseYAMLKey(key, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseYAMLScalar(rest, lineNumber)
+			if err != nil {
+				return nil, err
+			}
+			mapping[key] = value
+		}
+		return mapping, nil
+
+	default:
+		return parseYAMLScalar(text, lineNumber)
+	}
+}
+
+// splitYAMLFlow splits the inside of a flow collection at commas outside
+// quotes
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ // skip the escaped character
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+
+	if last := strings.TrimSpace(text[start:]); last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+
+	return parts
+}
+
+func parseYAMLScalar(text string, lineNumber int) (any, error) {
+	text = strings.TrimSpace(text)
+
+	switch {
+	case strings.HasPrefix(text, `"`):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid double-quoted string %s", lineNumber, text)
+		}
+		return value, nil
+
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("line %d: invalid single-quoted string %s", lineNumber, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f, nil
+	}
+
+	return text, nil
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want any
+	}{
+		{
+			name: "scalars",
+			yaml: `
+int: 42
+float: 1.5
+yes: true
+no: false
+none: ~
+plain: hello world
+double: "a: \"b # not a comment\""
+single: 'it''s'
+url: http://example.com:8080/x
+`,
+			want: map[string]any{
+				"int":    int64(42),
+				"float":  1.5,
+				"yes":    true,
+				"no":     false,
+				"none":   nil,
+				"plain":  "hello world",
+				"double": `a: "b # not a comment"`,
+				"single": "it's",
+				"url":    "http://example.com:8080/x",
+			},
+		},
+		{
+			name: "nested mappings and comments",
+			yaml: `
+# a comment
+outer:
+  inner:
+    value: 1 # trailing comment
+  other: x
+`,
+			want: map[string]any{
+				"outer": map[string]any{
+					"inner": map[string]any{"value": int64(1)},
+					"other": "x",
+				},
+			},
+		},
+		{
+			name: "sequences of mappings",
+			yaml: `
+nodes:
+  - name: a
+    config:
+      width: 800
+  - name: b
+connections:
+- from: a.out
+  to: b.in
+`,
+			want: map[string]any{
+				"nodes": []any{
+					map[string]any{"name": "a", "config": map[string]any{"width": int64(800)}},
+					map[string]any{"name": "b"},
+				},
+				"connections": []any{
+					map[string]any{"from": "a.out", "to": "b.in"},
+				},
+			},
+		},
+		{
+			name: "flow collections",
+			yaml: `
+tags: [pixelart, "a, b"]
+empty: []
+config: {width: 800, interpolation: Bilinear}
+`,
+			want: map[string]any{
+				"tags":   []any{"pixelart", "a, b"},
+				"empty":  []any{},
+				"config": map[string]any{"width": int64(800), "interpolation": "Bilinear"},
+			},
+		},
+		{
+			name: "nested sequences",
+			yaml: `
+- - 1
+  - 2
+-
+  - 3
+`,
+			want: []any{[]any{int64(1), int64(2)}, []any{int64(3)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML(tt.yaml)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"tab indentation", "a:\n\tb: 1", "line 2: indentation must use spaces"},
+		{"duplicate key", "a: 1\na: 2", `line 2: duplicate key "a"`},
+		{"bad indentation", "a:\n    b: 1\n  c: 2", "line 3: unexpected indentation"},
+		{"missing colon", "a: 1\nb", `line 2: expected "key: value"`},
+		{"unterminated flow", "a: [1, 2", "line 1: unterminated flow sequence"},
+		{"mixed block", "a: 1\n- b", "line 2: expected a mapping key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML(tt.yaml)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
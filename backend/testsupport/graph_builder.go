@@ -0,0 +1,316 @@
+// Package testsupport provides fixtures shared by the test suites.
+//
+// GraphBuilder describes an ImageGraph fluently and either builds it directly
+// as a domain aggregate or exposes its nodes and connections so that the HTTP
+// tests can replay them through the API:
+//
+//	ig := testsupport.NewGraphBuilder().
+//		WithInput().
+//		WithResize(800).
+//		WithOutput().
+//		ConnectAll().
+//		MustBuild(t)
+package testsupport
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dmpettyp/artwork/domain/imagegraph"
+)
+
+// NodeSpec describes a node added by a GraphBuilder
+type NodeSpec struct {
+	ID   imagegraph.NodeID
+	Type imagegraph.NodeType
+	Name string
+
+	// Config is nil when the node keeps its type's default config
+	Config imagegraph.NodeConfig
+}
+
+// ConnectionSpec describes a connection added by a GraphBuilder, referring to
+// nodes by name
+type ConnectionSpec struct {
+	From       string
+	OutputName imagegraph.OutputName
+	To         string
+	InputName  imagegraph.InputName
+}
+
+type imageSpec struct {
+	node       string
+	outputName imagegraph.OutputName
+	imageID    imagegraph.ImageID
+}
+
+// GraphBuilder describes an ImageGraph one node at a time. Nodes are named
+// after their type ("input", "blur", "blur2", ...) unless named with Named,
+// and later calls refer to them by that name. The first error encountered
+// is reported by Build.
+type GraphBuilder struct {
+	id          imagegraph.ImageGraphID
+	name        string
+	nodes       []NodeSpec
+	connections []ConnectionSpec
+	images      []imageSpec
+	err         error
+}
+
+// NewGraphBuilder starts describing an empty ImageGraph named "test"
+func NewGraphBuilder() *GraphBuilder {
+	return &GraphBuilder{
+		id:   imagegraph.MustNewImageGraphID(),
+		name: "test",
+	}
+}
+
+// WithName sets the name of the ImageGraph
+func (b *GraphBuilder) WithName(name string) *GraphBuilder {
+	b.name = name
+	return b
+}
+
+// WithNode adds a node of any type with its default config
+func (b *GraphBuilder) WithNode(nodeType imagegraph.NodeType) *GraphBuilder {
+	base := imagegraph.NodeTypeMapper.FromWithDefault(nodeType, "node")
+	name := base
+	for i := 2; b.find(name) != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+
+	b.nodes = append(b.nodes, NodeSpec{
+		ID:   imagegraph.MustNewNodeID(),
+		Type: nodeType,
+		Name: name,
+	})
+
+	return b
+}
+
+// WithInput adds an Input node
+func (b *GraphBuilder) WithInput() *GraphBuilder {
+	return b.WithNode(imagegraph.NodeTypeInput)
+}
+
+// WithBlur adds a Blur node with the provided radius
+func (b *GraphBuilder) WithBlur(radius int) *GraphBuilder {
+	return b.WithNode(imagegraph.NodeTypeBlur).
+		WithConfig(&imagegraph.NodeConfigBlur{Radius: radius})
+}
+
+// WithResize adds a Resize node that scales images to the provided width,
+// keeping their aspect ratio
+func (b *GraphBuilder) WithResize(width int) *GraphBuilder {
+	return b.WithNode(imagegraph.NodeTypeResize).
+		WithConfig(&imagegraph.NodeConfigResize{
+			Width:         &width,
+			Interpolation: "Bilinear",
+		})
+}
+
+// WithOutput adds an Output node
+func (b *GraphBuilder) WithOutput() *GraphBuilder {
+	return b.WithNode(imagegraph.NodeTypeOutput)
+}
+
+// Named renames the most recently added node. Call it before the node is
+// connected or given an image, which refer to it by name.
+func (b *GraphBuilder) Named(name string) *GraphBuilder {
+	last := b.last("Named")
+	if last == nil {
+		return b
+	}
+
+	if b.find(name) != nil {
+		b.fail(fmt.Errorf("Named: a node is already named %q", name))
+		return b
+	}
+
+	last.Name = name
+	return b
+}
+
+// WithConfig replaces the config of the most recently added node
+func (b *GraphBuilder) WithConfig(config imagegraph.NodeConfig) *GraphBuilder {
+	if last := b.last("WithConfig"); last != nil {
+		last.Config = config
+	}
+	return b
+}
+
+// WithImage sets the primary output image of the most recently added node
+// and propagates it to the node's connections, as if the node had been
+// uploaded to or had generated its output
+func (b *GraphBuilder) WithImage(imageID imagegraph.ImageID) *GraphBuilder {
+	last := b.last("WithImage")
+	if last == nil {
+		return b
+	}
+
+	b.images = append(b.images, imageSpec{
+		node:       last.Name,
+		outputName: imagegraph.NodeTypeDefs[last.Type].PrimaryOutput(),
+		imageID:    imageID,
+	})
+
+	return b
+}
+
+// Connect connects the primary output of one node to the primary input of
+// another
+func (b *GraphBuilder) Connect(from, to string) *GraphBuilder {
+	fromNode, toNode := b.find(from), b.find(to)
+	if fromNode == nil || toNode == nil {
+		b.fail(fmt.Errorf("Connect: no nodes named %q and %q", from, to))
+		return b
+	}
+
+	return b.ConnectPorts(
+		from,
+		imagegraph.NodeTypeDefs[fromNode.Type].PrimaryOutput(),
+		to,
+		imagegraph.NodeTypeDefs[toNode.Type].PrimaryInput(),
+	)
+}
+
+// ConnectPorts connects a specific output of one node to a specific input of
+// another
+func (b *GraphBuilder) ConnectPorts(
+	from string,
+	outputName imagegraph.OutputName,
+	to string,
+	inputName imagegraph.InputName,
+) *GraphBuilder {
+	b.connections = append(b.connections, ConnectionSpec{
+		From:       from,
+		OutputName: outputName,
+		To:         to,
+		InputName:  inputName,
+	})
+	return b
+}
+
+// ConnectAll chains the nodes in the order they were added, connecting each
+// node's primary output to the next node's primary input
+func (b *GraphBuilder) ConnectAll() *GraphBuilder {
+	for i := 1; i < len(b.nodes); i++ {
+		b.Connect(b.nodes[i-1].Name, b.nodes[i].Name)
+	}
+	return b
+}
+
+// ImageGraphID is the ID the built ImageGraph will have
+func (b *GraphBuilder) ImageGraphID() imagegraph.ImageGraphID {
+	return b.id
+}
+
+// Name is the name the built ImageGraph will have
+func (b *GraphBuilder) Name() string {
+	return b.name
+}
+
+// NodeID returns the ID of the named node, or a nil ID if there is none
+func (b *GraphBuilder) NodeID(name string) imagegraph.NodeID {
+	if n := b.find(name); n != nil {
+		return n.ID
+	}
+	return imagegraph.NodeID{}
+}
+
+// Nodes lists the nodes in the order they were added
+func (b *GraphBuilder) Nodes() []NodeSpec {
+	return b.nodes
+}
+
+// Connections lists the connections in the order they were added
+func (b *GraphBuilder) Connections() []ConnectionSpec {
+	return b.connections
+}
+
+// Build creates the described ImageGraph. Nodes are added and configured,
+// then connected, then given their images. The events raised while building
+// are discarded so that tests only see the events of what they do next.
+func (b *GraphBuilder) Build() (*imagegraph.ImageGraph, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	ig, err := imagegraph.NewImageGraph(b.id, b.name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range b.nodes {
+		if err := ig.AddNode(n.ID, n.Type, n.Name); err != nil {
+			return nil, err
+		}
+
+		if n.Config != nil {
+			if err := ig.SetNodeConfig(n.ID, n.Config); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, c := range b.connections {
+		err := ig.ConnectNodes(b.NodeID(c.From), c.OutputName, b.NodeID(c.To), c.InputName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, image := range b.images {
+		nodeID := b.NodeID(image.node)
+
+		node, _ := ig.Nodes.Get(nodeID)
+		err := ig.SetNodeOutputImage(nodeID, image.outputName, image.imageID, node.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		err = ig.PropagateOutputImageToConnections(nodeID, image.outputName, image.imageID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ig.ResetEvents()
+
+	return ig, nil
+}
+
+// MustBuild creates the described ImageGraph, failing the test if it can't
+func (b *GraphBuilder) MustBuild(t testing.TB) *imagegraph.ImageGraph {
+	t.Helper()
+
+	ig, err := b.Build()
+	if err != nil {
+		t.Fatalf("failed to build image graph: %v", err)
+	}
+
+	return ig
+}
+
+func (b *GraphBuilder) find(name string) *NodeSpec {
+	for i := range b.nodes {
+		if b.nodes[i].Name == name {
+			return &b.nodes[i]
+		}
+	}
+	return nil
+}
+
+func (b *GraphBuilder) last(method string) *NodeSpec {
+	if len(b.nodes) == 0 {
+		b.fail(fmt.Errorf("%s: no node has been added", method))
+		return nil
+	}
+	return &b.nodes[len(b.nodes)-1]
+}
+
+func (b *GraphBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !embedfrontend
+
+package webui
+
+import "io/fs"
+
+var embedded fs.FS
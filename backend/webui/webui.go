@@ -0,0 +1,16 @@
+// Package webui holds the frontend when it's embedded in the binary, so that
+// a single artwork binary can serve both the API and the UI. The frontend is
+// copied into dist and embedded by building with the embedfrontend tag, which
+// `make build-embedded` does.
+package webui
+
+import "io/fs"
+
+// FS returns the embedded frontend, and false if the binary was built
+// without it
+func FS() (fs.FS, bool) {
+	if embedded == nil {
+		return nil, false
+	}
+	return embedded, true
+}
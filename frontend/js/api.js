@@ -2,13 +2,50 @@
 
 const API_BASE = '/api';
 
-export async function listImageGraphs() {
-    const response = await fetch(`${API_BASE}/imagegraphs`);
+// Get the user requests are authenticated as, or null when the server
+// rejects the stored access token
+export async function getCurrentUser() {
+    const response = await fetch(`${API_BASE}/me`);
+    if (response.status === 401) {
+        return null;
+    }
+    if (!response.ok) {
+        throw new Error(`Failed to get current user: ${response.statusText}`);
+    }
+    return response.json();
+}
+
+// List all image graphs, newest first, fetching them a page at a time
+export async function listImageGraphs(tag = null) {
+    const graphs = [];
+
+    while (true) {
+        const params = new URLSearchParams({ limit: 500, offset: graphs.length });
+        if (tag) {
+            params.set('tag', tag);
+        }
+
+        const response = await fetch(`${API_BASE}/imagegraphs?${params}`);
+        if (!response.ok) {
+            throw new Error(`Failed to list image graphs: ${response.statusText}`);
+        }
+        const data = await response.json();
+        graphs.push(...data.imagegraphs);
+
+        if (data.imagegraphs.length === 0 || graphs.length >= data.total) {
+            return graphs;
+        }
+    }
+}
+
+// Search graph and node names and tags, returning typed hits
+export async function search(query) {
+    const response = await fetch(`${API_BASE}/search?q=${encodeURIComponent(query)}`);
     if (!response.ok) {
-        throw new Error(`Failed to list image graphs: ${response.statusText}`);
+        throw new Error(`Failed to search: ${response.statusText}`);
     }
     const data = await response.json();
-    return data.imagegraphs;
+    return data.hits;
 }
 
 export async function createImageGraph(name) {
@@ -34,6 +71,15 @@ export async function getImageGraph(id) {
     return response.json();
 }
 
+// Get a graph together with its layout and viewport in one request
+export async function getFullImageGraph(id) {
+    const response = await fetch(`${API_BASE}/imagegraphs/${id}/full`);
+    if (!response.ok) {
+        throw new Error(`Failed to get image graph: ${response.statusText}`);
+    }
+    return response.json();
+}
+
 export async function addNode(graphId, nodeType, nodeName, config) {
     const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/nodes`, {
         method: 'POST',
@@ -98,11 +144,14 @@ export async function disconnectNodes(graphId, sourceNodeId, sourceOutput, targe
     }
 }
 
-export async function updateNode(graphId, nodeId, name, config) {
+export async function updateNode(graphId, nodeId, name, config, description) {
     const body = {};
     if (name !== undefined && name !== null) {
         body.name = name;
     }
+    if (description !== undefined && description !== null) {
+        body.description = description;
+    }
     if (config !== undefined && config !== null) {
         body.config = config;
     }
@@ -119,6 +168,41 @@ export async function updateNode(graphId, nodeId, name, config) {
     }
 }
 
+export async function setNodeBypassed(graphId, nodeId, bypassed) {
+    const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/nodes/${nodeId}`, {
+        method: 'PATCH',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ bypassed }),
+    });
+    if (!response.ok) {
+        throw new Error(`Failed to update node bypass: ${response.statusText}`);
+    }
+}
+
+export async function setNodePinned(graphId, nodeId, pinned) {
+    const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/nodes/${nodeId}`, {
+        method: 'PATCH',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ pinned }),
+    });
+    if (!response.ok) {
+        throw new Error(`Failed to update node pin: ${response.statusText}`);
+    }
+}
+
+export async function upgradeNode(graphId, nodeId) {
+    const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/nodes/${nodeId}/upgrade`, {
+        method: 'POST',
+    });
+    if (!response.ok) {
+        throw new Error(`Failed to upgrade node: ${response.statusText}`);
+    }
+}
+
 export async function uploadNodeOutputImage(graphId, nodeId, outputName, imageFile) {
     const formData = new FormData();
     formData.append('image', imageFile);
@@ -134,6 +218,25 @@ export async function uploadNodeOutputImage(graphId, nodeId, outputName, imageFi
     return data.image_id;
 }
 
+// Creates an input node per image, expanding ZIP archives, and returns the
+// new node IDs
+export async function uploadInputs(graphId, files) {
+    const formData = new FormData();
+    for (const file of files) {
+        formData.append('images', file);
+    }
+
+    const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/inputs`, {
+        method: 'POST',
+        body: formData,
+    });
+    if (!response.ok) {
+        throw new Error(`Failed to upload inputs: ${response.statusText}`);
+    }
+    const data = await response.json();
+    return data.node_ids;
+}
+
 // Layout API functions
 
 export async function getLayout(graphId) {
@@ -145,11 +248,11 @@ export async function getLayout(graphId) {
 }
 
 export async function updateLayout(graphId, nodePositions) {
-    // Convert nodePositions Map to array format
+    // Convert nodePositions Map to array format, keeping any size, color and
+    // other layout state loaded with the positions
     const nodePositionsArray = Array.from(nodePositions.entries()).map(([nodeId, pos]) => ({
+        ...pos,
         node_id: nodeId,
-        x: pos.x,
-        y: pos.y,
     }));
 
     const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/layout`, {
@@ -166,6 +269,18 @@ export async function updateLayout(graphId, nodePositions) {
     }
 }
 
+// Arrange the nodes in layers following their connections and save the
+// positions as the graph's layout
+export async function autoLayout(graphId) {
+    const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/layout/auto`, {
+        method: 'POST',
+    });
+    if (!response.ok) {
+        throw new Error(`Failed to arrange graph: ${response.statusText}`);
+    }
+    return response.json();
+}
+
 // Viewport API functions
 
 export async function getViewport(graphId) {
@@ -176,6 +291,26 @@ export async function getViewport(graphId) {
     return response.json();
 }
 
+// Add or replace a named viewport bookmark
+export async function saveViewportBookmark(graphId, name, viewport, isDefault = false) {
+    const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/viewport/bookmarks/${encodeURIComponent(name)}`, {
+        method: 'PUT',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+            zoom: viewport.zoom,
+            pan_x: viewport.panX,
+            pan_y: viewport.panY,
+            default: isDefault,
+        }),
+    });
+    if (!response.ok) {
+        throw new Error(`Failed to save bookmark: ${response.statusText}`);
+    }
+    return response.json();
+}
+
 export async function updateViewport(graphId, viewport) {
     const response = await fetch(`${API_BASE}/imagegraphs/${graphId}/viewport`, {
         method: 'PUT',
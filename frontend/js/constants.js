@@ -24,14 +24,24 @@ export const API_PATHS = {
     base: '/api',
     imagegraphs: '/api/imagegraphs',
     images: (imageId) => `/api/images/${imageId}`,
+    nodePreview: (graphId, nodeId) => `/api/imagegraphs/${graphId}/nodes/${nodeId}/preview`,
+    exportsArchive: (graphId) => `/api/imagegraphs/${graphId}/exports/archive`,
     graphWebSocket: (graphId) => `/api/imagegraphs/${graphId}/ws`
 };
 
+// Cookie the access token is stored in when the server requires authentication
+export const TOKEN_COOKIE_NAME = 'artwork_token';
+
 // WebSocket configuration
 export const WS_CONFIG = {
     reconnectDelay: 3000 // 3 seconds
 };
 
+// Graph complexity limits configuration
+export const COMPLEXITY_CONFIG = {
+    warningRatio: 0.8 // Warn when a counter reaches this fraction of its limit
+};
+
 // Sidebar configuration
 export const SIDEBAR_CONFIG = {
     minWidth: 200,
@@ -42,7 +52,8 @@ export const SIDEBAR_CONFIG = {
 // Debounce delays (in milliseconds)
 export const DEBOUNCE_DELAYS = {
     layoutSave: 500,
-    viewportSave: 500
+    viewportSave: 500,
+    search: 250
 };
 
 // Zoom configuration
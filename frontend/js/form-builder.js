@@ -90,7 +90,13 @@ export class NodeConfigFormBuilder {
                                 ? 'Perceptual clusters (OKLab)'
                                 : optionValue === 'dominant_frequency'
                                     ? 'Dominant colors (frequency)'
-                                    : optionValue;
+                                    : optionValue === 'median_cut'
+                                        ? 'Median cut (fast)'
+                                        : optionValue === 'oklab'
+                                            ? 'Perceptual (OKLab)'
+                                            : optionValue === 'rgb'
+                                                ? 'RGB'
+                                                : optionValue;
                     input.appendChild(option);
                 });
             }
@@ -0,0 +1,84 @@
+// Read-only gallery of the Output images of public graphs
+const API_BASE = '/api/gallery';
+
+const titleElement = document.getElementById('gallery-title');
+const messageElement = document.getElementById('gallery-message');
+const gridElement = document.getElementById('gallery-grid');
+
+async function fetchGallery(path) {
+    const response = await fetch(`${API_BASE}${path}`);
+    if (response.status === 429) {
+        throw new Error('Too many requests, please try again shortly');
+    }
+    if (!response.ok) {
+        throw new Error(`Failed to load gallery: ${response.statusText}`);
+    }
+    return response.json();
+}
+
+function createCard(imageUrl, caption, detail, href) {
+    const card = document.createElement(href ? 'a' : 'div');
+    card.className = 'gallery-card';
+    if (href) {
+        card.href = href;
+    }
+
+    if (imageUrl) {
+        const img = document.createElement('img');
+        img.src = imageUrl;
+        img.alt = caption;
+        img.loading = 'lazy';
+        card.appendChild(img);
+    }
+
+    const captionElement = document.createElement('div');
+    captionElement.className = 'gallery-card-caption';
+    captionElement.textContent = caption;
+
+    if (detail) {
+        const detailElement = document.createElement('small');
+        detailElement.textContent = detail;
+        captionElement.appendChild(detailElement);
+    }
+
+    card.appendChild(captionElement);
+    return card;
+}
+
+async function showIndex() {
+    const { graphs } = await fetchGallery('');
+
+    if (graphs.length === 0) {
+        messageElement.textContent = 'Nothing has been published yet.';
+        return;
+    }
+
+    graphs.forEach(graph => {
+        const detail = `${graph.image_count} image${graph.image_count === 1 ? '' : 's'}`;
+        const href = `/gallery.html?graph=${encodeURIComponent(graph.id)}`;
+        gridElement.appendChild(createCard(graph.cover_url, graph.name, detail, href));
+    });
+}
+
+async function showGraph(graphId) {
+    const graph = await fetchGallery(`/${encodeURIComponent(graphId)}`);
+
+    titleElement.textContent = graph.name;
+    document.title = `Artwork - ${graph.name}`;
+
+    if (graph.images.length === 0) {
+        messageElement.textContent = 'This graph has no finished images yet.';
+        return;
+    }
+
+    graph.images.forEach(image => {
+        gridElement.appendChild(createCard(image.url, image.name, null, image.url));
+    });
+}
+
+const graphId = new URLSearchParams(window.location.search).get('graph');
+
+(graphId ? showGraph(graphId) : showIndex()).catch(error => {
+    console.error('Failed to load gallery:', error);
+    messageElement.textContent = error.message;
+});
@@ -1,6 +1,6 @@
 // GraphManager - Handles graph loading, selection, and WebSocket connections
 
-import { API_PATHS, WS_CONFIG } from './constants.js';
+import { API_PATHS, WS_CONFIG, COMPLEXITY_CONFIG } from './constants.js';
 
 export class GraphManager {
     constructor(api, graphState, renderer, toastManager) {
@@ -11,6 +11,11 @@ export class GraphManager {
 
         this.wsConnection = null;
         this.wsReconnectTimeout = null;
+        this.wsLastEventGraphId = null;
+        this.wsLastEventId = null;
+
+        // Only graphs with this tag are listed, when set
+        this.tagFilter = null;
 
         // Callbacks
         this.onGraphListRendered = null;
@@ -44,7 +49,7 @@ export class GraphManager {
     // Load and display graph list
     async loadGraphList(preferredGraphId = null) {
         try {
-            const graphs = await this.api.listImageGraphs();
+            const graphs = await this.api.listImageGraphs(this.tagFilter);
             this.renderGraphList(graphs);
 
             // Auto-select the first graph if none is selected
@@ -69,7 +74,12 @@ export class GraphManager {
         graphs.forEach(graph => {
             const option = document.createElement('option');
             option.value = graph.id;
-            option.textContent = graph.name;
+            option.textContent = graph.tags?.length
+                ? `${graph.name} [${graph.tags.join(', ')}]`
+                : graph.name;
+            if (graph.updated_at) {
+                option.title = `Last modified ${new Date(graph.updated_at).toLocaleString()}`;
+            }
 
             if (graph.id === currentGraphId) {
                 option.selected = true;
@@ -87,24 +97,18 @@ export class GraphManager {
     // Select and load a graph
     async selectGraph(graphId) {
         try {
-            const graph = await this.api.getImageGraph(graphId);
-
-            // Load layout and viewport separately
-            try {
-                const layout = await this.api.getLayout(graphId);
-                this.renderer.restoreNodePositions(layout.node_positions);
-            } catch (error) {
-                // Not an error - just means no layout was saved yet
-            }
+            const full = await this.api.getFullImageGraph(graphId);
+            const graph = full.imagegraph;
 
-            try {
-                const viewport = await this.api.getViewport(graphId);
-                this.renderer.restoreViewport(viewport);
-            } catch (error) {
-                // Not an error - just means no viewport was saved yet
-            }
+            this.renderer.restoreNodePositions(full.layout.node_positions);
+
+            // Open the graph at its default bookmark if it has one
+            const defaultBookmark = full.viewport.bookmarks.find(b => b.default);
+            this.renderer.restoreViewport(defaultBookmark || full.viewport);
+            this.renderBookmarks(full.viewport.bookmarks);
 
             this.graphState.setCurrentGraph(graph);
+            this.renderComplexity(graph.complexity);
             this.updateGraphInUrl(graphId);
             await this.loadGraphList(); // Refresh list to update active state
 
@@ -116,6 +120,36 @@ export class GraphManager {
         }
     }
 
+    // List the graph's viewport bookmarks in the bookmark dropdown
+    renderBookmarks(bookmarks) {
+        this.bookmarks = bookmarks;
+
+        const select = document.getElementById('viewport-bookmark-select');
+        if (!select) return;
+
+        select.innerHTML = '<option value="">Jump to bookmark...</option>';
+        bookmarks.forEach(bookmark => {
+            const option = document.createElement('option');
+            option.value = bookmark.name;
+            option.textContent = bookmark.default ? `${bookmark.name} (default)` : bookmark.name;
+            select.appendChild(option);
+        });
+    }
+
+    // Reload the bookmarks of a graph
+    async reloadBookmarks(graphId) {
+        const viewport = await this.api.getViewport(graphId);
+        this.renderBookmarks(viewport.bookmarks);
+    }
+
+    // Move the canvas to the named bookmark
+    jumpToBookmark(name) {
+        const bookmark = (this.bookmarks || []).find(b => b.name === name);
+        if (bookmark) {
+            this.renderer.restoreViewport(bookmark);
+        }
+    }
+
     // Reload the currently selected graph
     async reloadCurrentGraph() {
         const graphId = this.graphState.getCurrentGraphId();
@@ -123,6 +157,44 @@ export class GraphManager {
 
         const graph = await this.api.getImageGraph(graphId);
         this.graphState.setCurrentGraph(graph);
+        this.renderComplexity(graph.complexity);
+    }
+
+    // Show the graph's complexity counters, warning as they approach the
+    // server's limits
+    renderComplexity(complexity) {
+        const el = document.getElementById('graph-complexity');
+        if (!el) return;
+
+        if (!complexity) {
+            el.textContent = '';
+            el.classList.remove('warning', 'limit');
+            return;
+        }
+
+        const counter = (count, max, label) => max ? `${count}/${max} ${label}` : `${count} ${label}`;
+        const ratio = (count, max) => max ? count / max : 0;
+        const highest = Math.max(
+            ratio(complexity.nodes, complexity.max_nodes),
+            ratio(complexity.connections, complexity.max_connections)
+        );
+
+        const parts = [
+            counter(complexity.nodes, complexity.max_nodes, 'nodes'),
+            counter(complexity.connections, complexity.max_connections, 'connections')
+        ];
+        if (complexity.pending_generations > 0) {
+            parts.push(`${complexity.pending_generations} pending`);
+        }
+
+        el.textContent = parts.join(' · ');
+        el.classList.toggle('limit', highest >= 1);
+        el.classList.toggle('warning', highest >= COMPLEXITY_CONFIG.warningRatio && highest < 1);
+        el.title = highest >= 1
+            ? 'This graph has reached its size limit'
+            : highest >= COMPLEXITY_CONFIG.warningRatio
+                ? 'This graph is approaching its size limit'
+                : '';
     }
 
     // Reload just the layout for a graph
@@ -146,9 +218,19 @@ export class GraphManager {
         // Disconnect existing connection if any
         this.disconnectWebSocket();
 
-        // Determine WebSocket URL (ws:// for http://, wss:// for https://)
+        // Updates are only replayed when reconnecting to the same graph
+        if (this.wsLastEventGraphId !== graphId) {
+            this.wsLastEventGraphId = graphId;
+            this.wsLastEventId = null;
+        }
+
+        // Determine WebSocket URL (ws:// for http://, wss:// for https://),
+        // asking for the updates missed while disconnected
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}${API_PATHS.graphWebSocket(graphId)}`;
+        let wsUrl = `${protocol}//${window.location.host}${API_PATHS.graphWebSocket(graphId)}`;
+        if (this.wsLastEventId) {
+            wsUrl += `?last_event_id=${encodeURIComponent(this.wsLastEventId)}`;
+        }
 
         try {
             this.wsConnection = new WebSocket(wsUrl);
@@ -164,11 +246,17 @@ export class GraphManager {
             this.wsConnection.onmessage = async (event) => {
                 try {
                     const message = JSON.parse(event.data);
+                    if (message.id) {
+                        this.wsLastEventId = message.id;
+                    }
 
                     // Handle different message types
                     if (message.type === 'layout_update') {
                         // Layout changed - fetch and apply new layout
                         await this.reloadLayout(graphId);
+                    } else if (message.type === 'heartbeat') {
+                        // Periodic complexity counters - no graph changes
+                        this.renderComplexity(message.data);
                     } else if (message.type === 'node_update') {
                         // Node state changed - refresh the entire graph
                         await this.reloadCurrentGraph();
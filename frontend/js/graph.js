@@ -30,6 +30,12 @@ export class GraphState {
         return this.currentGraphId;
     }
 
+    // Viewers of a shared graph can't change it. Graphs carry no role when
+    // authentication is disabled.
+    canEditCurrentGraph() {
+        return this.currentGraph?.role !== 'viewer';
+    }
+
     updateNode(nodeId, updates) {
         if (!this.currentGraph) return;
 
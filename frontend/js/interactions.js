@@ -204,7 +204,7 @@ export class InteractionHandler {
 
     debouncedSaveLayout() {
         const graphId = this.graphState.getCurrentGraphId();
-        if (!graphId) return;
+        if (!graphId || !this.graphState.canEditCurrentGraph()) return;
 
         // Clear any existing timeout to debounce
         clearTimeout(this.saveLayoutTimeout);
@@ -223,7 +223,7 @@ export class InteractionHandler {
 
     debouncedSaveViewport() {
         const graphId = this.graphState.getCurrentGraphId();
-        if (!graphId) return;
+        if (!graphId || !this.graphState.canEditCurrentGraph()) return;
 
         // Clear any existing timeout to debounce
         clearTimeout(this.saveViewportTimeout);
@@ -8,7 +8,7 @@ import { ModalManager } from './modal.js';
 import { ToastManager } from './toast.js';
 import { NodeConfigFormBuilder } from './form-builder.js';
 import { GraphManager } from './graph-manager.js';
-import { SIDEBAR_CONFIG } from './constants.js';
+import { SIDEBAR_CONFIG, DEBOUNCE_DELAYS, TOKEN_COOKIE_NAME } from './constants.js';
 import { loadNodeTypeSchemas } from './node-type-schemas.js';
 import { setNodeTypeConfigs, getNodeTypeConfigs } from './node-type-config-store.js';
 import { OutputSidebar } from './output-sidebar.js';
@@ -62,6 +62,12 @@ const interactions = new InteractionHandler(
 const graphSelect = document.getElementById('graph-select');
 const createGraphBtn = document.getElementById('create-graph-btn');
 const refreshBtn = document.getElementById('refresh-btn');
+const autoLayoutBtn = document.getElementById('auto-layout-btn');
+const bookmarkViewBtn = document.getElementById('bookmark-view-btn');
+const viewportBookmarkSelect = document.getElementById('viewport-bookmark-select');
+const graphTagFilter = document.getElementById('graph-tag-filter');
+const graphSearchInput = document.getElementById('graph-search-input');
+const graphSearchResults = document.getElementById('graph-search-results');
 
 // Context menu
 const contextMenu = document.getElementById('context-menu');
@@ -133,6 +139,91 @@ async function handleDisconnectConnection(fromNodeId, fromOutput, toNodeId, toIn
 }
 
 // Refresh current graph
+// Filter the graph list by tag
+graphTagFilter.addEventListener('change', () => {
+    graphManager.tagFilter = graphTagFilter.value.trim() || null;
+    graphManager.loadGraphList();
+});
+
+// Search graphs and nodes, opening the graph (and node) of a chosen hit
+let searchTimeout = null;
+
+graphSearchInput.addEventListener('input', () => {
+    clearTimeout(searchTimeout);
+    searchTimeout = setTimeout(runSearch, DEBOUNCE_DELAYS.search);
+});
+
+graphSearchInput.addEventListener('keydown', (e) => {
+    if (e.key === 'Escape') {
+        hideSearchResults();
+    }
+});
+
+document.addEventListener('click', (e) => {
+    if (!e.target.closest('.graph-search')) {
+        hideSearchResults();
+    }
+});
+
+async function runSearch() {
+    const query = graphSearchInput.value.trim();
+    if (!query) {
+        hideSearchResults();
+        return;
+    }
+
+    try {
+        renderSearchResults(await api.search(query));
+    } catch (error) {
+        console.error('Failed to search:', error);
+        toastManager.error(`Failed to search: ${error.message}`);
+    }
+}
+
+function renderSearchResults(hits) {
+    graphSearchResults.innerHTML = '';
+
+    if (hits.length === 0) {
+        const empty = document.createElement('li');
+        empty.className = 'search-hit-context';
+        empty.textContent = 'No matches';
+        graphSearchResults.appendChild(empty);
+    }
+
+    hits.forEach((hit) => {
+        const item = document.createElement('li');
+        item.textContent = hit.field === 'tag' ? `#${hit.value}` : hit.value;
+
+        const context = document.createElement('div');
+        context.className = 'search-hit-context';
+        context.textContent = hit.type === 'node'
+            ? `Node ${hit.node_name} in ${hit.graph_name}`
+            : `Graph ${hit.graph_name}`;
+        item.appendChild(context);
+
+        item.addEventListener('click', () => openSearchHit(hit));
+        graphSearchResults.appendChild(item);
+    });
+
+    graphSearchResults.style.display = 'block';
+}
+
+function hideSearchResults() {
+    graphSearchResults.style.display = 'none';
+}
+
+async function openSearchHit(hit) {
+    hideSearchResults();
+
+    if (graphState.getCurrentGraphId() !== hit.graph_id) {
+        await graphManager.selectGraph(hit.graph_id);
+    }
+
+    if (hit.type === 'node') {
+        modals?.editNode.open(hit.node_id);
+    }
+}
+
 refreshBtn.addEventListener('click', async () => {
     if (!graphState.getCurrentGraphId()) return;
 
@@ -145,6 +236,43 @@ refreshBtn.addEventListener('click', async () => {
     }
 });
 
+autoLayoutBtn.addEventListener('click', async () => {
+    const graphId = graphState.getCurrentGraphId();
+    if (!graphId) return;
+
+    try {
+        await api.autoLayout(graphId);
+        await graphManager.reloadLayout(graphId);
+    } catch (error) {
+        console.error('Failed to arrange graph:', error);
+        toastManager.error(`Failed to arrange graph: ${error.message}`);
+    }
+});
+
+viewportBookmarkSelect.addEventListener('change', (e) => {
+    if (e.target.value) {
+        graphManager.jumpToBookmark(e.target.value);
+        e.target.value = '';
+    }
+});
+
+bookmarkViewBtn.addEventListener('click', async () => {
+    const graphId = graphState.getCurrentGraphId();
+    if (!graphId) return;
+
+    const name = window.prompt('Bookmark name');
+    if (!name || !name.trim()) return;
+
+    try {
+        await api.saveViewportBookmark(graphId, name.trim(), renderer.exportViewport());
+        await graphManager.reloadBookmarks(graphId);
+        toastManager.info(`Saved bookmark "${name.trim()}"`);
+    } catch (error) {
+        console.error('Failed to save bookmark:', error);
+        toastManager.error(`Failed to save bookmark: ${error.message}`);
+    }
+});
+
 // Context menu handlers
 svg.addEventListener('contextmenu', (e) => {
     e.preventDefault();
@@ -233,6 +361,12 @@ nodeContextMenu.addEventListener('click', (e) => {
         } else if (action === 'view-json') {
             const node = graphState.getNode(contextMenuNodeId);
             modals?.viewJson.open(node);
+        } else if (action === 'toggle-bypass') {
+            toggleNodeBypass(contextMenuNodeId);
+        } else if (action === 'toggle-pin') {
+            toggleNodePin(contextMenuNodeId);
+        } else if (action === 'upgrade') {
+            upgradeNode(contextMenuNodeId);
         } else if (action === 'delete') {
             modals?.deleteNode.open(contextMenuNodeId);
         }
@@ -242,6 +376,56 @@ nodeContextMenu.addEventListener('click', (e) => {
     // Don't close menu if clicking on empty space
 });
 
+async function toggleNodeBypass(nodeId) {
+    const graphId = graphState.getCurrentGraphId();
+    const node = graphState.getNode(nodeId);
+    if (!graphId || !node) return;
+
+    try {
+        await api.setNodeBypassed(graphId, nodeId, !node.bypassed);
+        await graphManager.reloadCurrentGraph();
+        toastManager.success(node.bypassed ? 'Node enabled' : 'Node bypassed');
+    } catch (error) {
+        console.error('Failed to toggle bypass:', error);
+        toastManager.error(`Failed to toggle bypass: ${error.message}`);
+    }
+}
+
+async function toggleNodePin(nodeId) {
+    const graphId = graphState.getCurrentGraphId();
+    const node = graphState.getNode(nodeId);
+    if (!graphId || !node) return;
+
+    try {
+        await api.setNodePinned(graphId, nodeId, !node.pinned);
+        await graphManager.reloadCurrentGraph();
+        toastManager.success(node.pinned ? 'Node unpinned' : 'Node pinned');
+    } catch (error) {
+        console.error('Failed to toggle pin:', error);
+        toastManager.error(`Failed to toggle pin: ${error.message}`);
+    }
+}
+
+async function upgradeNode(nodeId) {
+    const graphId = graphState.getCurrentGraphId();
+    const node = graphState.getNode(nodeId);
+    if (!graphId || !node) return;
+
+    if (node.implementation >= node.latest_implementation) {
+        toastManager.success(`Node already uses implementation v${node.implementation}`);
+        return;
+    }
+
+    try {
+        await api.upgradeNode(graphId, nodeId);
+        await graphManager.reloadCurrentGraph();
+        toastManager.success(`Node upgraded to implementation v${node.latest_implementation}`);
+    } catch (error) {
+        console.error('Failed to upgrade node:', error);
+        toastManager.error(`Failed to upgrade node: ${error.message}`);
+    }
+}
+
 // Clean up WebSocket on page unload
 window.addEventListener('beforeunload', () => {
     graphManager.cleanup();
@@ -318,6 +502,7 @@ function populateAddNodeContextMenu(schemas) {
     orderedTypes.forEach((nodeType) => {
         const config = schemas[nodeType];
         if (!config) return; // Skip if config doesn't exist (e.g., _orderedTypes itself)
+        if (config.deprecated) return; // Existing nodes keep working, but new ones aren't offered
 
         const category = config.category || 'Other';
         if (!categorized[category]) {
@@ -439,9 +624,29 @@ async function createGreyscalePaletteNode(position) {
     }
 }
 
+// Prompt for an access token when the server requires one. The token is kept
+// in a cookie so image and WebSocket requests are authenticated too.
+async function ensureAuthenticated() {
+    const user = await api.getCurrentUser();
+    if (user) {
+        return true;
+    }
+
+    const token = window.prompt('Enter your access token');
+    if (token) {
+        document.cookie = `${TOKEN_COOKIE_NAME}=${token.trim()}; path=/; SameSite=Strict`;
+        window.location.reload();
+    }
+    return false;
+}
+
 // Load initial data
 async function initialize() {
     try {
+        if (!(await ensureAuthenticated())) {
+            return;
+        }
+
         // Load node type schemas from backend
         const schemas = await loadNodeTypeSchemas();
         setNodeTypeConfigs(schemas);
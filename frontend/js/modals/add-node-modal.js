@@ -143,6 +143,22 @@ export class AddNodeModalController {
 
         const config = this.formBuilder.getValues(this.configFields);
 
+        // Several images, or a ZIP of them, each become an input node named
+        // after the file
+        const files = Array.from(this.imageInput.files);
+        if (nodeType === 'input' && (files.length > 1 || files[0].name.toLowerCase().endsWith('.zip'))) {
+            try {
+                const nodeIds = await this.api.uploadInputs(graphId, files);
+                this.close();
+                await this.graphManager.reloadCurrentGraph();
+                this.toastManager.success(`${nodeIds.length} input nodes added successfully`);
+            } catch (error) {
+                console.error('Failed to upload inputs:', error);
+                this.toastManager.error(`Failed to upload inputs: ${error.message}`);
+            }
+            return;
+        }
+
         try {
             // Add the node first to get the node ID
             const nodeId = await this.api.addNode(graphId, nodeType, nodeName, config);
@@ -14,6 +14,7 @@ export class EditNodeModalController {
         // DOM elements
         this.titleElement = document.getElementById('edit-config-modal-title');
         this.nameInput = document.getElementById('edit-node-name-input');
+        this.descriptionInput = document.getElementById('edit-node-description-input');
         this.imageUpload = document.getElementById('edit-image-upload');
         this.imageInput = document.getElementById('edit-image-input');
         this.configFields = document.getElementById('edit-config-fields');
@@ -62,6 +63,7 @@ export class EditNodeModalController {
 
         this.currentNodeId = nodeId;
         this.nameInput.value = node.name || '';
+        this.descriptionInput.value = node.description || '';
 
         // Update modal title
         const configs = this.getNodeTypeConfigs();
@@ -137,6 +139,7 @@ export class EditNodeModalController {
 
         const node = this.graphState.getNode(this.currentNodeId);
         const newName = this.nameInput.value.trim();
+        const newDescription = this.descriptionInput.value.trim();
 
         // Check if name is required for this node type
         const configs = this.getNodeTypeConfigs();
@@ -158,22 +161,24 @@ export class EditNodeModalController {
         // Determine what changed (handle empty string vs null for optional names)
         const oldName = node.name || '';
         const nameChanged = newName !== oldName;
+        const descriptionChanged = newDescription !== (node.description || '');
         const configChanged = JSON.stringify(config) !== JSON.stringify(node.config);
         const imageChanged = node.type === 'input' && this.imageInput.files.length > 0;
 
-        if (!nameChanged && !configChanged && !imageChanged) {
+        if (!nameChanged && !descriptionChanged && !configChanged && !imageChanged) {
             this.close();
             return;
         }
 
         try {
-            // Update name and/or config if changed
-            if (nameChanged || configChanged) {
+            // Update name, description and/or config if changed
+            if (nameChanged || descriptionChanged || configChanged) {
                 await this.api.updateNode(
                     graphId,
                     this.currentNodeId,
                     nameChanged ? newName : null,
-                    configChanged ? config : null
+                    configChanged ? config : null,
+                    descriptionChanged ? newDescription : null
                 );
             }
 
@@ -27,6 +27,7 @@ export async function loadNodeTypeSchemas() {
             configs[nodeType] = {
                 name: entry.display_name,
                 category: entry.category,
+                deprecated: entry.deprecated,
                 nameRequired: entry.schema.name_required,
                 fields: entry.schema.fields
             };
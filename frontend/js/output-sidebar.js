@@ -4,6 +4,7 @@ import { API_PATHS } from './constants.js';
 export class OutputSidebar {
     constructor(graphState, renderer, toastManager) {
         this.container = document.querySelector('.sidebar-content');
+        this.downloadLink = document.getElementById('download-exports-link');
         this.graphState = graphState;
         this.renderer = renderer;
         this.toastManager = toastManager;
@@ -13,6 +14,8 @@ export class OutputSidebar {
     }
 
     render(graph) {
+        this.updateDownloadLink(graph);
+
         if (!graph) {
             this.container.innerHTML = '<p style="color: #7f8c8d; text-align: center; margin-top: 20px;">No graph selected</p>';
             return;
@@ -46,6 +49,18 @@ export class OutputSidebar {
         });
     }
 
+    // Only offer the archive once at least one output has a final image
+    updateDownloadLink(graph) {
+        const hasExports = graph?.nodes.some(node =>
+            node.type === 'output' && node.outputs?.find(o => o.name === 'final')?.image_id
+        );
+
+        this.downloadLink.hidden = !hasExports;
+        if (hasExports) {
+            this.downloadLink.href = API_PATHS.exportsArchive(graph.id);
+        }
+    }
+
     createOutputCard(node, output) {
         const hasImage = output?.image_id;
 
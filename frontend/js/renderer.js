@@ -74,6 +74,15 @@ export class Renderer {
 
         this.clear();
 
+        // Forget the positions of removed nodes, which the server won't
+        // accept back when the layout is saved
+        const nodeIds = new Set(graph.nodes.map(node => node.id));
+        for (const nodeId of this.nodePositions.keys()) {
+            if (!nodeIds.has(nodeId)) {
+                this.nodePositions.delete(nodeId);
+            }
+        }
+
         // Render nodes first
         graph.nodes.forEach((node, index) => {
             // Simple grid layout if no position stored
@@ -104,9 +113,29 @@ export class Renderer {
         const g = document.createElementNS('http://www.w3.org/2000/svg', 'g');
         g.classList.add('node');
         g.classList.add(`state-${node.state}`);
+        if (node.bypassed) {
+            g.classList.add('bypassed');
+        }
+        if (node.pinned) {
+            g.classList.add('pinned');
+        }
+        if (node.stale) {
+            g.classList.add('stale');
+        }
         g.setAttribute('data-node-id', node.id);
         g.setAttribute('transform', `translate(${x},${y})`);
 
+        // Show the node's notes and when it last changed as a hover tooltip
+        const tooltip = [
+            node.description,
+            node.updated_at && `Last modified ${new Date(node.updated_at).toLocaleString()}`
+        ].filter(Boolean).join('\n\n');
+        if (tooltip) {
+            const title = document.createElementNS('http://www.w3.org/2000/svg', 'title');
+            title.textContent = tooltip;
+            g.appendChild(title);
+        }
+
         const inputs = node.inputs || [];
         const outputs = node.outputs || [];
 
@@ -199,12 +228,20 @@ export class Renderer {
         // Render thumbnail if first output has an image
         if (defaultImageId) {
             this.renderThumbnail(g, defaultImageId, thumbnailY);
+        } else if (node.state === 'generated' && this.graphState) {
+            // Performance mode skipped the preview, the server generates it
+            // when it's first asked for
+            const graphId = this.graphState.getCurrentGraphId();
+            this.renderThumbnail(g, null, thumbnailY, API_PATHS.nodePreview(graphId, node.id));
         } else if (node.state === 'waiting') {
             // Show "Waiting For Inputs..." message when in waiting state
             this.renderWaitingMessage(g, thumbnailY);
         } else if (node.state === 'generating') {
             // Show "Generating Outputs..." message when in generating state
             this.renderGeneratingMessage(g, thumbnailY);
+        } else if (node.state === 'failed') {
+            // Show "Generation Failed" message, with the error as a tooltip
+            this.renderFailedMessage(g, node.error, thumbnailY);
         }
 
         // Render port table
@@ -213,7 +250,7 @@ export class Renderer {
         this.nodesLayer.appendChild(g);
     }
 
-    renderThumbnail(parentG, imageId, yPos = NODE_DESIGN.thumbnail.y) {
+    renderThumbnail(parentG, imageId, yPos = NODE_DESIGN.thumbnail.y, src = API_PATHS.images(imageId)) {
         const image = document.createElementNS('http://www.w3.org/2000/svg', 'image');
         image.classList.add('node-thumbnail');
         image.setAttribute('x', (NODE_DESIGN.width - NODE_DESIGN.thumbnail.width) / 2);
@@ -221,7 +258,7 @@ export class Renderer {
         image.setAttribute('data-original-y', yPos); // Store original Y position for updates
         image.setAttribute('width', NODE_DESIGN.thumbnail.width);
         image.setAttribute('height', NODE_DESIGN.thumbnail.height);
-        image.setAttribute('href', API_PATHS.images(imageId));
+        image.setAttribute('href', src);
         image.setAttribute('preserveAspectRatio', 'xMidYMid meet');
 
         // Use canvas for small images to get crisp scaling
@@ -256,7 +293,7 @@ export class Renderer {
                 image.setAttribute('href', canvas.toDataURL());
             }
         };
-        tempImg.src = API_PATHS.images(imageId);
+        tempImg.src = src;
 
         parentG.appendChild(image);
     }
@@ -285,6 +322,24 @@ export class Renderer {
         parentG.appendChild(text);
     }
 
+    renderFailedMessage(parentG, error, yPos = NODE_DESIGN.thumbnail.y) {
+        const text = document.createElementNS('http://www.w3.org/2000/svg', 'text');
+        text.classList.add('node-failed-message');
+        text.setAttribute('x', NODE_DESIGN.width / 2);
+        text.setAttribute('y', yPos + NODE_DESIGN.thumbnail.height / 2);
+        text.setAttribute('text-anchor', 'middle');
+        text.setAttribute('dominant-baseline', 'middle');
+        text.textContent = 'Generation Failed';
+
+        if (error) {
+            const title = document.createElementNS('http://www.w3.org/2000/svg', 'title');
+            title.textContent = error;
+            text.appendChild(title);
+        }
+
+        parentG.appendChild(text);
+    }
+
     updateThumbnail(nodeGroup, imageId) {
         // Find existing thumbnail
         const existingThumbnail = nodeGroup.querySelector('.node-thumbnail');
@@ -597,7 +652,8 @@ export class Renderer {
     }
 
     updateNodePosition(nodeId, x, y) {
-        this.nodePositions.set(nodeId, { x, y });
+        // Keep the node's size, color and other saved layout state
+        this.nodePositions.set(nodeId, { ...this.nodePositions.get(nodeId), x, y });
         const nodeElement = this.nodesLayer.querySelector(`[data-node-id="${nodeId}"]`);
         if (nodeElement) {
             nodeElement.setAttribute('transform', `translate(${x},${y})`);
@@ -686,9 +742,11 @@ export class Renderer {
         this.nodePositions.clear();
 
         if (nodePositions) {
-            // nodePositions is now an array of {node_id, x, y}
-            for (const pos of nodePositions) {
-                this.nodePositions.set(pos.node_id, { x: pos.x, y: pos.y });
+            // nodePositions is an array of {node_id, x, y} plus the optional
+            // width, height, collapsed, color and z_order, which are kept so
+            // they are saved back unchanged
+            for (const { node_id, ...layout } of nodePositions) {
+                this.nodePositions.set(node_id, layout);
             }
         }
     }